@@ -2,7 +2,6 @@ package admin
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,11 +24,17 @@ type Admin struct {
 	pollerToPromAddr *timedmap.TimedMap[string, pollerDetails]
 	httpSD           conf.Httpsd
 	expireAfter      time.Duration
+	maintenance      *maintenanceStore
+	logLevels        *logLevelStore
+	probeClient      *http.Client
 }
 
 func (a *Admin) startServer() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/v1/sd", a.APISD)
+	mux.HandleFunc("/api/v1/maintenance", a.APIMaintenance)
+	mux.HandleFunc("/api/v1/loglevel", a.APILogLevel)
+	mux.HandleFunc("/probe", a.APIProbe)
 
 	a.logger.Debug().Str("listen", a.listen).Msg("Admin node starting")
 	server := &http.Server{
@@ -38,9 +43,11 @@ func (a *Admin) startServer() {
 		ReadHeaderTimeout: 60 * time.Second,
 	}
 	if a.httpSD.TLS.KeyFile != "" {
-		server.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS13,
+		tlsConfig, err := a.httpSD.TLS.Options().Server()
+		if err != nil {
+			a.logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
 		}
+		server.TLSConfig = tlsConfig
 	}
 
 	done := make(chan bool)
@@ -118,9 +125,10 @@ func (a *Admin) setupLogger() {
 }
 
 type pollerDetails struct {
-	Name string `json:"Name,omitempty"`
-	IP   string `json:"IP,omitempty"`
-	Port int    `json:"Port,omitempty"`
+	Name   string `json:"Name,omitempty"`
+	IP     string `json:"IP,omitempty"`
+	Port   int    `json:"Port,omitempty"`
+	Scheme string `json:"Scheme,omitempty"` // https when the exporter serves TLS
 }
 
 func (a *Admin) apiPublish(w http.ResponseWriter, r *http.Request) {
@@ -234,8 +242,10 @@ func doAdmin(c *cobra.Command, _ []string) {
 
 func newAdmin(configPath string) Admin {
 	a := Admin{
-		httpSD: conf.Config.Admin.Httpsd,
-		listen: conf.Config.Admin.Httpsd.Listen,
+		httpSD:      conf.Config.Admin.Httpsd,
+		listen:      conf.Config.Admin.Httpsd.Listen,
+		maintenance: &maintenanceStore{},
+		logLevels:   &logLevelStore{},
 	}
 	a.setupLogger()
 	if a.listen == "" {
@@ -243,7 +253,7 @@ func newAdmin(configPath string) Admin {
 			Str("config", configPath).
 			Msg("Admin.address is empty in config. Must be a valid address")
 	}
-	if a.httpSD.TLS != (conf.TLS{}) {
+	if a.httpSD.TLS.CertFile != "" || a.httpSD.TLS.KeyFile != "" {
 		util.CheckCert(a.httpSD.TLS.CertFile, "ssl_cert", configPath, a.logger)
 		util.CheckCert(a.httpSD.TLS.KeyFile, "ssl_key", configPath, a.logger)
 	}
@@ -251,6 +261,15 @@ func newAdmin(configPath string) Admin {
 	a.localIP, _ = util.FindLocalIP()
 	a.expireAfter = a.setDuration(a.httpSD.ExpireAfter, 1*time.Minute, "expire_after")
 	a.pollerToPromAddr = timedmap.New[string, pollerDetails](a.expireAfter)
+
+	// probes verify the certificates of exporters with the CA file and verification options of the admin node
+	tlsConfig, err := a.httpSD.TLS.Options().Client()
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	a.probeClient = &http.Client{Transport: transport}
 	a.logger.Debug().
 		Str("expireAfter", a.expireAfter.String()).
 		Str("localIP", a.localIP).
@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultLogLevelDuration is how long a log level set with the admin API lasts when the request has no duration
+const defaultLogLevelDuration = time.Hour
+
+// logLevelStore holds the log levels set at runtime. Pollers fetch their levels on each heartbeat
+type logLevelStore struct {
+	mu        sync.Mutex
+	overrides []logging.LevelOverride
+}
+
+type logLevelRequest struct {
+	Poller    string `json:"poller"`
+	Collector string `json:"collector"`
+	Level     string `json:"level"`
+	Duration  string `json:"duration,omitempty"`
+}
+
+// APILogLevel lists, sets, and reverts the log levels of collectors
+//
+//	GET    /api/v1/loglevel?poller=name                  levels of poller, or of all pollers when poller is empty
+//	PUT    /api/v1/loglevel                              set a level, body is {"poller": "name", "collector": "Zapi:Volume", "level": "debug", "duration": "1h"}
+//	DELETE /api/v1/loglevel?poller=name&collector=Zapi   revert the level of collector, or all levels of poller when collector is empty
+func (a *Admin) APILogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.httpSD.AuthBasic.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !a.verifyAuth(user, pass) {
+			w.Header().Set("Www-Authenticate", `Basic realm="api"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		a.writeLogLevels(w, a.logLevels.active(r.URL.Query().Get("poller"), time.Now()))
+	case http.MethodPut:
+		a.setLogLevel(w, r)
+	case http.MethodDelete:
+		poller := r.URL.Query().Get("poller")
+		if poller == "" {
+			http.Error(w, "poller is required", http.StatusBadRequest)
+			return
+		}
+		collector := r.URL.Query().Get("collector")
+		n := a.logLevels.end(poller, collector)
+		a.logger.Info().Str("poller", poller).Str("collector", collector).Int("levels", n).Msg("Reverted log levels")
+		_, _ = fmt.Fprintf(w, "OK")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (a *Admin) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.logger.Err(err).Msg("Unable to parse log level json")
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	override, err := newLevelOverride(req, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.logLevels.add(override)
+	a.logger.Info().
+		Str("poller", override.Poller).
+		Str("collector", override.Collector).
+		Str("level", override.Level).
+		Time("until", override.Until).
+		Msg("Set log level")
+	a.writeLogLevels(w, []logging.LevelOverride{override})
+}
+
+func (a *Admin) writeLogLevels(w http.ResponseWriter, overrides []logging.LevelOverride) {
+	j, err := json.Marshal(overrides)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to marshal log levels")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(j)
+}
+
+func newLevelOverride(req logLevelRequest, now time.Time) (logging.LevelOverride, error) {
+	if req.Poller == "" {
+		return logging.LevelOverride{}, fmt.Errorf("poller is required, use %q for all pollers", allPollers)
+	}
+	if req.Collector == "" {
+		return logging.LevelOverride{}, fmt.Errorf("collector is required, e.g. Zapi or Zapi:Volume")
+	}
+	if _, err := logging.ParseLevel(req.Level); err != nil {
+		return logging.LevelOverride{}, err
+	}
+	duration := defaultLogLevelDuration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return logging.LevelOverride{}, fmt.Errorf("duration %q must be a positive duration", req.Duration)
+		}
+		duration = d
+	}
+	return logging.LevelOverride{
+		Poller:    req.Poller,
+		Collector: req.Collector,
+		Level:     req.Level,
+		Until:     now.Add(duration),
+	}, nil
+}
+
+// add sets a level, replacing the level of the same poller and collector
+func (s *logLevelStore) add(override logging.LevelOverride) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = slices.DeleteFunc(s.overrides, func(o logging.LevelOverride) bool {
+		return o.Poller == override.Poller && o.Collector == override.Collector
+	})
+	s.overrides = append(s.overrides, override)
+}
+
+// active returns the levels of poller that have not expired, and removes the ones that have
+func (s *logLevelStore) active(poller string, now time.Time) []logging.LevelOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = slices.DeleteFunc(s.overrides, func(o logging.LevelOverride) bool { return !now.Before(o.Until) })
+
+	overrides := make([]logging.LevelOverride, 0)
+	for _, o := range s.overrides {
+		if poller == "" || o.Poller == poller || o.Poller == allPollers {
+			overrides = append(overrides, o)
+		}
+	}
+	return overrides
+}
+
+// end removes the level of collector of poller, or all levels of poller when collector is empty,
+// and returns how many were removed
+func (s *logLevelStore) end(poller string, collector string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.overrides)
+	s.overrides = slices.DeleteFunc(s.overrides, func(o logging.LevelOverride) bool {
+		return o.Poller == poller && (collector == "" || o.Collector == collector)
+	})
+	return before - len(s.overrides)
+}
@@ -0,0 +1,143 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/maintenance"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// allPollers is the poller name of windows that apply to every poller
+const allPollers = "*"
+
+// maintenanceStore holds the maintenance windows set at runtime. Pollers fetch their windows on each heartbeat
+type maintenanceStore struct {
+	mu      sync.Mutex
+	windows []maintenance.Window
+}
+
+type maintenanceRequest struct {
+	Poller   string   `json:"poller"`
+	Objects  []string `json:"objects,omitempty"`
+	Action   string   `json:"action,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// APIMaintenance lists, starts, and ends maintenance windows
+//
+//	GET    /api/v1/maintenance?poller=name  active windows of poller, or of all pollers when poller is empty
+//	PUT    /api/v1/maintenance              start a window, body is {"poller": "name", "objects": [], "action": "suppress", "duration": "2h"}
+//	DELETE /api/v1/maintenance?poller=name  end the windows of poller
+func (a *Admin) APIMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.httpSD.AuthBasic.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !a.verifyAuth(user, pass) {
+			w.Header().Set("Www-Authenticate", `Basic realm="api"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		a.writeWindows(w, a.maintenance.active(r.URL.Query().Get("poller"), time.Now()))
+	case http.MethodPut:
+		a.startMaintenance(w, r)
+	case http.MethodDelete:
+		poller := r.URL.Query().Get("poller")
+		if poller == "" {
+			http.Error(w, "poller is required", http.StatusBadRequest)
+			return
+		}
+		n := a.maintenance.end(poller)
+		a.logger.Info().Str("poller", poller).Int("windows", n).Msg("Ended maintenance")
+		_, _ = fmt.Fprintf(w, "OK")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (a *Admin) startMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.logger.Err(err).Msg("Unable to parse maintenance json")
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	window, err := newWindow(req, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.maintenance.add(window)
+	a.logger.Info().
+		Str("poller", window.Poller).
+		Strs("objects", window.Objects).
+		Str("action", string(window.Action)).
+		Time("until", window.Until).
+		Msg("Started maintenance")
+	a.writeWindows(w, []maintenance.Window{window})
+}
+
+func (a *Admin) writeWindows(w http.ResponseWriter, windows []maintenance.Window) {
+	j, err := json.Marshal(windows)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to marshal maintenance windows")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(j)
+}
+
+func newWindow(req maintenanceRequest, now time.Time) (maintenance.Window, error) {
+	if req.Poller == "" {
+		return maintenance.Window{}, fmt.Errorf("poller is required, use %q for all pollers", allPollers)
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		return maintenance.Window{}, fmt.Errorf("duration %q must be a positive duration", req.Duration)
+	}
+	action, err := maintenance.ParseAction(req.Action)
+	if err != nil {
+		return maintenance.Window{}, err
+	}
+	return maintenance.Window{
+		Poller:  req.Poller,
+		Objects: req.Objects,
+		Action:  action,
+		Until:   now.Add(duration),
+	}, nil
+}
+
+func (s *maintenanceStore) add(window maintenance.Window) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = append(s.windows, window)
+}
+
+// active returns the windows of poller that have not ended, and removes the ones that have
+func (s *maintenanceStore) active(poller string, now time.Time) []maintenance.Window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = slices.DeleteFunc(s.windows, func(w maintenance.Window) bool { return !now.Before(w.Until) })
+
+	windows := make([]maintenance.Window, 0)
+	for _, w := range s.windows {
+		if poller == "" || w.Poller == poller || w.Poller == allPollers {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// end removes the windows of poller and returns how many were removed
+func (s *maintenanceStore) end(poller string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.windows)
+	s.windows = slices.DeleteFunc(s.windows, func(w maintenance.Window) bool { return w.Poller == poller })
+	return before - len(s.windows)
+}
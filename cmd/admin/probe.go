@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultProbeTimeout is the timeout of a probe when Prometheus does not send its scrape timeout
+	defaultProbeTimeout = 30 * time.Second
+	// scrapeTimeoutHeader is the header with the scrape timeout of Prometheus in seconds
+	scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+)
+
+// APIProbe serves the metrics of the Prometheus exporter of the poller named by the target parameter, like the
+// blackbox exporter, so Prometheus scrapes every poller through the admin node and its service discovery decides
+// which clusters are scraped. target is the name or the addr of a poller that published its exporter to the admin node
+//
+//	GET /probe?target=cluster-01
+func (a *Admin) APIProbe(w http.ResponseWriter, r *http.Request) {
+	if a.httpSD.AuthBasic.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !a.verifyAuth(user, pass) {
+			w.Header().Set("Www-Authenticate", `Basic realm="api"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+	details, ok := a.probeTarget(target)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no poller named %s has published its Prometheus exporter", target), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout(r))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, details.metricsURL(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accept := r.Header.Get("Accept"); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	// the credentials of the exporter in harvest.yml are only sent for callers that authenticated to the admin node,
+	// otherwise anyone who reaches the admin node could read the metrics of exporters that require auth.
+	// Without admin auth, the caller authenticates to the exporter with its own Authorization header
+	if a.httpSD.AuthBasic.Username != "" {
+		setProbeAuth(req, probeAuth(details.Name))
+	} else if authorization := r.Header.Get("Authorization"); authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := a.probeClient.Do(req)
+	if err != nil {
+		a.logger.Warn().Err(err).Str("target", target).Str("url", req.URL.String()).Msg("Probe failed")
+		http.Error(w, "probe failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// probeTarget returns the published exporter of the poller named target, or of the poller whose addr is target
+func (a *Admin) probeTarget(target string) (pollerDetails, bool) {
+	if details, ok := a.pollerToPromAddr.GetValue(target); ok {
+		return details, true
+	}
+	for name, poller := range conf.Config.Pollers {
+		if poller.Addr != target {
+			continue
+		}
+		if details, ok := a.pollerToPromAddr.GetValue(name); ok {
+			return details, true
+		}
+	}
+	return pollerDetails{}, false
+}
+
+// probeAuth returns the auth of the Prometheus exporter of the poller named name in harvest.yml, or nil when the
+// exporter does not authenticate scrapes. Like bin/harvest, the last Prometheus exporter of the poller is used
+func probeAuth(name string) *conf.ExporterAuth {
+	poller, ok := conf.Config.Pollers[name]
+	if !ok {
+		return nil
+	}
+	for i := len(poller.Exporters) - 1; i >= 0; i-- {
+		if e, ok := conf.Config.Exporters[poller.Exporters[i]]; ok && e.Type == "Prometheus" {
+			return e.Auth
+		}
+	}
+	return nil
+}
+
+// setProbeAuth sets the credentials of auth on the probe req, the bearer token when auth has one,
+// otherwise the username and password. The caller must have authenticated to the admin node
+func setProbeAuth(req *http.Request, auth *conf.ExporterAuth) {
+	switch {
+	case auth == nil:
+	case auth.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+	case auth.Username != "":
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+}
+
+// probeTimeout returns the scrape timeout of Prometheus, less a little so the admin node answers before
+// Prometheus gives up
+func probeTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64)
+	if err != nil || seconds <= 0 {
+		return defaultProbeTimeout
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	return max(timeout-500*time.Millisecond, timeout/2)
+}
+
+func (p pollerDetails) metricsURL() string {
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + net.JoinHostPort(p.IP, strconv.Itoa(p.Port)) + "/metrics"
+}
@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/rs/zerolog"
+	"github.com/zekroTJA/timedmap/v2"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAPIProbe(t *testing.T) {
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = io.WriteString(w, "volume_size{volume=\"vol1\"} 42\n")
+	}))
+	defer exporter.Close()
+	host, port, _ := net.SplitHostPort(exporter.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	pollers, exporters := conf.Config.Pollers, conf.Config.Exporters
+	t.Cleanup(func() {
+		conf.Config.Pollers, conf.Config.Exporters = pollers, exporters
+	})
+	conf.Config.Pollers = map[string]*conf.Poller{"cluster-01": {Addr: "10.0.0.1"}}
+	a := Admin{
+		logger:           zerolog.Nop(),
+		pollerToPromAddr: timedmap.New[string, pollerDetails](time.Minute),
+		probeClient:      exporter.Client(),
+	}
+	a.pollerToPromAddr.Set("cluster-01", pollerDetails{Name: "cluster-01", IP: host, Port: portNum}, time.Minute)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "poller name", target: "cluster-01", wantStatus: http.StatusOK, wantBody: "volume_size{volume=\"vol1\"} 42\n"},
+		{name: "poller addr", target: "10.0.0.1", wantStatus: http.StatusOK, wantBody: "volume_size{volume=\"vol1\"} 42\n"},
+		{name: "unknown target", target: "cluster-02", wantStatus: http.StatusNotFound},
+		{name: "missing target", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.APIProbe(w, httptest.NewRequest(http.MethodGet, "/probe?target="+tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status got=%d, want=%d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body got=%q, want=%q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIProbeAuth(t *testing.T) {
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if r.Header.Get("Authorization") != "Bearer secret" && (!ok || user != "prom" || pass != "pass") {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, "volume_size{volume=\"vol1\"} 42\n")
+	}))
+	defer exporter.Close()
+	host, port, _ := net.SplitHostPort(exporter.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	pollers, exporters := conf.Config.Pollers, conf.Config.Exporters
+	t.Cleanup(func() {
+		conf.Config.Pollers, conf.Config.Exporters = pollers, exporters
+	})
+	conf.Config.Pollers = map[string]*conf.Poller{
+		"token":   {Exporters: []string{"influx", "prom-token"}},
+		"basic":   {Exporters: []string{"prom-basic"}},
+		"no-auth": {Exporters: []string{"prom"}},
+	}
+	conf.Config.Exporters = map[string]conf.Exporter{
+		"influx":     {Type: "InfluxDB"},
+		"prom":       {Type: "Prometheus"},
+		"prom-token": {Type: "Prometheus", Auth: &conf.ExporterAuth{BearerToken: "secret"}},
+		"prom-basic": {Type: "Prometheus", Auth: &conf.ExporterAuth{Username: "prom", Password: "pass"}},
+	}
+	newAdmin := func(adminAuth bool) *Admin {
+		a := &Admin{
+			logger:           zerolog.Nop(),
+			pollerToPromAddr: timedmap.New[string, pollerDetails](time.Minute),
+			probeClient:      exporter.Client(),
+		}
+		if adminAuth {
+			a.httpSD.AuthBasic.Username = "admin"
+			a.httpSD.AuthBasic.Password = "admin-pass"
+		}
+		return a
+	}
+
+	tests := []struct {
+		name          string
+		target        string
+		adminAuth     bool
+		authorization string // Authorization header of the caller, basic auth of the admin node when adminAuth is set
+		wantStatus    int
+	}{
+		{name: "token", target: "token", adminAuth: true, wantStatus: http.StatusOK},
+		{name: "basic", target: "basic", adminAuth: true, wantStatus: http.StatusOK},
+		{name: "exporter without auth", target: "no-auth", adminAuth: true, wantStatus: http.StatusUnauthorized},
+		// the stored credentials are not sent for callers that did not authenticate to the admin node
+		{name: "caller not authenticated", target: "token", adminAuth: true, authorization: "none", wantStatus: http.StatusUnauthorized},
+		{name: "admin without auth", target: "token", wantStatus: http.StatusUnauthorized},
+		{name: "admin without auth forwards caller auth", target: "token", authorization: "Bearer secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmin(tt.adminAuth)
+			a.pollerToPromAddr.Set(tt.target, pollerDetails{Name: tt.target, IP: host, Port: portNum}, time.Minute)
+			r := httptest.NewRequest(http.MethodGet, "/probe?target="+tt.target, nil)
+			switch {
+			case tt.adminAuth && tt.authorization == "":
+				r.SetBasicAuth("admin", "admin-pass")
+			case tt.authorization != "" && tt.authorization != "none":
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			a.APIProbe(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status got=%d, want=%d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: defaultProbeTimeout},
+		{header: "abc", want: defaultProbeTimeout},
+		{header: "10", want: 9500 * time.Millisecond},
+		{header: "0.5", want: 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/probe", nil)
+			r.Header.Set(scrapeTimeoutHeader, tt.header)
+			if got := probeTimeout(r); got != tt.want {
+				t.Errorf("probeTimeout got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Package aiqum collects cluster, aggregate, and volume metrics from the REST API of Active IQ Unified Manager.
+// It is used when pollers can not reach the clusters directly, but can reach the Unified Manager that monitors them.
+//
+// The records of Unified Manager are parsed with the counters of the Rest collector, so templates use the same
+// syntax and go through the same plugins and export options as the Rest templates.
+package aiqum
+
+import (
+	"fmt"
+	rest2 "github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"strings"
+	"time"
+)
+
+// templateVersion is the version used to pick the templates of an object.
+// The datacenter API of Unified Manager is versioned by its path, so templates do not depend on the version of UM
+var templateVersion = [3]int{9, 14, 0}
+
+// probeQuery is requested when the collector starts, to check that Unified Manager is reachable and the user can
+// read the datacenter API
+const probeQuery = "api/datacenter/cluster/clusters?max_records=1"
+
+type Aiqum struct {
+	*rest2.Rest // provides: AbstractCollector, Client, Prop, InitCache, HandleResults
+	href        string
+}
+
+func init() {
+	plugin.RegisterModule(&Aiqum{})
+}
+
+func (a *Aiqum) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.aiqum",
+		New: func() plugin.Module { return new(Aiqum) },
+	}
+}
+
+func (a *Aiqum) Init(ac *collector.AbstractCollector) error {
+
+	var err error
+
+	a.Rest = &rest2.Rest{AbstractCollector: ac}
+
+	a.InitProp()
+
+	if err := a.initClient(); err != nil {
+		return err
+	}
+
+	if a.Prop.TemplatePath, err = a.LoadTemplate(); err != nil {
+		return err
+	}
+
+	a.InitVars(ac.Params)
+
+	if err := collector.Init(a); err != nil {
+		return err
+	}
+
+	if err := a.InitCache(); err != nil {
+		return err
+	}
+
+	if err := a.InitMatrix(); err != nil {
+		return err
+	}
+
+	a.href = buildHref(a.Prop.Query, a.Prop.Filter)
+
+	a.Logger.Debug().
+		Int("numMetrics", len(a.Prop.Metrics)).
+		Str("href", a.href).
+		Str("timeout", a.Client.Timeout.String()).
+		Msg("initialized cache")
+
+	return nil
+}
+
+// initClient creates the client of Unified Manager. Unlike ONTAP, UM has no cluster to describe,
+// so the client is only checked with a request of the datacenter API
+func (a *Aiqum) initClient() error {
+	var (
+		poller *conf.Poller
+		err    error
+	)
+
+	if a.Options.IsTest {
+		a.Client = &rest.Client{Metadata: &util.Metadata{}}
+		return nil
+	}
+	if poller, err = conf.PollerNamed(a.Options.Poller); err != nil {
+		return err
+	}
+	if poller.Addr == "" {
+		return errs.New(errs.ErrMissingParam, "addr")
+	}
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if a.Client, err = rest.New(poller, timeout, a.Auth); err != nil {
+		return err
+	}
+	if _, err = a.Client.GetRest(probeQuery); err != nil {
+		return fmt.Errorf("failed to reach Unified Manager: %w", err)
+	}
+	a.Client.TraceLogSet(a.Name, a.Params)
+
+	return nil
+}
+
+// Estimate is not supported: the AIQUM API has no count of records
+func (a *Aiqum) Estimate() ([]collector.Estimate, error) {
+	return nil, errs.New(errs.ErrImplement, "Aiqum can not estimate without polling")
+}
+
+func (a *Aiqum) LoadTemplate() (string, error) {
+
+	jitter := a.Params.GetChildContentS("jitter")
+	template, path, err := a.ImportSubTemplate("", rest2.TemplateFn(a.Params, a.Object), jitter, templateVersion)
+	if err != nil {
+		return "", err
+	}
+
+	a.Params.Union(template)
+	return path, nil
+}
+
+// InitMatrix does not set the cluster label of the matrix, since UM monitors many clusters.
+// Templates read the cluster of each record instead, e.g. cluster.name => cluster
+func (a *Aiqum) InitMatrix() error {
+	mat := a.Matrix[a.Object]
+	// overwrite from abstract collector
+	mat.Object = a.Prop.Object
+
+	if a.Params.HasChildS("labels") {
+		for _, l := range a.Params.GetChildS("labels").GetChildren() {
+			mat.SetGlobalLabel(l.GetNameS(), l.GetContentS())
+		}
+	}
+
+	return nil
+}
+
+// buildHref returns the request of query. UM pages its records with _links.next, like ONTAP, but does not accept
+// the fields and return_timeout arguments of ONTAP, so only the filters of the template are added
+func buildHref(query string, filter []string) string {
+	href := query
+	if !strings.HasPrefix(href, "api/") {
+		href = "api/" + href
+	}
+	if len(filter) > 0 {
+		href += "?" + strings.Join(filter, "&")
+	}
+	return href
+}
+
+func (a *Aiqum) PollData() (map[string]*matrix.Matrix, error) {
+
+	var (
+		count        uint64
+		apiD, parseD time.Duration
+		records      []gjson.Result
+		err          error
+	)
+
+	a.Client.Metadata.Reset()
+	mat := a.Matrix[a.Object]
+	mat.Reset()
+
+	startTime := time.Now()
+	if records, err = rest.Fetch(a.Client, a.href); err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
+	}
+	apiD = time.Since(startTime)
+
+	if len(records) == 0 {
+		return nil, errs.New(errs.ErrNoInstance, "no "+a.Object+" instances on Unified Manager")
+	}
+
+	startTime = time.Now()
+	count, _ = a.HandleResults(mat, records, a.Prop, false)
+	parseD = time.Since(startTime)
+
+	_ = a.Metadata.LazySetValueInt64("api_time", "data", apiD.Microseconds())
+	_ = a.Metadata.LazySetValueInt64("parse_time", "data", parseD.Microseconds())
+	_ = a.Metadata.LazySetValueUint64("metrics", "data", count)
+	_ = a.Metadata.LazySetValueUint64("instances", "data", uint64(len(mat.GetInstances())))
+	_ = a.Metadata.LazySetValueUint64("bytesRx", "data", a.Client.Metadata.BytesRx)
+	_ = a.Metadata.LazySetValueUint64("bytesRxWire", "data", a.Client.Metadata.BytesRxWire)
+	_ = a.Metadata.LazySetValueUint64("numCalls", "data", a.Client.Metadata.NumCalls)
+
+	a.AddCollectCount(count)
+
+	return a.Matrix, nil
+}
+
+// PollCounter does nothing, the templates of UM have no counter task. It replaces the counter poll of the
+// Rest collector, which reads the cluster of an ONTAP system
+func (a *Aiqum) PollCounter() (map[string]*matrix.Matrix, error) {
+	return nil, nil
+}
+
+// LoadPlugin has no plugins of its own, templates use the built-in plugins, e.g. LabelAgent and MetricAgent.
+// The plugins of the Rest collector are not loaded, since they request ONTAP, which the poller can not reach
+func (a *Aiqum) LoadPlugin(kind string, _ *plugin.AbstractPlugin) plugin.Plugin {
+	a.Logger.Warn().Str("kind", kind).Msg("plugin not found")
+	return nil
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*Aiqum)(nil)
+)
@@ -0,0 +1,96 @@
+package aiqum
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"testing"
+)
+
+const (
+	pollerName = "test"
+)
+
+// newAiqum initializes an Aiqum collector with the templates shipped in conf/aiqum
+func newAiqum(object string, path string) (*Aiqum, error) {
+	opts := options.New(options.WithConfPath("../../../conf"))
+	opts.Poller = pollerName
+	opts.IsTest = true
+	ac := collector.New("Aiqum", object, opts, collectors.Params(object, path), nil)
+	a := Aiqum{}
+	if err := a.Init(ac); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func TestAiqum_Volumes(t *testing.T) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+
+	a, err := newAiqum("Volume", "volume.yaml")
+	if err != nil {
+		t.Fatalf("failed to create Aiqum collector: %v", err)
+	}
+
+	if want := "api/datacenter/storage/volumes?type=rw"; a.href != want {
+		t.Errorf("href got=%s, want=%s", a.href, want)
+	}
+
+	mat := a.Matrix[a.Object]
+	records := collectors.JSONToGson("testdata/volumes.json", true)
+	a.HandleResults(mat, records, a.Prop, false)
+
+	if got := len(mat.GetInstances()); got != 2 {
+		t.Fatalf("instances got=%d, want=2", got)
+	}
+
+	// volumes of the same name on two clusters are separate instances, labeled with their cluster
+	tests := []struct {
+		uuid    string
+		cluster string
+		aggr    string
+		size    float64
+	}{
+		{uuid: "0b2a6d21-5c8e-4f5b-9a57-6d3c7a1f2e01", cluster: "umeng-aff300-01-02", aggr: "aggr1", size: 107374182400},
+		{uuid: "5e4f3a2b-1c0d-4e9f-8a7b-6c5d4e3f2a10", cluster: "umeng-a400-03-04", aggr: "aggr1,aggr2", size: 214748364800},
+	}
+	size := mat.GetMetric("space.size")
+	if size == nil {
+		t.Fatal("metric space.size is missing")
+	}
+	for _, tt := range tests {
+		instance := mat.GetInstance(tt.uuid)
+		if instance == nil {
+			t.Errorf("instance %s is missing", tt.uuid)
+			continue
+		}
+		if got := instance.GetLabel("cluster"); got != tt.cluster {
+			t.Errorf("%s cluster got=%s, want=%s", tt.uuid, got, tt.cluster)
+		}
+		if got := instance.GetLabel("aggr"); got != tt.aggr {
+			t.Errorf("%s aggr got=%s, want=%s", tt.uuid, got, tt.aggr)
+		}
+		if got, _ := size.GetValueFloat64(instance); got != tt.size {
+			t.Errorf("%s size got=%f, want=%f", tt.uuid, got, tt.size)
+		}
+	}
+}
+
+func TestBuildHref(t *testing.T) {
+	tests := []struct {
+		query  string
+		filter []string
+		want   string
+	}{
+		{query: "api/datacenter/cluster/clusters", want: "api/datacenter/cluster/clusters"},
+		{query: "datacenter/storage/aggregates", want: "api/datacenter/storage/aggregates"},
+		{query: "api/datacenter/storage/volumes", filter: []string{"type=rw", "state=online"},
+			want: "api/datacenter/storage/volumes?type=rw&state=online"},
+	}
+	for _, tt := range tests {
+		if got := buildHref(tt.query, tt.filter); got != tt.want {
+			t.Errorf("buildHref(%s) got=%s, want=%s", tt.query, got, tt.want)
+		}
+	}
+}
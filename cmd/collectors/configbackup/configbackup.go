@@ -0,0 +1,343 @@
+// Copyright NetApp Inc, 2024 All rights reserved
+
+/*
+Package configbackup implements a collector that periodically fetches configuration documents of a cluster,
+e.g. export policies, snapshot policies, and network routes, and detects when they change.
+
+Each document is the sorted records of one REST API endpoint, without their links.
+The collector exports a hash of each document and a counter of its changes, and optionally archives each changed
+document as JSON, so configuration changes can be tracked without a separate CMDB tool.
+*/
+package configbackup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxArchives = 100
+	// archiveTime is the format of the names of archived documents, which sort by time
+	archiveTime = "20060102T150405Z"
+)
+
+// document is one configuration document of the cluster
+type document struct {
+	name    string
+	api     string
+	hash    string // hash of the latest document, empty until the document is fetched
+	changes uint64
+}
+
+type ConfigBackup struct {
+	*collector.AbstractCollector
+	client      *rest.Client
+	documents   []*document
+	archiveDir  string // archive changed documents in this directory, when set
+	maxArchives int    // the maximum number of archives of each document
+}
+
+func init() {
+	plugin.RegisterModule(&ConfigBackup{})
+}
+
+func (c *ConfigBackup) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.configbackup",
+		New: func() plugin.Module { return new(ConfigBackup) },
+	}
+}
+
+func (c *ConfigBackup) Init(a *collector.AbstractCollector) error {
+	c.AbstractCollector = a
+
+	if err := collector.Init(c); err != nil {
+		return err
+	}
+
+	documents := c.Params.GetChildS("documents")
+	if documents == nil || len(documents.GetChildren()) == 0 {
+		return errs.New(errs.ErrMissingParam, "documents")
+	}
+	for _, d := range documents.GetChildren() {
+		name, api := d.GetNameS(), strings.TrimPrefix(d.GetContentS(), "/")
+		if name == "" || api == "" {
+			return errs.New(errs.ErrInvalidParam, "documents: "+name+" needs a name and an API path")
+		}
+		c.documents = append(c.documents, &document{name: name, api: api})
+	}
+
+	if c.archiveDir = c.Params.GetChildContentS("archive_dir"); c.archiveDir != "" {
+		c.archiveDir = filepath.Join(c.archiveDir, c.Options.Poller)
+		c.maxArchives = defaultMaxArchives
+		if x := c.Params.GetChildContentS("max_archives"); x != "" {
+			n, err := strconv.Atoi(x)
+			if err != nil || n < 1 {
+				return errs.New(errs.ErrInvalidParam, "max_archives ("+x+") must be a positive number")
+			}
+			c.maxArchives = n
+		}
+		// the latest archives are the baseline of the documents, so changes made while the poller was down are detected
+		for _, d := range c.documents {
+			d.hash = c.latestArchive(d)
+		}
+	}
+
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	mat := c.Matrix[c.Object]
+	for _, name := range []string{"hash", "changes", "records", "last_change"} {
+		if _, err := mat.NewMetricUint64(name); err != nil {
+			return err
+		}
+	}
+	mat.SetGlobalLabel("cluster", c.client.Cluster().Name)
+	if c.Params.HasChildS("labels") {
+		for _, l := range c.Params.GetChildS("labels").GetChildren() {
+			mat.SetGlobalLabel(l.GetNameS(), l.GetContentS())
+		}
+	}
+
+	c.Logger.Debug().Int("documents", len(c.documents)).Str("archiveDir", c.archiveDir).Msg("initialized")
+	return nil
+}
+
+func (c *ConfigBackup) initClient() error {
+	poller, err := conf.PollerNamed(c.Options.Poller)
+	if err != nil {
+		return err
+	}
+
+	clientTimeout := c.Params.GetChildContentS("client_timeout")
+	if clientTimeout == "" {
+		clientTimeout = rest.DefaultTimeout
+	}
+	timeout, err := time.ParseDuration(clientTimeout)
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, "client_timeout ("+clientTimeout+"): "+err.Error())
+	}
+
+	if c.Options.IsTest {
+		c.client = &rest.Client{Metadata: &util.Metadata{}, Timeout: timeout}
+		return nil
+	}
+	if c.client, err = rest.New(poller, timeout, c.Auth); err != nil {
+		return err
+	}
+	if err := c.client.Init(5); err != nil {
+		return err
+	}
+	c.client.TraceLogSet(c.Name, c.Params)
+	return nil
+}
+
+// PollData fetches each document, and counts and archives the documents that changed since the previous poll
+func (c *ConfigBackup) PollData() (map[string]*matrix.Matrix, error) {
+	mat := c.Matrix[c.Object]
+	c.client.Metadata.Reset()
+	start := time.Now()
+
+	var errList []error
+	for _, d := range c.documents {
+		records, err := rest.Fetch(c.client, href(d.api))
+		if err != nil {
+			c.Logger.Warn().Err(err).Str("document", d.name).Str("api", d.api).Msg("Failed to fetch document")
+			errList = append(errList, fmt.Errorf("%s: %w", d.name, err))
+			continue
+		}
+		raw := make([]string, 0, len(records))
+		for _, r := range records {
+			raw = append(raw, r.Raw)
+		}
+		content, err := canonical(raw)
+		if err != nil {
+			c.Logger.Warn().Err(err).Str("document", d.name).Msg("Failed to parse document")
+			errList = append(errList, fmt.Errorf("%s: %w", d.name, err))
+			continue
+		}
+		c.update(mat, d, content, len(records), start)
+	}
+
+	if len(errList) == len(c.documents) {
+		return nil, errors.Join(errList...)
+	}
+
+	_ = c.Metadata.LazySetValueInt64("api_time", "data", time.Since(start).Microseconds())
+	_ = c.Metadata.LazySetValueUint64("instances", "data", uint64(len(mat.GetInstances())))
+	_ = c.Metadata.LazySetValueUint64("metrics", "data", uint64(len(mat.GetInstances())*len(mat.GetMetrics())))
+	_ = c.Metadata.LazySetValueUint64("bytesRx", "data", c.client.Metadata.BytesRx)
+	_ = c.Metadata.LazySetValueUint64("bytesRxWire", "data", c.client.Metadata.BytesRxWire)
+	_ = c.Metadata.LazySetValueUint64("numCalls", "data", c.client.Metadata.NumCalls)
+	c.AddCollectCount(uint64(len(mat.GetInstances())))
+
+	return c.Matrix, nil
+}
+
+// update sets the metrics of the instance of d, and counts and archives content when it changed
+func (c *ConfigBackup) update(mat *matrix.Matrix, d *document, content []byte, records int, now time.Time) {
+	instance := mat.GetInstance(d.name)
+	if instance == nil {
+		var err error
+		if instance, err = mat.NewInstance(d.name); err != nil {
+			c.Logger.Error().Err(err).Str("document", d.name).Msg("Failed to add instance")
+			return
+		}
+		instance.SetLabel("document", d.name)
+		instance.SetLabel("api", d.api)
+	}
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	// the first fetch of a document without an archive is its baseline
+	if d.hash != "" && d.hash != hash {
+		d.changes++
+		_ = mat.GetMetric("last_change").SetValueUint64(instance, uint64(now.Unix()))
+		c.Logger.Info().Str("document", d.name).Str("hash", hash[:12]).Str("previous", d.hash[:12]).Msg("Configuration changed")
+	}
+	if c.archiveDir != "" && d.hash != hash {
+		if err := c.archive(d, content, now); err != nil {
+			c.Logger.Error().Err(err).Str("document", d.name).Msg("Failed to archive document")
+		}
+	}
+	d.hash = hash
+
+	instance.SetLabel("hash", hash[:12])
+	// 48 bits of the hash, which a float64 holds exactly
+	_ = mat.GetMetric("hash").SetValueUint64(instance, binary.BigEndian.Uint64(sum[:8])>>16)
+	_ = mat.GetMetric("changes").SetValueUint64(instance, d.changes)
+	_ = mat.GetMetric("records").SetValueUint64(instance, uint64(records))
+}
+
+// archive writes content to a new file of d, and removes its oldest files beyond maxArchives
+func (c *ConfigBackup) archive(d *document, content []byte, now time.Time) error {
+	dir := filepath.Join(c.archiveDir, d.name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, content, "", "  "); err != nil {
+		return err
+	}
+	pretty.WriteByte('\n')
+	name := filepath.Join(dir, now.UTC().Format(archiveTime)+".json")
+	if err := os.WriteFile(name, pretty.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	archives := c.archives(dir)
+	for len(archives) > c.maxArchives {
+		if err := os.Remove(filepath.Join(dir, archives[0])); err != nil {
+			return err
+		}
+		archives = archives[1:]
+	}
+	return nil
+}
+
+// archives returns the sorted names of the archives in dir, oldest first
+func (c *ConfigBackup) archives(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// latestArchive returns the hash of the latest archive of d, or an empty string when there is none
+func (c *ConfigBackup) latestArchive(d *document) string {
+	dir := filepath.Join(c.archiveDir, d.name)
+	archives := c.archives(dir)
+	if len(archives) == 0 {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, archives[len(archives)-1]))
+	if err != nil {
+		c.Logger.Warn().Err(err).Str("document", d.name).Msg("Failed to read latest archive")
+		return ""
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		c.Logger.Warn().Err(err).Str("document", d.name).Msg("Failed to parse latest archive")
+		return ""
+	}
+	sum := sha256.Sum256(compact.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// href returns the href of api with all fields, unless api has its own query
+func href(api string) string {
+	if strings.Contains(api, "?") {
+		return api
+	}
+	return rest.NewHrefBuilder().APIPath(api).Fields([]string{"**"}).Build()
+}
+
+// canonical returns the records as a JSON array that only changes when the configuration changes:
+// keys are sorted, links are removed, and records are sorted
+func canonical(records []string) ([]byte, error) {
+	sorted := make([]string, 0, len(records))
+	for _, r := range records {
+		var v any
+		// numbers are kept as they are, instead of float64
+		decoder := json.NewDecoder(strings.NewReader(r))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		// Marshal sorts the keys of maps
+		data, err := json.Marshal(withoutLinks(v))
+		if err != nil {
+			return nil, err
+		}
+		sorted = append(sorted, string(data))
+	}
+	slices.Sort(sorted)
+	return []byte("[" + strings.Join(sorted, ",") + "]"), nil
+}
+
+func withoutLinks(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		delete(t, "_links")
+		for k, child := range t {
+			t[k] = withoutLinks(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = withoutLinks(child)
+		}
+	}
+	return v
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*ConfigBackup)(nil)
+)
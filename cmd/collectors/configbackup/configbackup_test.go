@@ -0,0 +1,96 @@
+package configbackup
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+	}{
+		{name: "empty", want: "[]"},
+		{
+			name:    "sorted keys and records",
+			records: []string{`{"name":"b","id":2}`, `{"name":"a","id":1}`},
+			want:    `[{"id":1,"name":"a"},{"id":2,"name":"b"}]`,
+		},
+		{
+			name:    "without links",
+			records: []string{`{"name":"default","_links":{"self":{"href":"/api/x"}},"rules":[{"index":1,"_links":{}}]}`},
+			want:    `[{"name":"default","rules":[{"index":1}]}]`,
+		},
+		{
+			name:    "large numbers",
+			records: []string{`{"size":9007199254740993}`},
+			want:    `[{"size":9007199254740993}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonical(tt.records)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("canonical() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	newCollector := func() (*ConfigBackup, *matrix.Matrix) {
+		c := &ConfigBackup{
+			AbstractCollector: &collector.AbstractCollector{Logger: logging.Get()},
+			archiveDir:        dir,
+			maxArchives:       2,
+		}
+		mat := matrix.New("ConfigBackup", "config_backup", "config_backup")
+		for _, name := range []string{"hash", "changes", "records", "last_change"} {
+			_, _ = mat.NewMetricUint64(name)
+		}
+		return c, mat
+	}
+
+	c, mat := newCollector()
+	d := &document{name: "export_policies", api: "api/protocols/nfs/export-policies"}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name        string
+		content     string
+		wantChanges uint64
+		wantFiles   int
+	}{
+		{name: "baseline", content: `[{"name":"default"}]`, wantChanges: 0, wantFiles: 1},
+		{name: "unchanged", content: `[{"name":"default"}]`, wantChanges: 0, wantFiles: 1},
+		{name: "changed", content: `[{"name":"default"},{"name":"nfs"}]`, wantChanges: 1, wantFiles: 2},
+		{name: "changed again", content: `[{"name":"nfs"}]`, wantChanges: 2, wantFiles: 2},
+	}
+	for i, s := range steps {
+		c.update(mat, d, []byte(s.content), 1, start.Add(time.Duration(i)*time.Hour))
+		instance := mat.GetInstance(d.name)
+		if got, _ := mat.GetMetric("changes").GetValueUint64(instance); got != s.wantChanges {
+			t.Errorf("%s: changes got=%d, want=%d", s.name, got, s.wantChanges)
+		}
+		files, _ := os.ReadDir(filepath.Join(dir, d.name))
+		if len(files) != s.wantFiles {
+			t.Errorf("%s: archives got=%d, want=%d", s.name, len(files), s.wantFiles)
+		}
+	}
+
+	// a restarted collector starts from the latest archive
+	restarted, _ := newCollector()
+	if got := restarted.latestArchive(d); got != d.hash {
+		t.Errorf("latestArchive() got=%s, want=%s", got, d.hash)
+	}
+}
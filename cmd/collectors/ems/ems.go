@@ -278,6 +278,11 @@ func (e *Ems) fetchEMSData(href string) ([]gjson.Result, error) {
 
 // PollInstance queries the cluster's EMS catalog and intersects that catalog with the EMS template.
 // This is required because ONTAP EMS Rest endpoint fails when queried for an EMS message that does not exist.
+// Estimate is not supported: events are only known once they are polled
+func (e *Ems) Estimate() ([]collector.Estimate, error) {
+	return nil, errs.New(errs.ErrImplement, "Ems can not estimate without polling")
+}
+
 func (e *Ems) PollInstance() (map[string]*matrix.Matrix, error) {
 	var (
 		err              error
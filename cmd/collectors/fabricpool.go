@@ -1,10 +1,18 @@
 package collectors
 
 import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/logging"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"maps"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func GetFlexGroupFabricPoolMetrics(dataMap map[string]*matrix.Matrix, object string, opName string, includeConstituents bool, l *logging.Logger) (*matrix.Matrix, error) {
@@ -143,3 +151,282 @@ func GetFlexGroupFabricPoolMetrics(dataMap map[string]*matrix.Matrix, object str
 	}
 	return cache, nil
 }
+
+const (
+	bytesPerGiB     = 1024 * 1024 * 1024
+	secondsPerMonth = 30 * 24 * 60 * 60
+)
+
+// tieringMetrics are the metrics of the tiering economics of a volume or aggregate
+var tieringMetrics = []string{
+	"performance_tier_footprint",
+	"capacity_tier_footprint",
+	"inactive_data",
+	"cold_data_percent",
+	"tiering_rate",
+	"object_store_cost",
+}
+
+// VolumeFootprint is the space a FabricPool volume uses in the performance and capacity tiers, in bytes
+type VolumeFootprint struct {
+	SVM             string
+	Volume          string
+	Aggr            string // empty for volumes that span aggregates
+	CloudTarget     string
+	PerformanceTier float64
+	CapacityTier    float64
+	InactiveData    float64
+}
+
+type tierSample struct {
+	capacityTier float64
+	time         time.Time
+}
+
+// Tiering computes the tiering economics of FabricPool volumes and aggregates:
+// the percent of their data that is cold, the rate data is tiered to the object store, and the cost of the object store
+type Tiering struct {
+	client     *rest.Client
+	pricePerGB float64
+	rate       int // number of polls between collects
+	polls      int
+	previous   map[string]tierSample // capacity tier footprint by volume and aggregate
+	volumes    *matrix.Matrix
+	aggrs      *matrix.Matrix
+}
+
+// NewPluginTiering returns the Tiering of a FabricPool plugin, or nil when its tiering param is not true.
+// It collects once every schedule of the plugin
+func NewPluginTiering(p *plugin.AbstractPlugin) (*Tiering, error) {
+	if !ReadPluginKey(p.Params, "tiering") {
+		return nil, nil
+	}
+	var pricePerGB float64
+	if value := p.Params.GetChildContentS("price_per_gb"); value != "" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 {
+			return nil, errs.New(errs.ErrInvalidParam, "price_per_gb: "+value)
+		}
+		pricePerGB = price
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	client, err := rest.New(conf.ZapiPoller(p.ParentParams), timeout, p.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Init(5); err != nil {
+		return nil, err
+	}
+
+	t := NewTiering(client, p.Parent, pricePerGB)
+	t.rate = p.SetPluginInterval()
+	// collect on the first poll
+	t.polls = t.rate
+	return t, nil
+}
+
+// Run collects the tiering economics when the schedule of the plugin is due, and returns the latest
+func (t *Tiering) Run(l *logging.Logger) []*matrix.Matrix {
+	if t.polls >= t.rate {
+		t.polls = 0
+		if err := t.Collect(); err != nil {
+			l.Logger.Error().Err(err).Msg("Failed to collect tiering economics")
+		}
+	}
+	t.polls++
+	return t.Matrices()
+}
+
+// NewTiering returns a Tiering. The cost of the object store is computed when pricePerGB, the monthly price
+// of one GiB in the object store, is positive
+func NewTiering(client *rest.Client, uuid string, pricePerGB float64) *Tiering {
+	t := &Tiering{
+		client:     client,
+		pricePerGB: pricePerGB,
+		previous:   make(map[string]tierSample),
+		volumes:    newTieringMatrix(uuid, "fabricpool_volume", "cloud_target", "aggr", "svm", "volume"),
+		aggrs:      newTieringMatrix(uuid, "fabricpool_aggr", "cloud_target", "aggr"),
+	}
+	return t
+}
+
+func newTieringMatrix(uuid string, object string, keys ...string) *matrix.Matrix {
+	mat := matrix.New(uuid+".FabricPoolTiering", object, object)
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range keys {
+		instanceKeys.NewChildS("", key)
+	}
+	mat.SetExportOptions(exportOptions)
+	for _, name := range tieringMetrics {
+		_, _ = mat.NewMetricFloat64(name)
+	}
+	return mat
+}
+
+// Matrices returns the tiering economics of the latest Collect
+func (t *Tiering) Matrices() []*matrix.Matrix {
+	return []*matrix.Matrix{t.volumes, t.aggrs}
+}
+
+// Collect fetches the footprint of the volumes of FabricPool aggregates and updates the tiering economics
+func (t *Tiering) Collect() error {
+	footprints, err := t.fetch()
+	if err != nil {
+		return err
+	}
+	t.Update(footprints, time.Now())
+	return nil
+}
+
+func (t *Tiering) fetch() ([]VolumeFootprint, error) {
+	// the cloud target of each FabricPool aggregate
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/aggregates").
+		Fields([]string{"name", "cloud_storage.stores.cloud_store.name"}).
+		Build()
+	records, err := rest.Fetch(t.client, href)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch aggregates href=%s err=%w", href, err)
+	}
+	cloudTargets := make(map[string]string)
+	for _, r := range records {
+		if target := r.Get("cloud_storage.stores.0.cloud_store.name").String(); target != "" {
+			cloudTargets[r.Get("name").String()] = target
+		}
+	}
+	if len(cloudTargets) == 0 {
+		return nil, nil
+	}
+
+	href = rest.NewHrefBuilder().
+		APIPath("api/private/cli/volume").
+		Fields([]string{"volume", "vserver", "aggr_list", "performance_tier_inactive_user_data"}).
+		Build()
+	if records, err = rest.Fetch(t.client, href); err != nil {
+		return nil, fmt.Errorf("failed to fetch volumes href=%s err=%w", href, err)
+	}
+	volumes := make(map[string]*VolumeFootprint)
+	for _, r := range records {
+		v := &VolumeFootprint{
+			SVM:          r.Get("vserver").String(),
+			Volume:       r.Get("volume").String(),
+			InactiveData: r.Get("performance_tier_inactive_user_data").Float(),
+		}
+		// a volume is on a FabricPool when one of its aggregates is
+		aggrs := r.Get("aggr_list").Array()
+		for _, aggr := range aggrs {
+			if target, ok := cloudTargets[aggr.String()]; ok {
+				v.CloudTarget = target
+			}
+		}
+		if v.CloudTarget == "" {
+			continue
+		}
+		if len(aggrs) == 1 {
+			v.Aggr = aggrs[0].String()
+		}
+		volumes[v.SVM+"."+v.Volume] = v
+	}
+
+	href = rest.NewHrefBuilder().
+		APIPath("api/private/cli/volume/footprint").
+		Fields([]string{"volume", "vserver", "volume_blocks_footprint_bin0", "volume_blocks_footprint_bin1"}).
+		Build()
+	if records, err = rest.Fetch(t.client, href); err != nil {
+		return nil, fmt.Errorf("failed to fetch footprints href=%s err=%w", href, err)
+	}
+	footprints := make([]VolumeFootprint, 0, len(volumes))
+	for _, r := range records {
+		v, ok := volumes[r.Get("vserver").String()+"."+r.Get("volume").String()]
+		if !ok {
+			continue
+		}
+		v.PerformanceTier = r.Get("volume_blocks_footprint_bin0").Float()
+		v.CapacityTier = r.Get("volume_blocks_footprint_bin1").Float()
+		footprints = append(footprints, *v)
+	}
+	return footprints, nil
+}
+
+// Update replaces the tiering economics with those of footprints, measured at now.
+// Volumes that span aggregates are not included in the totals of aggregates
+func (t *Tiering) Update(footprints []VolumeFootprint, now time.Time) {
+	for _, mat := range t.Matrices() {
+		mat.PurgeInstances()
+		mat.Reset()
+	}
+	totals := make(map[string]*VolumeFootprint)
+	seen := make(map[string]bool)
+
+	for _, v := range footprints {
+		key := "volume." + v.SVM + "." + v.Volume
+		instance, err := t.volumes.NewInstance(key)
+		if err != nil {
+			continue
+		}
+		instance.SetLabel("cloud_target", v.CloudTarget)
+		instance.SetLabel("aggr", v.Aggr)
+		instance.SetLabel("svm", v.SVM)
+		instance.SetLabel("volume", v.Volume)
+		t.set(t.volumes, instance, key, v, now)
+		seen[key] = true
+
+		if v.Aggr == "" {
+			continue
+		}
+		total, ok := totals[v.Aggr]
+		if !ok {
+			total = &VolumeFootprint{Aggr: v.Aggr, CloudTarget: v.CloudTarget}
+			totals[v.Aggr] = total
+		}
+		total.PerformanceTier += v.PerformanceTier
+		total.CapacityTier += v.CapacityTier
+		total.InactiveData += v.InactiveData
+	}
+
+	for aggr, total := range totals {
+		key := "aggr." + aggr
+		instance, err := t.aggrs.NewInstance(key)
+		if err != nil {
+			continue
+		}
+		instance.SetLabel("cloud_target", total.CloudTarget)
+		instance.SetLabel("aggr", aggr)
+		t.set(t.aggrs, instance, key, *total, now)
+		seen[key] = true
+	}
+
+	// forget the volumes and aggregates that were deleted
+	for key := range t.previous {
+		if !seen[key] {
+			delete(t.previous, key)
+		}
+	}
+}
+
+func (t *Tiering) set(mat *matrix.Matrix, instance *matrix.Instance, key string, v VolumeFootprint, now time.Time) {
+	_ = mat.GetMetric("performance_tier_footprint").SetValueFloat64(instance, v.PerformanceTier)
+	_ = mat.GetMetric("capacity_tier_footprint").SetValueFloat64(instance, v.CapacityTier)
+	_ = mat.GetMetric("inactive_data").SetValueFloat64(instance, v.InactiveData)
+
+	// cold data is the data tiered to the object store, and the inactive data still in the performance tier
+	if total := v.PerformanceTier + v.CapacityTier; total > 0 {
+		cold := min(v.CapacityTier+v.InactiveData, total)
+		_ = mat.GetMetric("cold_data_percent").SetValueFloat64(instance, cold/total*100)
+	}
+
+	// the rate is extrapolated to a month, and is negative when more data is read back than tiered
+	if previous, ok := t.previous[key]; ok {
+		if elapsed := now.Sub(previous.time).Seconds(); elapsed > 0 {
+			rate := (v.CapacityTier - previous.capacityTier) / elapsed * secondsPerMonth
+			_ = mat.GetMetric("tiering_rate").SetValueFloat64(instance, rate)
+		}
+	}
+	t.previous[key] = tierSample{capacityTier: v.CapacityTier, time: now}
+
+	if t.pricePerGB > 0 {
+		_ = mat.GetMetric("object_store_cost").SetValueFloat64(instance, v.CapacityTier/bytesPerGiB*t.pricePerGB)
+	}
+}
@@ -0,0 +1,64 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTieringUpdate(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	tiering := NewTiering(nil, "ZapiPerf", 0.02)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tiering.Update([]VolumeFootprint{
+		{SVM: "vs1", Volume: "vol1", Aggr: "aggr1", CloudTarget: "s3", PerformanceTier: 60 * gib, CapacityTier: 40 * gib, InactiveData: 10 * gib},
+		{SVM: "vs1", Volume: "vol2", Aggr: "aggr1", CloudTarget: "s3", PerformanceTier: 100 * gib},
+		{SVM: "vs1", Volume: "fg1", CloudTarget: "s3", PerformanceTier: 10 * gib, CapacityTier: 10 * gib},
+	}, start)
+
+	// a day later, vol1 tiered 1 GiB more and vol2 was deleted
+	tiering.Update([]VolumeFootprint{
+		{SVM: "vs1", Volume: "vol1", Aggr: "aggr1", CloudTarget: "s3", PerformanceTier: 59 * gib, CapacityTier: 41 * gib},
+		{SVM: "vs1", Volume: "fg1", CloudTarget: "s3", PerformanceTier: 10 * gib, CapacityTier: 10 * gib},
+	}, start.Add(24*time.Hour))
+
+	tests := []struct {
+		mat      *matrix.Matrix
+		instance string
+		metric   string
+		want     float64
+		wantOk   bool
+	}{
+		{mat: tiering.volumes, instance: "volume.vs1.vol1", metric: "cold_data_percent", want: 41, wantOk: true},
+		{mat: tiering.volumes, instance: "volume.vs1.vol1", metric: "tiering_rate", want: 30 * gib, wantOk: true},
+		{mat: tiering.volumes, instance: "volume.vs1.vol1", metric: "object_store_cost", want: 41 * 0.02, wantOk: true},
+		{mat: tiering.volumes, instance: "volume.vs1.fg1", metric: "cold_data_percent", want: 50, wantOk: true},
+		{mat: tiering.volumes, instance: "volume.vs1.fg1", metric: "tiering_rate", want: 0, wantOk: true},
+		{mat: tiering.aggrs, instance: "aggr.aggr1", metric: "capacity_tier_footprint", want: 41 * gib, wantOk: true},
+		{mat: tiering.aggrs, instance: "aggr.aggr1", metric: "tiering_rate", want: 30 * gib, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.instance+"/"+tt.metric, func(t *testing.T) {
+			instance := tt.mat.GetInstance(tt.instance)
+			if instance == nil {
+				t.Fatalf("instance %s not found", tt.instance)
+			}
+			got, ok := tt.mat.GetMetric(tt.metric).GetValueFloat64(instance)
+			if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("got=%v,%v want=%v,%v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if tiering.volumes.GetInstance("volume.vs1.vol2") != nil {
+		t.Errorf("deleted volume vol2 is still exported")
+	}
+	if _, ok := tiering.previous["volume.vs1.vol2"]; ok {
+		t.Errorf("deleted volume vol2 is still remembered")
+	}
+	if got := len(tiering.aggrs.GetInstances()); got != 1 {
+		t.Errorf("aggregates got=%d, want=1, volumes that span aggregates are not in the totals", got)
+	}
+}
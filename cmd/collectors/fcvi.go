@@ -0,0 +1,200 @@
+package collectors
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultFCVIErrorRateWarning  = 0.1
+	defaultFCVIErrorRateCritical = 1.0
+)
+
+// States of the FCVI adapters of a rollup, from the error rate of its worst adapter
+const (
+	FCVIHealthy  = 0
+	FCVIWarning  = 1
+	FCVICritical = 2
+)
+
+// fcviErrors are the counters of the errors of an FCVI adapter. They are deltas, so their sum divided by the
+// seconds of the poll is the error rate of the adapter
+var fcviErrors = []string{
+	"firmware_invalid_crc_count",
+	"firmware_invalid_transmit_word_count",
+	"firmware_link_failure_count",
+	"firmware_loss_of_signal_count",
+	"firmware_loss_of_sync_count",
+	"firmware_systat_discard_frames",
+	"hard_reset_count",
+	"soft_reset_count",
+}
+
+const (
+	fcviOps        = "rdma_write_ops"
+	fcviThroughput = "rdma_write_throughput"
+	fcviLatency    = "rdma_write_avg_latency"
+)
+
+// FCVIOptions are the thresholds of the FCVI plugins, read from the plugin parameters of the template
+type FCVIOptions struct {
+	ErrorRateWarning  float64 // errors per second of an adapter from which its state is warning
+	ErrorRateCritical float64 // errors per second of an adapter from which its state is critical
+}
+
+// ReadFCVIOptions returns the thresholds of the plugin parameters, with the defaults for the ones that are not set
+func ReadFCVIOptions(params *node.Node) (FCVIOptions, error) {
+	o := FCVIOptions{ErrorRateWarning: defaultFCVIErrorRateWarning, ErrorRateCritical: defaultFCVIErrorRateCritical}
+	for name, threshold := range map[string]*float64{"error_rate_warning": &o.ErrorRateWarning, "error_rate_critical": &o.ErrorRateCritical} {
+		value := params.GetChildContentS(name)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 {
+			return o, errs.New(errs.ErrInvalidParam, name+": "+value)
+		}
+		*threshold = v
+	}
+	if o.ErrorRateCritical < o.ErrorRateWarning {
+		return o, errs.New(errs.ErrInvalidParam, fmt.Sprintf("error_rate_critical: %g is below error_rate_warning: %g",
+			o.ErrorRateCritical, o.ErrorRateWarning))
+	}
+	return o, nil
+}
+
+// state returns the state of an adapter with errors per second
+func (o FCVIOptions) state(errors float64) float64 {
+	switch {
+	case errors >= o.ErrorRateCritical:
+		return FCVICritical
+	case errors >= o.ErrorRateWarning:
+		return FCVIWarning
+	default:
+		return FCVIHealthy
+	}
+}
+
+// FCVIRollups returns the health and utilization of the FCVI adapters of data rolled up per node, and per DR group
+// when drGroups, which maps a node to its DR group, is not empty.
+// The error rates, ops, and throughput of the adapters are summed, the latency is averaged weighted by ops, and the
+// state is the state of the worst adapter, so alerts fire when a single interconnect link degrades.
+// Instances of data must have the node label
+func FCVIRollups(data *matrix.Matrix, drGroups map[string]string, o FCVIOptions) []*matrix.Matrix {
+	nodeOf := func(i *matrix.Instance) string {
+		return i.GetLabel("node")
+	}
+	results := []*matrix.Matrix{fcviRollup(data, "fcvi_node", "node", nodeOf, o)}
+	if len(drGroups) > 0 {
+		drGroupOf := func(i *matrix.Instance) string {
+			return drGroups[i.GetLabel("node")]
+		}
+		results = append(results, fcviRollup(data, "fcvi_dr_group", "dr_group_id", drGroupOf, o))
+	}
+	return results
+}
+
+// fcviErrorRate returns the errors per second of an adapter in the last poll
+func fcviErrorRate(data *matrix.Matrix, i *matrix.Instance) (float64, bool) {
+	timestamp := data.GetMetric("timestamp")
+	if timestamp == nil {
+		return 0, false
+	}
+	seconds, ok := timestamp.GetValueFloat64(i)
+	if !ok || seconds <= 0 {
+		return 0, false
+	}
+	var total float64
+	var found bool
+	for _, m := range data.GetMetrics() {
+		if !slices.Contains(fcviErrors, m.GetName()) {
+			continue
+		}
+		if value, ok := m.GetValueFloat64(i); ok {
+			total += value
+			found = true
+		}
+	}
+	return total / seconds, found
+}
+
+// fcviRollup rolls up the adapters of data to the group that groupOf returns for each adapter, adapters without a
+// group are skipped. The label of the groups is named by key
+func fcviRollup(data *matrix.Matrix, object string, key string, groupOf func(*matrix.Instance) string, o FCVIOptions) *matrix.Matrix {
+	rollup := matrix.New(data.UUID+".FCVI."+object, object, object)
+	rollup.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	instanceKeys.NewChildS("", key)
+	rollup.SetExportOptions(exportOptions)
+
+	adapters, _ := rollup.NewMetricFloat64("adapters")
+	errorRate, _ := rollup.NewMetricFloat64("error_rate")
+	state, _ := rollup.NewMetricFloat64("state")
+	ops, _ := rollup.NewMetricFloat64(fcviOps)
+	throughput, _ := rollup.NewMetricFloat64(fcviThroughput)
+	latency, _ := rollup.NewMetricFloat64(fcviLatency)
+
+	// metrics of data by name, since the keys of the metrics are the counters of ONTAP
+	byName := make(map[string]*matrix.Metric)
+	for _, m := range data.GetMetrics() {
+		byName[m.GetName()] = m
+	}
+	valueOf := func(name string, i *matrix.Instance) (float64, bool) {
+		if m, ok := byName[name]; ok {
+			return m.GetValueFloat64(i)
+		}
+		return 0, false
+	}
+	add := func(m *matrix.Metric, r *matrix.Instance, value float64) {
+		total, _ := m.GetValueFloat64(r)
+		_ = m.SetValueFloat64(r, total+value)
+	}
+
+	averages := make(map[string]*matrix.Average)
+	for _, i := range data.GetInstances() {
+		if !i.IsExportable() {
+			continue
+		}
+		group := groupOf(i)
+		if group == "" {
+			continue
+		}
+		r := rollup.GetInstance(group)
+		if r == nil {
+			r, _ = rollup.NewInstance(group)
+			r.SetLabel(key, group)
+			_ = state.SetValueFloat64(r, FCVIHealthy)
+			averages[group] = &matrix.Average{}
+		}
+
+		add(adapters, r, 1)
+		if rate, ok := fcviErrorRate(data, i); ok {
+			add(errorRate, r, rate)
+			worst, _ := state.GetValueFloat64(r)
+			_ = state.SetValueFloat64(r, max(worst, o.state(rate)))
+		}
+		weight, ok := valueOf(fcviOps, i)
+		if ok {
+			add(ops, r, weight)
+		}
+		if value, ok := valueOf(fcviThroughput, i); ok {
+			add(throughput, r, value)
+		}
+		if value, ok := valueOf(fcviLatency, i); ok {
+			averages[group].Add(value, weight)
+		}
+	}
+
+	// without ops, the latency is 0
+	for instanceKey, r := range rollup.GetInstances() {
+		if value, ok := averages[instanceKey].Value(); ok {
+			_ = latency.SetValueFloat64(r, value)
+		}
+	}
+	return rollup
+}
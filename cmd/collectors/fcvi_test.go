@@ -0,0 +1,130 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+)
+
+func newFCVIData(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("FCVI", "fcvi", "fcvi")
+	// the keys of the metrics are the counters of ONTAP, the names are the names of the template
+	timestamp, _ := data.NewMetricFloat64("timestamp")
+	crc, _ := data.NewMetricFloat64("firmware.invalid_crc_count", "firmware_invalid_crc_count")
+	resets, _ := data.NewMetricFloat64("hard_reset_count")
+	ops, _ := data.NewMetricFloat64("rdma.write_ops", "rdma_write_ops")
+	latency, _ := data.NewMetricFloat64("rdma.write_average_latency", "rdma_write_avg_latency")
+	throughput, _ := data.NewMetricFloat64("rdma.write_throughput", "rdma_write_throughput")
+
+	for _, a := range []struct {
+		node, adapter            string
+		crc, resets              float64
+		ops, latency, throughput float64
+	}{
+		{"n1", "fcvi_device_0", 0, 0, 100, 10, 1000},
+		{"n1", "fcvi_device_1", 6, 0, 300, 30, 3000},
+		{"n2", "fcvi_device_0", 30, 30, 0, 50, 0},
+		{"n3", "fcvi_device_0", 0, 0, 10, 20, 100},
+	} {
+		i, err := data.NewInstance(a.node + ":" + a.adapter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.SetLabel("node", a.node)
+		i.SetLabel("fcvi", a.adapter)
+		_ = timestamp.SetValueFloat64(i, 60)
+		_ = crc.SetValueFloat64(i, a.crc)
+		_ = resets.SetValueFloat64(i, a.resets)
+		_ = ops.SetValueFloat64(i, a.ops)
+		_ = latency.SetValueFloat64(i, a.latency)
+		_ = throughput.SetValueFloat64(i, a.throughput)
+	}
+	return data
+}
+
+func TestReadFCVIOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    FCVIOptions
+		wantErr bool
+	}{
+		{name: "defaults", yaml: "FCVI:\n", want: FCVIOptions{ErrorRateWarning: 0.1, ErrorRateCritical: 1}},
+		{name: "thresholds", yaml: "error_rate_warning: 0.5\nerror_rate_critical: 5\n", want: FCVIOptions{ErrorRateWarning: 0.5, ErrorRateCritical: 5}},
+		{name: "not a number", yaml: "error_rate_warning: often\n", wantErr: true},
+		{name: "negative", yaml: "error_rate_critical: -1\n", wantErr: true},
+		{name: "critical below warning", yaml: "error_rate_warning: 2\nerror_rate_critical: 1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFCVIOptions(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFCVIOptions() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ReadFCVIOptions() got=%+v, want=%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFCVIRollups(t *testing.T) {
+	data := newFCVIData(t)
+	drGroups := map[string]string{"n1": "1", "n2": "1", "n3": "2"}
+	o := FCVIOptions{ErrorRateWarning: 0.1, ErrorRateCritical: 1}
+
+	results := FCVIRollups(data, drGroups, o)
+	byObject := make(map[string]*matrix.Matrix)
+	for _, m := range results {
+		byObject[m.Object] = m
+	}
+
+	tests := []struct {
+		object, instance, metric string
+		value                    float64
+	}{
+		{"fcvi_node", "n1", "adapters", 2},
+		// 6 errors in 60 seconds
+		{"fcvi_node", "n1", "error_rate", 0.1},
+		{"fcvi_node", "n1", "state", FCVIWarning},
+		{"fcvi_node", "n1", "rdma_write_ops", 400},
+		{"fcvi_node", "n1", "rdma_write_throughput", 4000},
+		// weighted by ops: (10*100 + 30*300) / 400
+		{"fcvi_node", "n1", "rdma_write_avg_latency", 25},
+		{"fcvi_node", "n2", "error_rate", 1},
+		{"fcvi_node", "n2", "state", FCVICritical},
+		// no ops, so no latency
+		{"fcvi_node", "n2", "rdma_write_avg_latency", 0},
+		{"fcvi_node", "n3", "state", FCVIHealthy},
+		{"fcvi_dr_group", "1", "adapters", 3},
+		{"fcvi_dr_group", "1", "error_rate", 1.1},
+		{"fcvi_dr_group", "1", "state", FCVICritical},
+		{"fcvi_dr_group", "2", "state", FCVIHealthy},
+		{"fcvi_dr_group", "2", "rdma_write_ops", 10},
+	}
+	for _, tt := range tests {
+		m := byObject[tt.object]
+		if m == nil {
+			t.Fatalf("missing rollup %s", tt.object)
+		}
+		i := m.GetInstance(tt.instance)
+		if i == nil {
+			t.Errorf("%s missing instance %s", tt.object, tt.instance)
+			continue
+		}
+		got, ok := m.GetMetric(tt.metric).GetValueFloat64(i)
+		if !ok || got != tt.value {
+			t.Errorf("%s %s %s got=%v ok=%t, want=%v", tt.object, tt.instance, tt.metric, got, ok, tt.value)
+		}
+	}
+
+	// without DR groups, e.g. when they can not be collected, adapters are only rolled up per node
+	if results := FCVIRollups(data, nil, o); len(results) != 1 || results[0].Object != "fcvi_node" {
+		t.Errorf("FCVIRollups() without DR groups got %d rollups, want fcvi_node only", len(results))
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright NetApp Inc, 2024 All rights reserved
+
+/*
+Package health implements a lightweight collector that probes the
+cluster, its nodes, and its network interfaces on a fast schedule.
+Each probe exports an "up" metric and a "reason" label that explains
+why the endpoint is down (auth_failed, timeout, tls_error, etc.)
+
+Unlike the other ONTAP collectors, Health does not enter standby mode
+when the cluster is unreachable, since reporting that is its job.
+*/
+package health
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/set"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultTimeout = "5s"
+
+// Reasons explaining the value of the up metric
+const (
+	ReasonOK                = "ok"
+	ReasonDown              = "down"
+	ReasonAuthFailed        = "auth_failed"
+	ReasonPermissionDenied  = "permission_denied"
+	ReasonTimeout           = "timeout"
+	ReasonTLSError          = "tls_error"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonConnectionError   = "connection_error"
+	ReasonHTTPError         = "http_error"
+)
+
+const (
+	probeCluster = "cluster"
+	probeNode    = "node"
+	probeLif     = "lif"
+)
+
+var allProbes = []string{probeCluster, probeNode, probeLif}
+
+type Health struct {
+	*collector.AbstractCollector
+	client *rest.Client
+	probes []string
+}
+
+func init() {
+	plugin.RegisterModule(&Health{})
+}
+
+func (h *Health) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.health",
+		New: func() plugin.Module { return new(Health) },
+	}
+}
+
+// Init initializes the collector. The client is not initialized with the cluster
+// since the cluster may be down, which the collector needs to report.
+func (h *Health) Init(a *collector.AbstractCollector) error {
+	h.AbstractCollector = a
+
+	if err := collector.Init(h); err != nil {
+		return err
+	}
+
+	if err := h.initClient(); err != nil {
+		return err
+	}
+
+	h.probes = allProbes
+	if probes := h.Params.GetChildS("probes"); probes != nil {
+		h.probes = make([]string, 0, len(probes.GetChildren()))
+		for _, p := range probes.GetAllChildContentS() {
+			if !slices.Contains(allProbes, p) {
+				return errs.New(errs.ErrInvalidParam, "probes: "+p+" must be one of "+strings.Join(allProbes, ", "))
+			}
+			h.probes = append(h.probes, p)
+		}
+	}
+	if !slices.Contains(h.probes, probeCluster) {
+		h.probes = append([]string{probeCluster}, h.probes...)
+	}
+
+	mat := h.Matrix[h.Object]
+	if _, err := mat.NewMetricUint8("up"); err != nil {
+		return err
+	}
+	if _, err := mat.NewMetricFloat64("latency", "latency_ms"); err != nil {
+		return err
+	}
+	if h.Params.HasChildS("labels") {
+		for _, l := range h.Params.GetChildS("labels").GetChildren() {
+			mat.SetGlobalLabel(l.GetNameS(), l.GetContentS())
+		}
+	}
+
+	h.Logger.Debug().Strs("probes", h.probes).Str("timeout", h.client.Timeout.String()).Msg("initialized")
+	return nil
+}
+
+func (h *Health) initClient() error {
+	poller, err := conf.PollerNamed(h.Options.Poller)
+	if err != nil {
+		return err
+	}
+	if poller.Addr == "" {
+		return errs.New(errs.ErrMissingParam, "addr")
+	}
+
+	clientTimeout := h.Params.GetChildContentS("client_timeout")
+	if clientTimeout == "" {
+		clientTimeout = defaultTimeout
+	}
+	timeout, err := time.ParseDuration(clientTimeout)
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, "client_timeout ("+clientTimeout+"): "+err.Error())
+	}
+
+	if h.Options.IsTest {
+		h.client = &rest.Client{Metadata: &util.Metadata{}, Timeout: timeout}
+		return nil
+	}
+	if h.client, err = rest.New(poller, timeout, h.Auth); err != nil {
+		return err
+	}
+	h.client.TraceLogSet(h.Name, h.Params)
+	return nil
+}
+
+// PollData probes each endpoint and updates the up metric and reason label of each instance
+func (h *Health) PollData() (map[string]*matrix.Matrix, error) {
+	mat := h.Matrix[h.Object]
+	mat.Reset()
+	h.client.Metadata.Reset()
+
+	start := time.Now()
+	cluster, latency, err := h.get("api/cluster", []string{"name", "uuid"})
+	reason := Reason(err)
+	h.setProbe(mat, probeCluster, map[string]string{"name": cluster.Get("name").String()}, reason, latency)
+
+	if reason == ReasonOK {
+		mat.SetGlobalLabel("cluster", cluster.Get("name").String())
+		for _, probe := range h.probes {
+			switch probe {
+			case probeNode:
+				h.probeNodes(mat)
+			case probeLif:
+				h.probeLifs(mat)
+			}
+		}
+	} else {
+		// The state of the nodes and lifs is unknown when the cluster can not be reached
+		for key, instance := range mat.GetInstances() {
+			if key == probeCluster {
+				continue
+			}
+			instance.SetLabel("reason", reason)
+			_ = mat.GetMetric("up").SetValueUint8(instance, 0)
+		}
+	}
+
+	_ = h.Metadata.LazySetValueInt64("api_time", "data", time.Since(start).Microseconds())
+	_ = h.Metadata.LazySetValueUint64("instances", "data", uint64(len(mat.GetInstances())))
+	_ = h.Metadata.LazySetValueUint64("metrics", "data", uint64(len(mat.GetInstances())*2))
+	_ = h.Metadata.LazySetValueUint64("bytesRx", "data", h.client.Metadata.BytesRx)
+	_ = h.Metadata.LazySetValueUint64("bytesRxWire", "data", h.client.Metadata.BytesRxWire)
+	_ = h.Metadata.LazySetValueUint64("numCalls", "data", h.client.Metadata.NumCalls)
+	h.AddCollectCount(uint64(len(mat.GetInstances())))
+
+	return h.Matrix, nil
+}
+
+func (h *Health) probeNodes(mat *matrix.Matrix) {
+	records, latency, err := h.getRecords("api/cluster/nodes", []string{"name", "state"})
+	if err != nil {
+		h.markKind(mat, probeNode, Reason(err), latency)
+		return
+	}
+	seen := set.New()
+	for _, r := range records {
+		name := r.Get("name").String()
+		reason := ReasonOK
+		if state := r.Get("state").String(); state != "up" {
+			reason = ReasonDown
+		}
+		seen.Add(h.setProbe(mat, probeNode, map[string]string{"name": name, "node": name}, reason, latency))
+	}
+	h.removeStale(mat, probeNode, seen)
+}
+
+func (h *Health) probeLifs(mat *matrix.Matrix) {
+	records, latency, err := h.getRecords("api/network/ip/interfaces", []string{"name", "state", "svm.name", "location.home_node.name"})
+	if err != nil {
+		h.markKind(mat, probeLif, Reason(err), latency)
+		return
+	}
+	seen := set.New()
+	for _, r := range records {
+		reason := ReasonOK
+		if state := r.Get("state").String(); state != "up" {
+			reason = ReasonDown
+		}
+		labels := map[string]string{
+			"name": r.Get("name").String(),
+			"svm":  r.Get("svm.name").String(),
+			"node": r.Get("location.home_node.name").String(),
+		}
+		seen.Add(h.setProbe(mat, probeLif, labels, reason, latency))
+	}
+	h.removeStale(mat, probeLif, seen)
+}
+
+// setProbe creates or updates the instance of a probe and returns its key
+func (h *Health) setProbe(mat *matrix.Matrix, kind string, labels map[string]string, reason string, latency time.Duration) string {
+	key := kind
+	switch {
+	case kind == probeCluster:
+	case labels["svm"] != "":
+		key = kind + ":" + labels["svm"] + ":" + labels["name"]
+	default:
+		key = kind + ":" + labels["name"]
+	}
+	instance := mat.GetInstance(key)
+	if instance == nil {
+		var err error
+		if instance, err = mat.NewInstance(key); err != nil {
+			h.Logger.Error().Err(err).Str("key", key).Msg("Failed to add instance")
+			return key
+		}
+	}
+	instance.SetLabel("kind", kind)
+	for k, v := range labels {
+		if v != "" {
+			instance.SetLabel(k, v)
+		}
+	}
+	instance.SetLabel("reason", reason)
+
+	up := uint8(0)
+	if reason == ReasonOK {
+		up = 1
+	}
+	_ = mat.GetMetric("up").SetValueUint8(instance, up)
+	_ = mat.GetMetric("latency").SetValueFloat64(instance, float64(latency.Microseconds())/1000)
+	return key
+}
+
+// markKind sets all instances of kind to down with reason
+func (h *Health) markKind(mat *matrix.Matrix, kind string, reason string, latency time.Duration) {
+	for _, instance := range mat.GetInstances() {
+		if instance.GetLabel("kind") != kind {
+			continue
+		}
+		instance.SetLabel("reason", reason)
+		_ = mat.GetMetric("up").SetValueUint8(instance, 0)
+		_ = mat.GetMetric("latency").SetValueFloat64(instance, float64(latency.Microseconds())/1000)
+	}
+}
+
+func (h *Health) removeStale(mat *matrix.Matrix, kind string, seen *set.Set) {
+	for key, instance := range mat.GetInstances() {
+		if instance.GetLabel("kind") == kind && !seen.Has(key) {
+			mat.RemoveInstance(key)
+			h.Logger.Debug().Str("key", key).Msg("removed instance")
+		}
+	}
+}
+
+func (h *Health) get(api string, fields []string) (gjson.Result, time.Duration, error) {
+	href := rest.NewHrefBuilder().APIPath(api).Fields(fields).Build()
+	start := time.Now()
+	response, err := h.client.GetRest(href)
+	latency := time.Since(start)
+	if err != nil {
+		return gjson.Result{}, latency, err
+	}
+	return gjson.ParseBytes(response), latency, nil
+}
+
+func (h *Health) getRecords(api string, fields []string) ([]gjson.Result, time.Duration, error) {
+	href := rest.NewHrefBuilder().APIPath(api).Fields(fields).Build()
+	start := time.Now()
+	records, err := rest.Fetch(h.client, href)
+	return records, time.Since(start), err
+}
+
+// Reason classifies err into one of the reason codes exported by the collector
+func Reason(err error) string {
+	if err == nil {
+		return ReasonOK
+	}
+
+	switch {
+	case errors.Is(err, errs.ErrAuthFailed):
+		return ReasonAuthFailed
+	case errors.Is(err, errs.ErrPermissionDenied):
+		return ReasonPermissionDenied
+	}
+
+	var restErr *errs.RestError
+	if errors.As(err, &restErr) {
+		switch restErr.StatusCode {
+		case http.StatusUnauthorized:
+			return ReasonAuthFailed
+		case http.StatusForbidden:
+			return ReasonPermissionDenied
+		}
+		if restErr.StatusCode != 0 {
+			return ReasonHTTPError
+		}
+	}
+
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		certInvalid      x509.CertificateInvalidError
+		verifyErr        *tls.CertificateVerificationError
+		recordErr        tls.RecordHeaderError
+	)
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalid) ||
+		errors.As(err, &verifyErr) || errors.As(err, &recordErr) || strings.Contains(err.Error(), "tls:") {
+		return ReasonTLSError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ReasonTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ReasonConnectionRefused
+	}
+	return ReasonConnectionError
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*Health)(nil)
+)
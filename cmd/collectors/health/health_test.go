@@ -0,0 +1,189 @@
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReason(t *testing.T) {
+	urlErr := func(err error) error {
+		return fmt.Errorf("connection error %w", &url.Error{Op: "Get", URL: "https://10.0.0.1/api/cluster", Err: err})
+	}
+	timeoutErr := &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}
+	refusedErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ReasonOK},
+		{name: "auth failed", err: errs.NewRest().StatusCode(401).Error(errs.ErrAuthFailed).Build(), want: ReasonAuthFailed},
+		{name: "401 without cause", err: errs.NewRest().StatusCode(401).Build(), want: ReasonAuthFailed},
+		{name: "forbidden", err: errs.NewRest().StatusCode(403).Error(errs.ErrPermissionDenied).Build(), want: ReasonPermissionDenied},
+		{name: "server error", err: errs.NewRest().StatusCode(500).Build(), want: ReasonHTTPError},
+		{name: "timeout", err: urlErr(timeoutErr), want: ReasonTimeout},
+		{name: "unknown authority", err: urlErr(x509.UnknownAuthorityError{}), want: ReasonTLSError},
+		{name: "handshake", err: urlErr(errors.New("remote error: tls: handshake failure")), want: ReasonTLSError},
+		{name: "refused", err: urlErr(refusedErr), want: ReasonConnectionRefused},
+		{name: "other", err: urlErr(errors.New("no route to host")), want: ReasonConnectionError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Reason(tt.err); got != tt.want {
+				t.Errorf("Reason() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fixtures serves the JSON files of testdata for the ONTAP APIs that Health probes
+type fixtures struct {
+	mu    sync.Mutex
+	files map[string]string // API path => file in testdata, the APIs without a file fail
+}
+
+func (f *fixtures) set(api string, file string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[api] = file
+}
+
+func (f *fixtures) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	file, ok := f.files[strings.TrimPrefix(r.URL.Path, "/")]
+	f.mu.Unlock()
+	if !ok || file == "" {
+		http.Error(w, `{"error": {"message": "unavailable", "code": "4"}}`, http.StatusInternalServerError)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join("testdata", file))
+}
+
+func newHealth(t *testing.T, addr string) *Health {
+	t.Helper()
+	opts := options.New()
+	opts.Poller = "test"
+	opts.IsTest = true
+	params, err := tree.LoadYaml([]byte("schedule:\n  - data: 15s\nprobes:\n  - node\n  - lif\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Health{}
+	if err := h.Init(collector.New("Health", "health", opts, params, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	insecure := true
+	poller := &conf.Poller{Addr: addr, Username: "admin", Password: "secret", UseInsecureTLS: &insecure}
+	if h.client, err = rest.New(poller, 5*time.Second, auth.NewCredentials(poller, logging.Get())); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// probe is the state of the instance of a probe
+type probe struct {
+	up     uint8
+	reason string
+}
+
+func probes(t *testing.T, h *Health) map[string]probe {
+	t.Helper()
+	data, err := h.PollData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mat := data[h.Object]
+	got := make(map[string]probe)
+	for key, instance := range mat.GetInstances() {
+		up, _ := mat.GetMetric("up").GetValueUint8(instance)
+		got[key] = probe{up: up, reason: instance.GetLabel("reason")}
+	}
+	return got
+}
+
+func TestPollData(t *testing.T) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+	f := &fixtures{files: map[string]string{
+		"api/cluster":               "cluster.json",
+		"api/cluster/nodes":         "nodes.json",
+		"api/network/ip/interfaces": "lifs.json",
+	}}
+	server := httptest.NewTLSServer(f)
+	defer server.Close()
+	h := newHealth(t, strings.TrimPrefix(server.URL, "https://"))
+
+	// reachable cluster, everything is up
+	want := map[string]probe{
+		"cluster":              {up: 1, reason: ReasonOK},
+		"node:umeng-aff300-01": {up: 1, reason: ReasonOK},
+		"node:umeng-aff300-02": {up: 1, reason: ReasonOK},
+		"lif:svm1:lif_data1":   {up: 1, reason: ReasonOK},
+		"lif:svm1:lif_data2":   {up: 1, reason: ReasonOK},
+	}
+	if got := probes(t, h); !maps.Equal(got, want) {
+		t.Errorf("reachable got=%v, want=%v", got, want)
+	}
+	if cluster := h.Matrix[h.Object].GetGlobalLabels()["cluster"]; cluster != "umeng-aff300-01-02" {
+		t.Errorf("cluster label got=%s, want=umeng-aff300-01-02", cluster)
+	}
+
+	// a node is down, and a lif was deleted
+	f.set("api/cluster/nodes", "nodes-down.json")
+	f.set("api/network/ip/interfaces", "lifs-removed.json")
+	want = map[string]probe{
+		"cluster":              {up: 1, reason: ReasonOK},
+		"node:umeng-aff300-01": {up: 1, reason: ReasonOK},
+		"node:umeng-aff300-02": {up: 0, reason: ReasonDown},
+		"lif:svm1:lif_data1":   {up: 1, reason: ReasonOK},
+	}
+	if got := probes(t, h); !maps.Equal(got, want) {
+		t.Errorf("node down got=%v, want=%v", got, want)
+	}
+
+	// the lif API fails, the lifs are marked with its reason
+	f.set("api/network/ip/interfaces", "")
+	want["lif:svm1:lif_data1"] = probe{up: 0, reason: ReasonHTTPError}
+	if got := probes(t, h); !maps.Equal(got, want) {
+		t.Errorf("lif API failed got=%v, want=%v", got, want)
+	}
+
+	// the cluster is unreachable, the nodes and lifs are marked with its reason
+	server.Close()
+	got := probes(t, h)
+	reason := got["cluster"].reason
+	if got["cluster"].up != 0 || reason == ReasonOK {
+		t.Fatalf("unreachable cluster got=%v, want it down", got["cluster"])
+	}
+	for key, p := range got {
+		if p.up != 0 || p.reason != reason {
+			t.Errorf("unreachable %s got=%v, want=%v", key, p, probe{up: 0, reason: reason})
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unreachable got %d instances, want=%d", len(got), len(want))
+	}
+}
@@ -109,9 +109,10 @@ func (kp *KeyPerf) InitMatrix() error {
 		}
 	}
 
-	// Add metadata metric for skips/numPartials
+	// Add metadata metric for skips/numPartials/invalidValues
 	_, _ = kp.Metadata.NewMetricUint64("skips")
 	_, _ = kp.Metadata.NewMetricUint64("numPartials")
+	_, _ = kp.Metadata.NewMetricUint64("invalidValues")
 	return nil
 }
 
@@ -242,6 +243,7 @@ func (kp *KeyPerf) pollData(
 	// clone matrix without numeric data
 	curMat = prevMat.Clone(matrix.With{Data: false, Metrics: true, Instances: true, ExportInstances: true})
 	curMat.Reset()
+	curMat.ResetInvalid()
 
 	apiD = time.Since(startTime)
 
@@ -262,6 +264,7 @@ func (kp *KeyPerf) pollData(
 	_ = kp.Metadata.LazySetValueUint64("metrics", "data", count)
 	_ = kp.Metadata.LazySetValueUint64("instances", "data", uint64(len(curMat.GetInstances())))
 	_ = kp.Metadata.LazySetValueUint64("bytesRx", "data", kp.Client.Metadata.BytesRx)
+	_ = kp.Metadata.LazySetValueUint64("bytesRxWire", "data", kp.Client.Metadata.BytesRxWire)
 	_ = kp.Metadata.LazySetValueUint64("numCalls", "data", kp.Client.Metadata.NumCalls)
 	_ = kp.Metadata.LazySetValueUint64("numPartials", "data", numPartials)
 
@@ -442,9 +445,14 @@ func (kp *KeyPerf) pollData(
 	_ = kp.Metadata.LazySetValueUint64("instances", "data", uint64(len(curMat.GetInstances())))
 	_ = kp.Metadata.LazySetValueInt64("calc_time", "data", calcD.Microseconds())
 	_ = kp.Metadata.LazySetValueUint64("skips", "data", uint64(totalSkips))
+	_ = kp.Metadata.LazySetValueUint64("invalidValues", "data", uint64(curMat.InvalidCount()))
 
-	// store cache for next poll
+	// store cache for next poll and recycle the previous cache
 	kp.Matrix[kp.Object] = cachedData
+	prevMat.Release()
+
+	// curMat is released by the collector after it is exported
+	curMat.SetReleasable(true)
 
 	newDataMap := make(map[string]*matrix.Matrix)
 	newDataMap[kp.Object] = curMat
@@ -0,0 +1,95 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strings"
+)
+
+// Igroup is an initiator group a LUN is mapped to
+type Igroup struct {
+	Name       string
+	Protocol   string   // fcp, iscsi, or mixed
+	Initiators []string // WWPNs and IQNs of the hosts of the igroup
+}
+
+// LunIgroups are the igroups of each mapped LUN, by LunKey
+type LunIgroups map[string][]*Igroup
+
+// LunKey returns the key of the LUN with path of svm, e.g. svm1:vol1:lun1 for /vol/vol1/qtree1/lun1.
+// The perf templates split the path of a LUN into the volume and lun labels the same way
+func LunKey(svm, path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return svm + ":" + parts[1] + ":" + parts[len(parts)-1]
+}
+
+// Map adds the igroup to the LUN with path of svm
+func (l LunIgroups) Map(svm, path string, igroup *Igroup) {
+	key := LunKey(svm, path)
+	if key == "" || igroup == nil {
+		return
+	}
+	l[key] = append(l[key], igroup)
+}
+
+// LunHostLabels are the labels the LunHost plugins add to LUNs
+var LunHostLabels = []string{"igroup", "protocol", "initiator"}
+
+// SetLunHostLabels sets the igroup, protocol, and initiator labels of the LUNs of data to the sorted,
+// comma-separated names, protocols, and initiators of the igroups each LUN is mapped to.
+// LUNs are matched by their svm, volume, and lun labels. Unmapped LUNs have empty labels.
+// The initiator label is only set when initiators is true
+func SetLunHostLabels(data *matrix.Matrix, igroups LunIgroups, initiators bool) {
+	for _, instance := range data.GetInstances() {
+		var names, protocols, wwpns []string
+		for _, igroup := range igroups[instance.GetLabel("svm")+":"+instance.GetLabel("volume")+":"+instance.GetLabel("lun")] {
+			names = appendUnique(names, igroup.Name)
+			protocols = appendUnique(protocols, igroup.Protocol)
+			for _, initiator := range igroup.Initiators {
+				wwpns = appendUnique(wwpns, initiator)
+			}
+		}
+		instance.SetLabel("igroup", joinSorted(names))
+		instance.SetLabel("protocol", joinSorted(protocols))
+		if initiators {
+			instance.SetLabel("initiator", joinSorted(wwpns))
+		}
+	}
+}
+
+// AddLunHostKeys adds the labels of the LunHost plugins to the instance_keys of the template, so they are
+// exported with the metrics of each LUN
+func AddLunHostKeys(params *node.Node, initiators bool) {
+	exportOptions := params.GetChildS("export_options")
+	if exportOptions == nil {
+		return
+	}
+	keys := exportOptions.GetChildS("instance_keys")
+	if keys == nil {
+		return
+	}
+	for _, label := range LunHostLabels {
+		if label == "initiator" && !initiators {
+			continue
+		}
+		if keys.GetChildByContent(label) == nil {
+			keys.NewChildS("", label)
+		}
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	if value == "" || slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+func joinSorted(values []string) string {
+	slices.Sort(values)
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,91 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"slices"
+	"testing"
+)
+
+func TestLunKey(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "volume", path: "/vol/vol1/lun1", want: "svm1:vol1:lun1"},
+		{name: "qtree", path: "/vol/vol1/qtree1/lun1", want: "svm1:vol1:lun1"},
+		{name: "invalid", path: "/vol/vol1", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LunKey("svm1", tt.path); got != tt.want {
+				t.Errorf("LunKey() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLunHostLabels(t *testing.T) {
+	esx := &Igroup{Name: "esx", Protocol: "fcp", Initiators: []string{"20:00:00:10:9b:1c:2d:3f", "20:00:00:10:9b:1c:2d:3e"}}
+	sql := &Igroup{Name: "sql", Protocol: "iscsi", Initiators: []string{"iqn.1991-05.com.microsoft:sql01"}}
+	igroups := make(LunIgroups)
+	igroups.Map("svm1", "/vol/vol1/lun1", esx)
+	igroups.Map("svm1", "/vol/vol1/qtree1/lun2", esx)
+	igroups.Map("svm1", "/vol/vol1/qtree1/lun2", sql)
+
+	data := matrix.New("LunHost", "lun", "lun")
+	for _, lun := range []string{"lun1", "lun2", "lun3"} {
+		instance, _ := data.NewInstance(lun)
+		instance.SetLabel("svm", "svm1")
+		instance.SetLabel("volume", "vol1")
+		instance.SetLabel("lun", lun)
+	}
+
+	SetLunHostLabels(data, igroups, true)
+
+	tests := []struct {
+		lun       string
+		igroup    string
+		protocol  string
+		initiator string
+	}{
+		{lun: "lun1", igroup: "esx", protocol: "fcp", initiator: "20:00:00:10:9b:1c:2d:3e,20:00:00:10:9b:1c:2d:3f"},
+		{lun: "lun2", igroup: "esx,sql", protocol: "fcp,iscsi", initiator: "20:00:00:10:9b:1c:2d:3e,20:00:00:10:9b:1c:2d:3f,iqn.1991-05.com.microsoft:sql01"},
+		{lun: "lun3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lun, func(t *testing.T) {
+			instance := data.GetInstance(tt.lun)
+			if got := instance.GetLabel("igroup"); got != tt.igroup {
+				t.Errorf("igroup got=%s, want=%s", got, tt.igroup)
+			}
+			if got := instance.GetLabel("protocol"); got != tt.protocol {
+				t.Errorf("protocol got=%s, want=%s", got, tt.protocol)
+			}
+			if got := instance.GetLabel("initiator"); got != tt.initiator {
+				t.Errorf("initiator got=%s, want=%s", got, tt.initiator)
+			}
+		})
+	}
+}
+
+func TestAddLunHostKeys(t *testing.T) {
+	params, err := tree.LoadYaml([]byte(`
+export_options:
+  instance_keys:
+    - lun
+    - svm
+    - volume
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddLunHostKeys(params, false)
+	AddLunHostKeys(params, false)
+	got := params.GetChildS("export_options").GetChildS("instance_keys").GetAllChildContentS()
+	want := []string{"lun", "svm", "volume", "igroup", "protocol"}
+	if !slices.Equal(got, want) {
+		t.Errorf("instance_keys got=%v, want=%v", got, want)
+	}
+}
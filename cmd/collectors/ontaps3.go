@@ -0,0 +1,97 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"strings"
+)
+
+const (
+	s3ErrorPercent       = "error_percent"
+	s3ClientErrorPercent = "client_error_percent"
+	s3Requests           = "requests"
+)
+
+// s3ClientErrors are the counters of requests rejected with a 4xx status, either malformed (400),
+// unauthenticated (401), or denied (403)
+var s3ClientErrors = []string{"authentication_failures", "default_deny_access", "explicit_deny_access", "request_parse_errors"}
+
+// OntapS3Errors adds the error ratios of the S3 requests of each instance of data.
+// error_percent is the percentage of failed operations, the sum of the <op>_failed counters over the sum of the
+// <op>_total counters. client_error_percent is the percentage of requests rejected with a 4xx status.
+// Both are computed from cooked, i.e. delta, counters, and are not set when the counters are missing
+func OntapS3Errors(data *matrix.Matrix, l *logging.Logger) {
+	errorPercent := data.GetMetric(s3ErrorPercent)
+	if errorPercent == nil {
+		var err error
+		if errorPercent, err = data.NewMetricFloat64(s3ErrorPercent); err != nil {
+			l.Error().Err(err).Str("metric", s3ErrorPercent).Msg("Failed to create metric")
+			return
+		}
+		errorPercent.SetProperty("raw")
+		errorPercent.SetUnit("percent")
+	}
+	clientErrorPercent := data.GetMetric(s3ClientErrorPercent)
+	if clientErrorPercent == nil {
+		var err error
+		if clientErrorPercent, err = data.NewMetricFloat64(s3ClientErrorPercent); err != nil {
+			l.Error().Err(err).Str("metric", s3ClientErrorPercent).Msg("Failed to create metric")
+			return
+		}
+		clientErrorPercent.SetProperty("raw")
+		clientErrorPercent.SetUnit("percent")
+	}
+
+	// failed counter of each operation, by its total counter
+	operations := make(map[string]string)
+	for key := range data.GetMetrics() {
+		if op, ok := strings.CutSuffix(key, "_total"); ok && data.GetMetric(op+"_failed") != nil {
+			operations[key] = op + "_failed"
+		}
+	}
+	requests := data.GetMetric(s3Requests)
+
+	for _, instance := range data.GetInstances() {
+		errorPercent.SetValueNAN(instance)
+		clientErrorPercent.SetValueNAN(instance)
+
+		var total, failed float64
+		seen := false
+		for totalKey, failedKey := range operations {
+			t, ok1 := data.GetMetric(totalKey).GetValueFloat64(instance)
+			f, ok2 := data.GetMetric(failedKey).GetValueFloat64(instance)
+			if ok1 && ok2 {
+				total += t
+				failed += f
+				seen = true
+			}
+		}
+		if seen {
+			_ = errorPercent.SetValueFloat64(instance, percent(failed, total))
+		}
+
+		if requests == nil {
+			continue
+		}
+		r, ok := requests.GetValueFloat64(instance)
+		if !ok {
+			continue
+		}
+		var clientErrors float64
+		for _, key := range s3ClientErrors {
+			if m := data.GetMetric(key); m != nil {
+				v, _ := m.GetValueFloat64(instance)
+				clientErrors += v
+			}
+		}
+		_ = clientErrorPercent.SetValueFloat64(instance, percent(clientErrors, r))
+	}
+}
+
+// percent returns part as a percentage of whole, 0 when whole is 0
+func percent(part float64, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return part / whole * 100
+}
@@ -0,0 +1,59 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func TestOntapS3Errors(t *testing.T) {
+	data := matrix.New("OntapS3SVM", "ontaps3_svm", "ontaps3_svm")
+	counters := []string{"get_object_total", "get_object_failed", "put_object_total", "put_object_failed",
+		"requests", "authentication_failures", "explicit_deny_access"}
+	for _, c := range counters {
+		_, _ = data.NewMetricFloat64(c)
+	}
+
+	tests := []struct {
+		name         string
+		values       map[string]float64
+		errors       float64
+		clientErrors float64
+		isSet        bool
+	}{
+		{
+			name: "errors",
+			values: map[string]float64{"get_object_total": 60, "get_object_failed": 3, "put_object_total": 40,
+				"put_object_failed": 2, "requests": 200, "authentication_failures": 4, "explicit_deny_access": 6},
+			errors: 5, clientErrors: 5, isSet: true,
+		},
+		{
+			name: "idle",
+			values: map[string]float64{"get_object_total": 0, "get_object_failed": 0, "put_object_total": 0,
+				"put_object_failed": 0, "requests": 0},
+			errors: 0, clientErrors: 0, isSet: true,
+		},
+		{
+			name:   "not cooked",
+			values: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, _ := data.NewInstance(tt.name)
+			for k, v := range tt.values {
+				_ = data.GetMetric(k).SetValueFloat64(instance, v)
+			}
+			OntapS3Errors(data, logging.Get())
+
+			errors, ok := data.GetMetric("error_percent").GetValueFloat64(instance)
+			if ok != tt.isSet || errors != tt.errors {
+				t.Errorf("error_percent got=%v set=%v, want=%v set=%v", errors, ok, tt.errors, tt.isSet)
+			}
+			clientErrors, ok := data.GetMetric("client_error_percent").GetValueFloat64(instance)
+			if ok != tt.isSet || clientErrors != tt.clientErrors {
+				t.Errorf("client_error_percent got=%v set=%v, want=%v set=%v", clientErrors, ok, tt.clientErrors, tt.isSet)
+			}
+		})
+	}
+}
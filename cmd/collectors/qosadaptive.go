@@ -0,0 +1,137 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"strings"
+)
+
+// bytesPerTB is the TB of the IOPS/TB of adaptive policies, which is binary like the sizes of ONTAP
+const bytesPerTB = 1024 * 1024 * 1024 * 1024
+
+const QosAdaptiveObject = "qos_adaptive"
+
+// QosAdaptiveMetrics are the metrics of the workloads of adaptive policies
+var QosAdaptiveMetrics = []string{
+	"expected_iops",
+	"headroom_iops",
+	"headroom_percent",
+	"peak_iops",
+}
+
+// AdaptivePolicy is an adaptive QoS policy. The expected and peak IOPS of a workload scale with the allocated
+// or used space of its volume, and are at least the absolute minimum IOPS
+type AdaptivePolicy struct {
+	ExpectedIOPS    float64 // IOPS per TB
+	PeakIOPS        float64 // IOPS per TB
+	AbsoluteMinIOPS float64
+	ExpectedUsed    bool // expected IOPS scale with the used space, instead of the allocated space
+	PeakUsed        bool // peak IOPS scale with the used space, instead of the allocated space
+}
+
+// IsUsedSpace returns true when an allocation of an adaptive policy is the used space,
+// e.g. used_space in REST and used-space in ZAPI
+func IsUsedSpace(allocation string) bool {
+	return strings.HasPrefix(allocation, "used")
+}
+
+// Ceilings returns the expected and peak IOPS of a workload of the policy, on a volume of size bytes allocated
+// and used bytes used
+func (p AdaptivePolicy) Ceilings(size, used float64) (float64, float64) {
+	scale := func(iops float64, isUsed bool) float64 {
+		space := size
+		if isUsed {
+			space = used
+		}
+		return max(iops*space/bytesPerTB, p.AbsoluteMinIOPS)
+	}
+	return scale(p.ExpectedIOPS, p.ExpectedUsed), scale(p.PeakIOPS, p.PeakUsed)
+}
+
+// AdaptivePolicies are adaptive policies by svm and name. The default policies, e.g. extreme, belong to the admin
+// SVM and apply to workloads of every SVM, so each policy is also found by its name
+type AdaptivePolicies map[string]AdaptivePolicy
+
+func (a AdaptivePolicies) Add(svm, name string, p AdaptivePolicy) {
+	a[svm+":"+name] = p
+	a[name] = p
+}
+
+// Get returns the policy name of svm, or the policy name of the admin SVM
+func (a AdaptivePolicies) Get(svm, name string) (AdaptivePolicy, bool) {
+	if p, ok := a[svm+":"+name]; ok {
+		return p, true
+	}
+	p, ok := a[name]
+	return p, ok
+}
+
+// VolumeSpace is the allocated and used space of a volume, in bytes
+type VolumeSpace struct {
+	Size float64
+	Used float64
+}
+
+// NewQosAdaptiveMatrix returns the matrix of the workloads of adaptive policies
+func NewQosAdaptiveMatrix(uuid string) (*matrix.Matrix, error) {
+	mat := matrix.New(uuid+".QosAdaptive", QosAdaptiveObject, QosAdaptiveObject)
+	for _, name := range QosAdaptiveMetrics {
+		if _, err := mat.NewMetricFloat64(name); err != nil {
+			return nil, err
+		}
+	}
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range []string{"policy_group", "svm", "volume", "workload"} {
+		instanceKeys.NewChildS("", key)
+	}
+	mat.SetExportOptions(exportOptions)
+	return mat, nil
+}
+
+// SetQosAdaptiveHeadroom replaces the instances of mat with the volume workloads of data that have an adaptive
+// policy, with their expected and peak IOPS, and the IOPS left before the workload reaches its peak.
+// volumes are keyed by svm and volume name, e.g. svm1:vol1
+func SetQosAdaptiveHeadroom(mat *matrix.Matrix, data *matrix.Matrix, policies AdaptivePolicies, volumes map[string]VolumeSpace) {
+	mat.PurgeInstances()
+	mat.Reset()
+	mat.SetGlobalLabels(data.GetGlobalLabels())
+
+	ops := data.GetMetric("ops")
+	for key, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		// adaptive policies are applied to volumes, the workloads of qtrees, LUNs, and files have fixed policies
+		if instance.GetLabel("qtree") != "" || instance.GetLabel("lun") != "" || instance.GetLabel("file") != "" {
+			continue
+		}
+		svm := instance.GetLabel("svm")
+		policy, ok := policies.Get(svm, instance.GetLabel("policy_group"))
+		if !ok {
+			continue
+		}
+		space, ok := volumes[svm+":"+instance.GetLabel("volume")]
+		if !ok {
+			continue
+		}
+		w, err := mat.NewInstance(key)
+		if err != nil {
+			continue
+		}
+		for _, label := range []string{"policy_group", "svm", "volume", "workload"} {
+			w.SetLabel(label, instance.GetLabel(label))
+		}
+
+		expected, peak := policy.Ceilings(space.Size, space.Used)
+		_ = mat.GetMetric("expected_iops").SetValueFloat64(w, expected)
+		_ = mat.GetMetric("peak_iops").SetValueFloat64(w, peak)
+		if ops == nil {
+			continue
+		}
+		if v, ok := ops.GetValueFloat64(instance); ok && peak > 0 {
+			_ = mat.GetMetric("headroom_iops").SetValueFloat64(w, peak-v)
+			_ = mat.GetMetric("headroom_percent").SetValueFloat64(w, 100*(peak-v)/peak)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func TestAdaptivePolicyCeilings(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       AdaptivePolicy
+		size         float64
+		used         float64
+		wantExpected float64
+		wantPeak     float64
+	}{
+		{
+			name:         "allocated and used",
+			policy:       AdaptivePolicy{ExpectedIOPS: 1000, PeakIOPS: 5000, AbsoluteMinIOPS: 100, PeakUsed: true},
+			size:         2 * bytesPerTB,
+			used:         bytesPerTB / 2,
+			wantExpected: 2000,
+			wantPeak:     2500,
+		},
+		{
+			name:         "absolute minimum",
+			policy:       AdaptivePolicy{ExpectedIOPS: 1000, PeakIOPS: 5000, AbsoluteMinIOPS: 500, ExpectedUsed: true, PeakUsed: true},
+			size:         bytesPerTB,
+			used:         bytesPerTB / 16,
+			wantExpected: 500,
+			wantPeak:     500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, peak := tt.policy.Ceilings(tt.size, tt.used)
+			if expected != tt.wantExpected || peak != tt.wantPeak {
+				t.Errorf("Ceilings() got=%f,%f, want=%f,%f", expected, peak, tt.wantExpected, tt.wantPeak)
+			}
+		})
+	}
+}
+
+func TestSetQosAdaptiveHeadroom(t *testing.T) {
+	policies := make(AdaptivePolicies)
+	policies.Add("svm1", "gold", AdaptivePolicy{ExpectedIOPS: 1000, PeakIOPS: 2000, PeakUsed: true})
+	policies.Add("admin", "extreme", AdaptivePolicy{ExpectedIOPS: 6144, PeakIOPS: 12288, AbsoluteMinIOPS: 1000})
+	volumes := map[string]VolumeSpace{
+		"svm1:vol1": {Size: 2 * bytesPerTB, Used: bytesPerTB},
+		"svm2:vol2": {Size: bytesPerTB / 1024, Used: 0},
+	}
+
+	data := matrix.New("Workload", "qos", "qos")
+	ops, _ := data.NewMetricFloat64("ops")
+	workloads := []struct {
+		workload string
+		svm      string
+		volume   string
+		qtree    string
+		policy   string
+		ops      float64
+	}{
+		{workload: "w1", svm: "svm1", volume: "vol1", policy: "gold", ops: 1500},
+		{workload: "w2", svm: "svm2", volume: "vol2", policy: "extreme", ops: 250},
+		{workload: "w3", svm: "svm1", volume: "vol1", policy: "fixed", ops: 10},
+		{workload: "w4", svm: "svm1", volume: "vol1", qtree: "q1", policy: "gold", ops: 10},
+	}
+	for _, w := range workloads {
+		instance, _ := data.NewInstance(w.workload)
+		instance.SetLabel("workload", w.workload)
+		instance.SetLabel("svm", w.svm)
+		instance.SetLabel("volume", w.volume)
+		instance.SetLabel("qtree", w.qtree)
+		instance.SetLabel("policy_group", w.policy)
+		_ = ops.SetValueFloat64(instance, w.ops)
+	}
+
+	mat, err := NewQosAdaptiveMatrix("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetQosAdaptiveHeadroom(mat, data, policies, volumes)
+
+	if got := len(mat.GetInstances()); got != 2 {
+		t.Fatalf("instances got=%d, want=2", got)
+	}
+	tests := []struct {
+		workload string
+		metric   string
+		want     float64
+	}{
+		{workload: "w1", metric: "expected_iops", want: 2000},
+		{workload: "w1", metric: "peak_iops", want: 2000},
+		{workload: "w1", metric: "headroom_iops", want: 500},
+		{workload: "w1", metric: "headroom_percent", want: 25},
+		{workload: "w2", metric: "expected_iops", want: 1000},
+		{workload: "w2", metric: "peak_iops", want: 1000},
+		{workload: "w2", metric: "headroom_iops", want: 750},
+		{workload: "w2", metric: "headroom_percent", want: 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.workload+"_"+tt.metric, func(t *testing.T) {
+			got, ok := mat.GetMetric(tt.metric).GetValueFloat64(mat.GetInstance(tt.workload))
+			if !ok || got != tt.want {
+				t.Errorf("%s got=%f, want=%f", tt.metric, got, tt.want)
+			}
+		})
+	}
+}
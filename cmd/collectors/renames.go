@@ -0,0 +1,72 @@
+package collectors
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/netapp/harvest/v2/third_party/go-version"
+	"strings"
+)
+
+// CounterRename is a counter of a counter table that ONTAP renamed in a release
+type CounterRename struct {
+	Old     string
+	New     string
+	Release string // first ONTAP release with the new name
+}
+
+// CounterRenames returns the renamed counters of a counter table, from the counter_renames section of the template.
+// The section maps counter tables to the releases that renamed their counters, e.g.
+//
+//	counter_renames:
+//	  volume:
+//	    9.14.1:
+//	      - old_counter => new_counter
+func CounterRenames(params *node.Node, table string) ([]CounterRename, error) {
+	var renames []CounterRename
+	section := params.GetChildS("counter_renames")
+	if section == nil {
+		return nil, nil
+	}
+	releases := section.GetChildS(table)
+	if releases == nil {
+		return nil, nil
+	}
+	for _, release := range releases.GetChildren() {
+		if _, err := version.NewVersion(release.GetNameS()); err != nil {
+			return nil, fmt.Errorf("counter_renames: invalid release %s of %s: %w", release.GetNameS(), table, err)
+		}
+		for _, rule := range release.GetAllChildContentS() {
+			oldName, newName, ok := strings.Cut(rule, "=>")
+			oldName, newName = strings.TrimSpace(oldName), strings.TrimSpace(newName)
+			if !ok || oldName == "" || newName == "" {
+				return nil, fmt.Errorf("counter_renames: invalid rename [%s] of %s, want old => new", rule, table)
+			}
+			renames = append(renames, CounterRename{Old: oldName, New: newName, Release: release.GetNameS()})
+		}
+	}
+	return renames, nil
+}
+
+// TranslateCounter returns the name of a template counter in the ONTAP release of the cluster.
+// Counters are renamed forward on releases that have the new name, and back on older releases,
+// so templates can use either name. It also returns the release of the rename, and false when the counter
+// has no other name in the release of the cluster
+func TranslateCounter(renames []CounterRename, name string, clusterVersion string) (string, string, bool) {
+	for _, r := range renames {
+		if name != r.Old && name != r.New {
+			continue
+		}
+		renamed, err := util.VersionAtLeast(clusterVersion, r.Release)
+		if err != nil {
+			return "", "", false
+		}
+		if renamed && name == r.Old {
+			return r.New, r.Release, true
+		}
+		if !renamed && name == r.New {
+			return r.Old, r.Release, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,59 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+)
+
+func TestCounterRenames(t *testing.T) {
+	params, err := tree.LoadYaml([]byte(`
+counter_renames:
+  volume:
+    9.14.1:
+      - read_blocks => read_blocks_total
+      - nfs_read_ops => nfs_ops_read
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renames, err := CounterRenames(params, "volume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renames) != 2 {
+		t.Fatalf("CounterRenames() got=%d, want=2", len(renames))
+	}
+
+	tests := []struct {
+		name           string
+		counter        string
+		clusterVersion string
+		want           string
+		wantOk         bool
+	}{
+		{name: "forward", counter: "read_blocks", clusterVersion: "9.15.1", want: "read_blocks_total", wantOk: true},
+		{name: "forward on release", counter: "read_blocks", clusterVersion: "9.14.1", want: "read_blocks_total", wantOk: true},
+		{name: "back", counter: "nfs_ops_read", clusterVersion: "9.13.1", want: "nfs_read_ops", wantOk: true},
+		{name: "old name on old release", counter: "read_blocks", clusterVersion: "9.13.1"},
+		{name: "new name on new release", counter: "read_blocks_total", clusterVersion: "9.14.1"},
+		{name: "not renamed", counter: "write_blocks", clusterVersion: "9.15.1"},
+		{name: "invalid version", counter: "read_blocks", clusterVersion: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, ok := TranslateCounter(renames, tt.counter, tt.clusterVersion)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("TranslateCounter() got=%s,%t, want=%s,%t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if renames, err := CounterRenames(params, "lun"); err != nil || len(renames) != 0 {
+		t.Errorf("CounterRenames(lun) got=%v,%v, want none", renames, err)
+	}
+
+	bad, _ := tree.LoadYaml([]byte("counter_renames:\n  volume:\n    9.14.1:\n      - read_blocks\n"))
+	if _, err := CounterRenames(bad, "volume"); err == nil {
+		t.Error("CounterRenames() want error for a rename without =>")
+	}
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+)
+
+// Estimate estimates the cost of a data poll from the number of instances of the object and the size of one record
+// of the query and of each endpoint. The counter task is polled first, like the poller does, to build the hrefs
+func (r *Rest) Estimate() ([]collector.Estimate, error) {
+	if _, err := r.PollCounter(); err != nil {
+		return nil, err
+	}
+	instances, bytes, err := r.estimateHref(r.Prop.Href)
+	if err != nil {
+		return nil, err
+	}
+	calls := 1
+	if r.incremental != nil {
+		// each poll collects one page of records
+		bytes = bytes / int64(max(instances, 1)) * int64(min(instances, r.incremental.recordsPerPoll))
+	}
+	metrics := exportableMetrics(r.Prop)
+	for _, e := range r.endpoints {
+		_, endpointBytes, err := r.estimateHref(e.prop.Href)
+		if err != nil {
+			return nil, err
+		}
+		calls++
+		bytes += endpointBytes
+		metrics += exportableMetrics(e.prop)
+	}
+	return []collector.Estimate{{
+		Task:      "data",
+		Calls:     calls,
+		Instances: instances,
+		Series:    instances * (metrics + r.labelSeries()),
+		Bytes:     bytes,
+	}}, nil
+}
+
+// estimateHref returns the number of records of href, and the bytes of all of them, estimated from the first one
+func (r *Rest) estimateHref(href string) (int, int64, error) {
+	count, err := rest.CountRecords(r.Client, href)
+	if err != nil {
+		return 0, 0, err
+	}
+	if count == 0 {
+		return 0, 0, nil
+	}
+	_, size, err := rest.SampleRecord(r.Client, href)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, int64(count) * int64(size), nil
+}
+
+// labelSeries returns 1 when the instances of the object are exported with a labels series, e.g. volume_labels
+func (r *Rest) labelSeries() int {
+	mat := r.Matrix[r.Object]
+	if mat == nil || mat.GetExportOptions().GetChildS("instance_labels") == nil {
+		return 0
+	}
+	return 1
+}
+
+func exportableMetrics(p *prop) int {
+	count := 0
+	for _, m := range p.Metrics {
+		if m.Exportable {
+			count++
+		}
+	}
+	return count
+}
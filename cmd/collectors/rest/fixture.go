@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FixtureName returns the name of the file with the recorded response of href, without extension,
+// e.g. api-storage-luns for api/storage/luns?fields=name
+func FixtureName(href string) string {
+	query, _, _ := strings.Cut(href, "?")
+	return strings.ReplaceAll(strings.Trim(query, "/"), "/", "-")
+}
+
+// readFixture returns the records of the response of href recorded in dir, as .json or .json.gz
+func readFixture(dir string, href string) ([]gjson.Result, error) {
+	name := filepath.Join(dir, FixtureName(href))
+
+	data, err := os.ReadFile(name + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = readGzip(name + ".json.gz")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorded response: %w", err)
+	}
+
+	response := gjson.ParseBytes(data)
+	if records := response.Get("records"); records.Exists() {
+		return records.Array(), nil
+	}
+	// endpoints of a single object, e.g. api/cluster, respond without records
+	return []gjson.Result{response}, nil
+}
+
+func readGzip(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, gz); err != nil { //nolint:gosec
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
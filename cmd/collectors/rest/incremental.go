@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+// incremental pages through the records of an object across polls, for objects with too many records to collect
+// in one poll, e.g. the quota reports of large clusters. Each poll collects at least recordsPerPoll records and
+// resumes from the next page of the previous poll. Polls export the last complete generation of records, so that
+// the instances of a generation are never mixed with the instances of the next one
+type incremental struct {
+	recordsPerPoll int
+	next           string         // link of the next page, empty at the start of a generation
+	staging        *matrix.Matrix // instances of the generation in progress
+	complete       *matrix.Matrix // last complete generation, nil until the first generation is complete
+	generation     int
+	polls          int // polls of the generation in progress
+}
+
+// fetchPages returns the records of the pages of a poll, from href, see rest.FetchPages
+func (r *Rest) fetchPages(href string) ([]gjson.Result, string, error) {
+	if r.Options.Fixtures != "" {
+		records, err := r.GetRestData(href)
+		return records, "", err
+	}
+	records, next, err := rest.FetchPages(r.Context(), r.Client, href, r.incremental.recordsPerPoll)
+	if err != nil {
+		_, err = r.handleError(err)
+	}
+	return records, next, err
+}
+
+// pollIncremental adds the records of the next pages to the generation in progress, and returns the last complete
+// generation. The poll is partial until the last page of a generation is collected, which is exported as the
+// partial metadata metric. A failed page is retried by the next poll, polls that exceed their slot are partial
+func (r *Rest) pollIncremental(
+	fetchPages func(href string) ([]gjson.Result, string, error),
+	endpointFunc func(e *EndPoint) ([]gjson.Result, time.Duration, error),
+) (map[string]*matrix.Matrix, error) {
+	inc := r.incremental
+	startTime := time.Now()
+
+	href := inc.next
+	if inc.staging == nil {
+		// start a new generation
+		href = r.Prop.Href
+		inc.staging = r.Matrix[r.Object].Clone(matrix.With{})
+		inc.polls = 0
+	}
+	records, next, err := fetchPages(href)
+	apiD := time.Since(startTime)
+	if err != nil && next == "" {
+		next = href
+	}
+	inc.next = next
+	inc.polls++
+
+	startTime = time.Now()
+	count, _ := r.handleResults(inc.staging, records, r.Prop, false, true)
+	if err != nil && !errors.Is(err, errs.ErrDeadline) {
+		return nil, err
+	}
+
+	var endpointAPID time.Duration
+	partial := inc.next != ""
+	if !partial {
+		var eCount uint64
+		eCount, endpointAPID = r.ProcessEndPoints(inc.staging, endpointFunc)
+		count += eCount
+		inc.complete = inc.staging
+		inc.staging = nil
+		inc.generation++
+		r.Logger.Info().
+			Int("generation", inc.generation).
+			Int("polls", inc.polls).
+			Int("instances", len(inc.complete.GetInstances())).
+			Msg("Collected all records")
+	}
+
+	// polls return a copy, since plugins change the matrix they are given
+	if inc.complete == nil {
+		r.Matrix[r.Object] = inc.staging.Clone(matrix.With{})
+	} else {
+		r.Matrix[r.Object] = inc.complete.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	}
+	parseD := time.Since(startTime)
+	numRecords := len(r.Matrix[r.Object].GetInstances())
+
+	_ = r.Metadata.LazySetValueInt64("api_time", "data", (apiD + endpointAPID).Microseconds())
+	_ = r.Metadata.LazySetValueInt64("parse_time", "data", parseD.Microseconds())
+	_ = r.Metadata.LazySetValueUint64("metrics", "data", count)
+	_ = r.Metadata.LazySetValueUint64("instances", "data", uint64(numRecords))
+	_ = r.Metadata.LazySetValueUint64("bytesRx", "data", r.Client.Metadata.BytesRx)
+	_ = r.Metadata.LazySetValueUint64("bytesRxWire", "data", r.Client.Metadata.BytesRxWire)
+	_ = r.Metadata.LazySetValueUint64("numCalls", "data", r.Client.Metadata.NumCalls)
+	var partialValue uint8
+	if partial {
+		partialValue = 1
+	}
+	_ = r.Metadata.LazySetValueUint8("partial", "data", partialValue)
+
+	r.AddCollectCount(count)
+
+	if !partial && numRecords == 0 {
+		return nil, errs.New(errs.ErrNoInstance, fmt.Sprintf("no %s instances on cluster", r.Object))
+	}
+	return r.Matrix, nil
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/tidwall/gjson"
+	"testing"
+)
+
+// pagesOf returns a fetch of the pages of volumes, with the links p1, p2, ... to the next pages.
+// The page fails, when set, fails once
+func pagesOf(fails string, pages ...[]string) func(href string) ([]gjson.Result, string, error) {
+	failed := false
+	return func(href string) ([]gjson.Result, string, error) {
+		i := 0
+		if href != "" && href[0] == 'p' {
+			_, _ = fmt.Sscanf(href, "p%d", &i)
+		}
+		if fails != "" && href == fails && !failed {
+			failed = true
+			return nil, href, errors.New("connection reset")
+		}
+		var records []gjson.Result
+		for _, volume := range pages[i] {
+			records = append(records, gjson.Parse(`{"name":"`+volume+`","svm":{"name":"svm1"},"snapshot_count":1}`))
+		}
+		next := ""
+		if i+1 < len(pages) {
+			next = fmt.Sprintf("p%d", i+1)
+		}
+		return records, next, nil
+	}
+}
+
+func TestPollIncremental(t *testing.T) {
+	r := newRest("Volume", "volume.yaml")
+	r.incremental = &incremental{recordsPerPoll: 2}
+
+	type poll struct {
+		instances int
+		partial   uint8
+		wantErr   bool
+	}
+	tests := []struct {
+		name  string
+		fetch func(href string) ([]gjson.Result, string, error)
+		polls []poll
+	}{
+		{
+			name:  "first generation",
+			fetch: pagesOf("", []string{"v1", "v2"}, []string{"v3", "v4"}, []string{"v5"}),
+			polls: []poll{{instances: 0, partial: 1}, {instances: 0, partial: 1}, {instances: 5}},
+		},
+		{
+			name:  "last generation is exported until the next one is complete",
+			fetch: pagesOf("", []string{"v1", "v2"}, []string{"v3"}),
+			polls: []poll{{instances: 5, partial: 1}, {instances: 3}},
+		},
+		{
+			name:  "failed page is retried",
+			fetch: pagesOf("p1", []string{"v1", "v2"}, []string{"v3", "v6"}),
+			polls: []poll{{instances: 3, partial: 1}, {wantErr: true}, {instances: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, p := range tt.polls {
+				r.Metadata.ResetInstance("data")
+				mm, err := r.pollIncremental(tt.fetch, volumeEndpoints)
+				if (err != nil) != p.wantErr {
+					t.Fatalf("poll %d err=%v, wantErr=%v", i, err, p.wantErr)
+				}
+				if p.wantErr {
+					continue
+				}
+				if got := len(mm["Volume"].GetInstances()); got != p.instances {
+					t.Errorf("poll %d instances got=%d, want=%d", i, got, p.instances)
+				}
+				partial, _ := r.Metadata.GetMetric("partial").GetValueUint8(r.Metadata.GetInstance("data"))
+				if partial != p.partial {
+					t.Errorf("poll %d partial got=%d, want=%d", i, partial, p.partial)
+				}
+			}
+		})
+	}
+
+	// an empty generation has no instances
+	_, err := r.pollIncremental(pagesOf("", nil), volumeEndpoints)
+	if !errors.Is(err, errs.ErrNoInstance) {
+		t.Errorf("empty generation err=%v, want %v", err, errs.ErrNoInstance)
+	}
+}
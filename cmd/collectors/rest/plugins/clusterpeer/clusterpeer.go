@@ -0,0 +1,147 @@
+// Package clusterpeer marks cluster peer relationships that are only configured on one side, and the remote clusters
+// of SnapMirror relationships that are not peered. Replication incidents often start as peering issues
+package clusterpeer
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"slices"
+	"time"
+)
+
+// authStates are the authentication states of a peer relationship that both clusters accepted
+var authStates = []string{"", "ok", "ok_and_offer"}
+
+type ClusterPeer struct {
+	*plugin.AbstractPlugin
+	client  *rest.Client
+	missing *matrix.Matrix
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &ClusterPeer{AbstractPlugin: p}
+}
+
+func (c *ClusterPeer) Init() error {
+	var err error
+	if err := c.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if c.client, err = rest.New(conf.ZapiPoller(c.ParentParams), timeout, c.Auth); err != nil {
+		c.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := c.client.Init(5); err != nil {
+		return err
+	}
+
+	c.missing = matrix.New(c.Parent+".ClusterPeerMissing", "cluster_peer", "cluster_peer_missing")
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	instanceKeys.NewChildS("", "remote_cluster")
+	c.missing.SetExportOptions(exportOptions)
+	if _, err := c.missing.NewMetricFloat64("missing"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ClusterPeer) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[c.Object]
+	c.client.Metadata.Reset()
+
+	if data.GetMetric("asymmetric") == nil {
+		if _, err := data.NewMetricFloat64("asymmetric"); err != nil {
+			c.Logger.Error().Err(err).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+
+	peered := make(map[string]bool)
+	for _, peer := range data.GetInstances() {
+		peered[peer.GetLabel("name")] = true
+		asymmetric := Asymmetric(peer.GetLabel("status"), peer.GetLabel("auth_state"))
+		if err := data.GetMetric("asymmetric").SetValueFloat64(peer, boolToFloat(asymmetric)); err != nil {
+			c.Logger.Error().Err(err).Msg("Unable to set value on metric")
+		}
+	}
+
+	c.missing.PurgeInstances()
+	c.missing.Reset()
+	c.missing.SetGlobalLabels(data.GetGlobalLabels())
+
+	remotes, err := c.remoteClusters()
+	if err != nil {
+		c.Logger.Error().Err(err).Msg("Failed to collect SnapMirror relationships")
+		return nil, c.client.Metadata, nil
+	}
+	local := data.GetGlobalLabels()["cluster"]
+	for remote, relationships := range MissingPeers(local, remotes, peered) {
+		instance, err := c.missing.NewInstance(remote)
+		if err != nil {
+			c.Logger.Error().Err(err).Str("remote", remote).Msg("Failed to add instance")
+			continue
+		}
+		instance.SetLabel("remote_cluster", remote)
+		_ = c.missing.GetMetric("missing").SetValueFloat64(instance, float64(relationships))
+	}
+
+	return []*matrix.Matrix{c.missing}, c.client.Metadata, nil
+}
+
+// remoteClusters returns the source and destination clusters of the SnapMirror relationships of the cluster,
+// both where the cluster is the destination and where it is the source
+func (c *ClusterPeer) remoteClusters() ([][2]string, error) {
+	var clusters [][2]string
+	for _, filter := range [][]string{nil, {"list_destinations_only=true"}} {
+		href := rest.NewHrefBuilder().
+			APIPath("api/snapmirror/relationships").
+			Fields([]string{"source.cluster.name", "destination.cluster.name"}).
+			Filter(filter).
+			Build()
+		relationships, err := collectors.InvokeRestCall(c.client, href, c.Logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range relationships {
+			clusters = append(clusters, [2]string{r.Get("source.cluster.name").String(), r.Get("destination.cluster.name").String()})
+		}
+	}
+	return clusters, nil
+}
+
+// Asymmetric returns true when a peer relationship is only configured on one side: the remote cluster has not
+// created its side yet, or did not accept the authentication of the local side
+func Asymmetric(status string, authState string) bool {
+	return status == "pending" || !slices.Contains(authStates, authState)
+}
+
+// MissingPeers returns the remote clusters of SnapMirror relationships that are not peered, with the number of
+// their relationships. clusters are the source and destination clusters of each relationship. Clusters that are
+// empty or local are not remote
+func MissingPeers(local string, clusters [][2]string, peered map[string]bool) map[string]int {
+	missing := make(map[string]int)
+	for _, pair := range clusters {
+		for _, cluster := range pair {
+			if cluster == "" || cluster == local || peered[cluster] {
+				continue
+			}
+			missing[cluster]++
+		}
+	}
+	return missing
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
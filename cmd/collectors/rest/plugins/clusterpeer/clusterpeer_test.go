@@ -0,0 +1,45 @@
+package clusterpeer
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestAsymmetric(t *testing.T) {
+	tests := []struct {
+		status    string
+		authState string
+		want      bool
+	}{
+		{status: "available", authState: "ok", want: false},
+		{status: "partial", authState: "ok_and_offer", want: false},
+		{status: "unavailable", authState: "", want: false},
+		{status: "pending", authState: "pending", want: true},
+		{status: "available", authState: "absent", want: true},
+		{status: "unavailable", authState: "revoked", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.authState, func(t *testing.T) {
+			if got := Asymmetric(tt.status, tt.authState); got != tt.want {
+				t.Errorf("Asymmetric() got=%t want=%t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingPeers(t *testing.T) {
+	clusters := [][2]string{
+		{"c2", "c1"},
+		{"c3", "c1"},
+		{"c3", "c1"},
+		{"c1", "c4"},
+		// intra-cluster relationships have no remote cluster
+		{"", ""},
+		{"c1", "c1"},
+	}
+	got := MissingPeers("c1", clusters, map[string]bool{"c2": true})
+	want := map[string]int{"c3": 2, "c4": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("MissingPeers() got=%v want=%v", got, want)
+	}
+}
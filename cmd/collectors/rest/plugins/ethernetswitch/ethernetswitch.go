@@ -0,0 +1,147 @@
+// Package ethernetswitch exports the fans and power supplies of the switches that ONTAP monitors. ONTAP has no REST
+// API for the environment of a switch, but its switch health monitor raises an alert when a fan or a power supply
+// of a switch fails, so a component has failed while it has an alert
+package ethernetswitch
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	fan   = "fan"
+	psu   = "psu"
+	other = "other"
+)
+
+var metrics = []string{"alerts", "fan_failed", "monitored", "psu_failed"}
+
+type EthernetSwitch struct {
+	*plugin.AbstractPlugin
+	client *rest.Client
+}
+
+// alert is an alert of the switch health monitor
+type alert struct {
+	resource  string
+	component string
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &EthernetSwitch{AbstractPlugin: p}
+}
+
+func (e *EthernetSwitch) Init() error {
+	var err error
+	if err := e.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if e.client, err = rest.New(conf.ZapiPoller(e.ParentParams), timeout, e.Auth); err != nil {
+		e.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return e.client.Init(5)
+}
+
+func (e *EthernetSwitch) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[e.Object]
+	e.client.Metadata.Reset()
+
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			e.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+
+	// No monitored switches
+	if len(data.GetInstances()) == 0 {
+		return nil, e.client.Metadata, nil
+	}
+
+	alerts, err := e.getAlerts()
+	if err != nil {
+		e.Logger.Error().Err(err).Msg("Failed to collect switch health alerts")
+	}
+	setMetrics(data, alerts)
+
+	return nil, e.client.Metadata, nil
+}
+
+// setMetrics sets the alerts of each switch, and whether its fans or power supplies failed.
+// The metrics of the components are absent when the alerts could not be collected
+func setMetrics(data *matrix.Matrix, alerts []alert) {
+	for _, instance := range data.GetInstances() {
+		monitored := 0.0
+		if instance.GetLabel("monitored") == "true" {
+			monitored = 1
+		}
+		_ = data.GetMetric("monitored").SetValueFloat64(instance, monitored)
+
+		if alerts == nil {
+			continue
+		}
+		counts := map[string]float64{fan: 0, psu: 0, other: 0}
+		for _, a := range alerts {
+			if isResourceOf(a.resource, instance.GetLabel("switch")) {
+				counts[a.component]++
+			}
+		}
+		_ = data.GetMetric("alerts").SetValueFloat64(instance, counts[fan]+counts[psu]+counts[other])
+		_ = data.GetMetric("fan_failed").SetValueFloat64(instance, min(counts[fan], 1))
+		_ = data.GetMetric("psu_failed").SetValueFloat64(instance, min(counts[psu], 1))
+	}
+}
+
+// getAlerts returns the alerts of the switch health monitor, which is named cluster-switch before ONTAP 9.8.
+// The result is empty, not nil, when there are no alerts
+func (e *EthernetSwitch) getAlerts() ([]alert, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/private/cli/system/health/alert").
+		Fields([]string{"alert_id", "alerting_resource", "probable_cause"}).
+		Filter([]string{"monitor=ethernet-switch|cluster-switch"}).
+		Build()
+	records, err := collectors.InvokeRestCall(e.client, href, e.Logger)
+	if err != nil {
+		return nil, err
+	}
+	alerts := make([]alert, 0, len(records))
+	for _, r := range records {
+		alerts = append(alerts, alert{
+			resource:  r.Get("alerting_resource").String(),
+			component: component(r.Get("alert_id").String(), r.Get("probable_cause").String()),
+		})
+	}
+	return alerts, nil
+}
+
+// component returns the component of a switch that an alert is about, from its ID or probable cause,
+// e.g. ClusterSwitchFanFailed_Alert or power-supply-failure
+func component(alertID string, cause string) string {
+	s := strings.ToLower(alertID + " " + cause)
+	switch {
+	case strings.Contains(s, "fan"):
+		return fan
+	case strings.Contains(s, "power") || strings.Contains(s, "psu"):
+		return psu
+	default:
+		return other
+	}
+}
+
+// isResourceOf returns true when the alerting resource of an alert is the switch. The resource is the name
+// of the switch, sometimes followed by its serial number, e.g. sw1 (FOC1234)
+func isResourceOf(resource string, switchName string) bool {
+	if resource == switchName {
+		return true
+	}
+	return strings.HasPrefix(resource, switchName+" ")
+}
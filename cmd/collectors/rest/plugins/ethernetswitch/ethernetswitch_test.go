@@ -0,0 +1,77 @@
+package ethernetswitch
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func TestComponent(t *testing.T) {
+	tests := []struct {
+		alertID string
+		cause   string
+		want    string
+	}{
+		{alertID: "ClusterSwitchFanFailed_Alert", want: fan},
+		{alertID: "EthernetSwitch_Alert", cause: "power-supply-failure", want: psu},
+		{alertID: "SwitchPSUFailed", want: psu},
+		{alertID: "ClusterSwitchUnreachable_Alert", cause: "connection-failure", want: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alertID, func(t *testing.T) {
+			if got := component(tt.alertID, tt.cause); got != tt.want {
+				t.Errorf("component() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetMetrics(t *testing.T) {
+	data := matrix.New("Rest.ethernet_switch", "ethernet_switch", "ethernet_switch")
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"sw1", "sw10", "sw2"} {
+		instance, _ := data.NewInstance(name)
+		instance.SetLabel("switch", name)
+		instance.SetLabel("monitored", "true")
+	}
+	data.GetInstance("sw2").SetLabel("monitored", "false")
+
+	alerts := []alert{
+		{resource: "sw1 (FOC1234)", component: fan},
+		{resource: "sw1 (FOC1234)", component: fan},
+		{resource: "sw1", component: other},
+		{resource: "sw10", component: psu},
+	}
+	setMetrics(data, alerts)
+
+	tests := []struct {
+		instance string
+		metric   string
+		want     float64
+	}{
+		{instance: "sw1", metric: "alerts", want: 3},
+		{instance: "sw1", metric: "fan_failed", want: 1},
+		{instance: "sw1", metric: "psu_failed", want: 0},
+		{instance: "sw10", metric: "alerts", want: 1},
+		{instance: "sw10", metric: "psu_failed", want: 1},
+		{instance: "sw2", metric: "alerts", want: 0},
+		{instance: "sw2", metric: "monitored", want: 0},
+		{instance: "sw1", metric: "monitored", want: 1},
+	}
+	for _, tt := range tests {
+		got, ok := data.GetMetric(tt.metric).GetValueFloat64(data.GetInstance(tt.instance))
+		if !ok || got != tt.want {
+			t.Errorf("%s %s got=%f %t, want=%f", tt.instance, tt.metric, got, ok, tt.want)
+		}
+	}
+
+	// without alerts, the components are unknown
+	data.Reset()
+	setMetrics(data, nil)
+	if _, ok := data.GetMetric("fan_failed").GetValueFloat64(data.GetInstance("sw1")); ok {
+		t.Errorf("fan_failed is set without alerts, want absent")
+	}
+}
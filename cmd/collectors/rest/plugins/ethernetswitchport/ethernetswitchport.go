@@ -0,0 +1,129 @@
+// Package ethernetswitchport maps the switch ports that are cabled to nodes to the ports of those nodes,
+// usually their cluster ports, so a dashboard can show both ends of a link, and whether they agree on its speed
+package ethernetswitchport
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"time"
+)
+
+var metrics = []string{"node_port_up", "speed_mismatch"}
+
+type EthernetSwitchPort struct {
+	*plugin.AbstractPlugin
+	client *rest.Client
+}
+
+// nodePort is an ethernet port of a node
+type nodePort struct {
+	broadcastDomain string
+	speed           float64 // Mb/s
+	state           string
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &EthernetSwitchPort{AbstractPlugin: p}
+}
+
+func (e *EthernetSwitchPort) Init() error {
+	var err error
+	if err := e.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if e.client, err = rest.New(conf.ZapiPoller(e.ParentParams), timeout, e.Auth); err != nil {
+		e.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return e.client.Init(5)
+}
+
+func (e *EthernetSwitchPort) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[e.Object]
+	e.client.Metadata.Reset()
+
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			e.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+
+	// No monitored switches
+	if len(data.GetInstances()) == 0 {
+		return nil, e.client.Metadata, nil
+	}
+
+	nodePorts, err := e.getNodePorts()
+	if err != nil {
+		e.Logger.Error().Err(err).Msg("Failed to collect node ports")
+		return nil, e.client.Metadata, nil
+	}
+	mapPorts(data, nodePorts)
+
+	return nil, e.client.Metadata, nil
+}
+
+// mapPorts sets the node port of each switch port that is cabled to a node. Switch ports that are cabled to other
+// devices, e.g. ISLs and shelves, have no node port
+func mapPorts(data *matrix.Matrix, nodePorts map[string]nodePort) {
+	for _, instance := range data.GetInstances() {
+		for _, name := range metrics {
+			data.GetMetric(name).SetValueNAN(instance)
+		}
+		node := instance.GetLabel("remote_node")
+		port := instance.GetLabel("remote_port")
+		np, ok := nodePorts[node+"."+port]
+		if node == "" || !ok {
+			continue
+		}
+		instance.SetLabel("node", node)
+		instance.SetLabel("node_port", port)
+		instance.SetLabel("node_port_broadcast_domain", np.broadcastDomain)
+
+		up := 0.0
+		if np.state == "up" {
+			up = 1
+		}
+		_ = data.GetMetric("node_port_up").SetValueFloat64(instance, up)
+
+		// the speed of a port that is down is unknown
+		speed, ok := data.GetMetric("speed").GetValueFloat64(instance)
+		if !ok || np.speed == 0 || speed == 0 {
+			continue
+		}
+		mismatch := 0.0
+		if speed != np.speed {
+			mismatch = 1
+		}
+		_ = data.GetMetric("speed_mismatch").SetValueFloat64(instance, mismatch)
+	}
+}
+
+// getNodePorts returns the physical ethernet ports of the nodes by node and port name, e.g. node1.e0a
+func (e *EthernetSwitchPort) getNodePorts() (map[string]nodePort, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/network/ethernet/ports").
+		Fields([]string{"name", "node.name", "broadcast_domain.name", "speed", "state"}).
+		Filter([]string{"type=physical"}).
+		Build()
+	records, err := collectors.InvokeRestCall(e.client, href, e.Logger)
+	if err != nil {
+		return nil, err
+	}
+	ports := make(map[string]nodePort, len(records))
+	for _, r := range records {
+		ports[r.Get("node.name").String()+"."+r.Get("name").String()] = nodePort{
+			broadcastDomain: r.Get("broadcast_domain.name").String(),
+			speed:           r.Get("speed").Float(),
+			state:           r.Get("state").String(),
+		}
+	}
+	return ports, nil
+}
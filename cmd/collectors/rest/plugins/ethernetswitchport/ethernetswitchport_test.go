@@ -0,0 +1,73 @@
+package ethernetswitchport
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func TestMapPorts(t *testing.T) {
+	data := matrix.New("Rest.ethernet_switch_port", "ethernet_switch_port", "ethernet_switch_port")
+	speed, _ := data.NewMetricFloat64("speed")
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ports := []struct {
+		key        string
+		remoteNode string
+		remotePort string
+		speed      float64
+	}{
+		{key: "sw1.Eth1/1", remoteNode: "node1", remotePort: "e0a", speed: 100000},
+		{key: "sw1.Eth1/2", remoteNode: "node2", remotePort: "e0a", speed: 100000},
+		{key: "sw1.Eth1/3", remoteNode: "node2", remotePort: "e0b", speed: 0},
+		{key: "sw1.Eth1/65", speed: 100000},
+	}
+	for _, p := range ports {
+		instance, _ := data.NewInstance(p.key)
+		instance.SetLabel("remote_node", p.remoteNode)
+		instance.SetLabel("remote_port", p.remotePort)
+		_ = speed.SetValueFloat64(instance, p.speed)
+	}
+	nodePorts := map[string]nodePort{
+		"node1.e0a": {broadcastDomain: "Cluster", speed: 100000, state: "up"},
+		"node2.e0a": {broadcastDomain: "Cluster", speed: 40000, state: "up"},
+		"node2.e0b": {broadcastDomain: "Cluster", state: "down"},
+	}
+	mapPorts(data, nodePorts)
+
+	tests := []struct {
+		key           string
+		node          string
+		up            float64
+		mismatch      float64
+		wantUp        bool
+		wantMismatch  bool
+		wantBroadcast string
+	}{
+		{key: "sw1.Eth1/1", node: "node1", up: 1, wantUp: true, wantMismatch: true, wantBroadcast: "Cluster"},
+		{key: "sw1.Eth1/2", node: "node2", up: 1, mismatch: 1, wantUp: true, wantMismatch: true, wantBroadcast: "Cluster"},
+		{key: "sw1.Eth1/3", node: "node2", wantUp: true, wantBroadcast: "Cluster"},
+		{key: "sw1.Eth1/65"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			instance := data.GetInstance(tt.key)
+			if got := instance.GetLabel("node"); got != tt.node {
+				t.Errorf("node got=%s, want=%s", got, tt.node)
+			}
+			if got := instance.GetLabel("node_port_broadcast_domain"); got != tt.wantBroadcast {
+				t.Errorf("node_port_broadcast_domain got=%s, want=%s", got, tt.wantBroadcast)
+			}
+			up, ok := data.GetMetric("node_port_up").GetValueFloat64(instance)
+			if ok != tt.wantUp || up != tt.up {
+				t.Errorf("node_port_up got=%f %t, want=%f %t", up, ok, tt.up, tt.wantUp)
+			}
+			mismatch, ok := data.GetMetric("speed_mismatch").GetValueFloat64(instance)
+			if ok != tt.wantMismatch || mismatch != tt.mismatch {
+				t.Errorf("speed_mismatch got=%f %t, want=%f %t", mismatch, ok, tt.mismatch, tt.wantMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Package job tracks the long-running operations of ONTAP, such as volume moves, aggregate relocations, and tiering
+// scans, from their jobs. Each job of a selected type is exported with its progress, state, and duration, and keeps
+// its state after it ends, while ONTAP keeps it in its job history, so alerts can fire when a job completes or fails
+package job
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var metrics = []string{"completed", "duration", "failed", "progress", "running"}
+
+// defaultTypes are the job types that are exported when the template does not select any.
+// ONTAP has no type for a job, so the type is matched from its description
+var defaultTypes = map[string]string{
+	"aggregate_relocation": `(?i)relocat`,
+	"tiering_scan":         `(?i)tier|object store|fabricpool`,
+	"volume_move":          `(?i)vol(ume)? move|^move `,
+}
+
+// progressRegex matches the percent complete that ONTAP reports in the message of a running job, e.g. 42% complete
+var progressRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%`)
+
+type Job struct {
+	*plugin.AbstractPlugin
+	types []jobType
+}
+
+// jobType is a selected type of job, and the regular expression that matches the description of its jobs
+type jobType struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &Job{AbstractPlugin: p}
+}
+
+func (j *Job) Init() error {
+	if err := j.InitAbc(); err != nil {
+		return err
+	}
+	types := defaultTypes
+	if t := j.Params.GetChildS("types"); t != nil {
+		types = make(map[string]string)
+		for _, child := range t.GetChildren() {
+			types[child.GetNameS()] = child.GetContentS()
+		}
+	}
+	var err error
+	if j.types, err = parseTypes(types); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseTypes compiles the regular expressions of the job types, sorted by name so a job that matches several types
+// always gets the same one
+func parseTypes(types map[string]string) ([]jobType, error) {
+	parsed := make([]jobType, 0, len(types))
+	for name, expr := range types {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("types: invalid regular expression of %s [%s]: %w", name, expr, err)
+		}
+		parsed = append(parsed, jobType{name: name, regex: regex})
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("types: no job types selected")
+	}
+	slices.SortFunc(parsed, func(a, b jobType) int { return strings.Compare(a.name, b.name) })
+	return parsed, nil
+}
+
+func (j *Job) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[j.Object]
+
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			j.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+	setMetrics(data, j.types, time.Now())
+	return nil, nil, nil
+}
+
+// setMetrics sets the type label and the metrics of each job at now. Jobs that are not of a selected type
+// are not exported
+func setMetrics(data *matrix.Matrix, types []jobType, now time.Time) {
+	for _, instance := range data.GetInstances() {
+		t := typeOf(instance.GetLabel("description"), types)
+		if t == "" {
+			instance.SetExportable(false)
+			continue
+		}
+		instance.SetExportable(true)
+		instance.SetLabel("type", t)
+
+		state := instance.GetLabel("state")
+		_ = data.GetMetric("running").SetValueFloat64(instance, boolToFloat(state == "running" || state == "queued" || state == "paused"))
+		_ = data.GetMetric("completed").SetValueFloat64(instance, boolToFloat(state == "success"))
+		_ = data.GetMetric("failed").SetValueFloat64(instance, boolToFloat(state == "failure"))
+
+		if state == "success" {
+			_ = data.GetMetric("progress").SetValueFloat64(instance, 100)
+		} else if progress, ok := parseProgress(instance.GetLabel("message")); ok {
+			_ = data.GetMetric("progress").SetValueFloat64(instance, progress)
+		} else {
+			data.GetMetric("progress").SetValueNAN(instance)
+		}
+
+		if duration, ok := jobDuration(data, instance, now); ok {
+			_ = data.GetMetric("duration").SetValueFloat64(instance, duration)
+		} else {
+			data.GetMetric("duration").SetValueNAN(instance)
+		}
+	}
+}
+
+// typeOf returns the first type whose regular expression matches the description of a job, or an empty string
+func typeOf(description string, types []jobType) string {
+	for _, t := range types {
+		if t.regex.MatchString(description) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// parseProgress returns the percent complete of the message of a job
+func parseProgress(message string) (float64, bool) {
+	match := progressRegex.FindStringSubmatch(message)
+	if match == nil {
+		return 0, false
+	}
+	progress, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || progress > 100 {
+		return 0, false
+	}
+	return progress, true
+}
+
+// jobDuration returns the seconds from the start of a job to its end, or to now while the job has not ended.
+// The start and end times are the timestamp counters of the template
+func jobDuration(data *matrix.Matrix, instance *matrix.Instance, now time.Time) (float64, bool) {
+	startMetric := data.GetMetric("start_time")
+	if startMetric == nil {
+		return 0, false
+	}
+	start, ok := startMetric.GetValueFloat64(instance)
+	if !ok || start <= 0 {
+		return 0, false
+	}
+	end := float64(now.Unix())
+	if endMetric := data.GetMetric("end_time"); endMetric != nil {
+		if e, ok := endMetric.GetValueFloat64(instance); ok && e > 0 {
+			end = e
+		}
+	}
+	return max(end-start, 0), true
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
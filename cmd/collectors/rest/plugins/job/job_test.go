@@ -0,0 +1,127 @@
+package job
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		message string
+		want    float64
+		ok      bool
+	}{
+		{message: "Cutover started: 42% complete", want: 42, ok: true},
+		{message: "Scanning: 12.5 % complete", want: 12.5, ok: true},
+		{message: "Transferring data: 20.11GB sent.", ok: false},
+		{message: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			got, ok := parseProgress(tt.message)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseProgress() got=%f %t, want=%f %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	if _, err := parseTypes(map[string]string{"bad": "("}); err == nil {
+		t.Errorf("parseTypes() of an invalid regular expression, want error")
+	}
+	if _, err := parseTypes(map[string]string{}); err == nil {
+		t.Errorf("parseTypes() of no types, want error")
+	}
+	types, err := parseTypes(defaultTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := typeOf(`Move "vol1" in Vserver "svm1" to aggregate "aggr2"`, types); got != "volume_move" {
+		t.Errorf("typeOf() got=%s, want=volume_move", got)
+	}
+	if got := typeOf("Snapshot Delete", types); got != "" {
+		t.Errorf("typeOf() got=%s, want empty", got)
+	}
+}
+
+func TestSetMetrics(t *testing.T) {
+	types, err := parseTypes(defaultTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(10_000, 0)
+
+	data := matrix.New("Rest.job", "job", "job")
+	for _, name := range append(metrics, "start_time", "end_time") {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs := []struct {
+		uuid        string
+		description string
+		state       string
+		message     string
+		start       float64
+		end         float64
+	}{
+		{uuid: "move", description: "Volume Move", state: "running", message: "Cutover: 42% complete", start: 9_000},
+		{uuid: "scan", description: "Tiering Scanner", state: "success", start: 1_000, end: 4_000},
+		{uuid: "arl", description: "Aggregate Relocation", state: "failure", message: "Relocation failed", start: 5_000, end: 5_600},
+		{uuid: "other", description: "Snapshot Delete", state: "running"},
+	}
+	for _, j := range jobs {
+		instance, _ := data.NewInstance(j.uuid)
+		instance.SetLabel("description", j.description)
+		instance.SetLabel("state", j.state)
+		instance.SetLabel("message", j.message)
+		if j.start > 0 {
+			_ = data.GetMetric("start_time").SetValueFloat64(instance, j.start)
+		}
+		if j.end > 0 {
+			_ = data.GetMetric("end_time").SetValueFloat64(instance, j.end)
+		}
+	}
+	setMetrics(data, types, now)
+
+	tests := []struct {
+		instance string
+		metric   string
+		want     float64
+	}{
+		{instance: "move", metric: "running", want: 1},
+		{instance: "move", metric: "progress", want: 42},
+		{instance: "move", metric: "duration", want: 1_000},
+		{instance: "scan", metric: "completed", want: 1},
+		{instance: "scan", metric: "running", want: 0},
+		{instance: "scan", metric: "progress", want: 100},
+		{instance: "scan", metric: "duration", want: 3_000},
+		{instance: "arl", metric: "failed", want: 1},
+		{instance: "arl", metric: "completed", want: 0},
+		{instance: "arl", metric: "duration", want: 600},
+	}
+	for _, tt := range tests {
+		got, ok := data.GetMetric(tt.metric).GetValueFloat64(data.GetInstance(tt.instance))
+		if !ok || got != tt.want {
+			t.Errorf("%s %s got=%f %t, want=%f", tt.instance, tt.metric, got, ok, tt.want)
+		}
+	}
+
+	wantTypes := map[string]string{"move": "volume_move", "scan": "tiering_scan", "arl": "aggregate_relocation"}
+	for uuid, want := range wantTypes {
+		if got := data.GetInstance(uuid).GetLabel("type"); got != want {
+			t.Errorf("%s type got=%s, want=%s", uuid, got, want)
+		}
+	}
+	if data.GetInstance("other").IsExportable() {
+		t.Errorf("other is exportable, want jobs of no selected type to not be exported")
+	}
+
+	// the progress of a failed job without a percent is unknown
+	if v, ok := data.GetMetric("progress").GetValueFloat64(data.GetInstance("arl")); ok && !math.IsNaN(v) {
+		t.Errorf("arl progress got=%f, want absent", v)
+	}
+}
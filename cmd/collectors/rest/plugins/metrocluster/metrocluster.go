@@ -0,0 +1,227 @@
+// Package metrocluster collects the MetroCluster state of the cluster and the resync progress of the plexes of
+// mirrored aggregates. Both are only collected when the MetroCluster template found nodes, so clusters that are
+// not in a MetroCluster make no extra requests
+package metrocluster
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+const (
+	clusterObject = "metrocluster"
+	plexObject    = "metrocluster_plex"
+)
+
+var clusterMetrics = []string{"auso_enabled", "configured", "switchover"}
+var clusterLabels = []string{"auso_failure_domain", "configuration_type", "local_configuration_state", "local_mode",
+	"remote_configuration_state", "remote_mode"}
+
+var plexMetrics = []string{"online", "resync_percent", "resyncing"}
+var plexLabels = []string{"resync_level", "state"}
+
+type Metrocluster struct {
+	*plugin.AbstractPlugin
+	client  *rest.Client
+	cluster *matrix.Matrix
+	plex    *matrix.Matrix
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &Metrocluster{AbstractPlugin: p}
+}
+
+func (m *Metrocluster) Init() error {
+	var err error
+	if err := m.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if m.client, err = rest.New(conf.ZapiPoller(m.ParentParams), timeout, m.Auth); err != nil {
+		m.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := m.client.Init(5); err != nil {
+		return err
+	}
+
+	if m.cluster, err = newMatrix(m.Parent, clusterObject, []string{"remote_cluster"}, clusterLabels, clusterMetrics); err != nil {
+		return err
+	}
+	if m.plex, err = newMatrix(m.Parent, plexObject, []string{"aggr", "node", "plex"}, plexLabels, plexMetrics); err != nil {
+		return err
+	}
+	return nil
+}
+
+func newMatrix(parent string, object string, keys []string, labels []string, metrics []string) (*matrix.Matrix, error) {
+	mat := matrix.New(parent+"."+object, object, object)
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range keys {
+		instanceKeys.NewChildS("", key)
+	}
+	instanceLabels := exportOptions.NewChildS("instance_labels", "")
+	for _, label := range labels {
+		instanceLabels.NewChildS("", label)
+	}
+	mat.SetExportOptions(exportOptions)
+	for _, metric := range metrics {
+		if _, err := mat.NewMetricFloat64(metric); err != nil {
+			return nil, err
+		}
+	}
+	return mat, nil
+}
+
+func (m *Metrocluster) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[m.Object]
+	m.client.Metadata.Reset()
+
+	m.cluster.PurgeInstances()
+	m.cluster.Reset()
+	m.plex.PurgeInstances()
+	m.plex.Reset()
+
+	// Not a MetroCluster
+	if len(data.GetInstances()) == 0 {
+		return nil, m.client.Metadata, nil
+	}
+
+	m.cluster.SetGlobalLabels(data.GetGlobalLabels())
+	m.plex.SetGlobalLabels(data.GetGlobalLabels())
+
+	if err := m.collectCluster(); err != nil {
+		m.Logger.Error().Err(err).Msg("Failed to collect metrocluster")
+	}
+	if err := m.collectPlexes(); err != nil {
+		m.Logger.Error().Err(err).Msg("Failed to collect metrocluster plexes")
+	}
+
+	return []*matrix.Matrix{m.cluster, m.plex}, m.client.Metadata, nil
+}
+
+func (m *Metrocluster) collectCluster() error {
+	href := rest.NewHrefBuilder().
+		APIPath("api/cluster/metrocluster").
+		Fields([]string{"local", "remote", "auso_failure_domain", "configuration_type"}).
+		Build()
+	response, err := m.client.GetRest(href)
+	if err != nil {
+		return err
+	}
+	result := gjson.ParseBytes(response)
+
+	instance, err := m.cluster.NewInstance(result.Get("remote.cluster.name").String())
+	if err != nil {
+		return err
+	}
+	instance.SetLabel("remote_cluster", result.Get("remote.cluster.name").String())
+	instance.SetLabel("auso_failure_domain", result.Get("auso_failure_domain").String())
+	instance.SetLabel("configuration_type", result.Get("configuration_type").String())
+	instance.SetLabel("local_configuration_state", result.Get("local.configuration_state").String())
+	instance.SetLabel("local_mode", result.Get("local.mode").String())
+	instance.SetLabel("remote_configuration_state", result.Get("remote.configuration_state").String())
+	instance.SetLabel("remote_mode", result.Get("remote.mode").String())
+
+	for name, value := range ClusterValues(result) {
+		m.setValue(m.cluster, name, instance, value)
+	}
+	return nil
+}
+
+func (m *Metrocluster) collectPlexes() error {
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/aggregates").
+		Fields([]string{"name", "uuid", "home_node.name"}).
+		Filter([]string{"block_storage.mirror.enabled=true"}).
+		Build()
+	aggregates, err := collectors.InvokeRestCall(m.client, href, m.Logger)
+	if err != nil {
+		return err
+	}
+
+	for _, aggregate := range aggregates {
+		href = rest.NewHrefBuilder().
+			APIPath("api/storage/aggregates/" + aggregate.Get("uuid").String() + "/plexes").
+			Fields([]string{"name", "online", "state", "resync"}).
+			Build()
+		plexes, err := collectors.InvokeRestCall(m.client, href, m.Logger)
+		if err != nil {
+			continue
+		}
+		aggrName := aggregate.Get("name").String()
+		for _, plex := range plexes {
+			plexName := plex.Get("name").String()
+			instance, err := m.plex.NewInstance(aggrName + plexName)
+			if err != nil {
+				m.Logger.Error().Err(err).Str("aggr", aggrName).Str("plex", plexName).Msg("Failed to add instance")
+				continue
+			}
+			instance.SetLabel("aggr", aggrName)
+			instance.SetLabel("node", aggregate.Get("home_node.name").String())
+			instance.SetLabel("plex", plexName)
+			instance.SetLabel("resync_level", plex.Get("resync.level").String())
+			instance.SetLabel("state", plex.Get("state").String())
+
+			for name, value := range PlexValues(plex) {
+				m.setValue(m.plex, name, instance, value)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Metrocluster) setValue(mat *matrix.Matrix, name string, instance *matrix.Instance, value float64) {
+	if err := mat.GetMetric(name).SetValueFloat64(instance, value); err != nil {
+		m.Logger.Error().Err(err).Str("metric", name).Msg("Unable to set value on metric")
+	}
+}
+
+// ClusterValues returns the metrics of an api/cluster/metrocluster record. A MetroCluster is switched over
+// when either cluster is not in normal mode, including partial switchovers and waiting for switchback
+func ClusterValues(result gjson.Result) map[string]float64 {
+	values := map[string]float64{
+		"auso_enabled": 0,
+		"configured":   0,
+		"switchover":   0,
+	}
+	if domain := result.Get("auso_failure_domain").String(); domain != "" && domain != "auso_disabled" {
+		values["auso_enabled"] = 1
+	}
+	if result.Get("local.configuration_state").String() == "configured" {
+		values["configured"] = 1
+	}
+	for _, mode := range []string{result.Get("local.mode").String(), result.Get("remote.mode").String()} {
+		if mode != "" && mode != "normal" {
+			values["switchover"] = 1
+		}
+	}
+	return values
+}
+
+// PlexValues returns the metrics of an aggregate plex record. The resync percent is only reported
+// while the plex resyncs, and is 100 otherwise
+func PlexValues(plex gjson.Result) map[string]float64 {
+	values := map[string]float64{
+		"online":         0,
+		"resync_percent": 100,
+		"resyncing":      0,
+	}
+	if plex.Get("online").Bool() {
+		values["online"] = 1
+	}
+	if plex.Get("resync.active").Bool() {
+		values["resyncing"] = 1
+		values["resync_percent"] = plex.Get("resync.percent").Float()
+	}
+	return values
+}
@@ -0,0 +1,71 @@
+package metrocluster
+
+import (
+	"github.com/tidwall/gjson"
+	"maps"
+	"testing"
+)
+
+func TestClusterValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   map[string]float64
+	}{
+		{
+			name:   "normal",
+			record: `{"local":{"mode":"normal","configuration_state":"configured"},"remote":{"mode":"normal"},"auso_failure_domain":"auso_on_cluster_disaster"}`,
+			want:   map[string]float64{"auso_enabled": 1, "configured": 1, "switchover": 0},
+		},
+		{
+			name:   "switchover",
+			record: `{"local":{"mode":"switchover","configuration_state":"configured"},"remote":{"mode":"waiting_for_switchback"},"auso_failure_domain":"auso_disabled"}`,
+			want:   map[string]float64{"auso_enabled": 0, "configured": 1, "switchover": 1},
+		},
+		{
+			name:   "partial",
+			record: `{"local":{"mode":"normal","configuration_state":"partially_configured"},"remote":{"mode":"partial_switchover"}}`,
+			want:   map[string]float64{"auso_enabled": 0, "configured": 0, "switchover": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClusterValues(gjson.Parse(tt.record))
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ClusterValues() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlexValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   map[string]float64
+	}{
+		{
+			name:   "normal",
+			record: `{"name":"plex0","online":true,"state":"normal","resync":{"active":false}}`,
+			want:   map[string]float64{"online": 1, "resync_percent": 100, "resyncing": 0},
+		},
+		{
+			name:   "resyncing",
+			record: `{"name":"plex4","online":true,"state":"resyncing","resync":{"active":true,"percent":42,"level":1}}`,
+			want:   map[string]float64{"online": 1, "resync_percent": 42, "resyncing": 1},
+		},
+		{
+			name:   "failed",
+			record: `{"name":"plex1","online":false,"state":"failed"}`,
+			want:   map[string]float64{"online": 0, "resync_percent": 100, "resyncing": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PlexValues(gjson.Parse(tt.record))
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("PlexValues() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
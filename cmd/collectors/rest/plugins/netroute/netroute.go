@@ -65,6 +65,16 @@ func (n *NetRoute) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *ut
 
 	n.data.SetGlobalLabels(data.GetGlobalLabels())
 
+	// number of LIFs that can use each route, a route without LIFs is unusable
+	interfaceCount := data.GetMetric("interfaces")
+	if interfaceCount == nil {
+		var err error
+		if interfaceCount, err = data.NewMetricFloat64("interfaces"); err != nil {
+			n.Logger.Error().Err(err).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+
 	count := 0
 	for key, instance := range data.GetInstances() {
 		cluster := data.GetGlobalLabels()["cluster"]
@@ -74,6 +84,7 @@ func (n *NetRoute) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *ut
 		interfacesList := gjson.Result{Type: gjson.JSON, Raw: interfaces}
 		names := interfacesList.Get("name").Array()
 		address := interfacesList.Get("address").Array()
+		_ = interfaceCount.SetValueFloat64(instance, float64(len(names)))
 
 		if len(names) == len(address) {
 			for i, name := range names {
@@ -0,0 +1,132 @@
+// Package smbc adds the recovery point objective (RPO) of SnapMirror Business Continuity (SM-BC) relationships
+// and whether each relationship is ready for an automatic failover. An automatic failover needs an in sync and
+// healthy relationship and a reachable mediator, so the mediators of the cluster are collected every poll
+package smbc
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"time"
+)
+
+var metrics = []string{"auto_failover_ready", "mediator_reachable", "rpo"}
+
+type SMBC struct {
+	*plugin.AbstractPlugin
+	client *rest.Client
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &SMBC{AbstractPlugin: p}
+}
+
+func (s *SMBC) Init() error {
+	var err error
+	if err := s.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if s.client, err = rest.New(conf.ZapiPoller(s.ParentParams), timeout, s.Auth); err != nil {
+		s.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return s.client.Init(5)
+}
+
+func (s *SMBC) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[s.Object]
+	s.client.Metadata.Reset()
+
+	if len(data.GetInstances()) == 0 {
+		return nil, s.client.Metadata, nil
+	}
+
+	for _, name := range metrics {
+		if data.GetMetric(name) == nil {
+			if _, err := data.NewMetricFloat64(name); err != nil {
+				s.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+				return nil, nil, err
+			}
+		}
+	}
+
+	reachable, err := s.mediatorReachable()
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to collect mediators")
+	}
+
+	lagTime := data.GetMetric("lag_time")
+	for _, relationship := range data.GetInstances() {
+		if !relationship.IsExportable() {
+			continue
+		}
+		state := relationship.GetLabel("state")
+
+		s.setValue(data, "mediator_reachable", relationship, boolToFloat(reachable))
+		ready := AutoFailoverReady(state, relationship.GetLabel("healthy"), reachable)
+		s.setValue(data, "auto_failover_ready", relationship, boolToFloat(ready))
+
+		data.GetMetric("rpo").SetValueNAN(relationship)
+		var lag float64
+		hasLag := false
+		if lagTime != nil {
+			lag, hasLag = lagTime.GetValueFloat64(relationship)
+		}
+		if rpo, ok := Rpo(state, lag, hasLag); ok {
+			s.setValue(data, "rpo", relationship, rpo)
+		}
+	}
+
+	return nil, s.client.Metadata, nil
+}
+
+func (s *SMBC) setValue(data *matrix.Matrix, name string, relationship *matrix.Instance, value float64) {
+	if err := data.GetMetric(name).SetValueFloat64(relationship, value); err != nil {
+		s.Logger.Error().Err(err).Str("metric", name).Msg("Unable to set value on metric")
+	}
+}
+
+// mediatorReachable returns true when a mediator of the cluster is reachable
+func (s *SMBC) mediatorReachable() (bool, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/cluster/mediators").
+		Fields([]string{"reachable"}).
+		Build()
+	mediators, err := collectors.InvokeRestCall(s.client, href, s.Logger)
+	if err != nil {
+		return false, err
+	}
+	for _, mediator := range mediators {
+		if mediator.Get("reachable").Bool() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AutoFailoverReady returns true when ONTAP can fail over a relationship without the loss of data:
+// the relationship is in sync and healthy and a mediator is reachable
+func AutoFailoverReady(state string, healthy string, mediatorReachable bool) bool {
+	return state == "in_sync" && healthy == "true" && mediatorReachable
+}
+
+// Rpo returns the recovery point objective of a relationship in seconds. An in sync relationship has no data to
+// lose, otherwise the RPO is the lag time. The second return value is false when the RPO is unknown
+func Rpo(state string, lagTime float64, hasLag bool) (float64, bool) {
+	if state == "in_sync" {
+		return 0, true
+	}
+	return lagTime, hasLag
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
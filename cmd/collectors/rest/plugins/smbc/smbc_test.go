@@ -0,0 +1,48 @@
+package smbc
+
+import "testing"
+
+func TestAutoFailoverReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		healthy   string
+		reachable bool
+		want      bool
+	}{
+		{name: "ready", state: "in_sync", healthy: "true", reachable: true, want: true},
+		{name: "out of sync", state: "out_of_sync", healthy: "true", reachable: true, want: false},
+		{name: "unhealthy", state: "in_sync", healthy: "false", reachable: true, want: false},
+		{name: "mediator unreachable", state: "in_sync", healthy: "true", reachable: false, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AutoFailoverReady(tt.state, tt.healthy, tt.reachable); got != tt.want {
+				t.Errorf("AutoFailoverReady() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRpo(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		lagTime float64
+		hasLag  bool
+		want    float64
+		wantOk  bool
+	}{
+		{name: "in sync", state: "in_sync", lagTime: 120, hasLag: true, want: 0, wantOk: true},
+		{name: "out of sync", state: "out_of_sync", lagTime: 120, hasLag: true, want: 120, wantOk: true},
+		{name: "unknown", state: "out_of_sync", want: 0, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Rpo(tt.state, tt.lagTime, tt.hasLag)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Rpo() got=%v %v, want=%v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package snapshotcompliance
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a job schedule of ONTAP, either cron or interval. Empty cron fields match any value
+type schedule struct {
+	name     string
+	interval time.Duration // interval schedules, 0 for cron schedules
+	minutes  []int
+	hours    []int
+	days     []int // days of the month, 1-31
+	weekdays []int // 0 is Sunday
+	months   []int // 1-12
+}
+
+// policyCopy is a schedule of a snapshot policy, and the number of its snapshots the policy keeps
+type policyCopy struct {
+	schedule string
+	prefix   string // prefix of the names of its snapshots, the name of the schedule by default
+	count    int
+}
+
+type snapshot struct {
+	name    string
+	created time.Time
+}
+
+type options struct {
+	grace   time.Duration // how long after a run its snapshot may be created
+	maxRuns int           // the number of recent runs of a schedule that are checked
+}
+
+// compliance is how the snapshots of a volume comply with the schedules of its snapshot policy
+type compliance struct {
+	missed          int           // runs of the schedules without a snapshot
+	missedSchedules []string      // schedules with missed runs
+	newestAge       time.Duration // age of the newest snapshot created by the schedules
+	hasNewest       bool          // false when the schedules created no snapshot
+	expectedAge     time.Duration // time since the schedules last ran, the newest snapshot is expected to be this old
+	checked         bool          // false when the policy has no schedule that keeps snapshots
+}
+
+// compliant returns true when the schedules missed no run
+func (c compliance) compliant() bool {
+	return c.checked && c.missed == 0
+}
+
+// check returns the compliance of the snapshots of a volume with the copies of its policy at now.
+// Runs before the oldest snapshot of the volume are not checked, e.g. runs before the volume was created
+func check(copies []policyCopy, schedules map[string]schedule, snapshots []snapshot, now time.Time, o options) compliance {
+	var c compliance
+	var oldest time.Time
+	for _, s := range snapshots {
+		if oldest.IsZero() || s.created.Before(oldest) {
+			oldest = s.created
+		}
+	}
+
+	for _, cp := range copies {
+		sched, ok := schedules[cp.schedule]
+		runs := min(cp.count, o.maxRuns)
+		if !ok || runs <= 0 {
+			continue
+		}
+		prefix := cmp.Or(cp.prefix, cp.schedule) + "."
+		var created []time.Time // newest first
+		for _, s := range snapshots {
+			if strings.HasPrefix(s.name, prefix) {
+				created = append(created, s.created)
+			}
+		}
+		slices.SortFunc(created, func(a, b time.Time) int { return b.Compare(a) })
+
+		var missed int
+		var expected time.Duration
+		if sched.interval > 0 {
+			missed = intervalMissed(sched.interval, runs, created, now, o.grace)
+			expected = sched.interval
+		} else {
+			fires := sched.fires(now.Add(-o.grace), runs)
+			if len(fires) == 0 {
+				continue
+			}
+			missed = cronMissed(fires, created, oldest, o.grace)
+			expected = now.Sub(fires[0])
+		}
+
+		if !c.checked || expected < c.expectedAge {
+			c.expectedAge = expected
+		}
+		c.checked = true
+		if len(created) > 0 && (!c.hasNewest || now.Sub(created[0]) < c.newestAge) {
+			c.newestAge = now.Sub(created[0])
+			c.hasNewest = true
+		}
+		if missed > 0 {
+			c.missed += missed
+			c.missedSchedules = append(c.missedSchedules, cp.schedule)
+		}
+	}
+	slices.Sort(c.missedSchedules)
+	return c
+}
+
+// cronMissed returns the number of fires without a snapshot created between a minute before the fire, allowing for
+// clock skew, and grace after it. Fires before oldest, the oldest snapshot of the volume, are not counted
+func cronMissed(fires []time.Time, created []time.Time, oldest time.Time, grace time.Duration) int {
+	missed := 0
+	for _, f := range fires {
+		if !oldest.IsZero() && f.Before(oldest.Add(-time.Minute)) {
+			break
+		}
+		found := slices.ContainsFunc(created, func(t time.Time) bool {
+			return !t.Before(f.Add(-time.Minute)) && !t.After(f.Add(grace))
+		})
+		if !found {
+			missed++
+		}
+	}
+	return missed
+}
+
+// intervalMissed returns the number of runs of an interval schedule missed in the last runs intervals. Interval
+// schedules have no fixed times, so a run is missed for each interval between snapshots, or since the newest
+// snapshot, beyond grace
+func intervalMissed(interval time.Duration, runs int, created []time.Time, now time.Time, grace time.Duration) int {
+	if len(created) == 0 {
+		return runs
+	}
+	start := now.Add(-interval * time.Duration(runs))
+	missed := 0
+	prev := now
+	for _, t := range created {
+		if gap := prev.Sub(t); gap > grace {
+			missed += int((gap - grace) / interval)
+		}
+		if t.Before(start) {
+			break
+		}
+		prev = t
+	}
+	return min(missed, runs)
+}
+
+// fires returns the last n times a cron schedule ran at or before t, newest first, looking back at most a year
+func (s schedule) fires(t time.Time, n int) []time.Time {
+	var result []time.Time
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 366; i++ {
+		d := day.AddDate(0, 0, -i)
+		if !s.onDay(d) {
+			continue
+		}
+		for _, h := range descending(s.hours, 23) {
+			for _, m := range descending(s.minutes, 59) {
+				f := time.Date(d.Year(), d.Month(), d.Day(), h, m, 0, 0, t.Location())
+				if f.After(t) {
+					continue
+				}
+				result = append(result, f)
+				if len(result) == n {
+					return result
+				}
+			}
+		}
+	}
+	return result
+}
+
+// onDay returns true when the schedule runs on the day of d. As in cron, when both the days of the month and the
+// weekdays are set, the schedule runs on the days that match either
+func (s schedule) onDay(d time.Time) bool {
+	if len(s.months) > 0 && !slices.Contains(s.months, int(d.Month())) {
+		return false
+	}
+	day := slices.Contains(s.days, d.Day())
+	weekday := slices.Contains(s.weekdays, int(d.Weekday()))
+	switch {
+	case len(s.days) > 0 && len(s.weekdays) > 0:
+		return day || weekday
+	case len(s.days) > 0:
+		return day
+	case len(s.weekdays) > 0:
+		return weekday
+	}
+	return true
+}
+
+// descending returns the values in descending order, or all the values from highest to 0 when values is empty
+func descending(values []int, highest int) []int {
+	if len(values) == 0 {
+		all := make([]int, 0, highest+1)
+		for v := highest; v >= 0; v-- {
+			all = append(all, v)
+		}
+		return all
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	slices.Reverse(sorted)
+	return sorted
+}
+
+var isoDurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseInterval returns the duration of the interval of a schedule, an ISO-8601 duration such as PT15M or P1DT2H.
+// It returns 0 when the interval can not be parsed
+func parseInterval(value string) time.Duration {
+	match := isoDurationRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0
+		}
+		d += time.Duration(n) * unit
+	}
+	return d
+}
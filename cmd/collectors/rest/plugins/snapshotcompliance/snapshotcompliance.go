@@ -0,0 +1,236 @@
+// Package snapshotcompliance compares the snapshots of each volume with the schedules of its snapshot policy,
+// and exports the runs of the schedules that created no snapshot, and the age of the newest snapshot
+package snapshotcompliance
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGrace   = 15 * time.Minute
+	defaultMaxRuns = 10
+)
+
+var metrics = []string{"compliant", "expected_age", "missed", "newest_age"}
+
+type SnapshotCompliance struct {
+	*plugin.AbstractPlugin
+	client  *rest.Client
+	options options
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &SnapshotCompliance{AbstractPlugin: p}
+}
+
+func (s *SnapshotCompliance) Init() error {
+	var err error
+	if err := s.InitAbc(); err != nil {
+		return err
+	}
+	if s.options, err = readOptions(s.Params.GetChildContentS("grace"), s.Params.GetChildContentS("max_runs")); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if s.client, err = rest.New(conf.ZapiPoller(s.ParentParams), timeout, s.Auth); err != nil {
+		s.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return s.client.Init(5)
+}
+
+// readOptions returns the options of the grace and max_runs parameters of the plugin
+func readOptions(grace string, maxRuns string) (options, error) {
+	o := options{grace: defaultGrace, maxRuns: defaultMaxRuns}
+	if grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil || d < 0 {
+			return o, errs.New(errs.ErrInvalidParam, "grace: "+grace)
+		}
+		o.grace = d
+	}
+	if maxRuns != "" {
+		n, err := strconv.Atoi(maxRuns)
+		if err != nil || n <= 0 {
+			return o, errs.New(errs.ErrInvalidParam, "max_runs: "+maxRuns)
+		}
+		o.maxRuns = n
+	}
+	return o, nil
+}
+
+func (s *SnapshotCompliance) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[s.Object]
+	s.client.Metadata.Reset()
+
+	for _, name := range metrics {
+		if data.GetMetric(name) == nil {
+			if _, err := data.NewMetricFloat64(name); err != nil {
+				s.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+				return nil, nil, err
+			}
+		}
+	}
+
+	// schedules run in the time zone of the cluster
+	now, err := collectors.GetClusterTime(s.client, nil, s.Logger)
+	if err != nil || now.IsZero() {
+		s.Logger.Warn().Err(err).Msg("Failed to collect cluster time, using the time of the poller")
+		now = time.Now()
+	}
+	schedules, err := s.getSchedules()
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to collect schedules")
+		return nil, s.client.Metadata, nil
+	}
+	policies, err := s.getPolicies()
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to collect snapshot policies")
+		return nil, s.client.Metadata, nil
+	}
+	snapshots, err := s.getSnapshots(now.Location())
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to collect snapshots")
+		return nil, s.client.Metadata, nil
+	}
+
+	for _, volume := range data.GetInstances() {
+		if !volume.IsExportable() {
+			continue
+		}
+		for _, name := range metrics {
+			data.GetMetric(name).SetValueNAN(volume)
+		}
+		volume.SetLabel("missed_schedules", "")
+		svm := volume.GetLabel("svm")
+		// policies of the cluster apply to all SVMs
+		copies, ok := policies[svm+"."+volume.GetLabel("snapshot_policy")]
+		if !ok {
+			copies, ok = policies["."+volume.GetLabel("snapshot_policy")]
+		}
+		if !ok {
+			continue
+		}
+		c := check(copies, schedules, snapshots[svm+"."+volume.GetLabel("volume")], now, s.options)
+		if !c.checked {
+			continue
+		}
+		compliant := 0.0
+		if c.compliant() {
+			compliant = 1
+		}
+		s.setValue(data, "compliant", volume, compliant)
+		s.setValue(data, "missed", volume, float64(c.missed))
+		s.setValue(data, "expected_age", volume, c.expectedAge.Seconds())
+		if c.hasNewest {
+			s.setValue(data, "newest_age", volume, c.newestAge.Seconds())
+		}
+		volume.SetLabel("missed_schedules", strings.Join(c.missedSchedules, ","))
+	}
+
+	return nil, s.client.Metadata, nil
+}
+
+func (s *SnapshotCompliance) setValue(data *matrix.Matrix, name string, volume *matrix.Instance, value float64) {
+	if err := data.GetMetric(name).SetValueFloat64(volume, value); err != nil {
+		s.Logger.Error().Err(err).Str("metric", name).Msg("Unable to set value on metric")
+	}
+}
+
+// getSchedules returns the job schedules of the cluster, by name
+func (s *SnapshotCompliance) getSchedules() (map[string]schedule, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/cluster/schedules").
+		Fields([]string{"name", "type", "cron", "interval"}).
+		Build()
+	records, err := collectors.InvokeRestCall(s.client, href, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make(map[string]schedule, len(records))
+	for _, r := range records {
+		sched := parseSchedule(r)
+		schedules[sched.name] = sched
+	}
+	return schedules, nil
+}
+
+func parseSchedule(r gjson.Result) schedule {
+	sched := schedule{name: r.Get("name").String()}
+	if r.Get("type").String() == "interval" {
+		sched.interval = parseInterval(r.Get("interval").String())
+		return sched
+	}
+	ints := func(path string) []int {
+		var values []int
+		for _, v := range r.Get(path).Array() {
+			values = append(values, int(v.Int()))
+		}
+		return values
+	}
+	sched.minutes = ints("cron.minutes")
+	sched.hours = ints("cron.hours")
+	sched.days = ints("cron.days")
+	sched.weekdays = ints("cron.weekdays")
+	sched.months = ints("cron.months")
+	return sched
+}
+
+// getPolicies returns the copies of the snapshot policies, by SVM and name. Policies of the cluster have no SVM
+func (s *SnapshotCompliance) getPolicies() (map[string][]policyCopy, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/snapshot-policies").
+		Fields([]string{"name", "svm.name", "copies"}).
+		Build()
+	records, err := collectors.InvokeRestCall(s.client, href, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string][]policyCopy, len(records))
+	for _, r := range records {
+		var copies []policyCopy
+		for _, c := range r.Get("copies").Array() {
+			copies = append(copies, policyCopy{
+				schedule: c.Get("schedule.name").String(),
+				prefix:   c.Get("prefix").String(),
+				count:    int(c.Get("count").Int()),
+			})
+		}
+		policies[r.Get("svm.name").String()+"."+r.Get("name").String()] = copies
+	}
+	return policies, nil
+}
+
+// getSnapshots returns the snapshots of the volumes, by SVM and volume, with their create time in loc
+func (s *SnapshotCompliance) getSnapshots(loc *time.Location) (map[string][]snapshot, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/private/cli/volume/snapshot").
+		Fields([]string{"vserver", "volume", "snapshot", "create_time"}).
+		Build()
+	records, err := collectors.InvokeRestCall(s.client, href, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string][]snapshot)
+	for _, r := range records {
+		created, err := time.Parse(time.RFC3339, r.Get("create_time").String())
+		if err != nil {
+			s.Logger.Debug().Err(err).Str("snapshot", r.Get("snapshot").String()).Msg("Invalid create_time")
+			continue
+		}
+		key := r.Get("vserver").String() + "." + r.Get("volume").String()
+		snapshots[key] = append(snapshots[key], snapshot{name: r.Get("snapshot").String(), created: created.In(loc)})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,181 @@
+package snapshotcompliance
+
+import (
+	"github.com/tidwall/gjson"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testSchedules = map[string]schedule{
+	"hourly": {name: "hourly", minutes: []int{5}},
+	"daily":  {name: "daily", minutes: []int{10}, hours: []int{0}},
+	"weekly": {name: "weekly", minutes: []int{15}, hours: []int{0}, weekdays: []int{0}},
+	"5min":   {name: "5min", interval: 5 * time.Minute},
+}
+
+func at(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// hourlies returns the snapshots of the hourly schedule of the last n hours before 2024-03-06T12:30, skipping the hours
+// of skip
+func hourlies(n int, skip ...int) []snapshot {
+	var snapshots []snapshot
+	for h := 12; h > 12-n; h-- {
+		if slices.Contains(skip, h) {
+			continue
+		}
+		created := time.Date(2024, 3, 6, h, 5, 3, 0, time.UTC)
+		snapshots = append(snapshots, snapshot{name: "hourly." + created.Format("2006-01-02_1504"), created: created})
+	}
+	return snapshots
+}
+
+func TestCheck(t *testing.T) {
+	o := options{grace: 15 * time.Minute, maxRuns: 10}
+	now := at("2024-03-06T12:30:00Z")
+	hourly := []policyCopy{{schedule: "hourly", count: 6}}
+
+	tests := []struct {
+		name          string
+		copies        []policyCopy
+		snapshots     []snapshot
+		now           time.Time
+		wantMissed    int
+		wantSchedules []string
+		wantNewest    time.Duration
+		wantExpected  time.Duration
+		wantCompliant bool
+	}{
+		{name: "compliant", copies: hourly, snapshots: hourlies(8), now: now,
+			wantNewest: 25*time.Minute - 3*time.Second, wantExpected: 25 * time.Minute, wantCompliant: true},
+		{name: "missed runs", copies: hourly, snapshots: hourlies(8, 10, 11), now: now,
+			wantMissed: 2, wantSchedules: []string{"hourly"}, wantNewest: 25*time.Minute - 3*time.Second, wantExpected: 25 * time.Minute},
+		{name: "late", copies: hourly, snapshots: hourlies(8, 12), now: now,
+			wantMissed: 1, wantSchedules: []string{"hourly"}, wantNewest: 85*time.Minute - 3*time.Second, wantExpected: 25 * time.Minute},
+		// the run of 12:05 may still create its snapshot
+		{name: "within grace", copies: hourly, snapshots: hourlies(8, 12), now: at("2024-03-06T12:15:00Z"),
+			wantNewest: 70*time.Minute - 3*time.Second, wantExpected: 70 * time.Minute, wantCompliant: true},
+		// runs before the oldest snapshot of the volume are not checked
+		{name: "new volume", copies: hourly, snapshots: hourlies(2), now: now,
+			wantNewest: 25*time.Minute - 3*time.Second, wantExpected: 25 * time.Minute, wantCompliant: true},
+		{name: "no snapshots", copies: hourly, now: now,
+			wantMissed: 6, wantSchedules: []string{"hourly"}, wantExpected: 25 * time.Minute},
+		{name: "prefix", now: now, copies: []policyCopy{{schedule: "hourly", prefix: "backup", count: 2}},
+			snapshots:  []snapshot{{name: "backup.1", created: at("2024-03-06T12:05:00Z")}, {name: "backup.2", created: at("2024-03-06T11:05:00Z")}},
+			wantNewest: 25 * time.Minute, wantExpected: 25 * time.Minute, wantCompliant: true},
+		{name: "several schedules", copies: []policyCopy{{schedule: "hourly", count: 2}, {schedule: "daily", count: 2}},
+			snapshots: hourlies(14), now: now,
+			wantMissed: 1, wantSchedules: []string{"daily"}, wantNewest: 25*time.Minute - 3*time.Second, wantExpected: 25 * time.Minute},
+		{name: "weekly", now: now, copies: []policyCopy{{schedule: "weekly", count: 2}},
+			snapshots:  []snapshot{{name: "weekly.1", created: at("2024-03-03T00:15:00Z")}, {name: "weekly.2", created: at("2024-02-25T00:15:00Z")}},
+			wantNewest: 84*time.Hour + 15*time.Minute, wantExpected: 84*time.Hour + 15*time.Minute, wantCompliant: true},
+		{name: "interval", now: now, copies: []policyCopy{{schedule: "5min", count: 3}},
+			snapshots: []snapshot{{name: "5min.1", created: at("2024-03-06T12:28:00Z")}, {name: "5min.2", created: at("2024-03-06T12:23:00Z")},
+				{name: "5min.3", created: at("2024-03-06T12:18:00Z")}},
+			wantNewest: 2 * time.Minute, wantExpected: 5 * time.Minute, wantCompliant: true},
+		{name: "interval stopped", now: now, copies: []policyCopy{{schedule: "5min", count: 3}},
+			snapshots:  []snapshot{{name: "5min.1", created: at("2024-03-06T11:00:00Z")}},
+			wantMissed: 3, wantSchedules: []string{"5min"}, wantNewest: 90 * time.Minute, wantExpected: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := check(tt.copies, testSchedules, tt.snapshots, tt.now, o)
+			if c.missed != tt.wantMissed {
+				t.Errorf("missed got=%d, want=%d", c.missed, tt.wantMissed)
+			}
+			if !slices.Equal(c.missedSchedules, tt.wantSchedules) {
+				t.Errorf("missedSchedules got=%v, want=%v", c.missedSchedules, tt.wantSchedules)
+			}
+			if tt.wantNewest != 0 && (!c.hasNewest || c.newestAge != tt.wantNewest) {
+				t.Errorf("newestAge got=%s, want=%s", c.newestAge, tt.wantNewest)
+			}
+			if c.expectedAge != tt.wantExpected {
+				t.Errorf("expectedAge got=%s, want=%s", c.expectedAge, tt.wantExpected)
+			}
+			if c.compliant() != tt.wantCompliant {
+				t.Errorf("compliant got=%t, want=%t", c.compliant(), tt.wantCompliant)
+			}
+		})
+	}
+
+	// policies without schedules that keep snapshots are not checked
+	if c := check([]policyCopy{{schedule: "hourly", count: 0}, {schedule: "unknown", count: 2}}, testSchedules, nil, now, o); c.checked {
+		t.Errorf("check() of a policy without schedules should not be checked")
+	}
+}
+
+func TestFires(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule schedule
+		want     []string
+	}{
+		{name: "hourly", schedule: testSchedules["hourly"], want: []string{"2024-03-06T12:05:00Z", "2024-03-06T11:05:00Z"}},
+		{name: "weekly", schedule: testSchedules["weekly"], want: []string{"2024-03-03T00:15:00Z", "2024-02-25T00:15:00Z"}},
+		{name: "monthly", schedule: schedule{minutes: []int{0}, hours: []int{1}, days: []int{1}},
+			want: []string{"2024-03-01T01:00:00Z", "2024-02-01T01:00:00Z"}},
+		// days of the month or weekdays, as in cron
+		{name: "days or weekdays", schedule: schedule{minutes: []int{0}, hours: []int{1}, days: []int{5}, weekdays: []int{1}},
+			want: []string{"2024-03-05T01:00:00Z", "2024-03-04T01:00:00Z"}},
+		// fires look back a year at most
+		{name: "months", schedule: schedule{minutes: []int{0}, hours: []int{0}, days: []int{1}, months: []int{1}},
+			want: []string{"2024-01-01T00:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range tt.schedule.fires(at("2024-03-06T12:30:00Z"), 2) {
+				got = append(got, f.Format(time.RFC3339))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fires() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	cron := parseSchedule(gjson.Parse(`{"name":"daily","type":"cron","cron":{"minutes":[10],"hours":[0]}}`))
+	if cron.interval != 0 || !slices.Equal(cron.minutes, []int{10}) || !slices.Equal(cron.hours, []int{0}) || cron.days != nil {
+		t.Errorf("parseSchedule() of a cron schedule got=%+v", cron)
+	}
+	interval := parseSchedule(gjson.Parse(`{"name":"5min","type":"interval","interval":"PT5M"}`))
+	if interval.interval != 5*time.Minute {
+		t.Errorf("parseSchedule() of an interval schedule got=%s, want=5m", interval.interval)
+	}
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "PT15M", want: 15 * time.Minute},
+		{value: "P1DT2H", want: 26 * time.Hour},
+		{value: "P7D", want: 7 * 24 * time.Hour},
+		{value: "PT30S", want: 30 * time.Second},
+		{value: "15m", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseInterval(tt.value); got != tt.want {
+			t.Errorf("parseInterval(%s) got=%s, want=%s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestReadOptions(t *testing.T) {
+	o, err := readOptions("", "")
+	if err != nil || o.grace != defaultGrace || o.maxRuns != defaultMaxRuns {
+		t.Errorf("readOptions() defaults got=%+v err=%v", o, err)
+	}
+	o, err = readOptions("5m", "3")
+	if err != nil || o.grace != 5*time.Minute || o.maxRuns != 3 {
+		t.Errorf("readOptions() got=%+v err=%v", o, err)
+	}
+	for _, tt := range [][2]string{{"5", ""}, {"-1m", ""}, {"", "0"}, {"", "ten"}} {
+		if _, err := readOptions(tt[0], tt[1]); err == nil {
+			t.Errorf("readOptions(%q, %q) want error", tt[0], tt[1])
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/util"
 	goversion "github.com/netapp/harvest/v2/third_party/go-version"
 	"github.com/tidwall/gjson"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
@@ -17,13 +18,27 @@ import (
 
 const explorer = "volume_analytics"
 
+const (
+	// defaultMaxPathLength is the length of the longest directory path exported as the dir_name label
+	defaultMaxPathLength = 256
+)
+
 var MaxDirCollectCount = 100
 
 type VolumeAnalytics struct {
 	*plugin.AbstractPlugin
-	currentVal int
-	client     *rest.Client
-	data       map[string]*matrix.Matrix
+	currentVal    int
+	client        *rest.Client
+	data          map[string]*matrix.Matrix
+	maxDepth      int  // depth of the deepest directories collected, 1 collects the directories of the volume root
+	maxPathLength int  // directories with longer paths are not collected, to bound the cardinality of dir_name
+	activity      bool // true to collect the top directories by activity of volumes with activity tracking on
+}
+
+// directory is a directory of a volume and its analytics record. path is relative to the volume root
+type directory struct {
+	path   string
+	record gjson.Result
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -36,6 +51,17 @@ var metrics = []string{
 	"dir_subdir_count",
 }
 
+// topMetrics maps the top_metric parameter of the top-metrics/directories API to the metric it is exported as
+var topMetrics = []struct {
+	topMetric string
+	metric    string
+}{
+	{topMetric: "iops.read", metric: "dir_read_ops"},
+	{topMetric: "iops.write", metric: "dir_write_ops"},
+	{topMetric: "throughput.read", metric: "dir_read_data"},
+	{topMetric: "throughput.write", metric: "dir_write_data"},
+}
+
 func (v *VolumeAnalytics) Init() error {
 
 	var err error
@@ -59,6 +85,24 @@ func (v *VolumeAnalytics) Init() error {
 		}
 	}
 
+	v.maxDepth = 1
+	if s := v.Params.GetChildContentS("MaxDepth"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return errs.New(errs.ErrInvalidParam, "MaxDepth ("+s+") must be a positive integer")
+		}
+		v.maxDepth = i
+	}
+	v.maxPathLength = defaultMaxPathLength
+	if s := v.Params.GetChildContentS("MaxPathLength"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return errs.New(errs.ErrInvalidParam, "MaxPathLength ("+s+") must be a positive integer")
+		}
+		v.maxPathLength = i
+	}
+	v.activity = v.Params.GetChildContentS("ActivityTracking") == "true"
+
 	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
 	if v.client, err = rest.New(conf.ZapiPoller(v.ParentParams), timeout, v.Auth); err != nil {
 		v.Logger.Error().Stack().Err(err).Msg("connecting")
@@ -89,6 +133,14 @@ func (v *VolumeAnalytics) initMatrix() error {
 			return err
 		}
 	}
+	if v.activity {
+		for _, t := range topMetrics {
+			if err := matrix.CreateMetric(t.metric, v.data[explorer]); err != nil {
+				v.Logger.Warn().Err(err).Str("key", t.metric).Msg("error while creating metric")
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -117,6 +169,11 @@ func (v *VolumeAnalytics) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matr
 	if ontapVersion.LessThan(version98After) {
 		return nil, nil, nil
 	}
+	// the top-metrics APIs of activity tracking were added in 9.10
+	version910, err := goversion.NewVersion("9.10")
+	if err != nil {
+		return nil, nil, nil
+	}
 
 	// Purge and reset data
 	// remove all metrics as analytics label may change over time
@@ -131,7 +188,7 @@ func (v *VolumeAnalytics) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matr
 	}
 
 	for instanceID, dataInstance := range data.GetInstances() {
-		if records, analytics, err := v.getAnalyticsData(instanceID); err != nil {
+		if records, analytics, err := v.getDirectories(instanceID); err != nil {
 			if errs.IsRestErr(err, errs.APINotFound) {
 				v.Logger.Debug().Err(err).Msg("API not found")
 			} else {
@@ -139,8 +196,9 @@ func (v *VolumeAnalytics) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matr
 			}
 		} else {
 			explorerMatrix := v.data[explorer]
-			for index, record := range records {
-				name := record.Get("name").String()
+			for index, dir := range records {
+				record := dir.record
+				name := dir.path
 				fileCount := record.Get("analytics.file_count").String()
 				bytesUsed := record.Get("analytics.bytes_used").String()
 				subDirCount := record.Get("analytics.subdir_count").String()
@@ -271,6 +329,15 @@ func (v *VolumeAnalytics) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matr
 		}
 	}
 
+	if v.activity && !ontapVersion.LessThan(version910) {
+		for instanceID, dataInstance := range data.GetInstances() {
+			if dataInstance.GetLabel("activity_tracking") != "on" {
+				continue
+			}
+			v.collectActivity(instanceID, dataInstance)
+		}
+	}
+
 	result := make([]*matrix.Matrix, 0, len(v.data))
 
 	for _, value := range v.data {
@@ -293,7 +360,134 @@ func (v *VolumeAnalytics) getLabelBucket(label string) string {
 	return "Yearly"
 }
 
-func (v *VolumeAnalytics) getAnalyticsData(instanceID string) ([]gjson.Result, gjson.Result, error) {
+// getDirectories returns the largest directories of the volume, down to MaxDepth, and the analytics of the volume.
+// The directories of each level are listed from the largest directories of the level above, until MaxDirectoryCount
+// directories are collected, so the largest directories near the root are collected first
+func (v *VolumeAnalytics) getDirectories(instanceID string) ([]directory, gjson.Result, error) {
+	var (
+		dirs      []directory
+		analytics gjson.Result
+	)
+	parents := []string{""}
+	budget := MaxDirCollectCount
+	for depth := 1; depth <= v.maxDepth && budget > 0 && len(parents) > 0; depth++ {
+		var next []string
+		for _, parent := range parents {
+			if budget <= 0 {
+				break
+			}
+			records, a, err := v.getAnalyticsData(instanceID, parent, budget)
+			if err != nil {
+				if depth == 1 {
+					return nil, gjson.Result{}, err
+				}
+				// the directory may have been removed since its parent was listed
+				v.Logger.Debug().Err(err).Str("dir", parent).Msg("Failed to collect analytic data of directory")
+				continue
+			}
+			if depth == 1 {
+				analytics = a
+			}
+			for _, record := range records {
+				name := record.Get("name").String()
+				if depth > 1 && (name == "." || name == "..") {
+					continue
+				}
+				p := name
+				if parent != "" {
+					p = parent + "/" + name
+				}
+				if len(p) > v.maxPathLength {
+					v.Logger.Debug().Str("dir", p).Int("maxPathLength", v.maxPathLength).Msg("Skip directory with long path")
+					continue
+				}
+				dirs = append(dirs, directory{path: p, record: record})
+				if name != "." && name != ".." {
+					next = append(next, p)
+				}
+				budget--
+				if budget <= 0 {
+					break
+				}
+			}
+		}
+		parents = next
+	}
+	return dirs, analytics, nil
+}
+
+// collectActivity exports the top directories by IOPS and throughput of a volume with activity tracking on.
+// Directories deeper than MaxDepth are rolled up into their ancestor at MaxDepth, and no more than
+// MaxDirectoryCount directories are exported per volume
+func (v *VolumeAnalytics) collectActivity(instanceID string, dataInstance *matrix.Instance) {
+	explorerMatrix := v.data[explorer]
+	count := 0
+	for key := range explorerMatrix.GetInstances() {
+		if strings.HasPrefix(key, instanceID) {
+			count++
+		}
+	}
+	for _, t := range topMetrics {
+		href := rest.NewHrefBuilder().
+			APIPath(path.Join("api/storage/volumes", instanceID, "top-metrics/directories")).
+			Fields([]string{"path", t.topMetric}).
+			Filter([]string{"top_metric=" + t.topMetric}).
+			MaxRecords(&MaxDirCollectCount).
+			Build()
+		records, err := rest.Fetch(v.client, href)
+		if err != nil {
+			if errs.IsRestErr(err, errs.APINotFound) {
+				v.Logger.Debug().Err(err).Msg("API not found")
+			} else {
+				v.Logger.Error().Err(err).Str("metric", t.topMetric).Msg("Failed to collect top directories")
+			}
+			continue
+		}
+		values := make(map[string]float64)
+		for _, record := range records {
+			value := record.Get(t.topMetric)
+			if !value.Exists() {
+				continue
+			}
+			values[RollUp(record.Get("path").String(), v.maxDepth)] += value.Float()
+		}
+		m := explorerMatrix.GetMetric(t.metric)
+		for p, value := range values {
+			if len(p) > v.maxPathLength {
+				continue
+			}
+			instance := explorerMatrix.GetInstance(instanceID + p)
+			if instance == nil {
+				if count >= MaxDirCollectCount {
+					continue
+				}
+				if instance, err = explorerMatrix.NewInstance(instanceID + p); err != nil {
+					continue
+				}
+				count++
+				for k1, v1 := range dataInstance.GetLabels() {
+					instance.SetLabel(k1, v1)
+				}
+				instance.SetLabel("dir_name", p)
+			}
+			m.SetValueFloat64(instance, value)
+		}
+	}
+}
+
+// RollUp returns the path of the ancestor of the directory dir at depth, relative to the volume root.
+// dir is the absolute path of a directory, e.g. /dir1/dir2. The volume root is "."
+func RollUp(dir string, depth int) string {
+	parts := strings.FieldsFunc(dir, func(r rune) bool { return r == '/' })
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts[:min(depth, len(parts))], "/")
+}
+
+// getAnalyticsData returns the largest subdirectories of dir, relative to the volume root, and the analytics of
+// the volume. The volume root is ""
+func (v *VolumeAnalytics) getAnalyticsData(instanceID string, dir string, maxRecords int) ([]gjson.Result, gjson.Result, error) {
 	var (
 		result    []gjson.Result
 		analytics gjson.Result
@@ -302,12 +496,15 @@ func (v *VolumeAnalytics) getAnalyticsData(instanceID string) ([]gjson.Result, g
 
 	fields := []string{"analytics.file_count", "analytics.bytes_used", "analytics.subdir_count", "analytics.by_modified_time.bytes_used", "analytics.by_accessed_time.bytes_used"}
 	query := path.Join("api/storage/volumes", instanceID, "files/")
+	if dir != "" {
+		query += "/" + url.PathEscape(dir)
+	}
 
 	href := rest.NewHrefBuilder().
 		APIPath(query).
 		Fields(fields).
 		Filter([]string{"order_by=analytics.bytes_used+desc", "type=directory"}).
-		MaxRecords(&MaxDirCollectCount).
+		MaxRecords(&maxRecords).
 		Build()
 	if result, analytics, err = rest.FetchAnalytics(v.client, href); err != nil {
 		return nil, gjson.Result{}, err
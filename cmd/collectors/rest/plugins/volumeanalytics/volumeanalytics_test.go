@@ -0,0 +1,112 @@
+package volumeanalytics
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/testutil"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"testing"
+)
+
+func TestRollUp(t *testing.T) {
+	tests := []struct {
+		dir   string
+		depth int
+		want  string
+	}{
+		{dir: "/", depth: 1, want: "."},
+		{dir: "/dir1", depth: 1, want: "dir1"},
+		{dir: "/dir1/dir2/dir3", depth: 1, want: "dir1"},
+		{dir: "/dir1/dir2/dir3", depth: 2, want: "dir1/dir2"},
+		{dir: "/dir1/dir2", depth: 5, want: "dir1/dir2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			if got := RollUp(tt.dir, tt.depth); got != tt.want {
+				t.Errorf("RollUp() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeAnalytics_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth string
+		maxCount string
+		want     []string // dir_name of the exported directories
+		activity string   // directory whose read ops are checked
+		readOps  float64  // read ops of activity, 0 when it is not exported
+	}{
+		{name: "root", maxDepth: "1", maxCount: "100", want: []string{".", "..", "dir1", "dir2", "dir3"}, activity: "dir1", readOps: 15},
+		{name: "depth", maxDepth: "2", maxCount: "100", want: []string{".", "..", "dir1", "dir1/sub1", "dir2", "dir2/sub2", "dir3/x"}, activity: "dir1/sub1", readOps: 15},
+		{name: "count", maxDepth: "2", maxCount: "4", want: []string{".", "..", "dir1", "dir2"}, activity: "dir1/sub1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newVolumeAnalytics(t, tt.maxDepth, tt.maxCount)
+
+			data := matrix.New("Rest", "volume", "volume")
+			data.SetGlobalLabel("cluster", "fake")
+			volume, _ := data.NewInstance("u1")
+			volume.SetLabel("volume", "vol1")
+			volume.SetLabel("activity_tracking", "on")
+
+			result, _, err := v.Run(map[string]*matrix.Matrix{"volume": data})
+			if err != nil {
+				t.Fatalf("Run() err=%v", err)
+			}
+			explorerMatrix := result[0]
+			var got []string
+			for _, instance := range explorerMatrix.GetInstances() {
+				got = append(got, instance.GetLabel("dir_name"))
+				if instance.GetLabel("volume") != "vol1" {
+					t.Errorf("dir %s has no volume label", instance.GetLabel("dir_name"))
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("dirs got=%v, want=%v", got, tt.want)
+			}
+
+			// the activity of deeper directories is rolled up into their ancestor at MaxDepth
+			instance := explorerMatrix.GetInstance("u1" + tt.activity)
+			readOps, ok := 0.0, false
+			if instance != nil {
+				readOps, ok = explorerMatrix.GetMetric("dir_read_ops").GetValueFloat64(instance)
+			}
+			if ok != (tt.readOps != 0) || readOps != tt.readOps {
+				t.Errorf("dir_read_ops of %s got=%f,%t want=%f", tt.activity, readOps, ok, tt.readOps)
+			}
+		})
+	}
+}
+
+func newVolumeAnalytics(t *testing.T, maxDepth string, maxCount string) *VolumeAnalytics {
+	t.Helper()
+	_, poller := testutil.StartONTAP(t, "testdata")
+
+	parentParams := node.NewS("parent")
+	parentParams.NewChildS("addr", poller.Addr)
+	parentParams.NewChildS("username", poller.Username)
+	parentParams.NewChildS("password", poller.Password)
+	parentParams.NewChildS("use_insecure_tls", "true")
+
+	params := node.NewS("VolumeAnalytics")
+	params.NewChildS("MaxDirectoryCount", maxCount)
+	params.NewChildS("MaxDepth", maxDepth)
+	params.NewChildS("ActivityTracking", "true")
+
+	p := plugin.New("Rest", &options.Options{Poller: "fake"}, params, parentParams, "volume",
+		auth.NewCredentials(conf.ZapiPoller(parentParams), logging.Get()))
+	v := &VolumeAnalytics{AbstractPlugin: p}
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	return v
+}
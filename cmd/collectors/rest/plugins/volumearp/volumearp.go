@@ -0,0 +1,209 @@
+// Package volumearp collects the Autonomous Ransomware Protection (ARP) counters of volumes and sets the severity
+// label of each volume, so alert rules can key off one label instead of the ARP state and attack probability
+package volumearp
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+// SnapshotLockingVersion is the first ONTAP release that reports the Snapshot locking of volumes
+const SnapshotLockingVersion = "9.12.1"
+
+const (
+	SeverityOk       = "ok"
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severities = []string{SeverityOk, SeverityInfo, SeverityWarning, SeverityCritical}
+
+// attackProbabilities are the codes of the anti_ransomware.attack_probability of a volume
+var attackProbabilities = map[string]float64{
+	"none":     0,
+	"low":      1,
+	"moderate": 2,
+	"high":     3,
+}
+
+var metrics = []string{"attack_probability", "attack_reports", "snapshot_locking_enabled", "suspect_files"}
+
+type VolumeArp struct {
+	*plugin.AbstractPlugin
+	client          *rest.Client
+	snapshotLocking bool
+}
+
+type arpInfo struct {
+	attackReports   float64
+	snapshotLocking bool
+	suspectFiles    float64
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &VolumeArp{AbstractPlugin: p}
+}
+
+func (v *VolumeArp) Init() error {
+	var err error
+	if err := v.InitAbc(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if v.client, err = rest.New(conf.ZapiPoller(v.ParentParams), timeout, v.Auth); err != nil {
+		v.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := v.client.Init(5); err != nil {
+		return err
+	}
+
+	// Asking for snapshot_locking_enabled before it exists fails the whole request
+	v.snapshotLocking, err = util.VersionAtLeast(v.client.Cluster().GetVersion(), SnapshotLockingVersion)
+	if err != nil {
+		return fmt.Errorf("unable to get version %w", err)
+	}
+	return nil
+}
+
+func (v *VolumeArp) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[v.Object]
+	v.client.Metadata.Reset()
+
+	for _, name := range metrics {
+		if data.GetMetric(name) == nil {
+			if _, err := data.NewMetricFloat64(name); err != nil {
+				v.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+				return nil, nil, err
+			}
+		}
+	}
+
+	arpMap, err := v.getArpInfo()
+	if err != nil {
+		v.Logger.Error().Err(err).Msg("Failed to collect volume arp data")
+	}
+
+	for _, volume := range data.GetInstances() {
+		if !volume.IsExportable() {
+			continue
+		}
+		state := volume.GetLabel("state")
+		probability := volume.GetLabel("attack_probability")
+		volume.SetLabel("severity", Severity(state, probability))
+
+		for _, name := range metrics {
+			data.GetMetric(name).SetValueNAN(volume)
+		}
+		if code, ok := attackProbabilities[probability]; ok {
+			v.setValue(data, "attack_probability", volume, code)
+		}
+		info, ok := arpMap[volume.GetLabel("volume")+volume.GetLabel("svm")]
+		if !ok {
+			continue
+		}
+		v.setValue(data, "attack_reports", volume, info.attackReports)
+		v.setValue(data, "suspect_files", volume, info.suspectFiles)
+		if v.snapshotLocking {
+			locked := 0.0
+			if info.snapshotLocking {
+				locked = 1
+			}
+			v.setValue(data, "snapshot_locking_enabled", volume, locked)
+		}
+	}
+
+	return nil, v.client.Metadata, nil
+}
+
+func (v *VolumeArp) setValue(data *matrix.Matrix, name string, volume *matrix.Instance, value float64) {
+	if err := data.GetMetric(name).SetValueFloat64(volume, value); err != nil {
+		v.Logger.Error().Err(err).Str("metric", name).Msg("Unable to set value on metric")
+	}
+}
+
+// Severity returns the severity of a volume with the ARP state and attack probability.
+// A high attack probability is critical, a moderate one or paused protection is a warning,
+// and a low attack probability, learning mode, or disabled protection is info
+func Severity(state, probability string) string {
+	severity := SeverityOk
+	switch probability {
+	case "high":
+		severity = SeverityCritical
+	case "moderate":
+		severity = SeverityWarning
+	case "low":
+		severity = SeverityInfo
+	}
+	switch state {
+	case "enable_paused", "dry_run_paused":
+		severity = maxSeverity(severity, SeverityWarning)
+	case "dry_run", "disabled", "disable_in_progress", "":
+		severity = maxSeverity(severity, SeverityInfo)
+	}
+	return severity
+}
+
+func maxSeverity(a, b string) string {
+	if severityIndex(b) > severityIndex(a) {
+		return b
+	}
+	return a
+}
+
+func severityIndex(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+func (v *VolumeArp) getArpInfo() (map[string]arpInfo, error) {
+	var (
+		result []gjson.Result
+		err    error
+	)
+	fields := []string{"name", "svm.name", "anti_ransomware.attack_reports", "anti_ransomware.suspect_files"}
+	if v.snapshotLocking {
+		fields = append(fields, "snapshot_locking_enabled")
+	}
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/volumes").
+		Fields(fields).
+		Filter([]string{"is_constituent=false"}).
+		Build()
+
+	if result, err = collectors.InvokeRestCall(v.client, href, v.Logger); err != nil {
+		return nil, err
+	}
+
+	arpMap := make(map[string]arpInfo, len(result))
+	for _, volume := range result {
+		arpMap[volume.Get("name").String()+volume.Get("svm.name").String()] = parseArpInfo(volume)
+	}
+	return arpMap, nil
+}
+
+// parseArpInfo returns the attack reports, suspect files, and Snapshot locking of a volume record.
+// The suspect files are the sum of the counts of each suspect file extension
+func parseArpInfo(volume gjson.Result) arpInfo {
+	info := arpInfo{
+		attackReports:   float64(len(volume.Get("anti_ransomware.attack_reports").Array())),
+		snapshotLocking: volume.Get("snapshot_locking_enabled").Bool(),
+	}
+	for _, count := range volume.Get("anti_ransomware.suspect_files.#.count").Array() {
+		info.suspectFiles += count.Float()
+	}
+	return info
+}
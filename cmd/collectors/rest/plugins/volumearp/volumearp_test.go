@@ -0,0 +1,48 @@
+package volumearp
+
+import (
+	"github.com/tidwall/gjson"
+	"testing"
+)
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		probability string
+		want        string
+	}{
+		{name: "enabled", state: "enabled", probability: "none", want: SeverityOk},
+		{name: "low", state: "enabled", probability: "low", want: SeverityInfo},
+		{name: "moderate", state: "enabled", probability: "moderate", want: SeverityWarning},
+		{name: "high", state: "enabled", probability: "high", want: SeverityCritical},
+		{name: "learning", state: "dry_run", probability: "none", want: SeverityInfo},
+		{name: "disabled", state: "disabled", probability: "", want: SeverityInfo},
+		{name: "paused", state: "enable_paused", probability: "low", want: SeverityWarning},
+		{name: "paused high", state: "dry_run_paused", probability: "high", want: SeverityCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Severity(tt.state, tt.probability); got != tt.want {
+				t.Errorf("Severity() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArpInfo(t *testing.T) {
+	volume := gjson.Parse(`{
+		"name": "vol1",
+		"svm": {"name": "svm1"},
+		"snapshot_locking_enabled": true,
+		"anti_ransomware": {
+			"attack_reports": [{"time": "2024-05-01T10:00:00Z"}, {"time": "2024-05-02T10:00:00Z"}],
+			"suspect_files": [{"count": 12, "format": "lckd"}, {"count": 3, "format": "enc"}]
+		}
+	}`)
+	got := parseArpInfo(volume)
+	want := arpInfo{attackReports: 2, snapshotLocking: true, suspectFiles: 15}
+	if got != want {
+		t.Errorf("parseArpInfo() got=%+v, want=%+v", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+// Package volumesnaplock converts the SnapLock compliance clock, expiry time, and retention periods of volumes
+// to metrics, and counts the volumes that expire soon, so compliance teams can alert before retention expires
+package volumesnaplock
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	expiringObject = "volume_snaplock_expiring"
+	// expiredWindow is the window of the volumes whose expiry time has passed
+	expiredWindow = "expired"
+	day           = 24 * 60 * 60
+)
+
+var defaultWindows = []int{7, 30, 90}
+
+var metrics = []string{
+	"compliance_clock",
+	"compliance_clock_skew",
+	"expiry_remaining",
+	"retention_default",
+	"retention_maximum",
+	"retention_minimum",
+}
+
+// timeLayouts are the formats ONTAP uses for SnapLock times. The compliance clock is RFC 3339,
+// while the expiry time is formatted like the CLI
+var timeLayouts = []string{time.RFC3339, "Mon Jan _2 15:04:05 MST 2006"}
+
+// retentionRegex matches ISO 8601 durations, e.g. P30Y or P1DT12H
+var retentionRegex = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// retentionUnits are the seconds of each group of retentionRegex. SnapLock counts years and months on the
+// calendar, so a year is 365 days and a month 30 days
+var retentionUnits = []float64{365 * day, 30 * day, 7 * day, day, 60 * 60, 60, 1}
+
+type VolumeSnapLock struct {
+	*plugin.AbstractPlugin
+	windows []int // days before expiry
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &VolumeSnapLock{AbstractPlugin: p}
+}
+
+func (v *VolumeSnapLock) Init() error {
+	if err := v.InitAbc(); err != nil {
+		return err
+	}
+	v.windows = defaultWindows
+	if windows := v.Params.GetChildS("expiry_windows"); windows != nil {
+		v.windows = nil
+		for _, w := range windows.GetAllChildContentS() {
+			days, err := strconv.Atoi(w)
+			if err != nil || days < 1 {
+				return fmt.Errorf("expiry_windows must be a positive number of days [%s]", w)
+			}
+			v.windows = append(v.windows, days)
+		}
+		slices.Sort(v.windows)
+	}
+	return nil
+}
+
+func (v *VolumeSnapLock) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[v.Object]
+
+	for _, name := range metrics {
+		if err := matrix.CreateMetric(name, data); err != nil {
+			v.Logger.Error().Err(err).Str("metric", name).Msg("add metric")
+			return nil, nil, err
+		}
+	}
+
+	expiring, err := v.newExpiring(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	for _, volume := range data.GetInstances() {
+		if !volume.IsExportable() {
+			continue
+		}
+		for _, name := range metrics {
+			data.GetMetric(name).SetValueNAN(volume)
+		}
+		for _, name := range []string{"retention_default", "retention_maximum", "retention_minimum"} {
+			if seconds, ok := parseRetention(volume.GetLabel(name)); ok {
+				v.setValue(data, name, volume, seconds)
+			}
+		}
+
+		clock, ok := parseTime(volume.GetLabel("compliance_clock_time"))
+		if !ok {
+			continue
+		}
+		v.setValue(data, "compliance_clock", volume, float64(clock.Unix()))
+		v.setValue(data, "compliance_clock_skew", volume, clock.Sub(now).Seconds())
+
+		// retention is enforced with the compliance clock of the volume, not the clock of the poller
+		expiry, ok := parseTime(volume.GetLabel("expiry_time"))
+		if !ok {
+			continue
+		}
+		remaining := expiry.Sub(clock).Seconds()
+		v.setValue(data, "expiry_remaining", volume, remaining)
+		v.countExpiring(expiring, volume.GetLabel("svm"), remaining)
+	}
+
+	return []*matrix.Matrix{expiring}, nil, nil
+}
+
+// newExpiring returns a matrix with an instance for each SVM with SnapLock volumes and each window,
+// so the count of a window is 0, not absent, when no volume expires in it
+func (v *VolumeSnapLock) newExpiring(data *matrix.Matrix) (*matrix.Matrix, error) {
+	mat := matrix.New(v.Parent+"."+expiringObject, expiringObject, expiringObject)
+	mat.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	instanceKeys.NewChildS("", "svm")
+	instanceKeys.NewChildS("", "window")
+	mat.SetExportOptions(exportOptions)
+	if _, err := mat.NewMetricFloat64("volumes"); err != nil {
+		return nil, err
+	}
+
+	for _, volume := range data.GetInstances() {
+		if !volume.IsExportable() {
+			continue
+		}
+		svm := volume.GetLabel("svm")
+		for _, window := range v.windowNames() {
+			if mat.GetInstance(svm+"."+window) != nil {
+				continue
+			}
+			instance, err := mat.NewInstance(svm + "." + window)
+			if err != nil {
+				return nil, err
+			}
+			instance.SetLabel("svm", svm)
+			instance.SetLabel("window", window)
+			_ = mat.GetMetric("volumes").SetValueFloat64(instance, 0)
+		}
+	}
+	return mat, nil
+}
+
+func (v *VolumeSnapLock) windowNames() []string {
+	names := []string{expiredWindow}
+	for _, days := range v.windows {
+		names = append(names, strconv.Itoa(days)+"d")
+	}
+	return names
+}
+
+// countExpiring adds a volume to each window it expires in. A volume that expires in 5 days is counted
+// in the 7d, 30d, and 90d windows
+func (v *VolumeSnapLock) countExpiring(mat *matrix.Matrix, svm string, remaining float64) {
+	volumes := mat.GetMetric("volumes")
+	add := func(window string) {
+		instance := mat.GetInstance(svm + "." + window)
+		if instance == nil {
+			return
+		}
+		count, _ := volumes.GetValueFloat64(instance)
+		_ = volumes.SetValueFloat64(instance, count+1)
+	}
+	if remaining <= 0 {
+		add(expiredWindow)
+		return
+	}
+	for _, days := range v.windows {
+		if remaining <= float64(days*day) {
+			add(strconv.Itoa(days) + "d")
+		}
+	}
+}
+
+func (v *VolumeSnapLock) setValue(data *matrix.Matrix, name string, volume *matrix.Instance, value float64) {
+	if err := data.GetMetric(name).SetValueFloat64(volume, value); err != nil {
+		v.Logger.Error().Err(err).Str("metric", name).Msg("Unable to set value on metric")
+	}
+}
+
+// parseTime parses a SnapLock time. Volumes without an expiry time report a dash or none
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseRetention returns the seconds of an ISO 8601 SnapLock retention period.
+// The infinite and unspecified retention periods have no duration, so they return false
+func parseRetention(value string) (float64, bool) {
+	matches := retentionRegex.FindStringSubmatch(value)
+	if matches == nil || value == "P" {
+		return 0, false
+	}
+	seconds := 0.0
+	for i, unit := range retentionUnits {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds += n * unit
+	}
+	return seconds, true
+}
@@ -0,0 +1,103 @@
+package volumesnaplock
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   float64
+		wantOk bool
+	}{
+		{value: "P30Y", want: 30 * 365 * day, wantOk: true},
+		{value: "P6M", want: 6 * 30 * day, wantOk: true},
+		{value: "P1DT12H", want: day + 12*60*60, wantOk: true},
+		{value: "PT30M", want: 30 * 60, wantOk: true},
+		{value: "infinite"},
+		{value: "unspecified"},
+		{value: "P"},
+		{value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetention(tt.value)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetention() got=%f %t, want=%f %t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestVolumeSnapLock_Run(t *testing.T) {
+	data := matrix.New("Rest.volume_snaplock", "volume_snaplock", "volume_snaplock")
+	volumes := []struct {
+		name   string
+		svm    string
+		expiry string
+	}{
+		{name: "soon", svm: "svm1", expiry: "2024-06-06T00:00:00Z"},
+		{name: "month", svm: "svm1", expiry: "Sun Jun 30 00:00:00 GMT 2024"},
+		{name: "expired", svm: "svm1", expiry: "2024-05-01T00:00:00Z"},
+		{name: "none", svm: "svm2", expiry: "-"},
+	}
+	for _, v := range volumes {
+		instance, err := data.NewInstance(v.svm + v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("volume", v.name)
+		instance.SetLabel("svm", v.svm)
+		instance.SetLabel("compliance_clock_time", "2024-06-01T00:00:00Z")
+		instance.SetLabel("expiry_time", v.expiry)
+		instance.SetLabel("retention_default", "P1Y")
+	}
+
+	v := New(plugin.New("Rest", nil, node.NewS("VolumeSnapLock"), nil, "volume_snaplock", nil))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	result, _, err := v.Run(map[string]*matrix.Matrix{"volume_snaplock": data})
+	if err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+
+	remaining := data.GetMetric("expiry_remaining")
+	if got, _ := remaining.GetValueFloat64(data.GetInstance("svm1soon")); got != 5*day {
+		t.Errorf("soon expiry_remaining got=%f, want=%d", got, 5*day)
+	}
+	if _, ok := remaining.GetValueFloat64(data.GetInstance("svm2none")); ok {
+		t.Errorf("none expiry_remaining is set, want absent")
+	}
+	if got, _ := data.GetMetric("retention_default").GetValueFloat64(data.GetInstance("svm2none")); got != 365*day {
+		t.Errorf("none retention_default got=%f, want=%d", got, 365*day)
+	}
+
+	expiring := result[0]
+	tests := []struct {
+		key  string
+		want float64
+	}{
+		{key: "svm1.expired", want: 1},
+		{key: "svm1.7d", want: 1},
+		{key: "svm1.30d", want: 2},
+		{key: "svm1.90d", want: 2},
+		{key: "svm2.7d", want: 0},
+	}
+	if got := len(expiring.GetInstances()); got != 8 {
+		t.Errorf("expiring got=%d instances, want=8", got)
+	}
+	for _, tt := range tests {
+		instance := expiring.GetInstance(tt.key)
+		if instance == nil {
+			t.Errorf("%s is missing", tt.key)
+			continue
+		}
+		if got, _ := expiring.GetMetric("volumes").GetValueFloat64(instance); got != tt.want {
+			t.Errorf("%s volumes got=%f, want=%f", tt.key, got, tt.want)
+		}
+	}
+}
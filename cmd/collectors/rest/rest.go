@@ -6,8 +6,13 @@ import (
 	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/aggregate"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/certificate"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/clusterpeer"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/disk"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/ethernetswitch"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/ethernetswitchport"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/health"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/job"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/metrocluster"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/metroclustercheck"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/netroute"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/ontaps3service"
@@ -16,11 +21,15 @@ import (
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/quota"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/securityaccount"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/shelf"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/smbc"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/snapmirror"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/snapshotcompliance"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/svm"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/systemnode"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/volume"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/volumeanalytics"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/volumearp"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/volumesnaplock"
 	"github.com/netapp/harvest/v2/cmd/collectors/rest/plugins/workload"
 	"github.com/netapp/harvest/v2/cmd/poller/collector"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
@@ -53,6 +62,8 @@ type Rest struct {
 	Prop                         *prop
 	endpoints                    []*EndPoint
 	isIgnoreUnknownFieldsEnabled bool
+	schema                       *SchemaDrift // nil unless schema_drift is enabled
+	incremental                  *incremental // nil unless records_per_poll is set
 }
 
 type EndPoint struct {
@@ -170,6 +181,11 @@ func (r *Rest) Init(a *collector.AbstractCollector) error {
 		return err
 	}
 
+	// recorded responses are polled without a counter poll, which builds the hrefs
+	if r.Options.Fixtures != "" {
+		r.updateHref()
+	}
+
 	r.Logger.Debug().
 		Int("numMetrics", len(r.Prop.Metrics)).
 		Str("timeout", r.Client.Timeout.String()).
@@ -193,6 +209,19 @@ func (r *Rest) InitVars(config *node.Node) {
 	} else {
 		r.Logger.Info().Str("timeout", rest.DefaultTimeout).Msg("Using default timeout")
 	}
+
+	if config.GetChildContentS("schema_drift") == "true" {
+		r.schema = &SchemaDrift{}
+	}
+
+	if perPoll := config.GetChildContentS("records_per_poll"); perPoll != "" {
+		n, err := strconv.Atoi(perPoll)
+		if err != nil || n <= 0 {
+			r.Logger.Warn().Str("records_per_poll", perPoll).Msg("Invalid records_per_poll, want a positive number. Polls collect all records")
+		} else {
+			r.incremental = &incremental{recordsPerPoll: n}
+		}
+	}
 }
 
 func (r *Rest) InitClient() error {
@@ -238,6 +267,9 @@ func (r *Rest) getClient(a *collector.AbstractCollector, c *auth.Credentials) (*
 	)
 
 	opt := a.GetOptions()
+	if opt.IsTest {
+		return &rest.Client{Metadata: &util.Metadata{}}, nil
+	}
 	if poller, err = conf.PollerNamed(opt.Poller); err != nil {
 		r.Logger.Error().Err(err).Str("poller", opt.Poller).Msgf("")
 		return nil, err
@@ -247,9 +279,6 @@ func (r *Rest) getClient(a *collector.AbstractCollector, c *auth.Credentials) (*
 		return nil, errs.New(errs.ErrMissingParam, "addr")
 	}
 	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
-	if a.Options.IsTest {
-		return &rest.Client{Metadata: &util.Metadata{}}, nil
-	}
 	if client, err = rest.New(poller, timeout, c); err != nil {
 		r.Logger.Error().Err(err).Str("poller", opt.Poller).Msg("error creating new client")
 		os.Exit(1)
@@ -363,10 +392,15 @@ func (r *Rest) PollCounter() (map[string]*matrix.Matrix, error) {
 }
 
 func (r *Rest) updateHref() {
+	var maxRecords *int
+	if r.incremental != nil {
+		maxRecords = &r.incremental.recordsPerPoll
+	}
 	r.Prop.Href = rest.NewHrefBuilder().
 		APIPath(r.Prop.Query).
 		Fields(r.Fields(r.Prop)).
 		Filter(r.Prop.Filter).
+		MaxRecords(maxRecords).
 		ReturnTimeout(r.Prop.ReturnTimeOut).
 		IsIgnoreUnknownFieldsEnabled(r.isIgnoreUnknownFieldsEnabled).
 		Build()
@@ -390,8 +424,11 @@ func (r *Rest) PollData() (map[string]*matrix.Matrix, error) {
 		records   []gjson.Result
 	)
 
-	r.Matrix[r.Object].Reset()
 	r.Client.Metadata.Reset()
+	if r.incremental != nil {
+		return r.pollIncremental(r.fetchPages, r.ProcessEndPoint)
+	}
+	r.Matrix[r.Object].Reset()
 
 	startTime = time.Now()
 
@@ -424,6 +461,9 @@ func (r *Rest) pollData(
 	mat := r.Matrix[r.Object]
 
 	count, _ = r.HandleResults(mat, records, r.Prop, false)
+	if r.schema != nil {
+		r.CheckSchema("data", SchemaFields(records))
+	}
 
 	// process endpoints
 	eCount, endpointAPID := r.ProcessEndPoints(mat, endpointFunc)
@@ -437,6 +477,7 @@ func (r *Rest) pollData(
 	_ = r.Metadata.LazySetValueUint64("metrics", "data", count)
 	_ = r.Metadata.LazySetValueUint64("instances", "data", uint64(numRecords))
 	_ = r.Metadata.LazySetValueUint64("bytesRx", "data", r.Client.Metadata.BytesRx)
+	_ = r.Metadata.LazySetValueUint64("bytesRxWire", "data", r.Client.Metadata.BytesRxWire)
 	_ = r.Metadata.LazySetValueUint64("numCalls", "data", r.Client.Metadata.NumCalls)
 
 	r.AddCollectCount(count)
@@ -490,20 +531,34 @@ func (r *Rest) LoadPlugin(kind string, abc *plugin.AbstractPlugin) plugin.Plugin
 		return aggregate.New(abc)
 	case "Disk":
 		return disk.New(abc)
+	case "EthernetSwitch":
+		return ethernetswitch.New(abc)
+	case "EthernetSwitchPort":
+		return ethernetswitchport.New(abc)
 	case "Health":
 		return health.New(abc)
+	case "Job":
+		return job.New(abc)
 	case "NetRoute":
 		return netroute.New(abc)
 	case "Quota":
 		return quota.New(abc)
 	case "Snapmirror":
 		return snapmirror.New(abc)
+	case "SnapshotCompliance":
+		return snapshotcompliance.New(abc)
 	case "Volume":
 		return volume.New(abc)
 	case "VolumeAnalytics":
 		return volumeanalytics.New(abc)
+	case "VolumeArp":
+		return volumearp.New(abc)
+	case "VolumeSnapLock":
+		return volumesnaplock.New(abc)
 	case "Certificate":
 		return certificate.New(abc)
+	case "ClusterPeer":
+		return clusterpeer.New(abc)
 	case "SVM":
 		return svm.New(abc)
 	case "Sensor":
@@ -518,8 +573,12 @@ func (r *Rest) LoadPlugin(kind string, abc *plugin.AbstractPlugin) plugin.Plugin
 		return qospolicyadaptive.New(abc)
 	case "OntapS3Service":
 		return ontaps3service.New(abc)
+	case "Metrocluster":
+		return metrocluster.New(abc)
 	case "MetroclusterCheck":
 		return metroclustercheck.New(abc)
+	case "SMBC":
+		return smbc.New(abc)
 	case "SystemNode":
 		return systemnode.New(abc)
 	case "Workload":
@@ -533,6 +592,12 @@ func (r *Rest) LoadPlugin(kind string, abc *plugin.AbstractPlugin) plugin.Plugin
 // HandleResults function is used for handling the rest response for parent as well as endpoints calls,
 // isEndPoint would be true only for the endpoint call, and it can't create/delete instance.
 func (r *Rest) HandleResults(mat *matrix.Matrix, result []gjson.Result, prop *prop, isEndPoint bool) (uint64, uint64) {
+	return r.handleResults(mat, result, prop, isEndPoint, false)
+}
+
+// handleResults is HandleResults. When keepInstances is true, instances missing from result are not removed,
+// which is used to add the pages of an incremental poll to the same matrix
+func (r *Rest) handleResults(mat *matrix.Matrix, result []gjson.Result, prop *prop, isEndPoint bool, keepInstances bool) (uint64, uint64) {
 	var (
 		err         error
 		count       uint64
@@ -630,14 +695,23 @@ func (r *Rest) HandleResults(mat *matrix.Matrix, result []gjson.Result, prop *pr
 		for _, metric := range prop.Metrics {
 			metr, ok := mat.GetMetrics()[metric.Name]
 			if !ok {
-				if metr, err = mat.NewMetricFloat64(metric.Name, metric.Label); err != nil {
+				if metr, err = r.newMetric(mat, metric); err != nil {
 					r.Logger.Error().Err(err).
 						Str("name", metric.Name).
-						Msg("NewMetricFloat64")
+						Msg("newMetric")
 				}
 			}
 			f := instanceData.Get(metric.Name)
 			if f.Exists() {
+				// parse the JSON number, not its float64, so counters larger than 2^53 keep their precision
+				if metric.MetricType == "uint64" {
+					if err = metr.SetValueString(instance, f.String()); err != nil {
+						r.Logger.Error().Err(err).Str("key", metric.Name).Str("metric", metric.Label).
+							Msg("Unable to set uint64 key on metric")
+					}
+					count++
+					continue
+				}
 				var floatValue float64
 				switch metric.MetricType {
 				case "duration":
@@ -665,7 +739,7 @@ func (r *Rest) HandleResults(mat *matrix.Matrix, result []gjson.Result, prop *pr
 	}
 
 	// Used for parent as we don't want to remove instances for endpoints
-	if !isEndPoint {
+	if !isEndPoint && !keepInstances {
 		// remove deleted instances
 		for key := range oldInstances.Iter() {
 			mat.RemoveInstance(key)
@@ -675,13 +749,26 @@ func (r *Rest) HandleResults(mat *matrix.Matrix, result []gjson.Result, prop *pr
 	return count, numPartials
 }
 
+// newMetric adds the metric of a template counter to mat. Counters with the uint64 type are stored as uint64,
+// the other counters as float64
+func (r *Rest) newMetric(mat *matrix.Matrix, metric *Metric) (*matrix.Metric, error) {
+	if metric.MetricType == "uint64" {
+		return mat.NewMetricUint64(metric.Name, metric.Label)
+	}
+	return mat.NewMetricFloat64(metric.Name, metric.Label)
+}
+
 func (r *Rest) GetRestData(href string) ([]gjson.Result, error) {
 	r.Logger.Debug().Str("href", href).Send()
 	if href == "" {
 		return nil, errs.New(errs.ErrConfig, "empty url")
 	}
+	if r.Options.Fixtures != "" {
+		return readFixture(r.Options.Fixtures, href)
+	}
 
-	result, err := rest.Fetch(r.Client, href)
+	// requests of a poll end with the slot of its task
+	result, err := rest.FetchContext(r.Context(), r.Client, href)
 	if err != nil {
 		return r.handleError(err)
 	}
@@ -438,3 +438,38 @@ func TestFields(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleResults_Uint64(t *testing.T) {
+	r := newRest("Volume", "volume.yaml")
+	mat := matrix.New("Rest", "aggr", "aggr")
+	p := &prop{
+		InstanceKeys:   []string{"uuid"},
+		InstanceLabels: map[string]string{"uuid": "uuid"},
+		Metrics: map[string]*Metric{
+			"space.size":    {Name: "space.size", Label: "space_total", MetricType: "uint64", Exportable: true},
+			"space.percent": {Name: "space.percent", Label: "space_percent", MetricType: "uint64", Exportable: true},
+			"space.used":    {Name: "space.used", Label: "space_used", Exportable: true},
+		},
+	}
+	result := gjson.Parse(`[{"uuid":"a","space":{"size":9007199254740993,"percent":12.5,"used":9007199254740993}}]`).Array()
+	r.HandleResults(mat, result, p, false)
+
+	instance := mat.GetInstance("a")
+	tests := []struct {
+		metric string
+		want   string
+	}{
+		{metric: "space.size", want: "9007199254740993"},
+		{metric: "space.percent", want: "12.5"},
+		// float64 counters round to the nearest float64
+		{metric: "space.used", want: "9007199254740992"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, ok := mat.GetMetric(tt.metric).GetValueString(instance)
+			if !ok || got != tt.want {
+				t.Errorf("GetValueString() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"github.com/tidwall/gjson"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// SchemaDrift detects changes of the fields or counters ONTAP responds with, e.g. after an upgrade.
+// Each poll, the sorted set of fields is hashed and compared with the hash of the previous poll
+type SchemaDrift struct {
+	hash    uint64
+	fields  []string
+	changes uint64 // number of changes since the collector started
+}
+
+// Observe records the fields of a poll and returns the fields added and removed since the previous poll.
+// The first poll is the baseline and has no changes
+func (s *SchemaDrift) Observe(fields []string) (added []string, removed []string, changed bool) {
+	fields = slices.Clone(fields)
+	slices.Sort(fields)
+	fields = slices.Compact(fields)
+
+	h := fnv.New64a()
+	for _, f := range fields {
+		_, _ = h.Write([]byte(f))
+		_, _ = h.Write([]byte{0})
+	}
+	hash := h.Sum64()
+
+	if s.fields == nil || hash == s.hash {
+		s.hash = hash
+		s.fields = fields
+		return nil, nil, false
+	}
+
+	for _, f := range fields {
+		if _, found := slices.BinarySearch(s.fields, f); !found {
+			added = append(added, f)
+		}
+	}
+	for _, f := range s.fields {
+		if _, found := slices.BinarySearch(fields, f); !found {
+			removed = append(removed, f)
+		}
+	}
+	s.hash = hash
+	s.fields = fields
+	s.changes++
+	return added, removed, true
+}
+
+// Changes returns the number of schema changes since the collector started
+func (s *SchemaDrift) Changes() uint64 {
+	return s.changes
+}
+
+// SchemaFields returns the paths of the fields in records, in dot notation.
+// Arrays are not indexed, the fields of objects in arrays are merged
+func SchemaFields(records []gjson.Result) []string {
+	seen := make(map[string]struct{})
+	var walk func(prefix string, value gjson.Result)
+	walk = func(prefix string, value gjson.Result) {
+		switch {
+		case value.IsObject():
+			value.ForEach(func(key, v gjson.Result) bool {
+				walk(prefix+key.String()+".", v)
+				return true
+			})
+		case value.IsArray():
+			value.ForEach(func(_, v gjson.Result) bool {
+				if v.IsObject() || v.IsArray() {
+					walk(prefix, v)
+				} else if prefix != "" {
+					seen[strings.TrimSuffix(prefix, ".")] = struct{}{}
+				}
+				return true
+			})
+		case prefix != "":
+			seen[strings.TrimSuffix(prefix, ".")] = struct{}{}
+		}
+	}
+	for _, record := range records {
+		walk("", record)
+	}
+
+	fields := make([]string, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// CheckSchema compares fields with the fields of the previous poll of task, when schema_drift is enabled.
+// Changes are logged with the fields added and removed, and counted by the schema_changes metadata of task
+func (r *Rest) CheckSchema(task string, fields []string) {
+	if r.schema == nil {
+		return
+	}
+	if r.Metadata.GetMetric("schema_changes") == nil {
+		if _, err := r.Metadata.NewMetricUint64("schema_changes"); err != nil {
+			r.Logger.Error().Err(err).Msg("Failed to create schema_changes metric")
+			return
+		}
+	}
+
+	if added, removed, changed := r.schema.Observe(fields); changed {
+		r.Logger.Warn().
+			Str("object", r.Object).
+			Str("task", task).
+			Strs("added", added).
+			Strs("removed", removed).
+			Msg("Schema changed")
+	}
+	_ = r.Metadata.LazySetValueUint64("schema_changes", task, r.schema.Changes())
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"github.com/tidwall/gjson"
+	"slices"
+	"testing"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name        string
+		fields      []string
+		wantAdded   []string
+		wantRemoved []string
+		wantChanged bool
+	}{
+		{name: "baseline", fields: []string{"name", "size"}},
+		{name: "same fields", fields: []string{"size", "name", "size"}},
+		{name: "added", fields: []string{"name", "size", "space.used"}, wantAdded: []string{"space.used"}, wantChanged: true},
+		{name: "added and removed", fields: []string{"name", "space.used", "state"},
+			wantAdded: []string{"state"}, wantRemoved: []string{"size"}, wantChanged: true},
+	}
+
+	s := &SchemaDrift{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, changed := s.Observe(tt.fields)
+			if changed != tt.wantChanged {
+				t.Errorf("Observe() changed got=%t, want=%t", changed, tt.wantChanged)
+			}
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("Observe() added got=%v, want=%v", added, tt.wantAdded)
+			}
+			if !slices.Equal(removed, tt.wantRemoved) {
+				t.Errorf("Observe() removed got=%v, want=%v", removed, tt.wantRemoved)
+			}
+		})
+	}
+	if s.Changes() != 2 {
+		t.Errorf("Changes() got=%d, want=2", s.Changes())
+	}
+}
+
+func TestSchemaFields(t *testing.T) {
+	records := gjson.Parse(`[
+		{"name": "vol1", "space": {"used": 1}, "tags": ["a", "b"]},
+		{"name": "vol2", "aggregates": [{"name": "aggr1"}, {"uuid": "u1"}]}
+	]`).Array()
+
+	want := []string{"aggregates.name", "aggregates.uuid", "name", "space.used", "tags"}
+	if got := SchemaFields(records); !slices.Equal(got, want) {
+		t.Errorf("SchemaFields() got=%v, want=%v", got, want)
+	}
+}
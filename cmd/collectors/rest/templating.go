@@ -181,6 +181,15 @@ func (r *Rest) ParseRestCounters(counter *node.Node, prop *prop) {
 		}
 	}
 
+	if len(instanceKeys) == 0 {
+		if instanceKeys = inferInstanceKeys(prop.InstanceLabels); len(instanceKeys) != 0 {
+			r.Logger.Info().
+				Str("query", prop.Query).
+				Strs("instanceKeys", util.GetSortedKeys(instanceKeys)).
+				Msg("Template has no instance keys, inferred them from its labels")
+		}
+	}
+
 	// populate prop.instanceKeys
 	// sort keys by display name. This is needed to match counter and endpoints keys
 	keys := util.GetSortedKeys(instanceKeys)
@@ -212,3 +221,23 @@ func (r *Rest) ParseRestCounters(counter *node.Node, prop *prop) {
 	}
 
 }
+
+// inferInstanceKeys returns the instance keys of a template without keys, by display name, from the labels of the
+// template: uuid when it is a label, otherwise name with svm.name and node.name, since names are only unique per
+// svm or node. It returns nothing when the template has none of these labels, e.g. for cluster-wide objects
+func inferInstanceKeys(labels map[string]string) map[string]string {
+	keys := make(map[string]string)
+	if display, ok := labels["uuid"]; ok {
+		keys[display] = "uuid"
+		return keys
+	}
+	if display, ok := labels["name"]; ok {
+		keys[display] = "name"
+		for _, scope := range []string{"svm.name", "node.name"} {
+			if display, ok := labels[scope]; ok {
+				keys[display] = scope
+			}
+		}
+	}
+	return keys
+}
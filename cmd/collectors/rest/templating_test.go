@@ -1,6 +1,9 @@
 package rest
 
 import (
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +68,32 @@ func Test_HandleTimestamp(t *testing.T) {
 		})
 	}
 }
+
+func TestInferInstanceKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters string
+		want     []string
+	}{
+		{name: "keys", counters: "- ^^name => volume\n- ^^svm.name => svm\n- ^uuid\n", want: []string{"svm.name", "name"}},
+		{name: "uuid", counters: "- ^name\n- ^svm.name => svm\n- ^uuid\n- size\n", want: []string{"uuid"}},
+		{name: "name and svm", counters: "- ^name => volume\n- ^svm.name => svm\n- size\n", want: []string{"svm.name", "name"}},
+		{name: "name and node", counters: "- ^name => port\n- ^node.name => node\n", want: []string{"node.name", "name"}},
+		{name: "uuid is a metric", counters: "- uuid\n- ^state\n", want: nil},
+		{name: "cluster wide", counters: "- ^fips.enabled => fips_enabled\n", want: nil},
+	}
+	r := newRest("Volume", "volume.yaml")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := tree.LoadYaml([]byte("counters:\n" + strings.ReplaceAll(tt.counters, "- ", "  - ")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &prop{InstanceLabels: make(map[string]string), Counters: make(map[string]string), Metrics: make(map[string]*Metric)}
+			r.ParseRestCounters(root.GetChildS("counters"), p)
+			if !slices.Equal(p.InstanceKeys, tt.want) {
+				t.Errorf("InstanceKeys got=%v, want=%v", p.InstanceKeys, tt.want)
+			}
+		})
+	}
+}
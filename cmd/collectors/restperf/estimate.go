@@ -0,0 +1,70 @@
+package restperf
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/tidwall/gjson"
+)
+
+// Estimate estimates the cost of the instance and data polls from the number of rows of the object, and the size
+// of one row. The series of a row are the values of its exported counters, so array counters count once per element.
+// The counter task is polled first, like the poller does, to know the counters of the object
+func (r *RestPerf) Estimate() ([]collector.Estimate, error) {
+	if _, err := r.PollCounter(); err != nil {
+		return nil, err
+	}
+
+	instance := collector.Estimate{Task: "instance", Calls: 1}
+	href := r.instanceHref()
+	count, err := rest.CountRecords(r.Client, href)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		_, size, err := rest.SampleRecord(r.Client, href)
+		if err != nil {
+			return nil, err
+		}
+		instance.Instances = count
+		instance.Bytes = int64(count) * int64(size)
+	}
+
+	data := collector.Estimate{Task: "data", Calls: 1}
+	href = r.dataHref()
+	if data.Instances, err = rest.CountRecords(r.Client, href); err != nil {
+		return nil, err
+	}
+	if data.Instances > 0 {
+		row, size, err := rest.SampleRecord(r.Client, href)
+		if err != nil {
+			return nil, err
+		}
+		data.Series = data.Instances * r.rowSeries(row)
+		data.Bytes = int64(data.Instances) * int64(size)
+	}
+	return []collector.Estimate{instance, data}, nil
+}
+
+// rowSeries returns the series of a row: one per value of its exported counters
+func (r *RestPerf) rowSeries(row gjson.Result) int {
+	series := 0
+	row.Get("counters").ForEach(func(_, counter gjson.Result) bool {
+		m, ok := r.Prop.Metrics[counter.Get("name").String()]
+		if !ok || !m.Exportable {
+			return true
+		}
+		switch {
+		case counter.Get("value").Exists():
+			series++
+		case counter.Get("values").IsArray():
+			series += len(counter.Get("values").Array())
+		default:
+			counter.Get("counters").ForEach(func(_, sub gjson.Result) bool {
+				series += len(sub.Get("values").Array())
+				return true
+			})
+		}
+		return true
+	})
+	return series
+}
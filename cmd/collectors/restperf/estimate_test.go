@@ -0,0 +1,32 @@
+package restperf
+
+import (
+	rest2 "github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/tidwall/gjson"
+	"testing"
+)
+
+func TestRowSeries(t *testing.T) {
+	r := newRestPerf("Volume", "volume.yaml")
+	r.Prop.Metrics = map[string]*rest2.Metric{
+		"read_ops":       {Name: "read_ops", Exportable: true},
+		"read_latency":   {Name: "read_latency", Exportable: true},
+		"total_ops":      {Name: "total_ops", Exportable: false},
+		"read_histogram": {Name: "read_histogram", Exportable: true},
+	}
+	row := gjson.Parse(`{"counters": [
+		{"name": "read_ops", "value": 10},
+		{"name": "total_ops", "value": 20},
+		{"name": "write_ops", "value": 30},
+		{"name": "read_latency", "values": [1, 2, 3], "labels": ["a", "b", "c"]},
+		{"name": "read_histogram", "counters": [
+			{"label": "x", "values": [1, 2]},
+			{"label": "y", "values": [3, 4]}
+		]}
+	]}`)
+
+	// read_ops, 3 values of read_latency, and 4 values of read_histogram
+	if got := r.rowSeries(row); got != 8 {
+		t.Errorf("rowSeries() got=%d, want=8", got)
+	}
+}
@@ -12,6 +12,7 @@ import (
 type FabricPool struct {
 	*plugin.AbstractPlugin
 	includeConstituents bool
+	tiering             *collectors.Tiering
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -28,6 +29,9 @@ func (f *FabricPool) Init() error {
 			f.includeConstituents = boolValue
 		}
 	}
+	if f.tiering, err = collectors.NewPluginTiering(f.AbstractPlugin); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -48,5 +52,8 @@ func (f *FabricPool) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 	if err != nil {
 		return nil, nil, err
 	}
+	if f.tiering != nil {
+		return append([]*matrix.Matrix{cache}, f.tiering.Run(f.Logger)...), nil, nil
+	}
 	return []*matrix.Matrix{cache}, nil, nil
 }
@@ -1,6 +1,7 @@
 package fcvi
 
 import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/tools/rest"
 	"github.com/netapp/harvest/v2/pkg/conf"
@@ -11,7 +12,8 @@ import (
 
 type FCVI struct {
 	*plugin.AbstractPlugin
-	client *rest.Client
+	client  *rest.Client
+	options collectors.FCVIOptions
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -23,6 +25,9 @@ func (f *FCVI) Init() error {
 	if err := f.InitAbc(); err != nil {
 		return err
 	}
+	if f.options, err = collectors.ReadFCVIOptions(f.Params); err != nil {
+		return err
+	}
 
 	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
 	if f.client, err = rest.New(conf.ZapiPoller(f.ParentParams), timeout, f.Auth); err != nil {
@@ -49,10 +54,6 @@ func (f *FCVI) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.M
 		return nil, nil, err
 	}
 
-	if len(records) == 0 {
-		return nil, nil, nil
-	}
-
 	for _, adapterData := range records {
 		if !adapterData.IsObject() {
 			f.Logger.Warn().Str("type", adapterData.Type.String()).Msg("adapter is not object, skipping")
@@ -68,5 +69,23 @@ func (f *FCVI) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.M
 		}
 	}
 
-	return nil, f.client.Metadata, nil
+	return collectors.FCVIRollups(data, f.getDRGroups(), f.options), f.client.Metadata, nil
+}
+
+// getDRGroups returns the DR group of each node of the MetroCluster
+func (f *FCVI) getDRGroups() map[string]string {
+	drGroups := make(map[string]string)
+	href := rest.NewHrefBuilder().
+		APIPath("api/cluster/metrocluster/nodes").
+		Fields([]string{"node.name", "dr_group_id"}).
+		Build()
+	records, err := rest.Fetch(f.client, href)
+	if err != nil {
+		f.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch DR groups")
+		return drGroups
+	}
+	for _, record := range records {
+		drGroups[record.Get("node.name").String()] = record.Get("dr_group_id").String()
+	}
+	return drGroups
 }
@@ -0,0 +1,196 @@
+// Package flexcache exports the FlexCache performance counters of caches only, labels each cache with its origin volume,
+// and computes the cache efficiency of each origin volume across all of its caches
+package flexcache
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+const (
+	// blockSize is the size of the blocks that caches request and retrieve
+	blockSize       = 4096
+	requested       = "blocks_requested_from_client"
+	retrieved       = "blocks_retrieved_from_origin"
+	bandwidthSaved  = "bandwidth_saved"
+	hitPercent      = "hit_percent"
+	caches          = "caches"
+	originObject    = "flexcache_origin"
+	originCluster   = "origin_cluster"
+	originSvm       = "origin_svm"
+	originVolume    = "origin_volume"
+	flexCachesQuery = "api/storage/flexcache/flexcaches"
+)
+
+var originLabels = []string{originCluster, originSvm, originVolume}
+
+type FlexCache struct {
+	*plugin.AbstractPlugin
+	client  *rest.Client
+	origins *matrix.Matrix
+}
+
+type origin struct {
+	cluster string
+	svm     string
+	volume  string
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &FlexCache{AbstractPlugin: p}
+}
+
+func (f *FlexCache) Init() error {
+	var err error
+	if err := f.InitAbc(); err != nil {
+		return err
+	}
+
+	if err := f.initOrigins(); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if f.client, err = rest.New(conf.ZapiPoller(f.ParentParams), timeout, f.Auth); err != nil {
+		f.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+
+	return f.client.Init(5)
+}
+
+func (f *FlexCache) initOrigins() error {
+	f.origins = matrix.New(f.Parent+".FlexCache", originObject, originObject)
+	for _, name := range []string{requested, retrieved, bandwidthSaved, hitPercent, caches} {
+		if _, err := f.origins.NewMetricFloat64(name); err != nil {
+			return err
+		}
+	}
+
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, label := range originLabels {
+		instanceKeys.NewChildS("", label)
+	}
+	f.origins.SetExportOptions(exportOptions)
+
+	// caches are exported with the labels of their origin
+	if exportOption := f.ParentParams.GetChildS("export_options"); exportOption != nil {
+		if exportedKeys := exportOption.GetChildS("instance_keys"); exportedKeys != nil {
+			for _, label := range originLabels {
+				if exportedKeys.GetChildByContent(label) == nil {
+					exportedKeys.NewChildS("", label)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FlexCache) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[f.Object]
+	f.client.Metadata.Reset()
+
+	href := rest.NewHrefBuilder().
+		APIPath(flexCachesQuery).
+		Fields([]string{"name", "svm.name", "origins.cluster.name", "origins.svm.name", "origins.volume.name"}).
+		Build()
+
+	records, err := rest.Fetch(f.client, href)
+	if err != nil {
+		f.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch data")
+		return nil, nil, err
+	}
+
+	f.update(data, originsOf(records))
+	return []*matrix.Matrix{f.origins}, f.client.Metadata, nil
+}
+
+// originsOf returns the origin of each cache, keyed by svm and volume of the cache
+func originsOf(records []gjson.Result) map[string]origin {
+	origins := make(map[string]origin)
+	for _, record := range records {
+		if !record.IsObject() {
+			continue
+		}
+		// a cache has one origin
+		o := record.Get("origins.0")
+		origins[record.Get("svm.name").String()+"#"+record.Get("name").String()] = origin{
+			cluster: o.Get("cluster.name").String(),
+			svm:     o.Get("svm.name").String(),
+			volume:  o.Get("volume.name").String(),
+		}
+	}
+	return origins
+}
+
+// update hides the volumes that are not caches, labels each cache with its origin,
+// computes the bandwidth each cache saved, and sums the counters of the caches of each origin
+func (f *FlexCache) update(data *matrix.Matrix, origins map[string]origin) {
+	f.origins.PurgeInstances()
+	f.origins.Reset()
+	f.origins.SetGlobalLabels(data.GetGlobalLabels())
+
+	saved := data.GetMetric(bandwidthSaved)
+	if saved == nil {
+		saved, _ = data.NewMetricFloat64(bandwidthSaved)
+	}
+	requestedMetric := data.GetMetric(requested)
+	retrievedMetric := data.GetMetric(retrieved)
+
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		o, ok := origins[instance.GetLabel("svm")+"#"+instance.GetLabel("volume")]
+		if !ok {
+			instance.SetExportable(false)
+			continue
+		}
+		instance.SetLabel(originCluster, o.cluster)
+		instance.SetLabel(originSvm, o.svm)
+		instance.SetLabel(originVolume, o.volume)
+
+		if requestedMetric == nil || retrievedMetric == nil {
+			continue
+		}
+		req, ok1 := requestedMetric.GetValueFloat64(instance)
+		ret, ok2 := retrievedMetric.GetValueFloat64(instance)
+		if !ok1 || !ok2 {
+			continue
+		}
+		_ = saved.SetValueFloat64(instance, max(req-ret, 0)*blockSize)
+
+		key := o.cluster + "#" + o.svm + "#" + o.volume
+		originInstance := f.origins.GetInstance(key)
+		if originInstance == nil {
+			var err error
+			if originInstance, err = f.origins.NewInstance(key); err != nil {
+				f.Logger.Error().Err(err).Str("key", key).Msg("Failed to add origin instance")
+				continue
+			}
+			originInstance.SetLabel(originCluster, o.cluster)
+			originInstance.SetLabel(originSvm, o.svm)
+			originInstance.SetLabel(originVolume, o.volume)
+		}
+		_ = f.origins.GetMetric(requested).AddValueFloat64(originInstance, req)
+		_ = f.origins.GetMetric(retrieved).AddValueFloat64(originInstance, ret)
+		_ = f.origins.GetMetric(bandwidthSaved).AddValueFloat64(originInstance, max(req-ret, 0)*blockSize)
+		_ = f.origins.GetMetric(caches).AddValueFloat64(originInstance, 1)
+	}
+
+	hit := f.origins.GetMetric(hitPercent)
+	for _, originInstance := range f.origins.GetInstances() {
+		req, _ := f.origins.GetMetric(requested).GetValueFloat64(originInstance)
+		ret, _ := f.origins.GetMetric(retrieved).GetValueFloat64(originInstance)
+		if req > 0 {
+			_ = hit.SetValueFloat64(originInstance, 100*max(req-ret, 0)/req)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package flexcache
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/tidwall/gjson"
+	"testing"
+)
+
+func newFlexCache(t *testing.T) *FlexCache {
+	t.Helper()
+	params := node.NewS("FlexCache")
+	parentParams := node.NewS("parent")
+	parentParams.NewChildS("export_options", "").NewChildS("instance_keys", "").NewChildS("", "volume")
+	f := &FlexCache{AbstractPlugin: plugin.New("RestPerf", nil, params, parentParams, "flexcache", nil)}
+	if err := f.initOrigins(); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFlexCache_Update(t *testing.T) {
+	f := newFlexCache(t)
+
+	records := gjson.Parse(`[
+		{"name": "cache1", "svm": {"name": "svm1"}, "origins": [{"cluster": {"name": "c2"}, "svm": {"name": "osvm"}, "volume": {"name": "vol1"}}]},
+		{"name": "cache2", "svm": {"name": "svm2"}, "origins": [{"cluster": {"name": "c2"}, "svm": {"name": "osvm"}, "volume": {"name": "vol1"}}]}
+	]`).Array()
+
+	data := matrix.New("FlexCache", "flexcache", "flexcache")
+	req, _ := data.NewMetricFloat64(requested)
+	ret, _ := data.NewMetricFloat64(retrieved)
+	for _, v := range []struct {
+		svm, volume string
+		req, ret    float64
+	}{
+		{"svm1", "cache1", 100, 20},
+		{"svm2", "cache2", 100, 40},
+		{"svm1", "vol_not_a_cache", 100, 100},
+	} {
+		instance, _ := data.NewInstance(v.svm + v.volume)
+		instance.SetLabel("svm", v.svm)
+		instance.SetLabel("volume", v.volume)
+		_ = req.SetValueFloat64(instance, v.req)
+		_ = ret.SetValueFloat64(instance, v.ret)
+	}
+
+	f.update(data, originsOf(records))
+
+	if data.GetInstance("svm1vol_not_a_cache").IsExportable() {
+		t.Errorf("volume that is not a cache should not be exported")
+	}
+	cache1 := data.GetInstance("svm1cache1")
+	if got := cache1.GetLabel(originVolume); got != "vol1" {
+		t.Errorf("origin_volume got=%s, want=vol1", got)
+	}
+	if got, _ := data.GetMetric(bandwidthSaved).GetValueFloat64(cache1); got != 80*blockSize {
+		t.Errorf("bandwidth_saved got=%f, want=%d", got, 80*blockSize)
+	}
+
+	origin := f.origins.GetInstance("c2#osvm#vol1")
+	if origin == nil {
+		t.Fatalf("origin instance not found")
+	}
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metric: requested, want: 200},
+		{metric: retrieved, want: 60},
+		{metric: bandwidthSaved, want: 140 * blockSize},
+		{metric: hitPercent, want: 70},
+		{metric: caches, want: 2},
+	}
+	for _, tt := range tests {
+		if got, _ := f.origins.GetMetric(tt.metric).GetValueFloat64(origin); got != tt.want {
+			t.Errorf("%s got=%f, want=%f", tt.metric, got, tt.want)
+		}
+	}
+
+	if f.ParentParams.GetChildS("export_options").GetChildS("instance_keys").GetChildByContent(originVolume) == nil {
+		t.Errorf("origin_volume should be an instance key of caches")
+	}
+}
@@ -0,0 +1,106 @@
+// Package lunhost adds the igroups and initiators each LUN is mapped to as labels of the LUN, so the latency of
+// the LUNs of a host can be charted without joins. ONTAP has no host in the counters of LUNs, so the LUN maps and
+// igroups are collected with config polls, every schedule of the plugin
+package lunhost
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"strconv"
+	"time"
+)
+
+type LunHost struct {
+	*plugin.AbstractPlugin
+	client     *rest.Client
+	currentVal int
+	initiators bool
+	igroups    collectors.LunIgroups
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &LunHost{AbstractPlugin: p}
+}
+
+func (l *LunHost) Init() error {
+	var err error
+	if err := l.InitAbc(); err != nil {
+		return err
+	}
+
+	l.initiators = true
+	if b, err := strconv.ParseBool(l.Params.GetChildContentS("initiators")); err == nil {
+		l.initiators = b
+	}
+	collectors.AddLunHostKeys(l.ParentParams, l.initiators)
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if l.client, err = rest.New(conf.ZapiPoller(l.ParentParams), timeout, l.Auth); err != nil {
+		l.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := l.client.Init(5); err != nil {
+		return err
+	}
+
+	// Assigned the value to currentVal so that plugin would be invoked first time to populate cache.
+	l.currentVal = l.SetPluginInterval()
+	return nil
+}
+
+func (l *LunHost) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[l.Object]
+	l.client.Metadata.Reset()
+
+	if l.currentVal >= l.PluginInvocationRate {
+		l.currentVal = 0
+		if igroups, err := l.getIgroups(); err == nil {
+			l.igroups = igroups
+		}
+	}
+	l.currentVal++
+
+	collectors.SetLunHostLabels(data, l.igroups, l.initiators)
+	return nil, l.client.Metadata, nil
+}
+
+// getIgroups returns the igroups of each mapped LUN
+func (l *LunHost) getIgroups() (collectors.LunIgroups, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/protocols/san/igroups").
+		Fields([]string{"name", "svm.name", "protocol", "initiators.name"}).
+		Build()
+	records, err := rest.Fetch(l.client, href)
+	if err != nil {
+		l.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch igroups")
+		return nil, err
+	}
+	igroups := make(map[string]*collectors.Igroup, len(records))
+	for _, record := range records {
+		igroup := &collectors.Igroup{Name: record.Get("name").String(), Protocol: record.Get("protocol").String()}
+		for _, initiator := range record.Get("initiators.#.name").Array() {
+			igroup.Initiators = append(igroup.Initiators, initiator.String())
+		}
+		igroups[record.Get("svm.name").String()+":"+igroup.Name] = igroup
+	}
+
+	href = rest.NewHrefBuilder().
+		APIPath("api/protocols/san/lun-maps").
+		Fields([]string{"svm.name", "lun.name", "igroup.name"}).
+		Build()
+	records, err = rest.Fetch(l.client, href)
+	if err != nil {
+		l.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch lun maps")
+		return nil, err
+	}
+	lunIgroups := make(collectors.LunIgroups)
+	for _, record := range records {
+		svm := record.Get("svm.name").String()
+		lunIgroups.Map(svm, record.Get("lun.name").String(), igroups[svm+":"+record.Get("igroup.name").String()])
+	}
+	return lunIgroups, nil
+}
@@ -0,0 +1,297 @@
+// Package namespace rolls the performance counters of NVMe namespaces up to their subsystems and to the hosts
+// of their subsystems. ONTAP has no counter tables for subsystems and hosts, so the subsystem of each namespace and
+// the hosts of each subsystem are collected with config polls, every schedule of the plugin
+package namespace
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Namespace struct {
+	*plugin.AbstractPlugin
+	client       *rest.Client
+	currentVal   int
+	perSubsystem bool
+	perHost      bool
+	subsystems   map[string]string           // svm:path of namespace => subsystem
+	hosts        map[string][]*subsystemHost // svm:subsystem => hosts
+}
+
+// subsystemHost is a host of a subsystem
+type subsystemHost struct {
+	nqn   string
+	wwpns []string // WWPNs of the FC connections of the host, empty when not connected with FC
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &Namespace{AbstractPlugin: p}
+}
+
+func (n *Namespace) Init() error {
+	var err error
+	if err := n.InitAbc(); err != nil {
+		return err
+	}
+
+	n.perSubsystem = true
+	if b, err := strconv.ParseBool(n.Params.GetChildContentS("metricsPerSubsystem")); err == nil {
+		n.perSubsystem = b
+	}
+	n.perHost = collectors.ReadPluginKey(n.Params, "metricsPerHost")
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if n.client, err = rest.New(conf.ZapiPoller(n.ParentParams), timeout, n.Auth); err != nil {
+		n.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := n.client.Init(5); err != nil {
+		return err
+	}
+
+	// Assigned the value to currentVal so that plugin would be invoked first time to populate cache.
+	n.currentVal = n.SetPluginInterval()
+	return nil
+}
+
+func (n *Namespace) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[n.Object]
+	n.client.Metadata.Reset()
+
+	if n.currentVal >= n.PluginInvocationRate {
+		n.currentVal = 0
+		if subsystems, err := n.getSubsystems(); err == nil {
+			n.subsystems = subsystems
+		}
+		if n.perHost {
+			if hosts, err := n.getHosts(); err == nil {
+				n.hosts = hosts
+			}
+		}
+	}
+	n.currentVal++
+
+	for _, instance := range data.GetInstances() {
+		instance.SetLabel("subsystem", n.subsystems[instance.GetLabel("svm")+":"+instance.GetLabel("path")])
+	}
+
+	var output []*matrix.Matrix
+	if n.perSubsystem {
+		output = append(output, rollup(data, "nvme_subsystem", []string{"svm", "subsystem"}, func(svm, subsystem string) [][]string {
+			return [][]string{{svm, subsystem}}
+		}))
+	}
+	if n.perHost {
+		host := rollup(data, "nvme_host", []string{"svm", "host_nqn"}, func(svm, subsystem string) [][]string {
+			var groups [][]string
+			for _, h := range n.hosts[svm+":"+subsystem] {
+				groups = append(groups, []string{svm, h.nqn})
+			}
+			return groups
+		})
+		n.addWwpns(host)
+		output = append(output, host)
+	}
+	return output, n.client.Metadata, nil
+}
+
+// getSubsystems returns the subsystem of each mapped namespace, by svm:path
+func (n *Namespace) getSubsystems() (map[string]string, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/namespaces").
+		Fields([]string{"name", "svm.name", "subsystem_map.subsystem.name"}).
+		Build()
+	records, err := rest.Fetch(n.client, href)
+	if err != nil {
+		n.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch namespaces")
+		return nil, err
+	}
+	subsystems := make(map[string]string, len(records))
+	for _, record := range records {
+		if subsystem := record.Get("subsystem_map.subsystem.name").String(); subsystem != "" {
+			subsystems[record.Get("svm.name").String()+":"+record.Get("name").String()] = subsystem
+		}
+	}
+	return subsystems, nil
+}
+
+// getHosts returns the hosts of each subsystem, by svm:subsystem, with the WWPNs of their FC connections
+func (n *Namespace) getHosts() (map[string][]*subsystemHost, error) {
+	href := rest.NewHrefBuilder().
+		APIPath("api/protocols/nvme/subsystems").
+		Fields([]string{"name", "svm.name", "hosts.nqn"}).
+		Build()
+	records, err := rest.Fetch(n.client, href)
+	if err != nil {
+		n.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch subsystems")
+		return nil, err
+	}
+	hosts := make(map[string][]*subsystemHost, len(records))
+	byNqn := make(map[string]*subsystemHost)
+	for _, record := range records {
+		key := record.Get("svm.name").String() + ":" + record.Get("name").String()
+		for _, nqn := range record.Get("hosts.#.nqn").Array() {
+			h := &subsystemHost{nqn: nqn.String()}
+			hosts[key] = append(hosts[key], h)
+			byNqn[key+":"+h.nqn] = h
+		}
+	}
+
+	// the WWPNs of hosts are only known for connected hosts
+	href = rest.NewHrefBuilder().
+		APIPath("api/protocols/nvme/subsystem-controllers").
+		Fields([]string{"svm.name", "subsystem.name", "host.nqn", "host.transport_address"}).
+		Build()
+	records, err = rest.Fetch(n.client, href)
+	if err != nil {
+		n.Logger.Warn().Err(err).Str("href", href).Msg("Failed to fetch subsystem controllers, hosts have no WWPN")
+		return hosts, nil
+	}
+	for _, record := range records {
+		h := byNqn[record.Get("svm.name").String()+":"+record.Get("subsystem.name").String()+":"+record.Get("host.nqn").String()]
+		if h == nil {
+			continue
+		}
+		if wwpn := wwpnOf(record.Get("host.transport_address").String()); wwpn != "" && !slices.Contains(h.wwpns, wwpn) {
+			h.wwpns = append(h.wwpns, wwpn)
+		}
+	}
+	return hosts, nil
+}
+
+// wwpnOf returns the WWPN of an FC transport address, e.g. 20:00:00:10:9b:1c:2d:3e for
+// nn-0x2000000000000000:pn-0x200000109b1c2d3e. It returns an empty string for other transports
+func wwpnOf(transportAddress string) string {
+	for _, part := range strings.Split(transportAddress, ":") {
+		hex, ok := strings.CutPrefix(part, "pn-0x")
+		if !ok || len(hex) != 16 {
+			continue
+		}
+		octets := make([]string, 0, 8)
+		for i := 0; i < len(hex); i += 2 {
+			octets = append(octets, strings.ToLower(hex[i:i+2]))
+		}
+		return strings.Join(octets, ":")
+	}
+	return ""
+}
+
+// addWwpns adds the WWPNs of each host as the wwpn label
+func (n *Namespace) addWwpns(host *matrix.Matrix) {
+	wwpns := make(map[string][]string)
+	for key, hosts := range n.hosts {
+		svm, _, _ := strings.Cut(key, ":")
+		for _, h := range hosts {
+			k := svm + ":" + h.nqn
+			for _, w := range h.wwpns {
+				if !slices.Contains(wwpns[k], w) {
+					wwpns[k] = append(wwpns[k], w)
+				}
+			}
+		}
+	}
+	for key, instance := range host.GetInstances() {
+		w := wwpns[key]
+		slices.Sort(w)
+		instance.SetLabel("wwpn", strings.Join(w, ","))
+	}
+}
+
+// rollup aggregates the namespaces of data to the groups of each namespace, given its svm and subsystem.
+// The labels of the groups are named by keys. Counters are summed, averages are weighted by their denominator,
+// e.g. avg_read_latency by read_ops, and percentages are averaged. Namespaces without subsystem are skipped
+func rollup(data *matrix.Matrix, object string, keys []string, groupsOf func(svm, subsystem string) [][]string) *matrix.Matrix {
+	result := matrix.New(data.UUID+".Namespace."+object, object, object)
+	result.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, k := range keys {
+		instanceKeys.NewChildS("", k)
+	}
+	if object == "nvme_host" {
+		exportOptions.NewChildS("instance_labels", "").NewChildS("", "wwpn")
+	}
+	result.SetExportOptions(exportOptions)
+
+	for key, m := range data.GetMetrics() {
+		if !m.IsExportable() || m.IsArray() {
+			continue
+		}
+		rm, err := result.NewMetricFloat64(key, m.GetName())
+		if err != nil {
+			continue
+		}
+		rm.SetProperty(m.GetProperty())
+		rm.SetComment(m.GetComment())
+		rm.SetUnit(m.GetUnit())
+	}
+
+	// weights of the averaged metrics, by instance and metric
+	weights := make(map[string]map[string]float64)
+	for _, i := range data.GetInstances() {
+		svm := i.GetLabel("svm")
+		subsystem := i.GetLabel("subsystem")
+		if svm == "" || subsystem == "" {
+			continue
+		}
+		for _, group := range groupsOf(svm, subsystem) {
+			instanceKey := strings.Join(group, ":")
+			r := result.GetInstance(instanceKey)
+			if r == nil {
+				r, _ = result.NewInstance(instanceKey)
+				for k, label := range keys {
+					r.SetLabel(label, group[k])
+				}
+				weights[instanceKey] = make(map[string]float64)
+			}
+			w := weights[instanceKey]
+			for key, rm := range result.GetMetrics() {
+				value, ok := data.GetMetric(key).GetValueFloat64(i)
+				if !ok {
+					continue
+				}
+				total, _ := rm.GetValueFloat64(r)
+				switch rm.GetProperty() {
+				case "average":
+					weight := 1.0
+					if denominator := data.GetMetric(rm.GetComment()); denominator != nil {
+						weight, _ = denominator.GetValueFloat64(i)
+					}
+					_ = rm.SetValueFloat64(r, total+value*weight)
+					w[key] += weight
+				case "percent":
+					_ = rm.SetValueFloat64(r, total+value)
+					w[key]++
+				default:
+					_ = rm.SetValueFloat64(r, total+value)
+				}
+			}
+		}
+	}
+
+	for instanceKey, r := range result.GetInstances() {
+		for key, weight := range weights[instanceKey] {
+			rm := result.GetMetric(key)
+			total, ok := rm.GetValueFloat64(r)
+			if !ok {
+				continue
+			}
+			if weight == 0 {
+				// no operations, so there is no latency
+				_ = rm.SetValueFloat64(r, 0)
+				continue
+			}
+			_ = rm.SetValueFloat64(r, total/weight)
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package namespace
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func Test_wwpnOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{name: "fc", address: "nn-0x2000000000000000:pn-0x200000109B1C2D3E", want: "20:00:00:10:9b:1c:2d:3e"},
+		{name: "tcp", address: "10.193.48.11", want: ""},
+		{name: "empty", address: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wwpnOf(tt.address); got != tt.want {
+				t.Errorf("wwpnOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newNamespaces returns namespaces with read_ops and avg_read_latency, as svm, subsystem, read_ops, latency
+func newNamespaces(namespaces ...[4]any) *matrix.Matrix {
+	data := matrix.New("RestPerf.Namespace", "namespace", "namespace")
+	ops, _ := data.NewMetricFloat64("read_ops")
+	ops.SetProperty("rate")
+	latency, _ := data.NewMetricFloat64("average_read_latency", "avg_read_latency")
+	latency.SetProperty("average")
+	latency.SetComment("read_ops")
+	for i, ns := range namespaces {
+		instance, _ := data.NewInstance(string(rune('a' + i)))
+		instance.SetLabel("svm", ns[0].(string))
+		instance.SetLabel("subsystem", ns[1].(string))
+		_ = ops.SetValueFloat64(instance, ns[2].(float64))
+		_ = latency.SetValueFloat64(instance, ns[3].(float64))
+	}
+	return data
+}
+
+func TestRollup(t *testing.T) {
+	data := newNamespaces(
+		[4]any{"svm1", "ss1", 100.0, 200.0},
+		[4]any{"svm1", "ss1", 300.0, 400.0},
+		[4]any{"svm1", "ss2", 0.0, 0.0},
+		[4]any{"svm1", "", 50.0, 10.0},
+	)
+	hosts := map[string][]string{
+		"svm1:ss1": {"nqn.h1", "nqn.h2"},
+		"svm1:ss2": {"nqn.h2"},
+	}
+
+	tests := []struct {
+		name     string
+		object   string
+		keys     []string
+		groupsOf func(svm, subsystem string) [][]string
+		want     map[string][2]float64 // read_ops, avg_read_latency by instance
+	}{
+		{
+			name:   "subsystem",
+			object: "nvme_subsystem",
+			keys:   []string{"svm", "subsystem"},
+			groupsOf: func(svm, subsystem string) [][]string {
+				return [][]string{{svm, subsystem}}
+			},
+			want: map[string][2]float64{"svm1:ss1": {400, 350}, "svm1:ss2": {0, 0}},
+		},
+		{
+			name:   "host",
+			object: "nvme_host",
+			keys:   []string{"svm", "host_nqn"},
+			groupsOf: func(svm, subsystem string) [][]string {
+				var groups [][]string
+				for _, h := range hosts[svm+":"+subsystem] {
+					groups = append(groups, []string{svm, h})
+				}
+				return groups
+			},
+			want: map[string][2]float64{"svm1:nqn.h1": {400, 350}, "svm1:nqn.h2": {400, 350}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rollup(data, tt.object, tt.keys, tt.groupsOf)
+			if len(got.GetInstances()) != len(tt.want) {
+				t.Fatalf("rollup() instances got=%d, want=%d", len(got.GetInstances()), len(tt.want))
+			}
+			for key, want := range tt.want {
+				instance := got.GetInstance(key)
+				if instance == nil {
+					t.Fatalf("rollup() missing instance %s", key)
+				}
+				ops, _ := got.GetMetric("read_ops").GetValueFloat64(instance)
+				latency, _ := got.GetMetric("average_read_latency").GetValueFloat64(instance)
+				if ops != want[0] || latency != want[1] {
+					t.Errorf("rollup() %s got ops=%v latency=%v, want ops=%v latency=%v", key, ops, latency, want[0], want[1])
+				}
+				if instance.GetLabel(tt.keys[1]) == "" {
+					t.Errorf("rollup() %s missing label %s", key, tt.keys[1])
+				}
+			}
+		})
+	}
+}
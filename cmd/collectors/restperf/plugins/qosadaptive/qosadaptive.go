@@ -0,0 +1,125 @@
+// Package qosadaptive exports the expected and peak IOPS of the workloads of adaptive QoS policies, and the IOPS
+// each workload has left before its peak. ONTAP scales the IOPS of an adaptive policy with the space of each
+// volume, so the counters of a workload do not show how close it is to its limit. The policies and the space of
+// the volumes are collected with config polls, every schedule of the plugin
+package qosadaptive
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+type QosAdaptive struct {
+	*plugin.AbstractPlugin
+	client     *rest.Client
+	currentVal int
+	workloads  *matrix.Matrix
+	policies   collectors.AdaptivePolicies
+	volumes    map[string]collectors.VolumeSpace
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &QosAdaptive{AbstractPlugin: p}
+}
+
+func (q *QosAdaptive) Init() error {
+	var err error
+	if err := q.InitAbc(); err != nil {
+		return err
+	}
+	if q.workloads, err = collectors.NewQosAdaptiveMatrix(q.Parent); err != nil {
+		return err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if q.client, err = rest.New(conf.ZapiPoller(q.ParentParams), timeout, q.Auth); err != nil {
+		q.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := q.client.Init(5); err != nil {
+		return err
+	}
+
+	// Assigned the value to currentVal so that plugin would be invoked first time to populate cache.
+	q.currentVal = q.SetPluginInterval()
+	return nil
+}
+
+func (q *QosAdaptive) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[q.Object]
+	q.client.Metadata.Reset()
+
+	if q.currentVal >= q.PluginInvocationRate {
+		q.currentVal = 0
+		q.update()
+	}
+	q.currentVal++
+
+	collectors.SetQosAdaptiveHeadroom(q.workloads, data, q.policies, q.volumes)
+	return []*matrix.Matrix{q.workloads}, q.client.Metadata, nil
+}
+
+// update collects the adaptive policies and the space of the volumes. The last ones are kept when a poll fails
+func (q *QosAdaptive) update() {
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/qos/policies").
+		Fields([]string{"name", "svm.name", "adaptive"}).
+		Build()
+	records, err := rest.Fetch(q.client, href)
+	if err != nil {
+		q.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch qos policies")
+		return
+	}
+	policies := policiesOf(records)
+
+	href = rest.NewHrefBuilder().
+		APIPath("api/storage/volumes").
+		Fields([]string{"name", "svm.name", "space.size", "space.used"}).
+		Build()
+	records, err = rest.Fetch(q.client, href)
+	if err != nil {
+		q.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch volumes")
+		return
+	}
+	q.policies = policies
+	q.volumes = volumesOf(records)
+}
+
+// policiesOf returns the adaptive policies of records. Fixed policies have no adaptive section
+func policiesOf(records []gjson.Result) collectors.AdaptivePolicies {
+	policies := make(collectors.AdaptivePolicies)
+	for _, record := range records {
+		adaptive := record.Get("adaptive")
+		if !adaptive.Exists() {
+			continue
+		}
+		// the expected IOPS scale with the allocated space, and the peak IOPS with the used space, by default
+		peakAllocation := adaptive.Get("peak_iops_allocation").String()
+		policies.Add(record.Get("svm.name").String(), record.Get("name").String(), collectors.AdaptivePolicy{
+			ExpectedIOPS:    adaptive.Get("expected_iops").Float(),
+			PeakIOPS:        adaptive.Get("peak_iops").Float(),
+			AbsoluteMinIOPS: adaptive.Get("absolute_min_iops").Float(),
+			ExpectedUsed:    collectors.IsUsedSpace(adaptive.Get("expected_iops_allocation").String()),
+			PeakUsed:        peakAllocation == "" || collectors.IsUsedSpace(peakAllocation),
+		})
+	}
+	return policies
+}
+
+// volumesOf returns the space of the volumes of records, by svm and volume name
+func volumesOf(records []gjson.Result) map[string]collectors.VolumeSpace {
+	volumes := make(map[string]collectors.VolumeSpace, len(records))
+	for _, record := range records {
+		volumes[record.Get("svm.name").String()+":"+record.Get("name").String()] = collectors.VolumeSpace{
+			Size: record.Get("space.size").Float(),
+			Used: record.Get("space.used").Float(),
+		}
+	}
+	return volumes
+}
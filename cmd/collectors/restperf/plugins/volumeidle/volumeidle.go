@@ -0,0 +1,30 @@
+package volumeidle
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+)
+
+type VolumeIdle struct {
+	*plugin.AbstractPlugin
+	idle *collectors.IdleVolumes
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &VolumeIdle{AbstractPlugin: p}
+}
+
+func (v *VolumeIdle) Init() error {
+	err := v.InitAbc()
+	if err != nil {
+		return err
+	}
+	v.idle, err = collectors.NewPluginIdleVolumes(v.AbstractPlugin)
+	return err
+}
+
+func (v *VolumeIdle) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	return []*matrix.Matrix{v.idle.Run(dataMap[v.Object])}, nil, nil
+}
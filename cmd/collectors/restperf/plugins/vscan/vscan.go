@@ -3,40 +3,131 @@ package vscan
 import (
 	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/util"
-	"strconv"
 	"strings"
+	"time"
 )
 
 type Vscan struct {
 	*plugin.AbstractPlugin
+	client  *rest.Client
+	options collectors.VscanOptions
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
 	return &Vscan{AbstractPlugin: p}
 }
 
+func (v *Vscan) Init() error {
+	var err error
+	if err := v.InitAbc(); err != nil {
+		return err
+	}
+
+	v.options = collectors.ReadVscanOptions(v.Params)
+	v.Logger.Debug().
+		Bool("isPerScanner", v.options.PerScanner).
+		Bool("isPerPool", v.options.PerPool).
+		Bool("isPerSvm", v.options.PerSvm).
+		Bool("scannerHealth", v.options.ScannerHealth).
+		Msg("Vscan options")
+
+	// scanner pools and connection status are not performance counters, and need their own queries
+	if !v.options.NeedsPools() {
+		return nil
+	}
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if v.client, err = rest.New(conf.ZapiPoller(v.ParentParams), timeout, v.Auth); err != nil {
+		v.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return v.client.Init(5)
+}
+
 func (v *Vscan) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
 	data := dataMap[v.Object]
-	// defaults plugin options
-	isPerScanner := true
-
-	if s := v.Params.GetChildContentS("metricsPerScanner"); s != "" {
-		if parseBool, err := strconv.ParseBool(s); err == nil {
-			isPerScanner = parseBool
-		} else {
-			v.Logger.Error().Err(err).Msg("Failed to parse metricsPerScanner")
+
+	v.addSvmAndScannerLabels(data)
+
+	var (
+		output   []*matrix.Matrix
+		metadata *util.Metadata
+	)
+	if v.options.HasRollups() {
+		pools := make(collectors.VscanPools)
+		var connections []collectors.VscanConnection
+		if v.client != nil {
+			v.client.Metadata.Reset()
+			pools = v.getPools()
+			if v.options.ScannerHealth {
+				connections = v.getConnections()
+			}
+			metadata = v.client.Metadata
 		}
+		output = append(output, collectors.VscanRollups(data, pools, connections, v.options, v.Logger)...)
 	}
-	v.Logger.Debug().Bool("isPerScanner", isPerScanner).Msg("Vscan options")
 
-	v.addSvmAndScannerLabels(data)
-	if !isPerScanner {
-		return nil, nil, nil
+	if v.options.PerScanner {
+		perScanner, _, err := v.aggregatePerScanner(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		output = append(output, perScanner...)
+	}
+	return output, metadata, nil
+}
+
+// getPools returns the scanner pools of the scanners of each SVM
+func (v *Vscan) getPools() collectors.VscanPools {
+	pools := make(collectors.VscanPools)
+
+	href := rest.NewHrefBuilder().
+		APIPath("api/protocols/vscan").
+		Fields([]string{"svm.name", "scanner_pools.name", "scanner_pools.servers"}).
+		Build()
+	records, err := rest.Fetch(v.client, href)
+	if err != nil {
+		v.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch scanner pools")
+		return pools
+	}
+	for _, record := range records {
+		svm := record.Get("svm.name").String()
+		for _, pool := range record.Get("scanner_pools").Array() {
+			var scanners []string
+			for _, server := range pool.Get("servers").Array() {
+				scanners = append(scanners, server.String())
+			}
+			pools.Add(svm, pool.Get("name").String(), scanners)
+		}
 	}
+	return pools
+}
 
-	return v.aggregatePerScanner(data)
+// getConnections returns the status of the connections between the nodes of each SVM and their scanners
+func (v *Vscan) getConnections() []collectors.VscanConnection {
+	href := rest.NewHrefBuilder().
+		APIPath("api/protocols/vscan/server-status").
+		Fields([]string{"svm.name", "node.name", "ip", "state", "disconnected_reason"}).
+		Build()
+	records, err := rest.Fetch(v.client, href)
+	if err != nil {
+		v.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch scanner connection status")
+		return nil
+	}
+	connections := make([]collectors.VscanConnection, 0, len(records))
+	for _, record := range records {
+		connections = append(connections, collectors.VscanConnection{
+			Svm:     record.Get("svm.name").String(),
+			Node:    record.Get("node.name").String(),
+			Scanner: record.Get("ip").String(),
+			State:   strings.ToLower(record.Get("state").String()),
+			Reason:  record.Get("disconnected_reason").String(),
+		})
+	}
+	return connections
 }
 
 func (v *Vscan) addSvmAndScannerLabels(data *matrix.Matrix) {
@@ -0,0 +1,127 @@
+package restperf
+
+import (
+	rest2 "github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strings"
+)
+
+// ReloadCounters re-reads the counters of the template, and replaces the metrics of the object without resetting
+// its cache: the instances and the raw values of the previous poll of unchanged counters are kept, so their rates are
+// cooked by the next poll. Added counters are cooked from the second poll that collects them, like after a restart.
+// Removed counters, and counters whose display name changed, are removed from the cache.
+// Only the counters of the template are reloaded, the other sections, e.g. plugins, need a restart of the poller
+func (r *RestPerf) ReloadCounters() error {
+	if isWorkloadDetailObject(r.Prop.Query) {
+		return errs.New(errs.ErrConfig, "the counters of workload detail objects can not be reloaded")
+	}
+	jitter := r.Params.GetChildContentS("jitter")
+	template, path, err := r.ImportSubTemplate("", rest2.TemplateFn(r.Params, r.Object), jitter, r.Client.Cluster().Version)
+	if err != nil {
+		return err
+	}
+	counters := template.GetChildS("counters")
+	if counters == nil {
+		return errs.New(errs.ErrMissingParam, "counters")
+	}
+	return r.reloadCounters(counters, path, func() error {
+		_, err := r.PollCounter()
+		return err
+	})
+}
+
+// reloadCounters replaces the counters of the template with counters, read from path. pollCounter polls the counter
+// schema of the new counters
+func (r *RestPerf) reloadCounters(counters *node.Node, path string, pollCounter func() error) error {
+	oldCounters := r.Params.GetChildS("counters")
+	if oldCounters != nil && oldCounters.Print(0) == counters.Print(0) {
+		return nil
+	}
+
+	oldProp := r.Prop
+	oldArchived := r.archivedMetrics
+	restore := func() {
+		r.Prop = oldProp
+		r.archivedMetrics = oldArchived
+		r.Params.PopChildS("counters")
+		if oldCounters != nil {
+			r.Params.AddChild(oldCounters)
+		}
+	}
+
+	r.Params.PopChildS("counters")
+	r.Params.AddChild(counters)
+	r.InitProp()
+	r.Prop.Object = oldProp.Object
+	r.Prop.Query = oldProp.Query
+	r.Prop.TemplatePath = path
+	r.Prop.ReturnTimeOut = oldProp.ReturnTimeOut
+	r.Prop.IsPublic = oldProp.IsPublic
+	r.ParseRestCounters(counters, r.Prop)
+
+	// the counter schema decides which counters exist and adds their denominators
+	r.archivedMetrics = make(map[string]*rest2.Metric)
+	if err := pollCounter(); err != nil {
+		restore()
+		return err
+	}
+
+	added, removed := diffMetrics(oldProp.Metrics, r.Prop.Metrics)
+	r.removeCounters(removed)
+
+	r.Logger.Info().
+		Str("path", path).
+		Strs("added", added).
+		Strs("removed", removed).
+		Int("numMetrics", len(r.Prop.Metrics)).
+		Msg("Reloaded counters")
+	return nil
+}
+
+// diffMetrics returns the metrics of next that are not in prev, and the metrics of prev that are not in next.
+// A metric whose display name or exportability changed is in both, since its cache can not be kept
+func diffMetrics(prev map[string]*rest2.Metric, next map[string]*rest2.Metric) ([]string, []string) {
+	var added, removed []string
+	for name, m := range next {
+		p, ok := prev[name]
+		if !ok || p.Label != m.Label || p.Exportable != m.Exportable {
+			added = append(added, name)
+		}
+	}
+	for name, p := range prev {
+		m, ok := next[name]
+		if !ok || p.Label != m.Label || p.Exportable != m.Exportable {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+// removeCounters removes the metrics of the counters from the cache, including the elements of array counters.
+// The counters that are no longer collected are forgotten
+func (r *RestPerf) removeCounters(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+		if _, ok := r.Prop.Metrics[name]; !ok {
+			delete(r.perfProp.counterInfo, name)
+		}
+	}
+	mat := r.Matrix[r.Object]
+	for key, metric := range mat.GetMetrics() {
+		name := key
+		if metric.IsArray() {
+			name, _, _ = strings.Cut(key, arrayKeyToken)
+		}
+		if removed[name] {
+			mat.RemoveMetric(key)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package restperf
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	rest2 "github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+	"time"
+)
+
+func TestReloadCounters(t *testing.T) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+	r := newRestPerf("Volume", "volume.yaml")
+	counters := jsonToPerfRecords("testdata/volume-counters-1.json")
+	pollCounter := func() error {
+		_, err := r.pollCounter(counters[0].Records.Array(), 0)
+		return err
+	}
+	if err := pollCounter(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.pollInstance(jsonToPerfRecords("testdata/volume-poll-instance.json")[0].Records.Array(), 0); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	pollData := jsonToPerfRecords("testdata/volume-poll-1.json")
+	pollData[0].Timestamp = now.UnixNano()
+	if _, err := r.pollData(now, pollData); err != nil {
+		t.Fatal(err)
+	}
+
+	// bytes_written is removed, and total_ops renamed
+	template, err := tree.LoadYaml([]byte(`
+counters:
+  - ^^uuid
+  - ^name                  => volume
+  - ^node.name             => node
+  - ^parent_aggregate      => aggr
+  - ^svm.name              => svm
+  - abc
+  - average_latency        => avg_latency
+  - bytes_read             => read_data
+  - other_latency
+  - read_latency
+  - total_ops              => ops
+  - total_other_ops        => other_ops
+  - total_read_ops         => read_ops
+  - total_write_ops        => write_ops
+  - write_latency
+  - nfs.misaligned_writes_histogram => misaligned_writes_histogram
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed reload keeps the current counters
+	oldProp := r.Prop
+	oldCounters := r.Params.GetChildS("counters").Print(0)
+	if err := r.reloadCounters(template.GetChildS("counters"), "next.yaml", func() error { return errors.New("boom") }); err == nil {
+		t.Fatal("reloadCounters() want error")
+	}
+	if r.Prop != oldProp || r.Params.GetChildS("counters").Print(0) != oldCounters {
+		t.Fatal("reloadCounters() failed reload replaced the counters")
+	}
+
+	if err := r.reloadCounters(template.GetChildS("counters"), "next.yaml", pollCounter); err != nil {
+		t.Fatal(err)
+	}
+	mat := r.Matrix["Volume"]
+	if mat.GetMetric("bytes_written") != nil || mat.GetMetric("total_ops") != nil {
+		t.Error("reloadCounters() kept the metrics of removed counters")
+	}
+	if _, ok := r.perfProp.counterInfo["bytes_written"]; ok {
+		t.Error("reloadCounters() kept the counter info of bytes_written")
+	}
+	if r.perfProp.isCacheEmpty {
+		t.Error("reloadCounters() emptied the cache")
+	}
+
+	future := now.Add(15 * time.Minute)
+	pollData = jsonToPerfRecords("testdata/volume-poll-2.json")
+	pollData[0].Timestamp = future.UnixNano()
+	got, err := r.pollData(future, pollData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got["Volume"]
+	var sum int64
+	for _, instance := range m.GetInstances() {
+		val, recorded := m.GetMetric("bytes_read").GetValueInt64(instance)
+		if !recorded {
+			t.Errorf("bytes_read is not cooked after the reload")
+		}
+		sum += val
+	}
+	if sum != 26 {
+		t.Errorf("bytes_read sum got=%d, want=26", sum)
+	}
+	if m.GetMetric("bytes_written") != nil {
+		t.Error("pollData() collected the removed bytes_written")
+	}
+	ops := m.GetMetric("total_ops")
+	if ops == nil || ops.GetName() != "ops" {
+		t.Fatal("pollData() did not collect the renamed total_ops")
+	}
+	for _, instance := range m.GetInstances() {
+		if _, recorded := ops.GetValueFloat64(instance); recorded {
+			t.Error("total_ops is cooked without a previous poll")
+		}
+	}
+}
+
+func TestDiffMetrics(t *testing.T) {
+	prev := map[string]*rest2.Metric{
+		"kept":    {Label: "kept", Exportable: true},
+		"renamed": {Label: "renamed", Exportable: true},
+		"removed": {Label: "removed", Exportable: true},
+	}
+	next := map[string]*rest2.Metric{
+		"kept":    {Label: "kept", Exportable: true},
+		"renamed": {Label: "new_name", Exportable: true},
+		"added":   {Label: "added", Exportable: true},
+	}
+	added, removed := diffMetrics(prev, next)
+	if diff := cmp.Diff([]string{"added", "renamed"}, added); diff != "" {
+		t.Errorf("diffMetrics() added mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"removed", "renamed"}, removed); diff != "" {
+		t.Errorf("diffMetrics() removed mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -2,19 +2,27 @@ package restperf
 
 import (
 	"fmt"
+	"github.com/netapp/harvest/v2/cmd/collectors"
 	rest2 "github.com/netapp/harvest/v2/cmd/collectors/rest"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/disk"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/fabricpool"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/fcp"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/fcvi"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/flexcache"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/headroom"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/lunhost"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/namespace"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/nic"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/ontaps3"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/qosadaptive"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/volume"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/volumeidle"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/volumetag"
 	"github.com/netapp/harvest/v2/cmd/collectors/restperf/plugins/vscan"
 	"github.com/netapp/harvest/v2/cmd/poller/collector"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/bus"
 	"github.com/netapp/harvest/v2/pkg/dict"
 	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/matrix"
@@ -66,6 +74,7 @@ type RestPerf struct {
 	*rest2.Rest     // provides: AbstractCollector, Client, Object, Query, TemplateFn, TemplateType
 	perfProp        *perfProp
 	archivedMetrics map[string]*rest2.Metric // Keeps metric definitions that are not found in the counter schema. These metrics may be available in future ONTAP versions.
+	sharedOpsTime   time.Time                // collection time of the shared parent ops used last
 }
 
 type counter struct {
@@ -102,6 +111,12 @@ func (r *RestPerf) HarvestModule() plugin.ModuleInfo {
 }
 
 func (r *RestPerf) Init(a *collector.AbstractCollector) error {
+	return r.InitCollector(a, r)
+}
+
+// InitCollector initializes r and schedules the poll methods of c.
+// c is r, except when RestPerf is embedded by a collector that fetches counters differently, e.g. StatPerf
+func (r *RestPerf) InitCollector(a *collector.AbstractCollector, c collector.Collector) error {
 
 	var err error
 
@@ -124,7 +139,7 @@ func (r *RestPerf) Init(a *collector.AbstractCollector) error {
 
 	r.InitVars(a.Params)
 
-	if err := collector.Init(r); err != nil {
+	if err := collector.Init(c); err != nil {
 		return err
 	}
 
@@ -196,9 +211,10 @@ func (r *RestPerf) InitMatrix() error {
 		}
 	}
 
-	// Add metadata metric for skips/numPartials
+	// Add metadata metric for skips/numPartials/invalidValues
 	_, _ = r.Metadata.NewMetricUint64("skips")
 	_, _ = r.Metadata.NewMetricUint64("numPartials")
+	_, _ = r.Metadata.NewMetricUint64("invalidValues")
 	return nil
 }
 
@@ -269,15 +285,25 @@ func (r *RestPerf) PollCounter() (map[string]*matrix.Matrix, error) {
 		return nil, errs.New(errs.ErrConfig, "empty url")
 	}
 
-	apiT := time.Now()
 	r.Client.Metadata.Reset()
+	// the schemas of the table are the same for all the pollers of the cluster
+	cluster := r.Client.Cluster()
+	if records, ok := r.Client.Schemas().Get(cluster, r.Prop.Query); ok {
+		r.Logger.Debug().Str("table", r.Prop.Query).Msg("Using cached counter schemas")
+		return r.pollCounter(records, 0)
+	}
 
-	records, err = rest.Fetch(r.Client, href)
+	apiT := time.Now()
+	records, err = rest.FetchContext(r.Context(), r.Client, href)
 	if err != nil {
 		return r.handleError(err, href)
 	}
+	apiD := time.Since(apiT)
+	if err := r.Client.Schemas().Put(cluster, r.Prop.Query, records); err != nil {
+		r.Logger.Warn().Err(err).Str("table", r.Prop.Query).Msg("Unable to cache counter schemas")
+	}
 
-	return r.pollCounter(records, time.Since(apiT))
+	return r.pollCounter(records, apiD)
 }
 
 func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[string]*matrix.Matrix, error) {
@@ -299,6 +325,10 @@ func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[
 	}
 	seenMetrics := make(map[string]bool)
 
+	if err := r.translateCounters(counterSchema); err != nil {
+		return nil, err
+	}
+
 	// populate denominator metric to prop metrics
 	counterSchema.ForEach(func(_, c gjson.Result) bool {
 		if !c.IsObject() {
@@ -349,6 +379,7 @@ func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[
 		return true
 	})
 
+	var counters []string
 	counterSchema.ForEach(func(_, c gjson.Result) bool {
 
 		if !c.IsObject() {
@@ -357,6 +388,7 @@ func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[
 		}
 
 		name := strings.Clone(c.Get("name").String())
+		counters = append(counters, name)
 		if _, has := r.Prop.Metrics[name]; has {
 			seenMetrics[name] = true
 			if _, ok := r.perfProp.counterInfo[name]; !ok {
@@ -375,6 +407,7 @@ func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[
 
 		return true
 	})
+	r.CheckSchema("counter", counters)
 
 	for name, metric := range r.Prop.Metrics {
 		if !seenMetrics[name] {
@@ -409,11 +442,59 @@ func (r *RestPerf) pollCounter(records []gjson.Result, apiD time.Duration) (map[
 	_ = r.Metadata.LazySetValueInt64("parse_time", "counter", time.Since(parseT).Microseconds())
 	_ = r.Metadata.LazySetValueUint64("metrics", "counter", uint64(len(r.perfProp.counterInfo)))
 	_ = r.Metadata.LazySetValueUint64("bytesRx", "counter", r.Client.Metadata.BytesRx)
+	_ = r.Metadata.LazySetValueUint64("bytesRxWire", "counter", r.Client.Metadata.BytesRxWire)
 	_ = r.Metadata.LazySetValueUint64("numCalls", "counter", r.Client.Metadata.NumCalls)
 
 	return nil, nil
 }
 
+// translateCounters renames the template counters that are not in the counter schema to their name in the
+// ONTAP release of the cluster, see collectors.CounterRenames. The display names of the counters do not change
+func (r *RestPerf) translateCounters(counterSchema gjson.Result) error {
+	renames, err := collectors.CounterRenames(r.Params, path.Base(r.Prop.Query))
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	inSchema := make(map[string]bool)
+	counterSchema.ForEach(func(_, c gjson.Result) bool {
+		inSchema[c.Get("name").String()] = true
+		return true
+	})
+
+	clusterVersion := r.Client.Cluster().GetVersion()
+	for _, metrics := range []map[string]*rest2.Metric{r.Prop.Metrics, r.archivedMetrics} {
+		for name, metric := range metrics {
+			if inSchema[name] {
+				continue
+			}
+			translated, release, ok := collectors.TranslateCounter(renames, name, clusterVersion)
+			if !ok || !inSchema[translated] {
+				continue
+			}
+			if _, has := metrics[translated]; has {
+				continue
+			}
+			metric.Name = translated
+			metrics[translated] = metric
+			delete(metrics, name)
+			if display, has := r.Prop.Counters[name]; has {
+				r.Prop.Counters[translated] = display
+				delete(r.Prop.Counters, name)
+			}
+			r.Logger.Info().
+				Str("counter", name).
+				Str("translated", translated).
+				Str("release", release).
+				Str("clusterVersion", clusterVersion).
+				Msg("Translated renamed counter")
+		}
+	}
+	return nil
+}
+
 func parseProps(instanceData gjson.Result) map[string]gjson.Result {
 	var props = map[string]gjson.Result{
 		"id": gjson.Get(instanceData.String(), "id"),
@@ -703,8 +784,23 @@ func (r *RestPerf) PollData() (map[string]*matrix.Matrix, error) {
 	startTime = time.Now()
 	r.Client.Metadata.Reset()
 
-	dataQuery := path.Join(r.Prop.Query, "rows")
+	href := r.dataHref()
+
+	r.Logger.Debug().Str("href", href).Send()
+	if href == "" {
+		return nil, errs.New(errs.ErrConfig, "empty url")
+	}
+
+	err = rest.FetchRestPerfDataContext(r.Context(), r.Client, href, &perfRecords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch href=%s %w", href, err)
+	}
 
+	return r.pollData(startTime, perfRecords)
+}
+
+// dataHref returns the href of the rows of the object, with the counters of the template
+func (r *RestPerf) dataHref() string {
 	var filter []string
 	// Sort filters so that the href is deterministic
 	metrics := maps.Keys(r.Prop.Metrics)
@@ -712,23 +808,26 @@ func (r *RestPerf) PollData() (map[string]*matrix.Matrix, error) {
 
 	filter = append(filter, "counters.name="+strings.Join(metrics, "|"))
 
-	href := rest.NewHrefBuilder().
-		APIPath(dataQuery).
+	return rest.NewHrefBuilder().
+		APIPath(path.Join(r.Prop.Query, "rows")).
 		Fields([]string{"*"}).
 		Filter(filter).
 		ReturnTimeout(r.Prop.ReturnTimeOut).
 		Build()
+}
 
-	r.Logger.Debug().Str("href", href).Send()
-	if href == "" {
-		return nil, errs.New(errs.ErrConfig, "empty url")
-	}
+// ProcessCounters, ProcessInstances, and ProcessData cook records in the format of the counter table
+// endpoints. They are used by collectors that embed RestPerf and fetch the records from a different source
 
-	err = rest.FetchRestPerfData(r.Client, href, &perfRecords)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch href=%s %w", href, err)
-	}
+func (r *RestPerf) ProcessCounters(records []gjson.Result, apiD time.Duration) (map[string]*matrix.Matrix, error) {
+	return r.pollCounter(records, apiD)
+}
+
+func (r *RestPerf) ProcessInstances(records []gjson.Result, apiD time.Duration) (map[string]*matrix.Matrix, error) {
+	return r.pollInstance(records, apiD)
+}
 
+func (r *RestPerf) ProcessData(startTime time.Time, perfRecords []rest.PerfRecord) (map[string]*matrix.Matrix, error) {
 	return r.pollData(startTime, perfRecords)
 }
 
@@ -784,6 +883,7 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 	// clone matrix without numeric data
 	curMat = prevMat.Clone(matrix.With{Data: false, Metrics: true, Instances: true, ExportInstances: true})
 	curMat.Reset()
+	curMat.ResetInvalid()
 	instanceKeys = r.Prop.InstanceKeys
 
 	apiD = time.Since(startTime)
@@ -1078,6 +1178,12 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 		})
 	}
 
+	// share the raw ops of workloads, so the workload detail collectors do not need to poll them again
+	if isWorkloadObject(r.Prop.Query) {
+		key := bus.Key{Cluster: r.Client.Cluster().Name, Object: path.Base(r.Prop.Query), Metric: "ops"}
+		r.Bus.Publish(key, bus.Snapshot(curMat, "ops", "workload", time.Now()))
+	}
+
 	if isWorkloadDetailObject(r.Prop.Query) {
 		if err := r.getParentOpsCounters(curMat); err != nil {
 			// no point to continue as we can't calculate the other counters
@@ -1091,6 +1197,7 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 	_ = r.Metadata.LazySetValueUint64("metrics", "data", count)
 	_ = r.Metadata.LazySetValueUint64("instances", "data", uint64(len(curMat.GetInstances())))
 	_ = r.Metadata.LazySetValueUint64("bytesRx", "data", r.Client.Metadata.BytesRx)
+	_ = r.Metadata.LazySetValueUint64("bytesRxWire", "data", r.Client.Metadata.BytesRxWire)
 	_ = r.Metadata.LazySetValueUint64("numCalls", "data", r.Client.Metadata.NumCalls)
 	_ = r.Metadata.LazySetValueUint64("numPartials", "data", numPartials)
 
@@ -1161,6 +1268,7 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 		metric.SetProperty(property)
 		// used in volume.go plugin
 		metric.SetComment(counter.denominator)
+		metric.SetUnit(counter.unit)
 
 		// raw/string - submit without post-processing
 		if property == "raw" || property == "string" {
@@ -1276,9 +1384,14 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 	_ = r.Metadata.LazySetValueUint64("instances", "data", uint64(len(curMat.GetInstances())))
 	_ = r.Metadata.LazySetValueInt64("calc_time", "data", calcD.Microseconds())
 	_ = r.Metadata.LazySetValueUint64("skips", "data", uint64(totalSkips))
+	_ = r.Metadata.LazySetValueUint64("invalidValues", "data", uint64(curMat.InvalidCount()))
 
-	// store cache for next poll
+	// store cache for next poll and recycle the previous cache
 	r.Matrix[r.Object] = cachedData
+	prevMat.Release()
+
+	// curMat is released by the collector after it is exported
+	curMat.SetReleasable(true)
 
 	newDataMap := make(map[string]*matrix.Matrix)
 	newDataMap[r.Object] = curMat
@@ -1287,8 +1400,8 @@ func (r *RestPerf) pollData(startTime time.Time, perfRecords []rest.PerfRecord)
 
 // Poll counter "ops" of the related/parent object, required for objects
 // workload_detail and workload_detail_volume. This counter is already
-// collected by the other collectors, so this poll is redundant
-// (until we implement some sort of inter-collector communication).
+// collected by the other collectors, so it is only polled when
+// the bus does not have fresh ops for every instance.
 func (r *RestPerf) getParentOpsCounters(data *matrix.Matrix) error {
 
 	var (
@@ -1312,6 +1425,13 @@ func (r *RestPerf) getParentOpsCounters(data *matrix.Matrix) error {
 		return errs.New(errs.ErrMissingParam, "counter ops")
 	}
 
+	key := bus.Key{Cluster: r.Client.Cluster().Name, Object: object, Metric: "ops"}
+	if shared, ok := r.FreshShared(key, r.sharedOpsTime); ok && shared.Apply(data, "ops") {
+		r.sharedOpsTime = shared.Time
+		r.Logger.Debug().Str("object", object).Time("collected", shared.Time).Msg("Using shared ops of parent object")
+		return nil
+	}
+
 	var filter []string
 	filter = append(filter, "counters.name=ops")
 	href := rest.NewHrefBuilder().
@@ -1326,7 +1446,7 @@ func (r *RestPerf) getParentOpsCounters(data *matrix.Matrix) error {
 		return errs.New(errs.ErrConfig, "empty url")
 	}
 
-	records, err = rest.Fetch(r.Client, href)
+	records, err = rest.FetchContext(r.Context(), r.Client, href)
 	if err != nil {
 		r.Logger.Error().Err(err).Str("href", href).Msg("Failed to fetch data")
 		return err
@@ -1391,31 +1511,38 @@ func (r *RestPerf) LoadPlugin(kind string, p *plugin.AbstractPlugin) plugin.Plug
 		return fcp.New(p)
 	case "Headroom":
 		return headroom.New(p)
+	case "LunHost":
+		return lunhost.New(p)
+	case "QosAdaptive":
+		return qosadaptive.New(p)
 	case "Volume":
 		return volume.New(p)
 	case "VolumeTag":
 		return volumetag.New(p)
+	case "VolumeIdle":
+		return volumeidle.New(p)
 	case "Disk":
 		return disk.New(p)
 	case "Vscan":
 		return vscan.New(p)
+	case "OntapS3":
+		return ontaps3.New(p)
 	case "FabricPool":
 		return fabricpool.New(p)
+	case "FlexCache":
+		return flexcache.New(p)
 	case "FCVI":
 		return fcvi.New(p)
+	case "Namespace":
+		return namespace.New(p)
 	default:
 		r.Logger.Info().Str("kind", kind).Msg("no Restperf plugin found")
 	}
 	return nil
 }
 
-// PollInstance updates instance cache
-func (r *RestPerf) PollInstance() (map[string]*matrix.Matrix, error) {
-	var (
-		err     error
-		records []gjson.Result
-	)
-
+// instanceHref returns the href of the instances of the object, the workloads for QoS objects
+func (r *RestPerf) instanceHref() string {
 	dataQuery := path.Join(r.Prop.Query, "rows")
 	fields := "properties"
 	var filter []string
@@ -1430,12 +1557,22 @@ func (r *RestPerf) PollInstance() (map[string]*matrix.Matrix, error) {
 		}
 	}
 
-	href := rest.NewHrefBuilder().
+	return rest.NewHrefBuilder().
 		APIPath(dataQuery).
 		Fields([]string{fields}).
 		Filter(filter).
 		ReturnTimeout(r.Prop.ReturnTimeOut).
 		Build()
+}
+
+// PollInstance updates instance cache
+func (r *RestPerf) PollInstance() (map[string]*matrix.Matrix, error) {
+	var (
+		err     error
+		records []gjson.Result
+	)
+
+	href := r.instanceHref()
 
 	r.Logger.Debug().Str("href", href).Send()
 	if href == "" {
@@ -1444,7 +1581,7 @@ func (r *RestPerf) PollInstance() (map[string]*matrix.Matrix, error) {
 
 	apiT := time.Now()
 	r.Client.Metadata.Reset()
-	records, err = rest.Fetch(r.Client, href)
+	records, err = rest.FetchContext(r.Context(), r.Client, href)
 	if err != nil {
 		return r.handleError(err, href)
 	}
@@ -1544,6 +1681,7 @@ func (r *RestPerf) pollInstance(records []gjson.Result, apiD time.Duration) (map
 	_ = r.Metadata.LazySetValueInt64("parse_time", "instance", time.Since(parseT).Microseconds())
 	_ = r.Metadata.LazySetValueUint64("instances", "instance", uint64(newSize))
 	_ = r.Metadata.LazySetValueUint64("bytesRx", "instance", r.Client.Metadata.BytesRx)
+	_ = r.Metadata.LazySetValueUint64("bytesRxWire", "instance", r.Client.Metadata.BytesRxWire)
 	_ = r.Metadata.LazySetValueUint64("numCalls", "instance", r.Client.Metadata.NumCalls)
 
 	if newSize == 0 {
@@ -0,0 +1,287 @@
+// Copyright NetApp Inc, 2024 All rights reserved
+
+/*
+Package statperf implements a collector that gathers performance counters with the ONTAP CLI passthrough
+(statistics catalog counter show and statistics show). Use it for counters that are not exposed by the REST
+counter tables. The CLI output is converted into the format of the counter table endpoints, so the counters
+are cooked by the same code as RestPerf.
+*/
+package statperf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/collectors/restperf"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/set"
+	"github.com/tidwall/gjson"
+	"golang.org/x/exp/maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cliQuery  = "api/private/cli"
+	separator = "##"
+	// Print one row per line with all fields and the separator between fields
+	cliSettings = `set -privilege advanced -showseparator "` + separator + `" -showallfields true -rows 0; `
+)
+
+// properties of ONTAP counters, in the order they are tested
+var counterProperties = []string{"string", "raw", "delta", "rate", "average", "percent"}
+
+type StatPerf struct {
+	*restperf.RestPerf // provides: Rest, AbstractCollector, cooking of counters
+	stringCounters     *set.Set
+}
+
+func init() {
+	plugin.RegisterModule(&StatPerf{})
+}
+
+func (s *StatPerf) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.statperf",
+		New: func() plugin.Module { return new(StatPerf) },
+	}
+}
+
+func (s *StatPerf) Init(a *collector.AbstractCollector) error {
+	s.RestPerf = &restperf.RestPerf{}
+	s.stringCounters = set.New()
+	return s.InitCollector(a, s)
+}
+
+// PollCounter fetches the counter schema of the object with statistics catalog counter show
+func (s *StatPerf) PollCounter() (map[string]*matrix.Matrix, error) {
+	command := "statistics catalog counter show -object " + s.Prop.Query
+
+	apiT := time.Now()
+	s.Client.Metadata.Reset()
+	rows, err := s.runCLI(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errs.New(errs.ErrConfig, "no counters found for object "+s.Prop.Query)
+	}
+
+	schema, err := s.counterSchema(rows)
+	if err != nil {
+		return nil, err
+	}
+	return s.ProcessCounters([]gjson.Result{schema}, time.Since(apiT))
+}
+
+// PollInstance fetches the instance keys and labels of the object with statistics show
+func (s *StatPerf) PollInstance() (map[string]*matrix.Matrix, error) {
+	counters := slices.Concat(s.Prop.InstanceKeys, maps.Keys(s.Prop.InstanceLabels))
+
+	apiT := time.Now()
+	s.Client.Metadata.Reset()
+	rows, err := s.runCLI(s.statisticsShow(counters))
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.records(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ProcessInstances(records.Array(), time.Since(apiT))
+}
+
+// PollData fetches the counters of the object with statistics show and cooks them
+func (s *StatPerf) PollData() (map[string]*matrix.Matrix, error) {
+	if len(s.Matrix[s.Object].GetInstances()) == 0 {
+		return nil, errs.New(errs.ErrNoInstance, "no "+s.Object+" instances fetched in PollInstance")
+	}
+
+	counters := slices.Concat(s.Prop.InstanceKeys, maps.Keys(s.Prop.InstanceLabels), maps.Keys(s.Prop.Metrics))
+
+	startTime := time.Now()
+	s.Client.Metadata.Reset()
+	rows, err := s.runCLI(s.statisticsShow(counters))
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.records(rows)
+	if err != nil {
+		return nil, err
+	}
+	perfRecords := []rest.PerfRecord{{Records: records, Timestamp: time.Now().UnixNano()}}
+
+	return s.ProcessData(startTime, perfRecords)
+}
+
+// Estimate is not supported: the CLI passthrough cannot count the rows of statistics show without running it
+func (s *StatPerf) Estimate() ([]collector.Estimate, error) {
+	return nil, errs.New(errs.ErrImplement, "StatPerf can not estimate without polling")
+}
+
+func (s *StatPerf) statisticsShow(counters []string) string {
+	slices.Sort(counters)
+	counters = slices.Compact(counters)
+	return "statistics show -object " + s.Prop.Query + " -raw -counter " + strings.Join(counters, "|")
+}
+
+// runCLI runs command with the CLI passthrough and parses its tabular output
+func (s *StatPerf) runCLI(command string) ([]map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"input": cliSettings + command})
+	if err != nil {
+		return nil, err
+	}
+	s.Logger.Debug().Str("command", command).Send()
+	response, err := s.Client.PostRest(cliQuery, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run command=[%s] err: %w", command, err)
+	}
+	return parseTable(gjson.GetBytes(response, "output").String()), nil
+}
+
+// counterSchema converts the rows of statistics catalog counter show into the counter schema of a counter table
+func (s *StatPerf) counterSchema(rows []map[string]string) (gjson.Result, error) {
+	schemas := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		name := row["counter"]
+		if name == "" {
+			continue
+		}
+		property := counterProperty(row["properties"], row["type"])
+		if property == "string" {
+			s.stringCounters.Add(name)
+		}
+		schema := map[string]any{
+			"name":        name,
+			"description": row["description"],
+			"type":        property,
+			"unit":        row["unit"],
+		}
+		if base := row["base-counter"]; base != "" && base != "-" {
+			schema["denominator"] = map[string]string{"name": base}
+		}
+		schemas = append(schemas, schema)
+	}
+
+	b, err := json.Marshal(map[string]any{"counter_schemas": schemas})
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.ParseBytes(b), nil
+}
+
+// records converts the rows of statistics show into the rows of a counter table.
+// String counters are converted into properties and numeric counters into counters
+func (s *StatPerf) records(rows []map[string]string) (gjson.Result, error) {
+	type record struct {
+		ID         string              `json:"id"`
+		Properties []map[string]string `json:"properties"`
+		Counters   []map[string]string `json:"counters"`
+	}
+
+	var (
+		records []*record
+		cur     *record
+		seen    *set.Set
+	)
+
+	for _, row := range rows {
+		name := row["counter"]
+		value := row["value"]
+		instance := row["instance"]
+		if name == "" || value == "" || value == "-" {
+			// array counters span multiple lines and are not supported
+			continue
+		}
+		// The rows of an instance are contiguous. A new instance starts when the instance name changes
+		// or a counter is repeated, since instance names are not unique, e.g. volumes in different SVMs
+		if cur == nil || cur.ID != instance || seen.Has(name) {
+			cur = &record{ID: instance}
+			seen = set.New()
+			records = append(records, cur)
+		}
+		seen.Add(name)
+		nv := map[string]string{"name": name, "value": value}
+		if s.stringCounters.Has(name) || !isNumber(value) {
+			cur.Properties = append(cur.Properties, nv)
+		} else {
+			cur.Counters = append(cur.Counters, nv)
+		}
+	}
+
+	b, err := json.Marshal(records)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.ParseBytes(b), nil
+}
+
+// parseTable parses the output of a CLI command that was run with -showseparator.
+// The first line with a separator is the header. Lines without a separator are ignored
+func parseTable(output string) []map[string]string {
+	var (
+		header []string
+		rows   []map[string]string
+	)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.Contains(line, separator) {
+			continue
+		}
+		fields := strings.Split(line, separator)
+		if header == nil {
+			header = make([]string, len(fields))
+			for i, f := range fields {
+				header[i] = strings.ToLower(strings.TrimSpace(f))
+			}
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, f := range fields {
+			if i < len(header) && header[i] != "" {
+				row[header[i]] = strings.TrimSpace(f)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// counterProperty returns the property used to cook a counter from the properties and type fields of
+// statistics catalog counter show, e.g. "rate,no-zero-values" is cooked as rate
+func counterProperty(properties string, kind string) string {
+	if kind == "string" || kind == "text" {
+		return "string"
+	}
+	props := strings.Split(properties, ",")
+	for _, p := range counterProperties {
+		if slices.Contains(props, p) {
+			return p
+		}
+	}
+	return "raw"
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*StatPerf)(nil)
+)
+
+// ReloadCounters is not supported: the counters of StatPerf are parsed from the CLI passthrough, not from the counter
+// schema that RestPerf reloads
+func (s *StatPerf) ReloadCounters() error {
+	return errs.New(errs.ErrImplement, "StatPerf can not reload its counters, restart the poller")
+}
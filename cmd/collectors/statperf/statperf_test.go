@@ -0,0 +1,111 @@
+package statperf
+
+import (
+	"github.com/netapp/harvest/v2/pkg/set"
+	"testing"
+)
+
+const catalogOutput = `
+Last login time: 1/2/2024 10:00:00
+
+object##counter##base-counter##properties##type##unit##description##
+volume##instance_name##-##string##string##none##Name of the instance##
+volume##read_ops##-##rate##raw##per_sec##Number of reads per second##
+volume##read_latency##read_ops##average,no-zero-values##raw##microsec##Average latency of reads##
+volume##read_data##-##rate##raw##b_per_sec##Bytes read per second##
+3 entries were displayed.
+`
+
+const showOutput = `
+object##instance##counter##value##
+volume##vol1##instance_name##vol1##
+volume##vol1##vserver_name##svm1##
+volume##vol1##read_ops##100##
+volume##vol1##read_latency##2000##
+volume##vol1##instance_name##vol1##
+volume##vol1##vserver_name##svm2##
+volume##vol1##read_ops##5##
+volume##vol2##instance_name##vol2##
+volume##vol2##vserver_name##svm1##
+volume##vol2##read_ops##-##
+`
+
+func Test_parseTable(t *testing.T) {
+	rows := parseTable(catalogOutput)
+	if len(rows) != 4 {
+		t.Fatalf("parseTable() got %d rows, want 4", len(rows))
+	}
+	if rows[2]["counter"] != "read_latency" || rows[2]["base-counter"] != "read_ops" {
+		t.Errorf("parseTable() got=%v", rows[2])
+	}
+}
+
+func Test_counterProperty(t *testing.T) {
+	tests := []struct {
+		properties string
+		kind       string
+		want       string
+	}{
+		{properties: "rate", kind: "raw", want: "rate"},
+		{properties: "average,no-zero-values", kind: "raw", want: "average"},
+		{properties: "string", kind: "string", want: "string"},
+		{properties: "no-display", kind: "text", want: "string"},
+		{properties: "", kind: "", want: "raw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.properties, func(t *testing.T) {
+			if got := counterProperty(tt.properties, tt.kind); got != tt.want {
+				t.Errorf("counterProperty() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatPerf_counterSchema(t *testing.T) {
+	s := &StatPerf{stringCounters: set.New()}
+	schema, err := s.counterSchema(parseTable(catalogOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schema.Get("counter_schemas.#").Int(); got != 4 {
+		t.Errorf("counter_schemas got=%d, want=4", got)
+	}
+	if got := schema.Get(`counter_schemas.#(name="read_latency").denominator.name`).String(); got != "read_ops" {
+		t.Errorf("read_latency denominator got=%s, want=read_ops", got)
+	}
+	if got := schema.Get(`counter_schemas.#(name="read_ops").denominator`); got.Exists() {
+		t.Errorf("read_ops denominator got=%s, want none", got.String())
+	}
+	if !s.stringCounters.Has("instance_name") {
+		t.Errorf("instance_name should be a string counter")
+	}
+}
+
+func TestStatPerf_records(t *testing.T) {
+	s := &StatPerf{stringCounters: set.NewFrom([]string{"instance_name"})}
+	records, err := s.records(parseTable(showOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(records.Array()); got != 3 {
+		t.Fatalf("records got=%d, want=3", got)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: `0.properties.#(name="vserver_name").value`, want: "svm1"},
+		{path: `0.counters.#(name="read_latency").value`, want: "2000"},
+		{path: `1.properties.#(name="vserver_name").value`, want: "svm2"},
+		{path: `1.counters.#(name="read_ops").value`, want: "5"},
+		{path: `2.id`, want: "vol2"},
+		{path: `2.properties.#`, want: "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := records.Get(tt.path).String(); got != tt.want {
+				t.Errorf("records got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
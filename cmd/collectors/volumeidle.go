@@ -0,0 +1,160 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"maps"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultIdleOps    = 1.0
+	defaultIdleWindow = 7 * 24 * time.Hour
+)
+
+// IdleVolumes flags the volumes whose IOPS stay below a threshold for an evaluation window. It keeps, for each
+// volume, when its IOPS were last above the threshold, so the state survives across polls but not restarts:
+// a volume is only flagged after the poller watched it for a whole window.
+// The IOPS of FlexGroup constituents are summed, so FlexGroups are evaluated as one volume
+type IdleVolumes struct {
+	counter string
+	ops     float64
+	window  time.Duration
+	states  map[string]*idleState
+	matrix  *matrix.Matrix
+	now     func() time.Time
+}
+
+type idleState struct {
+	labels map[string]string
+	since  time.Time // when the IOPS of the volume were last above the threshold, or when it was first seen
+}
+
+// NewPluginIdleVolumes returns the IdleVolumes of a VolumeIdle plugin
+func NewPluginIdleVolumes(p *plugin.AbstractPlugin) (*IdleVolumes, error) {
+	counter := "total_ops"
+	if value := p.Params.GetChildContentS("counter"); value != "" {
+		counter = value
+	}
+	ops := defaultIdleOps
+	if value := p.Params.GetChildContentS("idle_ops"); value != "" {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return nil, errs.New(errs.ErrInvalidParam, "idle_ops: "+value)
+		}
+		ops = v
+	}
+	window := defaultIdleWindow
+	if value := p.Params.GetChildContentS("window"); value != "" {
+		v, err := time.ParseDuration(value)
+		if err != nil || v <= 0 {
+			return nil, errs.New(errs.ErrInvalidParam, "window: "+value)
+		}
+		window = v
+	}
+	return NewIdleVolumes(p.Parent, counter, ops, window), nil
+}
+
+// NewIdleVolumes returns an IdleVolumes that reads the IOPS of volumes from counter. A volume is idle when its
+// IOPS stay below ops for window
+func NewIdleVolumes(uuid string, counter string, ops float64, window time.Duration) *IdleVolumes {
+	mat := matrix.New(uuid+".VolumeIdle", "volume", "volume_idle")
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range []string{"aggr", "node", "style", "svm", "volume"} {
+		instanceKeys.NewChildS("", key)
+	}
+	mat.SetExportOptions(exportOptions)
+	_, _ = mat.NewMetricFloat64("idle")
+	_, _ = mat.NewMetricFloat64("idle_days")
+
+	return &IdleVolumes{
+		counter: counter,
+		ops:     ops,
+		window:  window,
+		states:  make(map[string]*idleState),
+		matrix:  mat,
+		now:     time.Now,
+	}
+}
+
+// Run updates the idle state of the volumes of data and returns the matrix of the idle metrics. Volumes
+// without IOPS in this poll, e.g. on the first poll of a perf collector, keep their state. Volumes that
+// are gone are forgotten
+func (v *IdleVolumes) Run(data *matrix.Matrix) *matrix.Matrix {
+	now := v.now()
+	seen := make(map[string]bool)
+	ops := make(map[string]float64)
+	hasOps := make(map[string]bool)
+	metric := data.GetMetric(v.counter)
+
+	for _, instance := range data.GetInstances() {
+		key, labels := idleKey(instance)
+		seen[key] = true
+		if _, ok := v.states[key]; !ok {
+			v.states[key] = &idleState{labels: labels, since: now}
+		}
+		if metric == nil {
+			continue
+		}
+		if value, ok := metric.GetValueFloat64(instance); ok {
+			ops[key] += value
+			hasOps[key] = true
+		}
+	}
+
+	for key := range v.states {
+		if !seen[key] {
+			delete(v.states, key)
+		}
+	}
+	for key := range hasOps {
+		if ops[key] >= v.ops {
+			v.states[key].since = now
+		}
+	}
+
+	v.matrix.PurgeInstances()
+	v.matrix.Reset()
+	v.matrix.SetGlobalLabels(data.GetGlobalLabels())
+	for key, state := range v.states {
+		instance, err := v.matrix.NewInstance(key)
+		if err != nil {
+			continue
+		}
+		instance.SetLabels(maps.Clone(state.labels))
+		idle := now.Sub(state.since)
+		_ = v.matrix.GetMetric("idle").SetValueFloat64(instance, boolToFloat(idle >= v.window))
+		_ = v.matrix.GetMetric("idle_days").SetValueFloat64(instance, idle.Hours()/24)
+	}
+	return v.matrix
+}
+
+// idleKey returns the key and labels of the volume of instance. FlexGroup constituents return their FlexGroup
+func idleKey(instance *matrix.Instance) (string, map[string]string) {
+	volume := instance.GetLabel("volume")
+	labels := map[string]string{
+		"aggr":   instance.GetLabel("aggr"),
+		"node":   instance.GetLabel("node"),
+		"style":  "flexvol",
+		"svm":    instance.GetLabel("svm"),
+		"volume": volume,
+	}
+	if match := flexgroupRegex.FindStringSubmatch(volume); len(match) == 3 {
+		labels["aggr"] = ""
+		labels["node"] = ""
+		labels["style"] = "flexgroup"
+		labels["volume"] = match[1]
+	}
+	return labels["svm"] + "." + labels["volume"], labels
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIdleVolumes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	idle := NewIdleVolumes("ZapiPerf", "total_ops", 1, 48*time.Hour)
+	idle.now = func() time.Time { return now }
+
+	// poll returns the volume perf matrix with the IOPS of each volume, volumes without IOPS have no value
+	poll := func(ops map[string]float64, volumes ...string) *matrix.Matrix {
+		data := matrix.New("volume", "volume", "volume")
+		metric, _ := data.NewMetricFloat64("total_ops")
+		for _, volume := range volumes {
+			instance, _ := data.NewInstance(volume)
+			instance.SetLabel("svm", "vs1")
+			instance.SetLabel("volume", volume)
+			if v, ok := ops[volume]; ok {
+				_ = metric.SetValueFloat64(instance, v)
+			}
+		}
+		return idle.Run(data)
+	}
+
+	volumes := []string{"busy", "quiet", "deleted", "fg__0001", "fg__0002"}
+	// the first poll of a perf collector has no values
+	poll(nil, volumes...)
+	now = now.Add(24 * time.Hour)
+	poll(map[string]float64{"busy": 50, "quiet": 0.2, "deleted": 0, "fg__0001": 0.6, "fg__0002": 0.6}, volumes...)
+	now = now.Add(48 * time.Hour)
+	got := poll(map[string]float64{"busy": 0, "quiet": 0.5, "fg__0001": 0.6, "fg__0002": 0.6}, "busy", "quiet", "fg__0001", "fg__0002")
+
+	tests := []struct {
+		instance string
+		idle     float64
+		days     float64
+	}{
+		// busy was active on the second poll, two days ago
+		{instance: "vs1.busy", idle: 1, days: 2},
+		// quiet has been below the threshold since it was first seen
+		{instance: "vs1.quiet", idle: 1, days: 3},
+		// the constituents of fg sum to 1.2 IOPS
+		{instance: "vs1.fg", idle: 0, days: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.instance, func(t *testing.T) {
+			instance := got.GetInstance(tt.instance)
+			if instance == nil {
+				t.Fatalf("instance %s not found", tt.instance)
+			}
+			if v, _ := got.GetMetric("idle").GetValueFloat64(instance); v != tt.idle {
+				t.Errorf("idle got=%v want=%v", v, tt.idle)
+			}
+			if v, _ := got.GetMetric("idle_days").GetValueFloat64(instance); math.Abs(v-tt.days) > 1e-9 {
+				t.Errorf("idle_days got=%v want=%v", v, tt.days)
+			}
+		})
+	}
+
+	if got.GetInstance("vs1.deleted") != nil {
+		t.Errorf("deleted volume is still exported")
+	}
+	if style := got.GetInstance("vs1.fg").GetLabel("style"); style != "flexgroup" {
+		t.Errorf("style got=%s want=flexgroup", style)
+	}
+}
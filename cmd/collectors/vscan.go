@@ -0,0 +1,251 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VscanOptions are the rollups of the Vscan plugins, read from the plugin parameters of the template
+type VscanOptions struct {
+	PerScanner    bool     // aggregate counters per scanner
+	PerPool       bool     // aggregate counters per scanner pool
+	PerSvm        bool     // aggregate counters per SVM
+	ScannerHealth bool     // export the health of each scanner connection
+	HealthyStates []string // connection states that are healthy
+}
+
+func ReadVscanOptions(params *node.Node) VscanOptions {
+	o := VscanOptions{
+		PerScanner:    true,
+		PerPool:       ReadPluginKey(params, "metricsPerPool"),
+		PerSvm:        ReadPluginKey(params, "metricsPerSvm"),
+		ScannerHealth: ReadPluginKey(params, "scannerHealth"),
+		HealthyStates: []string{"connected"},
+	}
+	if b, err := strconv.ParseBool(params.GetChildContentS("metricsPerScanner")); err == nil {
+		o.PerScanner = b
+	}
+	if states := params.GetChildS("healthyStates"); states != nil && len(states.GetAllChildContentS()) > 0 {
+		o.HealthyStates = states.GetAllChildContentS()
+	}
+	return o
+}
+
+// HasRollups returns true when the options aggregate per scanner pool or SVM, or export the scanner health
+func (o VscanOptions) HasRollups() bool {
+	return o.PerPool || o.PerSvm || o.ScannerHealth
+}
+
+// NeedsPools returns true when the options need the scanner pools of the SVMs
+func (o VscanOptions) NeedsPools() bool {
+	return o.PerPool || o.ScannerHealth
+}
+
+// VscanConnection is the status of the connection between a node of an SVM and a scanner
+type VscanConnection struct {
+	Svm     string
+	Node    string
+	Scanner string
+	State   string
+	Reason  string
+}
+
+// VscanPools are the scanner pools of the scanners of each SVM, svm => scanner => pools
+type VscanPools map[string]map[string][]string
+
+// Add adds scanners to pool of svm
+func (p VscanPools) Add(svm string, pool string, scanners []string) {
+	if p[svm] == nil {
+		p[svm] = make(map[string][]string)
+	}
+	for _, scanner := range scanners {
+		if !slices.Contains(p[svm][scanner], pool) {
+			p[svm][scanner] = append(p[svm][scanner], pool)
+		}
+	}
+}
+
+const (
+	vscanLatency  = "scan_latency"
+	vscanRequests = "scan_request_dispatched_rate"
+)
+
+// VscanRollups returns the health of the scanner connections, and the counters of the connections in data aggregated
+// per scanner pool and per SVM, as enabled by o.
+// Requests are summed, latency is averaged weighted by requests, and the percentages of used resources are averaged.
+// With scanner health, the rollups also count the connections, and the healthy connections.
+// Instances of data must have the svm and scanner labels
+func VscanRollups(data *matrix.Matrix, pools VscanPools, connections []VscanConnection, o VscanOptions, l *logging.Logger) []*matrix.Matrix {
+	var results []*matrix.Matrix
+
+	if !o.ScannerHealth {
+		connections = nil
+	} else {
+		results = append(results, vscanHealth(data, pools, connections, o.HealthyStates))
+	}
+	if o.PerPool {
+		poolsOf := func(svm string, scanner string) [][]string {
+			groups := make([][]string, 0, len(pools[svm][scanner]))
+			for _, pool := range pools[svm][scanner] {
+				groups = append(groups, []string{svm, pool})
+			}
+			return groups
+		}
+		results = append(results, vscanRollup(data, connections, "vscan_scanner_pool", []string{"svm", "scanner_pool"}, poolsOf, o, l))
+	}
+	if o.PerSvm {
+		svmOf := func(svm string, _ string) [][]string {
+			return [][]string{{svm}}
+		}
+		results = append(results, vscanRollup(data, connections, "vscan_scanner_svm", []string{"svm"}, svmOf, o, l))
+	}
+	return results
+}
+
+// vscanHealth returns a matrix with the health of each connection, 1 when its state is healthy, 0 otherwise
+func vscanHealth(data *matrix.Matrix, pools VscanPools, connections []VscanConnection, healthyStates []string) *matrix.Matrix {
+	health := matrix.New(data.UUID+".VscanHealth", "vscan_scanner", "vscan_scanner")
+	health.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, k := range []string{"node", "scanner", "scanner_pool", "svm"} {
+		instanceKeys.NewChildS("", k)
+	}
+	instanceLabels := exportOptions.NewChildS("instance_labels", "")
+	instanceLabels.NewChildS("", "state")
+	instanceLabels.NewChildS("", "reason")
+	health.SetExportOptions(exportOptions)
+	metric, _ := health.NewMetricUint8("health")
+
+	for _, c := range connections {
+		instance, err := health.NewInstance(c.Svm + ":" + c.Scanner + ":" + c.Node)
+		if err != nil {
+			// duplicate connection
+			continue
+		}
+		instance.SetLabel("svm", c.Svm)
+		instance.SetLabel("node", c.Node)
+		instance.SetLabel("scanner", c.Scanner)
+		instance.SetLabel("scanner_pool", strings.Join(pools[c.Svm][c.Scanner], ","))
+		instance.SetLabel("state", c.State)
+		instance.SetLabel("reason", c.Reason)
+		value := 0.0
+		if slices.Contains(healthyStates, c.State) {
+			value = 1
+		}
+		_ = metric.SetValueFloat64(instance, value)
+	}
+	return health
+}
+
+// vscanRollup aggregates the connections of data, and the connection status, to the groups of each connection.
+// The labels of the groups are named by keys
+func vscanRollup(data *matrix.Matrix, connections []VscanConnection, object string, keys []string,
+	groupsOf func(svm, scanner string) [][]string, o VscanOptions, l *logging.Logger) *matrix.Matrix {
+
+	rollup := matrix.New(data.UUID+".Vscan."+object, object, object)
+	rollup.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, k := range keys {
+		instanceKeys.NewChildS("", k)
+	}
+	rollup.SetExportOptions(exportOptions)
+
+	for key, m := range data.GetMetrics() {
+		if key == vscanRequests || key == vscanLatency || strings.HasSuffix(key, "_used") {
+			if _, err := rollup.NewMetricFloat64(key, m.GetName()); err != nil {
+				l.Error().Err(err).Str("metric", key).Msg("Failed to create rollup metric")
+			}
+		}
+	}
+
+	// averaged metrics, by instance and metric
+	averages := make(map[string]map[string]*matrix.Average)
+	instanceOf := func(group []string) *matrix.Instance {
+		instanceKey := strings.Join(group, ":")
+		if r := rollup.GetInstance(instanceKey); r != nil {
+			return r
+		}
+		r, _ := rollup.NewInstance(instanceKey)
+		for n, k := range keys {
+			r.SetLabel(k, group[n])
+		}
+		averages[instanceKey] = make(map[string]*matrix.Average)
+		return r
+	}
+
+	requests := data.GetMetric(vscanRequests)
+	for _, i := range data.GetInstances() {
+		svm := i.GetLabel("svm")
+		scanner := i.GetLabel("scanner")
+		if svm == "" || scanner == "" {
+			continue
+		}
+		weight := 1.0
+		if requests != nil {
+			weight, _ = requests.GetValueFloat64(i)
+		}
+
+		for _, group := range groupsOf(svm, scanner) {
+			r := instanceOf(group)
+			avgs := averages[strings.Join(group, ":")]
+			for key, rm := range rollup.GetMetrics() {
+				m := data.GetMetric(key)
+				if m == nil {
+					continue
+				}
+				value, ok := m.GetValueFloat64(i)
+				if !ok {
+					continue
+				}
+				if key == vscanRequests {
+					total, _ := rm.GetValueFloat64(r)
+					_ = rm.SetValueFloat64(r, total+value)
+					continue
+				}
+				avg, ok := avgs[key]
+				if !ok {
+					avg = &matrix.Average{}
+					avgs[key] = avg
+				}
+				if key == vscanLatency {
+					avg.Add(value, weight)
+				} else {
+					avg.Add(value, 1)
+				}
+			}
+		}
+	}
+
+	// without requests, the latency is 0
+	for instanceKey, r := range rollup.GetInstances() {
+		for key, avg := range averages[instanceKey] {
+			if value, ok := avg.Value(); ok {
+				_ = rollup.GetMetric(key).SetValueFloat64(r, value)
+			}
+		}
+	}
+
+	if connections != nil {
+		total, _ := rollup.NewMetricFloat64("connections")
+		healthy, _ := rollup.NewMetricFloat64("connections_healthy")
+		for _, c := range connections {
+			for _, group := range groupsOf(c.Svm, c.Scanner) {
+				r := instanceOf(group)
+				t, _ := total.GetValueFloat64(r)
+				_ = total.SetValueFloat64(r, t+1)
+				h, _ := healthy.GetValueFloat64(r)
+				if slices.Contains(o.HealthyStates, c.State) {
+					h++
+				}
+				_ = healthy.SetValueFloat64(r, h)
+			}
+		}
+	}
+	return rollup
+}
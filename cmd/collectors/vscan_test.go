@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func newVscanData(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Vscan", "vscan", "vscan")
+	latency, _ := data.NewMetricFloat64("scan_latency")
+	requests, _ := data.NewMetricFloat64("scan_request_dispatched_rate")
+	cpu, _ := data.NewMetricFloat64("scanner_stats_pct_cpu_used")
+
+	for _, c := range []struct {
+		svm, scanner, node     string
+		latency, requests, cpu float64
+	}{
+		{"vs1", "10.0.0.1", "n1", 100, 30, 10},
+		{"vs1", "10.0.0.1", "n2", 200, 10, 20},
+		{"vs1", "10.0.0.2", "n1", 400, 0, 60},
+		{"vs2", "10.0.0.3", "n1", 50, 5, 5},
+	} {
+		i, err := data.NewInstance(c.svm + ":" + c.scanner + ":" + c.node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.SetLabel("svm", c.svm)
+		i.SetLabel("scanner", c.scanner)
+		i.SetLabel("node", c.node)
+		_ = latency.SetValueFloat64(i, c.latency)
+		_ = requests.SetValueFloat64(i, c.requests)
+		_ = cpu.SetValueFloat64(i, c.cpu)
+	}
+	return data
+}
+
+func TestVscanRollups(t *testing.T) {
+	data := newVscanData(t)
+	pools := make(VscanPools)
+	pools.Add("vs1", "pool1", []string{"10.0.0.1", "10.0.0.2"})
+	pools.Add("vs1", "pool2", []string{"10.0.0.2"})
+	pools.Add("vs2", "pool1", []string{"10.0.0.3"})
+	connections := []VscanConnection{
+		{Svm: "vs1", Node: "n1", Scanner: "10.0.0.1", State: "connected"},
+		{Svm: "vs1", Node: "n2", Scanner: "10.0.0.1", State: "connected"},
+		{Svm: "vs1", Node: "n1", Scanner: "10.0.0.2", State: "disconnected", Reason: "remote_closed"},
+		{Svm: "vs2", Node: "n1", Scanner: "10.0.0.3", State: "connected"},
+	}
+	o := VscanOptions{PerPool: true, PerSvm: true, ScannerHealth: true, HealthyStates: []string{"connected"}}
+
+	results := VscanRollups(data, pools, connections, o, logging.Get())
+	byObject := make(map[string]*matrix.Matrix)
+	for _, m := range results {
+		byObject[m.Object] = m
+	}
+
+	type want struct {
+		object, instance, metric string
+		value                    float64
+	}
+	tests := []want{
+		{"vscan_scanner", "vs1:10.0.0.1:n1", "health", 1},
+		{"vscan_scanner", "vs1:10.0.0.2:n1", "health", 0},
+		{"vscan_scanner_pool", "vs1:pool1", "scan_request_dispatched_rate", 40},
+		// weighted by requests: (100*30 + 200*10 + 400*0) / 40
+		{"vscan_scanner_pool", "vs1:pool1", "scan_latency", 125},
+		{"vscan_scanner_pool", "vs1:pool1", "scanner_stats_pct_cpu_used", 30},
+		{"vscan_scanner_pool", "vs1:pool1", "connections", 3},
+		{"vscan_scanner_pool", "vs1:pool1", "connections_healthy", 2},
+		// no requests, so no latency
+		{"vscan_scanner_pool", "vs1:pool2", "scan_latency", 0},
+		{"vscan_scanner_pool", "vs1:pool2", "connections_healthy", 0},
+		{"vscan_scanner_svm", "vs1", "scan_request_dispatched_rate", 40},
+		{"vscan_scanner_svm", "vs2", "scan_latency", 50},
+		{"vscan_scanner_svm", "vs2", "connections", 1},
+	}
+	for _, tt := range tests {
+		m := byObject[tt.object]
+		if m == nil {
+			t.Fatalf("missing rollup %s", tt.object)
+		}
+		instance := m.GetInstance(tt.instance)
+		if instance == nil {
+			t.Errorf("%s missing instance %s", tt.object, tt.instance)
+			continue
+		}
+		got, ok := m.GetMetric(tt.metric).GetValueFloat64(instance)
+		if !ok || got != tt.value {
+			t.Errorf("%s %s %s got=%v, want=%v", tt.object, tt.instance, tt.metric, got, tt.value)
+		}
+	}
+
+	if got := byObject["vscan_scanner"].GetInstance("vs1:10.0.0.2:n1").GetLabel("scanner_pool"); got != "pool1,pool2" {
+		t.Errorf("scanner_pool got=%s, want=pool1,pool2", got)
+	}
+}
+
+func TestVscanRollupsDisabled(t *testing.T) {
+	o := VscanOptions{PerSvm: true}
+	results := VscanRollups(newVscanData(t), nil, []VscanConnection{{Svm: "vs1", State: "connected"}}, o, logging.Get())
+	if len(results) != 1 || results[0].Object != "vscan_scanner_svm" {
+		t.Fatalf("VscanRollups() got %d matrices, want only vscan_scanner_svm", len(results))
+	}
+	if results[0].GetMetric("connections") != nil {
+		t.Errorf("connections should not be counted without scanner health")
+	}
+}
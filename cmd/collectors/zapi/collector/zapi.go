@@ -23,6 +23,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/set"
 	"github.com/netapp/harvest/v2/pkg/util"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -98,6 +99,10 @@ func (z *Zapi) InitVars() error {
 		}
 		z.TemplatePath = path
 		z.Params.Union(template)
+		// recorded responses are polled with the query of the template
+		if z.Options.Fixtures != "" {
+			return z.initQuery()
+		}
 		return nil
 	}
 
@@ -133,6 +138,18 @@ func (z *Zapi) InitVars() error {
 
 	z.Params.Union(template)
 
+	if err := z.initQuery(); err != nil {
+		return err
+	}
+
+	// if the object template includes a client_timeout, use it
+	if timeout := z.Params.GetChildContentS("client_timeout"); timeout != "" {
+		z.Client.SetTimeout(timeout)
+	}
+	return nil
+}
+
+func (z *Zapi) initQuery() error {
 	// object name from subtemplate
 	if z.object = z.Params.GetChildContentS("object"); z.object == "" {
 		return errs.New(errs.ErrMissingParam, "object")
@@ -142,11 +159,6 @@ func (z *Zapi) InitVars() error {
 	if z.Query = z.Params.GetChildContentS("query"); z.Query == "" {
 		return errs.New(errs.ErrMissingParam, "query")
 	}
-
-	// if the object template includes a client_timeout, use it
-	if timeout := z.Params.GetChildContentS("client_timeout"); timeout != "" {
-		z.Client.SetTimeout(timeout)
-	}
 	return nil
 }
 
@@ -333,7 +345,11 @@ func (z *Zapi) PollData() (map[string]*matrix.Matrix, error) {
 	tag = "initial"
 
 	for {
-		response, tag, ad, pd, err = z.Client.InvokeBatchWithTimers(request, tag)
+		if z.Options.Fixtures != "" {
+			response, tag, err = z.Client.InvokeBatchRequest(request, tag, filepath.Join(z.Options.Fixtures, z.Query+".xml"))
+		} else {
+			response, tag, ad, pd, err = z.Client.InvokeBatchWithTimers(request, tag)
+		}
 
 		if err != nil {
 			return nil, err
@@ -11,6 +11,7 @@ import (
 type FabricPool struct {
 	*plugin.AbstractPlugin
 	includeConstituents bool
+	tiering             *collectors.Tiering
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -27,6 +28,9 @@ func (f *FabricPool) Init() error {
 			f.includeConstituents = boolValue
 		}
 	}
+	if f.tiering, err = collectors.NewPluginTiering(f.AbstractPlugin); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -35,5 +39,8 @@ func (f *FabricPool) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 	if err != nil {
 		return nil, nil, err
 	}
+	if f.tiering != nil {
+		return append([]*matrix.Matrix{cache}, f.tiering.Run(f.Logger)...), nil, nil
+	}
 	return []*matrix.Matrix{cache}, nil, nil
 }
@@ -1,6 +1,7 @@
 package fcvi
 
 import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/pkg/api/ontapi/zapi"
 	"github.com/netapp/harvest/v2/pkg/conf"
@@ -14,7 +15,8 @@ const batchSize = "500"
 
 type FCVI struct {
 	*plugin.AbstractPlugin
-	client *zapi.Client
+	client  *zapi.Client
+	options collectors.FCVIOptions
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -26,6 +28,9 @@ func (f *FCVI) Init() error {
 	if err := f.InitAbc(); err != nil {
 		return err
 	}
+	if f.options, err = collectors.ReadFCVIOptions(f.Params); err != nil {
+		return err
+	}
 
 	if f.client, err = zapi.New(conf.ZapiPoller(f.ParentParams), f.Auth); err != nil {
 		f.Logger.Error().Stack().Err(err).Msg("connecting")
@@ -81,5 +86,28 @@ func (f *FCVI) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.M
 			instance.SetLabel("port", port)
 		}
 	}
-	return nil, f.client.Metadata, nil
+	return collectors.FCVIRollups(data, f.getDRGroups(), f.options), f.client.Metadata, nil
+}
+
+// getDRGroups returns the DR group of each node of the MetroCluster
+func (f *FCVI) getDRGroups() map[string]string {
+	drGroups := make(map[string]string)
+	request := node.NewXMLS("metrocluster-node-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired := node.NewXMLS("desired-attributes")
+	attributes := node.NewXMLS("metrocluster-node-info")
+	attributes.NewChildS("node-name", "")
+	attributes.NewChildS("dr-group-id", "")
+	desired.AddChild(attributes)
+	request.AddChild(desired)
+
+	result, err := f.client.InvokeZapiCall(request)
+	if err != nil {
+		f.Logger.Error().Err(err).Msg("Failed to fetch DR groups")
+		return drGroups
+	}
+	for _, n := range result {
+		drGroups[n.GetChildContentS("node-name")] = n.GetChildContentS("dr-group-id")
+	}
+	return drGroups
 }
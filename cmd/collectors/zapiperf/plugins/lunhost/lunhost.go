@@ -0,0 +1,121 @@
+// Package lunhost adds the igroups and initiators each LUN is mapped to as labels of the LUN, so the latency of
+// the LUNs of a host can be charted without joins. ONTAP has no host in the counters of LUNs, so the LUN maps and
+// igroups are collected with config polls, every schedule of the plugin
+package lunhost
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/api/ontapi/zapi"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"strconv"
+)
+
+const batchSize = "500"
+
+type LunHost struct {
+	*plugin.AbstractPlugin
+	client     *zapi.Client
+	currentVal int
+	initiators bool
+	igroups    collectors.LunIgroups
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &LunHost{AbstractPlugin: p}
+}
+
+func (l *LunHost) Init() error {
+	var err error
+	if err := l.InitAbc(); err != nil {
+		return err
+	}
+
+	l.initiators = true
+	if b, err := strconv.ParseBool(l.Params.GetChildContentS("initiators")); err == nil {
+		l.initiators = b
+	}
+	collectors.AddLunHostKeys(l.ParentParams, l.initiators)
+
+	if l.client, err = zapi.New(conf.ZapiPoller(l.ParentParams), l.Auth); err != nil {
+		l.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := l.client.Init(5); err != nil {
+		return err
+	}
+
+	// Assigned the value to currentVal so that plugin would be invoked first time to populate cache.
+	l.currentVal = l.SetPluginInterval()
+	return nil
+}
+
+func (l *LunHost) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[l.Object]
+	l.client.Metadata.Reset()
+
+	if l.currentVal >= l.PluginInvocationRate {
+		l.currentVal = 0
+		if igroups, err := l.getIgroups(); err == nil {
+			l.igroups = igroups
+		}
+	}
+	l.currentVal++
+
+	collectors.SetLunHostLabels(data, l.igroups, l.initiators)
+	return nil, l.client.Metadata, nil
+}
+
+// getIgroups returns the igroups of each mapped LUN
+func (l *LunHost) getIgroups() (collectors.LunIgroups, error) {
+	request := node.NewXMLS("igroup-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired := request.NewChildS("desired-attributes", "")
+	igroupInfo := desired.NewChildS("initiator-group-info", "")
+	igroupInfo.NewChildS("initiator-group-name", "")
+	igroupInfo.NewChildS("initiator-group-type", "")
+	igroupInfo.NewChildS("vserver", "")
+	igroupInfo.NewChildS("initiators", "")
+
+	records, err := l.client.InvokeZapiCall(request)
+	if err != nil {
+		l.Logger.Error().Err(err).Msg("Failed to fetch igroups")
+		return nil, err
+	}
+	igroups := make(map[string]*collectors.Igroup, len(records))
+	for _, record := range records {
+		igroup := &collectors.Igroup{
+			Name:     record.GetChildContentS("initiator-group-name"),
+			Protocol: record.GetChildContentS("initiator-group-type"),
+		}
+		if initiators := record.GetChildS("initiators"); initiators != nil {
+			for _, initiator := range initiators.GetChildren() {
+				igroup.Initiators = append(igroup.Initiators, initiator.GetChildContentS("initiator-name"))
+			}
+		}
+		igroups[record.GetChildContentS("vserver")+":"+igroup.Name] = igroup
+	}
+
+	request = node.NewXMLS("lun-map-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired = request.NewChildS("desired-attributes", "")
+	mapInfo := desired.NewChildS("lun-map-info", "")
+	mapInfo.NewChildS("initiator-group", "")
+	mapInfo.NewChildS("path", "")
+	mapInfo.NewChildS("vserver", "")
+
+	records, err = l.client.InvokeZapiCall(request)
+	if err != nil {
+		l.Logger.Error().Err(err).Msg("Failed to fetch lun maps")
+		return nil, err
+	}
+	lunIgroups := make(collectors.LunIgroups)
+	for _, record := range records {
+		svm := record.GetChildContentS("vserver")
+		lunIgroups.Map(svm, record.GetChildContentS("path"), igroups[svm+":"+record.GetChildContentS("initiator-group")])
+	}
+	return lunIgroups, nil
+}
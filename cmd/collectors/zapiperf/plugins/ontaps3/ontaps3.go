@@ -0,0 +1,22 @@
+package ontaps3
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+)
+
+// OntapS3 adds the error ratios of the S3 requests of each SVM and node
+type OntapS3 struct {
+	*plugin.AbstractPlugin
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &OntapS3{AbstractPlugin: p}
+}
+
+func (o *OntapS3) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	collectors.OntapS3Errors(dataMap[o.Object], o.Logger)
+	return nil, nil, nil
+}
@@ -0,0 +1,130 @@
+// Package qosadaptive exports the expected and peak IOPS of the workloads of adaptive QoS policies, and the IOPS
+// each workload has left before its peak. ONTAP scales the IOPS of an adaptive policy with the space of each
+// volume, so the counters of a workload do not show how close it is to its limit. The policies and the space of
+// the volumes are collected with config polls, every schedule of the plugin
+package qosadaptive
+
+import (
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/api/ontapi/zapi"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"strconv"
+)
+
+const batchSize = "500"
+
+type QosAdaptive struct {
+	*plugin.AbstractPlugin
+	client     *zapi.Client
+	currentVal int
+	workloads  *matrix.Matrix
+	policies   collectors.AdaptivePolicies
+	volumes    map[string]collectors.VolumeSpace
+}
+
+func New(p *plugin.AbstractPlugin) plugin.Plugin {
+	return &QosAdaptive{AbstractPlugin: p}
+}
+
+func (q *QosAdaptive) Init() error {
+	var err error
+	if err := q.InitAbc(); err != nil {
+		return err
+	}
+	if q.workloads, err = collectors.NewQosAdaptiveMatrix(q.Parent); err != nil {
+		return err
+	}
+
+	if q.client, err = zapi.New(conf.ZapiPoller(q.ParentParams), q.Auth); err != nil {
+		q.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	if err := q.client.Init(5); err != nil {
+		return err
+	}
+
+	// Assigned the value to currentVal so that plugin would be invoked first time to populate cache.
+	q.currentVal = q.SetPluginInterval()
+	return nil
+}
+
+func (q *QosAdaptive) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[q.Object]
+	q.client.Metadata.Reset()
+
+	if q.currentVal >= q.PluginInvocationRate {
+		q.currentVal = 0
+		q.update()
+	}
+	q.currentVal++
+
+	collectors.SetQosAdaptiveHeadroom(q.workloads, data, q.policies, q.volumes)
+	return []*matrix.Matrix{q.workloads}, q.client.Metadata, nil
+}
+
+// update collects the adaptive policies and the space of the volumes. The last ones are kept when a poll fails
+func (q *QosAdaptive) update() {
+	request := node.NewXMLS("qos-adaptive-policy-group-get-iter")
+	request.NewChildS("max-records", batchSize)
+	records, err := q.client.InvokeZapiCall(request)
+	if err != nil {
+		q.Logger.Error().Err(err).Msg("Failed to fetch adaptive policies")
+		return
+	}
+	policies := make(collectors.AdaptivePolicies)
+	for _, record := range records {
+		// the IOPS of ZAPI have units, e.g. 6144IOPS/TB and 1000IOPS
+		iops := func(name string) float64 {
+			xput, err := collectors.ZapiXputToRest(record.GetChildContentS(name))
+			if err != nil {
+				q.Logger.Warn().Err(err).Str("name", name).Msg("Unable to parse adaptive policy")
+				return 0
+			}
+			v, _ := strconv.ParseFloat(xput.IOPS, 64)
+			return v
+		}
+		// the expected IOPS scale with the allocated space, and the peak IOPS with the used space, by default
+		peakAllocation := record.GetChildContentS("peak-iops-allocation")
+		policies.Add(record.GetChildContentS("vserver"), record.GetChildContentS("policy-group"), collectors.AdaptivePolicy{
+			ExpectedIOPS:    iops("expected-iops"),
+			PeakIOPS:        iops("peak-iops"),
+			AbsoluteMinIOPS: iops("absolute-min-iops"),
+			ExpectedUsed:    collectors.IsUsedSpace(record.GetChildContentS("expected-iops-allocation")),
+			PeakUsed:        peakAllocation == "" || collectors.IsUsedSpace(peakAllocation),
+		})
+	}
+
+	request = node.NewXMLS("volume-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired := request.NewChildS("desired-attributes", "")
+	volumeAttributes := desired.NewChildS("volume-attributes", "")
+	idAttributes := volumeAttributes.NewChildS("volume-id-attributes", "")
+	idAttributes.NewChildS("name", "")
+	idAttributes.NewChildS("owning-vserver-name", "")
+	spaceAttributes := volumeAttributes.NewChildS("volume-space-attributes", "")
+	spaceAttributes.NewChildS("size", "")
+	spaceAttributes.NewChildS("size-used", "")
+
+	records, err = q.client.InvokeZapiCall(request)
+	if err != nil {
+		q.Logger.Error().Err(err).Msg("Failed to fetch volumes")
+		return
+	}
+	volumes := make(map[string]collectors.VolumeSpace, len(records))
+	for _, record := range records {
+		id := record.GetChildS("volume-id-attributes")
+		space := record.GetChildS("volume-space-attributes")
+		if id == nil || space == nil {
+			continue
+		}
+		size, _ := strconv.ParseFloat(space.GetChildContentS("size"), 64)
+		used, _ := strconv.ParseFloat(space.GetChildContentS("size-used"), 64)
+		volumes[id.GetChildContentS("owning-vserver-name")+":"+id.GetChildContentS("name")] = collectors.VolumeSpace{Size: size, Used: used}
+	}
+	q.policies = policies
+	q.volumes = volumes
+}
@@ -3,40 +3,143 @@ package vscan
 import (
 	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/api/ontapi/zapi"
+	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"github.com/netapp/harvest/v2/pkg/util"
-	"strconv"
 	"strings"
 )
 
+const batchSize = "500"
+
 type Vscan struct {
 	*plugin.AbstractPlugin
+	client  *zapi.Client
+	options collectors.VscanOptions
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
 	return &Vscan{AbstractPlugin: p}
 }
 
+func (v *Vscan) Init() error {
+	var err error
+	if err := v.InitAbc(); err != nil {
+		return err
+	}
+
+	v.options = collectors.ReadVscanOptions(v.Params)
+	v.Logger.Debug().
+		Bool("isPerScanner", v.options.PerScanner).
+		Bool("isPerPool", v.options.PerPool).
+		Bool("isPerSvm", v.options.PerSvm).
+		Bool("scannerHealth", v.options.ScannerHealth).
+		Msg("Vscan options")
+
+	// scanner pools and connection status are not performance counters, and need their own queries
+	if !v.options.NeedsPools() {
+		return nil
+	}
+	if v.client, err = zapi.New(conf.ZapiPoller(v.ParentParams), v.Auth); err != nil {
+		v.Logger.Error().Stack().Err(err).Msg("connecting")
+		return err
+	}
+	return v.client.Init(5)
+}
+
 func (v *Vscan) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
 	data := dataMap[v.Object]
-	// defaults plugin options
-	isPerScanner := true
-
-	if s := v.Params.GetChildContentS("metricsPerScanner"); s != "" {
-		if parseBool, err := strconv.ParseBool(s); err == nil {
-			isPerScanner = parseBool
-		} else {
-			v.Logger.Error().Err(err).Msg("Failed to parse metricsPerScanner")
+
+	v.addSvmAndScannerLabels(data)
+
+	var (
+		output   []*matrix.Matrix
+		metadata *util.Metadata
+	)
+	if v.options.HasRollups() {
+		pools := make(collectors.VscanPools)
+		var connections []collectors.VscanConnection
+		if v.client != nil {
+			v.client.Metadata.Reset()
+			pools = v.getPools()
+			if v.options.ScannerHealth {
+				connections = v.getConnections()
+			}
+			metadata = v.client.Metadata
 		}
+		output = append(output, collectors.VscanRollups(data, pools, connections, v.options, v.Logger)...)
 	}
-	v.Logger.Debug().Bool("isPerScanner", isPerScanner).Msg("Vscan options")
 
-	v.addSvmAndScannerLabels(data)
-	if !isPerScanner {
-		return nil, nil, nil
+	if v.options.PerScanner {
+		perScanner, _, err := v.aggregatePerScanner(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		output = append(output, perScanner...)
+	}
+	return output, metadata, nil
+}
+
+// getPools returns the scanner pools of the scanners of each SVM
+func (v *Vscan) getPools() collectors.VscanPools {
+	pools := make(collectors.VscanPools)
+
+	request := node.NewXMLS("vscan-scanner-pool-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired := node.NewXMLS("desired-attributes")
+	info := node.NewXMLS("vscan-scanner-pool-info")
+	info.NewChildS("vserver", "")
+	info.NewChildS("scanner-pool", "")
+	info.NewChildS("servers", "")
+	desired.AddChild(info)
+	request.AddChild(desired)
+
+	result, err := v.client.InvokeZapiCall(request)
+	if err != nil {
+		v.Logger.Error().Err(err).Msg("Failed to fetch scanner pools")
+		return pools
 	}
+	for _, pool := range result {
+		var scanners []string
+		if servers := pool.GetChildS("servers"); servers != nil {
+			scanners = servers.GetAllChildContentS()
+		}
+		pools.Add(pool.GetChildContentS("vserver"), pool.GetChildContentS("scanner-pool"), scanners)
+	}
+	return pools
+}
 
-	return v.aggregatePerScanner(data)
+// getConnections returns the status of the connections between the nodes of each SVM and their scanners
+func (v *Vscan) getConnections() []collectors.VscanConnection {
+	request := node.NewXMLS("vscan-connection-status-all-info-get-iter")
+	request.NewChildS("max-records", batchSize)
+	desired := node.NewXMLS("desired-attributes")
+	info := node.NewXMLS("vscan-connection-status-all-info")
+	info.NewChildS("vserver", "")
+	info.NewChildS("node-name", "")
+	info.NewChildS("server", "")
+	info.NewChildS("connection-status", "")
+	info.NewChildS("disconnect-reason", "")
+	desired.AddChild(info)
+	request.AddChild(desired)
+
+	result, err := v.client.InvokeZapiCall(request)
+	if err != nil {
+		v.Logger.Error().Err(err).Msg("Failed to fetch scanner connection status")
+		return nil
+	}
+	connections := make([]collectors.VscanConnection, 0, len(result))
+	for _, c := range result {
+		connections = append(connections, collectors.VscanConnection{
+			Svm:     c.GetChildContentS("vserver"),
+			Node:    c.GetChildContentS("node-name"),
+			Scanner: c.GetChildContentS("server"),
+			State:   strings.ToLower(c.GetChildContentS("connection-status")),
+			Reason:  c.GetChildContentS("disconnect-reason"),
+		})
+	}
+	return connections
 }
 
 func (v *Vscan) addSvmAndScannerLabels(data *matrix.Matrix) {
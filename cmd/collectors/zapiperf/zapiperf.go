@@ -26,6 +26,7 @@ package zapiperf
 
 import (
 	"errors"
+	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/disk"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/externalserviceoperation"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/fabricpool"
@@ -33,12 +34,17 @@ import (
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/fcvi"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/flexcache"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/headroom"
+	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/lunhost"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/nic"
+	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/ontaps3"
+	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/qosadaptive"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/volume"
+	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/volumeidle"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/volumetag"
 	"github.com/netapp/harvest/v2/cmd/collectors/zapiperf/plugins/vscan"
 	"github.com/netapp/harvest/v2/cmd/poller/collector"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/bus"
 	"github.com/netapp/harvest/v2/pkg/color"
 	"github.com/netapp/harvest/v2/pkg/dict"
 	"github.com/netapp/harvest/v2/pkg/errs"
@@ -86,7 +92,8 @@ type ZapiPerf struct {
 	isCacheEmpty    bool
 	keyName         string
 	keyNameIndex    int
-	testFilePath    string // Used only from unit test
+	testFilePath    string    // Used only from unit test
+	sharedOpsTime   time.Time // collection time of the shared parent ops used last
 }
 
 func init() {
@@ -149,12 +156,20 @@ func (z *ZapiPerf) LoadPlugin(kind string, abc *plugin.AbstractPlugin) plugin.Pl
 		return fabricpool.New(abc)
 	case "Headroom":
 		return headroom.New(abc)
+	case "LunHost":
+		return lunhost.New(abc)
+	case "QosAdaptive":
+		return qosadaptive.New(abc)
 	case "Volume":
 		return volume.New(abc)
 	case "VolumeTag":
 		return volumetag.New(abc)
+	case "VolumeIdle":
+		return volumeidle.New(abc)
 	case "Vscan":
 		return vscan.New(abc)
+	case "OntapS3":
+		return ontaps3.New(abc)
 	case "Disk":
 		return disk.New(abc)
 	case "ExternalServiceOperation":
@@ -187,9 +202,10 @@ func (z *ZapiPerf) InitCache() error {
 	z.Matrix[z.Object].Object = z.object
 	z.Logger.Debug().Msgf("object= %s --> %s", z.Object, z.object)
 
-	// Add metadata metric for skips/numPartials
+	// Add metadata metric for skips/numPartials/invalidValues
 	_, _ = z.Metadata.NewMetricUint64("skips")
 	_, _ = z.Metadata.NewMetricUint64("numPartials")
+	_, _ = z.Metadata.NewMetricUint64("invalidValues")
 
 	return nil
 }
@@ -396,6 +412,7 @@ func (z *ZapiPerf) PollData() (map[string]*matrix.Matrix, error) {
 	// clone matrix without numeric data and non-exportable all instances
 	curMat := prevMat.Clone(matrix.With{Data: false, Metrics: true, Instances: true, ExportInstances: false})
 	curMat.Reset()
+	curMat.ResetInvalid()
 
 	timestamp := curMat.GetMetric(timestampMetricName)
 	if timestamp == nil {
@@ -723,6 +740,12 @@ func (z *ZapiPerf) PollData() (map[string]*matrix.Matrix, error) {
 		} // end loop over instances
 	} // end batch request
 
+	// share the raw ops of workloads, so the workload detail collectors do not need to poll them again
+	if z.Query == objWorkload || z.Query == objWorkloadVolume {
+		key := bus.Key{Cluster: z.Client.Name(), Object: z.Query, Metric: "ops"}
+		z.Bus.Publish(key, bus.Snapshot(curMat, "ops", "workload", time.Now()))
+	}
+
 	if z.Query == objWorkloadDetail || z.Query == objWorkloadDetailVolume {
 		if rd, pd, err := z.getParentOpsCounters(curMat, z.keyName); err == nil {
 			apiT += rd
@@ -890,9 +913,14 @@ func (z *ZapiPerf) PollData() (map[string]*matrix.Matrix, error) {
 
 	_ = z.Metadata.LazySetValueInt64("calc_time", "data", calcD.Microseconds())
 	_ = z.Metadata.LazySetValueUint64("skips", "data", uint64(totalSkips))
+	_ = z.Metadata.LazySetValueUint64("invalidValues", "data", uint64(curMat.InvalidCount()))
 
-	// store cache for next poll
+	// store cache for next poll and recycle the previous cache
 	z.Matrix[z.Object] = cachedData
+	prevMat.Release()
+
+	// curMat is released by the collector after it is exported
+	curMat.SetReleasable(true)
 
 	newDataMap := make(map[string]*matrix.Matrix)
 	newDataMap[z.Object] = curMat
@@ -901,8 +929,8 @@ func (z *ZapiPerf) PollData() (map[string]*matrix.Matrix, error) {
 
 // Poll counter "ops" of the related/parent object, required for objects
 // workload_detail and workload_detail_volume. This counter is already
-// collected by the other ZapiPerf collectors, so this poll is redundant
-// (until we implement some sort of inter-collector communication).
+// collected by the other ZapiPerf collectors, so it is only polled when
+// the bus does not have fresh ops for every instance.
 func (z *ZapiPerf) getParentOpsCounters(data *matrix.Matrix, keyAttr string) (time.Duration, time.Duration, error) {
 
 	var (
@@ -928,6 +956,13 @@ func (z *ZapiPerf) getParentOpsCounters(data *matrix.Matrix, keyAttr string) (ti
 		return apiT, parseT, errs.New(errs.ErrMissingParam, "counter ops")
 	}
 
+	key := bus.Key{Cluster: z.Client.Name(), Object: object, Metric: "ops"}
+	if shared, ok := z.FreshShared(key, z.sharedOpsTime); ok && shared.Apply(data, "ops") {
+		z.sharedOpsTime = shared.Time
+		z.Logger.Debug().Str("object", object).Time("collected", shared.Time).Msg("Using shared ops of parent object")
+		return apiT, parseT, nil
+	}
+
 	instanceKeys = data.GetInstanceKeys()
 
 	// build ZAPI request
@@ -1100,6 +1135,10 @@ func (z *ZapiPerf) PollCounter() (map[string]*matrix.Matrix, error) {
 		return nil, errs.New(errs.ErrNoMetric, "no counters in response")
 	}
 
+	if err := z.translateCounters(wanted, counters); err != nil {
+		return nil, err
+	}
+
 	for key, counter := range counters {
 
 		// override counter properties from template
@@ -1331,6 +1370,7 @@ func (z *ZapiPerf) addCounter(counter *node.Node, name, display string, enabled
 	}
 
 	baseCounter = counter.GetChildContentS("base-counter")
+	unit := counter.GetChildContentS("unit")
 
 	if display == "" {
 		display = strings.ReplaceAll(name, "-", "_") // redundant for zapiperf
@@ -1416,6 +1456,7 @@ func (z *ZapiPerf) addCounter(counter *node.Node, name, display string, enabled
 
 			m.SetProperty(property)
 			m.SetComment(baseKey)
+			m.SetUnit(unit)
 			m.SetExportable(enabled)
 
 			if x := strings.Split(label, "."); len(x) == 2 {
@@ -1449,6 +1490,7 @@ func (z *ZapiPerf) addCounter(counter *node.Node, name, display string, enabled
 		z.scalarCounters = append(z.scalarCounters, name)
 		m.SetProperty(property)
 		m.SetComment(baseCounter)
+		m.SetUnit(unit)
 		m.SetExportable(enabled)
 
 	}
@@ -1669,3 +1711,33 @@ func (z *ZapiPerf) buildKeyValue(i *node.Node, keys []string) string {
 var (
 	_ collector.Collector = (*ZapiPerf)(nil)
 )
+
+// translateCounters renames the template counters that are not in the counter list of the object to their name
+// in the ONTAP release of the cluster, see collectors.CounterRenames. The display names of the counters do not change
+func (z *ZapiPerf) translateCounters(wanted map[string]string, counters map[string]*node.Node) error {
+	renames, err := collectors.CounterRenames(z.Params, z.Query)
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+	for name, display := range wanted {
+		if _, ok := counters[name]; ok {
+			continue
+		}
+		translated, release, ok := collectors.TranslateCounter(renames, name, z.HostVersion)
+		if !ok || counters[translated] == nil {
+			continue
+		}
+		if _, has := wanted[translated]; has {
+			continue
+		}
+		wanted[translated] = display
+		delete(wanted, name)
+		z.Logger.Info().
+			Str("counter", name).
+			Str("translated", translated).
+			Str("release", release).
+			Str("clusterVersion", z.HostVersion).
+			Msg("Translated renamed counter")
+	}
+	return nil
+}
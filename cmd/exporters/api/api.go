@@ -0,0 +1,370 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package api serves the latest matrix of each object as JSON over HTTP.
+
+It is meant for automation that wants point-in-time data of a poller without scraping and parsing
+the Prometheus exposition format. The exporter keeps a snapshot of the last export of each matrix,
+and serves the snapshots, filtered by the query parameters of the request, on /api/v1/objects.
+*/
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Matrix is the snapshot of the last export of a matrix
+type Matrix struct {
+	Collector    string            `json:"collector"`
+	Object       string            `json:"object"`
+	Identifier   string            `json:"identifier"`
+	Timestamp    int64             `json:"timestamp"` // unix milliseconds of the export
+	GlobalLabels map[string]string `json:"global_labels,omitempty"`
+	Metrics      []Metric          `json:"metrics"`
+	Instances    []Instance        `json:"instances"`
+}
+
+// Metric is the metadata of a metric. Values of instances are keyed by the key of their metric
+type Metric struct {
+	Key      string            `json:"key"`
+	Name     string            `json:"name"`
+	Labels   map[string]string `json:"labels,omitempty"` // labels of array metrics, like histogram buckets
+	Property string            `json:"property,omitempty"`
+	Unit     string            `json:"unit,omitempty"`
+}
+
+// Instance has the labels and the metric values of an instance
+type Instance struct {
+	Key    string                 `json:"key"`
+	Labels map[string]string      `json:"labels"`
+	Values map[string]json.Number `json:"values"` // the string values keep the precision of uint64 counters
+}
+
+// Summary describes a snapshot in the list of objects
+type Summary struct {
+	Collector  string `json:"collector"`
+	Object     string `json:"object"`
+	Identifier string `json:"identifier"`
+	Timestamp  int64  `json:"timestamp"`
+	Instances  int    `json:"instances"`
+}
+
+type API struct {
+	*exporter.AbstractExporter
+	mu        sync.RWMutex
+	snapshots map[string]*Matrix // by collector.object.identifier
+}
+
+func New(abc *exporter.AbstractExporter) exporter.Exporter {
+	return &API{AbstractExporter: abc}
+}
+
+func (a *API) Init() error {
+	if err := a.InitAbc(); err != nil {
+		return err
+	}
+	a.snapshots = make(map[string]*Matrix)
+
+	if a.Params.Port == nil || *a.Params.Port == 0 {
+		return errs.New(errs.ErrMissingParam, "port")
+	}
+	port := *a.Params.Port
+	if port < 0 {
+		return errs.New(errs.ErrInvalidParam, "port")
+	}
+
+	// The optional parameter LocalHTTPAddr is the address of the HTTP service, valid values are:
+	// - "localhost" or "127.0.0.1", this limits access to local machine
+	// - "" (default) or "0.0.0.0", allows access from network
+	addr := a.Params.LocalHTTPAddr
+
+	if !a.Params.IsTest {
+		go a.startHTTPD(addr, port)
+	}
+
+	a.Logger.Debug().Str("addr", addr).Int("port", port).Msg("initialized")
+	return nil
+}
+
+func (a *API) startHTTPD(addr string, port int) {
+	server := &http.Server{
+		Addr:              net.JoinHostPort(addr, strconv.Itoa(port)),
+		Handler:           a.Handler(),
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+
+	scheme := "http"
+	if a.Params.TLS.KeyFile != "" {
+		tlsConfig, err := a.Params.TLS.Options().Server()
+		if err != nil {
+			a.Logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
+		}
+		server.TLSConfig = tlsConfig
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/api/v1/objects", scheme, server.Addr)
+	a.Logger.Info().Str("url", url).Msg("server listen")
+
+	var err error
+	if a.Params.TLS.KeyFile != "" {
+		err = server.ListenAndServeTLS(a.Params.TLS.CertFile, a.Params.TLS.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.Logger.Fatal().Err(err).Str("url", url).Msg("Failed to start server")
+	}
+}
+
+// Handler returns the handler of the API
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/objects", a.ServeObjects)
+	mux.HandleFunc("GET /api/v1/objects/{object}", a.ServeObject)
+	return mux
+}
+
+func (a *API) Export(data *matrix.Matrix) (exporter.Stats, error) {
+	start := time.Now()
+	snapshot, stats := newSnapshot(data, start)
+	if prefix, err := matrix.ExportPrefix(data.GetExportOptions()); err != nil {
+		a.Logger.Error().Err(err).Str("object", data.Object).Msg("parameter: prefix")
+	} else {
+		snapshot.Object = prefix + snapshot.Object
+	}
+
+	a.mu.Lock()
+	a.snapshots[data.UUID+"."+data.Object+"."+data.Identifier] = snapshot
+	a.mu.Unlock()
+
+	a.AddExportCount(stats.MetricsExported)
+	if err := a.Metadata.LazyAddValueInt64("time", "export", time.Since(start).Microseconds()); err != nil {
+		a.Logger.Error().Err(err).Msg("metadata export time")
+	}
+	return stats, nil
+}
+
+// newSnapshot returns a copy of the exportable instances and metrics of data, since collectors reuse their matrices
+func newSnapshot(data *matrix.Matrix, t time.Time) (*Matrix, exporter.Stats) {
+	var stats exporter.Stats
+	snapshot := &Matrix{
+		Collector:    data.UUID,
+		Object:       data.Object,
+		Identifier:   data.Identifier,
+		Timestamp:    t.UnixMilli(),
+		GlobalLabels: make(map[string]string, len(data.GetGlobalLabels())),
+		Metrics:      make([]Metric, 0, len(data.GetMetrics())),
+		Instances:    make([]Instance, 0, len(data.GetInstances())),
+	}
+	for label, value := range data.GetGlobalLabels() {
+		snapshot.GlobalLabels[label] = value
+	}
+
+	metrics := make(map[string]*matrix.Metric)
+	for key, metric := range data.GetMetrics() {
+		if !metric.IsExportable() {
+			continue
+		}
+		metrics[key] = metric
+		m := Metric{Key: key, Name: metric.GetName(), Property: metric.GetProperty(), Unit: metric.GetUnit()}
+		if metric.HasLabels() {
+			m.Labels = make(map[string]string)
+			for label, value := range metric.GetLabels() {
+				m.Labels[label] = value
+			}
+		}
+		snapshot.Metrics = append(snapshot.Metrics, m)
+	}
+	slices.SortFunc(snapshot.Metrics, func(x, y Metric) int {
+		return strings.Compare(x.Key, y.Key)
+	})
+
+	for key, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		i := Instance{
+			Key:    key,
+			Labels: make(map[string]string, len(instance.GetLabels())),
+			Values: make(map[string]json.Number),
+		}
+		for label, value := range instance.GetLabels() {
+			i.Labels[label] = value
+		}
+		for mKey, metric := range metrics {
+			value, ok := metric.GetValueString(instance)
+			if !ok {
+				continue
+			}
+			// JSON has no NaN or Inf numbers
+			if v, _ := metric.GetValueFloat64(instance); math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			i.Values[mKey] = json.Number(value)
+		}
+		snapshot.Instances = append(snapshot.Instances, i)
+		stats.InstancesExported++
+		stats.MetricsExported += uint64(len(i.Values))
+	}
+	slices.SortFunc(snapshot.Instances, func(x, y Instance) int {
+		return strings.Compare(x.Key, y.Key)
+	})
+	return snapshot, stats
+}
+
+// ServeObjects lists the snapshots of the exporter
+func (a *API) ServeObjects(w http.ResponseWriter, _ *http.Request) {
+	a.mu.RLock()
+	summaries := make([]Summary, 0, len(a.snapshots))
+	for _, s := range a.snapshots {
+		summaries = append(summaries, Summary{
+			Collector:  s.Collector,
+			Object:     s.Object,
+			Identifier: s.Identifier,
+			Timestamp:  s.Timestamp,
+			Instances:  len(s.Instances),
+		})
+	}
+	a.mu.RUnlock()
+
+	slices.SortFunc(summaries, func(x, y Summary) int {
+		return strings.Compare(x.Collector+"."+x.Object+"."+x.Identifier, y.Collector+"."+y.Object+"."+y.Identifier)
+	})
+	a.write(w, summaries)
+}
+
+// ServeObject returns the snapshots of an object, filtered by the query parameters of the request:
+//   - collector: only the snapshots of the collector, e.g. RestPerf
+//   - instance: only the instance with the key
+//   - label.NAME: only the instances with the value of the label NAME, e.g. label.svm=vs1
+//   - metric: only the metrics with the name, can be repeated
+func (a *API) ServeObject(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	object := r.PathValue("object")
+	var matrices []*Matrix
+	a.mu.RLock()
+	for _, s := range a.snapshots {
+		if s.Object == object && (f.collector == "" || s.Collector == f.collector) {
+			matrices = append(matrices, s)
+		}
+	}
+	a.mu.RUnlock()
+
+	if len(matrices) == 0 {
+		http.Error(w, "no data for object "+object, http.StatusNotFound)
+		return
+	}
+
+	result := make([]*Matrix, 0, len(matrices))
+	for _, m := range matrices {
+		result = append(result, f.apply(m))
+	}
+	slices.SortFunc(result, func(x, y *Matrix) int {
+		return strings.Compare(x.Collector+"."+x.Identifier, y.Collector+"."+y.Identifier)
+	})
+	a.write(w, result)
+}
+
+func (a *API) write(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.Logger.Error().Err(err).Msg("write response")
+	}
+}
+
+// filter selects the instances and metrics of a snapshot
+type filter struct {
+	collector string
+	instance  string
+	labels    map[string]string
+	metrics   []string
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	f := filter{labels: make(map[string]string)}
+	for param, values := range r.URL.Query() {
+		switch {
+		case param == "collector":
+			f.collector = values[0]
+		case param == "instance":
+			f.instance = values[0]
+		case param == "metric":
+			f.metrics = append(f.metrics, values...)
+		case strings.HasPrefix(param, "label."):
+			f.labels[strings.TrimPrefix(param, "label.")] = values[0]
+		default:
+			return f, fmt.Errorf("unknown query parameter %s", param)
+		}
+	}
+	return f, nil
+}
+
+// apply returns a copy of m with the instances and metrics of the filter.
+// Snapshots are shared by requests, so they are never changed
+func (f filter) apply(m *Matrix) *Matrix {
+	if f.instance == "" && len(f.labels) == 0 && len(f.metrics) == 0 {
+		return m
+	}
+	result := *m
+
+	keys := make(map[string]bool)
+	if len(f.metrics) > 0 {
+		result.Metrics = make([]Metric, 0, len(f.metrics))
+		for _, metric := range m.Metrics {
+			if slices.Contains(f.metrics, metric.Name) {
+				result.Metrics = append(result.Metrics, metric)
+				keys[metric.Key] = true
+			}
+		}
+	}
+
+	result.Instances = make([]Instance, 0)
+	for _, instance := range m.Instances {
+		if !f.matches(instance) {
+			continue
+		}
+		if len(f.metrics) > 0 {
+			values := make(map[string]json.Number)
+			for key, value := range instance.Values {
+				if keys[key] {
+					values[key] = value
+				}
+			}
+			instance.Values = values
+		}
+		result.Instances = append(result.Instances, instance)
+	}
+	return &result
+}
+
+func (f filter) matches(instance Instance) bool {
+	if f.instance != "" && instance.Key != f.instance {
+		return false
+	}
+	for label, value := range f.labels {
+		if instance.Labels[label] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setupAPI(t *testing.T) *API {
+	t.Helper()
+	opts := options.New()
+	opts.IsTest = true
+	port := 13001
+	params := conf.Exporter{Type: "API", Port: &port, IsTest: true}
+	a := &API{AbstractExporter: exporter.New("API", "api", opts, params, nil)}
+	if err := a.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func newVolumes(collector string) *matrix.Matrix {
+	data := matrix.New(collector, "volume", "volume")
+	data.SetGlobalLabel("cluster", "c1")
+	size, _ := data.NewMetricUint64("space.size", "size")
+	used, _ := data.NewMetricUint64("space.used", "used")
+	hidden, _ := data.NewMetricUint64("internal")
+	hidden.SetExportable(false)
+	for i, svm := range []string{"svm1", "svm2"} {
+		instance, _ := data.NewInstance(svm + "vol1")
+		instance.SetLabel("svm", svm)
+		instance.SetLabel("volume", "vol1")
+		_ = size.SetValueInt64(instance, int64(100*(i+1)))
+		_ = used.SetValueInt64(instance, int64(10*(i+1)))
+	}
+	skipped, _ := data.NewInstance("skipped")
+	skipped.SetExportable(false)
+	return data
+}
+
+func TestServeObject(t *testing.T) {
+	a := setupAPI(t)
+	if _, err := a.Export(newVolumes("Rest")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Export(newVolumes("Zapi")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		url           string
+		wantStatus    int
+		wantMatrices  int
+		wantInstances int
+		wantValues    int
+	}{
+		{name: "all", url: "/api/v1/objects/volume", wantStatus: http.StatusOK, wantMatrices: 2, wantInstances: 2, wantValues: 2},
+		{name: "collector", url: "/api/v1/objects/volume?collector=Rest", wantStatus: http.StatusOK, wantMatrices: 1, wantInstances: 2, wantValues: 2},
+		{name: "label", url: "/api/v1/objects/volume?collector=Rest&label.svm=svm2", wantStatus: http.StatusOK, wantMatrices: 1, wantInstances: 1, wantValues: 2},
+		{name: "instance", url: "/api/v1/objects/volume?instance=svm1vol1", wantStatus: http.StatusOK, wantMatrices: 2, wantInstances: 1, wantValues: 2},
+		{name: "metric", url: "/api/v1/objects/volume?collector=Zapi&metric=used", wantStatus: http.StatusOK, wantMatrices: 1, wantInstances: 2, wantValues: 1},
+		{name: "unknown object", url: "/api/v1/objects/aggr", wantStatus: http.StatusNotFound},
+		{name: "unknown parameter", url: "/api/v1/objects/volume?svm=svm1", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status got=%d, want=%d body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var matrices []Matrix
+			if err := json.Unmarshal(w.Body.Bytes(), &matrices); err != nil {
+				t.Fatal(err)
+			}
+			if len(matrices) != tt.wantMatrices {
+				t.Fatalf("matrices got=%d, want=%d", len(matrices), tt.wantMatrices)
+			}
+			for _, m := range matrices {
+				if len(m.Instances) != tt.wantInstances {
+					t.Errorf("%s instances got=%d, want=%d", m.Collector, len(m.Instances), tt.wantInstances)
+				}
+				if len(m.Metrics) != tt.wantValues {
+					t.Errorf("%s metrics got=%d, want=%d", m.Collector, len(m.Metrics), tt.wantValues)
+				}
+				for _, instance := range m.Instances {
+					if len(instance.Values) != tt.wantValues {
+						t.Errorf("%s %s values got=%d, want=%d", m.Collector, instance.Key, len(instance.Values), tt.wantValues)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestServeObjects(t *testing.T) {
+	a := setupAPI(t)
+	data := newVolumes("Rest")
+	if _, err := a.Export(data); err != nil {
+		t.Fatal(err)
+	}
+	// snapshots are copies, collectors reuse their matrices
+	data.PurgeInstances()
+
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/objects", nil))
+	var summaries []Summary
+	if err := json.Unmarshal(w.Body.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Collector != "Rest" || summaries[0].Instances != 2 {
+		t.Errorf("summaries got=%+v, want one Rest volume with 2 instances", summaries)
+	}
+}
+
+func TestNewSnapshotPrecision(t *testing.T) {
+	data := matrix.New("Rest", "volume", "volume")
+	ops, _ := data.NewMetricUint64("ops")
+	latency, _ := data.NewMetricFloat64("latency")
+	instance, _ := data.NewInstance("vol1")
+	_ = ops.SetValueUint64(instance, 1<<63+1)
+	_ = latency.SetValueFloat64(instance, math.NaN())
+
+	snapshot, _ := newSnapshot(data, time.Now())
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Matrix
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	values := got.Instances[0].Values
+	if values["ops"] != "9223372036854775809" {
+		t.Errorf("ops got=%s, want=9223372036854775809", values["ops"])
+	}
+	if _, ok := values["latency"]; ok {
+		t.Errorf("latency got=%s, want NaN left out", values["latency"])
+	}
+}
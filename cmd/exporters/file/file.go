@@ -0,0 +1,248 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package file writes metrics as JSON Lines to rotating local files.
+
+It is meant for sites without network access to a time-series database:
+the files are collected, transferred, and replayed into a database later with "harvest import".
+Each line is a Record with the exportable metrics of one instance and the time they were exported.
+*/
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"math"
+	"time"
+)
+
+const (
+	defaultMaxFileBytes = 100 * 1024 * 1024
+	defaultRotateEvery  = time.Hour
+	defaultRetention    = 7 * 24 * time.Hour
+)
+
+// Record is one line of a file: the exportable metrics of one instance
+type Record struct {
+	Timestamp int64             `json:"timestamp"` // unix milliseconds
+	Object    string            `json:"object"`
+	Labels    map[string]string `json:"labels"`
+	Info      map[string]string `json:"info,omitempty"` // instance_labels
+	Metrics   []Sample          `json:"metrics"`
+}
+
+// Sample is the value of one metric. Labels are set for array metrics and metric keys
+type Sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  json.Number       `json:"value"`
+}
+
+type File struct {
+	*exporter.AbstractExporter
+	writer *rotator
+}
+
+func New(abc *exporter.AbstractExporter) exporter.Exporter {
+	return &File{AbstractExporter: abc}
+}
+
+func (f *File) Init() error {
+	if err := f.InitAbc(); err != nil {
+		return err
+	}
+
+	if f.Params.Path == "" {
+		return errs.New(errs.ErrMissingParam, "path")
+	}
+
+	maxFileBytes := int64(defaultMaxFileBytes)
+	if f.Params.MaxFileBytes < 0 {
+		return errs.New(errs.ErrInvalidParam, "max_file_bytes must not be negative")
+	} else if f.Params.MaxFileBytes > 0 {
+		maxFileBytes = f.Params.MaxFileBytes
+	}
+	if f.Params.MaxFiles < 0 {
+		return errs.New(errs.ErrInvalidParam, "max_files must not be negative")
+	}
+
+	rotateEvery, err := parseDuration("rotate_every", f.Params.RotateEvery, defaultRotateEvery)
+	if err != nil {
+		return err
+	}
+	retention, err := parseDuration("retention", f.Params.Retention, defaultRetention)
+	if err != nil {
+		return err
+	}
+
+	f.writer, err = newRotator(rotatorOptions{
+		dir:         f.Params.Path,
+		prefix:      "harvest-" + f.Options.Poller,
+		maxBytes:    maxFileBytes,
+		rotateEvery: rotateEvery,
+		retention:   retention,
+		maxFiles:    f.Params.MaxFiles,
+		compress:    f.Params.Compress,
+	})
+	if err != nil {
+		return err
+	}
+
+	f.Logger.Debug().
+		Str("path", f.Params.Path).
+		Int64("maxFileBytes", maxFileBytes).
+		Str("rotateEvery", rotateEvery.String()).
+		Str("retention", retention.String()).
+		Int("maxFiles", f.Params.MaxFiles).
+		Bool("compress", f.Params.Compress).
+		Msg("initialized")
+	return nil
+}
+
+func parseDuration(param string, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, errs.New(errs.ErrInvalidParam, fmt.Sprintf("%s [%s] is not a positive duration", param, value))
+	}
+	return d, nil
+}
+
+func (f *File) Export(data *matrix.Matrix) (exporter.Stats, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	start := time.Now()
+	lines, stats, err := f.Render(data, start)
+	if err != nil {
+		return stats, err
+	}
+	if err := f.Metadata.LazyAddValueInt64("time", "render", time.Since(start).Microseconds()); err != nil {
+		f.Logger.Error().Err(err).Msg("metadata render time")
+	}
+
+	if len(lines) > 0 {
+		if err := f.writer.write(lines); err != nil {
+			return stats, fmt.Errorf("unable to write object: %s, uuid: %s, err=%w", data.Object, data.UUID, err)
+		}
+	}
+	f.AddExportCount(uint64(len(lines)))
+
+	if err := f.Metadata.LazyAddValueInt64("time", "export", time.Since(start).Microseconds()); err != nil {
+		f.Logger.Error().Err(err).Msg("metadata export time")
+	}
+	f.Logger.Debug().Str("object", data.Object).Str("uuid", data.UUID).Int("numLines", len(lines)).Msg("exported")
+
+	return stats, nil
+}
+
+// Render returns one JSON line for each exportable instance of data that has at least one metric value
+func (f *File) Render(data *matrix.Matrix, t time.Time) ([][]byte, exporter.Stats, error) {
+	var (
+		stats                          exporter.Stats
+		keysToInclude, labelsToInclude []string
+		metricKeys                     map[string][]string
+	)
+
+	options := data.GetExportOptions()
+	includeAll := options.GetChildContentS("include_all_labels") == "true"
+	if x := options.GetChildS("instance_keys"); x != nil {
+		keysToInclude = x.GetAllChildContentS()
+	}
+	if x := options.GetChildS("instance_labels"); x != nil {
+		labelsToInclude = x.GetAllChildContentS()
+	}
+	// metric keys are redundant when all labels are included
+	if !includeAll {
+		metricKeys = matrix.MetricKeys(options)
+	}
+	prefix, err := matrix.ExportPrefix(options)
+	if err != nil {
+		f.Logger.Error().Err(err).Str("object", data.Object).Msg("parameter: prefix")
+	}
+
+	lines := make([][]byte, 0, len(data.GetInstances()))
+
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+
+		record := Record{
+			Timestamp: t.UnixMilli(),
+			Object:    prefix + data.Object,
+			Labels:    make(map[string]string),
+		}
+		for label, value := range data.GetGlobalLabels() {
+			record.Labels[label] = value
+		}
+		if includeAll {
+			for label, value := range instance.GetLabels() {
+				record.Labels[label] = value
+			}
+		} else {
+			for _, key := range keysToInclude {
+				if value, ok := instance.GetLabels()[key]; ok {
+					record.Labels[key] = value
+				}
+			}
+		}
+		for _, label := range labelsToInclude {
+			if value, ok := instance.GetLabels()[label]; ok {
+				if record.Info == nil {
+					record.Info = make(map[string]string)
+				}
+				record.Info[label] = value
+			}
+		}
+
+		for _, metric := range data.GetMetrics() {
+			if !metric.IsExportable() {
+				continue
+			}
+			// the string value keeps the precision of uint64 counters
+			value, ok := metric.GetValueString(instance)
+			if !ok {
+				continue
+			}
+			// JSON has no NaN or Inf numbers
+			if v, _ := metric.GetValueFloat64(instance); math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			sample := Sample{Name: metric.GetName(), Value: json.Number(value)}
+			if metric.HasLabels() || len(metricKeys[metric.GetName()]) > 0 {
+				sample.Labels = make(map[string]string)
+				for label, value := range metric.GetLabels() {
+					sample.Labels[label] = value
+				}
+				for _, key := range metricKeys[metric.GetName()] {
+					if value := instance.GetLabel(key); value != "" {
+						sample.Labels[key] = value
+					}
+				}
+			}
+			record.Metrics = append(record.Metrics, sample)
+		}
+
+		if len(record.Metrics) == 0 && len(record.Info) == 0 {
+			continue
+		}
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, stats, err
+		}
+		lines = append(lines, line)
+		stats.InstancesExported++
+		stats.MetricsExported += uint64(len(record.Metrics))
+	}
+
+	return lines, stats, nil
+}
@@ -0,0 +1,191 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setupFile(t *testing.T, params conf.Exporter) *File {
+	t.Helper()
+	opts := options.New()
+	opts.IsTest = true
+	opts.Poller = "test"
+	params.Type = "File"
+	f := &File{AbstractExporter: exporter.New("File", "file", opts, params, nil)}
+	if err := f.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func newVolumes(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+instance_labels:
+  - state
+metric_keys:
+  read_ops:
+    - client_ip
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := matrix.New("volume", "volume", "volume")
+	data.SetGlobalLabel("cluster", "c1")
+	data.SetExportOptions(options)
+	readOps, _ := data.NewMetricUint64("read_ops")
+	latency, _ := data.NewMetricFloat64("read_latency_hist.<2us", "read_latency_hist")
+	latency.SetLabel("metric", "<2us")
+	instance, _ := data.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	instance.SetLabel("client_ip", "10.0.0.1")
+	instance.SetLabel("state", "online")
+	_ = readOps.SetValueInt64(instance, 1)
+	_ = latency.SetValueFloat64(instance, 2.5)
+	hidden, _ := data.NewInstance("B")
+	hidden.SetExportable(false)
+	return data
+}
+
+func TestRender(t *testing.T) {
+	f := setupFile(t, conf.Exporter{Path: t.TempDir()})
+	ts := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+
+	lines, stats, err := f.Render(newVolumes(t), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("Render() got=%d lines, want=1", len(lines))
+	}
+	var got Record
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Record{
+		Timestamp: ts.UnixMilli(),
+		Object:    "volume",
+		Labels:    map[string]string{"cluster": "c1", "volume": "vol1"},
+		Info:      map[string]string{"state": "online"},
+		Metrics: []Sample{
+			{Name: "read_latency_hist", Labels: map[string]string{"metric": "<2us"}, Value: "2.5"},
+			{Name: "read_ops", Labels: map[string]string{"client_ip": "10.0.0.1"}, Value: "1"},
+		},
+	}
+	if got.Metrics[0].Name != want.Metrics[0].Name {
+		got.Metrics[0], got.Metrics[1] = got.Metrics[1], got.Metrics[0]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Render() got=%+v, want=%+v", got, want)
+	}
+	if stats.InstancesExported != 1 || stats.MetricsExported != 2 {
+		t.Errorf("Render() stats got=%+v, want 1 instance and 2 metrics", stats)
+	}
+}
+
+func TestRenderNonFinite(t *testing.T) {
+	f := setupFile(t, conf.Exporter{Path: t.TempDir()})
+	data := matrix.New("volume", "volume", "volume")
+	instance, _ := data.NewInstance("A")
+	for name, value := range map[string]float64{"read_ops": 1, "nan": math.NaN(), "inf": math.Inf(1), "neg_inf": math.Inf(-1)} {
+		metric, _ := data.NewMetricFloat64(name)
+		_ = metric.SetValueFloat64(instance, value)
+	}
+
+	lines, stats, err := f.Render(data, time.Now())
+	if err != nil {
+		t.Fatalf("Render() of non-finite values got err=%v", err)
+	}
+	if len(lines) != 1 || stats.MetricsExported != 1 {
+		t.Fatalf("Render() got=%d lines and %d metrics, want=1 line and 1 metric", len(lines), stats.MetricsExported)
+	}
+	var got Record
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Metrics) != 1 || got.Metrics[0].Name != "read_ops" {
+		t.Errorf("Render() got=%+v, want only read_ops", got.Metrics)
+	}
+}
+
+func TestExportRotation(t *testing.T) {
+	dir := t.TempDir()
+	f := setupFile(t, conf.Exporter{Path: dir, MaxFileBytes: 1, MaxFiles: 2, Compress: true})
+	now := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+	f.writer.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for range 3 {
+		if _, err := f.Export(newVolumes(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "harvest-test-*"+CompressedExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got=%d files, want=2 %v", len(files), files)
+	}
+
+	// the active file is readable before it is closed
+	file, err := os.Open(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(gz)
+	if !scanner.Scan() {
+		t.Fatalf("active file has no lines err=%v", scanner.Err())
+	}
+	var record Record
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Object != "volume" {
+		t.Errorf("record object got=%s, want=volume", record.Object)
+	}
+}
+
+func TestInitParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params conf.Exporter
+	}{
+		{name: "missing path", params: conf.Exporter{}},
+		{name: "invalid retention", params: conf.Exporter{Path: t.TempDir(), Retention: "a week"}},
+		{name: "negative rotate_every", params: conf.Exporter{Path: t.TempDir(), RotateEvery: "-1h"}},
+		{name: "negative max_files", params: conf.Exporter{Path: t.TempDir(), MaxFiles: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options.New()
+			opts.IsTest = true
+			f := &File{AbstractExporter: exporter.New("File", "file", opts, tt.params, nil)}
+			if err := f.Init(); err == nil {
+				t.Errorf("Init() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	Extension           = ".jsonl"
+	CompressedExtension = ".jsonl.gz"
+	timeFormat          = "20060102T150405.000000000"
+)
+
+type rotatorOptions struct {
+	dir         string
+	prefix      string
+	maxBytes    int64
+	rotateEvery time.Duration
+	retention   time.Duration
+	maxFiles    int // 0 keeps any number of files
+	compress    bool
+}
+
+// rotator appends lines to the active file of dir. It starts a new file when the active one
+// reaches maxBytes or is older than rotateEvery, and removes the files that are older than retention
+// or exceed maxFiles
+type rotator struct {
+	rotatorOptions
+	file   *os.File
+	gz     *gzip.Writer
+	w      io.Writer
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+func newRotator(options rotatorOptions) (*rotator, error) {
+	options.prefix = strings.ReplaceAll(options.prefix, string(os.PathSeparator), "_")
+	if err := os.MkdirAll(options.dir, 0750); err != nil {
+		return nil, err
+	}
+	return &rotator{rotatorOptions: options, now: time.Now}, nil
+}
+
+// write appends lines to the active file, and flushes them so the file is readable up to the last line
+func (r *rotator) write(lines [][]byte) error {
+	now := r.now()
+	if r.file == nil || r.size >= r.maxBytes || now.Sub(r.opened) >= r.rotateEvery {
+		if err := r.rotate(now); err != nil {
+			return err
+		}
+	}
+	for _, line := range lines {
+		if _, err := r.w.Write(line); err != nil {
+			return err
+		}
+		if _, err := r.w.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	if r.gz != nil {
+		return r.gz.Flush()
+	}
+	return nil
+}
+
+// Write counts the bytes written to the active file
+func (r *rotator) Write(p []byte) (int, error) {
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotator) rotate(now time.Time) error {
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	ext := Extension
+	if r.compress {
+		ext = CompressedExtension
+	}
+	name := filepath.Join(r.dir, r.prefix+"-"+now.UTC().Format(timeFormat)+ext)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.size = 0
+	r.opened = now
+	r.w = r
+	if r.compress {
+		r.gz = gzip.NewWriter(r)
+		r.w = r.gz
+	}
+
+	return r.prune(now)
+}
+
+func (r *rotator) close() error {
+	if r.file == nil {
+		return nil
+	}
+	var err error
+	if r.gz != nil {
+		err = r.gz.Close()
+		r.gz = nil
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	return err
+}
+
+// prune removes the files of this rotator that are older than retention, then the oldest files above maxFiles.
+// The active file is never removed
+func (r *rotator) prune(now time.Time) error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	active := filepath.Base(r.file.Name())
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == active || !r.owns(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > r.retention {
+			if err := os.Remove(filepath.Join(r.dir, name)); err != nil {
+				return fmt.Errorf("unable to remove %s: %w", name, err)
+			}
+			continue
+		}
+		files = append(files, name)
+	}
+
+	if r.maxFiles == 0 || len(files) < r.maxFiles {
+		return nil
+	}
+	// names sort by creation time, the active file counts as one
+	slices.Sort(files)
+	for _, name := range files[:len(files)-r.maxFiles+1] {
+		if err := os.Remove(filepath.Join(r.dir, name)); err != nil {
+			return fmt.Errorf("unable to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// owns returns true when name is the name of a file of this rotator
+func (r *rotator) owns(name string) bool {
+	stamp, ok := strings.CutPrefix(name, r.prefix+"-")
+	if !ok {
+		return false
+	}
+	if s, ok := strings.CutSuffix(stamp, CompressedExtension); ok {
+		stamp = s
+	} else if stamp, ok = strings.CutSuffix(stamp, Extension); !ok {
+		return false
+	}
+	_, err := time.Parse(timeFormat, stamp)
+	return err == nil
+}
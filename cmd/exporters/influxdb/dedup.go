@@ -0,0 +1,96 @@
+package influxdb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dedup removes the duplicate samples of redundant pollers that monitor the same cluster.
+//
+// Each poller polls at its own offset, so without a timestamp InfluxDB stores the samples of both pollers, at the
+// times they arrive. With dedup, the timestamp of a point is the start of the window it is exported in, so the
+// fingerprint of a point, its measurement, tags, and timestamp, is the same for both pollers. InfluxDB keeps one
+// point per fingerprint and overwrites the point of the first poller with the point of the second.
+// The exporter skips the points that it already wrote in the current window, e.g. when the window is longer than
+// the poll interval of an object.
+//
+// The series of a poller, like its metadata, have the name of the poller, so they are not deduplicated
+type dedup struct {
+	window    time.Duration
+	precision time.Duration // unit of the timestamps
+	start     time.Time     // start of the current window
+	sent      map[uint64]struct{}
+}
+
+// precisions are the units of the precision parameter of the InfluxDB write API, v1 and v2
+var precisions = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"n":  time.Nanosecond,
+	"us": time.Microsecond,
+	"u":  time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// newDedup returns the dedup of a dedup_window. The timestamps have the precision of the write URL,
+// which defaults to nanoseconds, like InfluxDB
+func newDedup(window string, writeURL string) (*dedup, error) {
+	w, err := time.ParseDuration(window)
+	if err != nil || w <= 0 {
+		return nil, fmt.Errorf("invalid dedup_window %q, must be a positive duration like 1m", window)
+	}
+	precision := "ns"
+	if u, err := url.Parse(writeURL); err == nil && u.Query().Has("precision") {
+		precision = u.Query().Get("precision")
+	}
+	unit, ok := precisions[precision]
+	if !ok {
+		return nil, fmt.Errorf("invalid precision %q for dedup_window, must be one of ns, us, ms, s, m, h", precision)
+	}
+	if w < unit {
+		return nil, fmt.Errorf("dedup_window %s is shorter than the precision %s", w, precision)
+	}
+	return &dedup{window: w, precision: unit, sent: make(map[uint64]struct{})}, nil
+}
+
+// timestamp returns the timestamp of the points exported at t, and forgets the points of the previous window
+func (d *dedup) timestamp(t time.Time) string {
+	start := t.Truncate(d.window)
+	if !start.Equal(d.start) {
+		d.start = start
+		clear(d.sent)
+	}
+	return strconv.FormatInt(start.UnixNano()/int64(d.precision), 10)
+}
+
+// isDuplicate returns true when a point with the fingerprint of m was written in the current window
+func (d *dedup) isDuplicate(m *Measurement) bool {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(m.measurement))
+	for _, tag := range m.tagSet {
+		_, _ = h.Write([]byte{','})
+		_, _ = h.Write([]byte(tag))
+	}
+	_, _ = h.Write([]byte{' '})
+	_, _ = h.Write([]byte(m.timestamp))
+	fingerprint := h.Sum64()
+	if _, ok := d.sent[fingerprint]; ok {
+		return true
+	}
+	d.sent[fingerprint] = struct{}{}
+	return false
+}
+
+// isPollerSeries returns true for the series that only one poller exports, like the metadata of its collectors
+func isPollerSeries(object string, globalLabels map[string]string) bool {
+	if _, ok := globalLabels["poller"]; ok {
+		return true
+	}
+	return object == "poller" || strings.HasPrefix(object, "metadata_")
+}
@@ -14,7 +14,9 @@ import (
 	"io"
 	"net/http"
 	url2 "net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -51,6 +53,7 @@ type InfluxDB struct {
 	client *http.Client
 	url    string
 	token  string
+	dedup  *dedup // nil unless dedup_window is set
 }
 
 func New(abc *exporter.AbstractExporter) exporter.Exporter {
@@ -118,6 +121,14 @@ func (e *InfluxDB) Init() error {
 			*url, *version, url2.PathEscape(*org), url2.PathEscape(*bucket), *precision)
 	}
 
+	if e.Params.DedupWindow != "" {
+		var err error
+		if e.dedup, err = newDedup(e.Params.DedupWindow, e.url); err != nil {
+			return err
+		}
+		e.Logger.Debug().Str("dedupWindow", e.dedup.window.String()).Msg("align timestamps to dedup window")
+	}
+
 	if token = e.Params.Token; token == nil {
 		return errs.New(errs.ErrMissingParam, "token")
 	}
@@ -139,7 +150,13 @@ func (e *InfluxDB) Init() error {
 	e.Logger.Debug().Str("dbEndpoint", dbEndpoint).Str("url", e.url).Send()
 
 	// construct HTTP client
-	e.client = &http.Client{Timeout: timeout}
+	tlsConfig, err := e.Params.TLS.Options().Client()
+	if err != nil {
+		e.Logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	e.client = &http.Client{Timeout: timeout, Transport: transport}
 
 	return nil
 }
@@ -227,6 +244,11 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 	rendered := make([][]byte, 0)
 
 	object := data.Object
+	if prefix, err := matrix.ExportPrefix(data.GetExportOptions()); err != nil {
+		e.Logger.Error().Err(err).Str("object", data.Object).Msg("parameter: prefix")
+	} else {
+		object = prefix + object
+	}
 
 	// user-defined preferences for export
 	var labelsToInclude, keysToInclude []string
@@ -240,6 +262,28 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 	if x := data.GetExportOptions().GetChildS("instance_labels"); x != nil {
 		labelsToInclude = x.GetAllChildContentS()
 	}
+	// metric keys are redundant when all labels are included
+	var metricKeys map[string][]string
+	if !includeAll {
+		metricKeys = matrix.MetricKeys(data.GetExportOptions())
+	}
+	// instance keys are tags and instance labels are fields, unless the template moves them with influx_fields,
+	// e.g. for high-cardinality keys like paths, or influx_tags
+	var asFields, asTags []string
+	if x := data.GetExportOptions().GetChildS("influx_fields"); x != nil {
+		asFields = x.GetAllChildContentS()
+	}
+	if x := data.GetExportOptions().GetChildS("influx_tags"); x != nil {
+		asTags = x.GetAllChildContentS()
+	}
+	tagKeys, fieldLabels := splitTagsFields(keysToInclude, labelsToInclude, asFields, asTags)
+
+	// with dedup, the points of redundant pollers have the same timestamp
+	var timestamp string
+	dedupe := e.dedup != nil && !isPollerSeries(data.Object, data.GetGlobalLabels())
+	if dedupe {
+		timestamp = e.dedup.timestamp(time.Now())
+	}
 
 	// measurement that we will not emit
 	// only to store global labels that we'll
@@ -262,16 +306,25 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 
 		m := NewMeasurement(object, len(global.tagSet))
 		copy(m.tagSet, global.tagSet)
+		m.SetTimestamp(timestamp)
 
 		// tag set
+		labelsAsFields := fieldLabels
 		if includeAll {
 			for label, value := range instance.GetLabels() {
-				if value != "" {
-					m.AddTag(label, value)
+				if value == "" {
+					continue
+				}
+				if slices.Contains(asFields, label) {
+					if !slices.Contains(labelsAsFields, label) {
+						labelsAsFields = append(slices.Clip(labelsAsFields), label)
+					}
+					continue
 				}
+				m.AddTag(label, value)
 			}
 		} else {
-			for _, key := range keysToInclude {
+			for _, key := range tagKeys {
 				if value, has := instance.GetLabels()[key]; has && value != "" {
 					m.AddTag(key, value)
 				}
@@ -286,7 +339,7 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 		// field set
 
 		// strings
-		for _, label := range labelsToInclude {
+		for _, label := range labelsAsFields {
 			if value, has := instance.GetLabels()[label]; has && value != "" {
 				if value == "true" || value == "false" {
 					m.AddField(label, value)
@@ -298,6 +351,9 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 			}
 		}
 
+		// metrics with metric keys are rendered in their own measurement, with the metric keys as extra tags
+		withKeys := make(map[string]*Measurement)
+
 		// numeric
 		for _, metric := range data.GetMetrics() {
 
@@ -323,6 +379,24 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 				fieldName = rename
 			}
 
+			if keys := metricKeys[metric.GetName()]; len(keys) > 0 {
+				id := strings.Join(keys, ",")
+				mk, ok := withKeys[id]
+				if !ok {
+					mk = NewMeasurement(object, len(m.tagSet))
+					copy(mk.tagSet, m.tagSet)
+					mk.SetTimestamp(timestamp)
+					for _, key := range keys {
+						if value, has := instance.GetLabels()[key]; has && value != "" && !slices.Contains(tagKeys, key) {
+							mk.AddTag(key, value)
+						}
+					}
+					withKeys[id] = mk
+				}
+				mk.AddField(fieldName, value)
+				continue
+			}
+
 			m.AddField(fieldName, value)
 			countTmp++
 		}
@@ -330,12 +404,31 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 		// skip instance with no tag set (no metrics)
 		if len(m.fieldSet) == 0 {
 			e.Logger.Debug().Msgf("skip instance (%s), no field set parsed", key)
+		} else if dedupe && e.dedup.isDuplicate(m) {
+			e.Logger.Debug().Msgf("skip instance (%s), already written in dedup window", key)
 		} else if r, err := m.Render(); err == nil {
 			rendered = append(rendered, []byte(r))
 			count += countTmp
 		} else {
 			e.Logger.Debug().Msg(err.Error())
 		}
+
+		ids := make([]string, 0, len(withKeys))
+		for id := range withKeys {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			if dedupe && e.dedup.isDuplicate(withKeys[id]) {
+				continue
+			}
+			if r, err := withKeys[id].Render(); err == nil {
+				rendered = append(rendered, []byte(r))
+				count += uint64(len(withKeys[id].fieldSet))
+			} else {
+				e.Logger.Debug().Msg(err.Error())
+			}
+		}
 	}
 
 	e.Logger.Debug().Msgf("rendered %d measurements with %d data points for (%s)", len(rendered), count, object)
@@ -347,3 +440,27 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 	}
 	return rendered, exporter.Stats{InstancesExported: instancesExported, MetricsExported: count}, nil
 }
+
+// splitTagsFields returns the labels that are exported as tags and as fields. Instance keys are tags and instance labels
+// are fields, except the keys of asFields, which are fields, and the labels of asTags, which are tags
+func splitTagsFields(keys, labels, asFields, asTags []string) ([]string, []string) {
+	var tags, fields []string
+	for _, key := range keys {
+		if slices.Contains(asFields, key) {
+			fields = append(fields, key)
+		} else {
+			tags = append(tags, key)
+		}
+	}
+	for _, label := range labels {
+		switch {
+		case slices.Contains(asTags, label):
+			if !slices.Contains(tags, label) {
+				tags = append(tags, label)
+			}
+		case !slices.Contains(fields, label):
+			fields = append(fields, label)
+		}
+	}
+	return tags, fields
+}
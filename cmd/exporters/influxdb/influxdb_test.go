@@ -8,7 +8,12 @@ import (
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupInfluxDB(t *testing.T, exporterName string) *InfluxDB {
@@ -133,3 +138,228 @@ func TestWhiteSpaceInParameter(t *testing.T) {
 		t.Fatalf("FAIL - expected [%s]\n                             got [%s]", expectedURL, influx.url)
 	}
 }
+
+func TestRenderMetricKeys(t *testing.T) {
+	influx := setupInfluxDB(t, "influx-test-url")
+
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+metric_keys:
+  read_ops:
+    - client_ip
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := matrix.New("volume", "volume", "volume")
+	data.SetExportOptions(options)
+	readOps, _ := data.NewMetricUint64("read_ops")
+	writeOps, _ := data.NewMetricUint64("write_ops")
+	instance, _ := data.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	instance.SetLabel("client_ip", "10.0.0.1")
+	_ = readOps.SetValueInt64(instance, 1)
+	_ = writeOps.SetValueInt64(instance, 2)
+
+	rendered, stats, err := influx.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, r := range rendered {
+		lines = append(lines, string(r))
+	}
+
+	want := []string{
+		"volume,volume=vol1 write_ops=2",
+		"volume,volume=vol1,client_ip=10.0.0.1 read_ops=1",
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Render() got=%v, want=%v", lines, want)
+	}
+	if stats.MetricsExported != 2 {
+		t.Errorf("Render() MetricsExported got=%d, want=2", stats.MetricsExported)
+	}
+}
+
+func TestRenderTagsFields(t *testing.T) {
+	influx := setupInfluxDB(t, "influx-test-url")
+
+	tests := []struct {
+		name    string
+		options string
+		want    string
+	}{
+		{
+			name: "default",
+			options: `
+instance_keys:
+  - path
+  - volume
+instance_labels:
+  - state
+`,
+			want: `file,path=/vol1/dir1,volume=vol1 state="online",size=2`,
+		},
+		{
+			name: "moved",
+			options: `
+instance_keys:
+  - path
+  - volume
+instance_labels:
+  - state
+influx_fields:
+  - path
+influx_tags:
+  - state
+`,
+			want: `file,volume=vol1,state=online path="/vol1/dir1",size=2`,
+		},
+		{
+			name: "all labels",
+			options: `
+include_all_labels: true
+influx_fields:
+  - path
+`,
+			want: `file,state=online,volume=vol1 path="/vol1/dir1",size=2`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := tree.LoadYaml([]byte(tt.options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := matrix.New("file", "file", "file")
+			data.SetExportOptions(options)
+			size, _ := data.NewMetricUint64("size")
+			instance, _ := data.NewInstance("A")
+			instance.SetLabel("volume", "vol1")
+			instance.SetLabel("path", "/vol1/dir1")
+			instance.SetLabel("state", "online")
+			_ = size.SetValueInt64(instance, 2)
+
+			rendered, _, err := influx.Render(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rendered) != 1 {
+				t.Fatalf("Render() got %d lines, want 1", len(rendered))
+			}
+			got := string(rendered[0])
+			if tt.name == "all labels" {
+				got = sortTags(got)
+			}
+			if got != tt.want {
+				t.Errorf("Render() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// sortTags sorts the tags of a line, since the labels of an instance are a map
+func sortTags(line string) string {
+	tags, fields, _ := strings.Cut(line, " ")
+	parts := strings.Split(tags, ",")
+	slices.Sort(parts[1:])
+	return strings.Join(parts, ",") + " " + fields
+}
+
+func TestRenderDedup(t *testing.T) {
+	influx := setupInfluxDB(t, "influx-test-url")
+	var err error
+	if influx.dedup, err = newDedup("24h", influx.url); err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := matrix.New("volume", "volume", "volume")
+	data.SetExportOptions(options)
+	data.SetGlobalLabel("cluster", "cluster1")
+	readOps, _ := data.NewMetricUint64("read_ops")
+	instance, _ := data.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	_ = readOps.SetValueInt64(instance, 1)
+
+	rendered, _, err := influx.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Now().Truncate(24 * time.Hour).Unix()
+	want := "volume,cluster=cluster1,volume=vol1 read_ops=1 " + strconv.FormatInt(timestamp, 10)
+	if len(rendered) != 1 || string(rendered[0]) != want {
+		t.Fatalf("Render() got=%q, want=%q", rendered, want)
+	}
+
+	// the same point is not written twice in a window, a new instance is
+	other, _ := data.NewInstance("B")
+	other.SetLabel("volume", "vol2")
+	_ = readOps.SetValueInt64(other, 2)
+	rendered, stats, err := influx.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != 1 || !strings.HasPrefix(string(rendered[0]), "volume,cluster=cluster1,volume=vol2 ") {
+		t.Errorf("Render() got=%q, want only vol2", rendered)
+	}
+	if stats.MetricsExported != 1 {
+		t.Errorf("Render() MetricsExported got=%d, want=1", stats.MetricsExported)
+	}
+
+	// the series of a poller have no timestamp and are always written
+	metadata := matrix.New("exporter", "metadata_exporter", "metadata_exporter")
+	metadata.SetExportOptions(options)
+	count, _ := metadata.NewMetricUint64("count")
+	mi, _ := metadata.NewInstance("A")
+	mi.SetLabel("volume", "vol1")
+	_ = count.SetValueInt64(mi, 1)
+	for range 2 {
+		rendered, _, _ = influx.Render(metadata)
+		if len(rendered) != 1 || string(rendered[0]) != "metadata_exporter,volume=vol1 count=1" {
+			t.Errorf("Render() metadata got=%q", rendered)
+		}
+	}
+}
+
+func TestNewDedup(t *testing.T) {
+	tests := []struct {
+		window  string
+		url     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{window: "1m", url: "http://localhost:8086/api/v2/write?org=a&bucket=b&precision=s", want: time.Second},
+		{window: "1m", url: "http://localhost:8086/api/v2/write?org=a&bucket=b", want: time.Nanosecond},
+		{window: "1h", url: "http://localhost:8086/write?db=a&precision=m", want: time.Minute},
+		{window: "30s", url: "http://localhost:8086/write?db=a&precision=m", wantErr: true},
+		{window: "1m", url: "http://localhost:8086/write?db=a&precision=2", wantErr: true},
+		{window: "0s", url: "http://localhost:8086/write?db=a", wantErr: true},
+		{window: "soon", url: "http://localhost:8086/write?db=a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window+" "+tt.url, func(t *testing.T) {
+			d, err := newDedup(tt.window, tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("newDedup() want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newDedup() err=%v", err)
+			}
+			if d.precision != tt.want {
+				t.Errorf("newDedup() precision got=%s, want=%s", d.precision, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package prometheus
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tlsconf"
+	"net/http"
+	"strings"
+)
+
+const bearerScheme = "Bearer "
+
+// scrapeAuth authenticates the scrapes of the exporter. The credentials are compared in constant time,
+// so a scraper can not guess them from the time of the responses
+type scrapeAuth struct {
+	token     []byte
+	username  []byte
+	password  []byte
+	clientCAs *x509.CertPool // nil when client certificates are not accepted
+}
+
+// newScrapeAuth returns the authentication of auth, or nil when auth has no method.
+// Client certificates are only accepted when the exporter serves TLS
+func newScrapeAuth(auth *conf.ExporterAuth, tls conf.TLS) (*scrapeAuth, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	s := &scrapeAuth{}
+	if auth.BearerToken != "" {
+		s.token = []byte(auth.BearerToken)
+	}
+	if auth.Username != "" || auth.Password != "" {
+		if auth.Username == "" || auth.Password == "" {
+			return nil, errs.New(errs.ErrInvalidParam, "auth requires both username and password")
+		}
+		s.username = []byte(auth.Username)
+		s.password = []byte(auth.Password)
+	}
+	if auth.ClientCAFile != "" {
+		if tls.KeyFile == "" {
+			return nil, errs.New(errs.ErrInvalidParam, "auth client_ca_file requires tls")
+		}
+		pool, err := tlsconf.CertPool(auth.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		s.clientCAs = pool
+	}
+	if s.token == nil && s.username == nil && s.clientCAs == nil {
+		return nil, errs.New(errs.ErrInvalidParam, "auth without bearer_token, username, or client_ca_file")
+	}
+	return s, nil
+}
+
+// check returns an empty string when r passes one of the methods, otherwise the reason r is rejected
+func (s *scrapeAuth) check(r *http.Request) string {
+	if s.clientCAs != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return ""
+	}
+	header := r.Header.Get("Authorization")
+	if s.token != nil && len(header) > len(bearerScheme) && strings.EqualFold(header[:len(bearerScheme)], bearerScheme) {
+		if subtle.ConstantTimeCompare([]byte(header[len(bearerScheme):]), s.token) == 1 {
+			return ""
+		}
+		return "invalid bearer token"
+	}
+	if user, pass, ok := r.BasicAuth(); ok && s.username != nil {
+		// both are compared, so the time does not tell whether the username is valid
+		validUser := subtle.ConstantTimeCompare([]byte(user), s.username)
+		validPass := subtle.ConstantTimeCompare([]byte(pass), s.password)
+		if validUser&validPass == 1 {
+			return ""
+		}
+		return "invalid username or password"
+	}
+	if s.token == nil && s.username == nil {
+		return "missing client certificate"
+	}
+	return "missing credentials"
+}
+
+// challenge sets the WWW-Authenticate header of the response to a rejected scrape
+func (s *scrapeAuth) challenge(w http.ResponseWriter) {
+	switch {
+	case s.username != nil:
+		w.Header().Set("WWW-Authenticate", `Basic realm="harvest"`)
+	case s.token != nil:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="harvest"`)
+	}
+}
+
+// authorize returns true when the scrape is allowed. Rejected scrapes are logged with their reason
+// and answered with 401 Unauthorized
+func (p *Prometheus) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if p.auth == nil {
+		return true
+	}
+	reason := p.auth.check(r)
+	if reason == "" {
+		return true
+	}
+	user, _, _ := r.BasicAuth()
+	p.Logger.Warn().
+		Str("remote_addr", r.RemoteAddr).
+		Str("uri", r.RequestURI).
+		Str("user", user).
+		Str("reason", reason).
+		Msg("Rejected scrape")
+	p.auth.challenge(w)
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+	return false
+}
@@ -11,14 +11,16 @@ import (
 
 type cache struct {
 	*sync.Mutex
-	data   map[string][][]byte
-	timers map[string]time.Time
-	expire time.Duration
+	data       map[string][][]byte
+	histograms map[string][]*nativeHistogram // native histograms of a key, only when native_histograms is enabled
+	timers     map[string]time.Time
+	expire     time.Duration
 }
 
 func newCache(d time.Duration) *cache {
 	c := cache{Mutex: &sync.Mutex{}, expire: d}
 	c.data = make(map[string][][]byte)
+	c.histograms = make(map[string][]*nativeHistogram)
 	c.timers = make(map[string]time.Time)
 	return &c
 }
@@ -33,11 +35,27 @@ func (c *cache) Put(key string, data [][]byte) {
 	c.timers[key] = time.Now()
 }
 
+// GetHistograms returns the native histograms of each key
+func (c *cache) GetHistograms() map[string][]*nativeHistogram {
+	c.Clean()
+	return c.histograms
+}
+
+// PutHistograms replaces the native histograms of a key, its metrics must be put too
+func (c *cache) PutHistograms(key string, histograms []*nativeHistogram) {
+	if len(histograms) == 0 {
+		delete(c.histograms, key)
+		return
+	}
+	c.histograms[key] = histograms
+}
+
 func (c *cache) Clean() {
 	for k, t := range c.timers {
 		if time.Since(t) > c.expire {
 			delete(c.timers, k)
 			delete(c.data, k)
+			delete(c.histograms, k)
 		}
 	}
 }
@@ -0,0 +1,165 @@
+package prometheus
+
+import (
+	"bytes"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// the default time an EMS event is linked to the perf metrics of its node
+	exemplarWindow = "5m"
+	// the maximum number of events kept per node
+	maxNodeEvents = 16
+	// the OpenMetrics spec limits the labels of an exemplar to 128 characters
+	maxExemplarLabels = 128
+	openMetricsType   = "application/openmetrics-text"
+	openMetricsHeader = openMetricsType + "; version=1.0.0; charset=utf-8"
+	emsObject         = "ems"
+)
+
+// exemplarSep separates a sample from its exemplar
+var exemplarSep = []byte(" # {")
+
+// nodeKey identifies a node across the clusters of an exporter
+type nodeKey struct {
+	cluster string
+	node    string
+}
+
+type emsEvent struct {
+	name     string
+	severity string
+	time     time.Time
+}
+
+// exemplars is a correlation buffer of the recent EMS events of each node.
+// The perf metrics of a node are rendered with an exemplar of the latest event of the node,
+// so that a latency spike links to the event that caused it
+type exemplars struct {
+	sync.Mutex
+	window time.Duration
+	events map[nodeKey][]emsEvent
+}
+
+func newExemplars(window time.Duration) *exemplars {
+	return &exemplars{window: window, events: make(map[nodeKey][]emsEvent)}
+}
+
+// observe adds the active events of an EMS matrix to the buffer of their node
+func (e *exemplars) observe(data *matrix.Matrix, now time.Time) {
+	if data.Object != emsObject {
+		return
+	}
+	events := data.GetMetric("events")
+	timestamps := data.GetMetric("timestamp")
+	if events == nil || timestamps == nil {
+		return
+	}
+	cluster := data.GetGlobalLabels()["cluster"]
+
+	e.Lock()
+	defer e.Unlock()
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		// resolved events have a value of 0
+		if active, ok := events.GetValueFloat64(instance); !ok || active != 1 {
+			continue
+		}
+		micros, ok := timestamps.GetValueFloat64(instance)
+		node := instance.GetLabel("node")
+		if !ok || node == "" {
+			continue
+		}
+		key := nodeKey{cluster: cluster, node: node}
+		event := emsEvent{name: data.UUID, severity: instance.GetLabel("severity"), time: time.UnixMicro(int64(micros))}
+		// active events are exported each poll, add them once
+		if slices.Contains(e.events[key], event) {
+			continue
+		}
+		e.events[key] = append(e.events[key], event)
+	}
+	e.prune(now)
+}
+
+// prune removes the events older than the window, and the oldest events of nodes with too many events
+func (e *exemplars) prune(now time.Time) {
+	for key, events := range e.events {
+		events = slices.DeleteFunc(events, func(event emsEvent) bool {
+			return now.Sub(event.time) > e.window
+		})
+		slices.SortFunc(events, func(a, b emsEvent) int {
+			return a.time.Compare(b.time)
+		})
+		if len(events) > maxNodeEvents {
+			events = events[len(events)-maxNodeEvents:]
+		}
+		if len(events) == 0 {
+			delete(e.events, key)
+		} else {
+			e.events[key] = events
+		}
+	}
+}
+
+// latest returns the exemplar of the latest event of a node within the window, or an empty string
+func (e *exemplars) latest(replacer *strings.Replacer, cluster string, node string, now time.Time) string {
+	e.Lock()
+	events := e.events[nodeKey{cluster: cluster, node: node}]
+	var event emsEvent
+	if len(events) > 0 {
+		event = events[len(events)-1]
+	}
+	e.Unlock()
+	if event.name == "" || now.Sub(event.time) > e.window {
+		return ""
+	}
+	labels := escape(replacer, "ems", event.name) + "," + escape(replacer, "node", node)
+	if event.severity != "" {
+		labels += "," + escape(replacer, "severity", event.severity)
+	}
+	if utf8.RuneCountInString(labels) > maxExemplarLabels {
+		labels = escape(replacer, "ems", event.name)
+		if utf8.RuneCountInString(labels) > maxExemplarLabels {
+			return ""
+		}
+	}
+	ts := strconv.FormatFloat(float64(event.time.UnixMilli())/1000, 'f', 3, 64)
+	return string(exemplarSep) + labels + "} 1 " + ts
+}
+
+// isPerf returns true when data was collected by a perf collector, e.g. ZapiPerf or RestPerf, or one of its plugins
+func isPerf(data *matrix.Matrix) bool {
+	collector, _, _ := strings.Cut(data.UUID, ".")
+	return strings.HasSuffix(collector, "Perf")
+}
+
+// acceptsOpenMetrics returns true when the Accept header of a scrape asks for the OpenMetrics format
+func acceptsOpenMetrics(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == openMetricsType {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutExemplar removes the exemplar of a sample, which the Prometheus text format does not support
+func withoutExemplar(metric []byte) []byte {
+	// the exemplar is the last part of a sample, comments start with #
+	if len(metric) > 0 && metric[0] != '#' {
+		if i := bytes.LastIndex(metric, exemplarSep); i >= 0 {
+			return metric[:i]
+		}
+	}
+	return metric
+}
@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setUpEmsMatrix(name string, node string, active float64, at time.Time) *matrix.Matrix {
+	m := matrix.New(name, "ems", name)
+	m.SetGlobalLabel("cluster", "cluster-01")
+	events, _ := m.NewMetricFloat64("events")
+	timestamp, _ := m.NewMetricFloat64("timestamp")
+	instance, _ := m.NewInstance(name + node)
+	instance.SetLabel("node", node)
+	instance.SetLabel("severity", "alert")
+	_ = events.SetValueFloat64(instance, active)
+	_ = timestamp.SetValueFloat64(instance, float64(at.UnixMicro()))
+	return m
+}
+
+func setUpPerfMatrix(uuid string) *matrix.Matrix {
+	m := matrix.New(uuid, "volume", "volume")
+	m.SetGlobalLabel("cluster", "cluster-01")
+	latency, _ := m.NewMetricFloat64("read_latency")
+	for _, node := range []string{"node-01", "node-02"} {
+		instance, _ := m.NewInstance(node)
+		instance.SetLabel("node", node)
+		_ = latency.SetValueFloat64(instance, 42)
+	}
+	return m
+}
+
+func TestExemplars(t *testing.T) {
+	absExp := exporter.New("Prometheus", "prom1", &options.Options{PromPort: 1},
+		conf.Exporter{IsTest: true, Exemplars: true, ExemplarWindow: "1h"}, nil)
+	p := New(absExp)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	prom := p.(*Prometheus)
+	now := time.Now().Truncate(time.Millisecond)
+	ts := strconv.FormatFloat(float64(now.UnixMilli())/1000, 'f', 3, 64)
+
+	_, _ = p.Export(setUpEmsMatrix("wafl.ca.resync.complete", "node-01", 0, now))
+	_, _ = p.Export(setUpEmsMatrix("disk.outOfService", "node-01", 1, now))
+	_, _ = p.Export(setUpEmsMatrix("nvram.battery.low", "node-02", 1, now.Add(-2*time.Hour)))
+
+	tests := []struct {
+		name string
+		uuid string
+		want []string
+	}{
+		{
+			name: "perf",
+			uuid: "ZapiPerf",
+			want: []string{
+				`volume_read_latency{cluster="cluster-01",node="node-01"} 42 # {ems="disk.outOfService",node="node-01",severity="alert"} 1 ` + ts,
+				`volume_read_latency{cluster="cluster-01",node="node-02"} 42`,
+			},
+		},
+		{
+			name: "not perf",
+			uuid: "Rest",
+			want: []string{
+				`volume_read_latency{cluster="cluster-01",node="node-01"} 42`,
+				`volume_read_latency{cluster="cluster-01",node="node-02"} 42`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setUpPerfMatrix(tt.uuid)
+			m.SetExportOptions(matrix.DefaultExportOptions())
+			m.GetExportOptions().NewChildS("instance_keys", "").NewChildS("", "node")
+			rendered, _ := prom.render(m)
+			got := make([]string, 0, len(rendered))
+			for _, r := range rendered {
+				got = append(got, string(r))
+			}
+			slices.Sort(got)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("render() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeExemplars(t *testing.T) {
+	absExp := exporter.New("Prometheus", "prom1", &options.Options{PromPort: 1},
+		conf.Exporter{IsTest: true, Exemplars: true}, nil)
+	p := New(absExp)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	prom := p.(*Prometheus)
+	_, _ = p.Export(setUpEmsMatrix("disk.outOfService", "node-01", 1, time.Now()))
+	perf := setUpPerfMatrix("RestPerf")
+	perf.GetExportOptions().NewChildS("instance_keys", "").NewChildS("", "node")
+	_, _ = p.Export(perf)
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantExemplar    bool
+	}{
+		{name: "text", accept: "text/plain;version=0.0.4;q=0.5,*/*;q=0.1", wantContentType: "text/plain"},
+		{
+			name:            "openmetrics",
+			accept:          "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			wantContentType: openMetricsHeader,
+			wantExemplar:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			prom.ServeMetrics(w, r)
+			body := w.Body.String()
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type got=%q, want=%q", got, tt.wantContentType)
+			}
+			if got := strings.Contains(body, `# {ems="disk.outOfService"`); got != tt.wantExemplar {
+				t.Errorf("exemplar got=%v, want=%v body=%s", got, tt.wantExemplar, body)
+			}
+			if got := strings.HasSuffix(body, "\n# EOF\n"); got != tt.wantExemplar {
+				t.Errorf("EOF got=%v, want=%v", got, tt.wantExemplar)
+			}
+		})
+	}
+}
@@ -7,14 +7,19 @@
 package prometheus
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/netapp/harvest/v2/pkg/set"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,6 +37,16 @@ func (p *Prometheus) startHTTPD(addr string, port int) {
 
 	var url string
 	if p.Params.TLS.KeyFile != "" {
+		tlsConfig, err := p.Params.TLS.Options().Server()
+		if err != nil {
+			p.Logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
+		}
+		if p.auth != nil && p.auth.clientCAs != nil {
+			// scrapes without a certificate are still served when they pass another method of auth
+			tlsConfig.ClientCAs = p.auth.clientCAs
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		server.TLSConfig = tlsConfig
 		url = fmt.Sprintf("https://%s/metrics", net.JoinHostPort(addr, strconv.Itoa(port)))
 	} else {
 		url = fmt.Sprintf("%s://%s/metrics", "http", net.JoinHostPort(addr, strconv.Itoa(port)))
@@ -107,8 +122,8 @@ func (p *Prometheus) denyAccess(w http.ResponseWriter, r *http.Request) {
 func (p *Prometheus) ServeMetrics(w http.ResponseWriter, r *http.Request) {
 
 	var (
-		data  [][]byte
-		count int
+		batches [][][]byte
+		count   int
 	)
 
 	start := time.Now()
@@ -118,33 +133,81 @@ func (p *Prometheus) ServeMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !p.authorize(w, r) {
+		return
+	}
+
+	// native histograms are only served to scrapers that accept protobuf
+	var histograms []*nativeHistogram
+	withHistograms := p.nativeHistograms && acceptsProtobuf(r.Header.Get("Accept"))
+
+	// the cache replaces the metrics of a key on export, so the batches can be written without holding the lock
 	p.cache.Lock()
 	for _, metrics := range p.cache.Get() {
-		data = append(data, metrics...)
+		batches = append(batches, metrics)
 		count += len(metrics)
 	}
+	if withHistograms {
+		for _, h := range p.cache.GetHistograms() {
+			histograms = append(histograms, h...)
+		}
+	}
 	p.cache.Unlock()
 
 	// serve our own metadata
 	// notice that some values are always taken from previous session
 	md, _ := p.render(p.Metadata)
-	data = append(data, md...)
+	batches = append(batches, md)
 	count += len(md)
 
-	if p.addMetaTags {
-		data = filterMetaTags(data)
+	// exemplars are only served to scrapers that accept OpenMetrics, and not with native histograms
+	contentType := "text/plain"
+	ending := ""
+	stripExemplars := false
+	if withHistograms {
+		contentType = protobufHeader
+	} else if p.exemplars != nil {
+		if acceptsOpenMetrics(r.Header.Get("Accept")) {
+			contentType = openMetricsHeader
+			ending = "# EOF\n"
+		} else {
+			stripExemplars = true
+		}
 	}
 
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	// the metrics are streamed to the scraper, the response is chunked since its length is not known upfront
+	var out io.Writer = w
+	var zw *gzip.Writer
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw = gzipWriters.Get().(*gzip.Writer)
+		zw.Reset(w)
+		defer gzipWriters.Put(zw)
+		out = zw
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "text/plain")
-	_, err := w.Write(bytes.Join(data, []byte("\n")))
+
+	bw := bufio.NewWriterSize(out, streamBufferSize)
+	var err error
+	if withHistograms {
+		err = writeProtobuf(bw, batches, histograms)
+	} else {
+		err = writeMetrics(bw, batches, p.addMetaTags, stripExemplars)
+	}
+	if err == nil {
+		_, err = bw.WriteString(ending)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
 	if err != nil {
 		p.Logger.Error().Err(err).Msg("write metrics")
-	} else {
-		// make sure stream ends with newline
-		if _, err2 := w.Write([]byte("\n")); err2 != nil {
-			p.Logger.Error().Err(err2).Msg("write ending newline")
-		}
 	}
 
 	// update metadata
@@ -159,31 +222,96 @@ func (p *Prometheus) ServeMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// filterMetaTags removes duplicate TYPE/HELP tags in the metrics
+// streamBufferSize is the size of the writes of a scrape response
+const streamBufferSize = 64 * 1024
+
+// gzipWriters reuses the compressors of scrape responses, since each one allocates a large window
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// acceptsGzip returns true when the Accept-Encoding header of a scraper allows gzip,
+// e.g. gzip, or gzip;q=0.8, but not gzip;q=0
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// writeMetrics writes the lines of each batch to w, one line per metric, without joining them in memory
+func writeMetrics(w *bufio.Writer, batches [][][]byte, addMetaTags bool, stripExemplars bool) error {
+	tags := newMetaTagFilter()
+	for _, metrics := range batches {
+		for _, m := range metrics {
+			if addMetaTags && !tags.keep(m) {
+				continue
+			}
+			if stripExemplars {
+				m = withoutExemplar(m)
+			}
+			if _, err := w.Write(m); err != nil {
+				return err
+			}
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// metaTagFilter removes duplicate TYPE/HELP tags in the metrics
 // Note: this is a workaround, normally Render() will only add
 // one TYPE/HELP for each metric type, however since some metric
 // types (e.g. metadata_collector_metrics) are submitted from multiple
 // collectors, we end up with duplicates in the final batch delivered
 // over HTTP.
-func filterMetaTags(metrics [][]byte) [][]byte {
+type metaTagFilter struct {
+	metricsWithTags map[string]bool
+	last            string // name of the last kept tag, the TYPE tag follows the HELP tag of a metric
+}
 
-	filtered := make([][]byte, 0)
+func newMetaTagFilter() *metaTagFilter {
+	return &metaTagFilter{metricsWithTags: make(map[string]bool)}
+}
 
-	metricsWithTags := make(map[string]bool)
+// keep returns false when m is a tag of a metric whose tags were already kept
+func (f *metaTagFilter) keep(m []byte) bool {
+	if !bytes.HasPrefix(m, []byte("# ")) {
+		f.last = ""
+		return true
+	}
+	fields := bytes.Fields(m)
+	if len(fields) <= 3 {
+		return false
+	}
+	name := fields[2]
+	if f.metricsWithTags[string(name)] {
+		return f.last == string(name)
+	}
+	f.last = string(name)
+	f.metricsWithTags[f.last] = true
+	return true
+}
 
-	for i, m := range metrics {
-		if bytes.HasPrefix(m, []byte("# ")) {
-			if fields := strings.Fields(string(m)); len(fields) > 3 {
-				name := fields[2]
-				if !metricsWithTags[name] {
-					metricsWithTags[name] = true
-					filtered = append(filtered, m)
-					if i+1 < len(metrics) {
-						filtered = append(filtered, metrics[i+1])
-					}
-				}
-			}
-		} else {
+// filterMetaTags removes duplicate TYPE/HELP tags in the metrics, see metaTagFilter
+func filterMetaTags(metrics [][]byte) [][]byte {
+	filtered := make([][]byte, 0, len(metrics))
+	tags := newMetaTagFilter()
+	for _, m := range metrics {
+		if tags.keep(m) {
 			filtered = append(filtered, m)
 		}
 	}
@@ -201,6 +329,10 @@ func (p *Prometheus) ServeInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !p.authorize(w, r) {
+		return
+	}
+
 	p.Logger.Debug().Msgf("(httpd) serving info request [%s] (%s)", r.RequestURI, r.RemoteAddr)
 
 	body := make([]string, 0)
@@ -0,0 +1,374 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Native histograms replace the series of each bucket of a classic histogram with a single series of sparse,
+// exponential buckets. Prometheus only scrapes them in its protobuf format, which Harvest encodes by hand
+// instead of depending on the protobuf module.
+// See https://prometheus.io/docs/specs/native_histograms/ and io/prometheus/client/metrics.proto
+
+const (
+	protobufType    = "application/vnd.google.protobuf"
+	protobufProto   = "io.prometheus.client.MetricFamily"
+	protobufHeader  = protobufType + "; proto=" + protobufProto + "; encoding=delimited"
+	typeUntyped     = 3
+	typeGaugeHisto  = 5
+	nativeSchema    = 3 // each bucket is 2^(1/8), about 9%, wider than the previous one
+	nativeZeroWidth = 0x1p-128
+)
+
+// nativeHistogram is a histogram of ONTAP, e.g. a latency histogram, mapped to the exponential buckets
+// of nativeSchema. ONTAP counts the samples of each interval, so it is a gauge histogram
+type nativeHistogram struct {
+	name    string // name of the metric, with its prefix
+	keys    string // labels of the histogram, as rendered in the text format
+	count   float64
+	sum     float64
+	buckets map[int]float64 // samples of each bucket index
+}
+
+// newNativeHistogram maps the values of the classic buckets of a histogram to native buckets.
+// bounds are the upper bounds of the classic buckets, the last one is usually +Inf.
+// The samples of a classic bucket are added to the native bucket of its upper bound, and the ones of the +Inf bucket
+// to the native bucket that follows the last finite bound. The sum assumes each sample is its upper bound,
+// like the _sum of the classic histogram
+func newNativeHistogram(name string, keys string, values []string, bounds []string) *nativeHistogram {
+	h := &nativeHistogram{name: name, keys: keys, buckets: make(map[int]float64)}
+	last := math.MinInt
+	for i, value := range values {
+		if i >= len(bounds) {
+			break
+		}
+		bound, err := strconv.ParseFloat(bounds[i], 64)
+		if err != nil {
+			continue
+		}
+		inf := math.IsInf(bound, 1)
+		var index int
+		switch {
+		case !inf:
+			index = nativeIndex(bound)
+			last = max(last, index)
+		case last == math.MinInt:
+			continue
+		default:
+			index = last + 1
+		}
+		samples, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(samples) || samples <= 0 {
+			continue
+		}
+		if !inf {
+			h.sum += bound * samples
+		}
+		h.buckets[index] += samples
+		h.count += samples
+	}
+	return h
+}
+
+// nativeIndex returns the index of the native bucket that contains bound. Bucket i holds the samples
+// in (2^((i-1)/2^schema), 2^(i/2^schema)]
+func nativeIndex(bound float64) int {
+	return int(math.Ceil(math.Log2(bound) * (1 << nativeSchema)))
+}
+
+// spans returns the spans of the buckets of h that have samples, and their counts, in the order of their index
+func (h *nativeHistogram) spans() ([][2]int, []float64) {
+	indexes := make([]int, 0, len(h.buckets))
+	for index := range h.buckets {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	var spans [][2]int // offset from the end of the previous span, length
+	counts := make([]float64, 0, len(indexes))
+	next := 0
+	for i, index := range indexes {
+		if i > 0 && index == next {
+			spans[len(spans)-1][1]++
+		} else {
+			offset := index
+			if i > 0 {
+				offset = index - next
+			}
+			spans = append(spans, [2]int{offset, 1})
+		}
+		next = index + 1
+		counts = append(counts, h.buckets[index])
+	}
+	return spans, counts
+}
+
+// acceptsProtobuf returns true when the Accept header of a scrape asks for the delimited protobuf format,
+// as Prometheus does when it scrapes native histograms
+func acceptsProtobuf(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != protobufType {
+			continue
+		}
+		if params["proto"] != protobufProto || params["encoding"] != "delimited" {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+// family is a metric family of the protobuf format, with its encoded metrics
+type family struct {
+	name    string
+	help    string
+	kind    uint64
+	metrics [][]byte
+}
+
+// writeProtobuf writes the metrics of the batches and the native histograms to w in the delimited protobuf format.
+// The classic buckets, count, and sum of the native histograms are not written, since the native histograms
+// replace them. Exemplars are not written
+func writeProtobuf(w io.Writer, batches [][][]byte, histograms []*nativeHistogram) error {
+	classic := make(map[string]bool, 3*len(histograms))
+	for _, h := range histograms {
+		classic[h.name+"_bucket"] = true
+		classic[h.name+"_count"] = true
+		classic[h.name+"_sum"] = true
+	}
+
+	var families []*family
+	byName := make(map[string]*family)
+	familyOf := func(name string, kind uint64) *family {
+		f, ok := byName[name]
+		if !ok {
+			f = &family{name: name, kind: kind}
+			byName[name] = f
+			families = append(families, f)
+		}
+		return f
+	}
+
+	help := make(map[string]string)
+	for _, metrics := range batches {
+		for _, m := range metrics {
+			if bytes.HasPrefix(m, []byte("# HELP ")) {
+				name, text, _ := strings.Cut(string(m[len("# HELP "):]), " ")
+				help[name] = text
+				continue
+			}
+			if bytes.HasPrefix(m, []byte("#")) {
+				continue
+			}
+			name, labels, value, err := parseSample(withoutExemplar(m))
+			if err != nil || classic[name] {
+				continue
+			}
+			f := familyOf(name, typeUntyped)
+			f.metrics = append(f.metrics, encodeUntyped(labels, value))
+		}
+	}
+	for _, h := range histograms {
+		labels, _, err := parseLabels(h.keys + "}")
+		if err != nil {
+			continue
+		}
+		f := familyOf(h.name, typeGaugeHisto)
+		f.metrics = append(f.metrics, encodeHistogram(labels, h))
+	}
+
+	var buf []byte
+	for _, f := range families {
+		f.help = help[f.name]
+		encoded := encodeFamily(f)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(encoded)))
+		buf = append(buf, encoded...)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSample parses a sample of the text format, e.g. volume_read_ops{svm="vs1",volume="vol1"} 42
+func parseSample(line []byte) (string, []labelPair, float64, error) {
+	s := string(line)
+	var (
+		name   string
+		labels []labelPair
+		rest   string
+	)
+	if open := strings.IndexByte(s, '{'); open >= 0 {
+		var (
+			n   int
+			err error
+		)
+		name = s[:open]
+		if labels, n, err = parseLabels(s[open+1:]); err != nil {
+			return "", nil, 0, err
+		}
+		rest = s[open+1+n:]
+	} else {
+		name, rest, _ = strings.Cut(s, " ")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return name, labels, value, nil
+}
+
+// parseLabels parses the labels of a sample up to its closing brace, e.g. svm="vs1",volume="vol1"}.
+// It returns the labels, and the length of s that they used
+func parseLabels(s string) ([]labelPair, int, error) {
+	var labels []labelPair
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '}':
+			return labels, i + 1, nil
+		case ',', ' ':
+			i++
+			continue
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, errors.New("label without a quoted value")
+		}
+		name := s[i : i+eq]
+		start := i + eq + 1
+		end := start + 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return nil, 0, errors.New("unterminated label value")
+		}
+		value, err := strconv.Unquote(s[start : end+1])
+		if err != nil {
+			return nil, 0, err
+		}
+		labels = append(labels, labelPair{name: name, value: value})
+		i = end + 1
+	}
+	return nil, 0, errors.New("unterminated labels")
+}
+
+// encodeFamily returns the protobuf encoding of a MetricFamily
+//
+//	message MetricFamily { string name = 1; string help = 2; MetricType type = 3; repeated Metric metric = 4; }
+func encodeFamily(f *family) []byte {
+	var b []byte
+	b = appendString(b, 1, f.name)
+	if f.help != "" {
+		b = appendString(b, 2, f.help)
+	}
+	b = appendVarint(b, 3, f.kind)
+	for _, m := range f.metrics {
+		b = appendBytes(b, 4, m)
+	}
+	return b
+}
+
+// appendLabels appends the labels of a Metric to b
+//
+//	message Metric { repeated LabelPair label = 1; Untyped untyped = 5; Histogram histogram = 7; }
+//	message LabelPair { string name = 1; string value = 2; }
+func appendLabels(b []byte, labels []labelPair) []byte {
+	var pair []byte
+	for _, l := range labels {
+		pair = appendString(pair[:0], 1, l.name)
+		pair = appendString(pair, 2, l.value)
+		b = appendBytes(b, 1, pair)
+	}
+	return b
+}
+
+// encodeUntyped returns the protobuf encoding of a Metric with an Untyped value, like the samples of the text format
+//
+//	message Untyped { double value = 1; }
+func encodeUntyped(labels []labelPair, value float64) []byte {
+	b := appendLabels(nil, labels)
+	return appendBytes(b, 5, appendDouble(nil, 1, value))
+}
+
+// encodeHistogram returns the protobuf encoding of a Metric with a float Histogram.
+// The counts of the buckets are absolute, not deltas, since the histogram has float counts
+//
+//	message Histogram {
+//	  double sample_sum = 2; double sample_count_float = 4; sint32 schema = 5; double zero_threshold = 6;
+//	  repeated BucketSpan positive_span = 12; repeated double positive_count = 14;
+//	}
+//	message BucketSpan { sint32 offset = 1; uint32 length = 2; }
+func encodeHistogram(labels []labelPair, h *nativeHistogram) []byte {
+	var histogram []byte
+	histogram = appendDouble(histogram, 2, h.sum)
+	histogram = appendDouble(histogram, 4, h.count)
+	histogram = appendVarint(histogram, 5, zigzag(nativeSchema))
+	histogram = appendDouble(histogram, 6, nativeZeroWidth)
+
+	spans, counts := h.spans()
+	var span []byte
+	for _, s := range spans {
+		span = appendVarint(span[:0], 1, zigzag(s[0]))
+		span = appendVarint(span, 2, uint64(s[1])) //nolint:gosec
+		histogram = appendBytes(histogram, 12, span)
+	}
+	if len(counts) > 0 {
+		packed := make([]byte, 0, 8*len(counts))
+		for _, c := range counts {
+			packed = binary.LittleEndian.AppendUint64(packed, math.Float64bits(c))
+		}
+		histogram = appendBytes(histogram, 14, packed)
+	}
+
+	b := appendLabels(nil, labels)
+	return appendBytes(b, 7, histogram)
+}
+
+func zigzag(v int) uint64 {
+	return uint64((int64(v) << 1) ^ (int64(v) >> 63)) //nolint:gosec
+}
+
+func appendVarint(b []byte, field uint64, v uint64) []byte {
+	b = binary.AppendUvarint(b, field<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendDouble(b []byte, field uint64, v float64) []byte {
+	b = binary.AppendUvarint(b, field<<3|1)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func appendString(b []byte, field uint64, s string) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b []byte, field uint64, v []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
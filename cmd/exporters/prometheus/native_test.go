@@ -0,0 +1,237 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func setUpHistogramMatrix(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := matrix.New("ZapiPerf.volume", "volume", "volume")
+	m.SetExportOptions(options)
+	buckets := []string{"<2us", "<6us", "<10us", ">10us"}
+	bucket, _ := m.NewMetricFloat64("read_latency_hist.bucket", "read_latency_hist")
+	bucket.SetBuckets(&buckets)
+	ops, _ := m.NewMetricFloat64("read_ops")
+	instance, _ := m.NewInstance("vol1")
+	instance.SetLabel("volume", "vol1")
+	_ = ops.SetValueFloat64(instance, 42)
+	for i, name := range buckets {
+		metric, _ := m.NewMetricFloat64("read_latency_hist."+name, "read_latency_hist")
+		metric.SetLabel("metric", name)
+		metric.SetLabel("comment", strconv.Itoa(i))
+		metric.SetLabel("bucket", "read_latency_hist.bucket")
+		metric.SetHistogram(true)
+		metric.SetArray(true)
+		_ = metric.SetValueFloat64(instance, []float64{1, 0, 3, 2}[i])
+	}
+	return m
+}
+
+func TestNewNativeHistogram(t *testing.T) {
+	h := newNativeHistogram("volume_read_latency_hist", `volume="vol1"`,
+		[]string{"1", "0", "3", "2"}, []string{"2", "6", "10", "+Inf"})
+
+	if h.count != 6 || h.sum != 32 {
+		t.Errorf("count=%f sum=%f, want count=6 sum=32", h.count, h.sum)
+	}
+	// 2 is the upper bound of bucket 8, 10 of bucket 27, and +Inf goes to the bucket after the last bound
+	want := map[int]float64{8: 1, 27: 3, 28: 2}
+	if diff := cmp.Diff(want, h.buckets); diff != "" {
+		t.Errorf("buckets mismatch (-want +got):\n%s", diff)
+	}
+
+	spans, counts := h.spans()
+	if diff := cmp.Diff([][2]int{{8, 1}, {18, 2}}, spans); diff != "" {
+		t.Errorf("spans mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]float64{1, 3, 2}, counts); diff != "" {
+		t.Errorf("counts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNativeIndex(t *testing.T) {
+	for _, bound := range []float64{1, 2, 6, 10, 100, 20_000_000} {
+		index := nativeIndex(bound)
+		lower := math.Pow(2, float64(index-1)/(1<<nativeSchema))
+		upper := math.Pow(2, float64(index)/(1<<nativeSchema))
+		if bound <= lower || bound > upper*(1+1e-12) {
+			t.Errorf("nativeIndex(%f)=%d, bucket is (%f, %f]", bound, index, lower, upper)
+		}
+	}
+}
+
+func TestAcceptsProtobuf(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.6,application/openmetrics-text;version=1.0.0;q=0.5", want: true},
+		{accept: "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=text", want: false},
+		{accept: "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0", want: false},
+		{accept: "application/openmetrics-text;version=1.0.0", want: false},
+		{accept: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := acceptsProtobuf(tt.accept); got != tt.want {
+				t.Errorf("acceptsProtobuf() got=%t, want=%t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	name, labels, value, err := parseSample([]byte(`volume_read_ops{svm="vs\"1",volume="vol1"} 42`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "volume_read_ops" || value != 42 {
+		t.Errorf("name=%s value=%f, want volume_read_ops 42", name, value)
+	}
+	want := []labelPair{{name: "svm", value: `vs"1`}, {name: "volume", value: "vol1"}}
+	if diff := cmp.Diff(want, labels, cmp.AllowUnexported(labelPair{})); diff != "" {
+		t.Errorf("labels mismatch (-want +got):\n%s", diff)
+	}
+	if _, _, _, err := parseSample([]byte(`volume_read_ops{svm="vs1 42`)); err == nil {
+		t.Errorf("parseSample() of unterminated labels, want error")
+	}
+}
+
+// protoField is a field of a protobuf message, decoded for the tests
+type protoField struct {
+	num    uint64
+	varint uint64
+	fixed  uint64
+	bytes  []byte
+}
+
+func decodeMessage(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		f := protoField{num: key >> 3}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(b)
+			b = b[n:]
+		case 1:
+			f.fixed = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			f.bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestServeMetricsNativeHistograms(t *testing.T) {
+	absExp := exporter.New("Prometheus", "prom1", &options.Options{PromPort: 1},
+		conf.Exporter{IsTest: true, SortLabels: true, NativeHistograms: true}, nil)
+	p := New(absExp)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Export(setUpHistogramMatrix(t)); err != nil {
+		t.Fatal(err)
+	}
+	prom := p.(*Prometheus)
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		prom.ServeMetrics(w, r)
+		return w
+	}
+
+	// scrapers of the text format get the classic buckets
+	text := serve("text/plain").Body.String()
+	if !strings.Contains(text, `volume_read_latency_hist_bucket{volume="vol1",le="10"} 4`) {
+		t.Errorf("text body has no classic bucket, got=%q", text)
+	}
+
+	resp := serve(protobufHeader)
+	if got := resp.Header().Get("Content-Type"); got != protobufHeader {
+		t.Errorf("Content-Type got=%q, want %q", got, protobufHeader)
+	}
+
+	kinds := make(map[string]uint64)
+	var histogram []protoField
+	body := resp.Body.Bytes()
+	for len(body) > 0 {
+		size, n := binary.Uvarint(body)
+		family := decodeMessage(t, body[n:n+int(size)])
+		body = body[n+int(size):]
+
+		var name string
+		for _, f := range family {
+			switch f.num {
+			case 1:
+				name = string(f.bytes)
+			case 3:
+				kinds[name] = f.varint
+			case 4:
+				for _, mf := range decodeMessage(t, f.bytes) {
+					if mf.num == 7 {
+						histogram = decodeMessage(t, mf.bytes)
+					}
+				}
+			}
+		}
+	}
+
+	if kinds["volume_read_latency_hist"] != typeGaugeHisto || kinds["volume_read_ops"] != typeUntyped {
+		t.Errorf("kinds got=%v, want a gauge histogram and an untyped metric", kinds)
+	}
+	for _, classic := range []string{"volume_read_latency_hist_bucket", "volume_read_latency_hist_count", "volume_read_latency_hist_sum"} {
+		if _, ok := kinds[classic]; ok {
+			t.Errorf("%s is served with native histograms", classic)
+		}
+	}
+
+	got := make(map[uint64]float64)
+	var spans int
+	for _, f := range histogram {
+		switch f.num {
+		case 2, 4, 6:
+			got[f.num] = math.Float64frombits(f.fixed)
+		case 5:
+			got[f.num] = float64(f.varint)
+		case 12:
+			spans++
+		}
+	}
+	want := map[uint64]float64{2: 32, 4: 6, 5: float64(zigzag(nativeSchema)), 6: nativeZeroWidth}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("histogram mismatch (-want +got):\n%s", diff)
+	}
+	if spans != 2 {
+		t.Errorf("spans got=%d, want 2", spans)
+	}
+}
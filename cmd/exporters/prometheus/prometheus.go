@@ -22,6 +22,7 @@ Package Description:
 package prometheus
 
 import (
+	"cmp"
 	"fmt"
 	"github.com/netapp/harvest/v2/cmd/poller/exporter"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/changelog"
@@ -29,6 +30,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/set"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -45,14 +47,17 @@ const (
 
 type Prometheus struct {
 	*exporter.AbstractExporter
-	cache           *cache
-	allowAddrs      []string
-	allowAddrsRegex []*regexp.Regexp
-	cacheAddrs      map[string]bool
-	checkAddrs      bool
-	addMetaTags     bool
-	globalPrefix    string
-	replacer        *strings.Replacer
+	cache            *cache
+	allowAddrs       []string
+	allowAddrsRegex  []*regexp.Regexp
+	cacheAddrs       map[string]bool
+	checkAddrs       bool
+	addMetaTags      bool
+	globalPrefix     string
+	replacer         *strings.Replacer
+	exemplars        *exemplars  // nil when exemplars are disabled
+	nativeHistograms bool        // serve histograms as native histograms to scrapers that accept protobuf
+	auth             *scrapeAuth // nil when scrapes are not authenticated
 }
 
 func New(abc *exporter.AbstractExporter) exporter.Exporter {
@@ -96,6 +101,24 @@ func (p *Prometheus) Init() error {
 		p.addMetaTags = true
 	}
 
+	// link perf metrics to the recent EMS events of their node if requested
+	if p.Params.Exemplars {
+		window := exemplarWindow
+		if p.Params.ExemplarWindow != "" {
+			window = p.Params.ExemplarWindow
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			p.Logger.Error().Err(err).Str("exemplar_window", window).Msg("invalid exemplar_window")
+			return errs.New(errs.ErrInvalidParam, "exemplar_window")
+		}
+		p.Logger.Debug().Str("exemplar_window", window).Msg("exemplars enabled")
+		p.exemplars = newExemplars(d)
+	}
+
+	// serve histograms as native histograms to scrapers that accept the protobuf format if requested
+	p.nativeHistograms = p.Params.NativeHistograms
+
 	// all other parameters are only relevant to the HTTP daemon
 	if x := p.Params.CacheMaxKeep; x != nil {
 		if d, err := time.ParseDuration(*x); err == nil {
@@ -151,6 +174,14 @@ func (p *Prometheus) Init() error {
 		p.cacheAddrs = make(map[string]bool)
 	}
 
+	// require scrapes to authenticate with a bearer token, basic auth, or a client certificate
+	auth, err := newScrapeAuth(p.Params.Auth, p.Params.TLS)
+	if err != nil {
+		p.Logger.Error().Err(err).Msg("auth")
+		return err
+	}
+	p.auth = auth
+
 	// Finally, the most important and only required parameter: port
 	// can be passed to us either as an option or as a parameter
 	port := p.Options.PromPort
@@ -205,9 +236,10 @@ func newReplacer() *strings.Replacer {
 func (p *Prometheus) Export(data *matrix.Matrix) (exporter.Stats, error) {
 
 	var (
-		metrics [][]byte
-		stats   exporter.Stats
-		err     error
+		metrics    [][]byte
+		histograms []*nativeHistogram
+		stats      exporter.Stats
+		err        error
 	)
 
 	// lock the exporter, to prevent other collectors from writing to us
@@ -216,7 +248,10 @@ func (p *Prometheus) Export(data *matrix.Matrix) (exporter.Stats, error) {
 
 	// render metrics into Prometheus format
 	start := time.Now()
-	metrics, stats = p.render(data)
+	if p.exemplars != nil {
+		p.exemplars.observe(data, start)
+	}
+	metrics, histograms, stats = p.renderWithHistograms(data)
 
 	// fix render time for metadata
 	d := time.Since(start)
@@ -227,6 +262,7 @@ func (p *Prometheus) Export(data *matrix.Matrix) (exporter.Stats, error) {
 	// lock cache, to prevent HTTPd reading while we are mutating it
 	p.cache.Lock()
 	p.cache.Put(key, metrics)
+	p.cache.PutHistograms(key, histograms)
 	p.cache.Unlock()
 
 	// update metadata
@@ -260,11 +296,20 @@ func (p *Prometheus) Export(data *matrix.Matrix) (exporter.Stats, error) {
 // fcp_lif_read_ops{vserver="nas_svm",port_id="e02"} 771
 
 func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
+	rendered, _, stats := p.renderWithHistograms(data)
+	return rendered, stats
+}
+
+// renderWithHistograms renders data like render, and also returns its histograms as native histograms
+// when native_histograms is enabled. Native histograms can only be served in the protobuf format
+func (p *Prometheus) renderWithHistograms(data *matrix.Matrix) ([][]byte, []*nativeHistogram, exporter.Stats) {
 	var (
 		rendered          [][]byte
+		natives           []*nativeHistogram
 		tagged            *set.Set
 		labelsToInclude   []string
 		keysToInclude     []string
+		metricKeys        map[string][]string
 		prefix            string
 		err               error
 		histograms        map[string]*histogram
@@ -280,6 +325,9 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 		tagged = set.New()
 	}
 
+	withExemplars := p.exemplars != nil && isPerf(data)
+	now := time.Now()
+
 	options := data.GetExportOptions()
 
 	if x := options.GetChildS("instance_labels"); x != nil {
@@ -305,7 +353,21 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 		}
 	}
 
-	prefix = p.globalPrefix + data.Object
+	// metric keys are redundant when all labels are included
+	if !includeAllLabels {
+		metricKeys = matrix.MetricKeys(options)
+	}
+
+	retention, metricRetention, err := matrix.Retention(options)
+	if err != nil {
+		p.Logger.Error().Err(err).Str("object", data.Object).Msg("parameter: retention")
+	}
+
+	objectPrefix, err := matrix.ExportPrefix(options)
+	if err != nil {
+		p.Logger.Error().Err(err).Str("object", data.Object).Msg("parameter: prefix")
+	}
+	prefix = p.globalPrefix + objectPrefix + data.Object
 
 	for key, value := range data.GetGlobalLabels() {
 		globalLabels = append(globalLabels, escape(p.replacer, key, value))
@@ -386,6 +448,9 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 					instanceLabelsSet[instanceKey] = struct{}{}
 					allLabels = append(allLabels, instanceKey) //nolint:makezero
 				}
+				if retention != "" {
+					allLabels = append(allLabels, escape(p.replacer, matrix.RetentionLabel, retention)) //nolint:makezero
+				}
 				if p.Params.SortLabels {
 					sort.Strings(allLabels)
 				}
@@ -404,6 +469,17 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 		if p.Params.SortLabels {
 			sort.Strings(instanceKeys)
 		}
+		var exemplar string
+		if withExemplars {
+			node := instance.GetLabel("node")
+			if node == "" {
+				node = data.GetGlobalLabels()["node"]
+			}
+			if node != "" {
+				exemplar = p.exemplars.latest(p.replacer, data.GetGlobalLabels()["cluster"], node, now)
+			}
+		}
+
 		histograms = make(map[string]*histogram)
 		for _, metric := range data.GetMetrics() {
 
@@ -412,6 +488,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 			}
 
 			if value, ok := metric.GetValueString(instance); ok {
+				keys := p.withMetricKeys(instanceKeys, instance, metricKeys[metric.GetName()], cmp.Or(metricRetention[metric.GetName()], retention))
 
 				// metric is array, determine if this is a plain array or histogram
 				if metric.HasLabels() {
@@ -445,7 +522,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 						"%s_%s{%s,%s} %s",
 						prefix,
 						metric.GetName(),
-						strings.Join(instanceKeys, ","),
+						keys,
 						strings.Join(metricLabels, ","),
 						value,
 					)
@@ -460,7 +537,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 					rendered = append(rendered, []byte(x))
 					// scalar metric
 				} else {
-					x := metric.GetName() + "{" + strings.Join(instanceKeys, ",") + "} " + value
+					x := metric.GetName() + "{" + keys + "} " + value + exemplar
 					if prefix != "" {
 						x = prefix + "_" + x
 					}
@@ -480,6 +557,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 		// normalized and export
 		for _, h := range histograms {
 			metric := h.metric
+			keys := p.withMetricKeys(instanceKeys, instance, metricKeys[metric.GetName()], cmp.Or(metricRetention[metric.GetName()], retention))
 			bucketNames := metric.Buckets()
 			objectMetric := data.Object + "_" + metric.GetName()
 			_, ok := normalizedLabels[objectMetric]
@@ -513,11 +591,14 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 				sumMetric   string
 			)
 			if canNormalize {
+				if p.nativeHistograms {
+					natives = append(natives, newNativeHistogram(prefix+"_"+metric.GetName(), keys, h.values, normalizedNames))
+				}
 				count, sum := h.computeCountAndSum(normalizedNames)
 				countMetric = fmt.Sprintf("%s_%s{%s} %s",
-					prefix, metric.GetName()+"_count", strings.Join(instanceKeys, ","), count)
+					prefix, metric.GetName()+"_count", keys, count)
 				sumMetric = fmt.Sprintf("%s_%s{%s} %d",
-					prefix, metric.GetName()+"_sum", strings.Join(instanceKeys, ","), sum)
+					prefix, metric.GetName()+"_sum", keys, sum)
 			}
 			for i, value := range h.values {
 				bucketName := (*bucketNames)[i]
@@ -527,7 +608,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 						"%s_%s{%s,%s} %s",
 						prefix,
 						metric.GetName()+"_bucket",
-						strings.Join(instanceKeys, ","),
+						keys,
 						`le="`+normalizedNames[i]+`"`,
 						value,
 					)
@@ -536,7 +617,7 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 						"%s_%s{%s,%s} %s",
 						prefix,
 						metric.GetName(),
-						strings.Join(instanceKeys, ","),
+						keys,
 						escape(p.replacer, "metric", bucketName),
 						value,
 					)
@@ -553,7 +634,33 @@ func (p *Prometheus) render(data *matrix.Matrix) ([][]byte, exporter.Stats) {
 		MetricsExported:   uint64(len(rendered)),
 	}
 
-	return rendered, stats
+	return rendered, natives, stats
+}
+
+// withMetricKeys returns instanceKeys, the metric keys, and the retention class of a metric, joined for rendering.
+// Metric keys without a value are left out, like the InfluxDB exporter does
+func (p *Prometheus) withMetricKeys(instanceKeys []string, instance *matrix.Instance, metricKeys []string, retention string) string {
+	if len(metricKeys) == 0 && retention == "" {
+		return strings.Join(instanceKeys, ",")
+	}
+	keys := slices.Clone(instanceKeys)
+	for _, key := range metricKeys {
+		value := instance.GetLabel(key)
+		if value == "" {
+			continue
+		}
+		kv := escape(p.replacer, key, value)
+		if !slices.Contains(keys, kv) {
+			keys = append(keys, kv)
+		}
+	}
+	if retention != "" {
+		keys = append(keys, escape(p.replacer, matrix.RetentionLabel, retention))
+	}
+	if p.Params.SortLabels {
+		sort.Strings(keys)
+	}
+	return strings.Join(keys, ",")
 }
 
 var numAndUnitRe = regexp.MustCompile(`(\d+)\s*(\w+)`)
@@ -6,11 +6,16 @@ package prometheus
 
 import (
 	"bytes"
+	"compress/gzip"
 	"github.com/google/go-cmp/cmp"
 	"github.com/netapp/harvest/v2/cmd/poller/exporter"
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"slices"
 	"strings"
 	"testing"
@@ -56,6 +61,145 @@ func TestFilterMetaTags(t *testing.T) {
 	t.Log("OK - output is exactly what is expected")
 }
 
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           bool
+	}{
+		{acceptEncoding: "gzip", want: true},
+		{acceptEncoding: "deflate, GZIP;q=0.8", want: true},
+		{acceptEncoding: "gzip;q=0", want: false},
+		{acceptEncoding: "identity", want: false},
+		{acceptEncoding: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			if got := acceptsGzip(tt.acceptEncoding); got != tt.want {
+				t.Errorf("acceptsGzip() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeMetricsGzip(t *testing.T) {
+	p, err := setUpPrometheusExporter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Export(setUpMatrix("bike")); err != nil {
+		t.Fatal(err)
+	}
+	prom := p.(*Prometheus)
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		prom.ServeMetrics(w, r)
+		return w
+	}
+
+	plain := serve("")
+	if got := plain.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding got=%q, want none", got)
+	}
+	if !strings.HasPrefix(plain.Body.String(), "bike_max_speed{} 3\nbike_max_speed{} 3\n") {
+		t.Errorf("plain body got=%q", plain.Body.String())
+	}
+
+	compressed := serve("gzip")
+	if got := compressed.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding got=%q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "bike_max_speed{} 3\nbike_max_speed{} 3\n") || !strings.HasSuffix(string(body), "\n") {
+		t.Errorf("gzip body got=%q", body)
+	}
+}
+
+func TestServeMetricsAuth(t *testing.T) {
+	absExp := exporter.New(
+		"Prometheus",
+		"prom1",
+		&options.Options{PromPort: 1},
+		conf.Exporter{
+			IsTest: true,
+			Auth:   &conf.ExporterAuth{BearerToken: "s3cret", Username: "prom", Password: "pass"},
+		},
+		nil,
+	)
+	p := New(absExp)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	prom := p.(*Prometheus)
+
+	tests := []struct {
+		name   string
+		header string
+		user   string
+		pass   string
+		want   int
+	}{
+		{name: "bearer", header: "Bearer s3cret", want: http.StatusOK},
+		{name: "bearer lowercase", header: "bearer s3cret", want: http.StatusOK},
+		{name: "invalid bearer", header: "Bearer s3cre", want: http.StatusUnauthorized},
+		{name: "basic", user: "prom", pass: "pass", want: http.StatusOK},
+		{name: "invalid basic", user: "prom", pass: "wrong", want: http.StatusUnauthorized},
+		{name: "none", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+			w := httptest.NewRecorder()
+			prom.ServeMetrics(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status got=%d, want=%d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("WWW-Authenticate header missing")
+			}
+		})
+	}
+}
+
+func TestNewScrapeAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    *conf.ExporterAuth
+		tls     conf.TLS
+		wantErr bool
+	}{
+		{name: "disabled", auth: nil},
+		{name: "token", auth: &conf.ExporterAuth{BearerToken: "s3cret"}},
+		{name: "empty", auth: &conf.ExporterAuth{}, wantErr: true},
+		{name: "username without password", auth: &conf.ExporterAuth{Username: "prom"}, wantErr: true},
+		{name: "client cert without tls", auth: &conf.ExporterAuth{ClientCAFile: "ca.pem"}, wantErr: true},
+		{name: "missing client ca", auth: &conf.ExporterAuth{ClientCAFile: "missing.pem"}, tls: conf.TLS{KeyFile: "key.pem"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newScrapeAuth(tt.auth, tt.tls)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newScrapeAuth() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestEscape(t *testing.T) {
 	replacer := newReplacer()
 
@@ -233,3 +377,130 @@ func setUpPrometheusExporter(prefix string) (exporter.Exporter, error) {
 	err := p.Init()
 	return p, err
 }
+
+func TestRenderMetricKeys(t *testing.T) {
+	p, err := setUpPrometheusExporter("")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+metric_keys:
+  read_ops:
+    - client_ip
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := matrix.New("volume", "volume", "volume")
+	m.SetExportOptions(options)
+	readOps, _ := m.NewMetricUint64("read_ops")
+	writeOps, _ := m.NewMetricUint64("write_ops")
+	instance, _ := m.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	instance.SetLabel("client_ip", "10.0.0.1")
+	_ = readOps.SetValueInt64(instance, 1)
+	_ = writeOps.SetValueInt64(instance, 2)
+	// a metric key without a value is left out, like the InfluxDB exporter does
+	noClient, _ := m.NewInstance("B")
+	noClient.SetLabel("volume", "vol2")
+	_ = readOps.SetValueInt64(noClient, 3)
+
+	rendered, _ := p.(*Prometheus).render(m)
+	var lines []string
+	for _, r := range rendered {
+		lines = append(lines, string(r))
+	}
+	slices.Sort(lines)
+
+	want := `volume_read_ops{client_ip="10.0.0.1",volume="vol1"} 1
+volume_read_ops{volume="vol2"} 3
+volume_write_ops{volume="vol1"} 2`
+	if diff := cmp.Diff(want, strings.Join(lines, "\n")); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRenderRetention(t *testing.T) {
+	p, err := setUpPrometheusExporter("")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	options, err := tree.LoadYaml([]byte(`
+instance_keys:
+  - volume
+instance_labels:
+  - state
+retention: warm
+metric_retention:
+  archive:
+    - size
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := matrix.New("volume", "volume", "volume")
+	m.SetExportOptions(options)
+	size, _ := m.NewMetricUint64("size")
+	readOps, _ := m.NewMetricUint64("read_ops")
+	instance, _ := m.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	instance.SetLabel("state", "online")
+	_ = size.SetValueInt64(instance, 1)
+	_ = readOps.SetValueInt64(instance, 2)
+
+	rendered, _ := p.(*Prometheus).render(m)
+	var lines []string
+	for _, r := range rendered {
+		lines = append(lines, string(r))
+	}
+	slices.Sort(lines)
+
+	want := `volume_labels{retention="warm",state="online",volume="vol1"} 1.0
+volume_read_ops{retention="warm",volume="vol1"} 2
+volume_size{retention="archive",volume="vol1"} 1`
+	if diff := cmp.Diff(want, strings.Join(lines, "\n")); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRenderObjectPrefix(t *testing.T) {
+	p, err := setUpPrometheusExporter("netapp")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	options, err := tree.LoadYaml([]byte(`
+prefix: custom
+instance_keys:
+  - volume
+instance_labels:
+  - state
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := matrix.New("volume", "volume", "volume")
+	m.SetExportOptions(options)
+	readOps, _ := m.NewMetricUint64("read_ops")
+	instance, _ := m.NewInstance("A")
+	instance.SetLabel("volume", "vol1")
+	instance.SetLabel("state", "online")
+	_ = readOps.SetValueInt64(instance, 1)
+
+	rendered, _ := p.(*Prometheus).render(m)
+	var lines []string
+	for _, r := range rendered {
+		lines = append(lines, string(r))
+	}
+	slices.Sort(lines)
+
+	want := `netapp_custom_volume_labels{state="online",volume="vol1"} 1.0
+netapp_custom_volume_read_ops{volume="vol1"} 1`
+	if diff := cmp.Diff(want, strings.Join(lines, "\n")); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
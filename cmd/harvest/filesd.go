@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// fileSdTarget is a target group of the Prometheus file service discovery format.
+// Labels that start with __meta_ are only available while relabeling, so they do not clash with the labels of Harvest
+type fileSdTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// writeFileSd writes the Prometheus exporters of the running pollers to the file service discovery file of the
+// Admin > file_sd section of harvest.yml. Each command of the manager rewrites it, so Prometheus scrapes the pollers
+// that were started, and stops scraping the ones that were stopped, without changing its config
+func writeFileSd(path string) {
+	if path == "" {
+		return
+	}
+	localIP, err := util.FindLocalIP()
+	if err != nil {
+		localIP = "127.0.0.1"
+	}
+	targets := fileSdTargets(conf.Config.PollersOrdered, getPollersStatus(), localIP)
+	if err := writeFileAtomic(path, targets); err != nil {
+		fmt.Printf("Unable to write file service discovery file %s err: %v\n", path, err)
+	}
+}
+
+// fileSdTargets returns a target group for each running poller of pollerNames that has a Prometheus exporter.
+// The address of a target is the local_http_addr of the last Prometheus exporter of the poller, like the port of the
+// poller, or the local IP when the exporter listens on all interfaces
+func fileSdTargets(pollerNames []string, statusesByName map[string][]*util.PollerStatus, localIP string) []fileSdTarget {
+	targets := make([]fileSdTarget, 0)
+	for _, name := range pollerNames {
+		poller, ok := conf.Config.Pollers[name]
+		if !ok {
+			continue
+		}
+		var exporter *conf.Exporter
+		for i := len(poller.Exporters) - 1; i >= 0; i-- {
+			if e, ok := conf.Config.Exporters[poller.Exporters[i]]; ok && e.Type == "Prometheus" {
+				exporter = &e
+				break
+			}
+		}
+		if exporter == nil {
+			continue
+		}
+		ip := localIP
+		if exporter.LocalHTTPAddr != "" && exporter.LocalHTTPAddr != "0.0.0.0" {
+			ip = exporter.LocalHTTPAddr
+		}
+
+		for _, status := range statusesByName[name] {
+			if status.Status != util.StatusRunning || status.PromPort == "" {
+				continue
+			}
+			target := fileSdTarget{
+				Targets: []string{net.JoinHostPort(ip, status.PromPort)},
+				Labels: map[string]string{
+					"__meta_poller":     name,
+					"__meta_datacenter": poller.Datacenter,
+					"__meta_addr":       poller.Addr,
+				},
+			}
+			if exporter.TLS.KeyFile != "" {
+				target.Labels["__scheme__"] = "https"
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// writeFileAtomic writes the targets to a temporary file that is renamed to path, so Prometheus never reads a partial
+// file. The file is not written when its targets did not change, so Prometheus does not reload it for nothing
+func writeFileAtomic(path string, targets []fileSdTarget) error {
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil { //nolint:gosec
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSdTargets(t *testing.T) {
+	pollers, exporters := conf.Config.Pollers, conf.Config.Exporters
+	t.Cleanup(func() {
+		conf.Config.Pollers, conf.Config.Exporters = pollers, exporters
+	})
+	conf.Config.Pollers = map[string]*conf.Poller{
+		"dc1-cluster": {Datacenter: "dc1", Addr: "10.0.0.1", Exporters: []string{"influx", "prom"}},
+		"dc2-cluster": {Datacenter: "dc2", Addr: "10.0.0.2", Exporters: []string{"prom-tls"}},
+		"stopped":     {Datacenter: "dc1", Addr: "10.0.0.3", Exporters: []string{"prom"}},
+		"no-prom":     {Datacenter: "dc1", Addr: "10.0.0.4", Exporters: []string{"influx"}},
+	}
+	conf.Config.Exporters = map[string]conf.Exporter{
+		"influx":   {Type: "InfluxDB"},
+		"prom":     {Type: "Prometheus"},
+		"prom-tls": {Type: "Prometheus", LocalHTTPAddr: "192.168.1.1", TLS: conf.TLS{CertFile: "cert.pem", KeyFile: "key.pem"}},
+	}
+	statuses := map[string][]*util.PollerStatus{
+		"dc1-cluster": {{Name: "dc1-cluster", Status: util.StatusRunning, PromPort: "13000"}},
+		"dc2-cluster": {{Name: "dc2-cluster", Status: util.StatusRunning, PromPort: "13001"}},
+		"stopped":     {{Name: "stopped", Status: util.StatusStopped, PromPort: "13002"}},
+		"no-prom":     {{Name: "no-prom", Status: util.StatusRunning}},
+	}
+
+	got := fileSdTargets([]string{"dc1-cluster", "dc2-cluster", "stopped", "no-prom", "unknown"}, statuses, "10.1.1.1")
+	want := []fileSdTarget{
+		{
+			Targets: []string{"10.1.1.1:13000"},
+			Labels:  map[string]string{"__meta_poller": "dc1-cluster", "__meta_datacenter": "dc1", "__meta_addr": "10.0.0.1"},
+		},
+		{
+			Targets: []string{"192.168.1.1:13001"},
+			Labels: map[string]string{"__meta_poller": "dc2-cluster", "__meta_datacenter": "dc2", "__meta_addr": "10.0.0.2",
+				"__scheme__": "https"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("fileSdTargets() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "harvest.json")
+	targets := []fileSdTarget{{Targets: []string{"10.1.1.1:13000"}, Labels: map[string]string{"__meta_poller": "dc1"}}}
+	if err := writeFileAtomic(path, targets); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "targets": [
+      "10.1.1.1:13000"
+    ],
+    "labels": {
+      "__meta_poller": "dc1"
+    }
+  }
+]
+`
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("writeFileAtomic() got=%s, want=%s", got, want)
+	}
+
+	// all pollers stopped
+	if err := writeFileAtomic(path, []fileSdTarget{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "[]\n" {
+		t.Errorf("writeFileAtomic() got=%s, want=[]", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("writeFileAtomic() left %d files, want 1", len(entries))
+	}
+}
@@ -25,9 +25,13 @@ import (
 	"github.com/netapp/harvest/v2/cmd/admin"
 	"github.com/netapp/harvest/v2/cmd/harvest/version"
 	"github.com/netapp/harvest/v2/cmd/tools/doctor"
+	"github.com/netapp/harvest/v2/cmd/tools/e2e"
 	"github.com/netapp/harvest/v2/cmd/tools/generate"
 	"github.com/netapp/harvest/v2/cmd/tools/grafana"
+	"github.com/netapp/harvest/v2/cmd/tools/importer"
+	"github.com/netapp/harvest/v2/cmd/tools/rename"
 	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/cmd/tools/template"
 	"github.com/netapp/harvest/v2/cmd/tools/zapi"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/set"
@@ -110,6 +114,11 @@ func doManageCmd(cmd *cobra.Command, args []string) {
 		}
 		log.Fatalf("config [%s]: %v\n", opts.config, err)
 	}
+	if opts.command == "start" || opts.command == "restart" {
+		for _, problem := range conf.Validate(conf.ConfigPath(opts.config)) {
+			fmt.Printf("warning: %s\n", problem.Error())
+		}
+	}
 
 	pollerNames = conf.Config.PollersOrdered
 	pollers := conf.Config.Pollers
@@ -168,6 +177,7 @@ func doManageCmd(cmd *cobra.Command, args []string) {
 	case "start":
 		startAllPollers(pollersFiltered, statusesByName)
 	}
+	writeFileSd(conf.Config.Admin.FileSd.Path)
 	printTable(pollersFiltered)
 }
 
@@ -524,6 +534,10 @@ func init() {
 	rootCmd.AddCommand(zapi.Cmd, rest.Cmd, grafana.Cmd)
 	rootCmd.AddCommand(generate.Cmd)
 	rootCmd.AddCommand(doctor.Cmd)
+	rootCmd.AddCommand(importer.Cmd)
+	rootCmd.AddCommand(rename.Cmd)
+	rootCmd.AddCommand(template.Cmd)
+	rootCmd.AddCommand(e2e.Cmd)
 	rootCmd.AddCommand(version.Cmd())
 	rootCmd.AddCommand(admin.Cmd())
 
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tlsconf"
+	"time"
+)
+
+// certificateWarnDays is the number of days before its expiry that the poller warns about a certificate
+const certificateWarnDays = 30
+
+// certificateSchedule is how often the poller re-reads its certificates, they are exported with the poller metadata
+var certificateSchedule = "1h"
+
+// certificate is a TLS certificate used by the poller or by one of its exporters
+type certificate struct {
+	component string // poller, or the name of the exporter
+	kind      string // client, server, or ca
+	path      string // file of the certificate, certificate_script when the certificate_script returned it
+	expiry    func() (time.Time, error)
+}
+
+// tlsCertificates returns the certificates of the poller: the client certificate that authenticates the poller with
+// its cluster, the CA of the cluster, and the certificates and CAs of the exporters of the poller
+func (p *Poller) tlsCertificates() []certificate {
+	var certs []certificate
+	fromFile := func(component string, kind string, path string) {
+		if path == "" {
+			return
+		}
+		certs = append(certs, certificate{
+			component: component,
+			kind:      kind,
+			path:      path,
+			expiry:    func() (time.Time, error) { return tlsconf.Expiry(path) },
+		})
+	}
+
+	if p.params.AuthStyle == conf.CertificateAuth && p.auth != nil {
+		pollerAuth, err := p.auth.GetPollerAuth()
+		if err != nil {
+			logger.Warn().Err(err).Msg("Unable to check the expiry of the client certificate")
+		} else {
+			path := pollerAuth.CertPath
+			if pollerAuth.HasCertificateScript {
+				path = "certificate_script"
+			}
+			certs = append(certs, certificate{
+				component: "poller",
+				kind:      "client",
+				path:      path,
+				expiry: func() (time.Time, error) {
+					cert, err := pollerAuth.Certificate()
+					if err != nil {
+						return time.Time{}, err
+					}
+					return tlsconf.ChainExpiry(cert.Certificate)
+				},
+			})
+		}
+	}
+	fromFile("poller", "ca", p.params.CaCertPath)
+
+	for _, name := range p.params.Exporters {
+		exp, ok := p.exporterParams[name]
+		if !ok {
+			continue
+		}
+		fromFile(name, "server", exp.TLS.CertFile)
+		fromFile(name, "ca", exp.TLS.CAFile)
+	}
+	return certs
+}
+
+// checkCertificates exports the days until each certificate of the poller expires, and warns about the ones that
+// expire within certificateWarnDays, since an expired client certificate silently fails the authentication of
+// the poller
+func (p *Poller) checkCertificates() (map[string]*matrix.Matrix, error) {
+	p.certificates.PurgeInstances()
+	now := time.Now()
+	for _, cert := range p.tlsCertificates() {
+		expiry, err := cert.expiry()
+		if err != nil {
+			logger.Warn().Err(err).
+				Str("component", cert.component).
+				Str("kind", cert.kind).
+				Str("path", cert.path).
+				Msg("Unable to check the expiry of the certificate")
+			continue
+		}
+
+		days := expiry.Sub(now).Hours() / 24
+		instance, err := p.certificates.NewInstance(cert.component + "." + cert.kind + "." + cert.path)
+		if err != nil {
+			continue
+		}
+		instance.SetLabel("component", cert.component)
+		instance.SetLabel("kind", cert.kind)
+		instance.SetLabel("path", cert.path)
+		p.certificates.GetMetric("days_to_expiry").SetValueFloat64(instance, days)
+
+		switch {
+		case days <= 0:
+			logger.Error().
+				Str("component", cert.component).
+				Str("kind", cert.kind).
+				Str("path", cert.path).
+				Time("expiry", expiry).
+				Msg("Certificate expired")
+		case days <= certificateWarnDays:
+			logger.Warn().
+				Str("component", cert.component).
+				Str("kind", cert.kind).
+				Str("path", cert.path).
+				Time("expiry", expiry).
+				Int("days", int(days)).
+				Msg("Certificate expires soon")
+		}
+	}
+	return nil, nil
+}
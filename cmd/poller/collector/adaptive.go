@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/schedule"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// adaptiveSlow is the fraction of changed values below which the interval of the data task doubles
+	adaptiveSlow = 0.01
+	// adaptiveFast is the fraction of changed values above which the interval of the data task halves
+	adaptiveFast = 0.5
+	// defaultAdaptiveTolerance is the relative change below which a value is considered unchanged
+	defaultAdaptiveTolerance = 0.05
+)
+
+// adaptive lengthens the interval of the data task of objects whose values change slowly, e.g. config objects,
+// and shortens it, within bounds, when many values change between polls
+type adaptive struct {
+	min       time.Duration
+	max       time.Duration
+	tolerance float64
+	previous  map[string]float64 // values of the last poll, by instance key and metric name
+}
+
+// parseAdaptive returns the "adaptive_schedule" section of the template, or nil when the template does not adapt
+// its schedule. base is the interval of the data task in the schedule. Example:
+//
+//	adaptive_schedule:
+//	  min_interval: 1m
+//	  max_interval: 15m
+//	  tolerance: 0.05
+func parseAdaptive(params *node.Node, base time.Duration) (*adaptive, error) {
+	section := params.GetChildS("adaptive_schedule")
+	if section == nil {
+		return nil, nil
+	}
+	if base <= 0 {
+		return nil, fmt.Errorf("adaptive_schedule: the schedule has no data task")
+	}
+	a := &adaptive{min: base, max: 4 * base, tolerance: defaultAdaptiveTolerance, previous: make(map[string]float64)}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{{"min_interval", &a.min}, {"max_interval", &a.max}} {
+		s := section.GetChildContentS(d.name)
+		if s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("adaptive_schedule: %s (%s) must be a positive duration", d.name, s)
+		}
+		*d.dst = v
+	}
+	if a.max < a.min {
+		return nil, fmt.Errorf("adaptive_schedule: max_interval (%s) must not be less than min_interval (%s)", a.max, a.min)
+	}
+	if s := section.GetChildContentS("tolerance"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("adaptive_schedule: tolerance (%s) must be a non-negative number", s)
+		}
+		a.tolerance = v
+	}
+	return a, nil
+}
+
+// next compares the exported values of mat with the values of the last poll, and returns the interval of the
+// next poll and the fraction of the values that changed. The interval doubles when fewer than 1% of the values
+// changed, and halves when more than half of them changed, within min and max.
+// The interval is unchanged when there is nothing to compare, e.g. on the first poll
+func (a *adaptive) next(mat *matrix.Matrix, current time.Duration) (time.Duration, float64) {
+	values := make(map[string]float64, len(a.previous))
+	compared, changed := 0, 0
+	for _, metric := range mat.GetMetrics() {
+		if !metric.IsExportable() {
+			continue
+		}
+		for key, instance := range mat.GetInstances() {
+			value, ok := metric.GetValueFloat64(instance)
+			if !ok {
+				continue
+			}
+			id := key + "\x00" + metric.GetName()
+			values[id] = value
+			if old, ok := a.previous[id]; ok {
+				compared++
+				if a.changed(old, value) {
+					changed++
+				}
+			}
+		}
+	}
+	a.previous = values
+
+	interval := min(max(current, a.min), a.max)
+	if compared == 0 {
+		return interval, 0
+	}
+	fraction := float64(changed) / float64(compared)
+	switch {
+	case fraction < adaptiveSlow:
+		interval = min(2*interval, a.max)
+	case fraction > adaptiveFast:
+		interval = max(interval/2, a.min)
+	}
+	return interval, fraction
+}
+
+func (a *adaptive) changed(old, value float64) bool {
+	diff := math.Abs(value - old)
+	if diff == 0 {
+		return false
+	}
+	return diff > a.tolerance*max(math.Abs(old), math.Abs(value))
+}
+
+// adaptSchedule changes the interval of the data task to how often the values of the collector's object change,
+// and records the interval in the metadata of the data task
+func (c *AbstractCollector) adaptSchedule(task *schedule.Task, data map[string]*matrix.Matrix) {
+	a := c.adaptive
+	if a == nil {
+		return
+	}
+	mat, ok := data[c.Object]
+	if !ok {
+		return
+	}
+	current := task.GetInterval()
+	interval, fraction := a.next(mat, current)
+	if interval != current {
+		c.Schedule.SetInterval(task, interval)
+		c.Logger.Info().
+			Str("task", task.Name).
+			Str("interval", interval.String()).
+			Str("previous", current.String()).
+			Float64("changed", fraction).
+			Msg("Adapted schedule")
+	}
+	_ = c.Metadata.LazySetValueFloat64("effective_interval", task.Name, interval.Seconds())
+}
+
+// dataInterval returns the interval of the data task of s, or 0 when s has no data task
+func dataInterval(s schedule.Scheduler) time.Duration {
+	if s == nil {
+		return 0
+	}
+	if task := s.GetTask("data"); task != nil {
+		return task.GetInterval()
+	}
+	return 0
+}
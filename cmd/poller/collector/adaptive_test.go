@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseAdaptive(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		base    time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no section", yaml: "object: volume\n", base: time.Minute, wantNil: true},
+		{name: "defaults", yaml: "adaptive_schedule:\n  tolerance: 0.1\n", base: time.Minute, wantMin: time.Minute, wantMax: 4 * time.Minute},
+		{name: "bounds", yaml: "adaptive_schedule:\n  min_interval: 30s\n  max_interval: 15m\n", base: time.Minute, wantMin: 30 * time.Second, wantMax: 15 * time.Minute},
+		{name: "max below min", yaml: "adaptive_schedule:\n  min_interval: 5m\n  max_interval: 1m\n", base: time.Minute, wantErr: true},
+		{name: "bad duration", yaml: "adaptive_schedule:\n  max_interval: 15\n", base: time.Minute, wantErr: true},
+		{name: "negative tolerance", yaml: "adaptive_schedule:\n  tolerance: -1\n", base: time.Minute, wantErr: true},
+		{name: "no data task", yaml: "adaptive_schedule:\n  max_interval: 15m\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			a, err := parseAdaptive(params, tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAdaptive() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (a == nil) != tt.wantNil {
+				t.Fatalf("parseAdaptive() nil=%t, wantNil=%t", a == nil, tt.wantNil)
+			}
+			if a != nil && (a.min != tt.wantMin || a.max != tt.wantMax) {
+				t.Errorf("bounds got=%s,%s want=%s,%s", a.min, a.max, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestAdaptive_Next(t *testing.T) {
+	a := &adaptive{min: 30 * time.Second, max: 4 * time.Minute, tolerance: 0.05, previous: make(map[string]float64)}
+	data := matrix.New("Rest", "volume", "volume")
+	metric, _ := data.NewMetricFloat64("size")
+	for i := range 100 {
+		instance, _ := data.NewInstance("vol" + strconv.Itoa(i))
+		metric.SetValueFloat64(instance, 1000)
+	}
+	set := func(changed int, value float64) {
+		for i := range changed {
+			metric.SetValueFloat64(data.GetInstance("vol"+strconv.Itoa(i)), value)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		changed int     // number of instances whose value is set
+		value   float64 // value of the changed instances
+		want    time.Duration
+	}{
+		{name: "first poll", want: time.Minute},
+		{name: "unchanged", want: 2 * time.Minute},
+		{name: "below tolerance", changed: 100, value: 1010, want: 4 * time.Minute},
+		{name: "max", want: 4 * time.Minute},
+		{name: "some changed", changed: 10, value: 2000, want: 4 * time.Minute},
+		{name: "most changed", changed: 60, value: 3000, want: 2 * time.Minute},
+		{name: "all changed", changed: 100, value: 5000, want: time.Minute},
+		{name: "all changed again", changed: 100, value: 10, want: 30 * time.Second},
+		{name: "min", changed: 100, value: 1000, want: 30 * time.Second},
+	}
+	interval := time.Minute
+	for _, step := range steps {
+		set(step.changed, step.value)
+		interval, _ = a.next(data, interval)
+		if interval != step.want {
+			t.Errorf("%s: interval got=%s, want=%s", step.name, interval, step.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package collector
+
+import (
+	"errors"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Failure classes of a poll. Consecutive failures of the same class open the circuit of a collector
+const (
+	FailureConnection  = "connection"
+	FailureDeadline    = "deadline"
+	FailureAuth        = "auth"
+	FailurePermission  = "permission"
+	FailureNoInstance  = "no_instance"
+	FailureNoMetric    = "no_metric"
+	FailureAPIRejected = "api_rejected"
+	FailureCMReject    = "cm_reject"
+	FailureOther       = "other"
+)
+
+// States of a circuit, exported as the circuit_state collector metadata
+const (
+	CircuitOK       uint8 = 0 // last poll succeeded
+	CircuitDegraded uint8 = 1 // polls are failing, but not often enough to open the circuit
+	CircuitStandby  uint8 = 2 // circuit is open, the failed task is retried after the cool-down
+)
+
+// CircuitStates are the names of the circuit states, indexed by state
+var CircuitStates = [3]string{"ok", "degraded", "standby"}
+
+// Escalation is the policy of a failure class
+type Escalation struct {
+	Failures    int           // consecutive failures that open the circuit, 0 never opens it
+	CoolDown    time.Duration // how long the circuit stays open the first time
+	MaxCoolDown time.Duration // when set, the cool-down is multiplied by 4 each time the circuit reopens, up to MaxCoolDown
+	Jitter      time.Duration // random duration added to the cool-down
+	// When true, the failed task is retried after the cool-down even if that is sooner than its interval.
+	// Otherwise, the task waits for the longer of the two
+	Early bool
+}
+
+// defaultEscalations are the policies of the failure classes. All classes except auth and other match how
+// collectors handled their errors before the breaker. Auth and other errors used to be retried on every poll,
+// now the task enters standby for 5 minutes after 3 and 5 consecutive failures
+func defaultEscalations() map[string]Escalation {
+	return map[string]Escalation{
+		FailureConnection:  {Failures: 1, CoolDown: 4 * time.Second, MaxCoolDown: 1024 * time.Second, Early: true},
+		FailureCMReject:    {Failures: 1, CoolDown: 30 * time.Second, Jitter: 30 * time.Second, Early: true},
+		FailureDeadline:    {Failures: 0, CoolDown: 5 * time.Minute},
+		FailureNoInstance:  {Failures: 1, CoolDown: 5 * time.Minute},
+		FailureNoMetric:    {Failures: 1, CoolDown: time.Hour},
+		FailurePermission:  {Failures: 1, CoolDown: time.Hour},
+		FailureAPIRejected: {Failures: 1, CoolDown: time.Hour},
+		FailureAuth:        {Failures: 3, CoolDown: 5 * time.Minute},
+		FailureOther:       {Failures: 5, CoolDown: 5 * time.Minute},
+	}
+}
+
+// Breaker tracks the consecutive failures of a collector's task and decides when its circuit opens.
+// The circuit closes when the task succeeds
+type Breaker struct {
+	escalations map[string]Escalation
+	task        string        // task of the consecutive failures
+	class       string        // class of the consecutive failures
+	failures    int           // number of consecutive failures of class
+	coolDown    time.Duration // cool-down of the last time the circuit opened
+}
+
+// NewBreaker returns a Breaker with the default escalations, overridden by
+// the circuit_breaker collector parameter. Example:
+//
+//	circuit_breaker:
+//	  auth:
+//	    failures: 1
+//	    cool_down: 1h
+func NewBreaker(params *node.Node) (*Breaker, error) {
+	b := &Breaker{escalations: defaultEscalations()}
+	if params == nil {
+		return b, nil
+	}
+	for _, c := range params.GetChildren() {
+		class := c.GetNameS()
+		e, ok := b.escalations[class]
+		if !ok {
+			return nil, errs.New(errs.ErrInvalidParam, "circuit_breaker: unknown failure class "+class)
+		}
+		if s := c.GetChildContentS("failures"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return nil, errs.New(errs.ErrInvalidParam, "circuit_breaker: "+class+" failures ("+s+") must be a non-negative integer")
+			}
+			e.Failures = n
+		}
+		for _, d := range []struct {
+			name string
+			dst  *time.Duration
+		}{{"cool_down", &e.CoolDown}, {"max_cool_down", &e.MaxCoolDown}, {"jitter", &e.Jitter}} {
+			s := c.GetChildContentS(d.name)
+			if s == "" {
+				continue
+			}
+			v, err := time.ParseDuration(s)
+			if err != nil || v < 0 {
+				return nil, errs.New(errs.ErrInvalidParam, "circuit_breaker: "+class+" "+d.name+" ("+s+") must be a non-negative duration")
+			}
+			*d.dst = v
+		}
+		if e.MaxCoolDown != 0 && e.MaxCoolDown < e.CoolDown {
+			return nil, errs.New(errs.ErrInvalidParam, "circuit_breaker: "+class+" max_cool_down must not be less than cool_down")
+		}
+		b.escalations[class] = e
+	}
+	return b, nil
+}
+
+// Failure records a failed poll of task. When the circuit opens, it returns the cool-down
+// and true. Otherwise, the collector is degraded and it returns false
+func (b *Breaker) Failure(task string, class string) (time.Duration, bool) {
+	if task != b.task || class != b.class {
+		b.task = task
+		b.class = class
+		b.failures = 0
+		b.coolDown = 0
+	}
+	b.failures++
+
+	e := b.escalations[class]
+	if e.Failures == 0 || b.failures < e.Failures {
+		return 0, false
+	}
+
+	switch {
+	case b.coolDown == 0 || e.MaxCoolDown == 0:
+		b.coolDown = e.CoolDown
+	case b.coolDown < e.MaxCoolDown:
+		b.coolDown = min(b.coolDown*4, e.MaxCoolDown)
+	}
+
+	coolDown := b.coolDown
+	if e.Jitter > 0 {
+		coolDown += time.Duration(rand.Int63n(int64(e.Jitter))) //nolint:gosec
+	}
+	return coolDown, true
+}
+
+// Success records a successful poll of task and closes the circuit if task was failing
+func (b *Breaker) Success(task string) {
+	if task != b.task {
+		return
+	}
+	b.task = ""
+	b.class = ""
+	b.failures = 0
+	b.coolDown = 0
+}
+
+// IsEarly returns true when tasks that failed with class are retried as soon as the cool-down ends
+func (b *Breaker) IsEarly(class string) bool {
+	return b.escalations[class].Early
+}
+
+// State returns the state of the circuit
+func (b *Breaker) State() uint8 {
+	switch {
+	case b.failures == 0:
+		return CircuitOK
+	case b.coolDown > 0:
+		return CircuitStandby
+	default:
+		return CircuitDegraded
+	}
+}
+
+// Class returns the failure class of the consecutive failures, empty when the last poll succeeded
+func (b *Breaker) Class() string {
+	return b.class
+}
+
+// FailureClass classifies the error of a poll
+func FailureClass(err error) string {
+	var (
+		urlErr *url.Error
+		netErr net.Error
+	)
+	switch {
+	// checked before connection errors, since the request that ran out of time is also a url.Error
+	case errors.Is(err, errs.ErrDeadline):
+		return FailureDeadline
+	case errors.Is(err, errs.ErrConnection), errors.As(err, &urlErr), errors.As(err, &netErr):
+		return FailureConnection
+	case errs.IsRestErr(err, errs.CMReject):
+		return FailureCMReject
+	case errors.Is(err, errs.ErrAuthFailed):
+		return FailureAuth
+	case errors.Is(err, errs.ErrNoInstance):
+		return FailureNoInstance
+	case errors.Is(err, errs.ErrNoMetric):
+		return FailureNoMetric
+	case errors.Is(err, errs.ErrPermissionDenied):
+		return FailurePermission
+	case errors.Is(err, errs.ErrAPIRequestRejected):
+		return FailureAPIRejected
+	}
+	return FailureOther
+}
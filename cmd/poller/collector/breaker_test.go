@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "zapi connection", err: errs.New(errs.ErrConnection, "dial"), want: FailureConnection},
+		{name: "rest connection", err: fmt.Errorf("connection error %w", &url.Error{Op: "Get", URL: "https://a", Err: fmt.Errorf("refused")}), want: FailureConnection},
+		{name: "deadline", err: fmt.Errorf("%w: %w", errs.New(errs.ErrDeadline, "task data"), &url.Error{Op: "Get", URL: "https://a", Err: fmt.Errorf("context deadline exceeded")}), want: FailureDeadline},
+		{name: "auth", err: errs.NewRest().StatusCode(401).Error(errs.ErrAuthFailed).Build(), want: FailureAuth},
+		{name: "permission", err: errs.New(errs.ErrPermissionDenied, "volume"), want: FailurePermission},
+		{name: "no instances", err: errs.New(errs.ErrNoInstance, "volume"), want: FailureNoInstance},
+		{name: "no metrics", err: errs.New(errs.ErrNoMetric, "volume"), want: FailureNoMetric},
+		{name: "api rejected", err: errs.New(errs.ErrAPIRequestRejected, "volume"), want: FailureAPIRejected},
+		{name: "cm reject", err: errs.NewRest().StatusCode(500).Code(errs.CMReject.Code).Build(), want: FailureCMReject},
+		{name: "other", err: fmt.Errorf("boom"), want: FailureOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailureClass(tt.err); got != tt.want {
+				t.Errorf("FailureClass() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b, err := NewBreaker(nil)
+	if err != nil {
+		t.Fatalf("NewBreaker() err=%v", err)
+	}
+
+	// connection failures open the circuit immediately and back off up to the max cool-down
+	want := []time.Duration{4 * time.Second, 16 * time.Second, 64 * time.Second, 256 * time.Second, 1024 * time.Second, 1024 * time.Second}
+	for i, w := range want {
+		coolDown, open := b.Failure("data", FailureConnection)
+		if !open || coolDown != w {
+			t.Errorf("failure %d got=%s,%t want=%s,true", i, coolDown, open, w)
+		}
+	}
+	if b.State() != CircuitStandby {
+		t.Errorf("State() got=%d want=%d", b.State(), CircuitStandby)
+	}
+
+	// a success of another task does not close the circuit
+	b.Success("instance")
+	if b.State() != CircuitStandby {
+		t.Errorf("State() got=%d want=%d", b.State(), CircuitStandby)
+	}
+	b.Success("data")
+	if b.State() != CircuitOK {
+		t.Errorf("State() got=%d want=%d", b.State(), CircuitOK)
+	}
+
+	// auth failures degrade the collector before the circuit opens
+	for i := range 2 {
+		if _, open := b.Failure("data", FailureAuth); open {
+			t.Errorf("auth failure %d opened the circuit", i)
+		}
+		if b.State() != CircuitDegraded {
+			t.Errorf("State() got=%d want=%d", b.State(), CircuitDegraded)
+		}
+	}
+	if coolDown, open := b.Failure("data", FailureAuth); !open || coolDown != 5*time.Minute {
+		t.Errorf("auth failure got=%s,%t want=5m,true", coolDown, open)
+	}
+
+	// a failure of another class starts counting again
+	if _, open := b.Failure("data", FailureOther); open {
+		t.Errorf("other failure opened the circuit")
+	}
+	if b.State() != CircuitDegraded {
+		t.Errorf("State() got=%d want=%d", b.State(), CircuitDegraded)
+	}
+}
+
+func TestNewBreaker(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "override", yaml: "auth:\n  failures: 1\n  cool_down: 1h\nconnection:\n  max_cool_down: 10m\n"},
+		{name: "unknown class", yaml: "timeout:\n  failures: 1\n", wantErr: true},
+		{name: "bad failures", yaml: "auth:\n  failures: -1\n", wantErr: true},
+		{name: "bad duration", yaml: "auth:\n  cool_down: soon\n", wantErr: true},
+		{name: "max less than cool down", yaml: "no_instance:\n  cool_down: 1h\n  max_cool_down: 1m\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := breakerParams(t, tt.yaml)
+			b, err := NewBreaker(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBreaker() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if coolDown, open := b.Failure("data", FailureAuth); !open || coolDown != time.Hour {
+				t.Errorf("auth failure got=%s,%t want=1h,true", coolDown, open)
+			}
+		})
+	}
+}
+
+func breakerParams(t *testing.T, yaml string) *node.Node {
+	t.Helper()
+	root, err := tree.LoadYaml([]byte(yaml))
+	if err != nil {
+		t.Fatalf("failed to load yaml err=%v", err)
+	}
+	return root
+}
+
+func TestRecordFailure(t *testing.T) {
+	c := New("Rest", "Volume", options.New(), nil, nil)
+	c.Metadata = matrix.New("Rest", "metadata_collector", "metadata_collector_Volume")
+	_, _ = c.Metadata.NewMetricUint64("failures")
+
+	c.recordFailure("data", errs.NewRest().StatusCode(401).Error(errs.ErrAuthFailed).Build())
+	c.recordFailure("data", errs.NewRest().StatusCode(401).Error(errs.ErrAuthFailed).Build())
+	c.recordFailure("data", errs.New(errs.ErrConnection, "dial"))
+
+	if got := len(c.Metadata.GetInstances()); got != len(errs.Categories) {
+		t.Errorf("instances got=%d, want=%d", got, len(errs.Categories))
+	}
+	for category, want := range map[string]uint64{errs.CategoryAuth: 2, errs.CategoryNetwork: 1, errs.CategoryParse: 0} {
+		instance := c.Metadata.GetInstance("failure:data:" + category)
+		if instance == nil {
+			t.Fatalf("missing failure instance of %s", category)
+		}
+		if got := instance.GetLabel("category"); got != category {
+			t.Errorf("category label got=%s, want=%s", got, category)
+		}
+		if got, _ := c.Metadata.GetMetric("failures").GetValueUint64(instance); got != want {
+			t.Errorf("%s failures got=%d, want=%d", category, got, want)
+		}
+	}
+}
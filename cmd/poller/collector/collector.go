@@ -17,10 +17,16 @@ implemented by AbstractCollector.
 package collector
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/bus"
 	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/hook"
+	"github.com/netapp/harvest/v2/pkg/lease"
 	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/maintenance"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"math"
@@ -29,9 +35,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/guard"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
 
@@ -60,7 +68,9 @@ type Collector interface {
 	AddCollectCount(uint64)
 	GetStatus() (uint8, string, string)
 	SetStatus(uint8, string)
-	SetSchedule(*schedule.Schedule)
+	SetSchedule(schedule.Scheduler)
+	GetSchedule() schedule.Scheduler
+	SetBreaker(*Breaker)
 	SetMatrix(map[string]*matrix.Matrix)
 	SetMetadata(*matrix.Matrix)
 	WantedExporters([]string) []string
@@ -68,6 +78,10 @@ type Collector interface {
 	LoadPlugins(*node.Node, Collector, string) error
 	LoadPlugin(string, *plugin.AbstractPlugin) plugin.Plugin
 	CollectAutoSupport(p *Payload)
+	Poll() ([]*matrix.Matrix, error)
+	IsShadow() bool
+	CheckGaps(time.Time) uint64
+	QueueReload(func() error)
 }
 
 const (
@@ -96,15 +110,35 @@ type AbstractCollector struct {
 	Options *options.Options // poller options
 	Params  *node.Node       // collector parameters
 	// note that this is a merge of poller parameters, collector conf and object conf ("subtemplate")
-	Schedule     *schedule.Schedule         // schedule of the collector
+	Sources      []Source                   // layers merged into Params, only recorded when not nil, e.g. by doctor params
+	Schedule     schedule.Scheduler         // schedule of the collector
+	Breaker      *Breaker                   // decides when failed tasks enter standby
+	Maintenance  *maintenance.Calendar      // maintenance windows of the poller, nil when there are none
+	Lease        *lease.Elector             // decides if the poller of a pair exports, nil when the poller has no lease
+	Hooks        *hook.Hooks                // scripts run around the polls of the poller, nil when there are none
+	Bus          *bus.Bus                   // shares collected data between the collectors of the poller
+	Guard        *guard.Guard               // memory and cardinality limits of the poller, nil when there are none
+	Shadow       *Shadow                    // pairs the collector with a shadow collector of its object, nil when there is none
+	Shadowing    bool                       // true when the collector is the shadow collector of Shadow
 	Matrix       map[string]*matrix.Matrix  // the data storage of the collector
 	Metadata     *matrix.Matrix             // metadata of the collector, such as poll duration, collected data points etc.
 	Exporters    []exporter.Exporter        // the exporters that the collector will emit data to
 	Plugins      map[string][]plugin.Plugin // built-in or custom plugins
 	collectCount uint64                     // count of collected data points
+	exporting    map[string]*atomic.Bool    // true while an exporter exports the data of the collector
 	// this is different from what the collector will have in its metadata, since this variable
 	// holds count independent of the poll interval of the collector, used to give stats to Poller
 	countMux    *sync.Mutex       // used for atomic access to collectCount
+	inWindow    bool              // true while the collector is in a maintenance window
+	shed        bool              // true while the collector is shed by the resource guard
+	units       map[string]string // units of the template by metric name, nil until the pipeline is initialized
+	derived     []derivedLabel    // labels derived from the labels of instances
+	sampling    *sampling         // the instances to export, nil when the template does not sample
+	adaptive    *adaptive         // adapts the interval of the data task, nil when the template does not adapt it
+	instanceTTL *instanceTTL      // removes instances missing from polls, nil when the template has no instance_ttl
+	labelLimits *labelLimits      // collapses the values of labels over their limit, nil when the template has no label_limits
+	gaps        *exportGaps       // detects the data intervals the collector missed, see CheckGaps
+	reload      chan func() error // reloads of the counters of the template, see RequestReload
 	Auth        *auth.Credentials // used for authing the collector
 	HostVersion string
 	HostModel   string
@@ -116,9 +150,11 @@ func New(name, object string, o *options.Options, params *node.Node, credentials
 		Name:     name,
 		Object:   object,
 		Options:  o,
-		Logger:   logging.Get().SubLogger("collector", name+":"+object),
+		Logger:   logging.ForObject(name, object).SubLogger("collector", name+":"+object),
 		Params:   params,
 		countMux: &sync.Mutex{},
+		gaps:     newExportGaps(),
+		reload:   make(chan func() error, 1),
 		Auth:     credentials,
 	}
 }
@@ -168,7 +204,11 @@ func Init(c Collector) error {
 		}
 	}
 
-	s := schedule.New()
+	// the scheduler of the template decides when the tasks run, by default at the fixed interval of each task
+	s, err := schedule.NewScheduler(params.GetChildContentS("scheduler"))
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, "scheduler: "+err.Error())
+	}
 
 	// Each task will be mapped to a collector method
 	// Example: "data" will be aligned to method PollData()
@@ -195,6 +235,13 @@ func Init(c Collector) error {
 	}
 	c.SetSchedule(s)
 
+	// Initialize the circuit breaker, which decides when failing tasks enter standby
+	breaker, err := NewBreaker(params.GetChildS("circuit_breaker"))
+	if err != nil {
+		return err
+	}
+	c.SetBreaker(breaker)
+
 	// Initialize Matrix, the container of collected data
 	mx := matrix.New(name, object, object)
 	if exportOptions := params.GetChildS("export_options"); exportOptions != nil {
@@ -205,6 +252,51 @@ func Init(c Collector) error {
 	}
 	mx.SetGlobalLabel("datacenter", params.GetChildContentS("datacenter"))
 
+	// How negative, NaN, and Inf cooked values are handled
+	invalidPolicy, err := matrix.ParseInvalidPolicy(params.GetChildContentS("invalid_values"))
+	if err != nil {
+		return errs.New(errs.ErrInvalidParam, "invalid_values: "+err.Error())
+	}
+	mx.SetInvalidPolicy(invalidPolicy)
+	if invalidPolicy == matrix.InvalidFlag && mx.GetExportOptions().GetChildContentS("include_all_labels") != "true" {
+		// The flag is exported with the instance labels, as an instance key it would split the series of the instance
+		labels := mx.GetExportOptions().GetChildS("instance_labels")
+		if labels == nil {
+			labels = mx.GetExportOptions().NewChildS("instance_labels", "")
+		}
+		labels.NewChildS("", matrix.InvalidLabel)
+	}
+
+	// Labels derived from other labels with a regex
+	if _, err := parseDerivedLabels(params); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
+	// Export a stable subset of the instances of objects with huge instance counts
+	if _, err := parseSampling(params); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
+	// Remove the instances of ephemeral objects that are missing from polls
+	if _, err := parseInstanceTTL(params); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
+	// Export the values of labels over their limit as other
+	if _, err := parseLabelLimits(params); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
+	// Adapt the interval of the data task to how often the values of the object change
+	if _, err := parseAdaptive(params, dataInterval(s)); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
+	// Poll out of band when the collector missed data intervals
+	if _, err := parseResyncAfter(params); err != nil {
+		return errs.New(errs.ErrInvalidParam, err.Error())
+	}
+
 	// Add user-defined global labels
 	if gl := params.GetChildS("global_labels"); gl != nil {
 		for _, c := range gl.GetChildren() {
@@ -255,8 +347,30 @@ func Init(c Collector) error {
 	_, _ = md.NewMetricUint64("metrics")
 	_, _ = md.NewMetricUint64("instances")
 	_, _ = md.NewMetricUint64("bytesRx")
+	_, _ = md.NewMetricUint64("bytesRxWire")
 	_, _ = md.NewMetricUint64("numCalls")
 	_, _ = md.NewMetricUint64("pluginInstances")
+	_, _ = md.NewMetricUint8("circuit_state")
+	// set on the failure instances of each task and error category, see recordFailure
+	_, _ = md.NewMetricUint64("failures")
+	// only set by incremental polls, 1 until the last page of a generation is collected
+	_, _ = md.NewMetricUint8("partial")
+	// set on the export instance of each exporter, see export
+	_, _ = md.NewMetricInt64("exporter_time")
+	_, _ = md.NewMetricUint64("exporter_failures")
+	// only set by templates with a sampling section
+	_, _ = md.NewMetricFloat64("sampling_fraction")
+	_, _ = md.NewMetricUint64("sampled_instances")
+	// only set by templates with an instance_ttl
+	_, _ = md.NewMetricUint64("expired_instances")
+	// only set by templates with a label_limits section
+	_, _ = md.NewMetricUint64("collapsed_values")
+	// only set by templates with an adaptive_schedule section
+	_, _ = md.NewMetricFloat64("effective_interval")
+	// only set by shadow collectors
+	_, _ = md.NewMetricUint64("shadow_added")
+	_, _ = md.NewMetricUint64("shadow_removed")
+	_, _ = md.NewMetricUint64("shadow_changed")
 
 	// Used by collector logging but not exported
 	loggingOnly := []string{begin, "export_time"}
@@ -299,6 +413,30 @@ func (c *AbstractCollector) GetHostUUID() string {
 	return c.HostUUID
 }
 
+// FreshShared returns the values of key on the bus when they were collected after since,
+// and are not older than the interval of the collector's data task
+func (c *AbstractCollector) FreshShared(key bus.Key, since time.Time) (bus.Values, bool) {
+	if c.Bus == nil || c.Schedule == nil {
+		return bus.Values{}, false
+	}
+	task := c.Schedule.GetTask("data")
+	if task == nil {
+		return bus.Values{}, false
+	}
+	return c.Bus.Fresh(key, since, task.GetInterval(), time.Now())
+}
+
+// publishLabels shares the labels of the objects in results that other collectors want, e.g. to join them
+func (c *AbstractCollector) publishLabels(results []*matrix.Matrix) {
+	for _, data := range results {
+		if !c.Bus.Wanted(data.Object) {
+			continue
+		}
+		key := bus.Key{Cluster: data.GetGlobalLabels()["cluster"], Object: data.Object}
+		c.Bus.PublishLabels(key, bus.SnapshotLabels(data, time.Now()))
+	}
+}
+
 // Start will run the collector in an infinite loop
 func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 	defer wg.Done()
@@ -313,10 +451,18 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 		exportStart time.Time
 	)
 
-	// keep track of connection errors
-	// to increment time before retry
-	// @TODO add to metadata
-	retryDelay := 1
+	if c.Breaker == nil {
+		c.Breaker, _ = NewBreaker(nil)
+	}
+	c.initPipeline()
+	c.adaptive, _ = parseAdaptive(c.Params, dataInterval(c.Schedule))
+	resyncAfter, _ := parseResyncAfter(c.Params)
+	c.gaps.setResyncAfter(resyncAfter)
+	if c.IsShadow() {
+		// the metadata of the shadow is exported next to the metadata of the current template
+		c.Metadata.Identifier += ".shadow"
+		c.Metadata.SetGlobalLabel("shadow", c.Shadow.Template)
+	}
 	c.SetStatus(0, "running")
 
 	for {
@@ -325,10 +471,20 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 		// https://github.com/NetApp/harvest-private/issues/114 for details
 
 		results := make([]*matrix.Matrix, 0)
+		shed := c.applyGuard()
+		hooked := false // true when the pre_poll hooks of this poll ran
+		skip := false   // true when a pre_poll hook failed and the poll is skipped
+		polled := false // true when the data task succeeded
 
 		// run all scheduled tasks
 		for _, task := range c.Schedule.GetTasks() {
-			if !task.IsDue() {
+			if !c.Schedule.IsDue(task) {
+				continue
+			}
+
+			// skip the poll, and check again when the task is due next time
+			if shed {
+				task.Start()
 				continue
 			}
 
@@ -339,9 +495,19 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 				continue
 			}
 
+			// the pre_poll hooks run once per poll, before its first task
+			if !hooked {
+				hooked = true
+				skip = c.runHook(hook.PrePoll, task.Name, nil)
+			}
+			if skip {
+				task.Start()
+				continue
+			}
+
 			var (
-				start, pluginStart   time.Time
-				taskTime, pluginTime time.Duration
+				start    time.Time
+				taskTime time.Duration
 			)
 
 			// reset task metadata
@@ -351,90 +517,26 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 			data, err := task.Run()
 			taskTime = time.Since(start)
 
-			// poll returned error, try to understand what to do
+			// poll returned error, let the circuit breaker decide what to do
 			switch {
 			case err != nil:
-				if !c.Schedule.IsStandBy() {
-					c.Logger.Debug().Msgf("handling error during [%s] poll...", task.Name)
-				}
-				switch {
-				// target system is unreachable
-				// enter standby mode and retry with some delay that will be increased if we fail again
-				case errors.Is(err, errs.ErrConnection):
-					if retryDelay < 1024 {
-						retryDelay *= 4
-					}
-					if !c.Schedule.IsStandBy() {
-						c.Logger.Warn().
-							Str("task", task.Name).
-							Int("retryDelaySecs", retryDelay).
-							Msg("target unreachable, entering standby mode and retry")
-					}
-					c.Logger.Debug().
-						Err(err).
-						Str("task", task.Name).
-						Int("retryDelaySecs", retryDelay).
-						Msg("target unreachable, entering standby mode and retry")
-					c.Schedule.SetStandByMode(task, time.Duration(retryDelay)*time.Second)
-					c.SetStatus(1, errs.ErrConnection.Error())
-
-				case errs.IsRestErr(err, errs.CMReject):
-					// Try again in 30 to 60 seconds
-					retryAfter := 30 + rand.Int63n(30) //nolint:gosec
-					c.Schedule.SetStandByMode(task, time.Duration(retryAfter)*time.Second)
-					c.SetStatus(1, err.Error())
-					c.Logger.Warn().
-						Str("task", task.Name).
-						Int64("retryAfterSecs", retryAfter).
-						Msg("CM reject, entering standby mode and retry")
-				// there are no instances to collect
-				case errors.Is(err, errs.ErrNoInstance):
-					c.Schedule.SetStandByModeMax(task, 5*time.Minute)
-					c.SetStatus(1, errs.ErrNoInstance.Error())
-					c.Logger.Info().
-						Str("task", task.Name).
-						Msg("no instances, entering standby")
-				// no metrics available
-				case errors.Is(err, errs.ErrNoMetric):
-					c.SetStatus(1, errs.ErrNoMetric.Error())
-					c.Schedule.SetStandByModeMax(task, 1*time.Hour)
-					c.Logger.Info().
-						Str("task", task.Name).
-						Str("object", c.Object).
-						Msg("no metrics of object on system, entering standby mode")
-				// not an error we are expecting, so enter failed or standby state
-				default:
-					switch {
-					case errors.Is(err, errs.ErrPermissionDenied):
-						c.Schedule.SetStandByModeMax(task, 1*time.Hour)
-						c.Logger.Error().Err(err).Str("task", task.Name).Msg("Entering standby mode")
-					case errors.Is(err, errs.ErrAPIRequestRejected):
-						c.Schedule.SetStandByModeMax(task, 1*time.Hour)
-						if !errors.Is(err, errs.ErrMetroClusterNotConfigured) {
-							// Log as info since these are not errors.
-							c.Logger.Info().Err(err).Str("task", task.Name).Msg("Entering standby mode")
-						}
-					default:
-						c.Logger.Error().Err(err).Str("task", task.Name).Send()
-					}
-
-					var herr errs.HarvestError
-					errMsg := err.Error()
-					if ok := errors.As(err, &herr); ok {
-						errMsg = herr.Inner.Error()
-					}
-
-					c.SetStatus(2, errMsg)
-				}
+				c.handleFailure(task, err)
+				c.runHook(hook.OnFailure, task.Name, err)
 				continue
 			case c.Schedule.IsStandBy():
 				c.Schedule.Recover()
-				retryDelay = 1
+				c.Breaker.Success(task.Name)
 				c.SetStatus(0, "running")
 				c.Logger.Info().Str("task", task.Name).Msg("recovered from standby mode, back to normal schedule")
 			default:
+				c.Breaker.Success(task.Name)
 				c.SetStatus(0, "running")
 			}
+			c.setCircuitState()
+			polled = polled || task.Name == "data"
+			if task.Name == "instance" {
+				c.sampleInstances()
+			}
 
 			if data != nil {
 
@@ -444,29 +546,7 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 
 				// run plugins after data poll
 				if task.Name == "data" {
-
-					pluginStart = time.Now()
-
-					for _, v := range c.Plugins {
-						for _, plg := range v {
-							pluginData, pluginMetadata, err := plg.Run(data)
-							if err != nil {
-								c.Logger.Error().Err(err).Str("plugin", plg.GetName()).Send()
-								continue
-							}
-							if pluginData != nil {
-								results = append(results, pluginData...)
-							}
-							if pluginMetadata != nil {
-								_ = c.Metadata.LazyAddValueUint64("bytesRx", task.Name, pluginMetadata.BytesRx)
-								_ = c.Metadata.LazyAddValueUint64("numCalls", task.Name, pluginMetadata.NumCalls)
-								_ = c.Metadata.LazySetValueUint64("pluginInstances", task.Name, pluginMetadata.PluginInstances)
-							}
-						}
-					}
-
-					pluginTime = time.Since(pluginStart)
-					_ = c.Metadata.LazySetValueInt64("plugin_time", task.Name, pluginTime.Microseconds())
+					results = c.processData(task, data, results)
 				}
 			}
 
@@ -484,35 +564,25 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 		// pass results to exporters
 
 		exportStart = time.Now()
-		exporterStats := exporter.Stats{}
-
-		for _, e := range c.Exporters {
-			if code, status, reason := e.GetStatus(); code != 0 {
-				c.Logger.Warn().
-					Str("exporter", e.GetName()).
-					Str("status", status).
-					Str("reason", reason).
-					Uint8("code", code).
-					Msg("skip export")
-				continue
+		labels, suppress := c.applyMaintenance()
+		// the standby of a pair collects, but does not export, so it can take over with warm caches
+		suppress = suppress || !c.Lease.IsHolder()
+		exported := c.prepareExport(results)
+		if len(results) > 0 {
+			c.recordShadow(results)
+			if c.IsShadow() {
+				exported = c.Shadow.rename(exported)
 			}
+		}
 
-			// Export metadata first
-			if _, err := e.Export(c.Metadata); err != nil {
-				c.Logger.Warn().Err(err).Str("exporter", e.GetName()).Msg("Unable to export metadata")
-			}
+		exporterStats, exportedSeries := c.export(exported, suppress, labels)
+		c.restoreSampling()
 
-			// Continue if metadata failed, since it might be specific to metadata
-			for _, data := range results {
-				if data.IsExportable() {
-					stats, err := e.Export(data)
-					if err != nil {
-						c.Logger.Error().Err(err).Str("exporter", e.GetName()).Msg("export data")
-						break
-					}
-					exporterStats.InstancesExported += stats.InstancesExported
-					exporterStats.MetricsExported += stats.MetricsExported
-				}
+		// Recycle the storage of exported matrices that are not used anymore.
+		// Exporters read a copy of them, see export
+		for _, data := range results {
+			if data.IsReleasable() {
+				data.Release()
 			}
 		}
 
@@ -520,10 +590,23 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 		if len(results) > 0 {
 			_ = c.Metadata.LazySetValueInt64("export_time", "data", time.Since(exportStart).Microseconds())
 			c.logMetadata("data", exporterStats)
+
+			// each exporter exports the same series, unless routed, so the guard counts the exporter with the most
+			var series uint64
+			for _, s := range exportedSeries {
+				series = max(series, s)
+			}
+			c.Guard.Record(c.Name+":"+c.Object, series)
+		}
+
+		if hooked && !skip {
+			c.runHook(hook.PostPoll, "", nil)
 		}
 
-		if nd := c.Schedule.NextDue(); nd > 0 {
-			c.Schedule.Sleep()
+		c.recordPoll(shed, polled)
+
+		if nd := c.Schedule.Next(); nd > 0 {
+			c.wait()
 			// log if lagging by more than 500 ms
 			// < is used since larger durations are more negative
 		} else if nd.Milliseconds() <= -500 && !c.Schedule.IsStandBy() {
@@ -534,6 +617,300 @@ func (c *AbstractCollector) Start(wg *sync.WaitGroup) {
 	}
 }
 
+// runPlugins runs the plugins of the collector on the data of a task, and returns the matrices they created
+func (c *AbstractCollector) runPlugins(taskName string, data map[string]*matrix.Matrix) []*matrix.Matrix {
+	var results []*matrix.Matrix
+	for _, v := range c.Plugins {
+		for _, plg := range v {
+			pluginData, pluginMetadata, err := plg.Run(data)
+			if err != nil {
+				c.Logger.Error().Err(err).Str("plugin", plg.GetName()).Send()
+				continue
+			}
+			if pluginData != nil {
+				results = append(results, pluginData...)
+			}
+			if pluginMetadata != nil {
+				_ = c.Metadata.LazyAddValueUint64("bytesRx", taskName, pluginMetadata.BytesRx)
+				if pluginMetadata.BytesRxWire > 0 {
+					_ = c.Metadata.LazyAddValueUint64("bytesRxWire", taskName, pluginMetadata.BytesRxWire)
+				}
+				_ = c.Metadata.LazyAddValueUint64("numCalls", taskName, pluginMetadata.NumCalls)
+				_ = c.Metadata.LazySetValueUint64("pluginInstances", taskName, pluginMetadata.PluginInstances)
+			}
+		}
+	}
+	return results
+}
+
+// initPipeline parses the sections of the template that the collector applies to the results of its polls.
+// Init reports invalid sections, so their errors are ignored
+func (c *AbstractCollector) initPipeline() {
+	c.units = parseUnits(c.Params)
+	c.derived, _ = parseDerivedLabels(c.Params)
+	c.sampling, _ = parseSampling(c.Params)
+	c.instanceTTL, _ = parseInstanceTTL(c.Params)
+	c.labelLimits, _ = parseLabelLimits(c.Params)
+}
+
+// processData derives labels, expires instances, adapts the schedule, runs the plugins, publishes labels, and samples
+// the instances of the data of a data task, and returns results with the matrices of the plugins appended
+func (c *AbstractCollector) processData(task *schedule.Task, data map[string]*matrix.Matrix, results []*matrix.Matrix) []*matrix.Matrix {
+	applyDerivedLabels(data, c.derived)
+	c.applyInstanceTTL(data)
+	c.adaptSchedule(task, data)
+	pluginStart := time.Now()
+	results = append(results, c.runPlugins(task.Name, data)...)
+	_ = c.Metadata.LazySetValueInt64("plugin_time", task.Name, time.Since(pluginStart).Microseconds())
+
+	c.publishLabels(results)
+	c.applySampling(data)
+	return results
+}
+
+// prepareExport sets the units of the template on results, and returns them with their labels limited, see
+// applyLabelLimits
+func (c *AbstractCollector) prepareExport(results []*matrix.Matrix) []*matrix.Matrix {
+	applyUnits(results, c.units)
+	return c.applyLabelLimits(results)
+}
+
+// Poll runs each task of the collector once, in the order of its schedule, and returns the collected matrices instead
+// of exporting them. Poll is used to embed a collector, see pkg/runner.
+// The results go through the same steps as the polls of Start, see processData and prepareExport, except the
+// steps that only apply to a scheduled collector that exports: the adaptive schedule, the circuit breaker, hooks,
+// maintenance windows, the lease, and shadow templates.
+// It must not be called while the collector is started. The matrices are valid until the next poll
+func (c *AbstractCollector) Poll() ([]*matrix.Matrix, error) {
+	var results []*matrix.Matrix
+	var errList []error
+	if c.units == nil {
+		c.initPipeline()
+	}
+	// the previous poll is not used anymore
+	c.restoreSampling()
+	for _, task := range c.Schedule.GetTasks() {
+		c.Metadata.ResetInstance(task.Name)
+		data, err := task.Run()
+		if err != nil {
+			errList = append(errList, fmt.Errorf("%s:%s task %s: %w", c.Name, c.Object, task.Name, err))
+			continue
+		}
+		if task.Name == "instance" {
+			c.sampleInstances()
+		}
+		for _, value := range data {
+			results = append(results, value)
+		}
+		if task.Name == "data" && data != nil {
+			results = c.processData(task, data, results)
+		}
+	}
+	return c.prepareExport(results), errors.Join(errList...)
+}
+
+// runHook runs the hook scripts of event for the collector's object, and returns true when the poll is skipped
+func (c *AbstractCollector) runHook(event hook.Event, task string, taskErr error) bool {
+	if !c.Hooks.Has(event, c.Object) {
+		return false
+	}
+	hc := hook.Context{
+		Event:      event,
+		Poller:     c.Options.Poller,
+		Datacenter: c.Params.GetChildContentS("datacenter"),
+		Addr:       c.Params.GetChildContentS("addr"),
+		Collector:  c.Name,
+		Object:     c.Object,
+		Task:       task,
+		Time:       time.Now(),
+	}
+	if taskErr != nil {
+		hc.Error = taskErr.Error()
+	}
+	skip, err := c.Hooks.Run(hc)
+	if err != nil {
+		c.Logger.Warn().Err(err).Str("event", string(event)).Bool("skip", skip).Msg("Hook failed")
+	}
+	return skip
+}
+
+// handleFailure records the failed poll of task with the circuit breaker.
+// When the circuit opens, the task enters standby until the cool-down ends
+func (c *AbstractCollector) handleFailure(task *schedule.Task, err error) {
+	if !c.Schedule.IsStandBy() {
+		c.Logger.Debug().Msgf("handling error during [%s] poll...", task.Name)
+	}
+	defer c.setCircuitState()
+	c.recordFailure(task.Name, err)
+
+	class := FailureClass(err)
+	coolDown, open := c.Breaker.Failure(task.Name, class)
+
+	if !open {
+		// degraded, the task is retried on its normal schedule
+		c.Logger.Error().Err(err).Str("task", task.Name).Str("failure", class).Send()
+		c.SetStatus(2, errMessage(err))
+		return
+	}
+
+	wasStandBy := c.Schedule.IsStandBy()
+	if c.Breaker.IsEarly(class) {
+		c.Schedule.SetStandByMode(task, coolDown)
+	} else {
+		c.Schedule.SetStandByModeMax(task, coolDown)
+	}
+
+	switch class {
+	// target system is unreachable, retry with a delay that increases if we fail again
+	case FailureConnection:
+		if !wasStandBy {
+			c.Logger.Warn().
+				Str("task", task.Name).
+				Int("retryDelaySecs", int(coolDown.Seconds())).
+				Msg("target unreachable, entering standby mode and retry")
+		}
+		c.Logger.Debug().
+			Err(err).
+			Str("task", task.Name).
+			Int("retryDelaySecs", int(coolDown.Seconds())).
+			Msg("target unreachable, entering standby mode and retry")
+		c.SetStatus(1, errs.ErrConnection.Error())
+	case FailureCMReject:
+		c.SetStatus(1, err.Error())
+		c.Logger.Warn().
+			Str("task", task.Name).
+			Int64("retryAfterSecs", int64(coolDown.Seconds())).
+			Msg("CM reject, entering standby mode and retry")
+	// there are no instances to collect
+	case FailureNoInstance:
+		c.SetStatus(1, errs.ErrNoInstance.Error())
+		c.Logger.Info().
+			Str("task", task.Name).
+			Msg("no instances, entering standby")
+	// no metrics available
+	case FailureNoMetric:
+		c.SetStatus(1, errs.ErrNoMetric.Error())
+		c.Logger.Info().
+			Str("task", task.Name).
+			Str("object", c.Object).
+			Msg("no metrics of object on system, entering standby mode")
+	case FailureAPIRejected:
+		if !errors.Is(err, errs.ErrMetroClusterNotConfigured) {
+			// Log as info since these are not errors.
+			c.Logger.Info().Err(err).Str("task", task.Name).Msg("Entering standby mode")
+		}
+		c.SetStatus(2, errMessage(err))
+	// not an error we are expecting, enter failed state until the cool-down ends
+	default:
+		c.Logger.Error().Err(err).
+			Str("task", task.Name).
+			Str("failure", class).
+			Str("coolDown", coolDown.String()).
+			Msg("Entering standby mode")
+		c.SetStatus(2, errMessage(err))
+	}
+}
+
+// recordFailure counts the failed polls of a task by the category of their error, see errs.Category,
+// on the failure instances of the task in the metadata of the collector.
+// The counters of all categories are created with the first failure, so alerts can use rate or increase
+func (c *AbstractCollector) recordFailure(task string, err error) {
+	category := errs.Category(err)
+	key := "failure:" + task + ":" + category
+	if c.Metadata.GetInstance(key) == nil {
+		for _, cat := range errs.Categories {
+			k := "failure:" + task + ":" + cat
+			if c.Metadata.GetInstance(k) != nil {
+				continue
+			}
+			instance, err := c.Metadata.NewInstance(k)
+			if err != nil {
+				return
+			}
+			instance.SetLabel("task", task)
+			instance.SetLabel("category", cat)
+			_ = c.Metadata.LazySetValueUint64("failures", k, 0)
+		}
+	}
+	_ = c.Metadata.LazyAddValueUint64("failures", key, 1)
+}
+
+// setCircuitState updates the circuit_state metadata of all tasks
+func (c *AbstractCollector) setCircuitState() {
+	state := c.Breaker.State()
+	for key := range c.Metadata.GetInstances() {
+		_ = c.Metadata.LazySetValueUint8("circuit_state", key, state)
+	}
+}
+
+func errMessage(err error) string {
+	var herr errs.HarvestError
+	if ok := errors.As(err, &herr); ok && herr.Inner != nil {
+		return herr.Inner.Error()
+	}
+	return err.Error()
+}
+
+// applyMaintenance returns the global labels that tag the exported data when the collector is in a maintenance window
+// with the tag action, and true when the export should be suppressed. The labels are added to the copy of the data
+// each export reads, since the global labels of the collector's matrices are shared
+func (c *AbstractCollector) applyMaintenance() (map[string]string, bool) {
+	action, active := c.Maintenance.Active(c.Object, time.Now())
+	if active != c.inWindow {
+		c.inWindow = active
+		if active {
+			c.Logger.Info().Str("action", string(action)).Msg("Entering maintenance window")
+		} else {
+			c.Logger.Info().Msg("Leaving maintenance window")
+		}
+	}
+	if active && action == maintenance.Tag {
+		return map[string]string{maintenance.Label: "true"}, false
+	}
+	return nil, active && action == maintenance.Suppress
+}
+
+// applyGuard returns true when the resource guard sheds the object of the collector, and logs when that changes.
+// A shed collector exports no series
+func (c *AbstractCollector) applyGuard() bool {
+	shed := c.Guard.Sheds(c.Object)
+	if shed != c.shed {
+		c.shed = shed
+		if shed {
+			c.Guard.Record(c.Name+":"+c.Object, 0)
+			c.Logger.Warn().Msg("Poller is above a hard resource limit, stop polling low-priority object")
+		} else {
+			c.Logger.Info().Msg("Poller is back within its resource limits, resume polling")
+		}
+	}
+	return shed
+}
+
+// parseUnits returns the units of the "units" section of the template, by metric name.
+// They replace the units ONTAP reports, and give units to metrics ONTAP has no unit for
+func parseUnits(params *node.Node) map[string]string {
+	overrides := make(map[string]string)
+	if units := params.GetChildS("units"); units != nil {
+		for _, u := range units.GetChildren() {
+			overrides[u.GetNameS()] = u.GetContentS()
+		}
+	}
+	return overrides
+}
+
+func applyUnits(results []*matrix.Matrix, overrides map[string]string) {
+	if len(overrides) == 0 {
+		return
+	}
+	for _, data := range results {
+		for _, metric := range data.GetMetrics() {
+			if unit, ok := overrides[metric.GetName()]; ok {
+				metric.SetUnit(unit)
+			}
+		}
+	}
+}
+
 func (c *AbstractCollector) logMetadata(taskName string, stats exporter.Stats) {
 	metrics := c.Metadata.GetMetrics()
 	info := c.Logger.Info() //nolint:zerologlint
@@ -551,8 +928,8 @@ func (c *AbstractCollector) logMetadata(taskName string, stats exporter.Stats) {
 	if taskName == "data" {
 		for _, metric := range metrics {
 			mName := metric.GetName()
-			if mName == "task_time" {
-				// don't log since it is covered by other durations
+			if mName == "task_time" || mName == "circuit_state" || strings.HasPrefix(mName, "exporter_") {
+				// don't log since it is covered by other durations, or is not a metric of the task
 				continue
 			}
 			value, _ := metric.GetValueFloat64(inst)
@@ -588,6 +965,9 @@ func (c *AbstractCollector) logMetadata(taskName string, stats exporter.Stats) {
 
 	bytesRx, _ := c.Metadata.GetMetric("bytesRx").GetValueUint64(inst)
 	info.Uint64("bytesRx", bytesRx)
+	if bytesRxWire, ok := c.Metadata.GetMetric("bytesRxWire").GetValueUint64(inst); ok {
+		info.Uint64("bytesRxWire", bytesRxWire)
+	}
 
 	numCalls, _ := c.Metadata.GetMetric("numCalls").GetValueUint64(inst)
 	info.Uint64("numCalls", numCalls)
@@ -653,11 +1033,30 @@ func (c *AbstractCollector) GetOptions() *options.Options {
 	return c.Options
 }
 
-// SetSchedule set Schedule s as a field of the collector
-func (c *AbstractCollector) SetSchedule(s *schedule.Schedule) {
+// SetSchedule set Scheduler s as a field of the collector
+func (c *AbstractCollector) SetSchedule(s schedule.Scheduler) {
 	c.Schedule = s
 }
 
+// GetSchedule returns the Scheduler of the collector
+func (c *AbstractCollector) GetSchedule() schedule.Scheduler {
+	return c.Schedule
+}
+
+// Context returns the context of the running task of the collector, or the background context when no task is
+// running. Collectors pass it to their requests so that requests end with the slot of the task
+func (c *AbstractCollector) Context() context.Context {
+	if c.Schedule == nil {
+		return context.Background()
+	}
+	return c.Schedule.Context()
+}
+
+// SetBreaker set Breaker b as a field of the collector
+func (c *AbstractCollector) SetBreaker(b *Breaker) {
+	c.Breaker = b
+}
+
 // SetMatrix set Matrix m as a field of the collector
 func (c *AbstractCollector) SetMatrix(m map[string]*matrix.Matrix) {
 	c.Matrix = m
@@ -670,6 +1069,9 @@ func (c *AbstractCollector) SetMetadata(m *matrix.Matrix) {
 
 // WantedExporters returns the list of exporters the receiver will export data to
 func (c *AbstractCollector) WantedExporters(exporters []string) []string {
+	if c.IsShadow() && c.Shadow.Exporter != "" {
+		return []string{c.Shadow.Exporter}
+	}
 	return conf.GetUniqueExporters(exporters)
 }
 
@@ -701,10 +1103,15 @@ func (c *AbstractCollector) LoadPlugins(params *node.Node, collector Collector,
 		}
 
 		abc = plugin.New(c.Name, c.Options, x, c.Params, c.Object, c.Auth)
+		abc.Bus = c.Bus
 
 		// case 1: available as built-in plugin
 		if p = GetBuiltinPlugin(name, abc); p != nil {
 			c.Logger.Debug().Msgf("loaded built-in plugin [%s]", name)
+		} else if c.Options.Fixtures != "" {
+			// collector plugins call the cluster, which is not available when polling recorded responses
+			c.Logger.Debug().Msgf("skip plugin [%s] with recorded responses", name)
+			continue
 			// case 2: available as dynamic plugin
 		} else {
 			p = collector.LoadPlugin(name, abc)
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"time"
+)
+
+// Estimate is the cost of one poll of a task of a collector, measured from the cluster without polling,
+// e.g. by counting the instances of an object and sampling one of its records
+type Estimate struct {
+	Task      string // name of the task, e.g. data
+	Calls     int    // API calls of a poll, without the calls of plugins
+	Instances int    // instances of the object
+	Series    int    // series exported by a poll, without the series of plugins, zero for tasks that export nothing
+	Bytes     int64  // bytes of the records of a poll, before compression
+}
+
+// Estimator is implemented by the collectors that can estimate the cost of their tasks without polling.
+// Estimate may call the cluster, like the counter task does, but not poll data
+type Estimator interface {
+	Estimate() ([]Estimate, error)
+}
+
+// PerHour returns the calls and bytes of a task that polls every interval, during an hour
+func (e Estimate) PerHour(interval time.Duration) (float64, float64) {
+	if interval <= 0 {
+		return 0, 0
+	}
+	polls := float64(time.Hour) / float64(interval)
+	return float64(e.Calls) * polls, float64(e.Bytes) * polls
+}
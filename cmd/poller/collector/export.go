@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"sync/atomic"
+	"time"
+)
+
+// exportResult is the outcome of the export of a poll to an exporter
+type exportResult struct {
+	stats    exporter.Stats
+	duration time.Duration
+	err      error
+}
+
+// export passes the results of a poll, with the global labels of labels added, to the exporters and returns
+// the export stats and the series exported by each exporter.
+//
+// Each exporter exports in its own goroutine, so a slow or blocked exporter, e.g. an InfluxDB outage,
+// does not delay the others. The collector waits for an exporter until its export_timeout,
+// and skips the exporter until its export returns. An exporter that timed out may still read its data while the
+// next poll updates the cache of the collector, so exporters read a deep copy of the results
+func (c *AbstractCollector) export(results []*matrix.Matrix, suppress bool, labels map[string]string) (exporter.Stats, map[string]uint64) {
+	var stats exporter.Stats
+	series := make(map[string]uint64)
+	releasable := true
+
+	type export struct {
+		e    exporter.Exporter
+		done chan exportResult
+	}
+	var exports []export
+
+	// exporters read a copy of the metadata, since the collector updates it while an exporter that timed out
+	// may still read it
+	metadata := c.Metadata.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	var snapshot []*matrix.Matrix
+	start := time.Now()
+
+	for _, e := range c.Exporters {
+		if code, status, reason := e.GetStatus(); code != 0 {
+			c.Logger.Warn().
+				Str("exporter", e.GetName()).
+				Str("status", status).
+				Str("reason", reason).
+				Uint8("code", code).
+				Msg("skip export")
+			continue
+		}
+		busy := c.isExporting(e.GetName())
+		if !busy.CompareAndSwap(false, true) {
+			c.Logger.Warn().Str("exporter", e.GetName()).Msg("skip export, previous export is still running")
+			c.recordExport(e.GetName(), 0, true)
+			continue
+		}
+		if snapshot == nil && !suppress {
+			snapshot = snapshotResults(results, labels)
+		}
+		done := make(chan exportResult, 1)
+		go func() {
+			defer busy.Store(false)
+			defer func() {
+				if r := recover(); r != nil {
+					done <- exportResult{err: errs.New(errs.ErrPanic, fmt.Sprint(r))}
+				}
+			}()
+			done <- c.exportTo(e, metadata, snapshot, suppress)
+		}()
+		exports = append(exports, export{e: e, done: done})
+	}
+
+	for _, x := range exports {
+		timeout := time.NewTimer(time.Until(start.Add(x.e.GetExportTimeout())))
+		select {
+		case r := <-x.done:
+			timeout.Stop()
+			if r.err != nil {
+				c.Logger.Error().Err(r.err).Str("exporter", x.e.GetName()).Msg("export data")
+			}
+			c.recordExport(x.e.GetName(), r.duration, r.err != nil)
+			stats.InstancesExported += r.stats.InstancesExported
+			stats.MetricsExported += r.stats.MetricsExported
+			series[x.e.GetName()] = r.stats.MetricsExported
+		case <-timeout.C:
+			c.Logger.Error().
+				Str("exporter", x.e.GetName()).
+				Str("timeout", x.e.GetExportTimeout().String()).
+				Msg("export timed out, skip the exporter until it returns")
+			c.recordExport(x.e.GetName(), time.Since(start), true)
+			releasable = false
+		}
+	}
+
+	// the snapshot is recycled when no exporter reads it anymore, otherwise it is left to the garbage collector
+	if releasable {
+		for _, data := range snapshot {
+			data.Release()
+		}
+	}
+	return stats, series
+}
+
+// snapshotResults returns a deep copy of the exportable results of a poll, with their own data, instances, labels,
+// and global labels, to which labels are added
+func snapshotResults(results []*matrix.Matrix, labels map[string]string) []*matrix.Matrix {
+	snapshot := make([]*matrix.Matrix, 0, len(results))
+	for _, data := range results {
+		if !data.IsExportable() {
+			continue
+		}
+		clone := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+		clone.UnshareGlobalLabels()
+		for label, value := range labels {
+			clone.SetGlobalLabel(label, value)
+		}
+		snapshot = append(snapshot, clone)
+	}
+	return snapshot
+}
+
+// exportTo exports the metadata of the collector and the results of a poll to an exporter
+func (c *AbstractCollector) exportTo(e exporter.Exporter, metadata *matrix.Matrix, results []*matrix.Matrix, suppress bool) exportResult {
+	var r exportResult
+	start := time.Now()
+
+	// Export metadata first
+	if _, err := e.Export(metadata); err != nil {
+		c.Logger.Warn().Err(err).Str("exporter", e.GetName()).Msg("Unable to export metadata")
+	}
+
+	// Continue if metadata failed, since it might be specific to metadata
+	for _, data := range results {
+		if !data.IsExportable() || suppress {
+			continue
+		}
+		// Skip data that is not routed to this exporter
+		if data = e.Route(data); data == nil {
+			continue
+		}
+		data = e.Normalize(data)
+		data = e.Convert(data)
+		data = e.Lint(data)
+		for _, m := range e.Provenance(data, c.Name) {
+			stats, err := e.Export(m)
+			if err != nil {
+				r.err = err
+				break
+			}
+			r.stats.InstancesExported += stats.InstancesExported
+			r.stats.MetricsExported += stats.MetricsExported
+		}
+		if r.err != nil {
+			break
+		}
+	}
+	r.duration = time.Since(start)
+	return r
+}
+
+// isExporting returns the flag that is true while an exporter exports the data of the collector
+func (c *AbstractCollector) isExporting(name string) *atomic.Bool {
+	if c.exporting == nil {
+		c.exporting = make(map[string]*atomic.Bool)
+	}
+	busy, ok := c.exporting[name]
+	if !ok {
+		busy = &atomic.Bool{}
+		c.exporting[name] = busy
+	}
+	return busy
+}
+
+// recordExport sets the export duration and counts the failures of an exporter, on the export instance of
+// the exporter in the metadata of the collector
+func (c *AbstractCollector) recordExport(name string, d time.Duration, failed bool) {
+	key := "export:" + name
+	if c.Metadata.GetInstance(key) == nil {
+		instance, err := c.Metadata.NewInstance(key)
+		if err != nil {
+			return
+		}
+		instance.SetLabel("task", "export")
+		instance.SetLabel("exporter", name)
+		_ = c.Metadata.LazySetValueUint64("exporter_failures", key, 0)
+	}
+	if d > 0 {
+		_ = c.Metadata.LazySetValueInt64("exporter_time", key, d.Microseconds())
+	}
+	if failed {
+		_ = c.Metadata.LazyAddValueUint64("exporter_failures", key, 1)
+	}
+}
@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// blockingExporter exports until it is released
+type blockingExporter struct {
+	*exporter.AbstractExporter
+	release chan struct{}
+}
+
+func (b *blockingExporter) Init() error {
+	return b.InitAbc()
+}
+
+func (b *blockingExporter) Export(data *matrix.Matrix) (exporter.Stats, error) {
+	if b.release != nil {
+		<-b.release
+	}
+	return exporter.Stats{InstancesExported: uint64(len(data.GetInstances()))}, nil
+}
+
+func newTestExporter(t *testing.T, name string, timeout string, release chan struct{}) *blockingExporter {
+	t.Helper()
+	e := &blockingExporter{
+		AbstractExporter: exporter.New("Test", name, options.New(), conf.Exporter{ExportTimeout: timeout}, nil),
+		release:          release,
+	}
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExportTimeout(t *testing.T) {
+	c := New("Rest", "Volume", options.New(), nil, nil)
+	c.Metadata = matrix.New("Rest", "metadata_collector", "metadata_collector_Volume")
+	_, _ = c.Metadata.NewMetricInt64("exporter_time")
+	_, _ = c.Metadata.NewMetricUint64("exporter_failures")
+
+	release := make(chan struct{})
+	fast := newTestExporter(t, "fast", "", nil)
+	slow := newTestExporter(t, "slow", "10ms", release)
+	c.Exporters = []exporter.Exporter{slow, fast}
+
+	data := matrix.New("Rest", "volume", "volume")
+	_, _ = data.NewInstance("vol1")
+	results := []*matrix.Matrix{data}
+
+	failures := func(name string) uint64 {
+		v, _ := c.Metadata.GetMetric("exporter_failures").GetValueUint64(c.Metadata.GetInstance("export:" + name))
+		return v
+	}
+
+	stats, series := c.export(results, false, nil)
+	if stats.InstancesExported != 1 {
+		t.Errorf("export() instances got=%d, want=1 from the fast exporter", stats.InstancesExported)
+	}
+	if _, ok := series["slow"]; ok {
+		t.Error("export() want no series of the slow exporter")
+	}
+	if failures("slow") != 1 || failures("fast") != 0 {
+		t.Errorf("failures got slow=%d fast=%d, want slow=1 fast=0", failures("slow"), failures("fast"))
+	}
+
+	// the slow exporter is skipped while its export is still running
+	_, _ = c.export(results, false, nil)
+	if failures("slow") != 2 {
+		t.Errorf("failures got slow=%d, want=2", failures("slow"))
+	}
+
+	close(release)
+	// wait for the export of the slow exporter to return
+	for c.isExporting("slow").Load() {
+		time.Sleep(time.Millisecond)
+	}
+	_, _ = c.export(results, false, nil)
+	if failures("slow") != 2 {
+		t.Errorf("failures got slow=%d, want=2", failures("slow"))
+	}
+}
+
+// readingExporter reads the labels and values of the data it exports once it is released
+type readingExporter struct {
+	*blockingExporter
+	seen chan string
+}
+
+func (r *readingExporter) Export(data *matrix.Matrix) (exporter.Stats, error) {
+	if data.Object == "metadata_collector" {
+		return exporter.Stats{}, nil
+	}
+	<-r.release
+	instance := data.GetInstance("vol1")
+	v, _ := data.GetMetric("size").GetValueFloat64(instance)
+	r.seen <- data.GetGlobalLabels()["datacenter"] + " " + instance.GetLabel("volume") + " " + strconv.FormatFloat(v, 'f', -1, 64)
+	return exporter.Stats{}, nil
+}
+
+// TestExportTimeoutNextPoll polls again while an exporter that timed out still reads the previous poll.
+// Run with -race
+func TestExportTimeoutNextPoll(t *testing.T) {
+	c := New("Rest", "Volume", options.New(), nil, nil)
+	c.Metadata = matrix.New("Rest", "metadata_collector", "metadata_collector_Volume")
+	_, _ = c.Metadata.NewMetricInt64("exporter_time")
+	_, _ = c.Metadata.NewMetricUint64("exporter_failures")
+
+	slow := &readingExporter{blockingExporter: newTestExporter(t, "slow", "10ms", make(chan struct{})), seen: make(chan string, 1)}
+	c.Exporters = []exporter.Exporter{slow}
+
+	data := matrix.New("Rest", "volume", "volume")
+	data.SetGlobalLabel("datacenter", "dc1")
+	instance, _ := data.NewInstance("vol1")
+	instance.SetLabel("volume", "vol1")
+	size, _ := data.NewMetricFloat64("size")
+	size.SetValueFloat64(instance, 1)
+
+	_, _ = c.export([]*matrix.Matrix{data}, false, nil)
+
+	// the next poll updates the cache of the collector while the slow exporter reads the previous poll
+	data.Reset()
+	data.SetGlobalLabel("datacenter", "dc2")
+	instance.SetLabel("volume", "vol2")
+	size.SetValueFloat64(instance, 2)
+	_, _ = c.export([]*matrix.Matrix{data}, false, nil)
+
+	close(slow.release)
+	if got := <-slow.seen; got != "dc1 vol1 1" {
+		t.Errorf("exporter got=%s, want=dc1 vol1 1 of the poll it exports", got)
+	}
+}
+
+// labelsExporter records the global labels of the data it exports
+type labelsExporter struct {
+	*blockingExporter
+	labels map[string]string
+}
+
+func (l *labelsExporter) Export(data *matrix.Matrix) (exporter.Stats, error) {
+	if data.Object != "metadata_collector" {
+		l.labels = data.GetGlobalLabels()
+	}
+	return exporter.Stats{}, nil
+}
+
+func TestExportMaintenanceLabels(t *testing.T) {
+	c := New("Rest", "Volume", options.New(), nil, nil)
+	c.Metadata = matrix.New("Rest", "metadata_collector", "metadata_collector_Volume")
+	_, _ = c.Metadata.NewMetricInt64("exporter_time")
+	_, _ = c.Metadata.NewMetricUint64("exporter_failures")
+	e := &labelsExporter{blockingExporter: newTestExporter(t, "labels", "", nil)}
+	c.Exporters = []exporter.Exporter{e}
+
+	shared := map[string]string{"datacenter": "dc1"}
+	data := matrix.New("Rest", "volume", "volume")
+	data.SetGlobalLabels(shared)
+	_, _ = data.NewInstance("vol1")
+
+	_, _ = c.export([]*matrix.Matrix{data}, false, map[string]string{"maintenance": "true"})
+	if e.labels["maintenance"] != "true" || e.labels["datacenter"] != "dc1" {
+		t.Errorf("exported global labels got=%v, want datacenter and maintenance", e.labels)
+	}
+	if _, ok := data.GetGlobalLabels()["maintenance"]; ok {
+		t.Error("export() want the global labels of the collector unchanged")
+	}
+
+	_, _ = c.export([]*matrix.Matrix{data}, false, nil)
+	if _, ok := e.labels["maintenance"]; ok {
+		t.Errorf("exported global labels got=%v, want no maintenance label outside the window", e.labels)
+	}
+}
+
+func TestExportTimeoutParam(t *testing.T) {
+	e := exporter.New("Test", "bad", options.New(), conf.Exporter{ExportTimeout: "soon"}, nil)
+	if err := e.InitAbc(); err == nil {
+		t.Error("InitAbc() want error for an invalid export_timeout")
+	}
+	if got := newTestExporter(t, "default", "", nil).GetExportTimeout(); got != exporter.DefaultExportTimeout {
+		t.Errorf("GetExportTimeout() got=%s, want=%s", got, exporter.DefaultExportTimeout)
+	}
+}
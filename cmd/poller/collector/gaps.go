@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// exportGaps detects the data intervals in which a collector exported nothing, e.g. because its schedule stalled,
+// and asks the collector to resync, i.e. to poll its data out of band, once resyncAfter intervals were missed in a row.
+// The collector records its polls from its goroutine, and the poller checks the gaps from its own goroutine,
+// so that a stalled collector is noticed
+type exportGaps struct {
+	mu          sync.Mutex
+	interval    time.Duration // interval of the data task, zero while the collector is not expected to export
+	last        time.Time     // time of the last export, or when the collector was first expected to export
+	counted     int           // missed intervals of the current gap that are counted in gaps
+	gaps        uint64        // missed intervals since the collector started
+	resyncAfter int           // missed intervals before a resync, zero to never resync
+	resynced    bool          // true when the current gap already asked for a resync
+	resync      chan struct{}
+}
+
+// parseResyncAfter returns the "resync_after" of the template, or zero when the template does not resync.
+// resync_after is the number of data intervals a collector can miss in a row before it polls out of band
+func parseResyncAfter(params *node.Node) (int, error) {
+	value := params.GetChildContentS("resync_after")
+	if value == "" {
+		return 0, nil
+	}
+	intervals, err := strconv.Atoi(value)
+	if err != nil || intervals < 1 {
+		return 0, fmt.Errorf("resync_after: must be a positive number of intervals [%s]", value)
+	}
+	return intervals, nil
+}
+
+func newExportGaps() *exportGaps {
+	return &exportGaps{resync: make(chan struct{}, 1)}
+}
+
+// setResyncAfter sets the missed intervals before a resync, zero to never resync
+func (g *exportGaps) setResyncAfter(intervals int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.resyncAfter = intervals
+}
+
+// polled records a loop of the collector at now. exported is true when the data task of the loop succeeded,
+// so its data, if any, was exported.
+// interval is the interval of the data task, and due the time until the task is due again.
+// The first interval is counted from the first time the task is due, so the warm up of the poller is not a gap
+func (g *exportGaps) polled(now time.Time, interval time.Duration, due time.Duration, exported bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case exported:
+		g.last = now
+	case g.interval == 0:
+		g.last = now.Add(due - interval)
+	default:
+		g.interval = interval
+		return
+	}
+	g.interval = interval
+	g.counted = 0
+	g.resynced = false
+}
+
+// pause stops detecting gaps while the collector is not expected to export, e.g. while its schedule is in standby
+func (g *exportGaps) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.interval = 0
+}
+
+// check counts the intervals missed since the last export at now, and asks for a resync when the collector missed
+// resyncAfter intervals. It returns the missed intervals since the collector started, and the ones added by this check.
+// An export can be up to an interval late, e.g. when a poll is slow, so an interval is missed once the next one ended
+func (g *exportGaps) check(now time.Time) (uint64, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.interval <= 0 {
+		return g.gaps, 0
+	}
+	missed := max(int(now.Sub(g.last)/g.interval)-1, 0)
+	added := 0
+	if missed > g.counted {
+		added = missed - g.counted
+		g.gaps += uint64(added)
+		g.counted = missed
+	}
+	if g.resyncAfter > 0 && missed >= g.resyncAfter && !g.resynced {
+		g.resynced = true
+		select {
+		case g.resync <- struct{}{}:
+		default:
+		}
+	}
+	return g.gaps, added
+}
+
+// CheckGaps returns the number of data intervals that the collector missed since it started.
+// It is safe to call from another goroutine than the one of the collector, see exportGaps
+func (c *AbstractCollector) CheckGaps(now time.Time) uint64 {
+	if c.gaps == nil {
+		return 0
+	}
+	gaps, added := c.gaps.check(now)
+	if added > 0 {
+		c.Logger.Warn().Int("missed", added).Uint64("gaps", gaps).Msg("collector missed data intervals")
+	}
+	return gaps
+}
+
+// recordPoll records a loop of the collector for the gap detector. polled is true when the data task succeeded.
+// Gaps are only detected while the collector is running: a collector that is shed, in standby, or whose data task fails
+// already reports why it does not export
+func (c *AbstractCollector) recordPoll(shed bool, polled bool) {
+	task := c.Schedule.GetTask("data")
+	if task == nil || shed || c.Schedule.IsStandBy() || c.Status != 0 {
+		c.gaps.pause()
+		return
+	}
+	c.gaps.polled(time.Now(), task.GetInterval(), task.NextDue(), polled)
+}
+
+// wait sleeps until a task of the collector is due, or until the gap detector asks for a resync,
+// which makes the data task due now. Reloads of the counters run while the collector waits, see RequestReload
+func (c *AbstractCollector) wait() {
+	for {
+		select {
+		case <-c.Schedule.Wait():
+			return
+		case <-c.gaps.resync:
+			if task := c.Schedule.GetTask("data"); task != nil {
+				c.Logger.Warn().Msg("resync, poll data out of band")
+				task.Expire()
+			}
+			return
+		case reload := <-c.reload:
+			c.runReload(reload)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+	"time"
+)
+
+func TestParseResyncAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    int
+		wantErr bool
+	}{
+		{name: "no resync", yaml: "object: volume\n", want: 0},
+		{name: "intervals", yaml: "resync_after: 3\n", want: 3},
+		{name: "zero", yaml: "resync_after: 0\n", wantErr: true},
+		{name: "duration", yaml: "resync_after: 10m\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			got, err := parseResyncAfter(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResyncAfter() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseResyncAfter() got=%d, want=%d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportGaps(t *testing.T) {
+	g := newExportGaps()
+	g.setResyncAfter(3)
+	start := time.Now()
+	interval := time.Minute
+
+	// not expected to export yet
+	if gaps, _ := g.check(start.Add(time.Hour)); gaps != 0 {
+		t.Fatalf("check() before the first poll got=%d, want=0", gaps)
+	}
+
+	// the data task is first due in 5 minutes, e.g. during the warm up of the poller
+	g.polled(start, interval, 5*time.Minute, false)
+	if gaps, _ := g.check(start.Add(5 * time.Minute)); gaps != 0 {
+		t.Errorf("check() during the warm up got=%d, want=0", gaps)
+	}
+
+	// an export that is late by less than an interval is not a gap
+	g.polled(start.Add(5*time.Minute), interval, interval, true)
+	if gaps, _ := g.check(start.Add(6*time.Minute + 50*time.Second)); gaps != 0 {
+		t.Errorf("check() of a late export got=%d, want=0", gaps)
+	}
+
+	// the collector stalls, each interval is counted once
+	if gaps, added := g.check(start.Add(7*time.Minute + 10*time.Second)); gaps != 1 || added != 1 {
+		t.Errorf("check() got=%d added=%d, want=1 added=1", gaps, added)
+	}
+	if gaps, added := g.check(start.Add(7*time.Minute + 20*time.Second)); gaps != 1 || added != 0 {
+		t.Errorf("check() twice got=%d added=%d, want=1 added=0", gaps, added)
+	}
+	select {
+	case <-g.resync:
+		t.Fatal("resync before resync_after intervals were missed")
+	default:
+	}
+
+	// three missed intervals ask for one resync
+	if gaps, _ := g.check(start.Add(9*time.Minute + 10*time.Second)); gaps != 3 {
+		t.Errorf("check() got=%d, want=3", gaps)
+	}
+	select {
+	case <-g.resync:
+	default:
+		t.Fatal("no resync after resync_after intervals were missed")
+	}
+	g.check(start.Add(10*time.Minute + 10*time.Second))
+	select {
+	case <-g.resync:
+		t.Fatal("more than one resync for the same gap")
+	default:
+	}
+
+	// the resync exports, the gaps since the start are kept
+	g.polled(start.Add(10*time.Minute+20*time.Second), interval, interval, true)
+	if gaps, added := g.check(start.Add(11 * time.Minute)); gaps != 4 || added != 0 {
+		t.Errorf("check() after the resync got=%d added=%d, want=4 added=0", gaps, added)
+	}
+
+	// no gaps are counted while paused, e.g. in standby
+	g.pause()
+	if gaps, _ := g.check(start.Add(time.Hour)); gaps != 4 {
+		t.Errorf("check() while paused got=%d, want=4", gaps)
+	}
+}
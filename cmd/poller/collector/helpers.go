@@ -13,14 +13,20 @@ import (
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/aggregator"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/changelog"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/clonedependency"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/efficiency"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/join"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/labelagent"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/max"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/metricagent"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/qosgovernor"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/rebucket"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/tree"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"github.com/netapp/harvest/v2/third_party/go-version"
+	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -31,6 +37,15 @@ import (
 // ImportTemplate looks for a collector's template by searching confPaths for the first template that exists in
 // confPath/collectorName/templateName
 func ImportTemplate(confPaths []string, templateName, collectorName string) (*node.Node, error) {
+	fp, err := FindTemplate(confPaths, templateName, collectorName)
+	if err != nil {
+		return nil, err
+	}
+	return tree.ImportYaml(fp)
+}
+
+// FindTemplate returns the path of the template of a collector, from the first conf path that has it
+func FindTemplate(confPaths []string, templateName, collectorName string) (string, error) {
 	homePath := conf.Path("")
 	for _, confPath := range confPaths {
 		fp := filepath.Join(homePath, confPath, strings.ToLower(collectorName), templateName)
@@ -38,9 +53,9 @@ func ImportTemplate(confPaths []string, templateName, collectorName string) (*no
 		if errors.Is(err, os.ErrNotExist) {
 			continue
 		}
-		return tree.ImportYaml(fp)
+		return fp, nil
 	}
-	return nil, errors.New("template not found on confPath")
+	return "", errors.New("template not found on confPath")
 }
 
 var versionRegex = regexp.MustCompile(`\d+\.\d+\.\d+`)
@@ -99,6 +114,7 @@ nextFile:
 				finalTemplate, err = tree.ImportYaml(templatePath)
 				if err == nil {
 					finalTemplate.PreprocessTemplate()
+					c.recordSource(templatePath, finalTemplate)
 					continue nextFile
 				}
 				importErrs = append(importErrs, fmt.Errorf("failed to import template: %s file: %w", templatePath, err))
@@ -111,6 +127,7 @@ nextFile:
 					continue
 				}
 				customTemplate.PreprocessTemplate()
+				c.recordSource(templatePath, customTemplate)
 				finalTemplate.Merge(customTemplate, nil)
 				continue nextFile
 			}
@@ -126,6 +143,8 @@ nextFile:
 		}
 	}
 
+	c.applyOverlays(finalTemplate, filenames)
+
 	return finalTemplate, templatePath, err
 }
 
@@ -228,5 +247,84 @@ func GetBuiltinPlugin(name string, abc *plugin.AbstractPlugin) plugin.Plugin {
 		return changelog.New(abc)
 	}
 
+	if name == "Join" {
+		return join.New(abc)
+	}
+
+	if name == "Rebucket" {
+		return rebucket.New(abc)
+	}
+
+	if name == "Efficiency" {
+		return efficiency.New(abc)
+	}
+
+	if name == "CloneDependency" {
+		return clonedependency.New(abc)
+	}
+
+	if name == "QosGovernor" {
+		return qosgovernor.New(abc)
+	}
+
 	return nil
 }
+
+// Union2 merges the fields of a Poller with the fields of a node.
+// This is a way to bridge the struct world with the string typed world.
+// If one of the poller field's does not exist in hNode, it will be copied
+// from poller to hNode.
+// If the field already exists in hNode, nothing is copied.
+// Instead of comparing each field of the poller individually and being forced
+// to keep this method in sync with the Poller struct, reflection via yaml marshaling
+// is used to do the comparison. First the poller is marshaled to yaml and then
+// unmarshalled into a list of generic yaml node. Each generic yaml node is walked, checking
+// if there is a corresponding node in hNode, when there isn't one, a new hNode is created
+// and populated with the yaml node's content. Finally, the new hNode is added to its parent
+func Union2(hNode *node.Node, poller *conf.Poller) {
+	marshal, err := yaml.Marshal(poller)
+	if err != nil {
+		return
+	}
+	root := yaml.Node{}
+	err = yaml.Unmarshal(marshal, &root)
+	if err != nil {
+		return
+	}
+	rootContent := root.Content[0]
+	if rootContent.Kind == yaml.MappingNode {
+		for index, yNode := range rootContent.Content {
+			// since rootContent is a mapping node every other yNode is a key
+			if index%2 == 0 && yNode.Tag == "!!str" {
+				// If the harvest node is missing this key, add it the harvest node
+				if !hNode.HasChildS(yNode.Value) {
+					// create a new harvest node to contain the missing content
+					newNode := node.NewS(yNode.Value)
+					// this is the value that goes along with the key from yNode
+					valNode := rootContent.Content[index+1]
+					switch valNode.Tag {
+					case "!!str", "!!bool":
+						newNode.Content = []byte(valNode.Value)
+					case "!!seq":
+						// the poller node that is missing is a sequence so add all the children of the sequence
+						for _, seqNode := range valNode.Content {
+							if seqNode.Tag == "!!str" {
+								newNode.NewChildS(seqNode.Value, seqNode.Value)
+							} else if seqNode.Tag == "!!map" {
+								for ci := 0; ci < len(seqNode.Content); ci += 2 {
+									newNode.NewChildS(seqNode.Content[ci].Value, seqNode.Content[ci+1].Value)
+								}
+							}
+						}
+					case "!!map":
+						// the poller node that is missing is a map, add all the children of the map
+						for ci := 0; ci < len(valNode.Content); ci += 2 {
+							newNode.NewChildS(valNode.Content[ci].Value, valNode.Content[ci+1].Value)
+						}
+					}
+					hNode.AddChild(newNode)
+				}
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"github.com/netapp/harvest/v2/third_party/go-version"
 	"sort"
 	"testing"
@@ -61,3 +62,36 @@ func Test_HARVEST_CONF(t *testing.T) {
 		t.Errorf("collectorName got=%s, want=Test", name)
 	}
 }
+
+func TestUnion2(t *testing.T) {
+	configPath := "../../tools/doctor/testdata/testConfig.yml"
+	n := node.NewS("foople")
+	conf.TestLoadHarvestConfig(configPath)
+	p, err := conf.PollerNamed("infinity2")
+	if err != nil {
+		panic(err)
+	}
+	Union2(n, p)
+	labels := n.GetChildS("labels")
+	if labels == nil {
+		t.Fatal("got nil, want labels")
+	}
+	type label struct {
+		key string
+		val string
+	}
+	wants := []label{
+		{key: "org", val: "abc"},
+		{key: "site", val: "RTP"},
+		{key: "floor", val: "3"},
+	}
+	for i, c := range labels.Children {
+		want := wants[i]
+		if want.key != c.GetNameS() {
+			t.Errorf("got key=%s, want=%s", c.GetNameS(), want.key)
+		}
+		if want.val != c.GetContentS() {
+			t.Errorf("got key=%s, want=%s", c.GetContentS(), want.val)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"regexp"
+	"strings"
+)
+
+// derivedLabel creates labels from the capture groups of a regex that matches a label of an instance
+type derivedLabel struct {
+	source  string
+	reg     *regexp.Regexp
+	targets []string // label of each capture group, empty to skip a group
+}
+
+// parseDerivedLabels returns the rules of the "derived_labels" section of the template, in order.
+// A rule has the syntax LABEL `REGEX` LABEL1,LABEL2. The targets are optional when the capture groups
+// of the regex are named, e.g. volume `^(?P<app>[a-z]+)_`
+func parseDerivedLabels(params *node.Node) ([]derivedLabel, error) {
+	section := params.GetChildS("derived_labels")
+	if section == nil {
+		return nil, nil
+	}
+	rules := make([]derivedLabel, 0, len(section.GetChildren()))
+	for _, c := range section.GetChildren() {
+		rule, err := parseDerivedLabel(strings.TrimSpace(c.GetContentS()))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseDerivedLabel(rule string) (derivedLabel, error) {
+	source, rest, ok := strings.Cut(rule, " `")
+	if !ok {
+		return derivedLabel{}, fmt.Errorf("derived_labels: rule has invalid format [%s]", rule)
+	}
+	expr, targets, ok := strings.Cut(rest, "`")
+	if !ok {
+		return derivedLabel{}, fmt.Errorf("derived_labels: rule has invalid format [%s]", rule)
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return derivedLabel{}, fmt.Errorf("derived_labels: invalid regex [%s]: %w", expr, err)
+	}
+	d := derivedLabel{source: strings.TrimSpace(source), reg: reg}
+	if targets = strings.TrimSpace(targets); targets != "" {
+		for _, t := range strings.Split(targets, ",") {
+			d.targets = append(d.targets, strings.TrimSpace(t))
+		}
+	} else {
+		// the names of the groups, the first name is the whole match
+		d.targets = reg.SubexpNames()[1:]
+	}
+	if d.source == "" || len(d.targets) != reg.NumSubexp() || strings.Join(d.targets, "") == "" {
+		return derivedLabel{}, fmt.Errorf("derived_labels: rule needs a label for each capture group [%s]", rule)
+	}
+	return d, nil
+}
+
+// applyDerivedLabels sets the derived labels of each instance of data. Labels are only set when the regex matches,
+// and rules are applied in order, so a rule can use the labels of the rules before it
+func applyDerivedLabels(data map[string]*matrix.Matrix, rules []derivedLabel) {
+	if len(rules) == 0 {
+		return
+	}
+	for _, mat := range data {
+		for _, instance := range mat.GetInstances() {
+			for _, r := range rules {
+				m := r.reg.FindStringSubmatch(instance.GetLabel(r.source))
+				if m == nil {
+					continue
+				}
+				for i, target := range r.targets {
+					if target != "" && m[i+1] != "" {
+						instance.SetLabel(target, m[i+1])
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+)
+
+func TestDerivedLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		volume  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "targets",
+			rule:   "volume `^([a-z]+)_(prd|dev)_` app,env",
+			volume: "sap_prd_data01",
+			want:   map[string]string{"app": "sap", "env": "prd"},
+		},
+		{
+			name:   "named groups",
+			rule:   "volume `^(?P<app>[a-z]+)_(?:prd|dev)_`",
+			volume: "sap_dev_log",
+			want:   map[string]string{"app": "sap", "env": ""},
+		},
+		{
+			name:   "skipped group",
+			rule:   "volume `^([a-z]+)_(prd|dev)_` ,env",
+			volume: "sap_prd_data01",
+			want:   map[string]string{"app": "", "env": "prd"},
+		},
+		{
+			name:   "no match",
+			rule:   "volume `^([a-z]+)_(prd|dev)_` app,env",
+			volume: "vol0",
+			want:   map[string]string{"app": "", "env": ""},
+		},
+		{name: "missing targets", rule: "volume `^([a-z]+)_`", wantErr: true},
+		{name: "too many targets", rule: "volume `^([a-z]+)_` app,env", wantErr: true},
+		{name: "bad regex", rule: "volume `^([a-z]+_` app", wantErr: true},
+		{name: "bad format", rule: "volume ^([a-z]+)_ app", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte("derived_labels:\n  - " + tt.rule + "\n"))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			rules, err := parseDerivedLabels(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDerivedLabels() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			data := matrix.New("Rest", "volume", "volume")
+			instance, _ := data.NewInstance("a")
+			instance.SetLabel("volume", tt.volume)
+			applyDerivedLabels(map[string]*matrix.Matrix{"volume": data}, rules)
+			for label, want := range tt.want {
+				if got := instance.GetLabel(label); got != want {
+					t.Errorf("label %s got=%q, want=%q", label, got, want)
+				}
+			}
+		})
+	}
+}
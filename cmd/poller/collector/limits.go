@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"golang.org/x/exp/maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// otherValue replaces the values of a label that are over its limit
+const otherValue = "other"
+
+// labelLimits bounds the distinct values of labels with unbounded values, e.g. the user_name of top clients.
+// The values of a label are allowed up to its limit, the ones over the limit are exported as "other", and the
+// instances that have the same instance keys once collapsed are exported as one instance with the sum of their values.
+// An allowed value keeps its slot while it is polled, so its series are stable, and frees it once it is not
+type labelLimits struct {
+	limits   map[string]int
+	allowed  map[string]map[string]bool // allowed values of each object and label
+	overflow map[string]bool            // objects and labels that were over their limit in the last poll
+}
+
+// parseLabelLimits returns the "label_limits" section of the template, or nil when the template does not limit labels.
+// The section maps a label to the maximum number of its distinct values that are exported
+func parseLabelLimits(params *node.Node) (*labelLimits, error) {
+	section := params.GetChildS("label_limits")
+	if section == nil {
+		return nil, nil
+	}
+	l := &labelLimits{limits: make(map[string]int), allowed: make(map[string]map[string]bool), overflow: make(map[string]bool)}
+	for _, c := range section.GetChildren() {
+		limit, err := strconv.Atoi(c.GetContentS())
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("label_limits: %s must be a positive number of values [%s]", c.GetNameS(), c.GetContentS())
+		}
+		l.limits[c.GetNameS()] = limit
+	}
+	return l, nil
+}
+
+// apply returns mat with the values of the limited labels over their limit replaced with "other", and the number of
+// values that were replaced. mat is not changed, it is cloned when a label is over its limit.
+// started are the labels that went over their limit in this poll
+func (l *labelLimits) apply(mat *matrix.Matrix) (*matrix.Matrix, int, []string) {
+	collapse := make(map[string]map[string]bool) // label => allowed values, for the labels over their limit
+	var started []string
+	for _, label := range sortedKeys(l.limits) {
+		values := make(map[string]bool)
+		for _, instance := range mat.GetInstances() {
+			if value, ok := instance.GetLabels()[label]; ok && instance.IsExportable() {
+				values[value] = true
+			}
+		}
+		key := mat.Object + "." + label
+		allowed := l.admit(key, values, l.limits[label])
+		over := len(values) > len(allowed)
+		if over {
+			collapse[label] = allowed
+			if !l.overflow[key] {
+				started = append(started, label)
+			}
+		}
+		l.overflow[key] = over
+	}
+	if len(collapse) == 0 {
+		return mat, 0, nil
+	}
+
+	clone := mat.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	replaced := make(map[string]bool)
+	merged := make(map[string]*matrix.Instance) // first instance of each set of exported keys
+	keys := exportedKeys(mat)
+	for _, key := range sortedKeys(clone.GetInstances()) {
+		instance := clone.GetInstance(key)
+		if !instance.IsExportable() {
+			continue
+		}
+		collapsed := false
+		for label, allowed := range collapse {
+			value, ok := instance.GetLabels()[label]
+			if ok && !allowed[value] {
+				replaced[label+"="+value] = true
+				instance.SetLabel(label, otherValue)
+				collapsed = true
+			}
+		}
+		if !collapsed {
+			continue
+		}
+		id := labelsID(instance.GetLabels(), keys)
+		first, ok := merged[id]
+		if !ok {
+			merged[id] = instance
+			continue
+		}
+		for _, metric := range clone.GetMetrics() {
+			if !summable(metric) {
+				// the merged instance has no denominator to weigh the values of its instances with
+				metric.SetValueNAN(first)
+				continue
+			}
+			if v, ok := metric.GetValueFloat64(instance); ok {
+				_ = metric.AddValueFloat64(first, v)
+			}
+		}
+		instance.SetExportable(false)
+	}
+	return clone, len(replaced), started
+}
+
+// exportedKeys returns the instance keys the exporters identify the instances of mat with,
+// or nil when all the labels are exported
+func exportedKeys(mat *matrix.Matrix) []string {
+	options := mat.GetExportOptions()
+	if options == nil || options.GetChildContentS("include_all_labels") == "true" {
+		return nil
+	}
+	if keys := options.GetChildS("instance_keys"); keys != nil {
+		return keys.GetAllChildContentS()
+	}
+	return nil
+}
+
+// summable returns false for the metrics that are cooked with a denominator (latencies, averages, and percents),
+// their values can not be added
+func summable(metric *matrix.Metric) bool {
+	name := metric.GetName()
+	switch {
+	case metric.GetProperty() == "average" || metric.GetProperty() == "percent":
+		return false
+	case strings.Contains(name, "average_") || strings.Contains(name, "avg_"):
+		return false
+	case !metric.IsHistogram() && strings.Contains(name, "_latency"):
+		return false
+	}
+	return true
+}
+
+// admit returns the allowed values of key for this poll. The allowed values that are still polled keep their slot,
+// and the free slots are given to the new values in order
+func (l *labelLimits) admit(key string, values map[string]bool, limit int) map[string]bool {
+	allowed := make(map[string]bool, limit)
+	for value := range l.allowed[key] {
+		if values[value] {
+			allowed[value] = true
+		}
+	}
+	for _, value := range sortedKeys(values) {
+		if len(allowed) >= limit {
+			break
+		}
+		allowed[value] = true
+	}
+	l.allowed[key] = allowed
+	return allowed
+}
+
+// labelsID returns the keys of an instance as a string, instances with the same keys have the same ID.
+// All the labels are used when keys is nil
+func labelsID(labels map[string]string, keys []string) string {
+	if keys == nil {
+		keys = sortedKeys(labels)
+	}
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+// applyLabelLimits returns the results with the values of the limited labels over their limit collapsed into "other",
+// and records the number of collapsed values in the metadata of the data task. The matrices of the collector are not
+// changed, so its cache keeps the values of the labels
+func (c *AbstractCollector) applyLabelLimits(results []*matrix.Matrix) []*matrix.Matrix {
+	l := c.labelLimits
+	if l == nil || len(results) == 0 {
+		return results
+	}
+	limited := make([]*matrix.Matrix, 0, len(results))
+	collapsed := 0
+	for _, mat := range results {
+		clone, n, started := l.apply(mat)
+		limited = append(limited, clone)
+		collapsed += n
+		for _, label := range started {
+			c.Logger.Warn().
+				Str("object", mat.Object).
+				Str("label", label).
+				Int("limit", l.limits[label]).
+				Msg("Label is over its limit, values over the limit are exported as other")
+		}
+	}
+	_ = c.Metadata.LazySetValueUint64("collapsed_values", "data", uint64(collapsed)) //nolint:gosec
+	return limited
+}
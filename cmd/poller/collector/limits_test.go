@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"maps"
+	"testing"
+)
+
+func TestParseLabelLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no limits", yaml: "object: top_clients\n", wantNil: true},
+		{name: "limit", yaml: "label_limits:\n  user_name: 100\n"},
+		{name: "zero", yaml: "label_limits:\n  user_name: 0\n", wantErr: true},
+		{name: "not a number", yaml: "label_limits:\n  user_name: many\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			limits, err := parseLabelLimits(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLabelLimits() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err == nil && (limits == nil) != tt.wantNil {
+				t.Errorf("parseLabelLimits() nil=%t, wantNil=%t", limits == nil, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestLabelLimits_Apply(t *testing.T) {
+	params, err := tree.LoadYaml([]byte("label_limits:\n  user_name: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits, err := parseLabelLimits(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// poll returns a matrix of the top clients with the users and ops, like a data poll
+	poll := func(users map[string]float64) *matrix.Matrix {
+		mat := matrix.New("Rest", "top_clients", "top_clients")
+		ops, _ := mat.NewMetricFloat64("ops")
+		for user, v := range users {
+			instance, _ := mat.NewInstance(user)
+			instance.SetLabel("user_name", user)
+			instance.SetLabel("svm", "svm1")
+			_ = ops.SetValueFloat64(instance, v)
+		}
+		return mat
+	}
+
+	// exported returns the ops of the exported instances by user_name
+	exported := func(mat *matrix.Matrix) map[string]float64 {
+		got := make(map[string]float64)
+		for _, instance := range mat.GetInstances() {
+			if !instance.IsExportable() {
+				continue
+			}
+			v, _ := mat.GetMetric("ops").GetValueFloat64(instance)
+			got[instance.GetLabel("user_name")] += v
+		}
+		return got
+	}
+
+	// under the limit, the matrix is exported as is
+	mat := poll(map[string]float64{"bob": 1, "carol": 2})
+	limited, collapsed, started := limits.apply(mat)
+	if limited != mat || collapsed != 0 || len(started) != 0 {
+		t.Errorf("apply() under the limit got collapsed=%d started=%v, want the matrix unchanged", collapsed, started)
+	}
+
+	// alice is new, bob and carol keep their slots
+	mat = poll(map[string]float64{"alice": 4, "bob": 1, "carol": 2, "dave": 8})
+	limited, collapsed, started = limits.apply(mat)
+	want := map[string]float64{"bob": 1, "carol": 2, otherValue: 12}
+	if got := exported(limited); !maps.Equal(got, want) {
+		t.Errorf("apply() got=%v, want=%v", got, want)
+	}
+	if collapsed != 2 {
+		t.Errorf("apply() collapsed got=%d, want=2", collapsed)
+	}
+	exportable := 0
+	for _, instance := range limited.GetInstances() {
+		if instance.IsExportable() {
+			exportable++
+		}
+	}
+	if exportable != 3 {
+		t.Errorf("apply() exported %d instances, want alice and dave merged into one other instance", exportable)
+	}
+	if len(started) != 1 || started[0] != "user_name" {
+		t.Errorf("apply() started got=%v, want=[user_name]", started)
+	}
+	if mat.GetInstance("alice").GetLabel("user_name") != "alice" {
+		t.Errorf("apply() changed the labels of the polled matrix")
+	}
+
+	// carol is gone, her slot goes to alice
+	mat = poll(map[string]float64{"alice": 4, "bob": 1, "dave": 8})
+	limited, collapsed, started = limits.apply(mat)
+	want = map[string]float64{"alice": 4, "bob": 1, otherValue: 8}
+	if got := exported(limited); !maps.Equal(got, want) {
+		t.Errorf("apply() got=%v, want=%v", got, want)
+	}
+	if collapsed != 1 || len(started) != 0 {
+		t.Errorf("apply() got collapsed=%d started=%v, want collapsed=1 and no started labels", collapsed, started)
+	}
+}
+
+func TestLabelLimits_ApplyKeys(t *testing.T) {
+	params, err := tree.LoadYaml([]byte("label_limits:\n  user_name: 1\nexport_options:\n  instance_keys:\n    - svm\n    - user_name\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits, err := parseLabelLimits(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the uuid is not exported, it does not keep the instances collapsed to other apart
+	mat := matrix.New("Rest", "top_clients", "top_clients")
+	mat.SetExportOptions(params.GetChildS("export_options"))
+	ops, _ := mat.NewMetricFloat64("ops")
+	latency, _ := mat.NewMetricFloat64("avg_latency")
+	latency.SetProperty("average")
+	for i, user := range []string{"alice", "bob", "carol"} {
+		instance, _ := mat.NewInstance(user)
+		instance.SetLabel("user_name", user)
+		instance.SetLabel("svm", "svm1")
+		instance.SetLabel("uuid", user+"-uuid")
+		_ = ops.SetValueFloat64(instance, float64(i+1))
+		_ = latency.SetValueFloat64(instance, 10)
+	}
+
+	limited, _, _ := limits.apply(mat)
+	var others []*matrix.Instance
+	for _, instance := range limited.GetInstances() {
+		if instance.IsExportable() && instance.GetLabel("user_name") == otherValue {
+			others = append(others, instance)
+		}
+	}
+	if len(others) != 1 {
+		t.Fatalf("apply() exported %d other instances, want bob and carol merged into one", len(others))
+	}
+	if v, ok := limited.GetMetric("ops").GetValueFloat64(others[0]); !ok || v != 5 {
+		t.Errorf("apply() other ops got=%v/%t, want=5/true", v, ok)
+	}
+	if v, ok := limited.GetMetric("avg_latency").GetValueFloat64(others[0]); ok {
+		t.Errorf("apply() other avg_latency got=%v, want it not exported", v)
+	}
+}
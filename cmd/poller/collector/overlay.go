@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"errors"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// dropKey is the section of an overlay that lists what the overlay removes from the template
+const dropKey = "drop"
+
+// applyOverlays patches a template with the overlays of the poller. The template_overlays of a poller are
+// directories, applied in order, with the same layout as conf, without version directories,
+// e.g. overlays/cluster-01/rest/volume.yaml patches every version of conf/rest/*/volume.yaml.
+// Relative directories are relative to the Harvest configuration directory
+func (c *AbstractCollector) applyOverlays(template *node.Node, filenames []string) {
+	overlays := c.Params.GetChildS("template_overlays")
+	if overlays == nil {
+		return
+	}
+	homePath := conf.Path("")
+	for _, dir := range overlays.GetAllChildContentS() {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(homePath, dir)
+		}
+		for _, f := range filenames {
+			fp := filepath.Join(dir, strings.ToLower(c.Name), f)
+			if _, err := os.Stat(fp); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			overlay, err := tree.ImportYaml(fp)
+			if err != nil {
+				c.Logger.Warn().Err(err).Str("path", fp).Msg("Unable to import template overlay. File is invalid or empty")
+				continue
+			}
+			overlay.PreprocessTemplate()
+			c.recordSource(fp, overlay)
+			ApplyOverlay(template, overlay)
+			c.Logger.Info().Str("path", fp).Msg("applied template overlay")
+		}
+	}
+}
+
+// ApplyOverlay patches template with overlay. The drop section of the overlay is removed from the template first,
+// then the rest of the overlay is merged like a custom template: lists are extended, e.g. counters,
+// and values are replaced, e.g. the data schedule
+func ApplyOverlay(template *node.Node, overlay *node.Node) {
+	if drop := overlay.PopChildS(dropKey); drop != nil {
+		dropNodes(template, drop)
+	}
+	template.Merge(overlay, nil)
+}
+
+// dropNodes removes the children of n that drop lists. A section of drop removes from the section with the same name,
+// a key without a value removes the key, and a list item removes the items and keys with that name
+func dropNodes(n *node.Node, drop *node.Node) {
+	for _, d := range drop.GetChildren() {
+		switch {
+		case len(d.GetChildren()) > 0:
+			if child := n.GetChildS(d.GetNameS()); child != nil {
+				dropNodes(child, d)
+			}
+		case d.GetNameS() != "" && d.GetContentS() == "":
+			n.PopChildS(d.GetNameS())
+		default:
+			n.Children = slices.DeleteFunc(n.Children, func(child *node.Node) bool {
+				return isNamed(child, d.GetContentS())
+			})
+		}
+	}
+}
+
+// isNamed returns true when the name or content of n is name. A counter, e.g. ^^name => volume,
+// is named by both its ONTAP name and its display name
+func isNamed(n *node.Node, name string) bool {
+	if n.GetNameS() == name || n.GetContentS() == name {
+		return true
+	}
+	if len(n.GetChildren()) > 0 {
+		return false
+	}
+	counter, display, _ := strings.Cut(n.GetContentS(), "=>")
+	return strings.TrimLeft(strings.TrimSpace(counter), "^") == name || strings.TrimSpace(display) == name
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const overlayTemplate = `
+name: Volume
+query: api/storage/volumes
+object: volume
+client_timeout: 1m
+schedule:
+  - data: 3m
+counters:
+  - ^^name => volume
+  - ^^svm.name => svm
+  - ^state => state
+  - ^style => style
+  - space.size => size
+plugins:
+  - Volume
+  - LabelAgent:
+      exclude_equals:
+        - style flexgroup_constituent
+export_options:
+  instance_keys:
+    - svm
+    - volume
+  instance_labels:
+    - state
+    - style
+`
+
+func TestApplyOverlay(t *testing.T) {
+	template, err := tree.LoadYaml([]byte(overlayTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := tree.LoadYaml([]byte(`
+schedule:
+  - data: 1m
+counters:
+  - space.available => size_available
+drop:
+  client_timeout:
+  counters:
+    - style
+    - space.size
+  export_options:
+    instance_labels:
+      - style
+  plugins:
+    - LabelAgent
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyOverlay(template, overlay)
+
+	if got := template.GetChildS("schedule").GetChildContentS("data"); got != "1m" {
+		t.Errorf("schedule data got=%s, want=1m", got)
+	}
+	if template.GetChildS("client_timeout") != nil {
+		t.Errorf("client_timeout got=%s, want dropped", template.GetChildContentS("client_timeout"))
+	}
+	if template.GetChildS(dropKey) != nil {
+		t.Errorf("drop is merged into the template")
+	}
+	wantCounters := []string{"^^name => volume", "^^svm.name => svm", "^state => state", "space.available => size_available"}
+	if got := template.GetChildS("counters").GetAllChildContentS(); !slices.Equal(got, wantCounters) {
+		t.Errorf("counters got=%v, want=%v", got, wantCounters)
+	}
+	if got := template.GetChildS("export_options").GetChildS("instance_labels").GetAllChildContentS(); !slices.Equal(got, []string{"state"}) {
+		t.Errorf("instance_labels got=%v, want=[state]", got)
+	}
+	plugins := template.GetChildS("plugins")
+	if plugins.GetChildS("LabelAgent") != nil || plugins.GetChildByContent("Volume") == nil {
+		t.Errorf("plugins got=%v, want=[Volume]", plugins.GetAllChildNamesS())
+	}
+}
+
+func TestApplyOverlays(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"common", "cluster-01"} {
+		if err := os.MkdirAll(filepath.Join(dir, d, "rest"), 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path string, content string) {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common/rest/volume.yaml", "schedule:\n  - data: 5m\ncounters:\n  - ^type => type\n")
+	write("cluster-01/rest/volume.yaml", "schedule:\n  - data: 1m\n")
+
+	template, err := tree.LoadYaml([]byte(overlayTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := node.NewS("Rest")
+	overlays := params.NewChildS("template_overlays", "")
+	overlays.NewChildS("", filepath.Join(dir, "common"))
+	overlays.NewChildS("", filepath.Join(dir, "cluster-01"))
+	overlays.NewChildS("", filepath.Join(dir, "missing"))
+	c := &AbstractCollector{Name: "Rest", Params: params, Logger: logging.Get(), Sources: []Source{}}
+
+	c.applyOverlays(template, []string{"volume.yaml"})
+
+	if got := template.GetChildS("schedule").GetChildContentS("data"); got != "1m" {
+		t.Errorf("schedule data got=%s, want=1m from the last overlay", got)
+	}
+	if template.GetChildS("counters").GetChildByContent("^type => type") == nil {
+		t.Errorf("counters missing ^type => type")
+	}
+	if len(c.Sources) != 2 {
+		t.Errorf("sources got=%d, want=2", len(c.Sources))
+	}
+}
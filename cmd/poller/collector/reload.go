@@ -0,0 +1,36 @@
+package collector
+
+// Reloader is implemented by the collectors that can reload the counters of their template while they run, without
+// resetting the data cached by their previous poll, e.g. the raw values that perf counters are cooked from
+type Reloader interface {
+	// ReloadCounters re-reads the counters of the template. It runs in the goroutine of the collector, between polls.
+	// The current counters are kept when it fails
+	ReloadCounters() error
+}
+
+// RequestReload asks c to reload the counters of its template before its next poll.
+// It returns false when c can not reload its counters, so a changed template needs a restart of the poller
+func RequestReload(c Collector) bool {
+	r, ok := c.(Reloader)
+	if !ok {
+		return false
+	}
+	c.QueueReload(r.ReloadCounters)
+	return true
+}
+
+// QueueReload queues reload to run in the goroutine of the collector as soon as it waits for its next task.
+// A reload is dropped when one is already queued, since it would read the same template
+func (c *AbstractCollector) QueueReload(reload func() error) {
+	select {
+	case c.reload <- reload:
+	default:
+	}
+}
+
+// runReload runs a queued reload, a collector keeps its current counters when the reload fails
+func (c *AbstractCollector) runReload(reload func() error) {
+	if err := reload(); err != nil {
+		c.Logger.Error().Err(err).Msg("Failed to reload counters, keep the current counters")
+	}
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"errors"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/poller/schedule"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+	"time"
+)
+
+type reloadingCollector struct {
+	*AbstractCollector
+	reloads int
+	err     error
+}
+
+func (r *reloadingCollector) Init(*AbstractCollector) error { return nil }
+
+func (r *reloadingCollector) ReloadCounters() error {
+	r.reloads++
+	return r.err
+}
+
+type staticCollector struct {
+	*AbstractCollector
+}
+
+func (s *staticCollector) Init(*AbstractCollector) error { return nil }
+
+func TestRequestReload(t *testing.T) {
+	ac := New("Rest", "Volume", &options.Options{}, node.NewS("params"), nil)
+	s := schedule.New()
+	if err := s.NewTask("data", 20*time.Millisecond, 0, nil, false, "data"); err != nil {
+		t.Fatal(err)
+	}
+	ac.Schedule = s
+	// the queued reloads run before the data task is due, then wait returns
+	task := s.GetTask("data")
+
+	if RequestReload(&staticCollector{AbstractCollector: ac}) {
+		t.Error("RequestReload() want false for a collector that can not reload its counters")
+	}
+
+	c := &reloadingCollector{AbstractCollector: ac, err: errors.New("boom")}
+	if !RequestReload(c) || !RequestReload(c) {
+		t.Fatal("RequestReload() want true")
+	}
+	task.Start()
+	c.wait()
+	if c.reloads != 1 {
+		t.Errorf("reloads got=%d, want=1, a queued reload is not queued again", c.reloads)
+	}
+
+	// a failed reload does not stop the collector
+	c.err = nil
+	RequestReload(c)
+	task.Start()
+	c.wait()
+	if c.reloads != 2 {
+		t.Errorf("reloads got=%d, want=2", c.reloads)
+	}
+}
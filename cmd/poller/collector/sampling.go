@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sampling exports a stable subset of the instances of an object, plus the instances that match a priority rule,
+// so objects with huge instance counts can trade completeness for cost.
+// An instance is sampled when the hash of its key is below fraction, so the same instances are exported on every
+// poll and after restarts.
+// Collectors with an instance task, like the perf collectors, poll the data of the instances they know, so the
+// instances that are sampled out are removed after each instance poll, see prune, and are not collected.
+// The instances of other collectors are created by their data poll, so they are still collected, and only their
+// export is sampled, after the plugins. Sampled out instances are made exportable again after the export, so the
+// next poll's plugins see them too
+type sampling struct {
+	fraction float64
+	priority []priorityRule
+	hidden   []*matrix.Instance // instances that sampling made not exportable for the export of the current poll
+}
+
+// priorityRule matches the instances whose label matches reg
+type priorityRule struct {
+	label string
+	reg   *regexp.Regexp
+}
+
+// parseSampling returns the "sampling" section of the template, or nil when the template does not sample.
+// The section has a fraction between 0 and 1 and an optional list of priority rules with the syntax LABEL `REGEX`
+func parseSampling(params *node.Node) (*sampling, error) {
+	section := params.GetChildS("sampling")
+	if section == nil {
+		return nil, nil
+	}
+	value := section.GetChildContentS("fraction")
+	fraction, err := strconv.ParseFloat(value, 64)
+	if err != nil || fraction < 0 || fraction > 1 {
+		return nil, fmt.Errorf("sampling: fraction must be between 0 and 1 [%s]", value)
+	}
+	s := &sampling{fraction: fraction}
+	if priority := section.GetChildS("priority"); priority != nil {
+		for _, c := range priority.GetChildren() {
+			rule := strings.TrimSpace(c.GetContentS())
+			label, rest, ok := strings.Cut(rule, " `")
+			expr, _, ok2 := strings.Cut(rest, "`")
+			if !ok || !ok2 || strings.TrimSpace(label) == "" {
+				return nil, fmt.Errorf("sampling: priority rule has invalid format [%s]", rule)
+			}
+			reg, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("sampling: invalid regex [%s]: %w", expr, err)
+			}
+			s.priority = append(s.priority, priorityRule{label: strings.TrimSpace(label), reg: reg})
+		}
+	}
+	return s, nil
+}
+
+// apply makes the exportable instances of mat that are not sampled not exportable, and returns the number of
+// sampled instances
+func (s *sampling) apply(mat *matrix.Matrix) int {
+	sampled := 0
+	for key, instance := range mat.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		if s.keep(key, instance) {
+			sampled++
+			continue
+		}
+		instance.SetExportable(false)
+		s.hidden = append(s.hidden, instance)
+	}
+	return sampled
+}
+
+// prune removes the instances of mat that are not sampled, and returns the number of removed instances
+func (s *sampling) prune(mat *matrix.Matrix) int {
+	var removed []string
+	for key, instance := range mat.GetInstances() {
+		if !s.keep(key, instance) {
+			removed = append(removed, key)
+		}
+	}
+	for _, key := range removed {
+		mat.RemoveInstance(key)
+	}
+	return len(removed)
+}
+
+// restore makes the instances that apply sampled out exportable again
+func (s *sampling) restore() {
+	for _, instance := range s.hidden {
+		instance.SetExportable(true)
+	}
+	s.hidden = s.hidden[:0]
+}
+
+func (s *sampling) keep(key string, instance *matrix.Instance) bool {
+	for _, r := range s.priority {
+		if r.reg.MatchString(instance.GetLabel(r.label)) {
+			return true
+		}
+	}
+	return sampleKey(key) < s.fraction
+}
+
+// sampleKey maps key to a stable value in [0, 1). FNV spreads similar keys, e.g. vol1 and vol2, poorly over its
+// high bits, so the hash is mixed with the finalizer of MurmurHash3 before its top 53 bits are used
+func sampleKey(key string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return float64(x>>11) / float64(uint64(1)<<53)
+}
+
+// applySampling samples the instances of the collector's object, after the plugins ran, and records the sampling
+// in the metadata of the data task. restoreSampling must be called once the poll is exported
+func (c *AbstractCollector) applySampling(data map[string]*matrix.Matrix) {
+	s := c.sampling
+	if s == nil {
+		return
+	}
+	mat, ok := data[c.Object]
+	if !ok {
+		return
+	}
+	sampled := s.apply(mat)
+	_ = c.Metadata.LazySetValueFloat64("sampling_fraction", "data", s.fraction)
+	_ = c.Metadata.LazySetValueUint64("sampled_instances", "data", uint64(sampled))
+}
+
+// sampleInstances removes the instances of the collector's object that are not sampled, after an instance task,
+// so the following data polls do not collect them. The derived labels are set first, since priority rules match them
+func (c *AbstractCollector) sampleInstances() {
+	s := c.sampling
+	if s == nil {
+		return
+	}
+	mat, ok := c.Matrix[c.Object]
+	if !ok {
+		return
+	}
+	applyDerivedLabels(map[string]*matrix.Matrix{c.Object: mat}, c.derived)
+	if removed := s.prune(mat); removed > 0 {
+		c.Logger.Debug().Int("removed", removed).Int("sampled", len(mat.GetInstances())).Msg("Sampled instances")
+	}
+}
+
+// restoreSampling makes the instances that applySampling sampled out exportable again.
+// It must be called before the results of the poll are released
+func (c *AbstractCollector) restoreSampling() {
+	if c.sampling != nil {
+		c.sampling.restore()
+	}
+}
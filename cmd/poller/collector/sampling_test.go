@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no section", yaml: "object: volume\n", wantNil: true},
+		{name: "fraction", yaml: "sampling:\n  fraction: 0.1\n"},
+		{name: "priority", yaml: "sampling:\n  fraction: 0.1\n  priority:\n    - svm `^prod`\n"},
+		{name: "missing fraction", yaml: "sampling:\n  priority:\n    - svm `^prod`\n", wantErr: true},
+		{name: "fraction above 1", yaml: "sampling:\n  fraction: 1.5\n", wantErr: true},
+		{name: "bad regex", yaml: "sampling:\n  fraction: 0.1\n  priority:\n    - svm `^(prod`\n", wantErr: true},
+		{name: "bad format", yaml: "sampling:\n  fraction: 0.1\n  priority:\n    - svm ^prod\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			s, err := parseSampling(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSampling() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err == nil && (s == nil) != tt.wantNil {
+				t.Errorf("parseSampling() nil=%t, wantNil=%t", s == nil, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestSampling_Apply(t *testing.T) {
+	params, err := tree.LoadYaml([]byte("sampling:\n  fraction: 0.1\n  priority:\n    - svm `^prod`\n"))
+	if err != nil {
+		t.Fatalf("failed to load yaml err=%v", err)
+	}
+	s, err := parseSampling(params)
+	if err != nil {
+		t.Fatalf("parseSampling() err=%v", err)
+	}
+
+	data := matrix.New("Rest", "volume", "volume")
+	for i := range 10_000 {
+		instance, _ := data.NewInstance("vol" + strconv.Itoa(i))
+		instance.SetLabel("svm", "test")
+	}
+	prod, _ := data.NewInstance("prod_vol")
+	prod.SetLabel("svm", "prod1")
+
+	sampled := s.apply(data)
+	if got := float64(sampled-1) / 10_000; math.Abs(got-0.1) > 0.01 {
+		t.Errorf("sampled fraction got=%f, want=0.1", got)
+	}
+	if !prod.IsExportable() {
+		t.Errorf("priority instance was sampled out")
+	}
+
+	// the same instances are sampled on every poll
+	exportable := make(map[string]bool)
+	for key, instance := range data.GetInstances() {
+		exportable[key] = instance.IsExportable()
+	}
+	s.restore()
+	if again := s.apply(data); again != sampled {
+		t.Errorf("sampled got=%d, want=%d", again, sampled)
+	}
+	for key, instance := range data.GetInstances() {
+		if instance.IsExportable() != exportable[key] {
+			t.Fatalf("instance %s changed exportable to %t", key, instance.IsExportable())
+		}
+	}
+
+	// an instance that matches a priority rule is exported again
+	for key, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			instance.SetLabel("svm", "prod2")
+			s.restore()
+			s.apply(data)
+			if !instance.IsExportable() {
+				t.Errorf("instance %s with a priority label is not exportable", key)
+			}
+			break
+		}
+	}
+}
+
+func TestSampling_Restore(t *testing.T) {
+	params, err := tree.LoadYaml([]byte("sampling:\n  fraction: 0\n"))
+	if err != nil {
+		t.Fatalf("failed to load yaml err=%v", err)
+	}
+	s, err := parseSampling(params)
+	if err != nil {
+		t.Fatalf("parseSampling() err=%v", err)
+	}
+	data := matrix.New("Rest", "volume", "volume")
+	vol1, _ := data.NewInstance("vol1")
+	// a plugin hides vol2
+	vol2, _ := data.NewInstance("vol2")
+	vol2.SetExportable(false)
+
+	if sampled := s.apply(data); sampled != 0 || vol1.IsExportable() {
+		t.Errorf("apply() got sampled=%d exportable=%t, want vol1 sampled out", sampled, vol1.IsExportable())
+	}
+	// after the export, the plugins of the next poll see the sampled out instances again
+	s.restore()
+	if !vol1.IsExportable() {
+		t.Error("restore() want vol1 exportable")
+	}
+	if vol2.IsExportable() {
+		t.Error("restore() want vol2, hidden by a plugin, not exportable")
+	}
+}
+
+func TestSampling_Prune(t *testing.T) {
+	params, err := tree.LoadYaml([]byte("sampling:\n  fraction: 0.5\n  priority:\n    - svm `^prod`\n"))
+	if err != nil {
+		t.Fatalf("failed to load yaml err=%v", err)
+	}
+	s, err := parseSampling(params)
+	if err != nil {
+		t.Fatalf("parseSampling() err=%v", err)
+	}
+
+	// the instances polled by an instance task, with the index of each instance as the value of size
+	data := matrix.New("ZapiPerf", "volume", "volume")
+	size, _ := data.NewMetricFloat64("size")
+	for i := range 100 {
+		instance, _ := data.NewInstance("vol" + strconv.Itoa(i))
+		instance.SetLabel("svm", "test")
+		_ = size.SetValueFloat64(instance, float64(i))
+	}
+	prod, _ := data.NewInstance("prod_vol")
+	prod.SetLabel("svm", "prod1")
+
+	removed := s.prune(data)
+	if removed == 0 || removed+len(data.GetInstances()) != 101 {
+		t.Fatalf("prune() removed=%d kept=%d, want some of the 101 instances removed", removed, len(data.GetInstances()))
+	}
+	if data.GetInstance("prod_vol") == nil {
+		t.Errorf("prune() removed the priority instance")
+	}
+	for key, instance := range data.GetInstances() {
+		if !s.keep(key, instance) {
+			t.Errorf("prune() kept %s, which is sampled out", key)
+		}
+		if key == "prod_vol" {
+			continue
+		}
+		if v, _ := size.GetValueFloat64(instance); "vol"+strconv.Itoa(int(v)) != key {
+			t.Errorf("prune() %s got size=%v, want the values of the kept instances unchanged", key, v)
+		}
+	}
+	if again := s.prune(data); again != 0 {
+		t.Errorf("prune() removed %d sampled instances, want 0", again)
+	}
+}
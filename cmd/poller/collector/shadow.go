@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const defaultShadowPrefix = "shadow"
+
+var shadowPrefixRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Shadow pairs the collector of an object with a shadow collector, which collects and cooks the object with a new
+// version of its template, but exports to a separate prefix or exporter. The shadow compares its exports with the
+// exports of the current template, so the new version can be checked on a production poller before it replaces
+// the current one
+type Shadow struct {
+	Template string // template of the shadow collector, e.g. volume_next.yaml
+	Prefix   string // prefix of the objects exported by the shadow, empty when the shadow has its own exporter
+	Exporter string // exporter of the shadow, empty when the shadow exports to the exporters of the poller
+	mu       sync.Mutex
+	current  exports  // exports of the last poll of the current template
+	reported []string // last diff that was logged
+}
+
+// exports summarizes the exports of a poll
+type exports struct {
+	metrics map[string]int  // number of exported instances, by object and metric name, e.g. volume_read_ops
+	labels  map[string]bool // exported labels, by object and label name, e.g. volume{svm}
+}
+
+// ShadowDiff is the difference between the exports of the shadow and the exports of the current template
+type ShadowDiff struct {
+	Added   []string // metrics and labels only exported by the shadow
+	Removed []string // metrics and labels only exported by the current template
+	Changed []string // metrics exported by both, but for a different number of instances
+}
+
+// ParseShadows returns the shadows of the objects of a collector, from the "shadow" section of its template,
+// or nil when the template has none. Example:
+//
+//	shadow:
+//	  prefix: shadow            # default
+//	  exporter: prometheus-next # optional
+//	  objects:
+//	    Volume: volume_next.yaml
+func ParseShadows(template *node.Node) (map[string]*Shadow, error) {
+	section := template.GetChildS("shadow")
+	if section == nil {
+		return nil, nil
+	}
+	exporter := section.GetChildContentS("exporter")
+	prefix := section.GetChildContentS("prefix")
+	switch {
+	case prefix == "" && exporter == "":
+		prefix = defaultShadowPrefix
+	case prefix != "" && !shadowPrefixRe.MatchString(prefix):
+		return nil, fmt.Errorf("shadow: prefix (%s) must be a valid metric name", prefix)
+	}
+	objects := section.GetChildS("objects")
+	if objects == nil || len(objects.GetChildren()) == 0 {
+		return nil, fmt.Errorf("shadow: objects are required")
+	}
+	shadows := make(map[string]*Shadow)
+	for _, o := range objects.GetChildren() {
+		if o.GetContentS() == "" {
+			return nil, fmt.Errorf("shadow: object %s has no template", o.GetNameS())
+		}
+		shadows[o.GetNameS()] = &Shadow{Template: o.GetContentS(), Prefix: prefix, Exporter: exporter}
+	}
+	return shadows, nil
+}
+
+// IsShadow returns true when the collector is the shadow collector of its object
+func (c *AbstractCollector) IsShadow() bool {
+	return c.Shadow != nil && c.Shadowing
+}
+
+// recordShadow records the exports of the current template, or compares the exports of the shadow with them,
+// and records the difference in the metadata of the data task
+func (c *AbstractCollector) recordShadow(results []*matrix.Matrix) {
+	s := c.Shadow
+	if s == nil {
+		return
+	}
+	e := summarize(results)
+	if !c.Shadowing {
+		s.mu.Lock()
+		s.current = e
+		s.mu.Unlock()
+		return
+	}
+	diff, ok := s.compare(e)
+	if !ok {
+		return
+	}
+	_ = c.Metadata.LazySetValueUint64("shadow_added", "data", uint64(len(diff.Added)))
+	_ = c.Metadata.LazySetValueUint64("shadow_removed", "data", uint64(len(diff.Removed)))
+	_ = c.Metadata.LazySetValueUint64("shadow_changed", "data", uint64(len(diff.Changed)))
+	if s.changed(diff) {
+		c.Logger.Info().
+			Str("template", s.Template).
+			Strs("added", diff.Added).
+			Strs("removed", diff.Removed).
+			Strs("changed", diff.Changed).
+			Msg("Shadow template differs")
+	}
+}
+
+// compare returns the difference between the exports of the shadow and the exports of the current template,
+// or false when the current template has not been polled yet
+func (s *Shadow) compare(shadow exports) (ShadowDiff, bool) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+	if current.metrics == nil {
+		return ShadowDiff{}, false
+	}
+
+	var diff ShadowDiff
+	for name, n := range shadow.metrics {
+		was, ok := current.metrics[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, name)
+		case was != n:
+			diff.Changed = append(diff.Changed, name+" "+strconv.Itoa(was)+" => "+strconv.Itoa(n))
+		}
+	}
+	for name := range current.metrics {
+		if _, ok := shadow.metrics[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	for label := range shadow.labels {
+		if !current.labels[label] {
+			diff.Added = append(diff.Added, label)
+		}
+	}
+	for label := range current.labels {
+		if !shadow.labels[label] {
+			diff.Removed = append(diff.Removed, label)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, true
+}
+
+// changed returns true when diff is not the last diff that was logged, so an unchanged diff is logged once
+func (s *Shadow) changed(diff ShadowDiff) bool {
+	var all []string
+	all = append(all, diff.Added...)
+	all = append(all, "")
+	all = append(all, diff.Removed...)
+	all = append(all, "")
+	all = append(all, diff.Changed...)
+	if slices.Equal(all, s.reported) {
+		return false
+	}
+	s.reported = all
+	return true
+}
+
+// summarize returns the metrics and labels that the exporters export from results
+func summarize(results []*matrix.Matrix) exports {
+	e := exports{metrics: make(map[string]int), labels: make(map[string]bool)}
+	for _, data := range results {
+		if !data.IsExportable() {
+			continue
+		}
+		exported := 0
+		for _, metric := range data.GetMetrics() {
+			if !metric.IsExportable() {
+				continue
+			}
+			n := 0
+			for _, instance := range data.GetInstances() {
+				if !instance.IsExportable() {
+					continue
+				}
+				if _, ok := metric.GetValueFloat64(instance); ok {
+					n++
+				}
+			}
+			if n > 0 {
+				e.metrics[data.Object+"_"+metric.GetName()] += n
+				exported++
+			}
+		}
+		if exported == 0 {
+			continue
+		}
+		if options := data.GetExportOptions(); options != nil {
+			for _, section := range []string{"instance_keys", "instance_labels"} {
+				if x := options.GetChildS(section); x != nil {
+					for _, label := range x.GetAllChildContentS() {
+						e.labels[data.Object+"{"+label+"}"] = true
+					}
+				}
+			}
+		}
+	}
+	return e
+}
+
+// rename returns copies of results whose objects have the prefix of the shadow, so the exporters of the poller
+// export them next to the current ones, e.g. shadow_volume_read_ops
+func (s *Shadow) rename(results []*matrix.Matrix) []*matrix.Matrix {
+	if s.Prefix == "" {
+		return results
+	}
+	renamed := make([]*matrix.Matrix, 0, len(results))
+	for _, data := range results {
+		if !data.IsExportable() {
+			continue
+		}
+		clone := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+		clone.Object = s.Prefix + "_" + data.Object
+		clone.UUID = s.Prefix + "." + data.UUID
+		renamed = append(renamed, clone)
+	}
+	return renamed
+}
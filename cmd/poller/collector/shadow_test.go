@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestParseShadows(t *testing.T) {
+	tests := []struct {
+		name         string
+		yaml         string
+		wantTemplate string
+		wantPrefix   string
+		wantExporter string
+		wantNil      bool
+		wantErr      bool
+	}{
+		{name: "no section", yaml: "objects:\n  Volume: volume.yaml\n", wantNil: true},
+		{name: "default prefix", yaml: "shadow:\n  objects:\n    Volume: volume_next.yaml\n", wantTemplate: "volume_next.yaml", wantPrefix: "shadow"},
+		{name: "prefix", yaml: "shadow:\n  prefix: next\n  objects:\n    Volume: volume_next.yaml\n", wantTemplate: "volume_next.yaml", wantPrefix: "next"},
+		{name: "exporter", yaml: "shadow:\n  exporter: prom-next\n  objects:\n    Volume: volume_next.yaml\n", wantTemplate: "volume_next.yaml", wantExporter: "prom-next"},
+		{name: "invalid prefix", yaml: "shadow:\n  prefix: 1-next\n  objects:\n    Volume: volume_next.yaml\n", wantErr: true},
+		{name: "no objects", yaml: "shadow:\n  prefix: next\n", wantErr: true},
+		{name: "no template", yaml: "shadow:\n  objects:\n    Volume:\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			shadows, err := ParseShadows(template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseShadows() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (shadows == nil) != tt.wantNil {
+				t.Fatalf("ParseShadows() nil=%t, wantNil=%t", shadows == nil, tt.wantNil)
+			}
+			if tt.wantNil {
+				return
+			}
+			s := shadows["Volume"]
+			if s == nil || s.Template != tt.wantTemplate || s.Prefix != tt.wantPrefix || s.Exporter != tt.wantExporter {
+				t.Errorf("shadow got=%+v, want template=%s prefix=%s exporter=%s", s, tt.wantTemplate, tt.wantPrefix, tt.wantExporter)
+			}
+		})
+	}
+}
+
+// newVolumes returns a matrix of volumes with a metric for each name, set for the first n instances,
+// and the labels in export options
+func newVolumes(t *testing.T, instances int, metrics map[string]int, labels ...string) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Rest", "volume", "volume")
+	options := node.NewS("export_options")
+	keys := options.NewChildS("instance_keys", "")
+	for _, label := range labels {
+		keys.NewChildS("", label)
+	}
+	data.SetExportOptions(options)
+	for i := range instances {
+		_, _ = data.NewInstance("vol" + strconv.Itoa(i))
+	}
+	for name, n := range metrics {
+		metric, _ := data.NewMetricFloat64(name)
+		for i := range n {
+			_ = metric.SetValueFloat64(data.GetInstance("vol"+strconv.Itoa(i)), 1)
+		}
+	}
+	return data
+}
+
+func TestShadow_Compare(t *testing.T) {
+	s := &Shadow{Template: "volume_next.yaml", Prefix: "shadow"}
+	shadow := summarize([]*matrix.Matrix{newVolumes(t, 3, map[string]int{"size": 3, "read_ops": 3, "new_metric": 2}, "volume", "svm", "aggr")})
+	if _, ok := s.compare(shadow); ok {
+		t.Fatalf("compare() before the current template is polled got=true, want=false")
+	}
+
+	s.current = summarize([]*matrix.Matrix{newVolumes(t, 3, map[string]int{"size": 3, "read_ops": 2, "old_metric": 3}, "volume", "svm", "node")})
+	diff, ok := s.compare(shadow)
+	if !ok {
+		t.Fatalf("compare() got=false, want=true")
+	}
+	want := ShadowDiff{
+		Added:   []string{"volume_new_metric", "volume{aggr}"},
+		Removed: []string{"volume_old_metric", "volume{node}"},
+		Changed: []string{"volume_read_ops 2 => 3"},
+	}
+	if !slices.Equal(diff.Added, want.Added) || !slices.Equal(diff.Removed, want.Removed) || !slices.Equal(diff.Changed, want.Changed) {
+		t.Errorf("compare() got=%+v, want=%+v", diff, want)
+	}
+
+	if !s.changed(diff) {
+		t.Errorf("changed() of the first diff got=false, want=true")
+	}
+	if s.changed(diff) {
+		t.Errorf("changed() of the same diff got=true, want=false")
+	}
+}
+
+func TestShadow_Rename(t *testing.T) {
+	data := newVolumes(t, 1, map[string]int{"size": 1}, "volume")
+	hidden := newVolumes(t, 1, map[string]int{"size": 1}, "volume")
+	hidden.SetExportable(false)
+
+	s := &Shadow{Prefix: "shadow"}
+	renamed := s.rename([]*matrix.Matrix{data, hidden})
+	if len(renamed) != 1 {
+		t.Fatalf("rename() got %d matrices, want 1", len(renamed))
+	}
+	if renamed[0].Object != "shadow_volume" || renamed[0].UUID != "shadow.Rest" {
+		t.Errorf("rename() got object=%s uuid=%s, want object=shadow_volume uuid=shadow.Rest", renamed[0].Object, renamed[0].UUID)
+	}
+	if data.Object != "volume" {
+		t.Errorf("rename() changed the object of the results to %s", data.Object)
+	}
+
+	// a shadow with its own exporter exports the results unchanged
+	s = &Shadow{Exporter: "prom-next"}
+	if renamed := s.rename([]*matrix.Matrix{data}); renamed[0] != data {
+		t.Errorf("rename() without prefix copied the results")
+	}
+}
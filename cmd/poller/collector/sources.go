@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strings"
+)
+
+// SourceHarvest is the source of the parameters that no layer sets, e.g. poller_name, which Harvest adds
+const SourceHarvest = "harvest"
+
+// Source is a layer of the parameters of a collector, like the poller section of harvest.yml or a template.
+// Layers are listed in the order they are merged, so a later layer overrides an earlier one
+type Source struct {
+	Name   string
+	Params *node.Node
+}
+
+// recordSource adds a layer to the sources of the collector, when the sources are recorded
+func (c *AbstractCollector) recordSource(name string, params *node.Node) {
+	if c.Sources == nil {
+		return
+	}
+	c.Sources = append(c.Sources, Source{Name: name, Params: params.Copy()})
+}
+
+// ParamSources returns the source of each parameter of params, by its path, e.g. schedule.data.
+// The source of a parameter is the last layer whose value is the resolved value. A list that no layer
+// has as resolved, like the union of the objects of two templates, has the names of all the layers that set it,
+// joined with " + "
+func ParamSources(params *node.Node, sources []Source) map[string]string {
+	_, resolved := FlattenParams(params)
+	layers := make([]map[string][]string, len(sources))
+	for i, s := range sources {
+		_, layers[i] = FlattenParams(s.Params)
+	}
+
+	result := make(map[string]string, len(resolved))
+	for path, values := range resolved {
+		var setBy []string
+		for i := len(sources) - 1; i >= 0; i-- {
+			layerValues, ok := layers[i][path]
+			if !ok {
+				continue
+			}
+			if slices.Equal(layerValues, values) {
+				setBy = []string{sources[i].Name}
+				break
+			}
+			setBy = append([]string{sources[i].Name}, setBy...)
+		}
+		if len(setBy) == 0 {
+			result[path] = SourceHarvest
+			continue
+		}
+		result[path] = strings.Join(setBy, " + ")
+	}
+	return result
+}
+
+// FlattenParams returns the paths of the parameters of n, in the order of the tree, and their values by path.
+// A list of values, like the counters of a template, is one parameter
+func FlattenParams(n *node.Node) ([]string, map[string][]string) {
+	var paths []string
+	values := make(map[string][]string)
+	var walk func(n *node.Node, path string)
+	walk = func(n *node.Node, path string) {
+		for _, child := range n.GetChildren() {
+			name := child.GetNameS()
+			childPath := name
+			if path != "" {
+				childPath = path + "." + name
+			}
+			switch {
+			case len(child.GetChildren()) == 0:
+				addParam(&paths, values, childPath, child.GetContentS())
+			case isList(child):
+				for _, item := range child.GetChildren() {
+					addParam(&paths, values, childPath, item.GetContentS())
+				}
+			default:
+				walk(child, childPath)
+			}
+		}
+	}
+	walk(n, "")
+	return paths, values
+}
+
+func addParam(paths *[]string, values map[string][]string, path string, value string) {
+	if _, ok := values[path]; !ok {
+		*paths = append(*paths, path)
+	}
+	values[path] = append(values[path], value)
+}
+
+// isList returns true when the children of n are values, without names of their own
+func isList(n *node.Node) bool {
+	for _, child := range n.GetChildren() {
+		if len(child.GetChildren()) > 0 {
+			return false
+		}
+		if name := child.GetNameS(); name != "" && name != child.GetContentS() {
+			return false
+		}
+	}
+	return true
+}
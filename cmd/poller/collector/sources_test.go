@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+)
+
+func loadParams(t *testing.T, yaml string) *node.Node {
+	t.Helper()
+	n, err := tree.LoadYaml([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestParamSources(t *testing.T) {
+	poller := loadParams(t, `
+addr: 10.0.0.1
+client_timeout: 1m
+`)
+	collectorTemplate := loadParams(t, `
+client_timeout: 30s
+schedule:
+  - data: 3m
+objects:
+  Volume: volume.yaml
+`)
+	customTemplate := loadParams(t, `
+objects:
+  Qtree: qtree.yaml
+`)
+	objectTemplate := loadParams(t, `
+query: api/storage/volumes
+counters:
+  - ^^name => volume
+  - size
+`)
+
+	params := collectorTemplate.Copy()
+	params.Merge(customTemplate, []string{""})
+	params.Union(objectTemplate)
+	// like Union2, the poller only fills the parameters that the templates do not set
+	params.NewChildS("addr", "10.0.0.1")
+	params.NewChildS("poller_name", "cluster-01")
+
+	sources := []Source{
+		{Name: "harvest.yml", Params: poller},
+		{Name: "default.yaml", Params: collectorTemplate},
+		{Name: "custom.yaml", Params: customTemplate},
+		{Name: "volume.yaml", Params: objectTemplate},
+	}
+	got := ParamSources(params, sources)
+	want := map[string]string{
+		"addr":           "harvest.yml",
+		"client_timeout": "default.yaml",
+		"schedule.data":  "default.yaml",
+		"objects.Volume": "default.yaml",
+		"objects.Qtree":  "custom.yaml",
+		"query":          "volume.yaml",
+		"counters":       "volume.yaml",
+		"poller_name":    SourceHarvest,
+	}
+	for path, source := range want {
+		if got[path] != source {
+			t.Errorf("source of %s got=%q, want=%q", path, got[path], source)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("ParamSources() got %d paths, want %d: %v", len(got), len(want), got)
+	}
+}
+
+func TestFlattenParams(t *testing.T) {
+	paths, values := FlattenParams(loadParams(t, `
+schedule:
+  - data: 3m
+counters:
+  - ^^name => volume
+  - size
+`))
+	if len(paths) != 2 || paths[0] != "schedule.data" || paths[1] != "counters" {
+		t.Errorf("FlattenParams() paths got=%v, want=[schedule.data counters]", paths)
+	}
+	if got := values["counters"]; len(got) != 2 || got[1] != "size" {
+		t.Errorf("FlattenParams() counters got=%v, want=[^^name => volume size]", got)
+	}
+}
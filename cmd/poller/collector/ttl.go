@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"strconv"
+)
+
+// instanceTTL removes the instances of ephemeral objects, e.g. snapshots or LUN clones, once they are missing from
+// a number of data polls, without waiting for the instance poll of the collector to remove them.
+// An instance is missing from a poll when the collector did not refresh it, i.e. none of its metrics has a value.
+// Instances that plugins or sampling hide are still refreshed, so they are not missing.
+// Instances that were never seen are left to the collector, e.g. perf instances before their first rate is cooked
+type instanceTTL struct {
+	polls   int
+	missing map[string]int // number of polls each instance has been missing from since it was last seen
+}
+
+// parseInstanceTTL returns the "instance_ttl" of the template, or nil when the template does not age out instances.
+// instance_ttl is the number of consecutive data polls an instance can be missing from before it is removed
+func parseInstanceTTL(params *node.Node) (*instanceTTL, error) {
+	value := params.GetChildContentS("instance_ttl")
+	if value == "" {
+		return nil, nil
+	}
+	polls, err := strconv.Atoi(value)
+	if err != nil || polls < 1 {
+		return nil, fmt.Errorf("instance_ttl: must be a positive number of polls [%s]", value)
+	}
+	return &instanceTTL{polls: polls, missing: make(map[string]int)}, nil
+}
+
+// apply removes the instances of mat that have been missing from the last t.polls polls, and returns their keys
+func (t *instanceTTL) apply(mat *matrix.Matrix) []string {
+	var expired []string
+	for key, instance := range mat.GetInstances() {
+		if seen(mat, instance) {
+			t.missing[key] = 0
+			continue
+		}
+		n, ok := t.missing[key]
+		if !ok {
+			continue
+		}
+		t.missing[key] = n + 1
+		if n+1 >= t.polls {
+			expired = append(expired, key)
+		}
+	}
+	for _, key := range expired {
+		mat.RemoveInstance(key)
+		delete(t.missing, key)
+	}
+	// forget the instances that the collector removed itself
+	for key := range t.missing {
+		if mat.GetInstance(key) == nil {
+			delete(t.missing, key)
+		}
+	}
+	return expired
+}
+
+// seen returns true when the collector refreshed instance in this poll. Collectors reset the values of their matrix
+// before each data poll, and the TTL is applied before sampling and plugins, so the values that are set are the ones
+// the collector polled. Exportability is not used, since plugins and sampling hide instances that are still present
+func seen(mat *matrix.Matrix, instance *matrix.Instance) bool {
+	for _, metric := range mat.GetMetrics() {
+		if _, ok := metric.GetValueFloat64(instance); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyInstanceTTL removes the expired instances of the collector's object and records their number in the metadata
+// of the data task. The instances are removed from the cache of the collector too, otherwise collectors that clone
+// their cache on each poll, e.g. the perf collectors, would add them back
+func (c *AbstractCollector) applyInstanceTTL(data map[string]*matrix.Matrix) {
+	t := c.instanceTTL
+	if t == nil {
+		return
+	}
+	mat, ok := data[c.Object]
+	if !ok {
+		return
+	}
+	expired := t.apply(mat)
+	if cached := c.Matrix[c.Object]; cached != nil && cached != mat {
+		for _, key := range expired {
+			cached.RemoveInstance(key)
+		}
+	}
+	_ = c.Metadata.LazySetValueUint64("expired_instances", "data", uint64(len(expired)))
+	if len(expired) > 0 {
+		c.Logger.Debug().Int("expired", len(expired)).Int("instance_ttl", t.polls).Msg("Removed expired instances")
+	}
+}
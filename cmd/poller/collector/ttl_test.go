@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"slices"
+	"testing"
+)
+
+func TestParseInstanceTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no ttl", yaml: "object: snapshot\n", wantNil: true},
+		{name: "polls", yaml: "instance_ttl: 3\n"},
+		{name: "zero", yaml: "instance_ttl: 0\n", wantErr: true},
+		{name: "duration", yaml: "instance_ttl: 10m\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("failed to load yaml err=%v", err)
+			}
+			ttl, err := parseInstanceTTL(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInstanceTTL() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err == nil && (ttl == nil) != tt.wantNil {
+				t.Errorf("parseInstanceTTL() nil=%t, wantNil=%t", ttl == nil, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestInstanceTTL_Apply(t *testing.T) {
+	ttl := &instanceTTL{polls: 2, missing: make(map[string]int)}
+
+	mat := matrix.New("ZapiPerf", "snapshot", "snapshot")
+	ops, _ := mat.NewMetricFloat64("ops")
+	for _, key := range []string{"snap1", "snap2", "snap3"} {
+		_, _ = mat.NewInstance(key)
+	}
+
+	// poll sets the values of the instances in seen, like a data poll
+	poll := func(seen ...string) []string {
+		mat.Reset()
+		for key, instance := range mat.GetInstances() {
+			if slices.Contains(seen, key) {
+				_ = ops.SetValueFloat64(instance, 1)
+			}
+		}
+		expired := ttl.apply(mat)
+		slices.Sort(expired)
+		return expired
+	}
+
+	tests := []struct {
+		name string
+		seen []string
+		want []string
+	}{
+		// snap3 was never seen, so it is left to the collector
+		{name: "first", seen: []string{"snap1", "snap2"}},
+		{name: "snap2 missing once", seen: []string{"snap1"}},
+		{name: "snap2 missing twice", seen: []string{"snap1"}, want: []string{"snap2"}},
+		{name: "snap1 missing once", seen: nil},
+		{name: "snap1 seen again", seen: []string{"snap1"}},
+		{name: "snap1 missing again", seen: nil},
+		{name: "snap1 missing twice", seen: nil, want: []string{"snap1"}},
+	}
+	for _, tt := range tests {
+		if got := poll(tt.seen...); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expired got=%v, want=%v", tt.name, got, tt.want)
+		}
+	}
+	if got := mat.GetInstanceKeys(); !slices.Equal(got, []string{"snap3"}) {
+		t.Errorf("instances got=%v, want=[snap3]", got)
+	}
+	if len(ttl.missing) != 0 {
+		t.Errorf("missing got=%v, want none", ttl.missing)
+	}
+}
+
+func TestInstanceTTL_ApplyHidden(t *testing.T) {
+	ttl := &instanceTTL{polls: 2, missing: make(map[string]int)}
+
+	mat := matrix.New("Rest", "snapshot", "snapshot")
+	ops, _ := mat.NewMetricFloat64("ops")
+	snap1, _ := mat.NewInstance("snap1")
+
+	for range 4 {
+		mat.Reset()
+		_ = ops.SetValueFloat64(snap1, 1)
+		if expired := ttl.apply(mat); len(expired) != 0 {
+			t.Fatalf("expired got=%v, want none for an instance that a plugin hides", expired)
+		}
+		// a plugin hides the instance after the collector polled it
+		snap1.SetExportable(false)
+	}
+	if mat.GetInstance("snap1") == nil {
+		t.Error("snap1 want kept")
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"cmp"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"time"
+)
+
+// WarmUp staggers the first polls of collectors over window, so that a poller that starts does not poll all
+// of its objects at once. Collectors are ordered by their weight, the number of counters of their template,
+// and the heaviest collectors poll last. The jitter of a template is added to its delay
+func WarmUp(collectors []Collector, window time.Duration) {
+	if window <= 0 || len(collectors) == 0 {
+		return
+	}
+	delays := warmUpDelays(collectors, window)
+	for _, c := range collectors {
+		d := delays[c]
+		c.GetSchedule().Delay(d)
+		c.GetLogger().Debug().Str("delay", d.String()).Msg("warm up")
+	}
+}
+
+// warmUpDelays returns the delay of the first poll of each collector, spread evenly over window,
+// lightest collectors first. Collectors of the same weight are ordered by name and object
+func warmUpDelays(collectors []Collector, window time.Duration) map[Collector]time.Duration {
+	type weighted struct {
+		c      Collector
+		weight int
+	}
+	ordered := make([]weighted, 0, len(collectors))
+	for _, c := range collectors {
+		ordered = append(ordered, weighted{c: c, weight: countCounters(c.GetParams())})
+	}
+	slices.SortStableFunc(ordered, func(a, b weighted) int {
+		return cmp.Or(
+			cmp.Compare(a.weight, b.weight),
+			cmp.Compare(a.c.GetName(), b.c.GetName()),
+			cmp.Compare(a.c.GetObject(), b.c.GetObject()),
+		)
+	})
+
+	delays := make(map[Collector]time.Duration, len(ordered))
+	step := window / time.Duration(len(ordered))
+	for i, w := range ordered {
+		delays[w.c] = time.Duration(i) * step
+	}
+	return delays
+}
+
+// countCounters returns the number of counters of a template, including the counters of its endpoints
+func countCounters(params *node.Node) int {
+	if params == nil {
+		return 0
+	}
+	n := 0
+	for _, child := range params.GetChildren() {
+		if child.GetNameS() == "counters" {
+			n += countLeaves(child)
+		} else {
+			n += countCounters(child)
+		}
+	}
+	return n
+}
+
+func countLeaves(n *node.Node) int {
+	children := n.GetChildren()
+	if len(children) == 0 {
+		return 1
+	}
+	leaves := 0
+	for _, child := range children {
+		if name := child.GetNameS(); name == "filter" || name == "hidden_fields" {
+			continue
+		}
+		leaves += countLeaves(child)
+	}
+	return leaves
+}
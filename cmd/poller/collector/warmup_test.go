@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+	"time"
+)
+
+type warmUpCollector struct {
+	*AbstractCollector
+}
+
+func (w *warmUpCollector) Init(*AbstractCollector) error {
+	return nil
+}
+
+func newWarmUpCollector(t *testing.T, object string, template string) *warmUpCollector {
+	t.Helper()
+	params, err := tree.LoadYaml([]byte(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &warmUpCollector{AbstractCollector: New("Rest", object, options.New(), params, nil)}
+}
+
+func TestWarmUpDelays(t *testing.T) {
+	volume := newWarmUpCollector(t, "Volume", `
+counters:
+  - ^^name => volume
+  - ^svm.name => svm
+  - space.size => size
+  - filter:
+      - is_constituent=*
+endpoints:
+  - query: api/private/cli/volume/efficiency/stat
+    counters:
+      - ^^volume
+      - num_compress_attempts
+`)
+	lock := newWarmUpCollector(t, "Lock", `
+counters:
+  - ^^uuid => uuid
+  - ^state => state
+`)
+	node := newWarmUpCollector(t, "Node", `
+counters:
+  - ^^name => node
+  - ^state => state
+`)
+
+	delays := warmUpDelays([]Collector{volume, node, lock}, 3*time.Minute)
+
+	want := map[Collector]time.Duration{lock: 0, node: time.Minute, volume: 2 * time.Minute}
+	for c, d := range want {
+		if delays[c] != d {
+			t.Errorf("warmUpDelays() object=%s got=%s, want=%s", c.GetObject(), delays[c], d)
+		}
+	}
+}
+
+func TestCountCounters(t *testing.T) {
+	c := newWarmUpCollector(t, "Volume", `
+counters:
+  volume-attributes:
+    - volume-id-attributes:
+        - ^^name => volume
+        - ^^owning-vserver-name => svm
+    - volume-space-attributes:
+        - size
+hidden_fields:
+  - is_constituent
+`)
+	if got := countCounters(c.GetParams()); got != 3 {
+		t.Errorf("countCounters() got=%d, want=3", got)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/units"
+)
+
+// ConvertUnits returns data when none of its metrics have a unit that converts to a canonical unit,
+// otherwise a clone of data where those metrics are in canonical units, with the suffix of the unit in their names.
+// Histograms are not converted, since their values are counts
+func ConvertUnits(data *matrix.Matrix) *matrix.Matrix {
+	if !hasConversions(data) {
+		return data
+	}
+
+	converted := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	renamed := make(map[string]string)
+
+	for _, metric := range converted.GetMetrics() {
+		c, ok := convertible(metric)
+		if !ok {
+			continue
+		}
+		name := metric.GetName()
+		renamed[name] = convertMetric(metric, c)
+	}
+
+	renameMetricKeys(converted, renamed)
+	return converted
+}
+
+// convertMetric converts the values of metric to the canonical unit of c, and returns its name with the suffix of the unit
+func convertMetric(metric *matrix.Metric, c units.Conversion) string {
+	if c.Factor != 1 {
+		values := metric.GetValues()
+		for i, ok := range metric.GetRecords() {
+			if ok {
+				values[i] *= c.Factor
+			}
+		}
+	}
+	name := units.Name(metric.GetName(), c.Unit)
+	metric.SetName(name)
+	metric.SetUnit(c.Unit)
+	return name
+}
+
+// renameMetricKeys renames the metric keys of data that are defined for renamed metrics, metric keys follow the
+// metrics they are defined for
+func renameMetricKeys(data *matrix.Matrix, renamed map[string]string) {
+	if data.GetExportOptions().GetChildS("metric_keys") == nil {
+		return
+	}
+	options := data.GetExportOptions().Copy()
+	for _, keys := range options.GetChildS("metric_keys").GetChildren() {
+		if name, ok := renamed[keys.GetNameS()]; ok {
+			keys.SetNameS(name)
+		}
+	}
+	data.SetExportOptions(options)
+}
+
+func hasConversions(data *matrix.Matrix) bool {
+	for _, metric := range data.GetMetrics() {
+		if _, ok := convertible(metric); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func convertible(metric *matrix.Metric) (units.Conversion, bool) {
+	if !metric.IsExportable() || metric.IsHistogram() || metric.GetUnit() == "" {
+		return units.Conversion{}, false
+	}
+	return units.Canonical(metric.GetUnit())
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	options, err := tree.LoadYaml([]byte(`
+metric_keys:
+  read_latency:
+    - client_ip
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := matrix.New("volume", "volume", "volume")
+	data.SetExportOptions(options)
+	instance, _ := data.NewInstance("A")
+
+	tests := []struct {
+		metric    string
+		unit      string
+		histogram bool
+		value     float64
+		wantName  string
+		wantValue float64
+	}{
+		{metric: "read_latency", unit: "microsec", value: 1500, wantName: "read_latency_seconds", wantValue: 0.0015},
+		{metric: "read_data", unit: "kb_per_sec", value: 2, wantName: "read_data_bytes_per_second", wantValue: 2048},
+		{metric: "size_bytes", unit: "b", value: 10, wantName: "size_bytes", wantValue: 10},
+		{metric: "read_ops", unit: "per_sec", value: 3, wantName: "read_ops", wantValue: 3},
+		{metric: "latency_hist", unit: "microsec", histogram: true, value: 4, wantName: "latency_hist", wantValue: 4},
+	}
+	for _, tt := range tests {
+		m, _ := data.NewMetricFloat64(tt.metric)
+		m.SetUnit(tt.unit)
+		m.SetHistogram(tt.histogram)
+		_ = m.SetValueFloat64(instance, tt.value)
+	}
+
+	converted := ConvertUnits(data)
+	if converted == data {
+		t.Fatalf("ConvertUnits() returned the original matrix")
+	}
+	for _, tt := range tests {
+		m := converted.GetMetric(tt.metric)
+		if m.GetName() != tt.wantName {
+			t.Errorf("%s name got=%s, want=%s", tt.metric, m.GetName(), tt.wantName)
+		}
+		if got, _ := m.GetValueFloat64(converted.GetInstance("A")); got != tt.wantValue {
+			t.Errorf("%s value got=%f, want=%f", tt.metric, got, tt.wantValue)
+		}
+		if original, _ := data.GetMetric(tt.metric).GetValueFloat64(instance); original != tt.value {
+			t.Errorf("%s original value changed got=%f, want=%f", tt.metric, original, tt.value)
+		}
+	}
+	if keys := matrix.MetricKeys(converted.GetExportOptions()); len(keys["read_latency_seconds"]) != 1 {
+		t.Errorf("metric keys should follow the renamed metric got=%v", keys)
+	}
+	if keys := matrix.MetricKeys(data.GetExportOptions()); len(keys["read_latency"]) != 1 {
+		t.Errorf("metric keys of the original matrix changed got=%v", keys)
+	}
+
+	unchanged := matrix.New("volume", "volume", "volume")
+	m, _ := unchanged.NewMetricFloat64("read_ops")
+	m.SetUnit("per_sec")
+	if ConvertUnits(unchanged) != unchanged {
+		t.Errorf("ConvertUnits() should return the original matrix when there is nothing to convert")
+	}
+}
@@ -9,12 +9,14 @@ functions.
 package exporter
 
 import (
+	"fmt"
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/logging"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"strconv"
 	"sync"
+	"time"
 )
 
 // Exporter defines the required attributes of an exporter
@@ -23,11 +25,18 @@ type Exporter interface {
 	Init() error      // initialize exporter
 	GetClass() string // the class of the exporter, e.g. Prometheus, InfluxDB
 	// GetName is different from Class, since we can have multiple instances of the same Class
-	GetName() string                      // the name of the exporter instance
-	GetExportCount() uint64               // return and reset number of exported data points, used by Poller to keep stats
-	AddExportCount(uint64)                // add count to the export count, called by the exporter itself
-	GetStatus() (uint8, string, string)   // return current state of the exporter
-	Export(*matrix.Matrix) (Stats, error) // render data in matrix to the desired format and emit
+	GetName() string                         // the name of the exporter instance
+	GetExportCount() uint64                  // return and reset number of exported data points, used by Poller to keep stats
+	AddExportCount(uint64)                   // add count to the export count, called by the exporter itself
+	GetStatus() (uint8, string, string)      // return current state of the exporter
+	GetExportTimeout() time.Duration         // how long a collector waits for the export of a poll
+	Export(*matrix.Matrix) (Stats, error)    // render data in matrix to the desired format and emit
+	Route(*matrix.Matrix) *matrix.Matrix     // return the part of the matrix that is routed to this exporter, or nil
+	Normalize(*matrix.Matrix) *matrix.Matrix // return the matrix with normalized label values
+	Convert(*matrix.Matrix) *matrix.Matrix   // return the matrix with metrics in canonical units, when enabled
+	Lint(*matrix.Matrix) *matrix.Matrix      // return the matrix with names that follow the naming conventions, when enabled
+	// return the matrices to export for the matrix of a collector, with provenance labels or info, when enabled
+	Provenance(*matrix.Matrix, string) []*matrix.Matrix
 	// this is the only function that should be implemented by "real" exporters
 }
 
@@ -38,6 +47,9 @@ var status = [3]string{
 	"failed",
 }
 
+// DefaultExportTimeout is how long a collector waits for the export of a poll, when the exporter has no export_timeout
+const DefaultExportTimeout = 30 * time.Second
+
 // Stats capture the number of instances and metrics exported
 type Stats struct {
 	InstancesExported uint64
@@ -58,6 +70,10 @@ type AbstractExporter struct {
 	*sync.Mutex                // mutex to block exporter during export
 	exportCount uint64         // atomic
 	countMux    *sync.Mutex
+	router      *Router
+	normalizer  *Normalizer
+	linter      *Linter
+	timeout     time.Duration // export_timeout
 }
 
 // New creates an AbstractExporter instance with the given arguments:
@@ -90,6 +106,26 @@ func New(c, n string, o *options.Options, p conf.Exporter, params *conf.Poller)
 
 // InitAbc initializes AbstractExporter
 func (e *AbstractExporter) InitAbc() error {
+	var err error
+	if e.router, err = NewRouter(e.Params.Routes); err != nil {
+		return err
+	}
+	if e.normalizer, err = NewNormalizer(e.Params.Normalize); err != nil {
+		return err
+	}
+	if e.linter, err = NewLinter(e.Params.NamingLint, e.Logger); err != nil {
+		return err
+	}
+	if err := checkProvenance(e.Params.Provenance); err != nil {
+		return err
+	}
+	e.timeout = DefaultExportTimeout
+	if e.Params.ExportTimeout != "" {
+		if e.timeout, err = time.ParseDuration(e.Params.ExportTimeout); err != nil || e.timeout <= 0 {
+			return fmt.Errorf("invalid export_timeout %q, must be a positive duration like 30s", e.Params.ExportTimeout)
+		}
+	}
+
 	e.Metadata.SetGlobalLabel("hostname", e.Options.Hostname)
 	e.Metadata.SetGlobalLabel("version", e.Options.Version)
 	e.Metadata.SetGlobalLabel("poller", e.Options.Poller)
@@ -102,6 +138,12 @@ func (e *AbstractExporter) InitAbc() error {
 	if _, err := e.Metadata.NewMetricUint64("count"); err != nil {
 		return err
 	}
+	// only set by exporters with naming_lint
+	if e.linter != nil {
+		if _, err := e.Metadata.NewMetricUint64("naming_violations"); err != nil {
+			return err
+		}
+	}
 
 	if instance, err := e.Metadata.NewInstance("export"); err == nil {
 		instance.SetLabel("task", "export")
@@ -147,6 +189,47 @@ func (e *AbstractExporter) AddExportCount(n uint64) {
 	e.countMux.Unlock()
 }
 
+// Route returns the part of data that matches the exporter's routes or nil when nothing matches
+func (e *AbstractExporter) Route(data *matrix.Matrix) *matrix.Matrix {
+	return e.router.Route(data)
+}
+
+// Normalize returns data with the label values normalized by the exporter's normalize rules
+func (e *AbstractExporter) Normalize(data *matrix.Matrix) *matrix.Matrix {
+	return e.normalizer.Normalize(data)
+}
+
+// Convert returns data with metrics converted to canonical units when the exporter has convert_units enabled
+func (e *AbstractExporter) Convert(data *matrix.Matrix) *matrix.Matrix {
+	if !e.Params.ConvertUnits {
+		return data
+	}
+	return ConvertUnits(data)
+}
+
+// Lint reports the metric names of data that do not follow the naming conventions when the exporter has naming_lint,
+// and returns data, or data with names that follow them in rewrite mode
+func (e *AbstractExporter) Lint(data *matrix.Matrix) *matrix.Matrix {
+	if e.linter == nil {
+		return data
+	}
+	data = e.linter.Lint(data)
+	e.Lock()
+	defer e.Unlock()
+	if err := e.Metadata.LazySetValueUint64("naming_violations", "export", uint64(e.linter.Violations())); err != nil {
+		e.Logger.Error().Err(err).Msg("error")
+	}
+	return data
+}
+
+// GetExportTimeout returns how long a collector waits for the export of a poll
+func (e *AbstractExporter) GetExportTimeout() time.Duration {
+	if e.timeout <= 0 {
+		return DefaultExportTimeout
+	}
+	return e.timeout
+}
+
 // GetStatus returns current state of exporter
 func (e *AbstractExporter) GetStatus() (uint8, string, string) {
 	return e.Status, status[e.Status], e.Message
@@ -0,0 +1,167 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package exporter
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/units"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	LintReport  = "report"
+	LintRewrite = "rewrite"
+)
+
+// invalidName matches the characters that the Prometheus naming conventions do not allow in metric names.
+// Colons are valid, but reserved for recording rules
+var invalidName = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Linter checks the names of exported metrics against the Prometheus naming conventions: lowercase, only letters,
+// digits, and underscores, and in base units with the unit as suffix, e.g. _seconds.
+// It reports each name that does not follow them once, or, in rewrite mode, exports the metrics with names that do.
+// Names that match an allowed pattern, e.g. legacy names that dashboards use, are exported as is.
+// A nil Linter does not check anything
+type Linter struct {
+	rewrite  bool
+	allow    []string
+	logger   *logging.Logger
+	mu       sync.Mutex
+	reported map[string]bool // names that did not follow the conventions
+}
+
+// NewLinter validates the naming_lint of an exporter and returns its Linter, or nil when lint is nil
+func NewLinter(lint *conf.NamingLint, logger *logging.Logger) (*Linter, error) {
+	if lint == nil {
+		return nil, nil
+	}
+	l := &Linter{allow: lint.Allow, logger: logger, reported: make(map[string]bool)}
+	switch lint.Mode {
+	case "", LintReport:
+	case LintRewrite:
+		l.rewrite = true
+	default:
+		return nil, fmt.Errorf("invalid naming_lint mode %q, must be %s or %s", lint.Mode, LintReport, LintRewrite)
+	}
+	for _, pattern := range lint.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid naming_lint allow %q: %w", pattern, err)
+		}
+	}
+	return l, nil
+}
+
+// Violations returns the number of names that did not follow the conventions since the exporter started
+func (l *Linter) Violations() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.reported)
+}
+
+// Lint reports the names of data that do not follow the conventions, and returns data.
+// In rewrite mode, it returns data when all its names follow the conventions, otherwise a clone of data with metrics
+// in base units and names that follow them
+func (l *Linter) Lint(data *matrix.Matrix) *matrix.Matrix {
+	if l == nil {
+		return data
+	}
+	violated := false
+	for _, metric := range data.GetMetrics() {
+		if !metric.IsExportable() {
+			continue
+		}
+		name := data.Object + "_" + metric.GetName()
+		if l.allowed(name) {
+			continue
+		}
+		if reasons := nameViolations(name, metric); len(reasons) > 0 {
+			l.report(name, reasons)
+			violated = true
+		}
+	}
+	if !violated || !l.rewrite {
+		return data
+	}
+
+	linted := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	linted.Object = sanitizeName(data.Object)
+	renamed := make(map[string]string)
+	for _, metric := range linted.GetMetrics() {
+		if !metric.IsExportable() || l.allowed(data.Object+"_"+metric.GetName()) {
+			continue
+		}
+		name := metric.GetName()
+		if c, ok := convertible(metric); ok {
+			convertMetric(metric, c)
+		}
+		metric.SetName(sanitizeName(metric.GetName()))
+		if metric.GetName() != name {
+			renamed[name] = metric.GetName()
+		}
+	}
+	renameMetricKeys(linted, renamed)
+	return linted
+}
+
+func (l *Linter) allowed(name string) bool {
+	return slices.ContainsFunc(l.allow, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
+
+// report logs the violations of name the first time they are found
+func (l *Linter) report(name string, reasons []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.reported[name] {
+		return
+	}
+	l.reported[name] = true
+	msg := "Metric name does not follow the Prometheus naming conventions"
+	if l.rewrite {
+		msg += ", it is rewritten"
+	}
+	l.logger.Warn().Str("metric", name).Strs("reasons", reasons).Msg(msg)
+}
+
+// nameViolations returns the reasons name, the name of metric with its object, does not follow the conventions
+func nameViolations(name string, metric *matrix.Metric) []string {
+	var reasons []string
+	if strings.ToLower(name) != name {
+		reasons = append(reasons, "uppercase")
+	}
+	if invalidName.MatchString(strings.ToLower(name)) {
+		reasons = append(reasons, "characters other than letters, digits, and underscores")
+	}
+	// histograms are counts of samples, their unit is the unit of their buckets
+	if metric.IsHistogram() {
+		return reasons
+	}
+	if c, ok := units.Canonical(metric.GetUnit()); ok {
+		switch {
+		case c.Factor != 1:
+			reasons = append(reasons, "unit "+metric.GetUnit()+" is not a base unit")
+		case units.Name(name, c.Unit) != name:
+			reasons = append(reasons, "no unit suffix")
+		}
+	}
+	return reasons
+}
+
+// sanitizeName returns name in lowercase, with the characters that are not allowed replaced with underscores
+func sanitizeName(name string) string {
+	return invalidName.ReplaceAllString(strings.ToLower(name), "_")
+}
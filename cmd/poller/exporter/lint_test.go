@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"testing"
+)
+
+func TestNewLinter(t *testing.T) {
+	tests := []struct {
+		name    string
+		lint    *conf.NamingLint
+		wantErr bool
+	}{
+		{name: "disabled", lint: nil},
+		{name: "default mode", lint: &conf.NamingLint{}},
+		{name: "rewrite", lint: &conf.NamingLint{Mode: "rewrite", Allow: []string{"volume_*"}}},
+		{name: "invalid mode", lint: &conf.NamingLint{Mode: "fix"}, wantErr: true},
+		{name: "invalid pattern", lint: &conf.NamingLint{Allow: []string{"volume_["}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLinter(tt.lint, logging.Get())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLinter() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if tt.lint == nil && l != nil {
+				t.Errorf("NewLinter() of no naming_lint should return nil")
+			}
+		})
+	}
+}
+
+func lintMatrix(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	options, err := tree.LoadYaml([]byte(`
+metric_keys:
+  Read.Latency:
+    - client_ip
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := matrix.New("volume", "volume", "volume")
+	data.SetExportOptions(options)
+	instance, _ := data.NewInstance("A")
+	for _, m := range []struct {
+		name  string
+		unit  string
+		value float64
+	}{
+		{name: "Read.Latency", unit: "microsec", value: 1500},
+		{name: "avg_latency", unit: "microsec", value: 2000},
+		{name: "size", unit: "b", value: 10},
+		{name: "read_ops", unit: "per_sec", value: 3},
+	} {
+		metric, _ := data.NewMetricFloat64(m.name)
+		metric.SetUnit(m.unit)
+		_ = metric.SetValueFloat64(instance, m.value)
+	}
+	return data
+}
+
+func TestLinter_Lint(t *testing.T) {
+	report, _ := NewLinter(&conf.NamingLint{Allow: []string{"volume_avg_*"}}, logging.Get())
+	data := lintMatrix(t)
+	if report.Lint(data) != data {
+		t.Errorf("Lint() in report mode should return the original matrix")
+	}
+	// Read.Latency and size, avg_latency is allowed
+	if got := report.Violations(); got != 2 {
+		t.Errorf("Violations() got=%d, want=2", got)
+	}
+	report.Lint(data)
+	if got := report.Violations(); got != 2 {
+		t.Errorf("Violations() should count each name once got=%d, want=2", got)
+	}
+
+	rewrite, _ := NewLinter(&conf.NamingLint{Mode: "rewrite", Allow: []string{"volume_avg_*"}}, logging.Get())
+	linted := rewrite.Lint(data)
+	if linted == data {
+		t.Fatalf("Lint() in rewrite mode returned the original matrix")
+	}
+	tests := []struct {
+		metric    string
+		wantName  string
+		wantValue float64
+	}{
+		{metric: "Read.Latency", wantName: "read_latency_seconds", wantValue: 0.0015},
+		{metric: "avg_latency", wantName: "avg_latency", wantValue: 2000},
+		{metric: "size", wantName: "size_bytes", wantValue: 10},
+		{metric: "read_ops", wantName: "read_ops", wantValue: 3},
+	}
+	for _, tt := range tests {
+		m := linted.GetMetric(tt.metric)
+		if m.GetName() != tt.wantName {
+			t.Errorf("%s name got=%s, want=%s", tt.metric, m.GetName(), tt.wantName)
+		}
+		if got, _ := m.GetValueFloat64(linted.GetInstance("A")); got != tt.wantValue {
+			t.Errorf("%s value got=%f, want=%f", tt.metric, got, tt.wantValue)
+		}
+		if data.GetMetric(tt.metric).GetName() != tt.metric {
+			t.Errorf("%s original name changed got=%s", tt.metric, data.GetMetric(tt.metric).GetName())
+		}
+	}
+	if keys := matrix.MetricKeys(linted.GetExportOptions()); len(keys["read_latency_seconds"]) != 1 {
+		t.Errorf("metric keys should follow the renamed metric got=%v", keys)
+	}
+
+	valid := matrix.New("volume", "volume", "volume")
+	m, _ := valid.NewMetricFloat64("read_ops")
+	m.SetUnit("per_sec")
+	if rewrite.Lint(valid) != valid {
+		t.Errorf("Lint() should return the original matrix when all names follow the conventions")
+	}
+
+	var disabled *Linter
+	if disabled.Lint(data) != data || disabled.Violations() != 0 {
+		t.Errorf("a nil Linter should not lint")
+	}
+}
+
+func TestNameViolations(t *testing.T) {
+	tests := []struct {
+		name      string
+		unit      string
+		histogram bool
+		want      int
+	}{
+		{name: "volume_read_ops", unit: "per_sec", want: 0},
+		{name: "volume_read_latency_seconds", unit: "sec", want: 0},
+		{name: "volume_size", unit: "b", want: 1},
+		{name: "volume_read_latency", unit: "microsec", want: 1},
+		{name: "volume_Read.Latency", unit: "microsec", want: 3},
+		{name: "volume_read_latency_hist", unit: "microsec", histogram: true, want: 0},
+		{name: "volume_labels", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &matrix.Metric{}
+			m.SetUnit(tt.unit)
+			m.SetHistogram(tt.histogram)
+			if got := nameViolations(tt.name, m); len(got) != tt.want {
+				t.Errorf("nameViolations() got=%v, want %d reasons", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package exporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Normalizer rewrites label values before they are exported, e.g. so that the SVM names
+// of clusters that use different cases end up in the same series.
+// A nil or empty Normalizer does not change anything
+type Normalizer struct {
+	rules []normalizeRule
+}
+
+type normalizeRule struct {
+	conf.Normalize
+	replace *regexp.Regexp
+}
+
+// NewNormalizer validates rules and returns a Normalizer
+func NewNormalizer(rules []conf.Normalize) (*Normalizer, error) {
+	n := &Normalizer{}
+	for i, r := range rules {
+		rule := normalizeRule{Normalize: r}
+		if r.Replace != "" {
+			re, err := regexp.Compile(r.Replace)
+			if err != nil {
+				return nil, fmt.Errorf("invalid normalize[%d] replace %q: %w", i, r.Replace, err)
+			}
+			rule.replace = re
+		}
+		if r.Hash && r.HashKey == "" {
+			return nil, fmt.Errorf("normalize[%d] hash requires a hash_key", i)
+		}
+		n.rules = append(n.rules, rule)
+	}
+	return n, nil
+}
+
+// Normalize returns data when none of its label values change,
+// otherwise a clone of data with normalized label values
+func (n *Normalizer) Normalize(data *matrix.Matrix) *matrix.Matrix {
+	if n == nil || len(n.rules) == 0 || !n.changes(data) {
+		return data
+	}
+
+	normalized := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	normalized.UnshareGlobalLabels()
+	globalLabels := normalized.GetGlobalLabels()
+	for k, v := range globalLabels {
+		globalLabels[k] = n.value(k, v)
+	}
+	for _, instance := range normalized.GetInstances() {
+		for k, v := range instance.GetLabels() {
+			instance.SetLabel(k, n.value(k, v))
+		}
+	}
+	return normalized
+}
+
+// changes returns true when at least one label value of data is not normalized
+func (n *Normalizer) changes(data *matrix.Matrix) bool {
+	for k, v := range data.GetGlobalLabels() {
+		if n.value(k, v) != v {
+			return true
+		}
+	}
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		for k, v := range instance.GetLabels() {
+			if n.value(k, v) != v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// value applies the rules of label to value
+func (n *Normalizer) value(label string, value string) string {
+	for _, r := range n.rules {
+		if len(r.Labels) > 0 && !slices.Contains(r.Labels, label) {
+			continue
+		}
+		if r.NFC && !norm.NFC.IsNormalString(value) {
+			value = norm.NFC.String(value)
+		}
+		if r.Trim {
+			value = strings.Join(strings.Fields(value), " ")
+		}
+		if r.Lowercase {
+			value = strings.ToLower(value)
+		}
+		if r.replace != nil {
+			value = r.replace.ReplaceAllString(value, r.With)
+		}
+		if r.Hash {
+			value = hashValue(r.HashKey, value)
+		}
+	}
+	return value
+}
+
+// hashLen is the number of bytes of the HMAC that are kept, 32 hex characters
+const hashLen = 16
+
+// hashValue returns the truncated HMAC-SHA256 of value with key as hex. Empty values are not hashed,
+// so labels that are not set stay empty
+func hashValue(key string, value string) string {
+	if value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:hashLen])
+}
@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"testing"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     []conf.Normalize
+		svm       string
+		want      string
+		unchanged bool
+	}{
+		{name: "no rules", rules: nil, svm: "SVM1", want: "SVM1", unchanged: true},
+		{name: "already normalized", rules: []conf.Normalize{{Lowercase: true}}, svm: "svm1", want: "svm1", unchanged: true},
+		{name: "lowercase", rules: []conf.Normalize{{Labels: []string{"svm"}, Lowercase: true}}, svm: "SVM1", want: "svm1"},
+		{name: "other label", rules: []conf.Normalize{{Labels: []string{"volume"}, Lowercase: true}}, svm: "SVM1", want: "SVM1", unchanged: true},
+		{name: "trim", rules: []conf.Normalize{{Trim: true}}, svm: "  svm \t 1 ", want: "svm 1"},
+		{name: "nfc", rules: []conf.Normalize{{NFC: true}}, svm: "café", want: "café"},
+		{name: "replace", rules: []conf.Normalize{{Replace: `[^a-z0-9_]`, With: "_"}}, svm: "svm-1.a", want: "svm_1_a"},
+		{name: "rules in order", rules: []conf.Normalize{{Lowercase: true}, {Replace: `[^a-z0-9]`, With: ""}}, svm: "SVM-1", want: "svm1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNormalizer(tt.rules)
+			if err != nil {
+				t.Fatalf("NewNormalizer() error = %v", err)
+			}
+			data := newRouteMatrix(t, "volume", "key")
+			data.GetInstance("key").SetLabel("svm", tt.svm)
+			data.SetGlobalLabel("datacenter", "dc1")
+
+			normalized := n.Normalize(data)
+			if got := normalized.GetInstance("key").GetLabel("svm"); got != tt.want {
+				t.Errorf("Normalize() got=%q, want=%q", got, tt.want)
+			}
+			if (normalized == data) != tt.unchanged {
+				t.Errorf("Normalize() returned the original matrix=%t, want=%t", normalized == data, tt.unchanged)
+			}
+			if got := data.GetInstance("key").GetLabel("svm"); got != tt.svm {
+				t.Errorf("Normalize() modified the original instance label got=%q, want=%q", got, tt.svm)
+			}
+		})
+	}
+}
+
+func TestNormalizer_GlobalLabels(t *testing.T) {
+	n, err := NewNormalizer([]conf.Normalize{{Labels: []string{"datacenter"}, Lowercase: true}})
+	if err != nil {
+		t.Fatalf("NewNormalizer() error = %v", err)
+	}
+	data := newRouteMatrix(t, "volume", "svm1")
+	data.SetGlobalLabel("datacenter", "DC1")
+
+	normalized := n.Normalize(data)
+	if got := normalized.GetGlobalLabels()["datacenter"]; got != "dc1" {
+		t.Errorf("Normalize() got=%q, want=%q", got, "dc1")
+	}
+	if got := data.GetGlobalLabels()["datacenter"]; got != "DC1" {
+		t.Errorf("Normalize() modified the original global label got=%q, want=%q", got, "DC1")
+	}
+}
+
+func TestNewNormalizer_Invalid(t *testing.T) {
+	_, err := NewNormalizer([]conf.Normalize{{Replace: "[a-"}})
+	if err == nil {
+		t.Errorf("NewNormalizer() expected error for invalid replace")
+	}
+}
+
+func TestNormalizer_Hash(t *testing.T) {
+	rules := []conf.Normalize{{Labels: []string{"svm"}, Hash: true, HashKey: "secret"}}
+	n, err := NewNormalizer(rules)
+	if err != nil {
+		t.Fatalf("NewNormalizer() error = %v", err)
+	}
+	other, err := NewNormalizer([]conf.Normalize{{Labels: []string{"svm"}, Hash: true, HashKey: "other"}})
+	if err != nil {
+		t.Fatalf("NewNormalizer() error = %v", err)
+	}
+
+	data := newRouteMatrix(t, "volume", "key")
+	data.GetInstance("key").SetLabel("svm", "svm1")
+	data.GetInstance("key").SetLabel("volume", "vol1")
+
+	got := n.Normalize(data).GetInstance("key").GetLabel("svm")
+	if got == "svm1" || len(got) != 2*hashLen {
+		t.Errorf("Normalize() got=%q, want a hash of %d characters", got, 2*hashLen)
+	}
+	if again := n.Normalize(data).GetInstance("key").GetLabel("svm"); again != got {
+		t.Errorf("Normalize() is not stable got=%q, want=%q", again, got)
+	}
+	if keyed := other.Normalize(data).GetInstance("key").GetLabel("svm"); keyed == got {
+		t.Errorf("Normalize() got the same hash=%q with a different key", keyed)
+	}
+	if volume := n.Normalize(data).GetInstance("key").GetLabel("volume"); volume != "vol1" {
+		t.Errorf("Normalize() hashed other label got=%q, want=%q", volume, "vol1")
+	}
+
+	data.GetInstance("key").SetLabel("svm", "")
+	if got := n.Normalize(data).GetInstance("key").GetLabel("svm"); got != "" {
+		t.Errorf("Normalize() hashed empty value got=%q", got)
+	}
+}
+
+func TestNewNormalizer_HashWithoutKey(t *testing.T) {
+	_, err := NewNormalizer([]conf.Normalize{{Hash: true}})
+	if err == nil {
+		t.Errorf("NewNormalizer() expected error for hash without hash_key")
+	}
+}
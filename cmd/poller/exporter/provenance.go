@@ -0,0 +1,77 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package exporter
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+// Provenance modes of the provenance exporter option
+const (
+	ProvenanceLabels = "labels" // add the provenance labels to every series
+	ProvenanceInfo   = "info"   // export a companion harvest_provenance_info series per object
+)
+
+// ProvenanceObject is the object of the companion info matrices
+const ProvenanceObject = "harvest_provenance"
+
+func checkProvenance(mode string) error {
+	switch mode {
+	case "", ProvenanceLabels, ProvenanceInfo:
+		return nil
+	}
+	return fmt.Errorf("invalid provenance %q, must be %s or %s", mode, ProvenanceLabels, ProvenanceInfo)
+}
+
+// Provenance returns the matrices to export for data, produced by collector.
+// With labels, data is cloned with the poller, harvest_version and collector global labels.
+// With info, data is followed by a matrix with a single harvest_provenance_info series
+// that has the same global labels as data, plus the provenance labels and object.
+// Labels data already has are not overwritten
+func (e *AbstractExporter) Provenance(data *matrix.Matrix, collector string) []*matrix.Matrix {
+	switch e.Params.Provenance {
+	case ProvenanceLabels:
+		labeled := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+		labeled.UnshareGlobalLabels()
+		labeled.SetGlobalLabels(e.provenanceLabels(collector))
+		return []*matrix.Matrix{labeled}
+	case ProvenanceInfo:
+		return []*matrix.Matrix{data, e.provenanceInfo(data, collector)}
+	}
+	return []*matrix.Matrix{data}
+}
+
+func (e *AbstractExporter) provenanceLabels(collector string) map[string]string {
+	return map[string]string{
+		"poller":          e.Options.Poller,
+		"harvest_version": e.Options.Version,
+		"collector":       collector,
+	}
+}
+
+func (e *AbstractExporter) provenanceInfo(data *matrix.Matrix, collector string) *matrix.Matrix {
+	info := matrix.New(data.UUID+".Provenance", ProvenanceObject, data.Identifier+".provenance")
+	info.SetGlobalLabels(data.GetGlobalLabels())
+
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	instance, _ := info.NewInstance(data.Object)
+	labels := e.provenanceLabels(collector)
+	labels["object"] = data.Object
+	for _, name := range []string{"collector", "harvest_version", "object", "poller"} {
+		if _, ok := data.GetGlobalLabels()[name]; ok {
+			continue
+		}
+		instanceKeys.NewChildS("", name)
+		instance.SetLabel(name, labels[name])
+	}
+	info.SetExportOptions(exportOptions)
+
+	metric, _ := info.NewMetricUint8("info")
+	_ = metric.SetValueUint8(instance, 1)
+	return info
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func newProvenanceExporter(mode string) *AbstractExporter {
+	o := &options.Options{Poller: "dc1-cluster", Version: "24.05.0"}
+	return New("Prometheus", "prom", o, conf.Exporter{Provenance: mode}, nil)
+}
+
+func newProvenanceData() *matrix.Matrix {
+	data := matrix.New("Rest", "volume", "Volume")
+	data.SetGlobalLabel("datacenter", "dc1")
+	data.SetGlobalLabel("poller", "custom")
+	instance, _ := data.NewInstance("vol1")
+	m, _ := data.NewMetricFloat64("size")
+	_ = m.SetValueFloat64(instance, 10)
+	return data
+}
+
+func TestProvenance(t *testing.T) {
+	tests := []struct {
+		mode        string
+		wantCount   int
+		wantOrigin  bool
+		wantVersion string
+	}{
+		{mode: "", wantCount: 1, wantOrigin: true},
+		{mode: ProvenanceLabels, wantCount: 1, wantVersion: "24.05.0"},
+		{mode: ProvenanceInfo, wantCount: 2, wantOrigin: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			data := newProvenanceData()
+			got := newProvenanceExporter(tt.mode).Provenance(data, "Rest")
+			if len(got) != tt.wantCount {
+				t.Fatalf("Provenance() got %d matrices, want=%d", len(got), tt.wantCount)
+			}
+			if (got[0] == data) != tt.wantOrigin {
+				t.Errorf("Provenance() returned original matrix=%t, want=%t", got[0] == data, tt.wantOrigin)
+			}
+			labels := got[0].GetGlobalLabels()
+			if labels["harvest_version"] != tt.wantVersion {
+				t.Errorf("harvest_version got=%s, want=%s", labels["harvest_version"], tt.wantVersion)
+			}
+			if labels["poller"] != "custom" {
+				t.Errorf("existing poller label should not be overwritten got=%s", labels["poller"])
+			}
+			if _, ok := data.GetGlobalLabels()["collector"]; ok {
+				t.Errorf("global labels of the original matrix changed")
+			}
+		})
+	}
+}
+
+func TestProvenanceInfo(t *testing.T) {
+	data := newProvenanceData()
+	info := newProvenanceExporter(ProvenanceInfo).Provenance(data, "Rest")[1]
+
+	if info.Object != ProvenanceObject || info.UUID == data.UUID {
+		t.Errorf("info matrix got object=%s uuid=%s", info.Object, info.UUID)
+	}
+	instance := info.GetInstance("volume")
+	if instance == nil {
+		t.Fatal("info matrix has no instance for volume")
+	}
+	want := map[string]string{"collector": "Rest", "harvest_version": "24.05.0", "object": "volume", "poller": ""}
+	for name, value := range want {
+		if got := instance.GetLabel(name); got != value {
+			t.Errorf("label %s got=%q, want=%q", name, got, value)
+		}
+	}
+	if got, _ := info.GetMetric("info").GetValueFloat64(instance); got != 1 {
+		t.Errorf("info got=%f, want=1", got)
+	}
+	if info.GetGlobalLabels()["datacenter"] != "dc1" {
+		t.Errorf("info matrix should have the global labels of data")
+	}
+}
+
+func TestCheckProvenance(t *testing.T) {
+	for _, mode := range []string{"", ProvenanceLabels, ProvenanceInfo} {
+		if err := checkProvenance(mode); err != nil {
+			t.Errorf("checkProvenance(%q) got=%v", mode, err)
+		}
+	}
+	if err := checkProvenance("series"); err == nil {
+		t.Errorf("checkProvenance(series) expected an error")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright NetApp Inc, 2024 All rights reserved
+*/
+
+package exporter
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"path"
+)
+
+// Router decides which matrices, and which of their instances, are sent to an exporter.
+// A nil or empty Router sends everything
+type Router struct {
+	include []conf.Route
+	exclude []conf.Route
+}
+
+// NewRouter validates routes and returns a Router
+func NewRouter(routes *conf.Routes) (*Router, error) {
+	if routes == nil {
+		return &Router{}, nil
+	}
+	for _, rules := range [][]conf.Route{routes.Include, routes.Exclude} {
+		for _, r := range rules {
+			if _, err := path.Match(r.Object, ""); err != nil {
+				return nil, fmt.Errorf("invalid route object %q: %w", r.Object, err)
+			}
+			for k, v := range r.Labels {
+				if _, err := path.Match(v, ""); err != nil {
+					return nil, fmt.Errorf("invalid route label %s=%q: %w", k, v, err)
+				}
+			}
+		}
+	}
+	return &Router{include: routes.Include, exclude: routes.Exclude}, nil
+}
+
+// Route returns the part of data that should be exported.
+// Returns data when all of it should be exported, nil when none of it should be exported,
+// otherwise a clone of data where the instances that are not routed are not exportable
+func (r *Router) Route(data *matrix.Matrix) *matrix.Matrix {
+	if r == nil || (len(r.include) == 0 && len(r.exclude) == 0) {
+		return data
+	}
+
+	instances := data.GetInstances()
+	if len(instances) == 0 {
+		if r.matches(data, nil) {
+			return data
+		}
+		return nil
+	}
+
+	var skip []string
+	for key, instance := range instances {
+		if instance.IsExportable() && !r.matches(data, instance) {
+			skip = append(skip, key)
+		}
+	}
+	if len(skip) == 0 {
+		return data
+	}
+	if len(skip) == len(instances) {
+		return nil
+	}
+
+	routed := data.Clone(matrix.With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+	for _, key := range skip {
+		routed.GetInstance(key).SetExportable(false)
+	}
+	return routed
+}
+
+func (r *Router) matches(data *matrix.Matrix, instance *matrix.Instance) bool {
+	included := len(r.include) == 0
+	for _, rule := range r.include {
+		if ruleMatches(rule, data, instance) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, rule := range r.exclude {
+		if ruleMatches(rule, data, instance) {
+			return false
+		}
+	}
+	return true
+}
+
+func ruleMatches(rule conf.Route, data *matrix.Matrix, instance *matrix.Instance) bool {
+	if rule.Object != "" {
+		if ok, _ := path.Match(rule.Object, data.Object); !ok {
+			return false
+		}
+	}
+	for key, pattern := range rule.Labels {
+		value, has := "", false
+		if instance != nil {
+			value, has = instance.GetLabels()[key]
+		}
+		if !has {
+			value = data.GetGlobalLabels()[key]
+		}
+		if ok, _ := path.Match(pattern, value); !ok {
+			return false
+		}
+	}
+	return true
+}
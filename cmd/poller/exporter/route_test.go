@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"slices"
+	"testing"
+)
+
+func newRouteMatrix(t *testing.T, object string, svms ...string) *matrix.Matrix {
+	t.Helper()
+	m := matrix.New("uuid", object, object)
+	m.SetGlobalLabel("cluster", "cluster-01")
+	for _, svm := range svms {
+		instance, err := m.NewInstance(svm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("svm", svm)
+	}
+	return m
+}
+
+func exportedInstances(m *matrix.Matrix) []string {
+	var keys []string
+	if m == nil {
+		return keys
+	}
+	for key, instance := range m.GetInstances() {
+		if instance.IsExportable() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestRouter_Route(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes *conf.Routes
+		object string
+		want   []string
+	}{
+		{name: "no routes", routes: nil, object: "volume", want: []string{"svm1", "svm2"}},
+		{name: "include object", routes: &conf.Routes{Include: []conf.Route{{Object: "qos*"}}}, object: "qos_volume", want: []string{"svm1", "svm2"}},
+		{name: "object not included", routes: &conf.Routes{Include: []conf.Route{{Object: "qos*"}}}, object: "volume", want: nil},
+		{name: "exclude object", routes: &conf.Routes{Exclude: []conf.Route{{Object: "qos*"}}}, object: "qos_volume", want: nil},
+		{name: "everything else", routes: &conf.Routes{Exclude: []conf.Route{{Object: "qos*"}}}, object: "volume", want: []string{"svm1", "svm2"}},
+		{name: "include label", routes: &conf.Routes{Include: []conf.Route{{Labels: map[string]string{"svm": "svm1"}}}}, object: "volume", want: []string{"svm1"}},
+		{name: "exclude label", routes: &conf.Routes{Exclude: []conf.Route{{Labels: map[string]string{"svm": "svm1"}}}}, object: "volume", want: []string{"svm2"}},
+		{name: "global label", routes: &conf.Routes{Include: []conf.Route{{Labels: map[string]string{"cluster": "cluster-*"}}}}, object: "volume", want: []string{"svm1", "svm2"}},
+		{name: "object and label", routes: &conf.Routes{Include: []conf.Route{{Object: "volume", Labels: map[string]string{"svm": "svm2"}}}}, object: "volume", want: []string{"svm2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewRouter(tt.routes)
+			if err != nil {
+				t.Fatalf("NewRouter() error = %v", err)
+			}
+			data := newRouteMatrix(t, tt.object, "svm1", "svm2")
+			routed := router.Route(data)
+			got := exportedInstances(routed)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Route() got=%v, want=%v", got, tt.want)
+			}
+			if routed != nil && routed != data && len(exportedInstances(data)) != 2 {
+				t.Errorf("Route() modified the original matrix")
+			}
+		})
+	}
+}
+
+func TestNewRouter_Invalid(t *testing.T) {
+	_, err := NewRouter(&conf.Routes{Include: []conf.Route{{Object: "qos["}}})
+	if err == nil {
+		t.Errorf("NewRouter() expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/requests"
+	"github.com/rs/zerolog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+)
+
+// logLevels are the log levels of the collectors of the poller. The levels set with the admin API win over the
+// levels of the config file
+type logLevels struct {
+	mu    sync.Mutex
+	file  map[string]zerolog.Level
+	admin map[string]zerolog.Level
+}
+
+// setFileLogLevels replaces the log levels of the config file
+func (p *Poller) setFileLogLevels(names map[string]string) {
+	levels, err := logging.ParseLevels(names)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid log_levels")
+	}
+	p.logLevels.mu.Lock()
+	defer p.logLevels.mu.Unlock()
+	p.logLevels.file = levels
+	p.applyLogLevels()
+}
+
+// setAdminLogLevels replaces the log levels set with the admin API
+func (p *Poller) setAdminLogLevels(overrides []logging.LevelOverride) {
+	levels := make(map[string]zerolog.Level, len(overrides))
+	for _, o := range overrides {
+		level, err := logging.ParseLevel(o.Level)
+		if err != nil {
+			logger.Error().Err(err).Str("collector", o.Collector).Msg("Invalid log level from admin node")
+			continue
+		}
+		// a level of this poller wins over a level of all pollers
+		if _, ok := levels[o.Collector]; ok && o.Poller != p.name {
+			continue
+		}
+		levels[o.Collector] = level
+	}
+	p.logLevels.mu.Lock()
+	defer p.logLevels.mu.Unlock()
+	p.logLevels.admin = levels
+	p.applyLogLevels()
+}
+
+// applyLogLevels merges the levels of the config file and the admin API, and logs when they change.
+// The caller must hold the lock
+func (p *Poller) applyLogLevels() {
+	merged := maps.Clone(p.logLevels.file)
+	if merged == nil {
+		merged = make(map[string]zerolog.Level)
+	}
+	maps.Copy(merged, p.logLevels.admin)
+	if maps.Equal(merged, logging.Levels()) {
+		return
+	}
+	logging.SetLevels(merged)
+	var changed []string
+	for key, level := range merged {
+		changed = append(changed, key+"="+level.String())
+	}
+	logger.Info().Str("levels", strings.Join(changed, ",")).Msg("Set log levels")
+}
+
+// handleReload re-reads the log levels of the poller from the config file on each signal, and then asks the
+// collectors to reload the counters of their templates. Both share one signal, since Unix has few signals for
+// applications. When the config file can not be read, e.g. while it is edited, neither is reloaded,
+// so the counters are not reloaded from a config the poller rejected
+func (p *Poller) handleReload(signalChannel chan os.Signal) {
+	for sig := range signalChannel {
+		poller, err := conf.ReadPoller(p.options.Config, p.name)
+		if err != nil {
+			logger.Error().Err(err).Str("signal", sig.String()).Msg("Unable to re-read config, skip reload")
+			continue
+		}
+		logger.Info().Str("signal", sig.String()).Msg("Re-read log levels")
+		p.setFileLogLevels(poller.LogLevels)
+		p.reloadCounters(sig)
+	}
+}
+
+// reloadCounters asks the collectors that support it to reload the counters of their templates, the other
+// collectors keep their templates until the poller restarts
+func (p *Poller) reloadCounters(sig os.Signal) {
+	var reloaded, skipped []string
+	for _, c := range p.collectors {
+		if collector.RequestReload(c) {
+			reloaded = append(reloaded, collectorKey(c))
+		} else {
+			skipped = append(skipped, collectorKey(c))
+		}
+	}
+	logger.Info().
+		Str("signal", sig.String()).
+		Int("reloaded", len(reloaded)).
+		Strs("skipped", skipped).
+		Msg("Reload counters of templates")
+}
+
+// watchReload re-reads the log levels of the config file, and reloads the counters of the templates of the
+// collectors, when the poller receives one of reloadSignals
+func (p *Poller) watchReload() {
+	if len(reloadSignals) == 0 {
+		return
+	}
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, reloadSignals...)
+	go p.handleReload(reload)
+}
+
+// syncLogLevels replaces the log levels set with the admin API with the ones set on the admin node
+func (p *Poller) syncLogLevels() {
+	if p.client == nil {
+		return
+	}
+	req, err := requests.New("GET", p.makeAdminURL("/api/v1/loglevel?poller="+url.QueryEscape(p.name)), nil)
+	if err != nil {
+		logger.Err(err).Msg("failed to create log level request")
+		return
+	}
+	user := conf.Config.Admin.Httpsd.AuthBasic.Username
+	if user != "" {
+		req.SetBasicAuth(user, conf.Config.Admin.Httpsd.AuthBasic.Password)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// publishDetails already logs when the admin node is unreachable
+		logger.Debug().Err(err).Msg("Failed to fetch log levels from admin node")
+		return
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Debug().Int("httpStatusCode", resp.StatusCode).Msg("Failed to fetch log levels from admin node")
+		return
+	}
+	var overrides []logging.LevelOverride
+	if err := json.NewDecoder(resp.Body).Decode(&overrides); err != nil {
+		logger.Error().Err(err).Msg("Unable to parse log levels from admin node")
+		return
+	}
+	p.setAdminLogLevels(overrides)
+}
@@ -38,6 +38,7 @@ type Options struct {
 	IsTest     bool     // true when run from unit test
 	ConfPath   string   // colon-separated paths to search for templates
 	ConfPaths  []string // sliced version of `ConfPath`, list of paths to search for templates
+	Fixtures   string   // directory of recorded API responses that test collectors poll instead of a cluster
 }
 
 func New(opts ...Option) *Options {
@@ -12,8 +12,8 @@ import (
 	"time"
 )
 
-/*The changelog feature is only applicable to labels and requires a UUID for the label name to exist.
-A default configuration for volume, SVM, and node is available, but the DSL can be overwritten as needed.
+/*The changelog feature compares the instances of consecutive polls by their uuid label, or by their key for objects
+without uuid. A default configuration for volume, SVM, and node is available, but the DSL can be overwritten as needed.
 The shape of the change_log is specific to each label change and is only applicable to matrix collected by the collector.
 */
 
@@ -143,12 +143,7 @@ func (c *ChangeLog) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *u
 	oldInstances := set.New()
 	prevInstancesUUIDKey := make(map[string]string)
 	for key, prevInstance := range prevMat.GetInstances() {
-		uuid := prevInstance.GetLabel("uuid")
-		if uuid == "" {
-			c.Logger.Warn().Str("object", object).Str("key", key).Msg("missing uuid")
-			continue
-		}
-		prevInstancesUUIDKey[uuid] = key
+		prevInstancesUUIDKey[identity(key, prevInstance)] = key
 		oldInstances.Add(key)
 	}
 
@@ -157,12 +152,7 @@ func (c *ChangeLog) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *u
 	metricChanges := c.CompareMetrics(data)
 	// loop over current instances
 	for key, instance := range data.GetInstances() {
-		uuid := instance.GetLabel("uuid")
-		if uuid == "" {
-			c.Logger.Warn().Str("object", object).Str("key", key).Msg("missing uuid. ChangeLog is not supported")
-			continue
-		}
-
+		uuid := identity(key, instance)
 		prevKey := prevInstancesUUIDKey[uuid]
 		if prevKey != "" {
 			// instance already in cache
@@ -236,12 +226,8 @@ func (c *ChangeLog) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *u
 	// create deleted instances change_log
 	for key := range oldInstances.Iter() {
 		prevInstance := prevMat.GetInstance(key)
-		uuid := prevInstance.GetLabel("uuid")
-		if uuid == "" {
-			c.Logger.Warn().Str("object", object).Str("key", key).Msg("missing uuid. ChangeLog is not supported")
-			continue
-		}
 		if prevInstance != nil {
+			uuid := identity(key, prevInstance)
 			change := &Change{
 				key:    uuid + "_" + object,
 				object: object,
@@ -273,6 +259,14 @@ func (c *ChangeLog) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *u
 	return matricesArray, nil, nil
 }
 
+// identity returns the uuid of an instance, or its key for objects without uuid
+func identity(key string, instance *matrix.Instance) string {
+	if uuid := instance.GetLabel("uuid"); uuid != "" {
+		return uuid
+	}
+	return key
+}
+
 // CompareMetrics compares the metrics of the current and previous instances
 func (c *ChangeLog) CompareMetrics(curMat *matrix.Matrix) map[string]map[string]struct{} {
 	metricChanges := make(map[string]map[string]struct{})
@@ -35,6 +35,8 @@ ChangeLog:
       - node
       - location
       - healthy
+      - state
+      - failover_state
   - object: volume
     track:
       - node
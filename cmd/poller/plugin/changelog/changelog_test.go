@@ -255,3 +255,30 @@ func TestChangeLogModifiedUnsupportedTrack(t *testing.T) {
 
 	checkChangeLogInstances(t, o, 0, 0, "", "")
 }
+
+func TestChangeLogWithoutUUID(t *testing.T) {
+	params := node.NewS("ChangeLog")
+	params.NewChildS("track", "").NewChildS("", "state")
+	params.NewChildS("publish_labels", "").NewChildS("", "node")
+	parentParams := node.NewS("parent")
+	parentParams.NewChildS("object", "node")
+	p := createChangeLog(params, parentParams)
+
+	poll := func(state string) []*matrix.Matrix {
+		m := matrix.New("TestChangeLog", "node", "node")
+		instance, _ := m.NewInstance("n1")
+		instance.SetLabel("node", "n1")
+		instance.SetLabel("state", state)
+		o, _, _ := p.Run(map[string]*matrix.Matrix{"svm": m})
+		return o
+	}
+	_ = poll("up")
+	o := poll("taken_over")
+
+	checkChangeLogInstances(t, o, 1, 8, update, opLabel)
+	for _, i := range o[0].GetInstances() {
+		if i.GetLabel(oldValue) != "up" || i.GetLabel(newValue) != "taken_over" || i.GetLabel(Track) != "state" {
+			t.Errorf("ChangeLog labels got %v", i.GetLabels())
+		}
+	}
+}
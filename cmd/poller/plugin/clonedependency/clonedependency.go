@@ -0,0 +1,172 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package clonedependency exports the dependencies between FlexClone volumes, the snapshots they were cloned from,
+// and the parent volumes of those snapshots. A snapshot can not be deleted while a clone depends on it,
+// so the plugin shows which clones must be split, and how much space splitting them needs, before a deletion
+package clonedependency
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+)
+
+const (
+	cloneObject  = "volume_clone"
+	parentObject = "volume_clone_parent"
+)
+
+type CloneDependency struct {
+	*plugin.AbstractPlugin
+}
+
+func New(p *plugin.AbstractPlugin) *CloneDependency {
+	return &CloneDependency{AbstractPlugin: p}
+}
+
+func (c *CloneDependency) Init() error {
+	return c.AbstractPlugin.Init()
+}
+
+type volumeKey struct {
+	svm    string
+	volume string
+}
+
+// snapshotKey is a snapshot that clones depend on
+type snapshotKey struct {
+	volumeKey
+	snapshot string
+}
+
+type clone struct {
+	parent   volumeKey
+	snapshot string  // the snapshot of parent the clone was created from
+	split    float64 // space needed to split the clone from its parent, in bytes
+	hasSplit bool
+}
+
+func (c *CloneDependency) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[c.Object]
+
+	clones := make(map[volumeKey]*clone)
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		parent := instance.GetLabel("clone_parent_volume")
+		if parent == "" {
+			continue
+		}
+		svm := instance.GetLabel("svm")
+		parentSvm := instance.GetLabel("clone_parent_svm")
+		if parentSvm == "" {
+			parentSvm = svm
+		}
+		cl := &clone{
+			parent:   volumeKey{svm: parentSvm, volume: parent},
+			snapshot: instance.GetLabel("clone_parent_snapshot"),
+		}
+		if metric := data.GetMetric("clone_split_estimate"); metric != nil {
+			cl.split, cl.hasSplit = metric.GetValueFloat64(instance)
+		}
+		clones[volumeKey{svm: svm, volume: instance.GetLabel("volume")}] = cl
+	}
+
+	cloneMat, err := c.newMatrix(cloneObject, data, []string{"svm", "volume"},
+		[]string{"parent_snapshot", "parent_svm", "parent_volume", "root_svm", "root_volume"},
+		"depth", "split_estimate")
+	if err != nil {
+		return nil, nil, err
+	}
+	parentMat, err := c.newMatrix(parentObject, data, []string{"snapshot", "svm", "volume"}, nil,
+		"clones", "split_estimate")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, cl := range clones {
+		instance, err := cloneMat.NewInstance(key.svm + "." + key.volume)
+		if err != nil {
+			c.Logger.Error().Err(err).Str("svm", key.svm).Str("volume", key.volume).Msg("Failed to create instance")
+			continue
+		}
+		depth, root := chain(clones, key)
+		instance.SetLabel("svm", key.svm)
+		instance.SetLabel("volume", key.volume)
+		instance.SetLabel("parent_svm", cl.parent.svm)
+		instance.SetLabel("parent_volume", cl.parent.volume)
+		instance.SetLabel("parent_snapshot", cl.snapshot)
+		instance.SetLabel("root_svm", root.svm)
+		instance.SetLabel("root_volume", root.volume)
+		_ = cloneMat.GetMetric("depth").SetValueFloat64(instance, float64(depth))
+		if cl.hasSplit {
+			_ = cloneMat.GetMetric("split_estimate").SetValueFloat64(instance, cl.split)
+		}
+
+		// only the clones created from a snapshot depend on it. The clones of a clone depend on the snapshots
+		// of that clone, which remain when the clone is split
+		parentKey := snapshotKey{volumeKey: cl.parent, snapshot: cl.snapshot}
+		parentID := parentKey.svm + "." + parentKey.volume + "." + parentKey.snapshot
+		parent := parentMat.GetInstance(parentID)
+		if parent == nil {
+			if parent, err = parentMat.NewInstance(parentID); err != nil {
+				c.Logger.Error().Err(err).Str("snapshot", parentKey.snapshot).Msg("Failed to create instance")
+				continue
+			}
+			parent.SetLabel("svm", parentKey.svm)
+			parent.SetLabel("volume", parentKey.volume)
+			parent.SetLabel("snapshot", parentKey.snapshot)
+		}
+		add(parentMat.GetMetric("clones"), parent, 1)
+		add(parentMat.GetMetric("split_estimate"), parent, cl.split)
+	}
+
+	return []*matrix.Matrix{cloneMat, parentMat}, nil, nil
+}
+
+// chain returns the number of parents of the clone key, up to the first volume that is not a clone, its root.
+// The walk stops after as many parents as there are clones, in case the labels of a poll are inconsistent
+func chain(clones map[volumeKey]*clone, key volumeKey) (int, volumeKey) {
+	depth := 0
+	for range len(clones) {
+		cl, ok := clones[key]
+		if !ok {
+			break
+		}
+		key = cl.parent
+		depth++
+	}
+	return depth, key
+}
+
+func add(metric *matrix.Metric, instance *matrix.Instance, v float64) {
+	current, _ := metric.GetValueFloat64(instance)
+	_ = metric.SetValueFloat64(instance, current+v)
+}
+
+func (c *CloneDependency) newMatrix(object string, data *matrix.Matrix, keys []string, labels []string, metrics ...string) (*matrix.Matrix, error) {
+	mat := matrix.New(c.Parent+"."+object, object, object)
+	mat.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range keys {
+		instanceKeys.NewChildS("", key)
+	}
+	if len(labels) > 0 {
+		instanceLabels := exportOptions.NewChildS("instance_labels", "")
+		for _, label := range labels {
+			instanceLabels.NewChildS("", label)
+		}
+	}
+	mat.SetExportOptions(exportOptions)
+	for _, name := range metrics {
+		if _, err := mat.NewMetricFloat64(name); err != nil {
+			return nil, err
+		}
+	}
+	return mat, nil
+}
@@ -0,0 +1,143 @@
+package clonedependency
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+)
+
+type volume struct {
+	name           string
+	svm            string
+	parent         string
+	parentSvm      string
+	parentSnapshot string
+	split          float64
+	exportable     bool
+}
+
+func newVolumes(t *testing.T, volumes []volume) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Rest.volume", "volume", "volume")
+	data.SetGlobalLabel("cluster", "c1")
+	split, err := data.NewMetricFloat64("clone_split_estimate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range volumes {
+		instance, err := data.NewInstance(v.svm + v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("volume", v.name)
+		instance.SetLabel("svm", v.svm)
+		instance.SetLabel("clone_parent_volume", v.parent)
+		instance.SetLabel("clone_parent_svm", v.parentSvm)
+		instance.SetLabel("clone_parent_snapshot", v.parentSnapshot)
+		instance.SetExportable(v.exportable)
+		if v.parent != "" {
+			_ = split.SetValueFloat64(instance, v.split)
+		}
+	}
+	return data
+}
+
+func TestCloneDependency_Run(t *testing.T) {
+	data := newVolumes(t, []volume{
+		{name: "prod", svm: "svm1", exportable: true},
+		{name: "dev1", svm: "svm1", parent: "prod", parentSvm: "svm1", parentSnapshot: "daily", split: 100, exportable: true},
+		{name: "dev2", svm: "svm2", parent: "prod", parentSvm: "svm1", parentSnapshot: "daily", split: 50, exportable: true},
+		// a clone of a clone depends on the snapshot of its parent clone
+		{name: "test", svm: "svm1", parent: "dev1", parentSnapshot: "clone_snap", split: 10, exportable: true},
+		{name: "hidden", svm: "svm1", parent: "prod", parentSvm: "svm1", parentSnapshot: "weekly", split: 1000},
+	})
+
+	c := New(plugin.New("Rest", nil, node.NewS("CloneDependency"), nil, "volume", nil))
+	if err := c.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	result, _, err := c.Run(map[string]*matrix.Matrix{"volume": data})
+	if err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Run() got %d matrices, want 2", len(result))
+	}
+	clones, parents := result[0], result[1]
+
+	if got := len(clones.GetInstances()); got != 3 {
+		t.Errorf("clones got=%d, want=3", got)
+	}
+	cloneTests := []struct {
+		key      string
+		parent   string
+		root     string
+		depth    float64
+		estimate float64
+	}{
+		{key: "svm1.dev1", parent: "prod", root: "prod", depth: 1, estimate: 100},
+		{key: "svm2.dev2", parent: "prod", root: "prod", depth: 1, estimate: 50},
+		{key: "svm1.test", parent: "dev1", root: "prod", depth: 2, estimate: 10},
+	}
+	for _, tt := range cloneTests {
+		instance := clones.GetInstance(tt.key)
+		if instance == nil {
+			t.Errorf("clone %s is missing", tt.key)
+			continue
+		}
+		if got := instance.GetLabel("parent_volume"); got != tt.parent {
+			t.Errorf("%s parent_volume got=%s, want=%s", tt.key, got, tt.parent)
+		}
+		if got := instance.GetLabel("root_volume"); got != tt.root {
+			t.Errorf("%s root_volume got=%s, want=%s", tt.key, got, tt.root)
+		}
+		if got, _ := clones.GetMetric("depth").GetValueFloat64(instance); got != tt.depth {
+			t.Errorf("%s depth got=%f, want=%f", tt.key, got, tt.depth)
+		}
+		if got, _ := clones.GetMetric("split_estimate").GetValueFloat64(instance); got != tt.estimate {
+			t.Errorf("%s split_estimate got=%f, want=%f", tt.key, got, tt.estimate)
+		}
+	}
+	// the clone of another SVM keeps its parent's SVM
+	if got := clones.GetInstance("svm2.dev2").GetLabel("parent_svm"); got != "svm1" {
+		t.Errorf("svm2.dev2 parent_svm got=%s, want=svm1", got)
+	}
+
+	parentTests := []struct {
+		key      string
+		clones   float64
+		estimate float64
+	}{
+		{key: "svm1.prod.daily", clones: 2, estimate: 150},
+		{key: "svm1.dev1.clone_snap", clones: 1, estimate: 10},
+	}
+	if got := len(parents.GetInstances()); got != len(parentTests) {
+		t.Errorf("parents got=%d, want=%d", got, len(parentTests))
+	}
+	for _, tt := range parentTests {
+		instance := parents.GetInstance(tt.key)
+		if instance == nil {
+			t.Errorf("parent %s is missing", tt.key)
+			continue
+		}
+		if got, _ := parents.GetMetric("clones").GetValueFloat64(instance); got != tt.clones {
+			t.Errorf("%s clones got=%f, want=%f", tt.key, got, tt.clones)
+		}
+		if got, _ := parents.GetMetric("split_estimate").GetValueFloat64(instance); got != tt.estimate {
+			t.Errorf("%s split_estimate got=%f, want=%f", tt.key, got, tt.estimate)
+		}
+	}
+}
+
+func TestChain_Cycle(t *testing.T) {
+	a := volumeKey{svm: "svm1", volume: "a"}
+	b := volumeKey{svm: "svm1", volume: "b"}
+	clones := map[volumeKey]*clone{
+		a: {parent: b},
+		b: {parent: a},
+	}
+	if depth, _ := chain(clones, a); depth != 2 {
+		t.Errorf("chain() depth got=%d, want=2", depth)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package efficiency rolls up the storage efficiency savings of volumes to their aggregates and to the cluster.
+// Efficiency ratios can not be summed or averaged: the ratio of an aggregate is the logical space of its volumes
+// divided by their physical space. The blocks a FlexClone shares with its parent are counted once, with the parent
+package efficiency
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+)
+
+const (
+	aggrObject    = "aggr_efficiency"
+	clusterObject = "cluster_efficiency"
+)
+
+var metrics = []string{
+	"compression_ratio",
+	"compression_saved",
+	"dedupe_ratio",
+	"dedupe_saved",
+	"logical_used",
+	"physical_used",
+	"ratio",
+}
+
+type Efficiency struct {
+	*plugin.AbstractPlugin
+}
+
+func New(p *plugin.AbstractPlugin) *Efficiency {
+	return &Efficiency{AbstractPlugin: p}
+}
+
+func (e *Efficiency) Init() error {
+	return e.AbstractPlugin.Init()
+}
+
+// space is the space of a set of volumes, in bytes
+type space struct {
+	physical    float64 // space used, without the blocks shared with other volumes
+	dedupe      float64 // space saved by deduplication
+	compression float64 // space saved by compression
+}
+
+func (s *space) add(o space) {
+	s.physical += o.physical
+	s.dedupe += o.dedupe
+	s.compression += o.compression
+}
+
+func (e *Efficiency) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[e.Object]
+
+	aggrs := make(map[string]*space)
+	nodes := make(map[string]string)
+	for _, instance := range data.GetInstances() {
+		s, ok := volumeSpace(data, instance)
+		if !ok {
+			continue
+		}
+		aggr := instance.GetLabel("aggr")
+		if aggr == "" {
+			continue
+		}
+		if aggrs[aggr] == nil {
+			aggrs[aggr] = &space{}
+			nodes[aggr] = instance.GetLabel("node")
+		}
+		aggrs[aggr].add(s)
+	}
+
+	aggrMat, err := e.newMatrix(aggrObject, data, "aggr", "node")
+	if err != nil {
+		return nil, nil, err
+	}
+	clusterMat, err := e.newMatrix(clusterObject, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(aggrs) == 0 {
+		return []*matrix.Matrix{aggrMat, clusterMat}, nil, nil
+	}
+
+	var cluster space
+	for aggr, s := range aggrs {
+		instance, err := aggrMat.NewInstance(aggr)
+		if err != nil {
+			e.Logger.Error().Err(err).Str("aggr", aggr).Msg("Failed to create instance")
+			continue
+		}
+		instance.SetLabel("aggr", aggr)
+		instance.SetLabel("node", nodes[aggr])
+		setSpace(aggrMat, instance, *s)
+		cluster.add(*s)
+	}
+	instance, err := clusterMat.NewInstance("cluster")
+	if err != nil {
+		return nil, nil, err
+	}
+	setSpace(clusterMat, instance, cluster)
+
+	return []*matrix.Matrix{aggrMat, clusterMat}, nil, nil
+}
+
+// volumeSpace returns the space of a volume, or false when the volume is not rolled up
+func volumeSpace(data *matrix.Matrix, instance *matrix.Instance) (space, bool) {
+	style := instance.GetLabel("style")
+	// the constituents of a FlexGroup are rolled up instead of the FlexGroup, since each is in one aggregate
+	if style == "flexgroup" {
+		return space{}, false
+	}
+	if !instance.IsExportable() && style != "flexgroup_constituent" {
+		return space{}, false
+	}
+	used, ok := value(data, "size_used", instance)
+	if !ok {
+		return space{}, false
+	}
+	dedupe, _ := value(data, "sis_dedup_saved", instance)
+	compression, _ := value(data, "sis_compress_saved", instance)
+	s := space{physical: used, dedupe: dedupe, compression: compression}
+
+	// the used space and the savings of a FlexClone include the blocks it shares with its parent,
+	// which are counted with the parent. Only the share of the clone's own blocks is rolled up
+	if instance.GetLabel("clone_parent_volume") != "" && used > 0 {
+		shared, _ := value(data, "clone_split_estimate", instance)
+		own := max(used-shared, 0) / used
+		s = space{physical: used * own, dedupe: dedupe * own, compression: compression * own}
+	}
+	return s, true
+}
+
+func value(data *matrix.Matrix, name string, instance *matrix.Instance) (float64, bool) {
+	metric := data.GetMetric(name)
+	if metric == nil {
+		return 0, false
+	}
+	return metric.GetValueFloat64(instance)
+}
+
+func (e *Efficiency) newMatrix(object string, data *matrix.Matrix, keys ...string) (*matrix.Matrix, error) {
+	mat := matrix.New(e.Parent+"."+object, object, object)
+	mat.SetGlobalLabels(data.GetGlobalLabels())
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	for _, key := range keys {
+		instanceKeys.NewChildS("", key)
+	}
+	mat.SetExportOptions(exportOptions)
+	for _, name := range metrics {
+		if _, err := mat.NewMetricFloat64(name); err != nil {
+			return nil, err
+		}
+	}
+	return mat, nil
+}
+
+// setSpace sets the metrics of instance from the space s. The ratios are not set when nothing is used
+func setSpace(mat *matrix.Matrix, instance *matrix.Instance, s space) {
+	logical := s.physical + s.dedupe + s.compression
+	values := map[string]float64{
+		"compression_saved": s.compression,
+		"dedupe_saved":      s.dedupe,
+		"logical_used":      logical,
+		"physical_used":     s.physical,
+	}
+	if s.physical > 0 {
+		values["ratio"] = logical / s.physical
+		values["dedupe_ratio"] = (s.physical + s.dedupe) / s.physical
+		values["compression_ratio"] = (s.physical + s.compression) / s.physical
+	}
+	for name, v := range values {
+		_ = mat.GetMetric(name).SetValueFloat64(instance, v)
+	}
+}
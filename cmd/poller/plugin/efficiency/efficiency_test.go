@@ -0,0 +1,135 @@
+package efficiency
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+)
+
+type volume struct {
+	name        string
+	aggr        string
+	style       string
+	cloneParent string
+	exportable  bool
+	used        float64
+	dedupe      float64
+	compression float64
+	split       float64
+}
+
+func newVolumes(t *testing.T, volumes []volume) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Rest.volume", "volume", "volume")
+	data.SetGlobalLabel("cluster", "c1")
+	for _, name := range []string{"size_used", "sis_dedup_saved", "sis_compress_saved", "clone_split_estimate"} {
+		if _, err := data.NewMetricFloat64(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range volumes {
+		instance, err := data.NewInstance(v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("volume", v.name)
+		instance.SetLabel("aggr", v.aggr)
+		instance.SetLabel("node", "n1")
+		instance.SetLabel("style", v.style)
+		instance.SetLabel("clone_parent_volume", v.cloneParent)
+		instance.SetExportable(v.exportable)
+		_ = data.GetMetric("size_used").SetValueFloat64(instance, v.used)
+		_ = data.GetMetric("sis_dedup_saved").SetValueFloat64(instance, v.dedupe)
+		_ = data.GetMetric("sis_compress_saved").SetValueFloat64(instance, v.compression)
+		if v.cloneParent != "" {
+			_ = data.GetMetric("clone_split_estimate").SetValueFloat64(instance, v.split)
+		}
+	}
+	return data
+}
+
+func TestEfficiency_Run(t *testing.T) {
+	data := newVolumes(t, []volume{
+		{name: "v1", aggr: "aggr1", style: "flexvol", exportable: true, used: 100, dedupe: 100},
+		{name: "v2", aggr: "aggr1", style: "flexvol", exportable: true, used: 300, compression: 100},
+		{name: "parent", aggr: "aggr2", style: "flexvol", exportable: true, used: 200, dedupe: 200},
+		// 3/4 of the clone is shared with its parent
+		{name: "clone", aggr: "aggr2", style: "flexvol", cloneParent: "parent", exportable: true, used: 200, dedupe: 100, split: 150},
+		// the FlexGroup is rolled up by its constituents, which are not exported
+		{name: "fg", aggr: "aggr1,aggr2", style: "flexgroup", exportable: true, used: 1000, dedupe: 1000},
+		{name: "fg__0001", aggr: "aggr2", style: "flexgroup_constituent", used: 100, compression: 100},
+		{name: "root", aggr: "aggr1", style: "flexvol", used: 1000, dedupe: 1000},
+	})
+
+	params := node.NewS("Efficiency")
+	e := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	if err := e.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	result, _, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	if err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Run() got %d matrices, want 2", len(result))
+	}
+
+	tests := []struct {
+		name     string
+		mat      *matrix.Matrix
+		instance string
+		want     map[string]float64
+	}{
+		{
+			// the ratio of the sums, not the average of the ratios 2 and 1.33
+			name: "aggr1", mat: result[0], instance: "aggr1",
+			want: map[string]float64{"physical_used": 400, "logical_used": 600, "dedupe_saved": 100, "compression_saved": 100,
+				"ratio": 1.5, "dedupe_ratio": 1.25, "compression_ratio": 1.25},
+		},
+		{
+			name: "aggr2", mat: result[0], instance: "aggr2",
+			want: map[string]float64{"physical_used": 350, "logical_used": 675, "dedupe_saved": 225, "compression_saved": 100},
+		},
+		{
+			name: "cluster", mat: result[1], instance: "cluster",
+			want: map[string]float64{"physical_used": 750, "logical_used": 1275, "dedupe_saved": 325, "compression_saved": 200, "ratio": 1.7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := tt.mat.GetInstance(tt.instance)
+			if instance == nil {
+				t.Fatalf("instance %s not found", tt.instance)
+			}
+			for name, want := range tt.want {
+				got, ok := tt.mat.GetMetric(name).GetValueFloat64(instance)
+				if !ok || got != want {
+					t.Errorf("%s got=%f,%t want=%f", name, got, ok, want)
+				}
+			}
+		})
+	}
+	if got := result[1].GetGlobalLabels()["cluster"]; got != "c1" {
+		t.Errorf("cluster label got=%s, want=c1", got)
+	}
+	if got := result[0].GetInstance("aggr1").GetLabel("node"); got != "n1" {
+		t.Errorf("node label got=%s, want=n1", got)
+	}
+}
+
+func TestEfficiency_NothingUsed(t *testing.T) {
+	data := newVolumes(t, []volume{{name: "v1", aggr: "aggr1", style: "flexvol", exportable: true}})
+	e := New(plugin.New("Rest", nil, node.NewS("Efficiency"), nil, "volume", nil))
+	if err := e.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	result, _, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	if err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+	aggr := result[0].GetInstance("aggr1")
+	if _, ok := result[0].GetMetric("ratio").GetValueFloat64(aggr); ok {
+		t.Errorf("ratio is set when nothing is used")
+	}
+}
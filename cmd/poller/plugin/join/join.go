@@ -0,0 +1,175 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package join enriches the instances of an object with labels of the instances of another object,
+// e.g. the type of the aggregate of each volume. The other object is collected by another collector
+// of the same poller, which shares its labels through the bus
+package join
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/bus"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"slices"
+	"strings"
+)
+
+type Join struct {
+	*plugin.AbstractPlugin
+	rules []rule
+}
+
+// rule copies labels of the instances of object to the instances with the same keys
+type rule struct {
+	object     string
+	localKeys  []string
+	remoteKeys []string
+	labels     []string // labels of object
+	displays   []string // names of the copied labels
+}
+
+func New(p *plugin.AbstractPlugin) *Join {
+	return &Join{AbstractPlugin: p}
+}
+
+func (j *Join) Init() error {
+	if err := j.AbstractPlugin.Init(); err != nil {
+		return err
+	}
+	for _, line := range j.Params.GetAllChildContentS() {
+		r, err := parseRule(line)
+		if err != nil {
+			return err
+		}
+		j.rules = append(j.rules, r)
+		j.Bus.Want(r.object)
+	}
+	if j.Bus == nil {
+		j.Logger.Debug().Msg("no bus to share labels, nothing is joined")
+	}
+	j.Logger.Debug().Int("rules", len(j.rules)).Msg("parsed join rules")
+	return nil
+}
+
+// parseRule parses a rule with the syntax
+// OBJECT KEY[=OTHER_KEY],... LABEL[ => DISPLAY],...
+func parseRule(line string) (rule, error) {
+	var r rule
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return r, fmt.Errorf("invalid join rule %q, want OBJECT KEYS LABELS", line)
+	}
+	r.object = fields[0]
+	for _, key := range strings.Split(fields[1], ",") {
+		local, remote, found := strings.Cut(key, "=")
+		if !found {
+			remote = local
+		}
+		if local == "" || remote == "" {
+			return r, fmt.Errorf("invalid key %q in join rule %q", key, line)
+		}
+		r.localKeys = append(r.localKeys, local)
+		r.remoteKeys = append(r.remoteKeys, remote)
+	}
+	for _, label := range strings.Split(strings.Join(fields[2:], " "), ",") {
+		name, display, found := strings.Cut(label, "=>")
+		name = strings.TrimSpace(name)
+		display = strings.TrimSpace(display)
+		if !found {
+			display = name
+		}
+		if name == "" || display == "" {
+			return r, fmt.Errorf("invalid label %q in join rule %q", label, line)
+		}
+		r.labels = append(r.labels, name)
+		r.displays = append(r.displays, display)
+	}
+	return r, nil
+}
+
+func (j *Join) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[j.Object]
+	cluster := data.GetGlobalLabels()["cluster"]
+
+	for _, r := range j.rules {
+		labels, ok := j.Bus.Labels(bus.Key{Cluster: cluster, Object: r.object})
+		if !ok {
+			j.Logger.Debug().Str("object", r.object).Msg("no labels to join yet")
+			continue
+		}
+		j.apply(data, r, labels)
+	}
+	return nil, nil, nil
+}
+
+// apply copies the labels of r to the instances of data that have an instance in labels with the same keys.
+// The labels of other instances are cleared, so they do not keep the labels of an earlier join.
+// The copied labels are exported as instance keys, unless they are already exported
+func (j *Join) apply(data *matrix.Matrix, r rule, labels bus.Labels) {
+	index := make(map[string]map[string]string, len(labels.Instances))
+	for _, instance := range labels.Instances {
+		if key, ok := joinKey(instance, r.remoteKeys); ok {
+			index[key] = instance
+		}
+	}
+
+	for _, instance := range data.GetInstances() {
+		var other map[string]string
+		if key, ok := joinKey(instance.GetLabels(), r.localKeys); ok {
+			other = index[key]
+		}
+		for n, label := range r.labels {
+			instance.SetLabel(r.displays[n], other[label])
+		}
+	}
+
+	exportOptions := data.GetExportOptions()
+	var added []string
+	for _, display := range r.displays {
+		if !slices.Contains(contents(exportOptions, "instance_keys"), display) &&
+			!slices.Contains(contents(exportOptions, "instance_labels"), display) {
+			added = append(added, display)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	if exportOptions == nil {
+		exportOptions = node.NewS("export_options")
+	} else {
+		exportOptions = exportOptions.Copy()
+	}
+	instanceKeys := exportOptions.GetChildS("instance_keys")
+	if instanceKeys == nil {
+		instanceKeys = exportOptions.NewChildS("instance_keys", "")
+	}
+	for _, display := range added {
+		instanceKeys.NewChildS("", display)
+	}
+	data.SetExportOptions(exportOptions)
+}
+
+// contents returns the contents of the children of the child name of n
+func contents(n *node.Node, name string) []string {
+	if n == nil || n.GetChildS(name) == nil {
+		return nil
+	}
+	return n.GetChildS(name).GetAllChildContentS()
+}
+
+// joinKey returns the values of keys in labels, or false when one of them is empty
+func joinKey(labels map[string]string, keys []string) (string, bool) {
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := labels[k]
+		if v == "" {
+			return "", false
+		}
+		values = append(values, v)
+	}
+	return strings.Join(values, "\x00"), true
+}
@@ -0,0 +1,133 @@
+package join
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/bus"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newJoin(t *testing.T, b *bus.Bus, rules ...string) *Join {
+	t.Helper()
+	params := node.NewS("Join")
+	for _, r := range rules {
+		params.NewChildS("", r)
+	}
+	abc := plugin.New("Rest", nil, params, nil, "Volume", nil)
+	abc.Bus = b
+	j := New(abc)
+	if err := j.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func newVolumes(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Volume", "volume", "volume")
+	data.SetGlobalLabel("cluster", "cluster-01")
+	for _, v := range []struct{ volume, svm, aggr, node string }{
+		{"vol1", "vs1", "aggr1", "n1"},
+		{"vol2", "vs1", "aggr2", "n2"},
+		{"vol3", "vs2", "", "n1"},
+	} {
+		instance, err := data.NewInstance(v.svm + v.volume)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("volume", v.volume)
+		instance.SetLabel("svm", v.svm)
+		instance.SetLabel("aggr", v.aggr)
+		instance.SetLabel("node", v.node)
+	}
+	exportOptions := node.NewS("export_options")
+	instanceKeys := exportOptions.NewChildS("instance_keys", "")
+	instanceKeys.NewChildS("", "volume")
+	instanceKeys.NewChildS("", "svm")
+	exportOptions.NewChildS("instance_labels", "").NewChildS("", "node_model")
+	data.SetExportOptions(exportOptions)
+	return data
+}
+
+func TestJoin(t *testing.T) {
+	b := bus.New()
+	j := newJoin(t, b, "aggr aggr type => aggregate_type, raid_type", "node node=name model => node_model")
+	if !b.Wanted("aggr") || !b.Wanted("node") {
+		t.Fatal("Init() should ask for the labels of aggr and node")
+	}
+
+	now := time.Now()
+	b.PublishLabels(bus.Key{Cluster: "cluster-01", Object: "aggr"}, bus.Labels{Time: now, Instances: []map[string]string{
+		{"aggr": "aggr1", "type": "ssd", "raid_type": "raid_dp"},
+		{"aggr": "aggr2", "type": "hdd", "raid_type": "raid4"},
+	}})
+	b.PublishLabels(bus.Key{Cluster: "cluster-02", Object: "node"}, bus.Labels{Time: now, Instances: []map[string]string{
+		{"name": "n1", "model": "other cluster"},
+	}})
+
+	data := newVolumes(t)
+	data.GetInstance("vs2vol3").SetLabel("aggregate_type", "stale")
+	if _, _, err := j.Run(map[string]*matrix.Matrix{"Volume": data}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		instance string
+		label    string
+		want     string
+	}{
+		{"vs1vol1", "aggregate_type", "ssd"},
+		{"vs1vol1", "raid_type", "raid_dp"},
+		{"vs1vol2", "aggregate_type", "hdd"},
+		{"vs2vol3", "aggregate_type", ""},
+		// the node labels are of another cluster
+		{"vs1vol1", "node_model", ""},
+	}
+	for _, tt := range tests {
+		if got := data.GetInstance(tt.instance).GetLabel(tt.label); got != tt.want {
+			t.Errorf("%s %s got=%q, want=%q", tt.instance, tt.label, got, tt.want)
+		}
+	}
+
+	keys := data.GetExportOptions().GetChildS("instance_keys").GetAllChildContentS()
+	want := []string{"volume", "svm", "aggregate_type", "raid_type"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("instance_keys got=%v, want=%v", keys, want)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    rule
+		wantErr bool
+	}{
+		{line: "node node model", want: rule{object: "node", localKeys: []string{"node"}, remoteKeys: []string{"node"},
+			labels: []string{"model"}, displays: []string{"model"}}},
+		{line: "svm svm=svm,node=home_node state=>svm_state", want: rule{object: "svm",
+			localKeys: []string{"svm", "node"}, remoteKeys: []string{"svm", "home_node"},
+			labels: []string{"state"}, displays: []string{"svm_state"}}},
+		{line: "node node", wantErr: true},
+		{line: "node node= model", wantErr: true},
+		{line: "node node model =>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseRule(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRule() error=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.object != tt.want.object || !slices.Equal(got.localKeys, tt.want.localKeys) ||
+				!slices.Equal(got.remoteKeys, tt.want.remoteKeys) || !slices.Equal(got.labels, tt.want.labels) ||
+				!slices.Equal(got.displays, tt.want.displays) {
+				t.Errorf("parseRule() got=%+v, want=%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"fmt"
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/bus"
 	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/logging"
 	"github.com/netapp/harvest/v2/pkg/matrix"
@@ -41,7 +42,9 @@ import (
 const DefaultPluginInterval = 30 * time.Minute
 const DefaultPollInterval = 3 * time.Minute
 
-// Plugin defines the methods of a plugin
+// Plugin defines the methods of a plugin.
+// Run is not called concurrently, but the matrices it receives are not safe for concurrent use. A plugin that
+// starts goroutines must wait for them before returning, and share the matrices with them through matrix.Locked
 type Plugin interface {
 	GetName() string
 	Init() error
@@ -110,6 +113,7 @@ type AbstractPlugin struct {
 	ParentParams         *node.Node       // parent collector parameters
 	PluginInvocationRate int
 	Auth                 *auth.Credentials
+	Bus                  *bus.Bus // shares collected data between the collectors of the poller, nil when not polling
 }
 
 // New creates an AbstractPlugin
@@ -143,7 +147,7 @@ func (p *AbstractPlugin) InitAbc() error {
 	if p.Name = p.Params.GetNameS(); p.Name == "" {
 		return errs.New(errs.ErrMissingParam, "plugin name")
 	}
-	p.Logger = logging.Get().SubLogger("plugin", p.Parent+":"+p.Name).SubLogger("object", p.Object)
+	p.Logger = logging.ForObject(p.Parent, p.Object).SubLogger("plugin", p.Parent+":"+p.Name).SubLogger("object", p.Object)
 
 	return nil
 }
@@ -0,0 +1,219 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package qosgovernor caps the number of QoS workloads that are exported. Clusters with tens of thousands of
+// workloads export too many series to be usable, so only the workloads that match a policy group or name pattern,
+// or that are among the busiest, are exported. The other workloads are rolled into a single "other" instance,
+// so the totals of the object do not change
+package qosgovernor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	otherWorkload = "other"
+	defaultRankBy = "ops"
+	// suppressedMetric is the number of workloads rolled into the other instance
+	suppressedMetric = "suppressed_workloads"
+)
+
+type QosGovernor struct {
+	*plugin.AbstractPlugin
+	policyGroups []*regexp.Regexp
+	workloads    []*regexp.Regexp
+	top          int             // number of busiest workloads to export, 0 when workloads are not ranked
+	rankBy       string          // metric that ranks workloads
+	hidden       map[string]bool // workloads that the governor made not exportable
+}
+
+func New(p *plugin.AbstractPlugin) *QosGovernor {
+	return &QosGovernor{AbstractPlugin: p}
+}
+
+func (g *QosGovernor) Init() error {
+	var err error
+	if err = g.AbstractPlugin.Init(); err != nil {
+		return err
+	}
+	if g.policyGroups, err = compile(g.Params.GetChildS("policy_groups")); err != nil {
+		return fmt.Errorf("policy_groups: %w", err)
+	}
+	if g.workloads, err = compile(g.Params.GetChildS("workloads")); err != nil {
+		return fmt.Errorf("workloads: %w", err)
+	}
+	if top := g.Params.GetChildContentS("top"); top != "" {
+		if g.top, err = strconv.Atoi(top); err != nil || g.top < 1 {
+			return fmt.Errorf("top must be a positive number [%s]", top)
+		}
+	}
+	if g.top == 0 && len(g.policyGroups) == 0 && len(g.workloads) == 0 {
+		return errors.New("at least one of policy_groups, workloads, or top is required")
+	}
+	g.rankBy = cmp.Or(g.Params.GetChildContentS("rank_by"), defaultRankBy)
+	g.hidden = make(map[string]bool)
+	return nil
+}
+
+func compile(patterns *node.Node) ([]*regexp.Regexp, error) {
+	if patterns == nil {
+		return nil, nil
+	}
+	var regs []*regexp.Regexp
+	for _, p := range patterns.GetAllChildContentS() {
+		reg, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex [%s]: %w", p, err)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+func (g *QosGovernor) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[g.Object]
+
+	keep := g.keep(data)
+	var suppressed []*matrix.Instance
+	for key, instance := range data.GetInstances() {
+		if keep[key] {
+			if g.hidden[key] {
+				instance.SetExportable(true)
+				delete(g.hidden, key)
+			}
+			continue
+		}
+		if g.hidden[key] || instance.IsExportable() {
+			instance.SetExportable(false)
+			g.hidden[key] = true
+			suppressed = append(suppressed, instance)
+		}
+	}
+	for key := range g.hidden {
+		if data.GetInstance(key) == nil {
+			delete(g.hidden, key)
+		}
+	}
+
+	other, err := g.rollUp(data, suppressed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*matrix.Matrix{other}, &util.Metadata{PluginInstances: uint64(len(suppressed))}, nil
+}
+
+// keep returns the keys of the workloads to export: the workloads that match a policy group or workload pattern,
+// and the top busiest of the other workloads. Workloads without a value of the ranking metric are not ranked
+func (g *QosGovernor) keep(data *matrix.Matrix) map[string]bool {
+	type ranked struct {
+		key   string
+		value float64
+	}
+	keep := make(map[string]bool)
+	var candidates []ranked
+	rankBy := data.GetMetric(g.rankBy)
+
+	for key, instance := range data.GetInstances() {
+		if !instance.IsExportable() && !g.hidden[key] {
+			continue
+		}
+		if matchAny(g.policyGroups, instance.GetLabel("policy_group")) || matchAny(g.workloads, instance.GetLabel("workload")) {
+			keep[key] = true
+			continue
+		}
+		if g.top == 0 || rankBy == nil {
+			continue
+		}
+		if v, ok := rankBy.GetValueFloat64(instance); ok {
+			candidates = append(candidates, ranked{key: key, value: v})
+		}
+	}
+
+	// ties are broken by key, so the same workloads are exported on each poll
+	slices.SortFunc(candidates, func(a, b ranked) int {
+		return cmp.Or(cmp.Compare(b.value, a.value), strings.Compare(a.key, b.key))
+	})
+	for _, c := range candidates[:min(g.top, len(candidates))] {
+		keep[c.key] = true
+	}
+	return keep
+}
+
+func matchAny(regs []*regexp.Regexp, value string) bool {
+	for _, reg := range regs {
+		if reg.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollUp returns a matrix with the other instance, whose metrics are the sums of the metrics of the suppressed
+// workloads. Latencies are weighted by the ops of their denominator, like the Aggregator plugin does, and other
+// averages and percentages are the mean of the workloads
+func (g *QosGovernor) rollUp(data *matrix.Matrix, suppressed []*matrix.Instance) (*matrix.Matrix, error) {
+	mat := data.Clone(matrix.With{Data: false, Metrics: true, Instances: false})
+	mat.UUID += ".QosGovernor"
+	if _, err := mat.NewMetricFloat64(suppressedMetric); err != nil {
+		return nil, err
+	}
+	other, err := mat.NewInstance(otherWorkload)
+	if err != nil {
+		return nil, err
+	}
+	other.SetLabel("workload", otherWorkload)
+	_ = mat.GetMetric(suppressedMetric).SetValueFloat64(other, float64(len(suppressed)))
+
+	for key, metric := range data.GetMetrics() {
+		otherMetric := mat.GetMetric(key)
+		if otherMetric == nil || metric.IsHistogram() {
+			continue
+		}
+		var opsMetric *matrix.Metric
+		if strings.Contains(key, "_latency") || key == "latency" {
+			opsMetric = data.GetMetric(metric.GetComment())
+		}
+		average := metric.GetProperty() == "average" || metric.GetProperty() == "percent"
+
+		var sum, count float64
+		for _, instance := range suppressed {
+			value, ok := metric.GetValueFloat64(instance)
+			if !ok {
+				continue
+			}
+			switch {
+			case opsMetric != nil:
+				ops, ok := opsMetric.GetValueFloat64(instance)
+				if !ok {
+					continue
+				}
+				sum += value * ops
+				count += ops
+			default:
+				sum += value
+				count++
+			}
+		}
+		switch {
+		case count == 0 && opsMetric == nil:
+			continue
+		case opsMetric != nil || average:
+			if count > 0 {
+				sum /= count
+			}
+		}
+		_ = otherMetric.SetValueFloat64(other, sum)
+	}
+	return mat, nil
+}
@@ -0,0 +1,140 @@
+package qosgovernor
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"slices"
+	"testing"
+)
+
+type workload struct {
+	name        string
+	policyGroup string
+	ops         float64
+	latency     float64
+}
+
+func newWorkloads(t *testing.T, workloads []workload) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("ZapiPerf.qos", "qos", "qos")
+	ops, _ := data.NewMetricFloat64("ops")
+	ops.SetProperty("rate")
+	latency, _ := data.NewMetricFloat64("latency")
+	latency.SetProperty("average")
+	latency.SetComment("ops")
+	for _, w := range workloads {
+		instance, err := data.NewInstance(w.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("workload", w.name)
+		instance.SetLabel("policy_group", w.policyGroup)
+		_ = ops.SetValueFloat64(instance, w.ops)
+		_ = latency.SetValueFloat64(instance, w.latency)
+	}
+	return data
+}
+
+func newGovernor(t *testing.T, yaml string) *QosGovernor {
+	t.Helper()
+	params, err := tree.LoadYaml([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(plugin.New("ZapiPerf", nil, params, nil, "qos", nil))
+	if err := g.Init(); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	return g
+}
+
+func exported(data *matrix.Matrix) []string {
+	var keys []string
+	for key, instance := range data.GetInstances() {
+		if instance.IsExportable() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestQosGovernor_Run(t *testing.T) {
+	g := newGovernor(t, `
+top: 1
+policy_groups:
+  - ^gold
+workloads:
+  - _prod$
+`)
+	data := newWorkloads(t, []workload{
+		{name: "vol1_prod", policyGroup: "bronze", ops: 1},
+		{name: "vol2", policyGroup: "gold_pg", ops: 2},
+		{name: "vol3", policyGroup: "bronze", ops: 100, latency: 10},
+		{name: "vol4", policyGroup: "bronze", ops: 30, latency: 20},
+		{name: "vol5", policyGroup: "bronze", ops: 10, latency: 100},
+	})
+
+	result, _, err := g.Run(map[string]*matrix.Matrix{"qos": data})
+	if err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+	if got, want := exported(data), []string{"vol1_prod", "vol2", "vol3"}; !slices.Equal(got, want) {
+		t.Errorf("exported got=%v, want=%v", got, want)
+	}
+
+	other := result[0].GetInstance(otherWorkload)
+	if other == nil {
+		t.Fatal("other instance is missing")
+	}
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metric: "ops", want: 40},
+		// weighted by ops, (30*20 + 10*100) / 40
+		{metric: "latency", want: 40},
+		{metric: suppressedMetric, want: 2},
+	}
+	for _, tt := range tests {
+		if got, _ := result[0].GetMetric(tt.metric).GetValueFloat64(other); got != tt.want {
+			t.Errorf("other %s got=%f, want=%f", tt.metric, got, tt.want)
+		}
+	}
+
+	// vol5 becomes the busiest workload, so it is exported again and vol3 is suppressed
+	_ = data.GetMetric("ops").SetValueFloat64(data.GetInstance("vol5"), 500)
+	if _, _, err = g.Run(map[string]*matrix.Matrix{"qos": data}); err != nil {
+		t.Fatalf("Run() err=%v", err)
+	}
+	if got, want := exported(data), []string{"vol1_prod", "vol2", "vol5"}; !slices.Equal(got, want) {
+		t.Errorf("exported got=%v, want=%v", got, want)
+	}
+}
+
+func TestQosGovernor_Init(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "top", yaml: "top: 100\n"},
+		{name: "patterns", yaml: "workloads:\n  - ^vol\n"},
+		{name: "no rule", yaml: "rank_by: ops\n", wantErr: true},
+		{name: "zero top", yaml: "top: 0\n", wantErr: true},
+		{name: "invalid regex", yaml: "policy_groups:\n  - '('\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := New(plugin.New("ZapiPerf", nil, params, nil, "qos", nil))
+			if err := g.Init(); (err != nil) != tt.wantErr {
+				t.Errorf("Init() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package rebucket re-buckets latency histograms into fixed buckets, so the histograms of different counter tables
+// have the same buckets and their heatmaps are comparable.
+// The count of each bucket is split between the new buckets it overlaps, assuming its latencies are uniformly
+// distributed. The count of the last bucket, which has no upper bound, is added to the new bucket of its lower bound
+package rebucket
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Rebucket struct {
+	*plugin.AbstractPlugin
+	boundaries []float64 // upper bounds of the new buckets in microseconds, ascending
+	labels     []string  // names of the new buckets, including the last bucket without upper bound
+	histograms []string  // names of the re-bucketed histograms, all latency histograms when empty
+}
+
+func New(p *plugin.AbstractPlugin) *Rebucket {
+	return &Rebucket{AbstractPlugin: p}
+}
+
+func (r *Rebucket) Init() error {
+	if err := r.AbstractPlugin.Init(); err != nil {
+		return err
+	}
+	var boundaries []string
+	if x := r.Params.GetChildS("boundaries"); x != nil {
+		boundaries = x.GetAllChildContentS()
+	}
+	if len(boundaries) == 0 {
+		return errors.New("boundaries are required")
+	}
+	for _, b := range boundaries {
+		d, err := time.ParseDuration(b)
+		if err != nil || d < time.Microsecond || d%time.Microsecond != 0 {
+			return fmt.Errorf("invalid boundary %q, want a whole number of microseconds, e.g. 500us", b)
+		}
+		us := float64(d.Microseconds())
+		if len(r.boundaries) > 0 && us <= r.boundaries[len(r.boundaries)-1] {
+			return fmt.Errorf("boundary %q is not greater than the previous boundary", b)
+		}
+		r.boundaries = append(r.boundaries, us)
+		r.labels = append(r.labels, "<"+formatMicros(d.Microseconds()))
+	}
+	r.labels = append(r.labels, ">"+formatMicros(int64(r.boundaries[len(r.boundaries)-1])))
+
+	if x := r.Params.GetChildS("histograms"); x != nil {
+		r.histograms = x.GetAllChildContentS()
+	}
+	r.Logger.Debug().Strs("buckets", r.labels).Strs("histograms", r.histograms).Msg("parsed buckets")
+	return nil
+}
+
+// formatMicros formats microseconds like the names of ONTAP buckets, e.g. 2us, 10ms, or 1s
+func formatMicros(us int64) string {
+	switch {
+	case us%1_000_000 == 0:
+		return strconv.FormatInt(us/1_000_000, 10) + "s"
+	case us%1_000 == 0:
+		return strconv.FormatInt(us/1_000, 10) + "ms"
+	default:
+		return strconv.FormatInt(us, 10) + "us"
+	}
+}
+
+var bucketRe = regexp.MustCompile(`^([<>])\s*(\d+(?:\.\d+)?)\s*(us|ms|msec|s|sec)$`)
+
+// bounds returns the lower and upper bounds of the buckets of an ONTAP histogram, in microseconds,
+// e.g. <2us, <6us, ..., >20s. The upper bound of the last bucket is +Inf
+func bounds(names []string) ([][2]float64, error) {
+	result := make([][2]float64, 0, len(names))
+	lower := 0.0
+	for i, name := range names {
+		m := bucketRe.FindStringSubmatch(strings.TrimSpace(name))
+		if m == nil {
+			return nil, fmt.Errorf("bucket %q is not a latency", name)
+		}
+		value, _ := strconv.ParseFloat(m[2], 64)
+		switch m[3] {
+		case "ms", "msec":
+			value *= 1_000
+		case "s", "sec":
+			value *= 1_000_000
+		}
+		if m[1] == ">" {
+			if i != len(names)-1 || value < lower {
+				return nil, fmt.Errorf("bucket %q is not the last bucket", name)
+			}
+			result = append(result, [2]float64{value, math.Inf(1)})
+			break
+		}
+		if value <= lower && i > 0 {
+			return nil, fmt.Errorf("bucket %q is not greater than the previous bucket", name)
+		}
+		result = append(result, [2]float64{lower, value})
+		lower = value
+	}
+	return result, nil
+}
+
+// split returns the counts of the new buckets, given the counts and bounds of the buckets of a histogram
+func (r *Rebucket) split(counts []float64, from [][2]float64) []float64 {
+	result := make([]float64, len(r.labels))
+	for i, count := range counts {
+		lo, hi := from[i][0], from[i][1]
+		if count == 0 {
+			continue
+		}
+		if math.IsInf(hi, 1) {
+			result[r.bucketOf(lo)] += count
+			continue
+		}
+		lower := 0.0
+		for j := range result {
+			upper := math.Inf(1)
+			if j < len(r.boundaries) {
+				upper = r.boundaries[j]
+			}
+			if overlap := min(hi, upper) - max(lo, lower); overlap > 0 {
+				result[j] += count * overlap / (hi - lo)
+			}
+			lower = upper
+		}
+	}
+	return result
+}
+
+// bucketOf returns the index of the new bucket of a latency
+func (r *Rebucket) bucketOf(us float64) int {
+	for j, b := range r.boundaries {
+		if us < b {
+			return j
+		}
+	}
+	return len(r.boundaries)
+}
+
+// roundCounts rounds counts to integers with the same total, by rounding up the counts with the largest fractions
+func roundCounts(counts []float64) []float64 {
+	total := 0.0
+	rounded := make([]float64, len(counts))
+	order := make([]int, len(counts))
+	for i, c := range counts {
+		total += c
+		rounded[i] = math.Floor(c)
+		order[i] = i
+	}
+	remaining := int(math.Round(total))
+	for _, c := range rounded {
+		remaining -= int(c)
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		fa, fb := counts[a]-rounded[a], counts[b]-rounded[b]
+		switch {
+		case fa > fb:
+			return -1
+		case fa < fb:
+			return 1
+		}
+		return 0
+	})
+	for i := 0; i < remaining && i < len(order); i++ {
+		rounded[order[i]]++
+	}
+	return rounded
+}
+
+func (r *Rebucket) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *util.Metadata, error) {
+	data := dataMap[r.Object]
+	// the histogram is the metric with the buckets, its elements are the metrics with its key as bucket label
+	var keys []string
+	for key, m := range data.GetMetrics() {
+		if m.Buckets() != nil && !m.IsHistogram() && !strings.HasSuffix(key, ".rebucket") {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		bucket := data.GetMetric(key)
+		if len(r.histograms) > 0 && !slices.Contains(r.histograms, bucket.GetName()) {
+			continue
+		}
+		from, err := bounds(*bucket.Buckets())
+		if err != nil {
+			r.Logger.Debug().Err(err).Str("histogram", bucket.GetName()).Msg("skip histogram")
+			continue
+		}
+		if err := r.rebucket(data, key, bucket, from); err != nil {
+			r.Logger.Error().Err(err).Str("histogram", bucket.GetName()).Msg("rebucket")
+		}
+	}
+	return nil, nil, nil
+}
+
+// rebucket adds the new buckets of the histogram bucketKey to data, and stops exporting its buckets
+func (r *Rebucket) rebucket(data *matrix.Matrix, bucketKey string, bucket *matrix.Metric, from [][2]float64) error {
+	elements := make([]*matrix.Metric, len(from))
+	for _, m := range data.GetMetrics() {
+		if !m.IsHistogram() || m.GetLabel("bucket") != bucketKey {
+			continue
+		}
+		index, err := strconv.Atoi(m.GetLabel("comment"))
+		if err != nil || index < 0 || index >= len(elements) {
+			return fmt.Errorf("invalid index %q of bucket %s", m.GetLabel("comment"), m.GetLabel("metric"))
+		}
+		elements[index] = m
+	}
+	if slices.Contains(elements, nil) {
+		return errors.New("missing buckets")
+	}
+
+	newKey := strings.TrimSuffix(bucketKey, ".bucket") + ".rebucket"
+	newBucket := data.GetMetric(newKey)
+	if newBucket == nil {
+		var err error
+		if newBucket, err = data.NewMetricFloat64(newKey, bucket.GetName()); err != nil {
+			return err
+		}
+		buckets := slices.Clone(r.labels)
+		newBucket.SetBuckets(&buckets)
+		newBucket.SetExportable(bucket.IsExportable())
+	}
+	newElements := make([]*matrix.Metric, len(r.labels))
+	for i, label := range r.labels {
+		key := newKey + "." + label
+		m := data.GetMetric(key)
+		if m == nil {
+			var err error
+			if m, err = data.NewMetricFloat64(key, bucket.GetName()); err != nil {
+				return err
+			}
+			m.SetLabel("metric", label)
+			m.SetLabel("comment", strconv.Itoa(i))
+			m.SetLabel("bucket", newKey)
+			m.SetHistogram(true)
+			m.SetArray(true)
+			m.SetProperty(elements[0].GetProperty())
+			m.SetUnit(elements[0].GetUnit())
+			m.SetExportable(elements[0].IsExportable())
+		}
+		newElements[i] = m
+	}
+	for _, m := range elements {
+		m.SetExportable(false)
+	}
+	bucket.SetExportable(false)
+
+	counts := make([]float64, len(elements))
+	for _, instance := range data.GetInstances() {
+		ok := true
+		integral := true
+		for i, m := range elements {
+			if counts[i], ok = m.GetValueFloat64(instance); !ok {
+				break
+			}
+			integral = integral && counts[i] == math.Trunc(counts[i])
+		}
+		if !ok {
+			continue
+		}
+		split := r.split(counts, from)
+		// counts of events stay whole numbers, rates may be fractions
+		if integral {
+			split = roundCounts(split)
+		}
+		for i, m := range newElements {
+			_ = m.SetValueFloat64(instance, split[i])
+		}
+	}
+	return nil
+}
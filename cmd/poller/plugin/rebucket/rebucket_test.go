@@ -0,0 +1,152 @@
+package rebucket
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func newRebucket(t *testing.T, boundaries ...string) (*Rebucket, error) {
+	t.Helper()
+	params := node.NewS("Rebucket")
+	b := params.NewChildS("boundaries", "")
+	for _, boundary := range boundaries {
+		b.NewChildS("", boundary)
+	}
+	r := New(plugin.New("ZapiPerf", nil, params, nil, "volume", nil))
+	return r, r.Init()
+}
+
+// newHistogram returns a matrix with a histogram like the ones of ZapiPerf, and an instance with counts
+func newHistogram(t *testing.T, buckets []string, counts []float64) (*matrix.Matrix, *matrix.Instance) {
+	t.Helper()
+	data := matrix.New("ZapiPerf.volume", "volume", "volume")
+	bucket, _ := data.NewMetricFloat64("read_latency_hist.bucket", "read_latency_histogram")
+	bucket.SetBuckets(&buckets)
+	instance, _ := data.NewInstance("vol1")
+	for i, name := range buckets {
+		m, _ := data.NewMetricFloat64("read_latency_hist."+name, "read_latency_histogram")
+		m.SetLabel("metric", name)
+		m.SetLabel("comment", strconv.Itoa(i))
+		m.SetLabel("bucket", "read_latency_hist.bucket")
+		m.SetHistogram(true)
+		m.SetProperty("delta")
+		_ = m.SetValueFloat64(instance, counts[i])
+	}
+	return data, instance
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name       string
+		boundaries []string
+		want       []string
+		wantErr    bool
+	}{
+		{name: "units", boundaries: []string{"500us", "1ms", "1500us", "2s"}, want: []string{"<500us", "<1ms", "<1500us", "<2s", ">2s"}},
+		{name: "none", wantErr: true},
+		{name: "not a duration", boundaries: []string{"fast"}, wantErr: true},
+		{name: "below microsecond", boundaries: []string{"500ns"}, wantErr: true},
+		{name: "not ascending", boundaries: []string{"1ms", "1ms"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRebucket(t, tt.boundaries...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, r.labels); !tt.wantErr && diff != "" {
+				t.Errorf("labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	got, err := bounds([]string{"<2us", "<1ms", "<1.5s", ">20s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]float64{{0, 2}, {2, 1_000}, {1_000, 1_500_000}, {20_000_000, math.Inf(1)}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("bounds mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, names := range [][]string{
+		{"<2us", "<1KB"},
+		{"<6us", "<2us"},
+		{">20s", "<2us"},
+		{"0-2us"},
+	} {
+		if _, err := bounds(names); err == nil {
+			t.Errorf("bounds(%v) got nil error", names)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		boundaries []string
+		counts     []float64
+		want       []float64
+	}{
+		{
+			name:       "split and overflow",
+			boundaries: []string{"4us", "10us"},
+			counts:     []float64{2, 8, 4, 1},
+			want:       []float64{6, 8, 1},
+		},
+		{
+			name:       "counts stay whole with the same total",
+			boundaries: []string{"3us"},
+			counts:     []float64{1, 1, 0, 0},
+			want:       []float64{1, 1},
+		},
+		{
+			name:       "rates are not rounded",
+			boundaries: []string{"3us"},
+			counts:     []float64{0, 0.5, 0, 0},
+			want:       []float64{0.125, 0.375},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRebucket(t, tt.boundaries...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, instance := newHistogram(t, []string{"<2us", "<6us", "<10us", ">10us"}, tt.counts)
+			if _, _, err := r.Run(map[string]*matrix.Matrix{"volume": data}); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]float64, 0, len(r.labels))
+			for i, label := range r.labels {
+				m := data.GetMetric("read_latency_hist.rebucket." + label)
+				if m == nil {
+					t.Fatalf("bucket %s not found", label)
+				}
+				if !m.IsHistogram() || !m.IsExportable() || m.GetName() != "read_latency_histogram" ||
+					m.GetLabel("bucket") != "read_latency_hist.rebucket" || m.GetLabel("comment") != strconv.Itoa(i) {
+					t.Errorf("bucket %s is not an exported element of the histogram", label)
+				}
+				v, _ := m.GetValueFloat64(instance)
+				got = append(got, v)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("counts mismatch (-want +got):\n%s", diff)
+			}
+			if data.GetMetric("read_latency_hist.<2us").IsExportable() || data.GetMetric("read_latency_hist.bucket").IsExportable() {
+				t.Errorf("the buckets of ONTAP are still exported")
+			}
+			if n := len(*data.GetMetric("read_latency_hist.rebucket").Buckets()); n != len(r.labels) {
+				t.Errorf("new histogram has %d buckets, want %d", n, len(r.labels))
+			}
+		})
+	}
+}
@@ -26,18 +26,23 @@ package main
 import (
 	"bytes"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/aiqum"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/configbackup"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/ems"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/health"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/keyperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/restperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/simple"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/statperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/storagegrid"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/unix"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/zapi/collector"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/zapiperf"
+	"github.com/netapp/harvest/v2/cmd/exporters/api"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
 	"github.com/netapp/harvest/v2/cmd/exporters/influxdb"
 	"github.com/netapp/harvest/v2/cmd/exporters/prometheus"
 	"github.com/netapp/harvest/v2/cmd/harvest/version"
@@ -46,11 +51,17 @@ import (
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/poller/schedule"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
 	"github.com/netapp/harvest/v2/pkg/api/ontapi/zapi"
 	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/bus"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/guard"
+	"github.com/netapp/harvest/v2/pkg/hook"
+	"github.com/netapp/harvest/v2/pkg/lease"
 	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/maintenance"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/requests"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
@@ -58,16 +69,17 @@ import (
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/shirou/gopsutil/v4/process"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 	"io"
 	"math"
 	"net/http"
 	_ "net/http/pprof" // #nosec since pprof is off by default
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"regexp"
 	"runtime"
+	"runtime/debug"
 	"slices"
 	"strconv"
 	"strings"
@@ -125,9 +137,18 @@ type Poller struct {
 	metadata        *matrix.Matrix
 	metadataTarget  *matrix.Matrix // exported as metadata_target_
 	status          *matrix.Matrix // exported as poller_status
-	certPool        *x509.CertPool
+	certificates    *matrix.Matrix // exported as metadata_certificate_
+	adminTLS        *tls.Config
 	client          *http.Client
 	auth            *auth.Credentials
+	maintenance     *maintenance.Calendar
+	lease           *lease.Elector
+	hooks           *hook.Hooks
+	bus             *bus.Bus
+	guard           *guard.Guard
+	budget          *util.Budget  // error budget of the REST calls to the target, nil when the target has no address
+	warmUp          time.Duration // the first polls of collectors are staggered over warmUp
+	logLevels       logLevels
 	hasPromExporter bool
 	maxRssBytes     uint64
 }
@@ -147,8 +168,8 @@ func (p *Poller) Init() error {
 	p.name = opts.Poller
 
 	zeroLogLevel := logging.GetZerologLevel(p.options.LogLevel)
-	// if we are a daemon, use file logging
-	if p.options.Daemon {
+	// if we are a daemon or a Windows service, use file logging
+	if p.options.Daemon || logging.IsService() {
 		fileLoggingEnabled = true
 	} else {
 		consoleLoggingEnabled = !p.options.LogToFile
@@ -192,6 +213,24 @@ func (p *Poller) Init() error {
 		logMaxBackups = p.params.LogMaxFiles
 	}
 
+	// maximum number of days to keep rotated files
+	if p.params.LogMaxAge != 0 {
+		logMaxAge = p.params.LogMaxAge
+	}
+
+	// interval to rotate the file, regardless of its size
+	var logRotateEvery time.Duration
+	if p.params.LogRotateEvery != "" {
+		logRotateEvery, err = time.ParseDuration(p.params.LogRotateEvery)
+		if err != nil {
+			logging.Get().SubLogger("Poller", p.name).Error().
+				Str("log_rotate_every", p.params.LogRotateEvery).
+				Err(err).
+				Msg("Invalid log_rotate_every")
+			return err
+		}
+	}
+
 	logConfig := logging.LogConfig{ConsoleLoggingEnabled: consoleLoggingEnabled,
 		PrefixKey:          "Poller",
 		PrefixValue:        p.name,
@@ -201,7 +240,10 @@ func (p *Poller) Init() error {
 		Filename:           logFileName,
 		MaxSize:            logMaxMegaBytes,
 		MaxBackups:         logMaxBackups,
-		MaxAge:             logMaxAge}
+		MaxAge:             logMaxAge,
+		RotateEvery:        logRotateEvery,
+		EventLogEnabled:    p.params.LogToEventLog,
+		EventLogSource:     logging.DefaultEventLogSource}
 
 	logger = logging.Configure(logConfig)
 
@@ -227,23 +269,40 @@ func (p *Poller) Init() error {
 		EmbedObject(p.options).
 		Msg("Init")
 
+	// loading the config ignores unknown keys, so warn about them, e.g. typos
+	for _, problem := range conf.Validate(configPath) {
+		logger.Warn().Str("problem", problem.Error()).Msg("Invalid config, see harvest doctor config")
+	}
+
 	// set signal handler for graceful termination
 	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel, SIGNALS...)
 	go p.handleSignals(signalChannel)
 	logger.Debug().Msgf("set signal handler for %v", SIGNALS)
 
+	// log levels of collectors, re-read from the config file on reloadSignals
+	p.setFileLogLevels(p.params.LogLevels)
+	p.watchReload()
+
 	if conf.Config.Admin.Httpsd.TLS.CertFile != "" {
 		util.CheckCert(conf.Config.Admin.Httpsd.TLS.CertFile, "ssl_cert", p.options.Config, *logger.Logger)
-		cert, err := os.ReadFile(conf.Config.Admin.Httpsd.TLS.CertFile)
-		if err != nil {
-			logger.Fatal().Str("certFile", conf.Config.Admin.Httpsd.TLS.CertFile).Msg("Unable to read cert file")
+		options := conf.Config.Admin.Httpsd.TLS.Options()
+		if options.CAFile == "" {
+			// the certificate of the admin node is usually self-signed, trust it
+			options.CAFile = conf.Config.Admin.Httpsd.TLS.CertFile
 		}
-		certPool := x509.NewCertPool()
-		if ok := certPool.AppendCertsFromPEM(cert); !ok {
-			logger.Fatal().Str("certFile", conf.Config.Admin.Httpsd.TLS.CertFile).Msg("Unable to parse cert")
+		if options.MinVersion == "" {
+			// the admin node is a Harvest server, it accepts the same minimum version as the servers of Harvest
+			options.MinVersion = "tls13"
 		}
-		p.certPool = certPool
+		tlsConfig, err := options.Client()
+		if tlsConfig.RootCAs == nil {
+			logger.Fatal().Err(err).Str("caFile", options.CAFile).Msg("Unable to read the CA of the admin node")
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("Invalid TLS options of the admin node, using defaults")
+		}
+		p.adminTLS = tlsConfig
 	}
 	// announce startup
 	if p.options.Daemon {
@@ -263,6 +322,51 @@ func (p *Poller) Init() error {
 	// create a shared auth service that all collectors will use
 	p.auth = auth.NewCredentials(p.params, logger)
 
+	// REST clients of the target record their calls in the same error budget
+	if p.params.Addr != "" {
+		p.budget = rest.BudgetFor(p.params)
+	}
+
+	// collectors share the data they collect through the bus
+	p.bus = bus.New()
+
+	// maintenance windows are shared by all collectors
+	if p.maintenance, err = maintenance.New(p.params.Maintenance); err != nil {
+		logger.Error().Err(err).Msg("Invalid maintenance window")
+		return err
+	}
+
+	// hook scripts are shared by all collectors
+	if p.hooks, err = hook.New(p.params.Hooks); err != nil {
+		logger.Error().Err(err).Msg("Invalid hook")
+		return err
+	}
+
+	// only the poller of a pair that holds the lease exports
+	if p.lease, err = lease.New(p.params.Lease, p.name, logger); err != nil {
+		logger.Error().Err(err).Msg("Invalid lease")
+		return err
+	}
+	p.lease.Start()
+
+	// memory and cardinality limits are shared by all collectors
+	if p.guard, err = guard.New(p.params.ResourceGuard); err != nil {
+		logger.Error().Err(err).Msg("Invalid resource guard")
+		return err
+	}
+	// the first polls of collectors are staggered over the warm-up window
+	if p.params.WarmUp != "" {
+		if p.warmUp, err = time.ParseDuration(p.params.WarmUp); err != nil || p.warmUp < 0 {
+			logger.Error().Err(err).Str("warm_up", p.params.WarmUp).Msg("Invalid warm_up")
+			return fmt.Errorf("invalid warm_up %q, must be a duration like 5m", p.params.WarmUp)
+		}
+	}
+	// the garbage collector works harder as the poller gets close to its hard memory limit,
+	// unless the limit is set with GOMEMLIMIT
+	if limit := p.guard.HardMemoryBytes(); limit > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(limit)
+	}
+
 	// initialize our metadata, the metadata will host the status of our
 	// collectors and exporters, as well as ping stats to target host
 	p.loadMetadata()
@@ -303,7 +407,7 @@ func (p *Poller) Init() error {
 	}
 
 	// for each object, only allow one of config & perf collectors to start
-	uniqueOCs := uniquifyObjectCollectors(objectsToCollectors)
+	uniqueOCs := withShadows(uniquifyObjectCollectors(objectsToCollectors))
 
 	// start the uniqueified collectors
 	err = p.loadCollectorObject(uniqueOCs)
@@ -347,6 +451,11 @@ func (p *Poller) Init() error {
 		return err
 	}
 
+	if err = p.schedule.NewTaskString("certificates", certificateSchedule, 0, p.checkCertificates, true, "poller_certificates_"+p.name); err != nil {
+		logger.Error().Err(err).Msg("set schedule:")
+		return err
+	}
+
 	logger.Debug().
 		Str("pollerSchedule", pollerSchedule).
 		Str("pollerLogSchedule", pollerLogSchedule).
@@ -479,6 +588,12 @@ func (p *Poller) Start() {
 
 	go p.startHeartBeat()
 
+	// stagger the first polls of collectors, heaviest last
+	if p.warmUp > 0 {
+		logger.Info().Str("warm_up", p.warmUp.String()).Int("collectors", len(p.collectors)).Msg("Warming up")
+		collector.WarmUp(p.collectors, p.warmUp)
+	}
+
 	// start collectors
 	for _, col = range p.collectors {
 		wg.Add(1)
@@ -504,12 +619,18 @@ func (p *Poller) Run() {
 	task := p.schedule.GetTask("poller")
 	asupTask := p.schedule.GetTask("asup")
 	logTask := p.schedule.GetTask("log")
+	certificateTask := p.schedule.GetTask("certificates")
 
 	// number of collectors/exporters that are still up
 	upCollectors := 0
 	upExporters := 0
 
 	for {
+		// certificates are checked before the poller task, so they are exported with the first metadata
+		if certificateTask.IsDue() {
+			_, _ = certificateTask.Run()
+		}
+
 		if task.IsDue() {
 			task.Start()
 			// flush metadata
@@ -528,11 +649,22 @@ func (p *Poller) Run() {
 				_ = p.status.LazySetValueUint8("status", "host", 0)
 			}
 
-			p.addMemoryMetadata()
+			rss := p.addMemoryMetadata()
+			p.checkGuard(rss)
+
+			if p.lease != nil {
+				var holder uint8
+				if p.lease.IsHolder() {
+					holder = 1
+				}
+				_ = p.status.LazySetValueUint8("lease_holder", "host", holder)
+			}
 
 			// add number of goroutines to metadata
 			_ = p.metadataTarget.LazySetValueInt64("goroutines", "host", int64(runtime.NumGoroutine()))
 
+			p.addBudgetMetadata()
+
 			upc := 0 // up collectors
 			upe := 0 // up exporters
 
@@ -544,9 +676,10 @@ func (p *Poller) Run() {
 					upc++
 				}
 
-				key := c.GetName() + "." + c.GetObject()
+				key := collectorKey(c)
 
 				_ = p.metadata.LazySetValueUint64("count", key, c.GetCollectCount())
+				_ = p.metadata.LazySetValueUint64("gaps", key, c.CheckGaps(time.Now()))
 				_ = p.metadata.LazySetValueUint8("status", key, code)
 
 				if msg != "" {
@@ -589,6 +722,11 @@ func (p *Poller) Run() {
 				if _, err := ee.Export(p.status); err != nil {
 					logger.Error().Err(err).Msg("export poller status:")
 				}
+				if len(p.certificates.GetInstances()) > 0 {
+					if _, err := ee.Export(p.certificates); err != nil {
+						logger.Error().Err(err).Msg("export certificate metadata:")
+					}
+				}
 			}
 
 			// only log when there are changes, which we expect to be infrequent
@@ -615,6 +753,7 @@ func (p *Poller) Run() {
 // Stop gracefully exits the program by closing zeroLog
 func (p *Poller) Stop() {
 	logger.Info().Msgf("cleaning up and stopping [pid=%d]", os.Getpid())
+	p.lease.Stop()
 }
 
 // set up signal disposition
@@ -706,7 +845,7 @@ func (p *Poller) readObjects(c conf.Collector) ([]objectCollector, error) {
 		return nil, fmt.Errorf("no templates loaded for %s", c.Name)
 	}
 	// add the poller's parameters to the collector's parameters
-	Union2(template, p.params)
+	collector.Union2(template, p.params)
 	template.NewChildS("poller_name", p.params.Name)
 
 	objects := make([]objectCollector, 0)
@@ -729,13 +868,43 @@ func (p *Poller) readObjects(c conf.Collector) ([]objectCollector, error) {
 		return nil, errs.New(errs.ErrMissingParam, "collector object")
 	}
 
+	shadows, err := collector.ParseShadows(template)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidParam, err.Error())
+	}
+	for i := range objects {
+		objects[i].shadow = shadows[objects[i].object]
+	}
+
 	return objects, nil
 }
 
 type objectCollector struct {
-	class    string
-	object   string
-	template *node.Node
+	class     string
+	object    string
+	template  *node.Node
+	shadow    *collector.Shadow // shadow of the object, nil when there is none
+	shadowing bool              // true for the shadow collector of the object
+}
+
+// withShadows adds a shadow collector for each collector whose object has a shadow
+func withShadows(ocs []objectCollector) []objectCollector {
+	for _, oc := range ocs {
+		if oc.shadow != nil && !oc.shadowing {
+			oc.shadowing = true
+			ocs = append(ocs, oc)
+		}
+	}
+	return ocs
+}
+
+// collectorKey returns the key of a collector in the metadata of the poller
+func collectorKey(c collector.Collector) string {
+	key := c.GetName() + "." + c.GetObject()
+	if c.IsShadow() {
+		key += ".shadow"
+	}
+	return key
 }
 
 // dynamically load and initialize a collector
@@ -746,7 +915,7 @@ func (p *Poller) loadCollectorObject(ocs []objectCollector) error {
 	logger.Debug().Int("collectors", len(ocs)).Msg("Starting collectors")
 
 	for _, oc := range ocs {
-		col, err := p.newCollector(oc.class, oc.object, oc.template)
+		col, err := p.newCollector(oc)
 		if err != nil {
 			switch {
 			case errors.Is(err, errs.ErrConnection):
@@ -795,13 +964,16 @@ func (p *Poller) loadCollectorObject(ocs []objectCollector) error {
 
 		// update metadata
 
-		instance, err := p.metadata.NewInstance(name + "." + obj)
+		instance, err := p.metadata.NewInstance(collectorKey(col))
 		if err != nil {
 			return err
 		}
 		instance.SetLabel("type", "collector")
 		instance.SetLabel("name", name)
 		instance.SetLabel("target", obj)
+		if col.IsShadow() {
+			instance.SetLabel("shadow", "true")
+		}
 	}
 
 	return nil
@@ -846,67 +1018,8 @@ func collectorContains(unique []objectCollector, searches ...string) bool {
 	return false
 }
 
-// Union2 merges the fields of a Poller with the fields of a node.
-// This is a way to bridge the struct world with the string typed world.
-// If one of the poller field's does not exist in hNode, it will be copied
-// from poller to hNode.
-// If the field already exists in hNode, nothing is copied.
-// Instead of comparing each field of the poller individually and being forced
-// to keep this method in sync with the Poller struct, reflection via yaml marshaling
-// is used to do the comparison. First the poller is marshaled to yaml and then
-// unmarshalled into a list of generic yaml node. Each generic yaml node is walked, checking
-// if there is a corresponding node in hNode, when there isn't one, a new hNode is created
-// and populated with the yaml node's content. Finally, the new hNode is added to its parent
-func Union2(hNode *node.Node, poller *conf.Poller) {
-	marshal, err := yaml.Marshal(poller)
-	if err != nil {
-		return
-	}
-	root := yaml.Node{}
-	err = yaml.Unmarshal(marshal, &root)
-	if err != nil {
-		return
-	}
-	rootContent := root.Content[0]
-	if rootContent.Kind == yaml.MappingNode {
-		for index, yNode := range rootContent.Content {
-			// since rootContent is a mapping node every other yNode is a key
-			if index%2 == 0 && yNode.Tag == "!!str" {
-				// If the harvest node is missing this key, add it the harvest node
-				if !hNode.HasChildS(yNode.Value) {
-					// create a new harvest node to contain the missing content
-					newNode := node.NewS(yNode.Value)
-					// this is the value that goes along with the key from yNode
-					valNode := rootContent.Content[index+1]
-					switch valNode.Tag {
-					case "!!str", "!!bool":
-						newNode.Content = []byte(valNode.Value)
-					case "!!seq":
-						// the poller node that is missing is a sequence so add all the children of the sequence
-						for _, seqNode := range valNode.Content {
-							if seqNode.Tag == "!!str" {
-								newNode.NewChildS(seqNode.Value, seqNode.Value)
-							} else if seqNode.Tag == "!!map" {
-								for ci := 0; ci < len(seqNode.Content); ci += 2 {
-									newNode.NewChildS(seqNode.Content[ci].Value, seqNode.Content[ci+1].Value)
-								}
-							}
-						}
-					case "!!map":
-						// the poller node that is missing is a map, add all the children of the map
-						for ci := 0; ci < len(valNode.Content); ci += 2 {
-							newNode.NewChildS(valNode.Content[ci].Value, valNode.Content[ci+1].Value)
-						}
-					}
-					hNode.AddChild(newNode)
-				}
-			}
-		}
-	}
-}
-
-func (p *Poller) newCollector(class string, object string, template *node.Node) (collector.Collector, error) {
-	name := "harvest.collector." + strings.ToLower(class)
+func (p *Poller) newCollector(oc objectCollector) (collector.Collector, error) {
+	name := "harvest.collector." + strings.ToLower(oc.class)
 	mod, err := plugin.GetModule(name)
 	if err != nil {
 		return nil, fmt.Errorf("error getting module %s err: %w", name, err)
@@ -916,7 +1029,23 @@ func (p *Poller) newCollector(class string, object string, template *node.Node)
 	if !ok {
 		return nil, errs.New(errs.ErrNoCollector, "no collectors")
 	}
-	delegate := collector.New(class, object, p.options, template.Copy(), p.auth)
+	template := oc.template.Copy()
+	if oc.shadowing {
+		// the shadow collects its object with the template of the shadow
+		objects := template.GetChildS("objects")
+		if objects == nil {
+			objects = template.NewChildS("objects", "")
+		}
+		objects.SetChildContentS(oc.object, oc.shadow.Template)
+	}
+	delegate := collector.New(oc.class, oc.object, p.options, template, p.auth)
+	delegate.Shadow = oc.shadow
+	delegate.Shadowing = oc.shadowing
+	delegate.Maintenance = p.maintenance
+	delegate.Lease = p.lease
+	delegate.Hooks = p.hooks
+	delegate.Bus = p.bus
+	delegate.Guard = p.guard
 	err = col.Init(delegate)
 	return col, err
 }
@@ -954,6 +1083,10 @@ func (p *Poller) loadExporter(name string) exporter.Exporter {
 		exp = prometheus.New(absExp)
 	case "InfluxDB":
 		exp = influxdb.New(absExp)
+	case "File":
+		exp = file.New(absExp)
+	case "API":
+		exp = api.New(absExp)
 	default:
 		logger.Error().Msgf("no exporter of name:type %s:%s", name, class)
 		return nil
@@ -993,6 +1126,7 @@ func (p *Poller) loadMetadata() {
 	p.metadata = matrix.New("poller", "metadata_component", "metadata_component")
 	_, _ = p.metadata.NewMetricUint8("status")
 	_, _ = p.metadata.NewMetricUint64("count")
+	_, _ = p.metadata.NewMetricUint64("gaps")
 	p.metadata.SetGlobalLabel("poller", p.name)
 	p.metadata.SetGlobalLabel("version", p.options.Version)
 	p.metadata.SetGlobalLabel("datacenter", p.params.Datacenter)
@@ -1007,11 +1141,23 @@ func (p *Poller) loadMetadata() {
 	_, _ = p.metadataTarget.NewMetricUint8("status")
 	_, _ = p.metadataTarget.NewMetricFloat64("ping")
 	_, _ = p.metadataTarget.NewMetricUint64("goroutines")
+	_, _ = p.metadataTarget.NewMetricUint64("api_calls")
+	_, _ = p.metadataTarget.NewMetricFloat64("api_success_ratio")
+	_, _ = p.metadataTarget.NewMetricFloat64("api_latency_p95")
+	for _, class := range util.ErrorClasses {
+		mm, _ := p.metadataTarget.NewMetricType("api_errors."+class, "uint64", "api_errors")
+		mm.SetLabel("class", class)
+	}
 
 	// metadata for the poller itself
 	p.status = matrix.New("poller", "poller", "poller_target")
 	_, _ = p.status.NewMetricUint8("status")
 	_, _ = p.status.NewMetricFloat64("memory_percent")
+	_, _ = p.status.NewMetricUint8("guard_level")
+	_, _ = p.status.NewMetricUint64("series")
+	if p.lease != nil {
+		_, _ = p.status.NewMetricUint8("lease_holder")
+	}
 	newMemoryMetric(p.status, "memory", "rss")
 	newMemoryMetric(p.status, "memory", "vms")
 	newMemoryMetric(p.status, "memory", "swap")
@@ -1039,9 +1185,18 @@ func (p *Poller) loadMetadata() {
 		p.status.SetGlobalLabel("promport", strconv.Itoa(p.options.PromPort))
 	}
 
+	// expiry of the TLS certificates of the poller and its exporters
+	p.certificates = matrix.New("poller", "metadata_certificate", "metadata_certificate")
+	_, _ = p.certificates.NewMetricFloat64("days_to_expiry")
+	for i := 0; i < len(globalKVs); i += 2 {
+		p.certificates.SetGlobalLabel(globalKVs[i], globalKVs[i+1])
+	}
+	p.certificates.SetExportOptions(matrix.DefaultExportOptions())
+
 	labels := p.params.Labels
 	if labels != nil {
 		for _, labelPtr := range *labels {
+			p.certificates.SetGlobalLabels(labelPtr)
 			p.metadata.SetGlobalLabels(labelPtr)
 			p.metadataTarget.SetGlobalLabels(labelPtr)
 			p.status.SetGlobalLabels(labelPtr)
@@ -1051,6 +1206,24 @@ func (p *Poller) loadMetadata() {
 	p.status.SetExportOptions(matrix.DefaultExportOptions())
 }
 
+// addBudgetMetadata adds the error budget of the REST calls to the target in the last util.BudgetWindow.
+// Nothing is added when there were no REST calls, e.g. when the poller only has ZAPI collectors
+func (p *Poller) addBudgetMetadata() {
+	if p.budget == nil {
+		return
+	}
+	stats := p.budget.Stats()
+	if stats.Calls == 0 {
+		return
+	}
+	_ = p.metadataTarget.LazySetValueUint64("api_calls", "host", stats.Calls)
+	_ = p.metadataTarget.LazySetValueFloat64("api_success_ratio", "host", stats.SuccessRatio)
+	_ = p.metadataTarget.LazySetValueFloat64("api_latency_p95", "host", float64(stats.P95Latency.Microseconds())/1000)
+	for _, class := range util.ErrorClasses {
+		_ = p.metadataTarget.LazySetValueUint64("api_errors."+class, "host", stats.Errors[class])
+	}
+}
+
 func newMemoryMetric(status *matrix.Matrix, label string, sub string) {
 	fullLabel := label + "." + sub
 	mm, _ := status.NewMetricType(fullLabel, "uint64", label)
@@ -1079,9 +1252,10 @@ func (p *Poller) targetIsOntap() bool {
 }
 
 type pollerDetails struct {
-	Name string `json:"Name,omitempty"`
-	IP   string `json:"IP,omitempty"`
-	Port int    `json:"Port,omitempty"`
+	Name   string `json:"Name,omitempty"`
+	IP     string `json:"IP,omitempty"`
+	Port   int    `json:"Port,omitempty"`
+	Scheme string `json:"Scheme,omitempty"`
 }
 
 func (p *Poller) publishDetails() {
@@ -1094,6 +1268,7 @@ func (p *Poller) publishDetails() {
 		return
 	}
 	exporterIP := "127.0.0.1"
+	scheme := "http"
 	heartBeatURL := ""
 	for _, exporterName := range p.params.Exporters {
 		exp, ok := p.exporterParams[exporterName]
@@ -1112,6 +1287,9 @@ func (p *Poller) publishDetails() {
 		if exp.HeartBeatURL != "" {
 			heartBeatURL = exp.HeartBeatURL
 		}
+		if exp.TLS.KeyFile != "" {
+			scheme = "https"
+		}
 	}
 
 	if !p.hasPromExporter {
@@ -1120,9 +1298,10 @@ func (p *Poller) publishDetails() {
 	}
 
 	details := pollerDetails{
-		Name: p.name,
-		IP:   exporterIP,
-		Port: p.options.PromPort,
+		Name:   p.name,
+		IP:     exporterIP,
+		Port:   p.options.PromPort,
+		Scheme: scheme,
 	}
 	payload, err := json.Marshal(details)
 	if err != nil {
@@ -1178,17 +1357,16 @@ func (p *Poller) publishDetails() {
 	}
 }
 
-// startHeartBeat never returns unless the receiver does not have a Prometheus exporter
-// Publish the receiver's discovery details to the admin node
+// startHeartBeat never returns unless the admin node is not configured.
+// Publish the receiver's discovery details to the admin node and fetch its runtime maintenance windows and log levels
 func (p *Poller) startHeartBeat() {
 	if conf.Config.Admin.Httpsd.Listen == "" {
 		return
 	}
 	p.createClient()
 	p.publishDetails()
-	if !p.hasPromExporter {
-		return
-	}
+	p.syncMaintenance()
+	p.syncLogLevels()
 	if conf.Config.Admin.Httpsd.HeartBeat == "" {
 		conf.Config.Admin.Httpsd.HeartBeat = "45s"
 	}
@@ -1201,29 +1379,68 @@ func (p *Poller) startHeartBeat() {
 	tick := time.Tick(duration)
 	for range tick {
 		p.publishDetails()
+		p.syncMaintenance()
+		p.syncLogLevels()
 	}
 }
 
 func (p *Poller) makePublishURL() string {
+	return p.makeAdminURL("/api/v1/sd")
+}
+
+func (p *Poller) makeAdminURL(api string) string {
 	// Listen will be one of: localhost:port, :port, ip:port
 	schema := "http"
 	if conf.Config.Admin.Httpsd.TLS.CertFile != "" {
 		schema = "https"
 	}
 	if strings.HasPrefix(conf.Config.Admin.Httpsd.Listen, ":") {
-		return fmt.Sprintf("%s://127.0.0.1:%s/api/v1/sd", schema, conf.Config.Admin.Httpsd.Listen[1:])
+		return fmt.Sprintf("%s://127.0.0.1:%s%s", schema, conf.Config.Admin.Httpsd.Listen[1:], api)
+	}
+	return fmt.Sprintf("%s://%s%s", schema, conf.Config.Admin.Httpsd.Listen, api)
+}
+
+// syncMaintenance replaces the runtime maintenance windows of the poller with the ones set on the admin node
+func (p *Poller) syncMaintenance() {
+	if p.client == nil {
+		return
+	}
+	req, err := requests.New("GET", p.makeAdminURL("/api/v1/maintenance?poller="+url.QueryEscape(p.name)), nil)
+	if err != nil {
+		logger.Err(err).Msg("failed to create maintenance request")
+		return
+	}
+	user := conf.Config.Admin.Httpsd.AuthBasic.Username
+	if user != "" {
+		req.SetBasicAuth(user, conf.Config.Admin.Httpsd.AuthBasic.Password)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// publishDetails already logs when the admin node is unreachable
+		logger.Debug().Err(err).Msg("Failed to fetch maintenance windows from admin node")
+		return
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Debug().Int("httpStatusCode", resp.StatusCode).Msg("Failed to fetch maintenance windows from admin node")
+		return
+	}
+	var windows []maintenance.Window
+	if err := json.NewDecoder(resp.Body).Decode(&windows); err != nil {
+		logger.Error().Err(err).Msg("Unable to parse maintenance windows from admin node")
+		return
+	}
+	if err := p.maintenance.SetRuntime(windows); err != nil {
+		logger.Error().Err(err).Msg("Invalid maintenance window from admin node")
 	}
-	return fmt.Sprintf("%s://%s/api/v1/sd", schema, conf.Config.Admin.Httpsd.Listen)
 }
 
 func (p *Poller) createClient() {
 	if conf.Config.Admin.Httpsd.TLS.CertFile != "" {
 		p.client = &http.Client{
 			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs:    p.certPool,
-					MinVersion: tls.VersionTLS13,
-				},
+				TLSClientConfig: p.adminTLS,
 			},
 		}
 	} else {
@@ -1311,18 +1528,19 @@ func (p *Poller) mergeConfPath() {
 	p.options.SetConfPath(path)
 }
 
-func (p *Poller) addMemoryMetadata() {
+// addMemoryMetadata adds the memory of the poller to its status and returns its RSS in bytes
+func (p *Poller) addMemoryMetadata() uint64 {
 
 	pid := os.Getpid()
 	proc, err := process.NewProcess(int32(pid))
 	if err != nil {
 		logger.Error().Err(err).Int("pid", pid).Msg("Failed to lookup process for poller")
-		return
+		return 0
 	}
 	memInfo, err := proc.MemoryInfo()
 	if err != nil {
 		logger.Error().Err(err).Int("pid", pid).Msg("Failed to get memory info for poller")
-		return
+		return 0
 	}
 
 	// The unix poller used KB for memory so use the same here
@@ -1334,7 +1552,7 @@ func (p *Poller) addMemoryMetadata() {
 	memory, err := mem.VirtualMemory()
 	if err != nil {
 		logger.Error().Err(err).Int("pid", pid).Msg("Failed to get memory for machine")
-		return
+		return memInfo.RSS
 	}
 
 	memPercentage := float64(memInfo.RSS) / float64(memory.Total) * 100
@@ -1342,6 +1560,36 @@ func (p *Poller) addMemoryMetadata() {
 
 	// Update maxRssBytes
 	p.maxRssBytes = max(p.maxRssBytes, memInfo.RSS)
+	return memInfo.RSS
+}
+
+// checkGuard updates the level of the resource guard, logs when it changes, and adds it to the status of the poller
+func (p *Poller) checkGuard(rss uint64) {
+	if p.guard == nil {
+		return
+	}
+	level, previous := p.guard.Check(rss)
+	series := p.guard.Series()
+	_ = p.status.LazySetValueUint8("guard_level", "host", uint8(level))
+	_ = p.status.LazySetValueUint64("series", "host", series)
+
+	if level == previous {
+		return
+	}
+	l := logger.Info() //nolint:zerologlint
+	switch level {
+	case guard.Soft:
+		l = logger.Warn() //nolint:zerologlint
+	case guard.Hard:
+		l = logger.Error() //nolint:zerologlint
+		// return the memory of the shed objects to the OS
+		defer debug.FreeOSMemory()
+	}
+	l.Str("level", level.String()).
+		Str("previous", previous.String()).
+		Uint64("rssMB", rss/(1024*1024)).
+		Uint64("series", series).
+		Msg("Resource guard level changed")
 }
 
 func (p *Poller) logPollerMetadata() (map[string]*matrix.Matrix, error) {
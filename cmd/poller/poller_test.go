@@ -1,14 +1,25 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/conf"
-	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"math"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPingParsing(t *testing.T) {
@@ -62,39 +73,6 @@ round-trip min/avg/max = 0.088/0.088/0.088 ms`,
 	}
 }
 
-func TestUnion2(t *testing.T) {
-	configPath := "../../cmd/tools/doctor/testdata/testConfig.yml"
-	n := node.NewS("foople")
-	conf.TestLoadHarvestConfig(configPath)
-	p, err := conf.PollerNamed("infinity2")
-	if err != nil {
-		panic(err)
-	}
-	Union2(n, p)
-	labels := n.GetChildS("labels")
-	if labels == nil {
-		t.Fatal("got nil, want labels")
-	}
-	type label struct {
-		key string
-		val string
-	}
-	wants := []label{
-		{key: "org", val: "abc"},
-		{key: "site", val: "RTP"},
-		{key: "floor", val: "3"},
-	}
-	for i, c := range labels.Children {
-		want := wants[i]
-		if want.key != c.GetNameS() {
-			t.Errorf("got key=%s, want=%s", c.GetNameS(), want.key)
-		}
-		if want.val != c.GetContentS() {
-			t.Errorf("got key=%s, want=%s", c.GetContentS(), want.val)
-		}
-	}
-}
-
 func TestPublishUrl(t *testing.T) {
 	poller := Poller{}
 
@@ -244,6 +222,23 @@ func Test_uniquifyObjectCollectors(t *testing.T) {
 	}
 }
 
+func Test_withShadows(t *testing.T) {
+	shadow := &collector.Shadow{Template: "volume_next.yaml", Prefix: "shadow"}
+	ocs := []objectCollector{
+		{class: "Rest", object: "Volume", shadow: shadow},
+		{class: "Rest", object: "Qtree"},
+	}
+	got := withShadows(ocs)
+	want := []objectCollector{
+		{class: "Rest", object: "Volume", shadow: shadow},
+		{class: "Rest", object: "Qtree"},
+		{class: "Rest", object: "Volume", shadow: shadow, shadowing: true},
+	}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(objectCollector{}), cmp.Comparer(func(a, b *collector.Shadow) bool { return a == b })); diff != "" {
+		t.Errorf("Mismatch (-got +want):\n%s", diff)
+	}
+}
+
 func objectCollectorMap(constructors ...string) map[string][]objectCollector {
 	objectsToCollectors := make(map[string][]objectCollector)
 
@@ -259,3 +254,100 @@ func objectCollectorMap(constructors ...string) map[string][]objectCollector {
 
 	return objectsToCollectors
 }
+
+func TestCheckCertificates(t *testing.T) {
+	dir := t.TempDir()
+	writeCert := func(name string, notAfter time.Time) string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name+".pem")
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ca := writeCert("ca", time.Now().Add(90*24*time.Hour))
+	server := writeCert("server", time.Now().Add(-24*time.Hour))
+
+	p := Poller{
+		options: &options.Options{},
+		params:  &conf.Poller{CaCertPath: ca, Exporters: []string{"prom", "unknown"}},
+		exporterParams: map[string]conf.Exporter{
+			"prom": {TLS: conf.TLS{CertFile: server, CAFile: filepath.Join(dir, "missing.pem")}},
+		},
+	}
+	p.loadMetadata()
+	if _, err := p.checkCertificates(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int)
+	for key, instance := range p.certificates.GetInstances() {
+		days, _ := p.certificates.GetMetric("days_to_expiry").GetValueFloat64(instance)
+		got[key] = int(math.Round(days))
+	}
+	want := map[string]int{
+		"poller.ca." + ca:       90,
+		"prom.server." + server: -1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("checkCertificates() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// reloadingCollector counts the reloads queued for it
+type reloadingCollector struct {
+	*collector.AbstractCollector
+	queued int
+}
+
+func (r *reloadingCollector) Init(*collector.AbstractCollector) error {
+	return nil
+}
+
+func (r *reloadingCollector) QueueReload(func() error) {
+	r.queued++
+}
+
+func (r *reloadingCollector) ReloadCounters() error {
+	return nil
+}
+
+func TestHandleReload(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "harvest.yml")
+	c := &reloadingCollector{AbstractCollector: collector.New("RestPerf", "Volume", options.New(), nil, nil)}
+	p := &Poller{name: "cluster-01", options: &options.Options{Config: config}, collectors: []collector.Collector{c}}
+
+	reload := func() {
+		signals := make(chan os.Signal, 1)
+		signals <- os.Interrupt
+		close(signals)
+		p.handleReload(signals)
+	}
+
+	// the config file can not be read, the counters are not reloaded
+	reload()
+	if c.queued != 0 {
+		t.Errorf("reloads got=%d, want=0 when the config can not be read", c.queued)
+	}
+
+	if err := os.WriteFile(config, []byte("Pollers:\n  cluster-01:\n    addr: 10.0.0.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reload()
+	if c.queued != 1 {
+		t.Errorf("reloads got=%d, want=1", c.queued)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals make the poller re-read the log levels of its config file
+var reloadSignals = []os.Signal{syscall.SIGUSR1}
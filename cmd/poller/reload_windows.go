@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// reloadSignals are empty since Windows has no SIGUSR1, use the admin API to change log levels
+var reloadSignals []os.Signal
@@ -28,15 +28,102 @@
 //
 // Schedule is meant to be used by at most one goroutine and is not
 // concurrent-safe.
+//
+// Collectors use a Scheduler, Schedule is the default one. Custom builds can
+// plug other scheduling strategies, e.g. cron expressions, with Register and
+// select them with the scheduler parameter of a template.
 
 package schedule
 
 import (
+	"context"
+	"fmt"
 	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"sync"
 	"time"
 )
 
+// DefaultScheduler is the name of Schedule in the registry of schedulers
+const DefaultScheduler = "interval"
+
+// Scheduler decides when the tasks of a collector run. The collector runs the due tasks, reports the failing ones
+// with the retry states, and sleeps until the next task is due
+type Scheduler interface {
+	// NewTask adds task n that runs f every interval i, see Schedule.NewTask
+	NewTask(n string, i time.Duration, jitter time.Duration, f func() (map[string]*matrix.Matrix, error), runNow bool, identifier string) error
+	// NewTaskString adds a task, the interval is parsed from i
+	NewTaskString(n, i string, jitter time.Duration, f func() (map[string]*matrix.Matrix, error), runNow bool, identifier string) error
+	// GetTask returns the task named n or nil if it doesn't exist
+	GetTask(n string) *Task
+	// GetTasks returns the tasks that may run, in the order they were added
+	GetTasks() []*Task
+
+	// IsDue tells whether it's time to run t
+	IsDue(t *Task) bool
+	// Next tells the duration until at least one task is due, negative when a task is late
+	Next() time.Duration
+	// Sleep sleeps until at least one task is due
+	Sleep()
+	// Wait returns a channel that receives when at least one task is due
+	Wait() <-chan time.Time
+	// SetInterval changes the normal interval of t to i
+	SetInterval(t *Task, i time.Duration)
+	// Delay postpones all tasks by d
+	Delay(d time.Duration)
+	// Context returns the context of the running task, or the background context when no task is running
+	Context() context.Context
+
+	// IsStandBy tells whether the tasks are stalled until a failing task succeeds
+	IsStandBy() bool
+	// IsTaskStandBy tells whether t is the failing task of the standby
+	IsTaskStandBy(t *Task) bool
+	// SetStandByMode stalls the tasks until t succeeds, t is retried every i
+	SetStandByMode(t *Task, i time.Duration)
+	// SetStandByModeMax stalls the tasks until t succeeds, t is retried every i or its interval, whichever is longer
+	SetStandByModeMax(t *Task, i time.Duration)
+	// Recover ends the standby after the failing task succeeded, the stalled tasks run as soon as possible
+	Recover()
+}
+
+var _ Scheduler = (*Schedule)(nil)
+
+var (
+	schedulers   = map[string]func() Scheduler{DefaultScheduler: func() Scheduler { return New() }}
+	schedulersMu sync.RWMutex
+)
+
+// Register adds the scheduler name to the schedulers that templates can select. newScheduler returns an empty
+// scheduler for each collector. Custom builds call Register from the init function of their package
+func Register(name string, newScheduler func() Scheduler) {
+	if name == "" {
+		panic("scheduler missing name")
+	}
+	if newScheduler == nil {
+		panic("missing newScheduler of scheduler " + name)
+	}
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	if _, ok := schedulers[name]; ok {
+		panic("scheduler already registered: " + name)
+	}
+	schedulers[name] = newScheduler
+}
+
+// NewScheduler returns an empty scheduler of the registered scheduler name, or a Schedule when name is empty
+func NewScheduler(name string) (Scheduler, error) {
+	if name == "" {
+		name = DefaultScheduler
+	}
+	schedulersMu.RLock()
+	defer schedulersMu.RUnlock()
+	newScheduler, ok := schedulers[name]
+	if !ok {
+		return nil, fmt.Errorf("scheduler not registered: %s", name)
+	}
+	return newScheduler(), nil
+}
+
 // Task represents a scheduled task
 type Task struct {
 	Name       string                                    // name of the task
@@ -44,6 +131,14 @@ type Task struct {
 	timer      time.Time                                 // last time task was executed
 	foo        func() (map[string]*matrix.Matrix, error) // pointer to the function that executes the task
 	identifier string                                    // optional additional information about schedule i.e. collector name
+	slot       time.Duration                             // the normal interval, unchanged in standby
+	ctx        context.Context                           // context of the running task, nil when the task is not running
+}
+
+// MakeTask returns task n that runs f every interval i. Schedulers other than Schedule keep their tasks
+// with MakeTask, and decide when they are due from Started
+func MakeTask(n string, i time.Duration, f func() (map[string]*matrix.Matrix, error), identifier string) *Task {
+	return &Task{Name: n, interval: i, slot: i, foo: f, identifier: identifier}
 }
 
 // Start marks the task as started by updating timer
@@ -54,12 +149,48 @@ func (t *Task) Start() {
 	t.timer = time.Now()
 }
 
-// Run marks the task as started and executes it
+// Run marks the task as started and executes it. The context of the task is done when its slot ends,
+// i.e. when the task is due again on its normal schedule, so that a slow task does not delay the next poll
 func (t *Task) Run() (map[string]*matrix.Matrix, error) {
 	t.Start()
+	ctx, cancel := context.WithDeadlineCause(context.Background(), t.Deadline(),
+		errs.New(errs.ErrDeadline, "task "+t.Name+" exceeded its slot of "+t.slot.String()))
+	t.ctx = ctx
+	defer func() {
+		cancel()
+		t.ctx = nil
+	}()
 	return t.foo()
 }
 
+// Expire makes the task due now, e.g. to poll out of band
+func (t *Task) Expire() {
+	t.timer = time.Now().Add(-t.interval)
+}
+
+// Deadline tells when the slot of the task ends, the normal interval after the task started
+func (t *Task) Deadline() time.Time {
+	return t.timer.Add(t.slot)
+}
+
+// Context returns the context of the running task, or the background context when the task is not running
+func (t *Task) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// IsRunning tells whether the task is running
+func (t *Task) IsRunning() bool {
+	return t.ctx != nil
+}
+
+// Started tells when the task was last started. When the task never ran, its first interval is counted from Started
+func (t *Task) Started() time.Time {
+	return t.timer
+}
+
 // GetDuration tells duration of executing the task
 // it assumes that the task just completed
 func (t *Task) GetDuration() time.Duration {
@@ -174,7 +305,7 @@ func (s *Schedule) Recover() {
 func (s *Schedule) NewTask(n string, i time.Duration, jitter time.Duration, f func() (map[string]*matrix.Matrix, error), runNow bool, identifier string) error {
 	if s.GetTask(n) == nil {
 		if i > 0 {
-			t := &Task{Name: n, interval: i, foo: f, identifier: identifier}
+			t := MakeTask(n, i, f, identifier)
 			s.cachedInterval[n] = t.interval // remember normal interval of task
 			if runNow {
 				t.timer = time.Now().Add(-i + jitter) // set to run after jitter
@@ -198,6 +329,31 @@ func (s *Schedule) NewTaskString(n, i string, jitter time.Duration, f func() (ma
 	return s.NewTask(n, d, jitter, f, runNow, identifier)
 }
 
+// SetInterval changes the normal interval of task t to i, e.g. when a collector adapts its schedule to how often
+// the values of its object change. When t is stalled in standby, i is used once the schedule recovers
+func (s *Schedule) SetInterval(t *Task, i time.Duration) {
+	if i <= 0 {
+		return
+	}
+	s.cachedInterval[t.Name] = i
+	t.slot = i
+	if !s.standByMode || s.standByTask.Name != t.Name {
+		t.interval = i
+	}
+}
+
+// Delay postpones all tasks by d, e.g. to stagger the first polls of collectors when a poller starts
+func (s *Schedule) Delay(d time.Duration) {
+	for _, t := range s.tasks {
+		t.timer = t.timer.Add(d)
+	}
+}
+
+// IsDue tells whether it's time to run task t
+func (s *Schedule) IsDue(t *Task) bool {
+	return t.IsDue()
+}
+
 // GetTasks returns scheduled tasks
 func (s *Schedule) GetTasks() []*Task {
 	if !s.standByMode {
@@ -216,6 +372,20 @@ func (s *Schedule) GetTask(n string) *Task {
 	return nil
 }
 
+// Context returns the context of the running task of the schedule, or the background context when no task is running.
+// Collectors pass it to their requests so that requests end with the slot of the task
+func (s *Schedule) Context() context.Context {
+	if s == nil {
+		return context.Background()
+	}
+	for _, t := range s.tasks {
+		if t.IsRunning() {
+			return t.ctx
+		}
+	}
+	return context.Background()
+}
+
 // Sleep sleeps until at least one task is due
 func (s *Schedule) Sleep() {
 	time.Sleep(s.NextDue())
@@ -227,6 +397,11 @@ func (s *Schedule) Wait() <-chan time.Time {
 	return time.After(s.NextDue())
 }
 
+// Next is NextDue, it implements Scheduler
+func (s *Schedule) Next() time.Duration {
+	return s.NextDue()
+}
+
 // NextDue tells duration until at least one task is due
 // If no tasks are scheduled, NextDue returns an arbitrary long duration
 // (This is useful for collectors that run background jobs and need to
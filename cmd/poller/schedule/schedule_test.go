@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"fmt"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"testing"
 	"time"
@@ -92,3 +93,133 @@ func TestNewTaskString(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskContext(t *testing.T) {
+	s := New()
+	var deadline time.Time
+	var hasDeadline bool
+	err := s.NewTask("data", time.Minute, 0, func() (map[string]*matrix.Matrix, error) {
+		deadline, hasDeadline = s.Context().Deadline()
+		return nil, nil
+	}, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := s.GetTask("data")
+
+	// the slot of a task in standby is its normal interval
+	s.SetStandByMode(task, time.Second)
+	start := time.Now()
+	if _, err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasDeadline || deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("deadline got=%s,%t want=%s", deadline, hasDeadline, start.Add(time.Minute))
+	}
+	if _, ok := s.Context().Deadline(); ok {
+		t.Errorf("context of the schedule has a deadline when no task is running")
+	}
+	if _, ok := (*Schedule)(nil).Context().Deadline(); ok {
+		t.Errorf("context of a nil schedule has a deadline")
+	}
+}
+
+func TestSchedule_Delay(t *testing.T) {
+	s := New()
+	if err := s.NewTaskString("data", "3m", 0, nil, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !s.GetTask("data").IsDue() {
+		t.Fatal("task should be due before the delay")
+	}
+	s.Delay(time.Minute)
+	if s.GetTask("data").IsDue() {
+		t.Error("task should not be due during the delay")
+	}
+	if due := s.NextDue(); due <= 59*time.Second || due > time.Minute {
+		t.Errorf("NextDue() got=%s, want about 1m", due)
+	}
+}
+
+func TestTask_Expire(t *testing.T) {
+	s := New()
+	if err := s.NewTaskString("data", "3m", 0, nil, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	task := s.GetTask("data")
+	task.Start()
+	if task.IsDue() {
+		t.Fatal("task should not be due after it started")
+	}
+	task.Expire()
+	if !task.IsDue() {
+		t.Error("task should be due after it expired")
+	}
+}
+
+func TestSchedule_SetInterval(t *testing.T) {
+	s := setupSchedule()
+	data := s.GetTask("data")
+
+	s.SetInterval(data, 6*time.Minute)
+	if data.GetInterval() != 6*time.Minute || data.slot != 6*time.Minute {
+		t.Errorf("interval got=%s slot=%s, want=6m", data.GetInterval(), data.slot)
+	}
+
+	// the standby interval is kept until the schedule recovers
+	s.SetStandByMode(data, time.Second)
+	s.SetInterval(data, 12*time.Minute)
+	if data.GetInterval() != time.Second {
+		t.Errorf("standby interval got=%s, want=1s", data.GetInterval())
+	}
+	s.Recover()
+	if data.GetInterval() != 12*time.Minute {
+		t.Errorf("recovered interval got=%s, want=12m", data.GetInterval())
+	}
+}
+
+// everyPoll is a scheduler whose tasks are always due
+type everyPoll struct {
+	*Schedule
+}
+
+func (e everyPoll) IsDue(*Task) bool {
+	return true
+}
+
+func TestNewScheduler(t *testing.T) {
+	Register("every_poll", func() Scheduler { return everyPoll{Schedule: New()} })
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "", want: "*schedule.Schedule"},
+		{name: DefaultScheduler, want: "*schedule.Schedule"},
+		{name: "every_poll", want: "schedule.everyPoll"},
+		{name: "cron", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScheduler(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewScheduler() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := fmt.Sprintf("%T", s); got != tt.want {
+				t.Errorf("NewScheduler() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+
+	s, _ := NewScheduler("every_poll")
+	if err := s.NewTaskString("data", "3m", 0, nil, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsDue(s.GetTask("data")) {
+		t.Error("IsDue() got=false, want=true")
+	}
+}
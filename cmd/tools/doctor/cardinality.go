@@ -0,0 +1,480 @@
+package doctor
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+	"github.com/netapp/harvest/v2/pkg/util"
+	tw "github.com/netapp/harvest/v2/third_party/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type cardinalityOptions struct {
+	url       string
+	file      string
+	top       int
+	objects   int
+	minSeries int
+}
+
+var cOpts = &cardinalityOptions{}
+
+var cardinalityCmd = &cobra.Command{
+	Use:   "cardinality [POLLER]",
+	Short: "Report the series of a poller by object, label, and label value",
+	Long: `Report the number of series of a poller by object, label, and label value, and suggest labels to drop.
+The series are read from the Prometheus exporter of a running poller, from a URL,
+or from a file of metrics in the Prometheus text format, e.g. saved with curl, or of the File exporter.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  doCardinalityCmd,
+}
+
+// keptLabels are never suggested to drop: they identify the cluster, or are dimensions of array and histogram metrics
+var keptLabels = []string{"datacenter", "cluster", "metric", "submetric", "le", "bucket"}
+
+// sample is a series, without its value
+type sample struct {
+	object string
+	name   string
+	labels map[string]string
+}
+
+type objectCardinality struct {
+	name    string
+	series  int
+	metrics int
+	labels  []labelCardinality
+}
+
+type labelCardinality struct {
+	name      string
+	values    int
+	series    int // series with the label
+	reduction int // series merged away when the label is dropped
+	top       []valueCount
+}
+
+type valueCount struct {
+	value  string
+	series int
+}
+
+func doCardinalityCmd(cmd *cobra.Command, args []string) {
+	samples, err := loadSamples(cmd, args)
+	if err != nil {
+		fmt.Printf("cardinality failed: %v\n", err)
+		os.Exit(1)
+	}
+	report(os.Stdout, analyze(samples, cOpts.top), cOpts.objects, cOpts.minSeries)
+}
+
+func loadSamples(cmd *cobra.Command, args []string) ([]sample, error) {
+	sources := len(args)
+	if cOpts.url != "" {
+		sources++
+	}
+	if cOpts.file != "" {
+		sources++
+	}
+	if sources != 1 {
+		return nil, errs.New(errs.ErrInvalidParam, "pass one of POLLER, --url, or --file")
+	}
+
+	if cOpts.file != "" {
+		f, err := os.Open(cOpts.file)
+		if err != nil {
+			return nil, err
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer f.Close()
+		if strings.HasSuffix(cOpts.file, file.Extension) {
+			return parseRecords(f)
+		}
+		if strings.HasSuffix(cOpts.file, file.CompressedExtension) {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, err
+			}
+			return parseRecords(gz)
+		}
+		return parseExposition(f)
+	}
+
+	url := cOpts.url
+	if url == "" {
+		port, err := pollerPromPort(cmd, args[0])
+		if err != nil {
+			return nil, err
+		}
+		url = "http://localhost:" + strconv.Itoa(port) + "/metrics"
+	}
+	return fetchSamples(url)
+}
+
+// pollerPromPort returns the port of the Prometheus exporter of a running poller, or of its config
+func pollerPromPort(cmd *cobra.Command, pollerName string) (int, error) {
+	statuses, err := util.GetPollerStatuses()
+	if err != nil {
+		return 0, err
+	}
+	for _, status := range statuses {
+		if status.Name == pollerName && status.PromPort != "" {
+			return strconv.Atoi(status.PromPort)
+		}
+	}
+	config := cmd.Root().PersistentFlags().Lookup("config")
+	if _, err := conf.LoadHarvestConfig(config.Value.String()); err != nil {
+		return 0, err
+	}
+	port, err := conf.GetLastPromPort(pollerName, false)
+	if err != nil {
+		return 0, err
+	}
+	if port == 0 {
+		return 0, errs.New(errs.ErrConfig, "poller "+pollerName+" has no Prometheus exporter")
+	}
+	return port, nil
+}
+
+func fetchSamples(url string) ([]sample, error) {
+	request, err := requests.New(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: time.Minute}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, response.Status)
+	}
+	return parseExposition(response.Body)
+}
+
+// parseExposition returns the series of the Prometheus text format. The object of a series is the longest prefix
+// of its name that has a _labels metric, or the name until its first underscore
+func parseExposition(r io.Reader) ([]sample, error) {
+	var samples []sample
+	seen := make(map[string]bool)
+	objects := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if key := seriesKey(s.name, s.labels, ""); !seen[key] {
+			seen[key] = true
+			samples = append(samples, s)
+		}
+		if object, ok := strings.CutSuffix(s.name, "_labels"); ok {
+			objects[object] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range samples {
+		samples[i].object = objectOf(samples[i].name, objects)
+	}
+	return samples, nil
+}
+
+func objectOf(name string, objects map[string]bool) string {
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] == '_' && objects[name[:i]] {
+			return name[:i]
+		}
+	}
+	if before, _, ok := strings.Cut(name, "_"); ok {
+		return before
+	}
+	return name
+}
+
+// parseLine parses the name and labels of a line of the Prometheus text format. The value is ignored
+func parseLine(line string) (sample, error) {
+	s := sample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return s, errors.New("missing value")
+	}
+	s.name = line[:end]
+	if line[end] != '{' {
+		return s, nil
+	}
+
+	i := end + 1
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == ',') {
+			i++
+		}
+		if i >= len(line) {
+			return s, errors.New("unterminated labels")
+		}
+		if line[i] == '}' {
+			return s, nil
+		}
+		eq := strings.IndexByte(line[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(line) || line[i+eq+1] != '"' {
+			return s, errors.New("invalid label")
+		}
+		key := line[i : i+eq]
+		i += eq + 2
+
+		var value strings.Builder
+		for {
+			if i >= len(line) {
+				return s, errors.New("unterminated label value")
+			}
+			c := line[i]
+			i++
+			if c == '"' {
+				break
+			}
+			if c == '\\' && i < len(line) {
+				c = line[i]
+				i++
+				if c == 'n' {
+					c = '\n'
+				}
+			}
+			value.WriteByte(c)
+		}
+		s.labels[key] = value.String()
+	}
+}
+
+// parseRecords returns the series of a file of the File exporter, like they are exported by harvest import
+func parseRecords(r io.Reader) ([]sample, error) {
+	var samples []sample
+	seen := make(map[string]bool)
+	add := func(s sample) {
+		if key := seriesKey(s.name, s.labels, ""); !seen[key] {
+			seen[key] = true
+			samples = append(samples, s)
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var record file.Record
+			if json.Unmarshal(line, &record) == nil {
+				for _, metric := range record.Metrics {
+					labels := record.Labels
+					if len(metric.Labels) > 0 {
+						labels = mergeLabels(record.Labels, metric.Labels)
+					}
+					add(sample{object: record.Object, name: record.Object + "_" + metric.Name, labels: labels})
+				}
+				if len(record.Info) > 0 {
+					add(sample{object: record.Object, name: record.Object + "_labels", labels: mergeLabels(record.Labels, record.Info)})
+				}
+			}
+		}
+		if err != nil {
+			// files that are still written end without a gzip footer
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return samples, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+func mergeLabels(a, b map[string]string) map[string]string {
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// seriesKey identifies a series by its name and labels, without the label skip
+func seriesKey(name string, labels map[string]string, skip string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != skip {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+// analyze counts the series of each object, and the values of each label of the object.
+// Objects are sorted by series, and their labels by the series merged away when the label is dropped
+func analyze(samples []sample, top int) []objectCardinality {
+	byObject := make(map[string][]sample)
+	for _, s := range samples {
+		byObject[s.object] = append(byObject[s.object], s)
+	}
+
+	result := make([]objectCardinality, 0, len(byObject))
+	for object, series := range byObject {
+		oc := objectCardinality{name: object, series: len(series)}
+		metrics := make(map[string]bool)
+		values := make(map[string]map[string]int)
+		for _, s := range series {
+			metrics[s.name] = true
+			for k, v := range s.labels {
+				if values[k] == nil {
+					values[k] = make(map[string]int)
+				}
+				values[k][v]++
+			}
+		}
+		oc.metrics = len(metrics)
+
+		for label, counts := range values {
+			lc := labelCardinality{name: label, values: len(counts)}
+			remaining := make(map[string]bool)
+			for _, s := range series {
+				if _, ok := s.labels[label]; ok {
+					lc.series++
+					remaining[seriesKey(s.name, s.labels, label)] = true
+				}
+			}
+			lc.reduction = lc.series - len(remaining)
+			for v, n := range counts {
+				lc.top = append(lc.top, valueCount{value: v, series: n})
+			}
+			slices.SortFunc(lc.top, func(a, b valueCount) int {
+				return cmp.Or(cmp.Compare(b.series, a.series), cmp.Compare(a.value, b.value))
+			})
+			if len(lc.top) > top {
+				lc.top = lc.top[:top]
+			}
+			oc.labels = append(oc.labels, lc)
+		}
+		slices.SortFunc(oc.labels, func(a, b labelCardinality) int {
+			return cmp.Or(cmp.Compare(b.reduction, a.reduction), cmp.Compare(b.values, a.values), cmp.Compare(a.name, b.name))
+		})
+		result = append(result, oc)
+	}
+	slices.SortFunc(result, func(a, b objectCardinality) int {
+		return cmp.Or(cmp.Compare(b.series, a.series), cmp.Compare(a.name, b.name))
+	})
+	return result
+}
+
+type suggestion struct {
+	object string
+	label  labelCardinality
+	series int
+}
+
+// suggest returns the labels that, when dropped, merge away at least half of the series of an object
+// with at least minSeries series
+func suggest(objects []objectCardinality, minSeries int) []suggestion {
+	var suggestions []suggestion
+	for _, o := range objects {
+		if o.series < minSeries {
+			continue
+		}
+		for _, l := range o.labels {
+			if slices.Contains(keptLabels, l.name) || l.reduction*2 < o.series {
+				continue
+			}
+			suggestions = append(suggestions, suggestion{object: o.name, label: l, series: o.series})
+		}
+	}
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Compare(b.label.reduction, a.label.reduction)
+	})
+	return suggestions
+}
+
+func report(out io.Writer, objects []objectCardinality, maxObjects int, minSeries int) {
+	total := 0
+	for _, o := range objects {
+		total += o.series
+	}
+	_, _ = fmt.Fprintf(out, "%d series in %d objects\n\n", total, len(objects))
+
+	table := tw.NewWriter(out)
+	table.SetBorder(false)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Object", "Series", "Share", "Metrics", "Labels"})
+	table.SetColumnAlignment([]int{tw.ALIGN_LEFT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT})
+	for _, o := range objects {
+		table.Append([]string{o.name, strconv.Itoa(o.series), percent(o.series, total),
+			strconv.Itoa(o.metrics), strconv.Itoa(len(o.labels))})
+	}
+	table.Render()
+
+	for _, o := range objects[:min(maxObjects, len(objects))] {
+		_, _ = fmt.Fprintf(out, "\n%s: %d series\n", o.name, o.series)
+		table := tw.NewWriter(out)
+		table.SetBorder(false)
+		table.SetAutoFormatHeaders(false)
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"Label", "Values", "Series", "Merged if dropped", "Top values"})
+		table.SetColumnAlignment([]int{tw.ALIGN_LEFT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_LEFT})
+		for _, l := range o.labels {
+			top := make([]string, 0, len(l.top))
+			for _, v := range l.top {
+				top = append(top, fmt.Sprintf("%s (%d)", v.value, v.series))
+			}
+			table.Append([]string{l.name, strconv.Itoa(l.values), strconv.Itoa(l.series),
+				strconv.Itoa(l.reduction), strings.Join(top, ", ")})
+		}
+		table.Render()
+	}
+
+	suggestions := suggest(objects, minSeries)
+	if len(suggestions) == 0 {
+		_, _ = fmt.Fprintf(out, "\nNo labels to drop: no label merges half of the series of an object with at least %d series\n", minSeries)
+		return
+	}
+	_, _ = fmt.Fprintln(out, "\nLabels to drop")
+	for _, s := range suggestions {
+		_, _ = fmt.Fprintf(out, "  %s: %s has %d values, dropping it merges %d of %d series (%s)\n",
+			s.object, s.label.name, s.label.values, s.label.reduction, s.series, percent(s.label.reduction, s.series))
+	}
+	_, _ = fmt.Fprintln(out, "Dropping a label merges the series that only differ by it. Remove it from the instance_keys "+
+		"of the template of the object when the merged series are not needed, or aggregate them with the Aggregator plugin.")
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
@@ -0,0 +1,155 @@
+package doctor
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{line: `up 1`, name: "up", labels: map[string]string{}},
+		{line: `volume_size{cluster="c1",volume="vol1"} 10`, name: "volume_size", labels: map[string]string{"cluster": "c1", "volume": "vol1"}},
+		{line: `volume_size{volume="a \"b\" \\c",} 10 1700000000`, name: "volume_size", labels: map[string]string{"volume": `a "b" \c`}},
+		{line: `node_ops{node="n1"} 3 # {ems="x"} 1 1700000000.123`, name: "node_ops", labels: map[string]string{"node": "n1"}},
+		{line: `volume_size{volume="vol1} 10`, wantErr: true},
+		{line: `volume_size{volume} 10`, wantErr: true},
+		{line: `volume_size`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseLine() got nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLine() err=%v", err)
+			}
+			if got.name != tt.name {
+				t.Errorf("name got=%s, want=%s", got.name, tt.name)
+			}
+			if diff := cmp.Diff(tt.labels, got.labels); diff != "" {
+				t.Errorf("labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseExpositionObjects(t *testing.T) {
+	text := `# HELP qos_detail_labels Pseudo-metric for qos_detail labels
+qos_detail_labels{workload="w1"} 1.0
+qos_detail_resource_latency{workload="w1"} 3
+qos_detail_resource_latency{workload="w1"} 3
+qos_ops{workload="w1"} 4
+metadata_collector_count{poller="p1"} 5
+`
+	samples, err := parseExposition(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(samples))
+	for _, s := range samples {
+		got = append(got, s.object+":"+s.name)
+	}
+	want := []string{
+		"qos_detail:qos_detail_labels",
+		"qos_detail:qos_detail_resource_latency",
+		"qos:qos_ops",
+		"metadata:metadata_collector_count",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("objects mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseRecords(t *testing.T) {
+	records := `{"timestamp":1,"object":"volume","labels":{"volume":"vol1"},"info":{"state":"online"},"metrics":[{"name":"size","value":1}]}
+{"timestamp":2,"object":"volume","labels":{"volume":"vol1"},"info":{"state":"online"},"metrics":[{"name":"size","value":2}]}
+{"timestamp":2,"object":"volume","labels":{"volume":"vol2"},"metrics":[{"name":"lat","labels":{"metric":"read"},"value":2}]}
+not json
+{"timestamp":3,"object":"volume","labels":{"volume":"vol3"},"metrics":[{"name":"size","value":3}]}`
+
+	samples, err := parseRecords(strings.NewReader(records))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(samples))
+	for _, s := range samples {
+		got = append(got, seriesKey(s.name, s.labels, ""))
+	}
+	want := []string{
+		"volume_size\x00volume\x00vol1",
+		"volume_labels\x00state\x00online\x00volume\x00vol1",
+		"volume_lat\x00metric\x00read\x00volume\x00vol2",
+		"volume_size\x00volume\x00vol3",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("series mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	var text bytes.Buffer
+	// 4 nodes with 25 files each, and 2 aggregates
+	for f := range 100 {
+		node := fmt.Sprintf("n%d", f%4)
+		_, _ = fmt.Fprintf(&text, "file_ops{cluster=\"c1\",node=%q,file=\"f%d\"} 1\n", node, f)
+		_, _ = fmt.Fprintf(&text, "file_ops_hist{cluster=\"c1\",node=%q,file=\"f%d\",metric=\"read\"} 1\n", node, f)
+	}
+	text.WriteString("aggr_space{cluster=\"c1\",aggr=\"a1\"} 1\naggr_space{cluster=\"c1\",aggr=\"a2\"} 1\n")
+
+	samples, err := parseExposition(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := analyze(samples, 2)
+	if len(objects) != 2 || objects[0].name != "file" || objects[0].series != 200 || objects[0].metrics != 2 {
+		t.Fatalf("analyze() got=%+v", objects)
+	}
+
+	labels := make(map[string]labelCardinality)
+	for _, l := range objects[0].labels {
+		labels[l.name] = l
+	}
+	if l := labels["file"]; l.values != 100 || l.series != 200 || l.reduction != 192 || len(l.top) != 2 {
+		t.Errorf("file label got=%+v", l)
+	}
+	if l := labels["node"]; l.values != 4 || l.reduction != 0 || l.top[0] != (valueCount{value: "n0", series: 50}) {
+		t.Errorf("node label got=%+v", l)
+	}
+	if l := labels["metric"]; l.series != 100 || l.reduction != 0 {
+		t.Errorf("metric label got=%+v", l)
+	}
+	if objects[0].labels[0].name != "file" {
+		t.Errorf("labels not sorted by reduction, got first=%s", objects[0].labels[0].name)
+	}
+
+	suggestions := suggest(objects, 100)
+	if len(suggestions) != 1 || suggestions[0].object != "file" || suggestions[0].label.name != "file" {
+		t.Errorf("suggest() got=%+v", suggestions)
+	}
+	if got := suggest(objects, 1000); len(got) != 0 {
+		t.Errorf("suggest() with min series got=%+v, want none", got)
+	}
+
+	var out bytes.Buffer
+	report(&out, objects, 1, 100)
+	for _, want := range []string{"202 series in 2 objects", "file: 200 series", "file: file has 100 values, dropping it merges 192 of 200 series (96.0%)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report() missing %q in\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "aggr: 2 series") {
+		t.Errorf("report() printed labels of more objects than asked")
+	}
+}
@@ -0,0 +1,97 @@
+package doctor
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	tw "github.com/netapp/harvest/v2/third_party/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+)
+
+type costOptions struct {
+	collectors []string
+	objects    []string
+}
+
+var coOpts = &costOptions{}
+
+var costCmd = &cobra.Command{
+	Use:   "cost POLLER",
+	Short: "Estimate the API calls, series, and bytes of the collectors of a poller, without polling",
+	Long: `Initialize the collectors of a poller, like the poller does, and estimate the cost of each of their tasks
+from the number of instances of each object, and the size of one of its records. Data is not polled.
+
+Per poll, the estimate is the number of API calls, instances, series, and bytes received.
+Per hour, the calls and bytes are multiplied by the polls of the schedule of the task.
+Plugins, e.g. the ones that collect more data, are not estimated. Only the Rest, RestPerf, and KeyPerf collectors
+can estimate their cost.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doCostCmd,
+}
+
+func doCostCmd(cmd *cobra.Command, args []string) {
+	config := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+	// the templates of the poller are used unless --confpath is passed
+	var confPath string
+	if f := cmd.Root().PersistentFlags().Lookup("confpath"); f.Changed {
+		confPath = f.Value.String()
+	}
+	r, err := runner.New(runner.Options{
+		Config:     config,
+		Poller:     args[0],
+		Collectors: coOpts.collectors,
+		Objects:    coOpts.objects,
+		ConfPath:   confPath,
+	})
+	if err != nil {
+		fmt.Printf("skipped: %v\n", err)
+	}
+	if r == nil {
+		os.Exit(1)
+	}
+	costs, err := r.Estimate()
+	if err != nil {
+		fmt.Printf("skipped: %v\n", err)
+	}
+	printCosts(os.Stdout, costs)
+}
+
+// printCosts prints the cost of each task, and the series of all tasks and their calls and bytes per hour
+func printCosts(out io.Writer, costs []runner.Cost) {
+	table := tw.NewWriter(out)
+	table.SetBorder(false)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Collector", "Task", "Interval", "Instances", "Series", "Calls/poll", "Bytes/poll", "Calls/hour", "Bytes/hour"})
+	table.SetColumnAlignment([]int{tw.ALIGN_LEFT, tw.ALIGN_LEFT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT,
+		tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT})
+
+	var series int
+	var calls, bytes float64
+	for _, c := range costs {
+		series += c.Series
+		calls += c.CallsPerHour
+		bytes += c.BytesPerHour
+		table.Append([]string{c.Collector, c.Task, c.Interval.String(), strconv.Itoa(c.Instances), strconv.Itoa(c.Series),
+			strconv.Itoa(c.Calls), formatBytes(float64(c.Bytes)), strconv.FormatFloat(c.CallsPerHour, 'f', 0, 64),
+			formatBytes(c.BytesPerHour)})
+	}
+	table.Render()
+	_, _ = fmt.Fprintf(out, "\nTotal: %d series, %s calls and %s per hour\n",
+		series, strconv.FormatFloat(calls, 'f', 0, 64), formatBytes(bytes))
+}
+
+// formatBytes formats bytes with a binary unit, e.g. 1.5 MiB
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	if i == 0 {
+		return strconv.FormatFloat(b, 'f', 0, 64) + " " + units[i]
+	}
+	return strconv.FormatFloat(b, 'f', 1, 64) + " " + units[i]
+}
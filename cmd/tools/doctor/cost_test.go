@@ -0,0 +1,42 @@
+package doctor
+
+import (
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes float64
+		want  string
+	}{
+		{bytes: 0, want: "0 B"},
+		{bytes: 1023, want: "1023 B"},
+		{bytes: 1536, want: "1.5 KiB"},
+		{bytes: 3 * 1024 * 1024 * 1024, want: "3.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.bytes); got != tt.want {
+			t.Errorf("formatBytes(%f) got=%s, want=%s", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestPrintCosts(t *testing.T) {
+	e := collector.Estimate{Task: "data", Calls: 2, Instances: 100, Series: 1200, Bytes: 50 * 1024}
+	cost := runner.Cost{Collector: "Rest:Volume", Estimate: e, Interval: 3 * time.Minute}
+	cost.CallsPerHour, cost.BytesPerHour = e.PerHour(cost.Interval)
+
+	var out strings.Builder
+	printCosts(&out, []runner.Cost{cost})
+	got := out.String()
+	// 20 polls per hour
+	for _, want := range []string{"Rest:Volume", "3m0s", "1200", "50.0 KiB", "40", "1000.0 KiB"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printCosts() missing %s in\n%s", want, got)
+		}
+	}
+}
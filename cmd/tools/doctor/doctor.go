@@ -15,7 +15,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 type options struct {
@@ -53,6 +55,13 @@ var mergeCmd = &cobra.Command{
 	Run:    doMergeCmd,
 }
 
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Strictly validate harvest.yml",
+	Long:  "Validate harvest.yml and its poller files, and report unknown keys, values of the wrong type, and duplicate poller names with their line numbers",
+	Run:   doConfigCmd,
+}
+
 var compareZapiRestMetricsCmd = &cobra.Command{
 	Use:    "compareZRMetrics",
 	Hidden: true,
@@ -68,6 +77,16 @@ func doCompareZapiRestCmd(_ *cobra.Command, _ []string) {
 	}
 }
 
+func doConfigCmd(cmd *cobra.Command, _ []string) {
+	var config = cmd.Root().PersistentFlags().Lookup("config")
+	color.DetectConsole(opts.Color)
+	aPath := conf.ConfigPath(config.Value.String())
+	if !checkConfigSchema(aPath).isValid {
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", aPath)
+}
+
 func doMergeCmd(_ *cobra.Command, _ []string) {
 	doMerge(opts.BaseTemplate, opts.MergeTemplate)
 }
@@ -233,6 +252,8 @@ func checkAll(aPath string, confPath string) {
 	anyFailed = !checkExporterTypes(cfg).isValid || anyFailed
 	anyFailed = !checkConfTemplates(confPaths).isValid || anyFailed
 	anyFailed = !checkCollectorName(cfg).isValid || anyFailed
+	anyFailed = !checkTLSOptions(cfg).isValid || anyFailed
+	anyFailed = !checkConfigSchema(aPath).isValid || anyFailed
 
 	if anyFailed {
 		os.Exit(1)
@@ -240,6 +261,26 @@ func checkAll(aPath string, confPath string) {
 	os.Exit(0)
 }
 
+// checkConfigSchema strictly validates the config file and its poller files, e.g. for unknown keys,
+// which are ignored when the config is loaded
+func checkConfigSchema(aPath string) validation {
+	valid := validation{isValid: true}
+	for _, problem := range conf.Validate(aPath) {
+		valid.isValid = false
+		valid.invalid = append(valid.invalid, problem.Error())
+	}
+
+	if !valid.isValid {
+		fmt.Printf("%s: Invalid config\n", color.Colorize("Error", color.Red))
+		fmt.Println("  Fix the following problems, Harvest ignores unknown keys:")
+		for _, problem := range valid.invalid {
+			fmt.Printf("  %s\n", problem)
+		}
+		fmt.Println()
+	}
+	return valid
+}
+
 // checkCollectorName checks if the collector names in the config struct are valid
 func checkCollectorName(config conf.HarvestConfig) validation {
 	valid := validation{isValid: true}
@@ -388,7 +429,7 @@ func checkExporterTypes(config conf.HarvestConfig) validation {
 			continue
 		}
 		switch exporter.Type {
-		case "Prometheus", "InfluxDB":
+		case "Prometheus", "InfluxDB", "File", "API":
 			break
 		default:
 			invalidTypes[name] = exporter.Type
@@ -412,6 +453,43 @@ func checkExporterTypes(config conf.HarvestConfig) validation {
 	return valid
 }
 
+// checkTLSOptions checks the TLS options of the pollers, exporters, and the admin node
+func checkTLSOptions(config conf.HarvestConfig) validation {
+	invalid := make(map[string]error)
+	if config.Defaults != nil {
+		if err := config.Defaults.TLSOptions().Validate(); err != nil {
+			invalid["Defaults"] = err
+		}
+	}
+	for name, poller := range config.Pollers {
+		if err := poller.TLSOptions().Validate(); err != nil {
+			invalid["poller "+name] = err
+		}
+	}
+	for name, exporter := range config.Exporters {
+		if err := exporter.TLS.Options().Validate(); err != nil {
+			invalid["exporter "+name] = err
+		}
+	}
+	if err := config.Admin.Httpsd.TLS.Options().Validate(); err != nil {
+		invalid["Admin"] = err
+	}
+
+	valid := validation{isValid: len(invalid) == 0}
+	if len(invalid) > 0 {
+		fmt.Printf("%s Invalid TLS options found\n", color.Colorize("Error:", color.Red))
+		for name := range invalid {
+			valid.invalid = append(valid.invalid, name)
+		}
+		slices.Sort(valid.invalid)
+		for _, name := range valid.invalid {
+			fmt.Printf("  %s: %s\n", color.Colorize(name, color.Red), invalid[name])
+		}
+		fmt.Println()
+	}
+	return valid
+}
+
 func checkExportersExist(config conf.HarvestConfig) validation {
 	if config.Exporters == nil {
 		fmt.Printf("%s: No Exporters section defined. No metrics will be exported.\n", color.Colorize("Error", color.Red))
@@ -562,7 +640,7 @@ func printRedactedConfig(aPath string, contents []byte) (*yaml.Node, error) {
 func sanitize(nodes []*yaml.Node) {
 	// Update this list when there are additional tokens to sanitize
 	sanitizeWords := []string{"username", "password", "grafana_api_token", "token",
-		"host", "addr"}
+		"hash_key", "host", "addr"}
 	for i, node := range nodes {
 		if node == nil {
 			continue
@@ -598,7 +676,12 @@ func collectNodes(root *yaml.Node, nodes *[]*yaml.Node) {
 
 func init() {
 	Cmd.AddCommand(mergeCmd)
+	Cmd.AddCommand(configCmd)
 	Cmd.AddCommand(compareZapiRestMetricsCmd)
+	Cmd.AddCommand(cardinalityCmd)
+	Cmd.AddCommand(parityCmd)
+	Cmd.AddCommand(paramsCmd)
+	Cmd.AddCommand(costCmd)
 	dFlags := compareZapiRestMetricsCmd.PersistentFlags()
 	mFlags := mergeCmd.PersistentFlags()
 
@@ -613,6 +696,22 @@ func init() {
 
 	_ = mergeCmd.MarkPersistentFlagRequired("template")
 	_ = mergeCmd.MarkPersistentFlagRequired("with")
+
+	cFlags := cardinalityCmd.Flags()
+	cFlags.StringVar(&cOpts.url, "url", "", "URL of the metrics, e.g. http://localhost:12990/metrics")
+	cFlags.StringVar(&cOpts.file, "file", "", "File of metrics in the Prometheus text format, or of the File exporter")
+	cFlags.IntVar(&cOpts.top, "top", 5, "Number of top values printed for each label")
+	cFlags.IntVar(&cOpts.objects, "objects", 10, "Number of objects, with the most series, whose labels are printed")
+	cFlags.IntVar(&cOpts.minSeries, "min-series", 1000, "Only suggest labels to drop of objects with at least this many series")
+	pFlags := parityCmd.Flags()
+	pFlags.StringSliceVar(&pOpts.objects, "objects", nil, "Objects to compare, e.g. Volume, defaults to the objects of the templates")
+	pFlags.DurationVar(&pOpts.wait, "wait", time.Minute, "Time between the two polls of the collectors")
+	paFlags := paramsCmd.Flags()
+	paFlags.StringSliceVar(&paOpts.collectors, "collectors", nil, "Collectors to print, e.g. Rest, defaults to the collectors of the poller")
+	paFlags.StringSliceVar(&paOpts.objects, "objects", nil, "Objects to print, e.g. Volume, defaults to the objects of the templates")
+	coFlags := costCmd.Flags()
+	coFlags.StringSliceVar(&coOpts.collectors, "collectors", nil, "Collectors to estimate, e.g. Rest, defaults to the collectors of the poller")
+	coFlags.StringSliceVar(&coOpts.objects, "objects", nil, "Objects to estimate, e.g. Volume, defaults to the objects of the templates")
 	Cmd.Flags().BoolVarP(
 		&opts.ShouldPrintConfig,
 		"print",
@@ -4,6 +4,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"gopkg.in/yaml.v3"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -14,6 +15,7 @@ func TestRedaction(t *testing.T) {
 	assertRedacted(t, `password: f`, `password: -REDACTED-`)
 	assertRedacted(t, `grafana_api_token: secret`, `grafana_api_token: -REDACTED-`)
 	assertRedacted(t, `token: secret`, `token: -REDACTED-`)
+	assertRedacted(t, `hash_key: secret`, `hash_key: -REDACTED-`)
 	assertRedacted(t, "# foo\nusername: pass\n#foot", `username: -REDACTED-`)
 	assertRedacted(t, `host: 1.2.3.4`, `host: -REDACTED-`)
 	assertRedacted(t, `addr: 1.2.3.4`, `addr: -REDACTED-`)
@@ -228,3 +230,51 @@ func TestExportersExist(t *testing.T) {
 		t.Errorf(`got isValid=true, want isValid=false since there is no exporters section`)
 	}
 }
+
+func TestCheckConfigSchema(t *testing.T) {
+	if valid := checkConfigSchema("testdata/noExporters.yml"); !valid.isValid {
+		t.Errorf("got invalid=%v, want valid", valid.invalid)
+	}
+	valid := checkConfigSchema("testdata/issue-284.yml")
+	want := []string{"testdata/issue-284.yml:3: unknown key exporters in exporter, did you mean exporter?"}
+	if !slices.Equal(valid.invalid, want) {
+		t.Errorf("got invalid=%v, want=%v", valid.invalid, want)
+	}
+}
+
+func TestCheckTLSOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      conf.HarvestConfig
+		wantInvalid []string
+	}{
+		{
+			name: "valid",
+			config: conf.HarvestConfig{
+				Pollers:   map[string]*conf.Poller{"a": {TLSMinVersion: "tls12", TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}},
+				Exporters: map[string]conf.Exporter{"prom": {TLS: conf.TLS{MinVersion: "TLS13"}}},
+			},
+		},
+		{
+			name: "invalid",
+			config: conf.HarvestConfig{
+				Defaults:  &conf.Poller{TLSRenegotiation: "always"},
+				Pollers:   map[string]*conf.Poller{"a": {TLSCipherSuites: []string{"TLS_NOPE"}}, "b": {}},
+				Exporters: map[string]conf.Exporter{"prom": {TLS: conf.TLS{MinVersion: "tls1"}}},
+				Admin:     conf.Admin{Httpsd: conf.Httpsd{TLS: conf.TLS{CipherSuites: []string{"RC4"}}}},
+			},
+			wantInvalid: []string{"Admin", "Defaults", "exporter prom", "poller a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := checkTLSOptions(tt.config)
+			if valid.isValid != (len(tt.wantInvalid) == 0) {
+				t.Errorf("isValid got=%t, want=%t", valid.isValid, len(tt.wantInvalid) == 0)
+			}
+			if !slices.Equal(valid.invalid, tt.wantInvalid) {
+				t.Errorf("invalid got=%v, want=%v", valid.invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
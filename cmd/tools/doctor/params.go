@@ -0,0 +1,81 @@
+package doctor
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+type paramsOptions struct {
+	collectors []string
+	objects    []string
+}
+
+var paOpts = &paramsOptions{}
+
+var paramsCmd = &cobra.Command{
+	Use:   "params POLLER",
+	Short: "Print the resolved parameters of the collectors of a poller, and where each one is set",
+	Long: `Initialize the collectors of a poller, like the poller does, and print their parameters after harvest.yml, the
+templates of the collectors, and the templates of their objects are merged. Initializing a collector connects to its
+cluster, so the object templates are the ones that match the version of the cluster.
+
+Each parameter is followed by its source: the poller in harvest.yml, or the path of the template that sets it.
+Parameters that no file sets, like poller_name, are set by harvest. Secrets are redacted.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doParamsCmd,
+}
+
+// redactedParams are the parameters whose values are not printed
+var redactedParams = []string{"password", "token", "auth_token", "bearer_token", "client_secret", "hash_key"}
+
+func doParamsCmd(cmd *cobra.Command, args []string) {
+	config := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+	// the templates of the poller are used unless --confpath is passed
+	var confPath string
+	if f := cmd.Root().PersistentFlags().Lookup("confpath"); f.Changed {
+		confPath = f.Value.String()
+	}
+	r, err := runner.New(runner.Options{
+		Config:     config,
+		Poller:     args[0],
+		Collectors: paOpts.collectors,
+		Objects:    paOpts.objects,
+		ConfPath:   confPath,
+		Sources:    true,
+	})
+	if err != nil {
+		fmt.Printf("skipped: %v\n", err)
+	}
+	if r == nil {
+		os.Exit(1)
+	}
+	printParams(os.Stdout, r.Params())
+}
+
+// printParams prints the parameters of each collector with their sources, in the order of the parameter tree
+func printParams(out io.Writer, params []runner.Params) {
+	for _, p := range params {
+		_, _ = fmt.Fprintf(out, "\n%s\n", p.Collector)
+		paths, values := collector.FlattenParams(p.Params)
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(out, "  %s: %s  # %s\n", path, formatParam(path, values[path]), p.Sources[path])
+		}
+	}
+}
+
+func formatParam(path string, values []string) string {
+	name := path[strings.LastIndex(path, ".")+1:]
+	if slices.Contains(redactedParams, name) {
+		return "-REDACTED-"
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
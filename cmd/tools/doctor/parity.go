@@ -0,0 +1,292 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type parityOptions struct {
+	objects []string
+	wait    time.Duration
+}
+
+var pOpts = &parityOptions{}
+
+var parityCmd = &cobra.Command{
+	Use:   "parity POLLER",
+	Short: "Compare the metrics exported by the ZAPI and REST collectors of a poller",
+	Long: `Compare the metrics exported by the Zapi and ZapiPerf templates with the metrics exported by the Rest and RestPerf
+templates, for the cluster of a poller. The collectors are polled twice, since performance collectors export metrics
+from their second poll. For each object, the metrics, labels, and units that differ are printed.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doParityCmd,
+}
+
+// exported is what the matrices of an object export
+type exported struct {
+	metrics map[string]string // unit of each metric
+	keys    map[string]bool   // labels of the metrics
+	labels  map[string]bool   // labels of the _labels metric
+}
+
+// objectParity is the difference between what the ZAPI and REST collectors export for an object
+type objectParity struct {
+	name    string
+	zapi    bool // ZAPI exports the object
+	rest    bool // REST exports the object
+	metrics difference
+	keys    difference
+	labels  difference
+	units   []unitDifference
+}
+
+type difference struct {
+	onlyZapi []string
+	onlyRest []string
+}
+
+type unitDifference struct {
+	metric string
+	zapi   string
+	rest   string
+}
+
+func (d difference) empty() bool {
+	return len(d.onlyZapi) == 0 && len(d.onlyRest) == 0
+}
+
+func (p objectParity) same() bool {
+	return p.zapi && p.rest && p.metrics.empty() && p.keys.empty() && p.labels.empty() && len(p.units) == 0
+}
+
+func doParityCmd(cmd *cobra.Command, args []string) {
+	config := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+	// the templates of the poller are used unless --confpath is passed
+	var confPath string
+	if f := cmd.Root().PersistentFlags().Lookup("confpath"); f.Changed {
+		confPath = f.Value.String()
+	}
+	zapi, rest, err := pollParity(config, confPath, args[0])
+	if err != nil {
+		fmt.Printf("parity failed: %v\n", err)
+		os.Exit(1)
+	}
+	reportParity(os.Stdout, compareParity(exports(zapi), exports(rest)))
+}
+
+// pollParity polls the ZAPI and REST collectors of a poller twice, and returns the matrices of their second poll
+func pollParity(config string, confPath string, poller string) ([]*matrix.Matrix, []*matrix.Matrix, error) {
+	newRunner := func(collectors ...string) (*runner.Runner, error) {
+		r, err := runner.New(runner.Options{
+			Config:     config,
+			Poller:     poller,
+			Collectors: collectors,
+			Objects:    pOpts.objects,
+			ConfPath:   confPath,
+		})
+		if r != nil && err != nil {
+			// objects that fail to load are reported as not exported
+			fmt.Printf("skipped: %v\n", err)
+		}
+		return r, err
+	}
+	zapiRunner, err := newRunner("Zapi", "ZapiPerf")
+	if zapiRunner == nil {
+		return nil, nil, err
+	}
+	restRunner, err := newRunner("Rest", "RestPerf")
+	if restRunner == nil {
+		return nil, nil, err
+	}
+
+	var zapi, rest []*matrix.Matrix
+	for i := range 2 {
+		if i > 0 {
+			fmt.Printf("waiting %s for the second poll of performance collectors\n", pOpts.wait)
+			time.Sleep(pOpts.wait)
+		}
+		zapi, err = zapiRunner.Poll()
+		printPollErrors(err)
+		rest, err = restRunner.Poll()
+		printPollErrors(err)
+	}
+	return zapi, rest, nil
+}
+
+func printPollErrors(err error) {
+	if err == nil {
+		return
+	}
+	var joined interface{ Unwrap() []error }
+	errList := []error{err}
+	if errors.As(err, &joined) {
+		errList = joined.Unwrap()
+	}
+	for _, e := range errList {
+		// objects without instances export nothing, which is reported as a gap
+		if errors.Is(e, errs.ErrNoInstance) || errors.Is(e, errs.ErrNoMetric) {
+			continue
+		}
+		fmt.Printf("poll failed: %v\n", e)
+	}
+}
+
+// exports returns what the matrices export, by object. A metric is exported when it has a value for an exported instance
+func exports(matrices []*matrix.Matrix) map[string]*exported {
+	result := make(map[string]*exported)
+	for _, m := range matrices {
+		if !m.IsExportable() {
+			continue
+		}
+		e := result[m.Object]
+		if e == nil {
+			e = &exported{metrics: make(map[string]string), keys: make(map[string]bool), labels: make(map[string]bool)}
+			result[m.Object] = e
+		}
+
+		var includeAll bool
+		if options := m.GetExportOptions(); options != nil {
+			if x := options.GetChildS("instance_keys"); x != nil {
+				for _, key := range x.GetAllChildContentS() {
+					e.keys[key] = true
+				}
+			}
+			if x := options.GetChildS("instance_labels"); x != nil {
+				for _, label := range x.GetAllChildContentS() {
+					e.labels[label] = true
+				}
+			}
+			includeAll = options.GetChildContentS("include_all_labels") == "true"
+		}
+
+		for _, instance := range m.GetInstances() {
+			if !instance.IsExportable() {
+				continue
+			}
+			if includeAll {
+				for label := range instance.GetLabels() {
+					e.keys[label] = true
+				}
+			}
+			for _, metric := range m.GetMetrics() {
+				if !metric.IsExportable() {
+					continue
+				}
+				if _, ok := metric.GetValueFloat64(instance); ok {
+					e.metrics[m.Object+"_"+metric.GetName()] = metric.GetUnit()
+				}
+			}
+		}
+	}
+	return result
+}
+
+// compareParity returns the parity of each object exported by ZAPI or REST, sorted by object
+func compareParity(zapi, rest map[string]*exported) []objectParity {
+	names := make([]string, 0, len(zapi)+len(rest))
+	for name := range zapi {
+		names = append(names, name)
+	}
+	for name := range rest {
+		if _, ok := zapi[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	empty := &exported{}
+	result := make([]objectParity, 0, len(names))
+	for _, name := range names {
+		z, zok := zapi[name]
+		r, rok := rest[name]
+		if !zok {
+			z = empty
+		}
+		if !rok {
+			r = empty
+		}
+		p := objectParity{
+			name:    name,
+			zapi:    zok,
+			rest:    rok,
+			metrics: diff(z.metrics, r.metrics),
+		}
+		// labels and units only differ for objects that both export
+		if zok && rok {
+			p.keys = diff(z.keys, r.keys)
+			p.labels = diff(z.labels, r.labels)
+			for metric, zapiUnit := range z.metrics {
+				if restUnit, ok := r.metrics[metric]; ok && restUnit != zapiUnit {
+					p.units = append(p.units, unitDifference{metric: metric, zapi: zapiUnit, rest: restUnit})
+				}
+			}
+			slices.SortFunc(p.units, func(a, b unitDifference) int {
+				return strings.Compare(a.metric, b.metric)
+			})
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// diff returns the sorted keys that are only in zapi, and only in rest
+func diff[V any](zapi, rest map[string]V) difference {
+	var d difference
+	for k := range zapi {
+		if _, ok := rest[k]; !ok {
+			d.onlyZapi = append(d.onlyZapi, k)
+		}
+	}
+	for k := range rest {
+		if _, ok := zapi[k]; !ok {
+			d.onlyRest = append(d.onlyRest, k)
+		}
+	}
+	slices.Sort(d.onlyZapi)
+	slices.Sort(d.onlyRest)
+	return d
+}
+
+func reportParity(out io.Writer, objects []objectParity) {
+	gaps := 0
+	for _, p := range objects {
+		if p.same() {
+			continue
+		}
+		gaps++
+		_, _ = fmt.Fprintf(out, "\n%s\n", p.name)
+		switch {
+		case !p.rest:
+			_, _ = fmt.Fprintf(out, "  only exported by ZAPI, %d metrics\n", len(p.metrics.onlyZapi))
+			continue
+		case !p.zapi:
+			_, _ = fmt.Fprintf(out, "  only exported by REST, %d metrics\n", len(p.metrics.onlyRest))
+			continue
+		}
+		printDifference(out, "metrics", p.metrics)
+		printDifference(out, "metric labels", p.keys)
+		printDifference(out, p.name+"_labels labels", p.labels)
+		for _, u := range p.units {
+			_, _ = fmt.Fprintf(out, "  unit of %s: ZAPI=%q REST=%q\n", u.metric, u.zapi, u.rest)
+		}
+	}
+	_, _ = fmt.Fprintf(out, "\n%d objects compared, %d with gaps\n", len(objects), gaps)
+}
+
+func printDifference(out io.Writer, what string, d difference) {
+	if len(d.onlyZapi) > 0 {
+		_, _ = fmt.Fprintf(out, "  %s only in ZAPI: %s\n", what, strings.Join(d.onlyZapi, ", "))
+	}
+	if len(d.onlyRest) > 0 {
+		_, _ = fmt.Fprintf(out, "  %s only in REST: %s\n", what, strings.Join(d.onlyRest, ", "))
+	}
+}
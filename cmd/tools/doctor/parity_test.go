@@ -0,0 +1,94 @@
+package doctor
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"strings"
+	"testing"
+)
+
+// newParityMatrix returns a matrix of object with one instance, and a value for each metric, as name:unit
+func newParityMatrix(t *testing.T, object string, keys []string, metrics ...string) *matrix.Matrix {
+	t.Helper()
+	m := matrix.New(object, object, object)
+	options := node.NewS("export_options")
+	k := options.NewChildS("instance_keys", "")
+	for _, key := range keys {
+		k.NewChildS("", key)
+	}
+	options.NewChildS("instance_labels", "").NewChildS("", "state")
+	m.SetExportOptions(options)
+	instance, _ := m.NewInstance("i1")
+	for _, metric := range metrics {
+		name, unit, _ := strings.Cut(metric, ":")
+		mt, _ := m.NewMetricFloat64(name)
+		mt.SetUnit(unit)
+		_ = mt.SetValueFloat64(instance, 1)
+	}
+	// metrics without values are not exported
+	_, _ = m.NewMetricFloat64("empty")
+	return m
+}
+
+func TestCompareParity(t *testing.T) {
+	zapi := exports([]*matrix.Matrix{
+		newParityMatrix(t, "volume", []string{"svm", "volume", "node"}, "read_ops:per_sec", "read_latency:microsec", "avg_latency:microsec"),
+		newParityMatrix(t, "flexcache", []string{"svm"}, "size"),
+		newParityMatrix(t, "qtree", []string{"qtree"}, "files"),
+	})
+	rest := exports([]*matrix.Matrix{
+		newParityMatrix(t, "volume", []string{"svm", "volume"}, "read_ops:per_sec", "read_latency:millisec", "write_ops:per_sec"),
+		newParityMatrix(t, "ems", []string{"message"}, "events"),
+		newParityMatrix(t, "qtree", []string{"qtree"}, "files"),
+	})
+
+	got := compareParity(zapi, rest)
+	names := make([]string, 0, len(got))
+	for _, p := range got {
+		names = append(names, p.name)
+	}
+	if diff := cmp.Diff([]string{"ems", "flexcache", "qtree", "volume"}, names); diff != "" {
+		t.Fatalf("objects mismatch (-want +got):\n%s", diff)
+	}
+
+	ems, flexcache, qtree, volume := got[0], got[1], got[2], got[3]
+	if ems.zapi || !ems.rest || !flexcache.zapi || flexcache.rest {
+		t.Errorf("objects exported by one collector got ems=%+v flexcache=%+v", ems, flexcache)
+	}
+	if !qtree.same() {
+		t.Errorf("qtree got=%+v, want same", qtree)
+	}
+	want := difference{onlyZapi: []string{"volume_avg_latency"}, onlyRest: []string{"volume_write_ops"}}
+	if diff := cmp.Diff(want, volume.metrics, cmp.AllowUnexported(difference{})); diff != "" {
+		t.Errorf("metrics mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(difference{onlyZapi: []string{"node"}}, volume.keys, cmp.AllowUnexported(difference{})); diff != "" {
+		t.Errorf("keys mismatch (-want +got):\n%s", diff)
+	}
+	if !volume.labels.empty() {
+		t.Errorf("labels got=%+v, want empty", volume.labels)
+	}
+	wantUnits := []unitDifference{{metric: "volume_read_latency", zapi: "microsec", rest: "millisec"}}
+	if diff := cmp.Diff(wantUnits, volume.units, cmp.AllowUnexported(unitDifference{})); diff != "" {
+		t.Errorf("units mismatch (-want +got):\n%s", diff)
+	}
+
+	var out bytes.Buffer
+	reportParity(&out, got)
+	for _, want := range []string{
+		"only exported by REST, 1 metrics",
+		"metrics only in ZAPI: volume_avg_latency",
+		"metric labels only in ZAPI: node",
+		`unit of volume_read_latency: ZAPI="microsec" REST="millisec"`,
+		"4 objects compared, 3 with gaps",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("reportParity() missing %q in\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "qtree") {
+		t.Errorf("reportParity() printed an object without gaps")
+	}
+}
@@ -0,0 +1,190 @@
+// Package e2e is a smoke test of the collectors of a poller against its cluster. It polls each collector, checks that
+// the metrics and labels of a manifest are exported, and writes a JUnit report, e.g. to run after an ONTAP upgrade
+// and catch the metrics that the upgrade broke before the dashboards go blank
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+type options struct {
+	manifest   string
+	junit      string
+	collectors []string
+	wait       time.Duration
+}
+
+var opts = &options{}
+
+var Cmd = &cobra.Command{
+	Use:   "e2e POLLER",
+	Short: "Check that the collectors of a poller export the metrics of a manifest",
+	Long: `Poll the collectors of a poller once against its cluster, and check that they export the metrics and labels
+of a manifest. Performance collectors are polled a second time, after --wait, since they export metrics from their
+second poll. The results are written as a JUnit report, and the command exits with 1 when a check fails.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doE2E,
+	Example: `
+# Check the default manifest, the metrics of the main dashboards
+bin/harvest e2e cluster-01
+
+# Check the REST collectors with your own manifest
+bin/harvest e2e cluster-01 --collectors Rest,RestPerf --manifest manifest.yaml --junit report.xml`,
+}
+
+func init() {
+	flags := Cmd.Flags()
+	flags.StringVar(&opts.manifest, "manifest", "", "Path of the manifest, defaults to the manifest of the main dashboards")
+	flags.StringVar(&opts.junit, "junit", "harvest-e2e.xml", "Path of the JUnit report, no report is written when empty")
+	flags.StringSliceVar(&opts.collectors, "collectors", nil, "Collectors to check, e.g. Rest, defaults to the collectors of the poller")
+	flags.DurationVar(&opts.wait, "wait", time.Minute, "Time between the two polls of performance collectors")
+}
+
+func doE2E(cmd *cobra.Command, args []string) {
+	// the collectors log each template they load
+	logging.Get()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	config := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+	// the templates of the poller are used unless --confpath is passed
+	var confPath string
+	if f := cmd.Root().PersistentFlags().Lookup("confpath"); f.Changed {
+		confPath = f.Value.String()
+	}
+
+	manifest, err := LoadManifest(opts.manifest)
+	if err != nil {
+		fmt.Printf("e2e failed: %v\n", err)
+		os.Exit(1)
+	}
+	report := Run(runner.Options{
+		Config:     config,
+		Poller:     args[0],
+		Collectors: opts.collectors,
+		ConfPath:   confPath,
+	}, manifest, opts.wait)
+
+	for _, s := range report.Suites {
+		for _, c := range s.Cases {
+			if c.Failure != nil {
+				fmt.Printf("FAIL %s %s: %s\n", c.ClassName, c.Name, c.Failure.Message)
+			}
+		}
+	}
+	fmt.Printf("%d checks, %d failed, %d skipped\n", report.Tests, report.Failures, report.Skipped)
+
+	if opts.junit != "" {
+		if err := writeReport(report, opts.junit); err != nil {
+			fmt.Printf("e2e failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("JUnit report written to %s\n", opts.junit)
+	}
+	if report.Failures > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeReport(report *Report, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report %s: %w", path, err)
+	}
+	if err := report.Write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Run polls the collectors of a poller, and returns the report of their polls and of the checks of the manifest.
+// Performance collectors are polled twice, wait apart
+func Run(o runner.Options, m Manifest, wait time.Duration) *Report {
+	report := &Report{Name: "harvest e2e " + o.Poller}
+
+	start := time.Now()
+	r, err := runner.New(o)
+	initialize := TestCase{ClassName: "harvest", Name: "initialize", Time: time.Since(start).Seconds()}
+	if err != nil {
+		initialize.Failure = &Failure{Message: "collectors failed to initialize", Text: err.Error()}
+	}
+	report.add(TestSuite{Name: "harvest", Time: initialize.Time, Cases: []TestCase{initialize}})
+	if r == nil {
+		return report
+	}
+
+	results := r.PollEach()
+	if hasPerf(r.Collectors()) {
+		time.Sleep(wait)
+		results = r.PollEach()
+	}
+
+	// results of each collector, in the order of the collectors
+	var names []string
+	byCollector := make(map[string][]runner.Result)
+	for _, result := range results {
+		name, _, _ := strings.Cut(result.Collector, ":")
+		if _, ok := byCollector[name]; !ok {
+			names = append(names, name)
+		}
+		byCollector[name] = append(byCollector[name], result)
+	}
+
+	for _, name := range names {
+		suite := TestSuite{Name: name}
+		var matrices []*matrix.Matrix
+		for _, result := range byCollector[name] {
+			c := TestCase{ClassName: name, Name: "poll " + result.Collector, Time: result.Duration.Seconds()}
+			switch {
+			case errors.Is(result.Err, errs.ErrNoInstance):
+				// e.g. a lab cluster without SnapMirror relationships
+				c.Skipped = &Skipped{Message: result.Err.Error()}
+			case result.Err != nil:
+				c.Failure = &Failure{Message: "poll failed", Text: result.Err.Error()}
+			}
+			suite.Cases = append(suite.Cases, c)
+			suite.Time += c.Time
+			matrices = append(matrices, result.Matrices...)
+		}
+		suite.Cases = append(suite.Cases, m.check(name, matrices)...)
+		report.add(suite)
+	}
+
+	// the manifest of the collectors that did not run is skipped
+	for _, name := range sortedKeys(m) {
+		if _, ok := byCollector[name]; ok {
+			continue
+		}
+		suite := TestSuite{Name: name}
+		for _, object := range sortedKeys(m[name]) {
+			suite.Cases = append(suite.Cases, TestCase{
+				ClassName: name + "." + object,
+				Name:      "manifest",
+				Skipped:   &Skipped{Message: "collector " + name + " did not run"},
+			})
+		}
+		report.add(suite)
+	}
+	return report
+}
+
+// hasPerf returns true when a collector is a performance collector, which exports metrics from its second poll
+func hasPerf(collectors []string) bool {
+	for _, c := range collectors {
+		name, _, _ := strings.Cut(c, ":")
+		if strings.HasSuffix(name, "Perf") {
+			return true
+		}
+	}
+	return false
+}
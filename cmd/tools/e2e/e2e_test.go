@@ -0,0 +1,92 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/netapp/harvest/v2/pkg/runner"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	m, err := LoadManifest("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, collector := range []string{"Rest", "RestPerf", "Zapi", "ZapiPerf"} {
+		if len(m[collector]) == 0 {
+			t.Errorf("default manifest has no objects of %s", collector)
+		}
+	}
+	if _, err := LoadManifest("testdata/nope.yaml"); err == nil {
+		t.Errorf("LoadManifest() of a missing file, want error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := LoadManifest("testdata/manifest.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Run(runner.Options{Config: "testdata/harvest.yml", Poller: "local", ConfPath: "../../../conf"}, m, 0)
+
+	results := make(map[string]string) // classname name => result
+	for _, s := range report.Suites {
+		for _, c := range s.Cases {
+			result := "pass"
+			if c.Failure != nil {
+				result = "fail"
+			}
+			if c.Skipped != nil {
+				result = "skip"
+			}
+			results[c.ClassName+" "+c.Name] = result
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "harvest initialize", want: "pass"},
+		{name: "Simple poll Simple:nodemon", want: "pass"},
+		{name: "Simple.nodemon nodemon_num_cpu", want: "pass"},
+		{name: "Simple.nodemon nodemon_num_threads", want: "fail"},
+		{name: "Simple.nodemon label poller", want: "pass"},
+		// global labels are exported too
+		{name: "Simple.nodemon label datacenter", want: "pass"},
+		{name: "Simple.nodemon label serial", want: "fail"},
+		{name: "Simple.metrocluster metrocluster_switchover", want: "fail"},
+		// Rest is not a collector of the poller
+		{name: "Rest.volume manifest", want: "skip"},
+	}
+	for _, tt := range tests {
+		if got := results[tt.name]; got != tt.want {
+			t.Errorf("%s got=%q, want=%q", tt.name, got, tt.want)
+		}
+	}
+	if report.Tests != len(tests) || report.Failures != 3 || report.Skipped != 1 {
+		t.Errorf("report got tests=%d failures=%d skipped=%d, want tests=%d failures=3 skipped=1",
+			report.Tests, report.Failures, report.Skipped, len(tests))
+	}
+
+	// the report is valid JUnit XML
+	var b bytes.Buffer
+	if err := report.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := xml.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Failures != report.Failures || len(decoded.Suites) != len(report.Suites) {
+		t.Errorf("decoded report got failures=%d suites=%d, want failures=%d suites=%d",
+			decoded.Failures, len(decoded.Suites), report.Failures, len(report.Suites))
+	}
+}
+
+func TestRunUnknownPoller(t *testing.T) {
+	report := Run(runner.Options{Config: "testdata/harvest.yml", Poller: "nope"}, Manifest{}, 0)
+	if report.Failures != 1 || report.Suites[0].Cases[0].Name != "initialize" {
+		t.Errorf("report got failures=%d, want the initialize failure", report.Failures)
+	}
+}
@@ -0,0 +1,76 @@
+package e2e
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Report is a JUnit report, the format that CI systems such as Jenkins and GitLab read.
+// There is a suite for each collector, with a test case for each poll and each metric and label of the manifest
+type Report struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+type TestSuite struct {
+	Name     string     `xml:"name,attr"`
+	Tests    int        `xml:"tests,attr"`
+	Failures int        `xml:"failures,attr"`
+	Skipped  int        `xml:"skipped,attr"`
+	Time     float64    `xml:"time,attr"`
+	Cases    []TestCase `xml:"testcase"`
+}
+
+type TestCase struct {
+	ClassName string   `xml:"classname,attr"`
+	Name      string   `xml:"name,attr"`
+	Time      float64  `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+}
+
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// add adds a suite to the report and counts its tests
+func (r *Report) add(s TestSuite) {
+	s.Tests = len(s.Cases)
+	for _, c := range s.Cases {
+		if c.Failure != nil {
+			s.Failures++
+		}
+		if c.Skipped != nil {
+			s.Skipped++
+		}
+	}
+	r.Tests += s.Tests
+	r.Failures += s.Failures
+	r.Skipped += s.Skipped
+	r.Time += s.Time
+	r.Suites = append(r.Suites, s)
+}
+
+// Write writes the report to w as XML
+func (r *Report) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
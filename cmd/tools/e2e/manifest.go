@@ -0,0 +1,162 @@
+package e2e
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"gopkg.in/yaml.v3"
+	"os"
+	"slices"
+	"strings"
+)
+
+//go:embed manifest.yaml
+var defaultManifest []byte
+
+// Manifest is the metrics and labels that each object of each collector must export, collector => object => object
+type Manifest map[string]map[string]Object
+
+// Object is what an object must export
+type Object struct {
+	Metrics []string `yaml:"metrics"`
+	Labels  []string `yaml:"labels"`
+}
+
+// LoadManifest reads the manifest at path, or the default manifest when path is empty
+func LoadManifest(path string) (Manifest, error) {
+	contents := defaultManifest
+	name := "default manifest"
+	if path != "" {
+		name = path
+		var err error
+		if contents, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+		}
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(contents, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// exported is what the matrices of an object export
+type exported struct {
+	metrics map[string]bool // metrics with a value for an exported instance
+	labels  map[string]bool // exported labels with a value for an exported instance
+}
+
+// exports returns what the matrices export, by object
+func exports(matrices []*matrix.Matrix) map[string]*exported {
+	result := make(map[string]*exported)
+	for _, m := range matrices {
+		if !m.IsExportable() {
+			continue
+		}
+		e := result[m.Object]
+		if e == nil {
+			e = &exported{metrics: make(map[string]bool), labels: make(map[string]bool)}
+			result[m.Object] = e
+		}
+
+		var keys []string
+		includeAll := false
+		if options := m.GetExportOptions(); options != nil {
+			if x := options.GetChildS("instance_keys"); x != nil {
+				keys = append(keys, x.GetAllChildContentS()...)
+			}
+			if x := options.GetChildS("instance_labels"); x != nil {
+				keys = append(keys, x.GetAllChildContentS()...)
+			}
+			includeAll = options.GetChildContentS("include_all_labels") == "true"
+		}
+		for label, value := range m.GetGlobalLabels() {
+			if value != "" {
+				e.labels[label] = true
+			}
+		}
+
+		for _, instance := range m.GetInstances() {
+			if !instance.IsExportable() {
+				continue
+			}
+			for label, value := range instance.GetLabels() {
+				if value != "" && (includeAll || slices.Contains(keys, label)) {
+					e.labels[label] = true
+				}
+			}
+			for _, metric := range m.GetMetrics() {
+				if !metric.IsExportable() {
+					continue
+				}
+				if _, ok := metric.GetValueFloat64(instance); ok {
+					e.metrics[m.Object+"_"+metric.GetName()] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+// check returns a test case for each metric and label that the manifest requires of the objects of a collector.
+// matrices are the matrices of all the objects of the collector
+func (m Manifest) check(collector string, matrices []*matrix.Matrix) []TestCase {
+	objects := m[collector]
+	if len(objects) == 0 {
+		return nil
+	}
+	exports := exports(matrices)
+
+	var cases []TestCase
+	for _, object := range sortedKeys(objects) {
+		want := objects[object]
+		got := exports[object]
+		className := collector + "." + object
+		for _, metric := range want.Metrics {
+			c := TestCase{ClassName: className, Name: metric}
+			switch {
+			case got == nil:
+				c.Failure = &Failure{Message: "object " + object + " is not exported"}
+			case !got.metrics[metric]:
+				c.Failure = &Failure{Message: "metric " + metric + " is not exported", Text: nearest(object, metric, got.metrics)}
+			}
+			cases = append(cases, c)
+		}
+		for _, label := range want.Labels {
+			c := TestCase{ClassName: className, Name: "label " + label}
+			switch {
+			case got == nil:
+				c.Failure = &Failure{Message: "object " + object + " is not exported"}
+			case !got.labels[label]:
+				c.Failure = &Failure{Message: "label " + label + " has no value", Text: "exported labels: " + strings.Join(sortedKeys(got.labels), ", ")}
+			}
+			cases = append(cases, c)
+		}
+	}
+	return cases
+}
+
+// nearest lists the exported metrics of object that share the first word of metric after the object, to spot
+// renamed metrics
+func nearest(object string, metric string, metrics map[string]bool) string {
+	word, _, _ := strings.Cut(strings.TrimPrefix(metric, object+"_"), "_")
+	var similar []string
+	for _, name := range sortedKeys(metrics) {
+		if strings.HasPrefix(name, object+"_"+word) {
+			similar = append(similar, name)
+		}
+	}
+	if len(similar) == 0 {
+		return ""
+	}
+	return "similar metrics: " + strings.Join(similar, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -37,6 +37,7 @@ type PollerInfo struct {
 	IsFull        bool
 	CertDir       string
 	Mounts        []string
+	Resources     *Resources // nil when the instances of the poller were not observed
 }
 
 type AdminInfo struct {
@@ -60,18 +61,23 @@ type PromTemplate struct {
 }
 
 type options struct {
-	Poller      string
-	loglevel    int
-	image       string
-	filesdPath  string
-	showPorts   bool
-	outputPath  string
-	certDir     string
-	promPort    int
-	grafanaPort int
-	mounts      []string
-	configPath  string
-	confPath    string
+	Poller        string
+	loglevel      int
+	image         string
+	filesdPath    string
+	showPorts     bool
+	outputPath    string
+	certDir       string
+	promPort      int
+	grafanaPort   int
+	mounts        []string
+	configPath    string
+	confPath      string
+	namespace     string
+	instancesURL  string
+	templatesPath string
+	maxInstances  int64
+	maxAPILoad    float64
 }
 
 var metricRe = regexp.MustCompile(`(\w+)\{`)
@@ -186,6 +192,7 @@ func generateDocker(kind int) {
 	configFilePath = asComposePath(opts.configPath)
 	certDirPath = asComposePath(opts.certDir)
 	filesd := make([]string, 0, len(conf.Config.PollersOrdered))
+	hints := observedResources()
 
 	for _, v := range conf.Config.PollersOrdered {
 		port, _ := conf.GetLastPromPort(v, true)
@@ -201,6 +208,7 @@ func generateDocker(kind int) {
 			IsFull:        kind == full,
 			CertDir:       certDirPath,
 			Mounts:        makeMounts(v),
+			Resources:     hints[v],
 		}
 		pollerTemplate.Pollers = append(pollerTemplate.Pollers, pollerInfo)
 		filesd = append(filesd, fmt.Sprintf("- targets: ['%s:%d']", pollerInfo.ServiceName, pollerInfo.Port))
@@ -340,6 +348,7 @@ func copyFiles(srcPath, destPath string) error {
 		"bin":                   true,
 		"autosupport":           true,
 		"onePollerPerContainer": true,
+		"k8":                    true,
 	}
 	// requires specific permissions
 	dirsPermissions := map[string]os.FileMode{
@@ -664,6 +673,8 @@ func init() {
 	Cmd.AddCommand(metricCmd)
 	Cmd.AddCommand(descCmd)
 	Cmd.AddCommand(dockerCmd)
+	Cmd.AddCommand(k8sCmd)
+	Cmd.AddCommand(layoutCmd)
 	dockerCmd.AddCommand(fullCmd)
 
 	dFlags := dockerCmd.PersistentFlags()
@@ -686,6 +697,26 @@ func init() {
 	dFlags.BoolVarP(&opts.showPorts, "port", "p", true, "Expose poller ports to host machine")
 	_ = dockerCmd.MarkPersistentFlagRequired("output")
 	dFlags.StringSliceVar(&opts.mounts, "volume", []string{}, "Additional volume mounts to include in compose file")
+	dFlags.StringVar(&opts.instancesURL, "instances", "", "Prometheus URL to read the instances of each poller from, to add resource hints")
+
+	kFlags := k8sCmd.Flags()
+	kFlags.IntVarP(&opts.loglevel, "loglevel", "l", 2,
+		"logging level (0=trace, 1=debug, 2=info, 3=warning, 4=error, 5=critical)",
+	)
+	kFlags.StringVar(&opts.image, "image", "ghcr.io/netapp/harvest:latest", "Harvest image")
+	kFlags.StringVarP(&opts.outputPath, "output", "o", "", "Output file path. ")
+	kFlags.StringVarP(&opts.namespace, "namespace", "n", "harvest", "Kubernetes namespace of the pollers")
+	kFlags.StringVar(&opts.instancesURL, "instances", "", "Prometheus URL to read the instances of each poller from, to add resource hints")
+	_ = k8sCmd.MarkFlagRequired("output")
+
+	lFlags := layoutCmd.Flags()
+	lFlags.StringVarP(&opts.outputPath, "output", "o", "", "Output file path of the harvest.yml with the layout")
+	lFlags.StringVar(&opts.instancesURL, "instances", "", "Prometheus URL to read the cost of each object from")
+	lFlags.StringVar(&opts.templatesPath, "templates", "layout", "Directory of the collector templates of the layout, added to the conf_path of the pollers")
+	lFlags.Int64Var(&opts.maxInstances, "max-instances", 50_000, "Maximum number of instances a poller collects")
+	lFlags.Float64Var(&opts.maxAPILoad, "max-api-load", 1, "Maximum API time of a poller, per second of its polls")
+	_ = layoutCmd.MarkFlagRequired("output")
+	_ = layoutCmd.MarkFlagRequired("instances")
 
 	fFlags.StringVar(&opts.filesdPath, "filesdpath", "container/prometheus/harvest_targets.yml",
 		"Prometheus file_sd target path. Written when the --output is set")
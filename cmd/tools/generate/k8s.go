@@ -0,0 +1,211 @@
+package generate
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/color"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/requests"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// K8sTemplate is the data of the Kubernetes manifest template
+type K8sTemplate struct {
+	Namespace string
+	Secret    string // name of the secret with harvest.yml
+	Pollers   []PollerInfo
+}
+
+// Resources are the resource hints of a poller container, derived from the number of instances the poller collects
+type Resources struct {
+	Instances  int64
+	RequestMiB int64 // memory request
+	LimitMiB   int64 // memory limit
+	CPUMilli   int64 // CPU request, in thousandths of a core
+}
+
+// CPUs returns the CPU request in cores, e.g. 0.15
+func (r Resources) CPUs() string {
+	return strconv.FormatFloat(float64(r.CPUMilli)/1000, 'f', -1, 64)
+}
+
+// The resource hints are a rough model of a poller: a baseline, and memory and CPU that grow with the instances
+// it collects. 10 pollers with the default templates fit in 1 GB and 2 cores, see system requirements
+const (
+	baseMemoryMiB     = 64
+	instancesPerMiB   = 200
+	baseCPUMilli      = 100
+	instancesPerMilli = 500
+)
+
+// instancesQuery is the number of instances each poller collected during the last day
+const instancesQuery = `sum by (poller) (max_over_time(metadata_collector_instances{task="data"}[1d]))`
+
+var k8sCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "generate Kubernetes manifests for all pollers defined in config",
+	Long: `Generate a Kubernetes manifest with a Deployment and a Service for each poller defined in config.
+The manifest can be applied with kubectl, or copied into the templates of a Helm chart.`,
+	Run: doK8s,
+}
+
+func doK8s(cmd *cobra.Command, _ []string) {
+	addRootOptions(cmd)
+	generateK8s()
+}
+
+func generateK8s() {
+	_, err := conf.LoadHarvestConfig(opts.configPath)
+	if err != nil {
+		logErrAndExit(err)
+	}
+	hints := observedResources()
+
+	k8sTemplate := K8sTemplate{Namespace: opts.namespace, Secret: "harvest-config"}
+	for _, v := range conf.Config.PollersOrdered {
+		port, _ := conf.GetLastPromPort(v, true)
+		k8sTemplate.Pollers = append(k8sTemplate.Pollers, PollerInfo{
+			ServiceName: k8sName("poller-" + v),
+			PollerName:  v,
+			Port:        port,
+			LogLevel:    opts.loglevel,
+			Image:       opts.image,
+			Resources:   hints[v],
+		})
+	}
+
+	t, err := template.New("k8s.tmpl").ParseFiles("container/k8/k8s.tmpl")
+	if err != nil {
+		logErrAndExit(err)
+	}
+	outputPath := opts.outputPath
+	// in a container, relative paths are written to the mounted folder of the host
+	if os.Getenv("HARVEST_DOCKER") != "" && !filepath.IsAbs(outputPath) {
+		outputPath = filepath.Join("/opt/temp", outputPath)
+	}
+	out, err := os.Create(outputPath)
+	if err != nil {
+		logErrAndExit(err)
+	}
+	defer silentClose(out)
+	if err := t.Execute(out, k8sTemplate); err != nil {
+		logErrAndExit(err)
+	}
+
+	color.DetectConsole("")
+	_, _ = fmt.Fprintf(os.Stderr,
+		"Create the namespace and the secret with your harvest.yml, and apply the manifest with:\n"+
+			color.Colorize("kubectl create namespace "+opts.namespace+"\n", color.Green)+
+			color.Colorize("kubectl create secret generic "+k8sTemplate.Secret+" --namespace "+opts.namespace+" --from-file=harvest.yml="+opts.configPath+"\n", color.Green)+
+			color.Colorize("kubectl apply -f "+opts.outputPath+"\n", color.Green))
+}
+
+var k8sNameRe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// k8sName returns name as a Kubernetes resource name: lowercase alphanumerics and dashes, at most 63 characters
+func k8sName(name string) string {
+	name = k8sNameRe.ReplaceAllString(normalizeContainerNames(name), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "-")
+}
+
+// observedResources returns the resource hints of the pollers whose instances were observed by the Prometheus
+// of --instances. It returns nil when --instances is not set
+func observedResources() map[string]*Resources {
+	if opts.instancesURL == "" {
+		return nil
+	}
+	instances, err := fetchInstances(opts.instancesURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "no resource hints, failed to query instances: %v\n", err)
+		return nil
+	}
+	hints := make(map[string]*Resources, len(instances))
+	for poller, n := range instances {
+		hints[poller] = resourcesFor(n)
+	}
+	for _, poller := range conf.Config.PollersOrdered {
+		if _, ok := hints[poller]; !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "no resource hints for poller %s, no instances observed\n", poller)
+		}
+	}
+	return hints
+}
+
+// resourcesFor returns the resource hints of a poller that collects instances.
+// Memory is rounded up to 32 MiB and CPU to 50 millicores
+func resourcesFor(instances int64) *Resources {
+	request := roundUp(baseMemoryMiB+instances/instancesPerMiB, 32)
+	return &Resources{
+		Instances:  instances,
+		RequestMiB: request,
+		LimitMiB:   2 * request,
+		CPUMilli:   roundUp(baseCPUMilli+instances/instancesPerMilli, 50),
+	}
+}
+
+func roundUp(n int64, to int64) int64 {
+	return (n + to - 1) / to * to
+}
+
+// fetchInstances returns the number of instances each poller collected, queried from a Prometheus server
+func fetchInstances(prometheusURL string) (map[string]int64, error) {
+	body, err := queryPrometheus(prometheusURL, instancesQuery)
+	if err != nil {
+		return nil, err
+	}
+	return parseInstances(body)
+}
+
+// queryPrometheus returns the response of an instant query of a Prometheus server
+func queryPrometheus(prometheusURL string, query string) ([]byte, error) {
+	u := strings.TrimSuffix(prometheusURL, "/") + "/api/v1/query?query=" + url.QueryEscape(query)
+	request, err := requests.New(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: time.Minute}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer silentClose(response.Body)
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, response.Status)
+	}
+	return body, nil
+}
+
+// parseInstances parses the response of a Prometheus instant query, by poller
+func parseInstances(body []byte) (map[string]int64, error) {
+	if status := gjson.GetBytes(body, "status").String(); status != "success" {
+		return nil, fmt.Errorf("query failed: status=%q error=%q", status, gjson.GetBytes(body, "error").String())
+	}
+	instances := make(map[string]int64)
+	for _, r := range gjson.GetBytes(body, "data.result").Array() {
+		poller := r.Get("metric.poller").String()
+		// the value of a sample is [timestamp, "value"]
+		value, err := strconv.ParseFloat(r.Get("value.1").String(), 64)
+		if poller == "" || err != nil {
+			continue
+		}
+		instances[poller] = int64(value)
+	}
+	return instances, nil
+}
@@ -0,0 +1,121 @@
+package generate
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func Test_k8sName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "dots and underscores", in: "poller-Cluster_01.lab", want: "poller-cluster-01-lab"},
+		{name: "other characters", in: "poller-a b/c", want: "poller-a-b-c"},
+		{name: "too long", in: "poller-" + strings.Repeat("a", 55) + "-bcd", want: "poller-" + strings.Repeat("a", 55)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k8sName(tt.in); got != tt.want {
+				t.Errorf("k8sName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourcesFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances int64
+		want      Resources
+	}{
+		{name: "none", instances: 0, want: Resources{RequestMiB: 64, LimitMiB: 128, CPUMilli: 100}},
+		{name: "rounded up", instances: 24_000, want: Resources{Instances: 24_000, RequestMiB: 192, LimitMiB: 384, CPUMilli: 150}},
+		{name: "large", instances: 500_000, want: Resources{Instances: 500_000, RequestMiB: 2592, LimitMiB: 5184, CPUMilli: 1100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, *resourcesFor(tt.instances)); diff != "" {
+				t.Errorf("resourcesFor() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if got := resourcesFor(24_000).CPUs(); got != "0.15" {
+		t.Errorf("CPUs() = %v, want 0.15", got)
+	}
+}
+
+func Test_parseInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{
+			name: "vector",
+			body: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"poller":"u2"},"value":[1700000000,"24000"]},
+				{"metric":{"poller":"sar"},"value":[1700000000,"1234.5"]},
+				{"metric":{},"value":[1700000000,"1"]},
+				{"metric":{"poller":"nan"},"value":[1700000000,"x"]}]}}`,
+			want: map[string]int64{"u2": 24000, "sar": 1234},
+		},
+		{name: "empty", body: `{"status":"success","data":{"resultType":"vector","result":[]}}`, want: map[string]int64{}},
+		{name: "error", body: `{"status":"error","errorType":"bad_data","error":"parse error"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInstances([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInstances() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); !tt.wantErr && diff != "" {
+				t.Errorf("parseInstances() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_k8sTemplate(t *testing.T) {
+	tmpl, err := template.New("k8s.tmpl").ParseFiles("../../../container/k8/k8s.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, K8sTemplate{
+		Namespace: "harvest",
+		Secret:    "harvest-config",
+		Pollers: []PollerInfo{
+			{ServiceName: "poller-u2", PollerName: "u2", Port: 13001, LogLevel: 2, Image: "harvest:test", Resources: resourcesFor(24_000)},
+			{ServiceName: "poller-sar", PollerName: "sar", LogLevel: 2, Image: "harvest:test"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"name: poller-u2",
+		"name: poller-sar",
+		"memory: 192Mi",
+		"cpu: 150m",
+		"memory: 384Mi",
+		"secretName: harvest-config",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("manifest missing %q in\n%s", want, got)
+		}
+	}
+	// pollers without a port have no Service, and pollers without hints no resources
+	if n := strings.Count(got, "kind: Service"); n != 1 {
+		t.Errorf("manifest has %d services, want 1", n)
+	}
+	if n := strings.Count(got, "resources:"); n != 1 {
+		t.Errorf("manifest has %d resources, want 1", n)
+	}
+}
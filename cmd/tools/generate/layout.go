@@ -0,0 +1,535 @@
+package generate
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/color"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	harvestyaml "github.com/netapp/harvest/v2/pkg/tree/yaml"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// objectCost is the measured cost of polling an object of a poller
+type objectCost struct {
+	Poller    string
+	Collector string
+	Object    string
+	Instances int64
+	APITime   time.Duration // average API time of a data poll
+	Interval  time.Duration // interval of the data poll
+}
+
+// load is the fraction of the interval the object waits on the cluster's API
+func (o objectCost) load() float64 {
+	if o.Interval <= 0 {
+		return 0
+	}
+	return o.APITime.Seconds() / o.Interval.Seconds()
+}
+
+// pollerBin is a poller of the layout and the objects assigned to it
+type pollerBin struct {
+	Name      string
+	Objects   []objectCost
+	Instances int64
+	Load      float64
+}
+
+// The layout queries the instances and the API time of the data polls of each object during the last day
+const (
+	objectInstancesQuery = `max by (poller, collector, object) (max_over_time(metadata_collector_instances{task="data"}[1d]))`
+	objectAPITimeQuery   = `max by (poller, collector, object, interval) (avg_over_time(metadata_collector_api_time{task="data"}[1d]))`
+)
+
+// maxObjectLoad is the largest fraction of its interval a data poll may wait on the API.
+// Objects above it get a longer interval, so a poll finishes well before the next one starts
+const maxObjectLoad = 0.5
+
+// intervals are the data poll intervals the layout proposes
+var intervals = []time.Duration{
+	time.Minute, 2 * time.Minute, 3 * time.Minute, 5 * time.Minute, 10 * time.Minute,
+	15 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+var layoutCmd = &cobra.Command{
+	Use:   "layout",
+	Short: "generate a harvest.yml that spreads the objects of each poller across pollers",
+	Long: `Generate a harvest.yml that spreads the objects of each poller across as many pollers as needed to fit
+the instance and API budgets of a poller. The cost of each object is read from the metadata the pollers exported
+to Prometheus during the last day. Objects whose polls take too long get a longer poll interval.`,
+	Run: doLayout,
+}
+
+func doLayout(cmd *cobra.Command, _ []string) {
+	addRootOptions(cmd)
+	generateLayout()
+}
+
+func generateLayout() {
+	_, err := conf.LoadHarvestConfig(opts.configPath)
+	if err != nil {
+		logErrAndExit(err)
+	}
+	costs, err := fetchObjectCosts(opts.instancesURL)
+	if err != nil {
+		logErrAndExit(err)
+	}
+
+	byPoller := make(map[string][]objectCost)
+	for _, c := range costs {
+		byPoller[c.Poller] = append(byPoller[c.Poller], c)
+	}
+
+	layout := make(map[string][]*pollerBin)
+	for _, name := range conf.Config.PollersOrdered {
+		objects, ok := byPoller[name]
+		if !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "poller %s is unchanged, no objects observed\n", name)
+			continue
+		}
+		proposed := slices.Clone(objects)
+		for i, o := range objects {
+			proposed[i].Interval = proposeInterval(o, maxObjectLoad)
+		}
+		bins := packObjects(name, proposed, opts.maxInstances, opts.maxAPILoad)
+		layout[name] = bins
+		printLayout(name, objects, proposed, bins)
+	}
+
+	outputPath := containerPath(opts.outputPath)
+	templatesPath := containerPath(opts.templatesPath)
+	pollers := make(map[string][]*yaml.Node)
+	for name, bins := range layout {
+		poller := conf.Config.Pollers[name]
+		nodes, err := writeLayoutTemplates(poller, bins, templatesPath)
+		if err != nil {
+			logErrAndExit(err)
+		}
+		pollers[name] = nodes
+	}
+
+	src, err := os.ReadFile(opts.configPath)
+	if err != nil {
+		logErrAndExit(err)
+	}
+	out, err := layoutConfig(src, layout, pollers)
+	if err != nil {
+		logErrAndExit(err)
+	}
+	if err := os.WriteFile(outputPath, out, 0o600); err != nil {
+		logErrAndExit(err)
+	}
+
+	color.DetectConsole("")
+	_, _ = fmt.Fprintf(os.Stderr,
+		"Review the layout, and start the pollers with:\n"+
+			color.Colorize("bin/harvest start --config "+opts.outputPath+"\n", color.Green))
+}
+
+// containerPath returns the path of a file written by the tool. In a container, relative paths are written to
+// the mounted folder of the host
+func containerPath(path string) string {
+	if os.Getenv("HARVEST_DOCKER") != "" && !filepath.IsAbs(path) {
+		return filepath.Join("/opt/temp", path)
+	}
+	return path
+}
+
+// fetchObjectCosts returns the cost of the objects of each poller, queried from a Prometheus server
+func fetchObjectCosts(prometheusURL string) ([]objectCost, error) {
+	instances, err := queryPrometheus(prometheusURL, objectInstancesQuery)
+	if err != nil {
+		return nil, err
+	}
+	apiTimes, err := queryPrometheus(prometheusURL, objectAPITimeQuery)
+	if err != nil {
+		return nil, err
+	}
+	return parseObjectCosts(instances, apiTimes)
+}
+
+// parseObjectCosts joins the responses of the instances and API time queries by poller, collector, and object.
+// Objects without an API time are left out, since their interval is unknown
+func parseObjectCosts(instancesBody []byte, apiTimesBody []byte) ([]objectCost, error) {
+	instances := make(map[string]int64)
+	err := eachSample(instancesBody, func(metric gjson.Result, value float64) {
+		instances[objectKey(metric)] = int64(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var costs []objectCost
+	err = eachSample(apiTimesBody, func(metric gjson.Result, value float64) {
+		// the interval label is in seconds, e.g. 180.0000
+		interval, err := strconv.ParseFloat(metric.Get("interval").String(), 64)
+		if err != nil || interval <= 0 {
+			return
+		}
+		costs = append(costs, objectCost{
+			Poller:    metric.Get("poller").String(),
+			Collector: metric.Get("collector").String(),
+			Object:    metric.Get("object").String(),
+			Instances: instances[objectKey(metric)],
+			APITime:   time.Duration(value) * time.Microsecond,
+			Interval:  time.Duration(interval * float64(time.Second)).Round(time.Second),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(costs, func(a, b objectCost) int {
+		return cmp.Or(
+			strings.Compare(a.Poller, b.Poller),
+			strings.Compare(a.Collector, b.Collector),
+			strings.Compare(a.Object, b.Object),
+		)
+	})
+	return costs, nil
+}
+
+func objectKey(metric gjson.Result) string {
+	return metric.Get("poller").String() + "/" + metric.Get("collector").String() + "/" + metric.Get("object").String()
+}
+
+// eachSample calls fn with the labels and the value of each sample of the response of a Prometheus instant query.
+// Samples without a poller, collector, or object are skipped
+func eachSample(body []byte, fn func(metric gjson.Result, value float64)) error {
+	if status := gjson.GetBytes(body, "status").String(); status != "success" {
+		return fmt.Errorf("query failed: status=%q error=%q", status, gjson.GetBytes(body, "error").String())
+	}
+	for _, r := range gjson.GetBytes(body, "data.result").Array() {
+		metric := r.Get("metric")
+		// the value of a sample is [timestamp, "value"]
+		value, err := strconv.ParseFloat(r.Get("value.1").String(), 64)
+		if err != nil || metric.Get("poller").String() == "" || metric.Get("collector").String() == "" ||
+			metric.Get("object").String() == "" {
+			continue
+		}
+		fn(metric, value)
+	}
+	return nil
+}
+
+// proposeInterval returns the interval of an object. Objects within maxLoad keep their interval, others get the
+// shortest longer interval within maxLoad, or the longest interval when none is
+func proposeInterval(o objectCost, maxLoad float64) time.Duration {
+	if o.load() <= maxLoad {
+		return o.Interval
+	}
+	for _, interval := range intervals {
+		if interval > o.Interval && o.APITime.Seconds()/interval.Seconds() <= maxLoad {
+			return interval
+		}
+	}
+	return max(o.Interval, intervals[len(intervals)-1])
+}
+
+// packObjects assigns the objects of a poller to as few pollers as fit the budgets of a poller, first fit
+// decreasing. The weight of an object is the largest fraction of a budget it uses. An object larger than a budget
+// gets a poller of its own. Pollers are named after the original poller: name, name-2, name-3...
+func packObjects(name string, objects []objectCost, maxInstances int64, maxLoad float64) []*pollerBin {
+	weight := func(o objectCost) float64 {
+		return max(float64(o.Instances)/float64(maxInstances), o.load()/maxLoad)
+	}
+	sorted := slices.Clone(objects)
+	slices.SortStableFunc(sorted, func(a, b objectCost) int {
+		return cmp.Compare(weight(b), weight(a))
+	})
+
+	var bins []*pollerBin
+	for _, o := range sorted {
+		var bin *pollerBin
+		for _, b := range bins {
+			if b.Instances+o.Instances <= maxInstances && b.Load+o.load() <= maxLoad {
+				bin = b
+				break
+			}
+		}
+		if bin == nil {
+			bin = &pollerBin{Name: name}
+			if len(bins) > 0 {
+				bin.Name = name + "-" + strconv.Itoa(len(bins)+1)
+			}
+			bins = append(bins, bin)
+		}
+		bin.Objects = append(bin.Objects, o)
+		bin.Instances += o.Instances
+		bin.Load += o.load()
+	}
+	return bins
+}
+
+func printLayout(name string, measured []objectCost, proposed []objectCost, bins []*pollerBin) {
+	fmt.Printf("%s: %d objects on %d pollers\n", name, len(measured), len(bins))
+	for _, b := range bins {
+		fmt.Printf("  %-30s objects=%-3d instances=%-8d api_load=%.2f\n", b.Name, len(b.Objects), b.Instances, b.Load)
+	}
+	for i, m := range measured {
+		if p := proposed[i]; p.Interval != m.Interval {
+			fmt.Printf("  %s:%s interval %s => %s, api_time=%s\n", m.Collector, m.Object,
+				formatInterval(m.Interval), formatInterval(p.Interval), m.APITime.Round(time.Millisecond))
+		}
+	}
+}
+
+// formatInterval formats an interval the way templates do, e.g. 3m
+func formatInterval(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	default:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+}
+
+// writeLayoutTemplates writes a template for each collector and interval of each poller of the layout, and returns
+// the collectors of each poller, as YAML nodes of harvest.yml
+func writeLayoutTemplates(poller *conf.Poller, bins []*pollerBin, templatesPath string) ([]*yaml.Node, error) {
+	confPath := cmp.Or(poller.ConfPath, opts.confPath, conf.DefaultConfPath)
+	confPaths := filepath.SplitList(confPath)
+
+	nodes := make([]*yaml.Node, 0, len(bins))
+	for _, b := range bins {
+		var collectors []map[string][]string
+		for _, group := range groupObjects(b.Objects) {
+			first := group[0]
+			templateName := "layout-" + k8sName(b.Name) + "-" + formatInterval(first.Interval) + ".yaml"
+			template, err := layoutTemplate(poller, confPaths, group)
+			if err != nil {
+				return nil, err
+			}
+			dir := filepath.Join(templatesPath, strings.ToLower(first.Collector))
+			if err := os.MkdirAll(dir, 0o750); err != nil {
+				return nil, err
+			}
+			data, err := harvestyaml.Dump(template)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(filepath.Join(dir, templateName), append(data, '\n'), 0o600); err != nil {
+				return nil, err
+			}
+			collectors = append(collectors, map[string][]string{first.Collector: {templateName}})
+		}
+		// collectors of the poller that were not observed stay on the first poller
+		if len(nodes) == 0 {
+			for _, c := range poller.Collectors {
+				if !observed(c.Name, bins) {
+					templates := *conf.NewCollector(c.Name).Templates
+					if c.Templates != nil {
+						templates = *c.Templates
+					}
+					collectors = append(collectors, map[string][]string{c.Name: templates})
+				}
+			}
+		}
+		var n yaml.Node
+		if err := n.Encode(collectors); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &n)
+	}
+	return nodes, nil
+}
+
+// observed returns true when the objects of a collector are part of the layout. ZAPI collectors are observed as
+// their REST counterpart when the poller switched to REST
+func observed(name string, bins []*pollerBin) bool {
+	for _, b := range bins {
+		for _, o := range b.Objects {
+			if o.Collector == name || o.Collector == strings.Replace(name, "Zapi", "Rest", 1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupObjects groups objects by collector and interval, in order of collector and interval
+func groupObjects(objects []objectCost) [][]objectCost {
+	sorted := slices.Clone(objects)
+	slices.SortFunc(sorted, func(a, b objectCost) int {
+		return cmp.Or(
+			strings.Compare(a.Collector, b.Collector),
+			cmp.Compare(a.Interval, b.Interval),
+			strings.Compare(a.Object, b.Object),
+		)
+	})
+	var groups [][]objectCost
+	for i, o := range sorted {
+		if i == 0 || o.Collector != sorted[i-1].Collector || o.Interval != sorted[i-1].Interval {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], o)
+	}
+	return groups
+}
+
+// layoutTemplate returns the collector template of a group of objects with the same collector and interval:
+// the templates of the collector in harvest.yml, with only the objects of the group, and their interval
+func layoutTemplate(poller *conf.Poller, confPaths []string, group []objectCost) (*node.Node, error) {
+	name := group[0].Collector
+	templates := *conf.NewCollector(name).Templates
+	for _, c := range poller.Collectors {
+		if c.Name == name && c.Templates != nil {
+			templates = *c.Templates
+		}
+	}
+
+	var template *node.Node
+	for _, t := range templates {
+		sub, err := collector.ImportTemplate(confPaths, t, name)
+		if err != nil {
+			// custom.yaml does not exist for most people
+			continue
+		}
+		if template == nil {
+			template = sub
+		} else if name == "Zapi" || name == "ZapiPerf" {
+			template.Merge(sub, []string{"objects"})
+		} else {
+			template.Merge(sub, []string{""})
+		}
+	}
+	if template == nil {
+		return nil, fmt.Errorf("no templates of %s found on conf path %s", name, strings.Join(confPaths, ":"))
+	}
+
+	all := template.PopChildS("objects")
+	if all == nil {
+		return nil, fmt.Errorf("templates of %s have no objects", name)
+	}
+	objects := template.NewChildS("objects", "")
+	for _, o := range group {
+		t := all.GetChildS(o.Object)
+		if t == nil {
+			return nil, fmt.Errorf("object %s of %s is not in the templates %s", o.Object, name, strings.Join(templates, ","))
+		}
+		objects.NewChildS(o.Object, t.GetContentS())
+	}
+
+	schedule := template.GetChildS("schedule")
+	if schedule == nil {
+		schedule = template.NewChildS("schedule", "")
+	}
+	if data := schedule.GetChildS("data"); data != nil {
+		data.SetContentS(formatInterval(group[0].Interval))
+	} else {
+		schedule.NewChildS("data", formatInterval(group[0].Interval))
+	}
+	return template, nil
+}
+
+// layoutConfig returns harvest.yml with each poller of the layout replaced by the pollers of its layout.
+// The pollers are copies of the original poller with their own collectors. Only the first poller keeps the
+// prom_port of the original, and all of them search the templates of the layout first
+func layoutConfig(src []byte, layout map[string][]*pollerBin, collectors map[string][]*yaml.Node) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(src, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("empty config")
+	}
+	pollers := mappingValue(root.Content[0], "Pollers")
+	if pollers == nil {
+		return nil, fmt.Errorf("config has no Pollers")
+	}
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(pollers.Content); i += 2 {
+		key, value := pollers.Content[i], pollers.Content[i+1]
+		bins, ok := layout[key.Value]
+		if !ok {
+			content = append(content, key, value)
+			continue
+		}
+		confPath := opts.confPath
+		if p := mappingValue(value, "conf_path"); p != nil {
+			confPath = p.Value
+		}
+		for j, b := range bins {
+			// the first poller keeps the comments of the original
+			k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: b.Name}
+			if j == 0 {
+				k = copyNode(key)
+				k.Value = b.Name
+			}
+			v := copyNode(value)
+			if v.Kind != yaml.MappingNode {
+				// a poller without parameters
+				v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			if j > 0 {
+				deleteMappingKey(v, "prom_port")
+			}
+			setMappingValue(v, "conf_path", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str",
+				Value: opts.templatesPath + string(filepath.ListSeparator) + cmp.Or(confPath, conf.DefaultConfPath)})
+			setMappingValue(v, "collectors", collectors[key.Value][j])
+			content = append(content, k, v)
+		}
+	}
+	pollers.Content = content
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), encoder.Close()
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(n *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = value
+			return
+		}
+	}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+func deleteMappingKey(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = slices.Delete(n.Content, i, i+2)
+			return
+		}
+	}
+}
+
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, 0, len(n.Content))
+	for _, child := range n.Content {
+		c.Content = append(c.Content, copyNode(child))
+	}
+	return &c
+}
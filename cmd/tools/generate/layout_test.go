@@ -0,0 +1,172 @@
+package generate
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseObjectCosts(t *testing.T) {
+	instances := `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"poller":"u2","collector":"Rest","object":"Volume"},"value":[1700000000,"24000"]},
+		{"metric":{"poller":"u2","collector":"RestPerf","object":"Workload"},"value":[1700000000,"3000"]}]}}`
+	apiTimes := `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"poller":"u2","collector":"RestPerf","object":"Workload","interval":"180.0000"},"value":[1700000000,"120000000"]},
+		{"metric":{"poller":"u2","collector":"Rest","object":"Volume","interval":"180.0000"},"value":[1700000000,"1500000.5"]},
+		{"metric":{"poller":"u2","collector":"Rest","object":"Node"},"value":[1700000000,"1"]}]}}`
+
+	got, err := parseObjectCosts([]byte(instances), []byte(apiTimes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []objectCost{
+		{Poller: "u2", Collector: "Rest", Object: "Volume", Instances: 24000, APITime: 1500 * time.Millisecond, Interval: 3 * time.Minute},
+		{Poller: "u2", Collector: "RestPerf", Object: "Workload", Instances: 3000, APITime: 2 * time.Minute, Interval: 3 * time.Minute},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseObjectCosts() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := parseObjectCosts([]byte(`{"status":"error","error":"parse error"}`), []byte(apiTimes)); err == nil {
+		t.Error("parseObjectCosts() want error for a failed query")
+	}
+}
+
+func Test_proposeInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiTime  time.Duration
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "within budget", apiTime: 10 * time.Second, interval: time.Minute, want: time.Minute},
+		{name: "longer", apiTime: 50 * time.Second, interval: time.Minute, want: 2 * time.Minute},
+		{name: "not on ladder", apiTime: 200 * time.Second, interval: 3 * time.Minute, want: 10 * time.Minute},
+		{name: "longest", apiTime: 2 * time.Hour, interval: time.Minute, want: time.Hour},
+		{name: "longer than longest", apiTime: 2 * time.Hour, interval: 2 * time.Hour, want: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proposeInterval(objectCost{APITime: tt.apiTime, Interval: tt.interval}, maxObjectLoad)
+			if got != tt.want {
+				t.Errorf("proposeInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_packObjects(t *testing.T) {
+	object := func(name string, instances int64, apiSeconds int) objectCost {
+		return objectCost{Object: name, Instances: instances, APITime: time.Duration(apiSeconds) * time.Second, Interval: time.Minute}
+	}
+	tests := []struct {
+		name    string
+		objects []objectCost
+		want    map[string][]string
+	}{
+		{
+			name:    "fits",
+			objects: []objectCost{object("Volume", 1000, 6), object("Node", 10, 1)},
+			want:    map[string][]string{"u2": {"Volume", "Node"}},
+		},
+		{
+			name:    "instances",
+			objects: []objectCost{object("Volume", 30_000, 6), object("Lun", 25_000, 6), object("Qtree", 20_000, 1)},
+			want:    map[string][]string{"u2": {"Volume", "Qtree"}, "u2-2": {"Lun"}},
+		},
+		{
+			name:    "api load",
+			objects: []objectCost{object("Volume", 100, 36), object("Lun", 100, 30), object("Node", 10, 12)},
+			want:    map[string][]string{"u2": {"Volume", "Node"}, "u2-2": {"Lun"}},
+		},
+		{
+			name:    "larger than a poller",
+			objects: []objectCost{object("Volume", 80_000, 1), object("Node", 10, 1)},
+			want:    map[string][]string{"u2": {"Volume"}, "u2-2": {"Node"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][]string)
+			for _, b := range packObjects("u2", tt.objects, 50_000, 1) {
+				for _, o := range b.Objects {
+					got[b.Name] = append(got[b.Name], o.Object)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("packObjects() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_layoutConfig(t *testing.T) {
+	src := `
+Exporters:
+  prom:
+    exporter: Prometheus
+    port_range: 13000-13100
+Pollers:
+  # the cluster
+  u2:
+    addr: 10.0.0.1
+    prom_port: 13000
+    collectors:
+      - Rest
+      - RestPerf
+  sar:
+    addr: 10.0.0.2
+`
+	collectors := func(s string) *yaml.Node {
+		var n yaml.Node
+		if err := yaml.Unmarshal([]byte(s), &n); err != nil {
+			t.Fatal(err)
+		}
+		return n.Content[0]
+	}
+	layout := map[string][]*pollerBin{"u2": {{Name: "u2"}, {Name: "u2-2"}}}
+	nodes := map[string][]*yaml.Node{"u2": {
+		collectors("[Rest: [layout-u2-1m.yaml]]"),
+		collectors("[RestPerf: [layout-u2-2-3m.yaml]]"),
+	}}
+
+	opts.templatesPath = "layout"
+	opts.confPath = ""
+	out, err := layoutConfig([]byte(src), layout, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Pollers map[string]struct {
+			Addr       string                `yaml:"addr"`
+			PromPort   int                   `yaml:"prom_port"`
+			ConfPath   string                `yaml:"conf_path"`
+			Collectors []map[string][]string `yaml:"collectors"`
+		} `yaml:"Pollers"`
+	}
+	if err := yaml.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Pollers) != 3 {
+		t.Fatalf("pollers got=%d, want=3\n%s", len(got.Pollers), out)
+	}
+	u2, u22 := got.Pollers["u2"], got.Pollers["u2-2"]
+	if u2.Addr != "10.0.0.1" || u22.Addr != "10.0.0.1" {
+		t.Errorf("addr got=%s,%s, want=10.0.0.1", u2.Addr, u22.Addr)
+	}
+	if u2.PromPort != 13000 || u22.PromPort != 0 {
+		t.Errorf("prom_port got=%d,%d, want=13000,0", u2.PromPort, u22.PromPort)
+	}
+	if u2.ConfPath != "layout:conf" {
+		t.Errorf("conf_path got=%s, want=layout:conf", u2.ConfPath)
+	}
+	if diff := cmp.Diff([]map[string][]string{{"RestPerf": {"layout-u2-2-3m.yaml"}}}, u22.Collectors); diff != "" {
+		t.Errorf("collectors mismatch (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(string(out), "# the cluster") {
+		t.Errorf("comments of the config are lost\n%s", out)
+	}
+}
@@ -0,0 +1,275 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type templateOptions struct {
+	collector string
+	query     string
+	object    string
+	output    string
+}
+
+var templateOpts = &templateOptions{}
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "generate a starter collector template by sampling a record from a cluster",
+	Run:   doTemplate,
+}
+
+// instanceKeyParents are the nested objects whose name is used as an instance key when they appear in a record
+var instanceKeyParents = []string{"svm", "volume", "node", "aggregate", "lun", "qtree", "namespace", "consistency_group"}
+
+// skipFields are never proposed as counters
+var skipFields = []string{"_links"}
+
+// templateCounter is a counter proposed for a generated template
+type templateCounter struct {
+	path    string
+	display string
+	isKey   bool
+	isLabel bool
+}
+
+func (c templateCounter) prefix() string {
+	switch {
+	case c.isKey:
+		return "^^"
+	case c.isLabel:
+		return "^"
+	}
+	return ""
+}
+
+func doTemplate(cmd *cobra.Command, _ []string) {
+	addRootOptions(cmd)
+	if !strings.EqualFold(templateOpts.collector, "rest") {
+		logErrAndExit(fmt.Errorf("collector=%s is not supported, only Rest templates can be generated", templateOpts.collector))
+	}
+	query := strings.TrimPrefix(templateOpts.query, "/")
+	object := templateOpts.object
+	if object == "" {
+		object = objectFromQuery(query)
+	}
+
+	record, err := fetchSampleRecord(query)
+	if err != nil {
+		logErrAndExit(err)
+	}
+
+	content := buildTemplate(query, object, record)
+
+	if templateOpts.output == "" {
+		fmt.Print(content)
+		return
+	}
+	if err := os.WriteFile(templateOpts.output, []byte(content), 0600); err != nil {
+		logErrAndExit(err)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Wrote template to %s\n", templateOpts.output)
+}
+
+func fetchSampleRecord(query string) (gjson.Result, error) {
+	var (
+		poller     *conf.Poller
+		err        error
+		restClient *rest.Client
+	)
+
+	_, err = conf.LoadHarvestConfig(opts.configPath)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	if poller, _, err = rest.GetPollerAndAddr(opts.Poller); err != nil {
+		return gjson.Result{}, err
+	}
+
+	timeout, _ := time.ParseDuration(rest.DefaultTimeout)
+	if restClient, err = rest.New(poller, timeout, auth.NewCredentials(poller, logging.Get())); err != nil {
+		return gjson.Result{}, fmt.Errorf("error creating new client %w", err)
+	}
+	if err = restClient.Init(2); err != nil {
+		return gjson.Result{}, fmt.Errorf("error init rest client %w", err)
+	}
+
+	maxRecords := 1
+	href := rest.NewHrefBuilder().
+		APIPath(query).
+		Fields([]string{"*"}).
+		MaxRecords(&maxRecords).
+		Build()
+
+	records, err := rest.Fetch(restClient, href)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	if len(records) == 0 {
+		return gjson.Result{}, errors.New("no records returned for " + query + ", unable to infer counters")
+	}
+	return records[0], nil
+}
+
+// objectFromQuery derives an object name from the last segment of a REST query,
+// e.g. api/storage/qtrees => qtree
+func objectFromQuery(query string) string {
+	parts := strings.Split(strings.Trim(query, "/"), "/")
+	object := parts[len(parts)-1]
+	if strings.HasSuffix(object, "ies") {
+		object = strings.TrimSuffix(object, "ies") + "y"
+	} else {
+		object = strings.TrimSuffix(object, "s")
+	}
+	return strings.ReplaceAll(object, "-", "_")
+}
+
+// templateName converts an object into the CamelCased name used by the template, e.g. qos_policy => QosPolicy
+func templateName(object string) string {
+	var name strings.Builder
+	for _, part := range strings.Split(object, "_") {
+		if part == "" {
+			continue
+		}
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return name.String()
+}
+
+// proposeCounters flattens a record into counters. Strings and booleans become labels, numbers become metrics.
+// Arrays and the fields listed in skipFields are ignored.
+func proposeCounters(object string, record gjson.Result) []templateCounter {
+	var counters []templateCounter
+	var walk func(prefix string, value gjson.Result)
+	walk = func(prefix string, value gjson.Result) {
+		value.ForEach(func(key, v gjson.Result) bool {
+			name := key.String()
+			if slices.Contains(skipFields, name) {
+				return true
+			}
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			switch {
+			case v.IsObject():
+				walk(path, v)
+			case v.IsArray():
+				// arrays need a plugin or an explicit template entry
+			case v.Type == gjson.Number:
+				counters = append(counters, templateCounter{path: path, display: displayName(object, path)})
+			default:
+				counters = append(counters, templateCounter{path: path, display: displayName(object, path), isLabel: true})
+			}
+			return true
+		})
+	}
+	walk("", record)
+
+	hasKey := false
+	for i, c := range counters {
+		if !c.isLabel {
+			continue
+		}
+		if c.path == "name" {
+			counters[i].isKey = true
+			hasKey = true
+			continue
+		}
+		if parent, ok := strings.CutSuffix(c.path, ".name"); ok && slices.Contains(instanceKeyParents, parent) {
+			counters[i].isKey = true
+			hasKey = true
+		}
+	}
+	if !hasKey {
+		for i, c := range counters {
+			if c.path == "uuid" {
+				counters[i].isKey = true
+			}
+		}
+	}
+
+	slices.SortStableFunc(counters, func(a, b templateCounter) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return counters
+}
+
+// displayName returns the name Harvest exports for path, e.g. svm.name => svm and name => the object
+func displayName(object string, path string) string {
+	if path == "name" {
+		return object
+	}
+	if parent, ok := strings.CutSuffix(path, ".name"); ok && !strings.Contains(parent, ".") {
+		return parent
+	}
+	return strings.ReplaceAll(path, ".", "_")
+}
+
+// buildTemplate returns the YAML of a starter Rest template for object
+func buildTemplate(query string, object string, record gjson.Result) string {
+	counters := proposeCounters(object, record)
+
+	width := 0
+	for _, c := range counters {
+		width = max(width, len(c.prefix())+len(c.path))
+	}
+
+	var b strings.Builder
+	b.WriteString("name:                       " + templateName(object) + "\n")
+	b.WriteString("query:                      " + query + "\n")
+	b.WriteString("object:                     " + object + "\n")
+	b.WriteString("\ncounters:\n")
+
+	var keys, labels []string
+	for _, c := range counters {
+		field := c.prefix() + c.path
+		b.WriteString(fmt.Sprintf("  - %-*s => %s\n", width, field, c.display))
+		switch {
+		case c.isKey:
+			keys = append(keys, c.display)
+		case c.isLabel:
+			labels = append(labels, c.display)
+		}
+	}
+
+	b.WriteString("\nexport_options:\n")
+	if len(keys) > 0 {
+		b.WriteString("  instance_keys:\n")
+		for _, k := range keys {
+			b.WriteString("    - " + k + "\n")
+		}
+	}
+	if len(labels) > 0 {
+		b.WriteString("  instance_labels:\n")
+		for _, l := range labels {
+			b.WriteString("    - " + l + "\n")
+		}
+	}
+	return b.String()
+}
+
+func init() {
+	Cmd.AddCommand(templateCmd)
+
+	flags := templateCmd.PersistentFlags()
+	flags.StringVarP(&opts.Poller, "poller", "p", "", "name of poller to sample the record from")
+	flags.StringVar(&templateOpts.collector, "collector", "rest", "collector the template is generated for. Only rest is supported")
+	flags.StringVar(&templateOpts.query, "query", "", "REST API to sample, e.g. api/storage/qtrees")
+	flags.StringVar(&templateOpts.object, "object", "", "object name of the template. Defaults to the last segment of the query")
+	flags.StringVarP(&templateOpts.output, "output", "o", "", "output file path. Defaults to stdout")
+	_ = templateCmd.MarkPersistentFlagRequired("poller")
+	_ = templateCmd.MarkPersistentFlagRequired("query")
+}
@@ -0,0 +1,63 @@
+package generate
+
+import (
+	"github.com/tidwall/gjson"
+	"strings"
+	"testing"
+)
+
+func Test_objectFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "api/storage/qtrees", want: "qtree"},
+		{query: "api/storage/qos/policies", want: "policy"},
+		{query: "api/cluster", want: "cluster"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := objectFromQuery(tt.query); got != tt.want {
+				t.Errorf("objectFromQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildTemplate(t *testing.T) {
+	record := gjson.Parse(`{
+		"svm": {"name": "vs1", "uuid": "abc", "_links": {"self": {"href": "/api/svm/svms/abc"}}},
+		"volume": {"name": "vol1"},
+		"id": 1,
+		"name": "q1",
+		"security_style": "unix",
+		"statistics": {"iops_raw": 42},
+		"nfs_enabled": true,
+		"qos_policy": ["a", "b"]
+	}`)
+
+	got := buildTemplate("api/storage/qtrees", "qtree", record)
+
+	want := []string{
+		"name:                       Qtree",
+		"query:                      api/storage/qtrees",
+		"  - id                  => id",
+		"  - ^^name              => qtree",
+		"  - ^nfs_enabled        => nfs_enabled",
+		"  - statistics.iops_raw => statistics_iops_raw",
+		"  - ^^svm.name          => svm",
+		"  - ^svm.uuid           => svm_uuid",
+		"  - ^^volume.name       => volume",
+		"  instance_keys:\n    - qtree\n    - svm\n    - volume\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("buildTemplate() missing %q\n%s", w, got)
+		}
+	}
+	for _, unwanted := range []string{"_links", "qos_policy"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("buildTemplate() should not contain %q\n%s", unwanted, got)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package grafana
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+//go:embed units.yaml
+var unitsYaml []byte
+
+var generateOpts struct {
+	templates []string
+	outputDir string
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "generate baseline dashboards from collector templates",
+	Run:   doGenerate,
+	Example: `
+# Generate a dashboard for each object of the templates in conf/rest/9.12.0 and write them to ~/generated
+grafana generate --template conf/rest/9.12.0 --output-dir ~/generated
+
+# Generate a dashboard for a custom template. Templates of the same object are combined into one dashboard
+grafana generate --template conf/rest/9.12.0/custom_volume.yaml --template conf/restperf/9.12.0/volume.yaml -o ~/generated`,
+}
+
+func init() {
+	Cmd.AddCommand(generateCmd)
+	generateCmd.PersistentFlags().StringSliceVarP(&generateOpts.templates, "template", "t", nil,
+		"Template file or directory of templates (searched recursively) to generate dashboards from")
+	generateCmd.PersistentFlags().StringVarP(&generateOpts.outputDir, "output-dir", "o", "",
+		"Write generated dashboards to the local directory. The directory must not exist")
+	_ = generateCmd.MarkPersistentFlagRequired("template")
+	_ = generateCmd.MarkPersistentFlagRequired("output-dir")
+}
+
+// dashObject is what a dashboard is generated from: the metrics and instance keys of
+// all the templates of an object
+type dashObject struct {
+	name    string
+	object  string
+	keys    []string
+	metrics []string
+	labels  bool // true when the object exports a _labels metric
+}
+
+func doGenerate(_ *cobra.Command, _ []string) {
+	exitIfExist(generateOpts.outputDir, "output-dir")
+
+	objects, err := readTemplates(generateOpts.templates)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(objects) == 0 {
+		fmt.Println("error: no templates with metrics found")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(generateOpts.outputDir, 0750); err != nil {
+		fmt.Printf("error: failed to create output-dir %s: %v\n", generateOpts.outputDir, err)
+		os.Exit(1)
+	}
+
+	units := generateUnits()
+	for _, o := range objects {
+		data, err := generateDashboard(o, units)
+		if err != nil {
+			fmt.Printf("error: failed to generate dashboard for %s: %v\n", o.object, err)
+			os.Exit(1)
+		}
+		fp := filepath.Join(generateOpts.outputDir, o.object+".json")
+		if err := os.WriteFile(fp, data, GPerm); err != nil {
+			fmt.Printf("error: failed to write %s: %v\n", fp, err)
+			os.Exit(1)
+		}
+		fmt.Printf("OK - generated %s with %d panels\n", fp, len(o.metrics))
+	}
+}
+
+// readTemplates reads the templates in paths and groups them by object, in the order they are read
+func readTemplates(paths []string) ([]*dashObject, error) {
+	var (
+		objects []*dashObject
+		byName  = make(map[string]*dashObject)
+	)
+
+	add := func(path string) error {
+		t, err := tree.ImportYaml(path)
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+		object := t.GetChildContentS("object")
+		counters := t.GetChildS("counters")
+		if object == "" || counters == nil {
+			// collector default.yaml or not a template
+			return nil
+		}
+		o, ok := byName[object]
+		if !ok {
+			o = &dashObject{name: t.GetChildContentS("name"), object: object}
+			byName[object] = o
+			objects = append(objects, o)
+		}
+		o.addCounters(counters)
+		if endpoints := t.GetChildS("endpoints"); endpoints != nil {
+			for _, endpoint := range endpoints.GetChildren() {
+				o.addCounters(endpoint.GetChildS("counters"))
+			}
+		}
+		if exportOptions := t.GetChildS("export_options"); exportOptions != nil {
+			if keys := exportOptions.GetChildS("instance_keys"); keys != nil {
+				for _, k := range keys.GetAllChildContentS() {
+					if !slices.Contains(o.keys, k) {
+						o.keys = append(o.keys, k)
+					}
+				}
+			}
+			if exportOptions.GetChildS("instance_labels") != nil {
+				o.labels = true
+			}
+		}
+		return nil
+	}
+
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return nil
+			}
+			return add(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// objects without metrics have nothing to plot
+	objects = slices.DeleteFunc(objects, func(o *dashObject) bool { return len(o.metrics) == 0 })
+	return objects, nil
+}
+
+func (o *dashObject) addCounters(counters *node.Node) {
+	if counters == nil {
+		return
+	}
+	for _, c := range counters.GetChildren() {
+		if c.GetNameS() == "filter" || c.GetNameS() == "hidden_fields" {
+			continue
+		}
+		if len(c.GetChildren()) > 0 {
+			// ZAPI counters are nested by their parent elements
+			o.addCounters(c)
+			continue
+		}
+		name, display, kind, _ := util.ParseMetric(c.GetContentS())
+		if name == "" || kind != "float" {
+			continue
+		}
+		metric := o.object + "_" + display
+		if !slices.Contains(o.metrics, metric) {
+			o.metrics = append(o.metrics, metric)
+		}
+	}
+}
+
+// generateUnits returns the Grafana unit of each metric listed in units.yaml
+func generateUnits() map[string]string {
+	var metrics []Metric
+	units := make(map[string]string)
+	if err := yaml.Unmarshal(unitsYaml, &metrics); err != nil {
+		return units
+	}
+	for _, m := range metrics {
+		units[m.Metric] = m.GrafanaJSON
+	}
+	return units
+}
+
+// unitOf returns the Grafana unit of metric. Metrics not listed in units are guessed by their name
+func unitOf(metric string, units map[string]string) string {
+	if u, ok := units[metric]; ok {
+		return u
+	}
+	switch {
+	case strings.HasSuffix(metric, "_latency"):
+		return "µs"
+	case strings.HasSuffix(metric, "_percent"), strings.HasSuffix(metric, "_util"),
+		strings.HasSuffix(metric, "_utilization"), strings.HasSuffix(metric, "_pct"):
+		return "percent"
+	case strings.HasSuffix(metric, "_data"), strings.HasSuffix(metric, "_throughput"):
+		return "Bps"
+	case strings.HasSuffix(metric, "_ops"):
+		return "iops"
+	case strings.Contains(metric, "_size"), strings.Contains(metric, "space"), strings.Contains(metric, "bytes"):
+		return "bytes"
+	case strings.HasSuffix(metric, "_time"), strings.HasSuffix(metric, "_duration"), strings.HasSuffix(metric, "_lag"):
+		return "s"
+	}
+	return "locale"
+}
+
+func generateDashboard(o *dashObject, units map[string]string) ([]byte, error) {
+	caser := cases.Title(language.Und)
+	title := o.name
+	if title == "" {
+		title = caser.String(o.object)
+	}
+
+	// variables filter each other from left to right
+	source := o.object + "_labels"
+	if !o.labels {
+		source = o.metrics[0]
+	}
+	variables := []map[string]any{
+		{
+			"current": map[string]any{"selected": false, "text": "Prometheus", "value": "Prometheus"},
+			"hide":    2, "includeAll": false, "label": "Data Source", "multi": false,
+			"name": "DS_PROMETHEUS", "options": []any{}, "query": "prometheus", "refresh": 2, "type": "datasource",
+		},
+	}
+	var (
+		filters []string
+		legend  []string
+	)
+	names := append([]string{"datacenter", "cluster"}, o.keys...)
+	for i, key := range names {
+		if i > 1 && (key == "datacenter" || key == "cluster") {
+			continue
+		}
+		name := variableName(key)
+		query := "label_values(" + source + selector(filters) + ", " + key + ")"
+		variables = append(variables, map[string]any{
+			"allValue":   ".*",
+			"current":    map[string]any{},
+			"datasource": "${DS_PROMETHEUS}",
+			"definition": query,
+			"hide":       0,
+			"includeAll": key != "datacenter",
+			"multi":      true,
+			"name":       name,
+			"options":    []any{},
+			"query":      map[string]any{"query": query, "refId": "StandardVariableQuery"},
+			"refresh":    2,
+			"sort":       1,
+			"type":       "query",
+		})
+		filters = append(filters, key+`=~"$`+name+`"`)
+		if i > 1 {
+			legend = append(legend, "{{"+key+"}}")
+		}
+	}
+	if len(legend) == 0 {
+		legend = []string{"{{cluster}}"}
+	}
+	variables = append(variables, topResourcesVariable())
+
+	panels := []map[string]any{
+		{
+			"collapsed": false, "datasource": "${DS_PROMETHEUS}", "gridPos": map[string]int{"h": 1, "w": 24, "x": 0, "y": 0},
+			"id": 1, "panels": []any{}, "title": "Metrics", "type": "row",
+		},
+	}
+	for i, metric := range o.metrics {
+		expr := metric + selector(filters) + "\n  and \ntopk($TopResources, avg_over_time(" + metric + selector(filters) + "[3h] @ end()))"
+		panels = append(panels, map[string]any{
+			"datasource": "${DS_PROMETHEUS}",
+			"fieldConfig": map[string]any{
+				"defaults": map[string]any{
+					"color":  map[string]string{"mode": "palette-classic"},
+					"custom": map[string]any{"drawStyle": "line", "fillOpacity": 10, "lineWidth": 2, "showPoints": "never", "spanNulls": true},
+					"unit":   unitOf(metric, units),
+				},
+				"overrides": []any{},
+			},
+			"gridPos": map[string]int{"h": 10, "w": 12, "x": 12 * (i % 2), "y": 1 + 10*(i/2)},
+			"id":      i + 2,
+			"options": map[string]any{
+				"legend":  map[string]any{"calcs": []string{"mean", "lastNotNull", "max"}, "displayMode": "table", "placement": "bottom"},
+				"tooltip": map[string]string{"mode": "single"},
+			},
+			"targets": []map[string]any{
+				{"expr": expr, "interval": "", "legendFormat": strings.Join(legend, " - "), "refId": "A"},
+			},
+			"title": "Top $TopResources " + title + " by " + strings.TrimPrefix(metric, o.object+"_"),
+			"type":  "timeseries",
+		})
+	}
+
+	dashboard := map[string]any{
+		"__inputs": []map[string]string{
+			{"label": "Prometheus", "name": "DS_PROMETHEUS", "pluginId": "prometheus", "pluginName": "Prometheus", "type": "datasource"},
+		},
+		"description":   "Generated from the " + title + " templates",
+		"editable":      true,
+		"graphTooltip":  1,
+		"id":            nil,
+		"panels":        panels,
+		"schemaVersion": 30,
+		"tags":          []string{"harvest", "ontap", "cdot", "generated"},
+		"templating":    map[string]any{"list": variables},
+		"time":          map[string]string{"from": "now-3h", "to": "now"},
+		"title":         "ONTAP: " + title + " (generated)",
+		"uid":           "",
+		"version":       1,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+func selector(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(filters, ",") + "}"
+}
+
+// variableName returns the name of the dashboard variable of a label, e.g. svm => SVM, home_node => HomeNode
+func variableName(label string) string {
+	switch label {
+	case "svm", "lun", "lif":
+		return strings.ToUpper(label)
+	}
+	caser := cases.Title(language.Und)
+	return strings.ReplaceAll(caser.String(strings.ReplaceAll(label, "_", " ")), " ", "")
+}
+
+func topResourcesVariable() map[string]any {
+	values := []string{"1", "2", "3", "4", "5", "6", "8", "10", "15", "25", "50", "100", "250", "500"}
+	options := make([]map[string]any, 0, len(values))
+	for _, v := range values {
+		options = append(options, map[string]any{"selected": v == "5", "text": v, "value": v})
+	}
+	return map[string]any{
+		"current":    map[string]any{"selected": true, "text": "5", "value": "5"},
+		"hide":       0,
+		"includeAll": false,
+		"multi":      false,
+		"name":       "TopResources",
+		"options":    options,
+		"query":      strings.Join(values, ","),
+		"type":       "custom",
+	}
+}
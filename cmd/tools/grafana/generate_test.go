@@ -0,0 +1,118 @@
+package grafana
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestGenerateDashboard(t *testing.T) {
+	dir := t.TempDir()
+	custom := `
+name:   CustomQtree
+query:  api/storage/qtrees
+object: qtree
+
+counters:
+  - ^^name         => qtree
+  - ^^svm.name     => svm
+  - ^security_style => security_style
+  - space.hard_limit => disk_limit
+  - files.used     => files_used
+  - filter:
+      - name=!""
+
+export_options:
+  instance_keys:
+    - qtree
+    - svm
+  instance_labels:
+    - security_style
+`
+	perf := `
+name:   QtreePerf
+query:  api/cluster/counter/tables/qtree
+object: qtree
+
+counters:
+  - ^^parent_vol => volume
+  - ^^svm.name   => svm
+  - nfs_ops
+  - internal_ops
+
+export_options:
+  instance_keys:
+    - svm
+    - volume
+`
+	for name, content := range map[string]string{"custom_qtree.yaml": custom, "perf/qtree.yaml": perf} {
+		fp := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fp, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := readTemplates([]string{dir})
+	if err != nil {
+		t.Fatalf("readTemplates() err=%v", err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("readTemplates() got=%d objects, want=1", len(objects))
+	}
+
+	data, err := generateDashboard(objects[0], map[string]string{"qtree_disk_limit": "kbytes"})
+	if err != nil {
+		t.Fatalf("generateDashboard() err=%v", err)
+	}
+
+	var variables []string
+	for _, v := range gjson.GetBytes(data, "templating.list.#.name").Array() {
+		variables = append(variables, v.String())
+	}
+	wantVariables := []string{"DS_PROMETHEUS", "Datacenter", "Cluster", "Qtree", "SVM", "Volume", "TopResources"}
+	if len(variables) != len(wantVariables) {
+		t.Fatalf("variables got=%v, want=%v", variables, wantVariables)
+	}
+	for i := range variables {
+		if variables[i] != wantVariables[i] {
+			t.Errorf("variables got=%v, want=%v", variables, wantVariables)
+			break
+		}
+	}
+
+	wantCluster := `label_values(qtree_labels{datacenter=~"$Datacenter"}, cluster)`
+	if got := gjson.GetBytes(data, "templating.list.2.definition").String(); got != wantCluster {
+		t.Errorf("cluster variable got=%s, want=%s", got, wantCluster)
+	}
+
+	panels := gjson.GetBytes(data, `panels.#(type=="timeseries")#`).Array()
+	wantUnits := map[string]string{
+		"qtree_disk_limit":   "kbytes",
+		"qtree_files_used":   "locale",
+		"qtree_nfs_ops":      "iops",
+		"qtree_internal_ops": "iops",
+	}
+	if len(panels) != len(wantUnits) {
+		t.Fatalf("panels got=%d, want=%d", len(panels), len(wantUnits))
+	}
+	for _, p := range panels {
+		expr := p.Get("targets.0.expr").String()
+		metric := metricRe.FindStringSubmatch(expr)[1]
+		want, ok := wantUnits[metric]
+		if !ok {
+			t.Errorf("unexpected panel for metric %s", metric)
+			continue
+		}
+		if got := p.Get("fieldConfig.defaults.unit").String(); got != want {
+			t.Errorf("%s unit got=%s, want=%s", metric, got, want)
+		}
+		if got := p.Get("targets.0.legendFormat").String(); got != "{{qtree}} - {{svm}} - {{volume}}" {
+			t.Errorf("%s legend got=%s", metric, got)
+		}
+	}
+}
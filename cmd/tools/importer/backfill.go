@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultBackfillHours = 2
+
+type backfillOptions struct {
+	hours   int
+	objects []string
+}
+
+var backfillOpts = &backfillOptions{}
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill [flags] POLLER",
+	Short: "Pull the archived performance samples of a cluster into Prometheus or InfluxDB",
+	Long: `Pull the performance samples that ONTAP archived in the last hours, and send them to Prometheus or InfluxDB
+with the time they were sampled, to fill the gaps of a poller that was down.
+The cluster is read from the poller's section of harvest.yml.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doBackfill,
+}
+
+func init() {
+	backfillCmd.Flags().IntVar(&backfillOpts.hours, "hours", defaultBackfillHours, "Number of hours to backfill, up to a year")
+	backfillCmd.Flags().StringSliceVar(&backfillOpts.objects, "object", []string{"volume", "lun"}, "Objects to backfill")
+}
+
+// archive is an ONTAP object whose performance samples are archived, and how its samples map to the metrics
+// and instance keys of its RestPerf template
+type archive struct {
+	api     string            // collection of the instances, the samples of an instance are at api/<uuid>/metrics
+	fields  []string          // fields of the instances
+	filter  []string          // filter of the instances
+	metrics map[string]string // Harvest metric, by the field of the sample
+	labels  func(b *backfill, instance gjson.Result) map[string]string
+}
+
+var archives = map[string]archive{
+	"volume": {
+		api:    "api/storage/volumes",
+		fields: []string{"name", "svm.name", "style", "aggregates.name"},
+		// the samples of a FlexGroup are not the sum of its constituents, which the Volume plugin exports
+		filter: []string{"style=flexvol"},
+		metrics: map[string]string{
+			"iops.read":        "read_ops",
+			"iops.write":       "write_ops",
+			"iops.other":       "other_ops",
+			"iops.total":       "total_ops",
+			"latency.read":     "read_latency",
+			"latency.write":    "write_latency",
+			"latency.other":    "other_latency",
+			"latency.total":    "avg_latency",
+			"throughput.read":  "read_data",
+			"throughput.write": "write_data",
+			"throughput.total": "total_data",
+		},
+		labels: func(b *backfill, instance gjson.Result) map[string]string {
+			aggr := instance.Get("aggregates.0.name").String()
+			return map[string]string{
+				"aggr":   aggr,
+				"node":   b.nodes[aggr],
+				"style":  instance.Get("style").String(),
+				"svm":    instance.Get("svm.name").String(),
+				"volume": instance.Get("name").String(),
+			}
+		},
+	},
+	"lun": {
+		api:    "api/storage/luns",
+		fields: []string{"name", "svm.name", "location.volume.name"},
+		metrics: map[string]string{
+			"iops.read":        "read_ops",
+			"iops.write":       "write_ops",
+			"latency.read":     "avg_read_latency",
+			"latency.write":    "avg_write_latency",
+			"throughput.read":  "read_data",
+			"throughput.write": "write_data",
+		},
+		labels: func(_ *backfill, instance gjson.Result) map[string]string {
+			// the name of a LUN is its path, e.g. /vol/vol1/lun1, the lun label is the last element of the path
+			name := instance.Get("name").String()
+			return map[string]string{
+				"lun":    name[strings.LastIndex(name, "/")+1:],
+				"svm":    instance.Get("svm.name").String(),
+				"volume": instance.Get("location.volume.name").String(),
+			}
+		},
+	},
+}
+
+// intervals are the intervals of ONTAP's archived samples, from the finest to the coarsest. The samples of 1h are
+// 15 seconds apart, of 1d 5 minutes, of 1w 30 minutes, of 1m 2 hours, and of 1y a day
+var intervals = []struct {
+	name   string
+	covers time.Duration
+}{
+	{name: "1h", covers: time.Hour},
+	{name: "1d", covers: 24 * time.Hour},
+	{name: "1w", covers: 7 * 24 * time.Hour},
+	{name: "1m", covers: 30 * 24 * time.Hour},
+	{name: "1y", covers: 365 * 24 * time.Hour},
+}
+
+// intervalOf returns the finest interval that covers the last hours
+func intervalOf(hours int) (string, error) {
+	window := time.Duration(hours) * time.Hour
+	for _, interval := range intervals {
+		if window <= interval.covers {
+			return interval.name, nil
+		}
+	}
+	return "", errs.New(errs.ErrInvalidParam, "hours must be at most "+strconv.Itoa(365*24))
+}
+
+func doBackfill(cmd *cobra.Command, args []string) {
+	config := cmd.Root().PersistentFlags().Lookup("config")
+	if err := runBackfill(config.Value.String(), args[0], os.Stdout); err != nil {
+		fmt.Printf("backfill failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runBackfill(configPath string, pollerName string, out io.Writer) error {
+	if opts.batchSize <= 0 {
+		return errs.New(errs.ErrInvalidParam, "batch-size must be positive")
+	}
+	if backfillOpts.hours <= 0 {
+		return errs.New(errs.ErrInvalidParam, "hours must be positive")
+	}
+	for _, object := range backfillOpts.objects {
+		if _, ok := archives[object]; !ok {
+			return errs.New(errs.ErrInvalidParam, "object "+object+" has no archived samples, expected one of "+
+				strings.Join(sortedKeys(archives), ", "))
+		}
+	}
+	if _, err := conf.LoadHarvestConfig(configPath); err != nil {
+		return err
+	}
+	poller, _, err := rest.GetPollerAndAddr(pollerName)
+	if err != nil {
+		return err
+	}
+	t, err := newTarget()
+	if err != nil {
+		return err
+	}
+	client, err := rest.New(poller, opts.timeout, auth.NewCredentials(poller, logging.Get()))
+	if err != nil {
+		return fmt.Errorf("poller=%s %w", poller.Name, err)
+	}
+	if err := client.Init(1); err != nil {
+		return fmt.Errorf("poller=%s %w", poller.Name, err)
+	}
+
+	b, err := newBackfill(client, poller, t, time.Now(), backfillOpts.hours)
+	if err != nil {
+		return err
+	}
+	b.batchSize = opts.batchSize
+	b.out = out
+	for _, object := range backfillOpts.objects {
+		if err := b.object(object); err != nil {
+			return fmt.Errorf("%s: %w", object, err)
+		}
+	}
+	return nil
+}
+
+type backfill struct {
+	client    *rest.Client
+	target    target
+	batchSize int
+	out       io.Writer
+	labels    map[string]string // global labels
+	interval  string
+	from      time.Time         // samples before from are not sent
+	nodes     map[string]string // node of each aggregate, by aggregate name
+	samples   int64
+}
+
+func newBackfill(client *rest.Client, poller *conf.Poller, t target, now time.Time, hours int) (*backfill, error) {
+	interval, err := intervalOf(hours)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{"cluster": client.Cluster().Name}
+	if poller.Datacenter != "" {
+		labels["datacenter"] = poller.Datacenter
+	}
+	return &backfill{
+		client:    client,
+		target:    t,
+		batchSize: defaultBatchSize,
+		out:       io.Discard,
+		labels:    labels,
+		interval:  interval,
+		from:      now.Add(-time.Duration(hours) * time.Hour),
+	}, nil
+}
+
+// object sends the archived samples of the instances of object
+func (b *backfill) object(object string) error {
+	a := archives[object]
+	if object == "volume" && b.nodes == nil {
+		if err := b.loadNodes(); err != nil {
+			return err
+		}
+	}
+	href := rest.NewHrefBuilder().
+		APIPath(a.api).
+		Fields(append([]string{"uuid"}, a.fields...)).
+		Filter(a.filter).
+		Build()
+	instances, err := rest.Fetch(b.client, href)
+	if err != nil {
+		return err
+	}
+
+	for n, instance := range instances {
+		uuid := instance.Get("uuid").String()
+		if uuid == "" {
+			continue
+		}
+		records, err := b.records(object, a, uuid, merge(b.labels, a.labels(b, instance)))
+		if err != nil {
+			var re *errs.RestError
+			// instances created or deleted during the backfill have no samples
+			if errors.As(err, &re) && re.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return err
+		}
+		for _, record := range records {
+			b.samples += int64(b.target.add(record))
+		}
+		if b.target.pending() >= b.batchSize {
+			if err := b.target.flush(); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(b.out, "%s: backfilled %d/%d instances, samples=%d\n", object, n+1, len(instances), b.samples)
+		}
+	}
+	if err := b.target.flush(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(b.out, "%s: backfilled %d instances, samples=%d\n", object, len(instances), b.samples)
+	return nil
+}
+
+// loadNodes reads the node of each aggregate, since the node of a volume is the node of its aggregate
+func (b *backfill) loadNodes() error {
+	href := rest.NewHrefBuilder().
+		APIPath("api/storage/aggregates").
+		Fields([]string{"name", "node.name"}).
+		Build()
+	aggrs, err := rest.Fetch(b.client, href)
+	if err != nil {
+		return err
+	}
+	b.nodes = make(map[string]string, len(aggrs))
+	for _, aggr := range aggrs {
+		b.nodes[aggr.Get("name").String()] = aggr.Get("node.name").String()
+	}
+	return nil
+}
+
+// records returns a record for each sample of an instance since from, oldest first. Samples whose status is not ok,
+// e.g. partial_no_data, are skipped
+func (b *backfill) records(object string, a archive, uuid string, labels map[string]string) ([]file.Record, error) {
+	href := rest.NewHrefBuilder().
+		APIPath(a.api + "/" + uuid + "/metrics").
+		Fields([]string{"timestamp", "status", "iops", "latency", "throughput"}).
+		Filter([]string{"interval=" + b.interval}).
+		Build()
+	samples, err := rest.Fetch(b.client, href)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := sortedKeys(a.metrics)
+	records := make([]file.Record, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Get("status").String() != "ok" {
+			continue
+		}
+		timestamp, err := time.Parse(time.RFC3339, sample.Get("timestamp").String())
+		if err != nil || timestamp.Before(b.from) {
+			continue
+		}
+		record := file.Record{Timestamp: timestamp.UnixMilli(), Object: object, Labels: labels}
+		for _, field := range fields {
+			value := sample.Get(field)
+			if !value.Exists() {
+				continue
+			}
+			record.Metrics = append(record.Metrics, file.Sample{Name: a.metrics[field], Value: json.Number(value.Raw)})
+		}
+		if len(record.Metrics) > 0 {
+			records = append(records, record)
+		}
+	}
+	// ONTAP returns the newest sample first, Prometheus expects the samples of a series in order
+	slices.SortFunc(records, func(x, y file.Record) int {
+		return cmp.Compare(x.Timestamp, y.Timestamp)
+	})
+	return records, nil
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/testutil"
+	"testing"
+	"time"
+)
+
+// recorder is a target that records the records it is given
+type recorder struct {
+	records []file.Record
+	samples int
+	flushes int
+}
+
+func (r *recorder) add(record file.Record) int {
+	r.records = append(r.records, record)
+	r.samples += len(record.Metrics)
+	return len(record.Metrics)
+}
+
+func (r *recorder) pending() int {
+	return r.samples
+}
+
+func (r *recorder) flush() error {
+	r.samples = 0
+	r.flushes++
+	return nil
+}
+
+func TestIntervalOf(t *testing.T) {
+	tests := []struct {
+		hours   int
+		want    string
+		wantErr bool
+	}{
+		{hours: 1, want: "1h"},
+		{hours: 2, want: "1d"},
+		{hours: 24, want: "1d"},
+		{hours: 25, want: "1w"},
+		{hours: 24 * 30, want: "1m"},
+		{hours: 24 * 365, want: "1y"},
+		{hours: 24*365 + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := intervalOf(tt.hours)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("intervalOf(%d) got=%s,%v want=%s,wantErr=%t", tt.hours, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	_, poller := testutil.StartONTAP(t, "testdata/backfill")
+	poller.Datacenter = "dc1"
+	client, err := rest.New(poller, 5*time.Second, auth.NewCredentials(poller, logging.Get()))
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	if err := client.Init(1); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+
+	r := &recorder{}
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	b, err := newBackfill(client, poller, r, now, 1)
+	if err != nil {
+		t.Fatalf("newBackfill() err=%v", err)
+	}
+	for _, object := range []string{"volume", "lun"} {
+		if err := b.object(object); err != nil {
+			t.Fatalf("object(%s) err=%v", object, err)
+		}
+	}
+
+	volume := map[string]string{"datacenter": "dc1", "cluster": "fake", "svm": "svm1", "volume": "vol1",
+		"aggr": "aggr2", "node": "node2", "style": "flexvol"}
+	lun := map[string]string{"datacenter": "dc1", "cluster": "fake", "svm": "svm1", "volume": "vol1", "lun": "lun1"}
+	want := []file.Record{
+		// the samples that are not ok or older than an hour are skipped, the others are sent oldest first
+		{Timestamp: time.Date(2024, 6, 15, 11, 59, 15, 0, time.UTC).UnixMilli(), Object: "volume", Labels: volume,
+			Metrics: []file.Sample{
+				{Name: "other_ops", Value: "0"}, {Name: "read_ops", Value: "5"}, {Name: "total_ops", Value: "15"},
+				{Name: "write_ops", Value: "10"}, {Name: "other_latency", Value: "0"}, {Name: "read_latency", Value: "100"},
+				{Name: "avg_latency", Value: "133"}, {Name: "write_latency", Value: "150"}, {Name: "read_data", Value: "2048"},
+				{Name: "total_data", Value: "6144"}, {Name: "write_data", Value: "4096"},
+			}},
+		{Timestamp: time.Date(2024, 6, 15, 11, 59, 45, 0, time.UTC).UnixMilli(), Object: "volume", Labels: volume,
+			Metrics: []file.Sample{
+				{Name: "other_ops", Value: "1"}, {Name: "read_ops", Value: "10"}, {Name: "total_ops", Value: "31"},
+				{Name: "write_ops", Value: "20"}, {Name: "other_latency", Value: "50"}, {Name: "read_latency", Value: "200"},
+				{Name: "avg_latency", Value: "260.5"}, {Name: "write_latency", Value: "300"}, {Name: "read_data", Value: "4096"},
+				{Name: "total_data", Value: "12288"}, {Name: "write_data", Value: "8192"},
+			}},
+		// the LUN deleted during the backfill has no samples
+		{Timestamp: time.Date(2024, 6, 15, 11, 59, 45, 0, time.UTC).UnixMilli(), Object: "lun", Labels: lun,
+			Metrics: []file.Sample{
+				{Name: "read_ops", Value: "7"}, {Name: "write_ops", Value: "8"}, {Name: "avg_read_latency", Value: "90"},
+				{Name: "avg_write_latency", Value: "110"}, {Name: "read_data", Value: "512"}, {Name: "write_data", Value: "1024"},
+			}},
+	}
+	if diff := cmp.Diff(r.records, want); diff != "" {
+		t.Errorf("Mismatch (-got +want):\n%s", diff)
+	}
+	if r.flushes != 2 {
+		t.Errorf("flushes got=%d, want=2", r.flushes)
+	}
+}
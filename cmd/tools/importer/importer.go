@@ -0,0 +1,285 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package importer replays the files of the File exporter into Prometheus, via remote write, or InfluxDB,
+with the time the metrics were exported.
+
+The number of lines sent from each file is saved in a state file after each batch,
+so an import that is interrupted, or that is run again after more files or lines are copied, resumes where it stopped.
+
+The backfill subcommand sends the performance samples that ONTAP archived instead of files, see backfill.go.
+*/
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBatchSize = 5000
+	defaultStateFile = "harvest-import.state"
+	reportInterval   = 5 * time.Second
+)
+
+type options struct {
+	remoteWriteURL string
+	influxURL      string
+	token          string
+	batchSize      int
+	stateFile      string
+	timeout        time.Duration
+}
+
+var opts = &options{}
+
+var Cmd = &cobra.Command{
+	Use:   "import [flags] FILE|DIRECTORY...",
+	Short: "Replay files of the File exporter into Prometheus or InfluxDB",
+	Long: `Replay files of the File exporter into Prometheus or InfluxDB, with the time the metrics were exported.
+Directories are searched for files of the File exporter, not recursively.
+Progress is saved in the state file, so running the same import again resumes where it stopped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  doImport,
+}
+
+func init() {
+	// the flags of the target are shared with the backfill subcommand
+	flags := Cmd.PersistentFlags()
+	flags.StringVar(&opts.remoteWriteURL, "remote-write-url", "", "Prometheus remote write URL, e.g. http://localhost:9090/api/v1/write")
+	flags.StringVar(&opts.influxURL, "influxdb-url", "", "InfluxDB write URL, e.g. http://localhost:8086/api/v2/write?org=harvest&bucket=harvest")
+	flags.StringVarP(&opts.token, "token", "t", "", "Token sent as a bearer token to Prometheus, or as an API token to InfluxDB")
+	flags.IntVar(&opts.batchSize, "batch-size", defaultBatchSize, "Number of samples sent per request")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of each request")
+	Cmd.Flags().StringVar(&opts.stateFile, "state", defaultStateFile, "File that records the progress of the import")
+	Cmd.MarkFlagsMutuallyExclusive("remote-write-url", "influxdb-url")
+	Cmd.MarkFlagsOneRequired("remote-write-url", "influxdb-url")
+	Cmd.AddCommand(backfillCmd)
+}
+
+func doImport(_ *cobra.Command, args []string) {
+	if err := run(args, os.Stdout); err != nil {
+		fmt.Printf("import failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string, out io.Writer) error {
+	if opts.batchSize <= 0 {
+		return errs.New(errs.ErrInvalidParam, "batch-size must be positive")
+	}
+
+	t, err := newTarget()
+	if err != nil {
+		return err
+	}
+
+	files, err := filesOf(paths)
+	if err != nil {
+		return err
+	}
+	s, err := loadState(opts.stateFile)
+	if err != nil {
+		return err
+	}
+
+	i := &importer{target: t, batchSize: opts.batchSize, state: s, statePath: opts.stateFile, out: out, start: time.Now()}
+	for n, f := range files {
+		if err := i.importFile(f); err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+		_, _ = fmt.Fprintf(out, "imported %d/%d files, %s\n", n+1, len(files), i.progress())
+	}
+	return nil
+}
+
+// newTarget returns the target of the remote-write-url or influxdb-url flag
+func newTarget() (target, error) {
+	client := &http.Client{Timeout: opts.timeout}
+	if opts.remoteWriteURL != "" {
+		return &remoteWrite{client: client, url: opts.remoteWriteURL, token: opts.token}, nil
+	}
+	return newInflux(client, opts.influxURL, opts.token)
+}
+
+// filesOf returns the files of paths, with the files of each directory sorted by name, which is by poller and time
+func filesOf(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !isExported(p) {
+				return nil, errs.New(errs.ErrInvalidParam, p+" is not a file of the File exporter, expected "+
+					file.Extension+" or "+file.CompressedExtension)
+			}
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() && isExported(entry.Name()) {
+				names = append(names, entry.Name())
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(p, name))
+		}
+	}
+	return files, nil
+}
+
+func isExported(name string) bool {
+	return strings.HasSuffix(name, file.Extension) || strings.HasSuffix(name, file.CompressedExtension)
+}
+
+// state is the number of lines sent from each file, by file name
+type state struct {
+	Lines map[string]int64 `json:"lines"`
+}
+
+func loadState(path string) (*state, error) {
+	s := &state{Lines: make(map[string]int64)}
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if s.Lines == nil {
+		s.Lines = make(map[string]int64)
+	}
+	return s, nil
+}
+
+// save replaces the state file, so an interrupted save does not lose the previous state
+func (s *state) save(path string) error {
+	contents, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, contents, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type importer struct {
+	target     target
+	batchSize  int
+	state      *state
+	statePath  string
+	out        io.Writer
+	start      time.Time
+	lastReport time.Time
+	lines      int64
+	samples    int64
+	invalid    int64
+}
+
+func (i *importer) progress() string {
+	rate := float64(i.samples) / max(time.Since(i.start).Seconds(), 1e-9)
+	return fmt.Sprintf("lines=%d samples=%d invalid=%d rate=%.0f samples/s", i.lines, i.samples, i.invalid, rate)
+}
+
+// importFile sends the lines of path that were not sent yet. A last line without a line feed is
+// still being written, and is sent by the next import
+func (i *importer) importFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, file.CompressedExtension) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		r = gz
+	}
+
+	key := filepath.Base(path)
+	sent := i.state.Lines[key]
+	reader := bufio.NewReader(r)
+	var n int64
+
+	commit := func() error {
+		if err := i.target.flush(); err != nil {
+			return err
+		}
+		i.state.Lines[key] = n
+		return i.state.save(i.statePath)
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// files that are still written, or were copied while written, end without a gzip footer
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return err
+			}
+			break
+		}
+		n++
+		if n <= sent {
+			continue
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var record file.Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			i.invalid++
+			continue
+		}
+		i.lines++
+		i.samples += int64(i.target.add(record))
+
+		if i.target.pending() >= i.batchSize {
+			if err := commit(); err != nil {
+				return err
+			}
+			if time.Since(i.lastReport) >= reportInterval {
+				i.lastReport = time.Now()
+				_, _ = fmt.Fprintf(i.out, "%s: %s\n", key, i.progress())
+			}
+		}
+	}
+
+	if n <= sent {
+		return nil
+	}
+	return commit()
+}
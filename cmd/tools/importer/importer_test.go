@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// snappyDecode decodes the literals of a snappy block
+func snappyDecode(t *testing.T, src []byte) []byte {
+	t.Helper()
+	size, n := binary.Uvarint(src)
+	src = src[n:]
+	var dst []byte
+	for len(src) > 0 {
+		tag := src[0]
+		if tag&3 != 0 {
+			t.Fatalf("unexpected snappy copy tag %x", tag)
+		}
+		length := int(tag>>2) + 1
+		src = src[1:]
+		if tag>>2 == 61 {
+			length = int(src[0]) | int(src[1])<<8 + 1
+			src = src[2:]
+		}
+		dst = append(dst, src[:length]...)
+		src = src[length:]
+	}
+	if uint64(len(dst)) != size {
+		t.Fatalf("snappy decoded %d bytes, want=%d", len(dst), size)
+	}
+	return dst
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, size := range []int{0, 1, 60, 61, 1 << 16, 1<<16 + 1, 200_000} {
+		src := bytes.Repeat([]byte("harvest"), size/7+1)[:size]
+		if got := snappyDecode(t, snappyEncode(src)); !bytes.Equal(got, src) {
+			t.Errorf("size=%d snappy round trip does not match", size)
+		}
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	got := encodeWriteRequest([]series{newSeries("vol-ops", map[string]string{"a": "b"}, 1, 1)})
+
+	label := func(name, value string) []byte {
+		b := append([]byte{0x0a, byte(len(name))}, name...)
+		b = append(b, 0x12, byte(len(value)))
+		return append(b, value...)
+	}
+	nameLabel := label("__name__", "vol_ops")
+	aLabel := label("a", "b")
+	sample := []byte{0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x10, 0x01}
+
+	var ts []byte
+	ts = append(append(append(ts, 0x0a, byte(len(nameLabel))), nameLabel...), 0x0a, byte(len(aLabel)))
+	ts = append(append(append(ts, aLabel...), 0x12, byte(len(sample))), sample...)
+	want := append([]byte{0x0a, byte(len(ts))}, ts...)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeWriteRequest() got=%x, want=%x", got, want)
+	}
+}
+
+func TestImportResume(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		sent = append(sent, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "harvest-u2-20240615T020000.000000000.jsonl")
+	line := func(volume string) string {
+		return `{"timestamp":1718416800000,"object":"volume","labels":{"volume":"` + volume + `"},"metrics":[{"name":"read_ops","value":1}]}`
+	}
+	// the last line is still being written
+	if err := os.WriteFile(path, []byte(line("vol1")+"\nnot json\n"+line("vol2")+"\n"+line("vol3")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.influxURL = server.URL + "/api/v2/write?org=o&bucket=b"
+	opts.batchSize = 1
+	opts.stateFile = filepath.Join(dir, "state")
+
+	if err := run([]string{dir}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"volume,volume=vol1 read_ops=1 1718416800000",
+		"volume,volume=vol2 read_ops=1 1718416800000",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("first import got=%v, want=%v", sent, want)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("\n")
+	_ = f.Close()
+
+	sent = nil
+	if err := run([]string{dir}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "volume=vol3") {
+		t.Errorf("resumed import got=%v, want only vol3", sent)
+	}
+}
+
+func TestFilesOf(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"harvest-b-1.jsonl.gz", "harvest-a-1.jsonl", "notes.txt", "data.parquet"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := filesOf([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "harvest-a-1.jsonl"), filepath.Join(dir, "harvest-b-1.jsonl.gz")}
+	if !slices.Equal(got, want) {
+		t.Errorf("filesOf() got=%v, want=%v", got, want)
+	}
+
+	if _, err := filesOf([]string{filepath.Join(dir, "data.parquet")}); err == nil {
+		t.Errorf("filesOf() expected an error for a file that is not JSON Lines")
+	}
+}
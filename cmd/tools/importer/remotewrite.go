@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"encoding/binary"
+	"math"
+	"regexp"
+	"slices"
+)
+
+// The Prometheus remote write protocol is a snappy compressed protobuf WriteRequest.
+// Harvest encodes both by hand instead of depending on the protobuf and snappy modules
+// See https://prometheus.io/docs/specs/remote_write_spec/
+
+type label struct {
+	name  string
+	value string
+}
+
+type series struct {
+	labels    []label
+	value     float64
+	timestamp int64 // unix milliseconds
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// promName replaces the characters that are not allowed in Prometheus metric and label names
+func promName(name string) string {
+	return invalidNameChars.ReplaceAllString(name, "_")
+}
+
+// newSeries returns a series of metric with labels sorted by name, as the protocol requires
+func newSeries(metric string, labels map[string]string, value float64, timestamp int64) series {
+	s := series{value: value, timestamp: timestamp}
+	s.labels = append(s.labels, label{name: "__name__", value: promName(metric)})
+	for name, value := range labels {
+		s.labels = append(s.labels, label{name: promName(name), value: value})
+	}
+	slices.SortFunc(s.labels, func(a, b label) int {
+		switch {
+		case a.name < b.name:
+			return -1
+		case a.name > b.name:
+			return 1
+		}
+		return 0
+	})
+	return s
+}
+
+// encodeWriteRequest returns the protobuf encoding of a WriteRequest with one TimeSeries and one Sample per series
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(all []series) []byte {
+	var request, ts, buf []byte
+	for _, s := range all {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			buf = buf[:0]
+			buf = appendString(buf, 1, l.name)
+			buf = appendString(buf, 2, l.value)
+			ts = appendBytes(ts, 1, buf)
+		}
+		buf = buf[:0]
+		buf = binary.AppendUvarint(buf, 1<<3|1)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(s.value))
+		buf = binary.AppendUvarint(buf, 2<<3)
+		buf = binary.AppendUvarint(buf, uint64(s.timestamp))
+		ts = appendBytes(ts, 2, buf)
+		request = appendBytes(request, 1, ts)
+	}
+	return request
+}
+
+func appendString(b []byte, field uint64, s string) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b []byte, field uint64, v []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// snappyEncode returns src in the snappy block format, as uncompressed literals.
+// Any snappy decoder reads it, and remote write payloads are small enough to not need compression
+// See https://github.com/google/snappy/blob/main/format_description.txt
+func snappyEncode(src []byte) []byte {
+	const maxLiteral = 1 << 16
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)+len(src)/maxLiteral*3+8), uint64(len(src)))
+	for len(src) > 0 {
+		n := min(len(src), maxLiteral)
+		if n <= 60 {
+			dst = append(dst, byte(n-1)<<2)
+		} else {
+			// tag 61 means the length minus one follows in two little endian bytes
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/exporters/file"
+	"github.com/netapp/harvest/v2/cmd/exporters/influxdb"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// target is a time-series database that records are replayed into
+type target interface {
+	// add buffers the samples of record and returns how many were added
+	add(record file.Record) int
+	// pending returns the number of buffered samples
+	pending() int
+	// flush sends the buffered samples
+	flush() error
+}
+
+type remoteWrite struct {
+	client *http.Client
+	url    string
+	token  string
+	series []series
+}
+
+func (r *remoteWrite) add(record file.Record) int {
+	for _, sample := range record.Metrics {
+		labels := record.Labels
+		if len(sample.Labels) > 0 {
+			labels = merge(record.Labels, sample.Labels)
+		}
+		// remote write samples are float64
+		value, _ := sample.Value.Float64()
+		r.series = append(r.series, newSeries(record.Object+"_"+sample.Name, labels, value, record.Timestamp))
+	}
+	n := len(record.Metrics)
+	// instance labels are exported like the Prometheus exporter does, as an info metric with value 1
+	if len(record.Info) > 0 {
+		r.series = append(r.series, newSeries(record.Object+"_labels", merge(record.Labels, record.Info), 1, record.Timestamp))
+		n++
+	}
+	return n
+}
+
+func (r *remoteWrite) pending() int {
+	return len(r.series)
+}
+
+func (r *remoteWrite) flush() error {
+	if len(r.series) == 0 {
+		return nil
+	}
+	request, err := requests.New("POST", r.url, bytes.NewReader(snappyEncode(encodeWriteRequest(r.series))))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if r.token != "" {
+		request.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	if err := send(r.client, request); err != nil {
+		return err
+	}
+	r.series = r.series[:0]
+	return nil
+}
+
+type influx struct {
+	client  *http.Client
+	url     string
+	token   string
+	lines   []string
+	samples int
+}
+
+// newInflux returns an InfluxDB target that writes to writeURL with millisecond precision
+func newInflux(client *http.Client, writeURL string, token string) (*influx, error) {
+	u, err := url.Parse(writeURL)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidParam, "influxdb url: "+err.Error())
+	}
+	query := u.Query()
+	query.Set("precision", "ms")
+	u.RawQuery = query.Encode()
+	return &influx{client: client, url: u.String(), token: token}, nil
+}
+
+// add renders one measurement for the samples of record without labels, and one for each set of sample labels
+func (i *influx) add(record file.Record) int {
+	measurements := make(map[string]*influxdb.Measurement)
+	timestamp := strconv.FormatInt(record.Timestamp, 10)
+
+	measurement := func(labels map[string]string) *influxdb.Measurement {
+		all := merge(record.Labels, labels)
+		id := ""
+		for _, name := range sortedKeys(labels) {
+			id += name + "=" + labels[name] + ","
+		}
+		if m, ok := measurements[id]; ok {
+			return m
+		}
+		m := influxdb.NewMeasurement(record.Object, 0)
+		for _, name := range sortedKeys(all) {
+			if all[name] != "" {
+				m.AddTag(name, all[name])
+			}
+		}
+		m.SetTimestamp(timestamp)
+		measurements[id] = m
+		return m
+	}
+
+	for _, sample := range record.Metrics {
+		measurement(sample.Labels).AddField(sample.Name, sample.Value.String())
+	}
+	for _, name := range sortedKeys(record.Info) {
+		measurement(nil).AddFieldString(name, record.Info[name])
+	}
+
+	for _, id := range sortedKeys(measurements) {
+		if line, err := measurements[id].Render(); err == nil {
+			i.lines = append(i.lines, line)
+		}
+	}
+	n := len(record.Metrics) + len(record.Info)
+	i.samples += n
+	return n
+}
+
+func (i *influx) pending() int {
+	return i.samples
+}
+
+func (i *influx) flush() error {
+	if len(i.lines) == 0 {
+		return nil
+	}
+	request, err := requests.New("POST", i.url, strings.NewReader(strings.Join(i.lines, "\n")))
+	if err != nil {
+		return err
+	}
+	if i.token != "" {
+		request.Header.Set("Authorization", "Token "+i.token)
+	}
+	if err := send(i.client, request); err != nil {
+		return err
+	}
+	i.lines = i.lines[:0]
+	i.samples = 0
+	return nil
+}
+
+func send(client *http.Client, request *http.Request) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return errs.New(errs.ErrAPIResponse, err.Error())
+		}
+		return fmt.Errorf("%w: %s %s", errs.ErrAPIRequestRejected, response.Status, string(body))
+	}
+	return nil
+}
+
+// merge returns a new map with the entries of a and b. Entries of b win
+func merge(a, b map[string]string) map[string]string {
+	m := make(map[string]string, len(a)+len(b))
+	maps.Copy(m, a)
+	maps.Copy(m, b)
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package rename
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// the keys of the dashboard strings that are queries
+var queryKeys = []string{"expr", "definition", "query"}
+
+// the keys of the transformation options that map field names
+var fieldMapKeys = []string{"excludeByName", "indexByName", "renameByName"}
+
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// Dashboard returns data with r renamed in the queries of the dashboard.
+// Labels are also renamed in legends, and in the fields of transformations and byName overrides
+func Dashboard(data []byte, r Rename) ([]byte, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	var edits []edit
+	editString := func(value gjson.Result, renamed string) {
+		if renamed == value.String() {
+			return
+		}
+		edits = append(edits, edit{start: value.Index, end: value.Index + len(value.Raw), text: quote(renamed)})
+	}
+
+	var walk func(parent gjson.Result)
+	walk = func(parent gjson.Result) {
+		parent.ForEach(func(key, value gjson.Result) bool {
+			k := key.String()
+			switch {
+			case value.Type == gjson.String && slices.Contains(queryKeys, k):
+				editString(value, RenameExpr(value.String(), r))
+			case value.Type == gjson.String && k == "legendFormat" && r.Kind == Label:
+				editString(value, renameLegend(value.String(), r))
+			case value.Type == gjson.String && k == "options" && r.Kind == Label &&
+				parent.Get("id").String() == "byName" && value.String() == r.Old:
+				editString(value, r.New)
+			case value.IsObject() && slices.Contains(fieldMapKeys, k) && r.Kind == Label:
+				value.ForEach(func(field, _ gjson.Result) bool {
+					if field.String() == r.Old {
+						editString(field, r.New)
+					}
+					return true
+				})
+			case value.IsObject() || value.IsArray():
+				walk(value)
+			}
+			return true
+		})
+	}
+	walk(gjson.ParseBytes(data))
+
+	if len(edits) == 0 {
+		return data, nil
+	}
+	var b bytes.Buffer
+	last := 0
+	slices.SortFunc(edits, func(a, b edit) int { return a.start - b.start })
+	for _, e := range edits {
+		b.Write(data[last:e.start])
+		b.WriteString(e.text)
+		last = e.end
+	}
+	b.Write(data[last:])
+	return b.Bytes(), nil
+}
+
+// RenameExpr returns the PromQL expression expr with r renamed.
+// Quoted text is not renamed, except for labels that are arguments of functions, e.g. label_replace
+func RenameExpr(expr string, r Rename) string {
+	if r.Kind == Metric {
+		return replaceNames(expr, r.Old, r.New, `"'`+"`", isNameChar, nil)
+	}
+	return replaceNames(expr, r.Old, r.New, `"'`+"`", isNameChar, func(prefix string, text string) string {
+		// values of label matchers, e.g. {type="volume"}
+		if p := strings.TrimRight(prefix, " "); strings.HasSuffix(p, "=") || strings.HasSuffix(p, "~") {
+			return text
+		}
+		if len(text) >= 2 && text[1:len(text)-1] == r.Old {
+			return text[:1] + r.New + text[len(text)-1:]
+		}
+		return text
+	})
+}
+
+func renameLegend(legend string, r Rename) string {
+	re := regexp.MustCompile(`\{\{\s*` + regexp.QuoteMeta(r.Old) + `\s*}}`)
+	return re.ReplaceAllString(legend, "{{"+r.New+"}}")
+}
+
+// quote returns s as a JSON string, without escaping HTML characters, as Grafana does
+func quote(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
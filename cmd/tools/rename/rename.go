@@ -0,0 +1,250 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package rename renames a metric or label consistently across the templates and dashboards Harvest ships with.
+
+Templates are changed where they define the metric or label: the display names of counters, export_options,
+and the rules of plugins. Dashboards are changed where they query the metric or label: expressions,
+variable queries, legends, and for labels, the fields of transformations and overrides.
+Files are changed in place, line by line, so the diff of a rename only has the lines that changed.
+*/
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Kind is what is renamed, a metric or a label
+type Kind string
+
+const (
+	Metric Kind = "metric"
+	Label  Kind = "label"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Rename renames Old to New
+type Rename struct {
+	Kind Kind
+	Old  string
+	New  string
+}
+
+func (r Rename) validate() error {
+	for _, name := range []string{r.Old, r.New} {
+		if !validName.MatchString(name) {
+			return fmt.Errorf("invalid %s name %q", r.Kind, name)
+		}
+	}
+	if r.Old == r.New {
+		return fmt.Errorf("%s is renamed to itself", r.Old)
+	}
+	return nil
+}
+
+type options struct {
+	confPath   string
+	dashboards string
+	dryRun     bool
+}
+
+var opts = &options{}
+
+var Cmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename a metric or label across templates and dashboards",
+}
+
+var metricCmd = &cobra.Command{
+	Use:   "metric [flags] OLD NEW",
+	Short: "Rename a metric across templates and dashboards",
+	Long: `Rename a metric across templates and dashboards.
+The metric is renamed in the templates whose object is the prefix of OLD, e.g. volume for volume_size,
+so NEW must have the same prefix.`,
+	Args: cobra.ExactArgs(2),
+	Run:  doRename(Metric),
+	Example: `
+# Show the changes without writing them
+harvest rename metric --dry-run volume_size volume_size_bytes`,
+}
+
+var labelCmd = &cobra.Command{
+	Use:   "label [flags] OLD NEW",
+	Short: "Rename a label across templates and dashboards",
+	Args:  cobra.ExactArgs(2),
+	Run:   doRename(Label),
+	Example: `
+# Show the changes without writing them
+harvest rename label --dry-run svm vserver`,
+}
+
+func init() {
+	Cmd.AddCommand(metricCmd, labelCmd)
+	Cmd.PersistentFlags().StringVar(&opts.confPath, "confpath", "conf", "directory of the templates")
+	Cmd.PersistentFlags().StringVar(&opts.dashboards, "dashboards", "grafana/dashboards", "directory of the dashboards")
+	Cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", false, "print the changes without writing them")
+}
+
+func doRename(kind Kind) func(*cobra.Command, []string) {
+	return func(_ *cobra.Command, args []string) {
+		r := Rename{Kind: kind, Old: args[0], New: args[1]}
+		if err := r.validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		changed, err := Files(r, opts.confPath, opts.dashboards, opts.dryRun, os.Stdout)
+		if err != nil {
+			fmt.Printf("rename failed: %v\n", err)
+			os.Exit(1)
+		}
+		switch {
+		case changed == 0:
+			fmt.Printf("%s %s not found\n", kind, r.Old)
+		case opts.dryRun:
+			fmt.Printf("%d files would change\n", changed)
+		default:
+			fmt.Printf("%d files changed\n", changed)
+		}
+	}
+}
+
+// Files renames r in the templates of confPath and the dashboards of dashboards, writes the diff of each
+// changed file to w, and returns the number of changed files.
+// Files are only written when all of them could be renamed, and dryRun is false
+func Files(r Rename, confPath string, dashboards string, dryRun bool, w io.Writer) (int, error) {
+	type change struct {
+		path string
+		data []byte
+		perm fs.FileMode
+	}
+	var changes []change
+
+	each := func(dir string, ext string, renameFn func([]byte, Rename) ([]byte, error)) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// the InfluxDB dashboards do not use PromQL
+			if d.IsDir() && d.Name() == "influxdb" {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || filepath.Ext(path) != ext {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			renamed, err := renameFn(data, r)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if bytes.Equal(data, renamed) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change{path: path, data: renamed, perm: info.Mode().Perm()})
+			_, _ = io.WriteString(w, Diff(path, data, renamed))
+			return nil
+		})
+	}
+
+	if err := each(confPath, ".yaml", Template); err != nil {
+		return 0, err
+	}
+	if err := each(dashboards, ".json", Dashboard); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(changes), nil
+	}
+	for _, c := range changes {
+		if err := os.WriteFile(c.path, c.data, c.perm); err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), nil
+}
+
+// Diff returns the lines that differ between before and after, with their line numbers.
+// Renames change lines in place, so lines are compared by number
+func Diff(path string, before []byte, after []byte) string {
+	var b strings.Builder
+	b.WriteString("--- " + path + "\n")
+	was := strings.Split(string(before), "\n")
+	is := strings.Split(string(after), "\n")
+	for i := range max(len(was), len(is)) {
+		var w, n string
+		if i < len(was) {
+			w = was[i]
+		}
+		if i < len(is) {
+			n = is[i]
+		}
+		if w == n {
+			continue
+		}
+		_, _ = fmt.Fprintf(&b, "%d\n- %s\n+ %s\n", i+1, strings.TrimSpace(w), strings.TrimSpace(n))
+	}
+	return b.String()
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// replaceNames replaces the names in s that equal from with to. Names are runs of the bytes of isChar.
+// Names preceded by $ are variables and are not replaced. Text quoted by any of quotes is passed to quoted
+func replaceNames(s string, from string, to string, quotes string, isChar func(byte) bool,
+	quoted func(prefix string, text string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case strings.IndexByte(quotes, c) >= 0:
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(s))
+			text := s[i:end]
+			if quoted != nil {
+				text = quoted(s[:i], text)
+			}
+			b.WriteString(text)
+			i = end
+		case isChar(c):
+			end := i
+			for end < len(s) && isChar(s[end]) {
+				end++
+			}
+			name := s[i:end]
+			if name == from && (i == 0 || s[i-1] != '$') {
+				name = to
+			}
+			b.WriteString(name)
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
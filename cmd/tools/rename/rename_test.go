@@ -0,0 +1,191 @@
+package rename
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Rename
+		expr string
+		want string
+	}{
+		{name: "metric", r: Rename{Metric, "volume_size", "volume_size_bytes"},
+			expr: `sum(volume_size{svm=~"$SVM"}) / sum(volume_size_used)`,
+			want: `sum(volume_size_bytes{svm=~"$SVM"}) / sum(volume_size_used)`},
+		{name: "metric in variable", r: Rename{Metric, "volume_labels", "volume_info"},
+			expr: `label_values(volume_labels{cluster=~"$Cluster"}, svm)`,
+			want: `label_values(volume_info{cluster=~"$Cluster"}, svm)`},
+		{name: "label", r: Rename{Label, "svm", "vserver"},
+			expr: `topk(5, sum by (cluster, svm) (volume_size{svm=~"$SVM", type="svm"}))`,
+			want: `topk(5, sum by (cluster, vserver) (volume_size{vserver=~"$SVM", type="svm"}))`},
+		{name: "label argument", r: Rename{Label, "svm", "vserver"},
+			expr: `label_replace(volume_size, "name", "$1", "svm", "(.*)")`,
+			want: `label_replace(volume_size, "name", "$1", "vserver", "(.*)")`},
+		{name: "label in metric name", r: Rename{Label, "svm", "vserver"},
+			expr: `svm_vol_total_ops`,
+			want: `svm_vol_total_ops`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenameExpr(tt.expr, tt.r); got != tt.want {
+				t.Errorf("RenameExpr() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	template := `name:     Volume
+query:    volume-get-iter
+object:   volume
+
+counters:
+  volume-attributes:
+    - volume-id-attributes:
+      - ^^name                   => volume
+      - ^^owning-vserver-name    => svm  # owner
+    - volume-space-attributes:
+      - size-used
+      - size                     => size
+    - filter:
+      - svm=true
+
+plugins:
+  - LabelAgent:
+      replace_regex:
+        - svm svm_name ` + "`svm`" + ` ` + "`x`" + `
+  - MetricAgent:
+      compute_metric:
+        - size_free SUBTRACT size size_used
+
+export_options:
+  instance_keys:
+    - svm
+    - volume
+`
+	tests := []struct {
+		name    string
+		r       Rename
+		want    []string
+		wantErr bool
+	}{
+		{name: "label", r: Rename{Label, "svm", "vserver"}, want: []string{
+			"- ^^owning-vserver-name    => vserver  # owner",
+			"- svm=true",
+			"- vserver svm_name `svm` `x`",
+			"    - vserver\n",
+		}},
+		{name: "metric without display name", r: Rename{Metric, "volume_size_used", "volume_size_used_bytes"}, want: []string{
+			"- size-used                => size_used_bytes\n",
+			"- size_free SUBTRACT size size_used_bytes",
+		}},
+		{name: "metric of other object", r: Rename{Metric, "lun_size", "lun_size_bytes"}, want: []string{
+			"- size                     => size\n",
+		}},
+		{name: "metric with other prefix", r: Rename{Metric, "volume_size", "vol_size"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Template([]byte(template), tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Template() error=%v, wantErr=%t", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Template() missing %q\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	dashboard := `{
+  "panels": [
+    {
+      "targets": [
+        {
+          "expr": "volume_size{svm=~\"$SVM\"}",
+          "legendFormat": "{{svm}} - {{ volume }}"
+        }
+      ],
+      "transformations": [
+        {
+          "id": "organize",
+          "options": {
+            "renameByName": {
+              "svm": "SVM",
+              "volume": "Volume"
+            }
+          }
+        }
+      ],
+      "fieldConfig": {
+        "overrides": [
+          {
+            "matcher": {
+              "id": "byName",
+              "options": "svm"
+            }
+          }
+        ]
+      }
+    }
+  ],
+  "title": "svm"
+}`
+	got, err := Dashboard([]byte(dashboard), Rename{Label, "svm", "vserver"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer(
+		`volume_size{svm=`, `volume_size{vserver=`,
+		`"{{svm}}`, `"{{vserver}}`,
+		`"svm": "SVM"`, `"vserver": "SVM"`,
+		`"options": "svm"`, `"options": "vserver"`,
+	).Replace(dashboard)
+	if string(got) != want {
+		t.Errorf("Dashboard() got=\n%s\nwant=\n%s", got, want)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "conf")
+	dashboards := filepath.Join(dir, "dashboards")
+	for _, d := range []string{conf, dashboards} {
+		if err := os.MkdirAll(d, 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	template := filepath.Join(conf, "volume.yaml")
+	dashboard := filepath.Join(dashboards, "volume.json")
+	_ = os.WriteFile(template, []byte("object: volume\ncounters:\n  - size => size\n"), 0o600)
+	_ = os.WriteFile(dashboard, []byte(`{"expr": "volume_size"}`), 0o600)
+
+	r := Rename{Metric, "volume_size", "volume_size_bytes"}
+	var diff bytes.Buffer
+	changed, err := Files(r, conf, dashboards, true, &diff)
+	if err != nil || changed != 2 {
+		t.Fatalf("Files() dry run got=%d err=%v, want=2", changed, err)
+	}
+	if data, _ := os.ReadFile(dashboard); string(data) != `{"expr": "volume_size"}` {
+		t.Errorf("dry run changed %s", dashboard)
+	}
+	if !strings.Contains(diff.String(), "+ - size => size_bytes") {
+		t.Errorf("Files() diff got=%s", diff.String())
+	}
+
+	if _, err := Files(r, conf, dashboards, false, &diff); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dashboard); string(data) != `{"expr": "volume_size_bytes"}` {
+		t.Errorf("Files() got=%s", data)
+	}
+}
@@ -0,0 +1,139 @@
+package rename
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"regexp"
+	"strings"
+)
+
+var topLevelKey = regexp.MustCompile(`^([a-z_]+):`)
+
+// Template returns data with r renamed in the template.
+// Counters are renamed by their display names. A counter without a display name gets one.
+// In export_options and plugins, names outside of backticks are renamed.
+// Metrics are only renamed in templates whose object is the prefix of the metric
+func Template(data []byte, r Rename) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	from, to := r.Old, r.New
+
+	if r.Kind == Metric {
+		object := ""
+		for _, line := range lines {
+			if after, ok := strings.CutPrefix(line, "object:"); ok {
+				object = strings.TrimSpace(trimComment(after))
+				break
+			}
+		}
+		prefix := object + "_"
+		if object == "" || !strings.HasPrefix(from, prefix) {
+			return data, nil
+		}
+		if !strings.HasPrefix(to, prefix) {
+			return nil, fmt.Errorf("%s must start with %s, the object of the template", to, prefix)
+		}
+		from = strings.TrimPrefix(from, prefix)
+		to = strings.TrimPrefix(to, prefix)
+	}
+
+	labels := exportedLabels(lines)
+	arrowColumn := -1
+	skipIndent := -1
+	section := ""
+
+	for n, line := range lines {
+		if m := topLevelKey.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			skipIndent = -1
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		switch section {
+		case "export_options", "plugins":
+			lines[n] = replaceNames(line, from, to, "`", isLabelChar, nil)
+		case "counters", "endpoints":
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if skipIndent >= 0 && indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+			item, ok := strings.CutPrefix(trimmed, "- ")
+			if !ok {
+				continue
+			}
+			spec := strings.TrimSpace(trimComment(item))
+			if spec == "filter:" || spec == "hidden_fields:" {
+				// children are fields of the API, not counters
+				skipIndent = indent
+				continue
+			}
+			if strings.HasSuffix(spec, ":") || strings.Contains(spec, ": ") {
+				continue
+			}
+			if i := strings.Index(line, "=>"); i >= 0 && arrowColumn < 0 {
+				arrowColumn = i
+			}
+
+			_, display, kind, _ := util.ParseMetric(spec)
+			isLabel := kind != "float" || labels[display]
+			if display != from || isLabel != (r.Kind == Label) {
+				continue
+			}
+			lines[n] = renameCounter(line, spec, display, to, arrowColumn)
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// renameCounter returns the line of the counter spec with its display name replaced by to,
+// or with to as display name when spec has none
+func renameCounter(line string, spec string, display string, to string, arrowColumn int) string {
+	if i := strings.Index(line, "=>"); i >= 0 {
+		after := line[i+2:]
+		j := strings.Index(after, display)
+		return line[:i+2] + after[:j] + to + after[j+len(display):]
+	}
+	i := strings.Index(line, spec) + len(spec)
+	padding := max(arrowColumn-i, 1)
+	return line[:i] + strings.Repeat(" ", padding) + "=> " + to + line[i:]
+}
+
+// exportedLabels returns the names of the instance_keys and instance_labels of the export_options in lines.
+// Perf templates do not mark labels with ^
+func exportedLabels(lines []string) map[string]bool {
+	labels := make(map[string]bool)
+	section, list := "", ""
+	for _, line := range lines {
+		if m := topLevelKey.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		}
+		if section != "export_options" {
+			continue
+		}
+		trimmed := strings.TrimSpace(trimComment(line))
+		if key, ok := strings.CutSuffix(trimmed, ":"); ok {
+			list = key
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && (list == "instance_keys" || list == "instance_labels") {
+			labels[strings.TrimSpace(item)] = true
+		}
+	}
+	return labels
+}
+
+func trimComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isLabelChar(c byte) bool {
+	return c != ':' && isNameChar(c)
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"net"
+	"net/http"
+	"sync"
+)
+
+var (
+	budgetsMu sync.Mutex
+	budgets   = make(map[string]*util.Budget)
+)
+
+// budgetFor returns the error budget shared by the clients of baseURL
+func budgetFor(baseURL string) *util.Budget {
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	b, ok := budgets[baseURL]
+	if !ok {
+		b = util.NewBudget(util.BudgetWindow)
+		budgets[baseURL] = b
+	}
+	return b
+}
+
+// BudgetFor returns the error budget of the REST calls to the cluster of poller
+func BudgetFor(poller *conf.Poller) *util.Budget {
+	return budgetFor(baseURL(poller))
+}
+
+// ErrorClass returns the error class of the result of a REST call
+func ErrorClass(err error) string {
+	if err == nil {
+		return util.ClassOK
+	}
+
+	switch {
+	case errors.Is(err, errs.ErrAuthFailed):
+		return util.ClassAuthFailed
+	case errors.Is(err, errs.ErrPermissionDenied):
+		return util.ClassPermissionDenied
+	case errors.Is(err, context.DeadlineExceeded):
+		return util.ClassTimeout
+	}
+
+	var restErr *errs.RestError
+	if errors.As(err, &restErr) && restErr.StatusCode != 0 {
+		switch {
+		case restErr.StatusCode == http.StatusUnauthorized:
+			return util.ClassAuthFailed
+		case restErr.StatusCode == http.StatusForbidden:
+			return util.ClassPermissionDenied
+		case restErr.StatusCode >= http.StatusInternalServerError:
+			return util.ClassServerError
+		case restErr.StatusCode >= http.StatusBadRequest:
+			return util.ClassClientError
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return util.ClassTimeout
+	}
+	return util.ClassConnectionError
+}
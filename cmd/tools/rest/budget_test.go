@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"net"
+	"net/http"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestErrorClass(t *testing.T) {
+	restErr := func(code int) error {
+		return errs.NewRest().StatusCode(code).Error(errors.New("failed")).API("api/storage/volumes").Build()
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "ok", err: nil, want: util.ClassOK},
+		{name: "auth", err: errs.NewRest().StatusCode(http.StatusUnauthorized).Error(errs.ErrAuthFailed).Build(), want: util.ClassAuthFailed},
+		{name: "forbidden", err: restErr(http.StatusForbidden), want: util.ClassPermissionDenied},
+		{name: "not found", err: restErr(http.StatusNotFound), want: util.ClassClientError},
+		{name: "bad request", err: restErr(http.StatusBadRequest), want: util.ClassClientError},
+		{name: "server", err: restErr(http.StatusServiceUnavailable), want: util.ClassServerError},
+		{name: "deadline", err: fmt.Errorf("%w: %w", context.DeadlineExceeded, errors.New("canceled")), want: util.ClassTimeout},
+		{name: "net timeout", err: fmt.Errorf("connection error %w", timeoutError{}), want: util.ClassTimeout},
+		{name: "connection", err: fmt.Errorf("connection error %w", errors.New("connection refused")), want: util.ClassConnectionError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorClass(tt.err); got != tt.want {
+				t.Errorf("ErrorClass() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
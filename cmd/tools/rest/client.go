@@ -4,6 +4,7 @@ package rest
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/netapp/harvest/v2/pkg/auth"
@@ -27,6 +28,10 @@ import (
 const (
 	// DefaultTimeout should be > than ONTAP's default REST timeout, which is 15 seconds for GET requests
 	DefaultTimeout = "30s"
+	// DefaultReturnTimeout is ONTAP's default return_timeout of GET requests, in seconds
+	DefaultReturnTimeout = 15
+	// MinReturnTimeout is the lowest return_timeout Harvest asks for, in seconds
+	MinReturnTimeout = 1
 	// DefaultDialerTimeout limits the time spent establishing a TCP connection
 	DefaultDialerTimeout = 10 * time.Second
 	Message              = "message"
@@ -35,17 +40,19 @@ const (
 )
 
 type Client struct {
-	client   *http.Client
-	request  *http.Request
-	buffer   *bytes.Buffer
-	Logger   *logging.Logger
-	baseURL  string
-	cluster  Cluster
-	token    string
-	Timeout  time.Duration
-	logRest  bool // used to log Rest request/response
-	auth     *auth.Credentials
-	Metadata *util.Metadata
+	client    *http.Client
+	request   *http.Request
+	buffer    *bytes.Buffer
+	Logger    *logging.Logger
+	baseURL   string
+	cluster   Cluster
+	token     string
+	Timeout   time.Duration
+	logRest   bool // used to log Rest request/response
+	auth      *auth.Credentials
+	Metadata  *util.Metadata
+	coalescer *Coalescer   // shares the records of concurrent fetches of the poller, nil when disabled
+	schemas   *SchemaCache // counter schemas shared by the pollers of the host, nil when disabled
 }
 
 type Cluster struct {
@@ -75,12 +82,9 @@ func New(poller *conf.Poller, timeout time.Duration, credentials *auth.Credentia
 		return nil, errs.New(errs.ErrMissingParam, "addr")
 	}
 
-	if poller.IsKfs {
-		url = "https://" + addr + ":8443/"
-	} else {
-		url = "https://" + addr + "/"
-	}
+	url = baseURL(poller)
 	client.baseURL = url
+	client.Metadata.Budget = budgetFor(url)
 	client.Timeout = timeout
 
 	transport, err = credentials.Transport(nil)
@@ -94,10 +98,23 @@ func New(poller *conf.Poller, timeout time.Duration, credentials *auth.Credentia
 	if poller.LogSet != nil {
 		client.logRest = slices.Contains(*poller.LogSet, "Rest")
 	}
+	if poller.CoalesceRequests {
+		client.coalescer = coalescerFor(url)
+	}
+	if client.schemas, err = NewSchemaCache(poller.CounterCache); err != nil {
+		return nil, err
+	}
 
 	return &client, nil
 }
 
+func baseURL(poller *conf.Poller) string {
+	if poller.IsKfs {
+		return "https://" + poller.Addr + ":8443/"
+	}
+	return "https://" + poller.Addr + "/"
+}
+
 func (c *Client) TraceLogSet(collectorName string, config *node.Node) {
 	// check for log sets and enable Rest request logging if collectorName is in the set
 	if llogs := config.GetChildS("log"); llogs != nil {
@@ -116,7 +133,25 @@ func (c *Client) printRequestAndResponse(req string, response []byte) {
 
 // GetRest makes a REST request to the cluster and returns a json response as a []byte
 func (c *Client) GetRest(request string) ([]byte, error) {
+	return c.invokeRest(context.Background(), http.MethodGet, request, nil)
+}
+
+// GetRestContext is GetRest with a context. The request fails when the context is done
+func (c *Client) GetRestContext(ctx context.Context, request string) ([]byte, error) {
+	return c.invokeRest(ctx, http.MethodGet, request, nil)
+}
+
+// PostRest makes a REST POST request with a json body to the cluster and returns a json response as a []byte.
+// Harvest only uses POST for read-only requests, e.g. to run a show command with the CLI passthrough
+func (c *Client) PostRest(request string, body []byte) ([]byte, error) {
+	return c.invokeRest(context.Background(), http.MethodPost, request, body)
+}
+
+func (c *Client) invokeRest(ctx context.Context, method string, request string, body []byte) ([]byte, error) {
 	var err error
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
 	if strings.Index(request, "/") == 0 {
 		request = request[1:]
 	}
@@ -125,11 +160,22 @@ func (c *Client) GetRest(request string) ([]byte, error) {
 		return nil, err
 	}
 	u := c.baseURL + request
-	c.request, err = requests.New("GET", u, nil)
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	c.request, err = requests.New(method, u, reqBody)
 	if err != nil {
 		return nil, err
 	}
+	c.request = c.request.WithContext(ctx)
 	c.request.Header.Set("Accept", "application/json")
+	// setting Accept-Encoding turns off the transparent decompression of the transport, so the client can count
+	// the bytes received before decompression
+	c.request.Header.Set("Accept-Encoding", AcceptEncoding)
+	if body != nil {
+		c.request.Header.Set("Content-Type", "application/json")
+	}
 	pollerAuth, err := c.auth.GetPollerAuth()
 	if err != nil {
 		return nil, err
@@ -142,14 +188,24 @@ func (c *Client) GetRest(request string) ([]byte, error) {
 	}
 
 	// ensure that we can change body dynamically
-	c.request.GetBody = func() (io.ReadCloser, error) {
-		r := bytes.NewReader(c.buffer.Bytes())
-		return io.NopCloser(r), nil
+	if body != nil {
+		c.request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else {
+		c.request.GetBody = func() (io.ReadCloser, error) {
+			r := bytes.NewReader(c.buffer.Bytes())
+			return io.NopCloser(r), nil
+		}
 	}
 
+	start := time.Now()
 	result, err := c.invokeWithAuthRetry()
 	c.Metadata.BytesRx += uint64(len(result))
 	c.Metadata.NumCalls++
+	if c.Metadata.Budget != nil {
+		c.Metadata.Budget.Record(time.Since(start), ErrorClass(err))
+	}
 
 	return result, err
 }
@@ -167,10 +223,6 @@ func (c *Client) invokeWithAuthRetry() ([]byte, error) {
 			innerErr  error
 		)
 
-		if c.request.Body != nil {
-			//goland:noinspection GoUnhandledErrorResult
-			defer response.Body.Close()
-		}
 		if c.buffer != nil {
 			defer c.buffer.Reset()
 		}
@@ -180,11 +232,17 @@ func (c *Client) invokeWithAuthRetry() ([]byte, error) {
 
 		// send request to server
 		if response, innerErr = c.client.Do(c.request); innerErr != nil {
+			// the cluster is reachable, but the request did not complete before the deadline of the context
+			if ctx := c.request.Context(); ctx.Err() != nil {
+				return nil, fmt.Errorf("%w: %w", context.Cause(ctx), innerErr)
+			}
 			return nil, fmt.Errorf("connection error %w", innerErr)
 		}
 		//goland:noinspection GoUnhandledErrorResult
 		defer response.Body.Close()
-		innerBody, innerErr = io.ReadAll(response.Body)
+		var wireBytes uint64
+		innerBody, wireBytes, innerErr = readBody(response)
+		c.Metadata.BytesRxWire += wireBytes
 		if innerErr != nil {
 			return nil, errs.NewRest().
 				StatusCode(response.StatusCode).
@@ -241,6 +299,18 @@ func (c *Client) invokeWithAuthRetry() ([]byte, error) {
 
 	body, err = doInvoke()
 
+	// rewind the request body, if any, so the request can be retried
+	retry := func() ([]byte, error) {
+		if c.request.Body != nil && c.request.GetBody != nil {
+			b, err := c.request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			c.request.Body = b
+		}
+		return doInvoke()
+	}
+
 	if err != nil {
 		var he errs.HarvestError
 		if errors.As(err, &he) {
@@ -263,10 +333,10 @@ func (c *Client) invokeWithAuthRetry() ([]byte, error) {
 						c.token = pollerAuth.AuthToken
 						c.request.Header.Set("Authorization", "Bearer "+c.token)
 						c.Logger.Debug().Msg("Using authToken from credential script")
-						return doInvoke()
+						return retry()
 					}
 					c.request.SetBasicAuth(pollerAuth2.Username, pollerAuth2.Password)
-					return doInvoke()
+					return retry()
 				}
 			}
 		}
@@ -357,6 +427,11 @@ func (c *Client) Cluster() Cluster {
 	return c.cluster
 }
 
+// Schemas returns the cache of counter schemas of the poller, nil when the poller has no counter_cache
+func (c *Client) Schemas() *SchemaCache {
+	return c.schemas
+}
+
 func (cl Cluster) GetVersion() string {
 	ver := cl.Version
 	return fmt.Sprintf("%d.%d.%d", ver[0], ver[1], ver[2])
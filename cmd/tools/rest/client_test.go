@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/testutil"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newFakeClient(t *testing.T, dir string) (*testutil.ONTAP, *Client) {
+	t.Helper()
+	fake, poller := testutil.StartONTAP(t, dir)
+	client, err := New(poller, 5*time.Second, auth.NewCredentials(poller, logging.Get()))
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	return fake, client
+}
+
+func TestClient_Init(t *testing.T) {
+	fake, client := newFakeClient(t, t.TempDir())
+	fake.Version = [3]int{9, 15, 1}
+	fake.AddFault(testutil.Fault{Path: "api/cluster", Status: http.StatusServiceUnavailable, Times: 1})
+
+	if err := client.Init(2); err != nil {
+		t.Fatalf("Init() err=%v", err)
+	}
+	if got := client.Cluster().GetVersion(); got != "9.15.1" {
+		t.Errorf("version got=%s, want=9.15.1", got)
+	}
+	if got := fake.Calls("api/cluster"); got != 2 {
+		t.Errorf("calls got=%d, want=2", got)
+	}
+}
+
+func TestClient_ErrorClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault testutil.Fault
+		pass  string
+		want  string
+	}{
+		{name: "ok", want: util.ClassOK},
+		{name: "server error", fault: testutil.Fault{Status: http.StatusServiceUnavailable}, want: util.ClassServerError},
+		{name: "not found", fault: testutil.Fault{Status: http.StatusNotFound}, want: util.ClassClientError},
+		{name: "timeout", fault: testutil.Fault{Latency: time.Second}, want: util.ClassTimeout},
+		{name: "auth failed", pass: "wrong", want: util.ClassAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeClient(t, t.TempDir())
+			if tt.fault != (testutil.Fault{}) {
+				fake.AddFault(tt.fault)
+			}
+			if tt.pass != "" {
+				fake.Password = tt.pass
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err := client.GetRestContext(ctx, "api/cluster")
+			if got := ErrorClass(err); got != tt.want {
+				t.Errorf("ErrorClass got=%s, want=%s err=%v", got, tt.want, err)
+			}
+			if tt.pass != "" && !errors.Is(err, errs.ErrAuthFailed) {
+				t.Errorf("err got=%v, want=%v", err, errs.ErrAuthFailed)
+			}
+		})
+	}
+}
+
+func TestFetchPages_Fake(t *testing.T) {
+	dir := t.TempDir()
+	recording := `{"records":[{"name":"vol1"},{"name":"vol2"},{"name":"vol3"},{"name":"vol4"},{"name":"vol5"}],"num_records":5}`
+	if err := os.WriteFile(filepath.Join(dir, "api-storage-volumes.json"), []byte(recording), 0600); err != nil {
+		t.Fatalf("failed to write recording err=%v", err)
+	}
+	fake, client := newFakeClient(t, dir)
+
+	records, next, err := FetchPages(context.Background(), client, "api/storage/volumes?fields=name&max_records=2", 10)
+	if err != nil {
+		t.Fatalf("FetchPages() err=%v", err)
+	}
+	if len(records) != 5 || next != "" {
+		t.Errorf("records got=%d next=%s, want=5 and no next", len(records), next)
+	}
+	if got := fake.Calls("api/storage/volumes"); got != 3 {
+		t.Errorf("calls got=%d, want=3", got)
+	}
+}
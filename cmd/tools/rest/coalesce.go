@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"context"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Coalescer merges the concurrent fetches of the clients of a poller that query the same endpoint.
+// A fetch joins a fetch in flight with the same path and query, and fields that include its fields,
+// and shares its records instead of querying the cluster again.
+// Records of a joined fetch may have more fields than the fetch asked for.
+//
+// A shared fetch runs with a context that is detached from the fetch that started it, so a caller that gives up
+// does not fail the others. A fetch only joins a fetch whose deadline is not before its own, so the shared fetch is
+// bounded by the deadline of the longest waiter, and the shared fetch is canceled once no caller waits for it
+type Coalescer struct {
+	mu       sync.Mutex
+	inflight map[string][]*call // calls in flight, by endpoint
+	shared   uint64             // fetches that shared the records of another fetch
+}
+
+type call struct {
+	fields   []string // sorted fields of the call, nil when the href has no fields
+	deadline time.Time
+	waiters  int // callers waiting for the records of the call
+	cancel   context.CancelFunc
+	done     chan struct{}
+	records  []gjson.Result
+	err      error
+}
+
+var (
+	coalescersMu sync.Mutex
+	coalescers   = make(map[string]*Coalescer)
+)
+
+// coalescerFor returns the Coalescer shared by the clients of baseURL
+func coalescerFor(baseURL string) *Coalescer {
+	coalescersMu.Lock()
+	defer coalescersMu.Unlock()
+	c, ok := coalescers[baseURL]
+	if !ok {
+		c = NewCoalescer()
+		coalescers[baseURL] = c
+	}
+	return c
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{inflight: make(map[string][]*call)}
+}
+
+// Do returns the records of href, fetched with fetch, or shared with a compatible fetch in flight.
+// Do returns the error of ctx when ctx is done before the records are fetched
+func (c *Coalescer) Do(ctx context.Context, href string, fetch func(ctx context.Context) ([]gjson.Result, error)) ([]gjson.Result, error) {
+	endpoint, fields := splitFields(href)
+	deadline, _ := ctx.Deadline()
+
+	c.mu.Lock()
+	for _, other := range c.inflight[endpoint] {
+		if covers(other.fields, fields) && outlasts(other.deadline, deadline) {
+			c.shared++
+			other.waiters++
+			c.mu.Unlock()
+			return c.wait(ctx, endpoint, other)
+		}
+	}
+	var (
+		fetchCtx context.Context
+		cancel   context.CancelFunc
+	)
+	if deadline.IsZero() {
+		fetchCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	} else {
+		fetchCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	}
+	cl := &call{fields: fields, deadline: deadline, waiters: 1, cancel: cancel, done: make(chan struct{})}
+	c.inflight[endpoint] = append(c.inflight[endpoint], cl)
+	c.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		cl.records, cl.err = fetch(fetchCtx)
+		c.mu.Lock()
+		c.remove(endpoint, cl)
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	return c.wait(ctx, endpoint, cl)
+}
+
+// wait returns the records of cl, or the error of ctx when ctx is done first.
+// The fetch of cl is canceled when its last waiter gives up
+func (c *Coalescer) wait(ctx context.Context, endpoint string, cl *call) ([]gjson.Result, error) {
+	select {
+	case <-cl.done:
+		return slices.Clone(cl.records), cl.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 {
+			c.remove(endpoint, cl)
+			cl.cancel()
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// remove removes cl from the calls in flight, so later fetches do not join it. c.mu must be held
+func (c *Coalescer) remove(endpoint string, cl *call) {
+	calls := slices.DeleteFunc(c.inflight[endpoint], func(o *call) bool { return o == cl })
+	if len(calls) == 0 {
+		delete(c.inflight, endpoint)
+	} else {
+		c.inflight[endpoint] = calls
+	}
+}
+
+// outlasts returns true when a call with deadline runs at least until want. A zero deadline has no limit
+func outlasts(deadline time.Time, want time.Time) bool {
+	if deadline.IsZero() {
+		return true
+	}
+	return !want.IsZero() && !want.After(deadline)
+}
+
+// Shared returns the number of fetches that shared the records of another fetch
+func (c *Coalescer) Shared() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shared
+}
+
+// splitFields returns the path and query of href without its fields, with sorted parameters, and its sorted fields
+func splitFields(href string) (string, []string) {
+	path, rawQuery, _ := strings.Cut(href, "?")
+	path = strings.TrimPrefix(path, "/")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// not comparable with other hrefs, only with itself
+		return href, nil
+	}
+	var fields []string
+	for _, f := range query["fields"] {
+		for _, field := range strings.Split(f, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	query.Del("fields")
+	slices.Sort(fields)
+	fields = slices.Compact(fields)
+	// Encode sorts the parameters
+	return path + "?" + query.Encode(), fields
+}
+
+// covers returns true when a call with fields returns the fields of want.
+// A call without fields only covers calls without fields, and ** covers every field
+func covers(fields []string, want []string) bool {
+	if len(fields) == 0 || len(want) == 0 {
+		return len(fields) == len(want)
+	}
+	if slices.Contains(fields, "**") {
+		return true
+	}
+	for _, w := range want {
+		if _, found := slices.BinarySearch(fields, w); !found {
+			return false
+		}
+	}
+	return true
+}
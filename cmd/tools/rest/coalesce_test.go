@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"github.com/tidwall/gjson"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		name string
+		href string
+		want string
+		ok   bool
+	}{
+		{name: "identical", href: "api/storage/volumes?fields=name,svm.name", want: "api/storage/volumes?fields=svm.name,name", ok: true},
+		{name: "subset", href: "api/storage/volumes?fields=name,svm.name,space", want: "api/storage/volumes?fields=name", ok: true},
+		{name: "superset", href: "api/storage/volumes?fields=name", want: "api/storage/volumes?fields=name,space"},
+		{name: "all fields", href: "api/storage/volumes?fields=**", want: "api/storage/volumes?fields=name", ok: true},
+		{name: "no fields", href: "api/storage/volumes", want: "api/storage/volumes", ok: true},
+		{name: "other query", href: "api/storage/volumes?fields=name&is_constituent=true", want: "api/storage/volumes?fields=name"},
+		{name: "reordered query", href: "api/storage/volumes?return_records=true&fields=name&max_records=100",
+			want: "api/storage/volumes?max_records=100&fields=name&return_records=true", ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, fields := splitFields(tt.href)
+			wantEndpoint, wantFields := splitFields(tt.want)
+			if got := endpoint == wantEndpoint && covers(fields, wantFields); got != tt.ok {
+				t.Errorf("covers() got=%v, want=%v", got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCoalescer(t *testing.T) {
+	c := NewCoalescer()
+	var fetches atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]gjson.Result, error) {
+		fetches.Add(1)
+		close(started)
+		<-release
+		return gjson.Parse(`[{"name":"vol1","space":1}]`).Array(), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]gjson.Result, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = c.Do(context.Background(), "api/storage/volumes?fields=name,space", fetch)
+	}()
+	<-started
+	for i, href := range []string{"api/storage/volumes?fields=name", "api/storage/volumes?fields=space,name"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i+1], _ = c.Do(context.Background(), href, func(context.Context) ([]gjson.Result, error) {
+				fetches.Add(1)
+				return nil, nil
+			})
+		}()
+	}
+	// wait until both fetches joined the first one
+	for c.Shared() < 2 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetches got=%d, want=1", got)
+	}
+	for i, r := range results {
+		if len(r) != 1 || r[0].Get("name").String() != "vol1" {
+			t.Errorf("results[%d] got=%v", i, r)
+		}
+	}
+
+	// the fetch is done, the next one queries the cluster
+	_, _ = c.Do(context.Background(), "api/storage/volumes?fields=name", func(context.Context) ([]gjson.Result, error) {
+		fetches.Add(1)
+		return nil, nil
+	})
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("fetches after done got=%d, want=2", got)
+	}
+}
+
+func TestCoalescerContext(t *testing.T) {
+	c := NewCoalescer()
+	href := "api/storage/volumes?fields=name"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetchErr := make(chan error, 1)
+	fetch := func(ctx context.Context) ([]gjson.Result, error) {
+		close(started)
+		select {
+		case <-release:
+			return gjson.Parse(`[{"name":"vol1"}]`).Array(), nil
+		case <-ctx.Done():
+			fetchErr <- ctx.Err()
+			return nil, ctx.Err()
+		}
+	}
+
+	// the caller that started the fetch gives up, the fetch continues for the caller that joined it
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Do(leaderCtx, href, fetch)
+		leaderErr <- err
+	}()
+	<-started
+	joined := make(chan []gjson.Result, 1)
+	go func() {
+		records, _ := c.Do(context.Background(), href, nil)
+		joined <- records
+	}()
+	for c.Shared() < 1 {
+		runtime.Gosched()
+	}
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() of the canceled caller got err=%v, want=%v", err, context.Canceled)
+	}
+	close(release)
+	if records := <-joined; len(records) != 1 {
+		t.Errorf("Do() of the joined caller got=%v, want the records of the shared fetch", records)
+	}
+
+	// a caller that joined gives up without waiting for the fetch, which is canceled once no caller waits
+	started = make(chan struct{})
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _ = c.Do(ctx, href, fetch)
+	}()
+	<-started
+	joinCtx, cancelJoin := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelJoin()
+	if _, err := c.Do(joinCtx, href, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() of the joined caller got err=%v, want=%v", err, context.DeadlineExceeded)
+	}
+	cancel()
+	if err := <-fetchErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("fetch got err=%v, want=%v once no caller waits", err, context.Canceled)
+	}
+}
+
+func TestCoalescerDeadline(t *testing.T) {
+	c := NewCoalescer()
+	href := "api/storage/volumes?fields=name"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func(ctx context.Context) ([]gjson.Result, error) {
+		if fetches.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_, _ = c.Do(ctx, href, fetch)
+		close(done)
+	}()
+	<-started
+
+	// a fetch with a later deadline, or without deadline, does not join a fetch that may stop before it
+	later, cancelLater := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLater()
+	_, _ = c.Do(later, href, fetch)
+	_, _ = c.Do(context.Background(), href, fetch)
+	if got := fetches.Load(); got != 3 || c.Shared() != 0 {
+		t.Errorf("fetches got=%d shared=%d, want=3 fetches and none shared", got, c.Shared())
+	}
+	close(release)
+	<-done
+}
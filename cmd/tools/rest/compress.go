@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AcceptEncoding are the encodings the client accepts. ONTAP compresses large responses, e.g. of perf counters, well
+const AcceptEncoding = "gzip, deflate"
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n) //nolint:gosec
+	return n, err
+}
+
+// readBody reads the body of response, decompressed while it is read, and returns it with the number of bytes
+// received before decompression
+func readBody(response *http.Response) ([]byte, uint64, error) {
+	wire := &countingReader{r: response.Body}
+	var (
+		reader io.Reader = wire
+		err    error
+	)
+	switch encoding := strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		reader, err = gzip.NewReader(wire)
+	case "deflate":
+		reader, err = zlib.NewReader(wire)
+	default:
+		return nil, wire.n, fmt.Errorf("unsupported content encoding %s", encoding)
+	}
+	if errors.Is(err, io.EOF) {
+		// an empty body has no compression header
+		return []byte{}, wire.n, nil
+	}
+	if err != nil {
+		return nil, wire.n, err
+	}
+	body, err := io.ReadAll(reader)
+	return body, wire.n, err
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	records := `{"records":[` + strings.Repeat(`{"name":"vol1","svm":{"name":"svm1"}},`, 100) + `{}]}`
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte(records))
+	_ = gw.Close()
+
+	var deflate bytes.Buffer
+	zw := zlib.NewWriter(&deflate)
+	_, _ = zw.Write([]byte(records))
+	_ = zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+		wantWire int
+		wantErr  bool
+	}{
+		{name: "identity", body: []byte(records), want: records, wantWire: len(records)},
+		{name: "gzip", encoding: "gzip", body: gz.Bytes(), want: records, wantWire: gz.Len()},
+		{name: "deflate", encoding: "Deflate", body: deflate.Bytes(), want: records, wantWire: deflate.Len()},
+		{name: "empty gzip", encoding: "gzip", body: nil, want: ""},
+		{name: "unsupported", encoding: "br", body: []byte("x"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{
+				Header: http.Header{},
+				Body:   io.NopCloser(bytes.NewReader(tt.body)),
+			}
+			if tt.encoding != "" {
+				response.Header.Set("Content-Encoding", tt.encoding)
+			}
+			body, wire, err := readBody(response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readBody() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(body) != tt.want {
+				t.Errorf("readBody() body got=%d bytes, want=%d bytes", len(body), len(tt.want))
+			}
+			if int(wire) != tt.wantWire {
+				t.Errorf("readBody() wire got=%d, want=%d", wire, tt.wantWire)
+			}
+		})
+	}
+	if gz.Len() >= len(records) {
+		t.Errorf("gzip got=%d bytes, want less than %d", gz.Len(), len(records))
+	}
+}
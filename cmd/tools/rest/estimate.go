@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/tidwall/gjson"
+	"strings"
+)
+
+// CountRecords returns the number of records of href, without fetching them
+func CountRecords(client *Client, href string) (int, error) {
+	href = withArg(withArg(href, "return_records", "false"), "max_records", "")
+	response, err := client.GetRest(href)
+	if err != nil {
+		return 0, fmt.Errorf("error making request %w", err)
+	}
+	numRecords := gjson.GetBytes(response, "num_records")
+	if !numRecords.Exists() {
+		return 0, fmt.Errorf("no num_records in the response of %s", href)
+	}
+	return int(numRecords.Int()), nil
+}
+
+// SampleRecord returns the first record of href, and its size in bytes, as ONTAP returns it.
+// The record does not exist when href has no records
+func SampleRecord(client *Client, href string) (gjson.Result, int, error) {
+	href = withArg(href, "max_records", "1")
+	response, err := client.GetRest(href)
+	if err != nil {
+		return gjson.Result{}, 0, fmt.Errorf("error making request %w", err)
+	}
+	record := gjson.GetBytes(response, "records.0")
+	return record, len(record.Raw), nil
+}
+
+// withArg returns href with the query argument name set to value, or without it when value is empty
+func withArg(href string, name string, value string) string {
+	path, query, _ := strings.Cut(href, "?")
+	args := strings.Split(query, "&")
+	result := make([]string, 0, len(args)+1)
+	for _, arg := range args {
+		if arg == "" || strings.HasPrefix(arg, name+"=") {
+			continue
+		}
+		result = append(result, arg)
+	}
+	if value != "" {
+		result = append(result, name+"="+value)
+	}
+	if len(result) == 0 {
+		return path
+	}
+	return path + "?" + strings.Join(result, "&")
+}
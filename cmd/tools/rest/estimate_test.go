@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"testing"
+)
+
+func TestWithArg(t *testing.T) {
+	tests := []struct {
+		name  string
+		href  string
+		arg   string
+		value string
+		want  string
+	}{
+		{name: "replace", href: "api/storage/volumes?return_records=true&fields=name", arg: "return_records", value: "false",
+			want: "api/storage/volumes?fields=name&return_records=false"},
+		{name: "add", href: "api/storage/volumes?return_records=true", arg: "max_records", value: "1",
+			want: "api/storage/volumes?return_records=true&max_records=1"},
+		{name: "remove", href: "api/storage/volumes?return_records=true&max_records=100", arg: "max_records", value: "",
+			want: "api/storage/volumes?return_records=true"},
+		{name: "prefix of another arg", href: "api/storage/volumes?max_records_x=1", arg: "max_records", value: "2",
+			want: "api/storage/volumes?max_records_x=1&max_records=2"},
+		{name: "no query", href: "api/storage/volumes", arg: "max_records", value: "",
+			want: "api/storage/volumes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withArg(tt.href, tt.arg, tt.value); got != tt.want {
+				t.Errorf("withArg() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -395,8 +396,24 @@ func FetchForCli(client *Client, href string, records *[]any, downloadAll bool,
 	return nil
 }
 
-// Fetch collects all records
+// Fetch collects all records. When the client coalesces requests, concurrent fetches of the same records are shared
 func Fetch(client *Client, href string) ([]gjson.Result, error) {
+	return FetchContext(context.Background(), client, href)
+}
+
+// FetchContext is Fetch with a context. When the context has a deadline, the return_timeout of each request is
+// lowered so that ONTAP returns before the deadline, see AdaptReturnTimeout.
+// A coalesced fetch runs with a context of its own, see Coalescer
+func FetchContext(ctx context.Context, client *Client, href string) ([]gjson.Result, error) {
+	if client.coalescer != nil {
+		return client.coalescer.Do(ctx, client.baseURL+href, func(ctx context.Context) ([]gjson.Result, error) {
+			return fetchAll(ctx, client, href)
+		})
+	}
+	return fetchAll(ctx, client, href)
+}
+
+func fetchAll(ctx context.Context, client *Client, href string) ([]gjson.Result, error) {
 	var (
 		records []gjson.Result
 		result  []gjson.Result
@@ -418,7 +435,7 @@ func Fetch(client *Client, href string) ([]gjson.Result, error) {
 			downloadAll = maxRecords == 0
 		}
 	}
-	err = fetch(client, href, &records, downloadAll, int64(maxRecords))
+	err = fetch(ctx, client, href, &records, downloadAll, int64(maxRecords))
 	if err != nil {
 		return nil, err
 	}
@@ -466,8 +483,8 @@ func FetchAnalytics(client *Client, href string) ([]gjson.Result, gjson.Result,
 	return result, *analytics, nil
 }
 
-func fetch(client *Client, href string, records *[]gjson.Result, downloadAll bool, maxRecords int64) error {
-	getRest, err := client.GetRest(href)
+func fetch(ctx context.Context, client *Client, href string, records *[]gjson.Result, downloadAll bool, maxRecords int64) error {
+	getRest, err := client.GetRestContext(ctx, AdaptReturnTimeout(ctx, href))
 	if err != nil {
 		return fmt.Errorf("error making request %w", err)
 	}
@@ -505,7 +522,7 @@ func fetch(client *Client, href string, records *[]gjson.Result, downloadAll boo
 					// nextLink is same as previous link, no progress is being made, exit
 					return nil
 				}
-				err := fetch(client, nextLink, records, downloadAll, maxRecords)
+				err := fetch(ctx, client, nextLink, records, downloadAll, maxRecords)
 				if err != nil {
 					return err
 				}
@@ -556,9 +573,36 @@ func fetchAnalytics(client *Client, href string, records *[]gjson.Result, analyt
 	return nil
 }
 
+// FetchPages collects the records of href and of its next pages, until at least maxRecords records are collected.
+// It returns the link of the next page, empty when all records are collected. On error, it returns the records
+// collected so far and the link of the page that failed, so that the caller can resume from it
+func FetchPages(ctx context.Context, client *Client, href string, maxRecords int) ([]gjson.Result, string, error) {
+	var result []gjson.Result
+	for href != "" && len(result) < maxRecords {
+		getRest, err := client.GetRestContext(ctx, AdaptReturnTimeout(ctx, href))
+		if err != nil {
+			return result, href, fmt.Errorf("error making request %w", err)
+		}
+		output := gjson.ParseBytes(getRest)
+		result = append(result, output.Get("records").Array()...)
+		next := strings.Clone(output.Get("_links.next.href").String())
+		if next == href {
+			// nextLink is same as previous link, no progress is being made, exit
+			next = ""
+		}
+		href = next
+	}
+	return result, href, nil
+}
+
 // FetchRestPerfData This method is used in PerfRest collector. This method returns timestamp per batch
 func FetchRestPerfData(client *Client, href string, perfRecords *[]PerfRecord) error {
-	getRest, err := client.GetRest(href)
+	return FetchRestPerfDataContext(context.Background(), client, href, perfRecords)
+}
+
+// FetchRestPerfDataContext is FetchRestPerfData with a context, see FetchContext
+func FetchRestPerfDataContext(ctx context.Context, client *Client, href string, perfRecords *[]PerfRecord) error {
+	getRest, err := client.GetRestContext(ctx, AdaptReturnTimeout(ctx, href))
 	if err != nil {
 		return fmt.Errorf("error making request %w", err)
 	}
@@ -582,7 +626,7 @@ func FetchRestPerfData(client *Client, href string, perfRecords *[]PerfRecord) e
 				// nextLink is same as previous link, no progress is being made, exit
 				return nil
 			}
-			err := FetchRestPerfData(client, strings.Clone(next.String()), perfRecords)
+			err := FetchRestPerfDataContext(ctx, client, strings.Clone(next.String()), perfRecords)
 			if err != nil {
 				return err
 			}
@@ -591,6 +635,37 @@ func FetchRestPerfData(client *Client, href string, perfRecords *[]PerfRecord) e
 	return nil
 }
 
+// AdaptReturnTimeout lowers the return_timeout of href, so that ONTAP returns a page of records before the deadline
+// of ctx. ONTAP returns the records it has when return_timeout expires, with a link to the next page.
+// Half of the time left is given to ONTAP, the other half is left to transfer the page and fetch the next pages.
+// href is unchanged when ctx has no deadline, or when its return_timeout is already lower
+func AdaptReturnTimeout(ctx context.Context, href string) string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return href
+	}
+	adapted := max(int(time.Until(deadline).Seconds()/2), MinReturnTimeout)
+
+	current := DefaultReturnTimeout
+	if v, err := util.GetQueryParam(href, "return_timeout"); err == nil && v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			current = n
+		}
+	}
+	if adapted >= current {
+		return href
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	query := u.Query()
+	query.Set("return_timeout", strconv.Itoa(adapted))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 func stderr(format string, a ...any) {
 	_, _ = fmt.Fprintf(os.Stderr, format, a...)
 }
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"context"
+	"github.com/netapp/harvest/v2/pkg/util"
+	"testing"
+	"time"
+)
+
+func TestAdaptReturnTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		href string
+		left time.Duration // time left until the deadline, none when zero
+		want string        // return_timeout of the adapted href
+	}{
+		{name: "no deadline", href: "api/storage/volumes?fields=name&return_timeout=30", want: "30"},
+		{name: "time enough", href: "api/storage/volumes?fields=name&return_timeout=30", left: 2 * time.Minute, want: "30"},
+		{name: "lowered", href: "api/storage/volumes?fields=name&return_timeout=30", left: 20 * time.Second, want: "9"},
+		{name: "lowered from default", href: "api/storage/volumes?fields=name", left: 10 * time.Second, want: "4"},
+		{name: "default kept", href: "api/storage/volumes?fields=name", left: time.Minute, want: ""},
+		{name: "at least minimum", href: "api/storage/volumes?fields=name", left: time.Second, want: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.left > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.left)
+				defer cancel()
+			}
+			href := AdaptReturnTimeout(ctx, tt.href)
+			got, err := util.GetQueryParam(href, "return_timeout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("return_timeout got=%s want=%s, href=%s", got, tt.want, href)
+			}
+			if fields, _ := util.GetQueryParam(href, "fields"); fields != "name" {
+				t.Errorf("fields got=%s want=name, href=%s", fields, href)
+			}
+		})
+	}
+}
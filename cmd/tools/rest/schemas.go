@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/tidwall/gjson"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSchemaMaxAge is how long cached counter schemas are used, when counter_cache has no max_age
+const DefaultSchemaMaxAge = 24 * time.Hour
+
+// SchemaCache stores the counter schemas of the counter tables of clusters on disk, so the pollers of a host that
+// monitor the same cluster fetch them once per ONTAP version, instead of each poller fetching them when it starts.
+// Schemas are keyed by the UUID and version of the cluster, an upgrade fetches them again
+type SchemaCache struct {
+	dir    string
+	maxAge time.Duration
+}
+
+// NewSchemaCache returns the SchemaCache of counter_cache, or nil when c is nil
+func NewSchemaCache(c *conf.CounterCache) (*SchemaCache, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.Path == "" {
+		return nil, fmt.Errorf("counter_cache path is empty")
+	}
+	s := &SchemaCache{dir: conf.Path(c.Path), maxAge: DefaultSchemaMaxAge}
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid counter_cache max_age %q, must be a positive duration like 24h", c.MaxAge)
+		}
+		s.maxAge = d
+	}
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create counter_cache %s: %w", s.dir, err)
+	}
+	return s, nil
+}
+
+// path returns the file of the schemas of table, the href of a counter table, of cluster
+func (s *SchemaCache) path(cluster Cluster, table string) string {
+	h := sha256.Sum256([]byte(cluster.Info + "\n" + table))
+	return filepath.Join(s.dir, cluster.UUID+"_"+cluster.GetVersion()+"_"+hex.EncodeToString(h[:8])+".json")
+}
+
+// Get returns the cached records of table, ok is false when they are not cached or older than max_age
+func (s *SchemaCache) Get(cluster Cluster, table string) ([]gjson.Result, bool) {
+	if s == nil || cluster.UUID == "" {
+		return nil, false
+	}
+	path := s.path(cluster, table)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > s.maxAge {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	// a file that another poller is writing is renamed into place, a corrupt file is fetched again
+	if !gjson.ValidBytes(data) {
+		return nil, false
+	}
+	result := gjson.ParseBytes(data)
+	if !result.IsArray() {
+		return nil, false
+	}
+	records := result.Array()
+	if len(records) == 0 {
+		return nil, false
+	}
+	return records, true
+}
+
+// Put caches the records of table. The file is written to a temporary file and renamed, so pollers never read
+// a partial file
+func (s *SchemaCache) Put(cluster Cluster, table string, records []gjson.Result) error {
+	if s == nil || cluster.UUID == "" || len(records) == 0 {
+		return nil
+	}
+	raw := make([]string, 0, len(records))
+	for _, r := range records {
+		raw = append(raw, r.Raw)
+	}
+	f, err := os.CreateTemp(s.dir, ".schemas-*")
+	if err != nil {
+		return fmt.Errorf("failed to cache counter schemas: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("[" + strings.Join(raw, ",") + "]"); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to cache counter schemas: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to cache counter schemas: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(cluster, table)); err != nil {
+		return fmt.Errorf("failed to cache counter schemas: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/tidwall/gjson"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSchemaCache(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		cache   *conf.CounterCache
+		wantErr bool
+	}{
+		{name: "disabled", cache: nil},
+		{name: "default max_age", cache: &conf.CounterCache{Path: dir}},
+		{name: "max_age", cache: &conf.CounterCache{Path: filepath.Join(dir, "nested"), MaxAge: "1h"}},
+		{name: "no path", cache: &conf.CounterCache{}, wantErr: true},
+		{name: "invalid max_age", cache: &conf.CounterCache{Path: dir, MaxAge: "1 day"}, wantErr: true},
+		{name: "negative max_age", cache: &conf.CounterCache{Path: dir, MaxAge: "-1h"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSchemaCache(tt.cache)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSchemaCache() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if tt.cache == nil && s != nil {
+				t.Errorf("NewSchemaCache() of no counter_cache should return nil")
+			}
+		})
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	s, err := NewSchemaCache(&conf.CounterCache{Path: t.TempDir(), MaxAge: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := Cluster{UUID: "a1b2", Info: "NetApp Release 9.13.1", Version: [3]int{9, 13, 1}}
+	table := "api/cluster/counter/tables/volume"
+	records := gjson.Parse(`[{"name":"volume","counter_schemas":[{"name":"read_ops","type":"rate"}]}]`).Array()
+
+	if _, ok := s.Get(cluster, table); ok {
+		t.Errorf("Get() of an empty cache should miss")
+	}
+	if err := s.Put(cluster, table, records); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := s.Get(cluster, table)
+	if !ok {
+		t.Fatalf("Get() after Put() should hit")
+	}
+	if len(got) != 1 || got[0].Get("counter_schemas.0.name").String() != "read_ops" {
+		t.Errorf("Get() got=%v, want the records of Put()", got)
+	}
+
+	// other tables and versions are cached separately
+	if _, ok := s.Get(cluster, "api/cluster/counter/tables/lun"); ok {
+		t.Errorf("Get() of another table should miss")
+	}
+	upgraded := cluster
+	upgraded.Info = "NetApp Release 9.14.1"
+	upgraded.Version = [3]int{9, 14, 1}
+	if _, ok := s.Get(upgraded, table); ok {
+		t.Errorf("Get() after an upgrade should miss")
+	}
+
+	// expired and corrupt files are fetched again
+	path := s.path(cluster, table)
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get(cluster, table); ok {
+		t.Errorf("Get() of schemas older than max_age should miss")
+	}
+	if err := os.WriteFile(path, []byte(`[{"name":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get(cluster, table); ok {
+		t.Errorf("Get() of a corrupt file should miss")
+	}
+
+	// clusters without a UUID, e.g. in tests, and a nil cache are not cached
+	if err := s.Put(Cluster{}, table, records); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get(Cluster{}, table); ok {
+		t.Errorf("Get() of a cluster without UUID should miss")
+	}
+	var disabled *SchemaCache
+	if err := disabled.Put(cluster, table, records); err != nil {
+		t.Errorf("Put() of a nil cache err=%v", err)
+	}
+	if _, ok := disabled.Get(cluster, table); ok {
+		t.Errorf("Get() of a nil cache should miss")
+	}
+
+	// temporary files are removed
+	entries, _ := os.ReadDir(s.dir)
+	if len(entries) != 1 {
+		t.Errorf("cache got %d files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,76 @@
+package template
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/tools/template/golden"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var update bool
+
+var Cmd = &cobra.Command{
+	Use:   "template",
+	Short: "Template tools",
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test [flags] DIRECTORY...",
+	Short: "Test templates against recorded responses",
+	Long: `Test templates against recorded responses.
+Each directory with a ` + golden.CaseFile + ` is a case, directories are searched recursively.
+A case fails when the matrices its template produces from the recorded responses differ from ` + golden.GoldenFile + `.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  doTest,
+	Example: `
+# Test the cases of the template harness
+harvest template test cmd/tools/template/golden/testdata
+
+# Create or replace the golden file of a case after a template change
+harvest template test --update cmd/tools/template/golden/testdata/rest/lun`,
+}
+
+func init() {
+	Cmd.AddCommand(testCmd)
+	testCmd.Flags().BoolVar(&update, "update", false, "Replace the golden files with the snapshots of the cases")
+}
+
+func doTest(_ *cobra.Command, args []string) {
+	// the collectors log each template they load
+	logging.Get()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	dirs, err := golden.Find(args)
+	if err != nil {
+		fmt.Printf("test failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(dirs) == 0 {
+		fmt.Printf("no %s found in %v\n", golden.CaseFile, args)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, dir := range dirs {
+		c, err := golden.Load(dir)
+		if err == nil {
+			err = c.Check(update)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", dir, err)
+			continue
+		}
+		if update {
+			fmt.Printf("updated %s\n", dir)
+		} else {
+			fmt.Printf("ok %s\n", dir)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d cases failed\n", failed, len(dirs))
+		os.Exit(1)
+	}
+}
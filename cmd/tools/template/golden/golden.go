@@ -0,0 +1,265 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package golden tests templates against recorded responses.
+
+A case is a directory with a case.yaml, the responses the template's APIs recorded from a cluster,
+and golden.txt, the snapshot of the matrices the collector and its built-in plugins produce from those responses.
+A test polls the recorded responses with the template and fails when the snapshot differs from golden.txt,
+so a change to a template, or to the collector, that changes the exported data is caught.
+
+REST responses are named after the API path, e.g. api-storage-luns.json or api-storage-luns.json.gz for api/storage/luns,
+and ZAPI responses after the query, e.g. lun-get-iter.xml.
+Collector plugins call the cluster, so only the built-in plugins run, e.g. LabelAgent or MetricAgent.
+*/
+package golden
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/collectors/rest"
+	zapi "github.com/netapp/harvest/v2/cmd/collectors/zapi/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	CaseFile   = "case.yaml"
+	GoldenFile = "golden.txt"
+
+	// templates are copied to this version, which is the version of the test clients
+	templateVersion = "9.8.0"
+)
+
+// Case is a template test, read from the case.yaml of its directory
+type Case struct {
+	Dir       string `yaml:"-"`
+	Collector string `yaml:"collector"` // Rest or Zapi
+	Object    string `yaml:"object"`    // object of the template, e.g. Lun
+	Template  string `yaml:"template"`  // path of the template, relative to the directory of the case
+}
+
+// Load reads the case in dir
+func Load(dir string) (*Case, error) {
+	contents, err := os.ReadFile(filepath.Join(dir, CaseFile))
+	if err != nil {
+		return nil, err
+	}
+	c := &Case{Dir: dir}
+	if err := yaml.Unmarshal(contents, c); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CaseFile, err)
+	}
+	if c.Collector != "Rest" && c.Collector != "Zapi" {
+		return nil, errs.New(errs.ErrInvalidParam, "collector "+strconv.Quote(c.Collector)+" is not supported, expected Rest or Zapi")
+	}
+	if c.Object == "" {
+		return nil, errs.New(errs.ErrMissingParam, "object")
+	}
+	if c.Template == "" {
+		return nil, errs.New(errs.ErrMissingParam, "template")
+	}
+	return c, nil
+}
+
+// Find returns the directories of the cases in paths, searched recursively, sorted by name
+func Find(paths []string) ([]string, error) {
+	var dirs []string
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && d.Name() == CaseFile {
+				dirs = append(dirs, filepath.Dir(path))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs), nil
+}
+
+// Run polls the recorded responses of the case with its template and returns the snapshot of the matrices
+func (c *Case) Run() ([]byte, error) {
+	confPath, err := os.MkdirTemp("", "harvest-template-test")
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer os.RemoveAll(confPath)
+
+	// the collectors select templates by version, so the template is the only one in the conf path
+	model := ""
+	if c.Collector == "Zapi" {
+		model = "cdot"
+	}
+	name := filepath.Base(c.Template)
+	dir := filepath.Join(confPath, strings.ToLower(c.Collector), model, templateVersion)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	template, err := os.ReadFile(filepath.Join(c.Dir, c.Template))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), template, 0600); err != nil {
+		return nil, err
+	}
+
+	opts := options.New(options.WithConfPath(confPath))
+	opts.Poller = "template-test"
+	opts.IsTest = true
+	opts.Fixtures = c.Dir
+	ac := collector.New(c.Collector, c.Object, opts, collectors.Params(c.Object, name), nil)
+
+	var data map[string]*matrix.Matrix
+	switch c.Collector {
+	case "Rest":
+		r := &rest.Rest{}
+		if err := r.Init(ac); err != nil {
+			return nil, err
+		}
+		data, err = r.PollData()
+	default:
+		z := &zapi.Zapi{}
+		if err := z.Init(ac); err != nil {
+			return nil, err
+		}
+		data, err = z.PollData()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("poll %s: %w", c.Object, err)
+	}
+
+	results := make([]*matrix.Matrix, 0, len(data))
+	for _, m := range data {
+		results = append(results, m)
+	}
+	for _, plugins := range ac.Plugins {
+		for _, p := range plugins {
+			pluginData, _, err := p.Run(data)
+			if err != nil {
+				return nil, fmt.Errorf("plugin %s: %w", p.GetName(), err)
+			}
+			results = append(results, pluginData...)
+		}
+	}
+	return Snapshot(results), nil
+}
+
+// Check compares the snapshot of the case with its golden file, or replaces the golden file when update is true
+func (c *Case) Check(update bool) error {
+	got, err := c.Run()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.Dir, GoldenFile)
+	if update {
+		return os.WriteFile(path, got, 0600)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w, run with update to create it", err)
+	}
+	if diff := Diff(want, got); diff != "" {
+		return fmt.Errorf("snapshot differs from %s (-want +got):\n%s", path, diff)
+	}
+	return nil
+}
+
+// Snapshot renders the exportable instances and metrics of data, sorted, one label or value per line.
+// Metadata, like poll times, is not part of the snapshot since it changes between polls
+func Snapshot(data []*matrix.Matrix) []byte {
+	slices.SortStableFunc(data, func(a, b *matrix.Matrix) int {
+		return strings.Compare(a.UUID+"/"+a.Object, b.UUID+"/"+b.Object)
+	})
+
+	var b strings.Builder
+	for _, m := range data {
+		if !m.IsExportable() {
+			continue
+		}
+		b.WriteString("matrix " + m.UUID + " " + m.Object + "\n")
+		for _, name := range sortedKeys(m.GetGlobalLabels()) {
+			b.WriteString("  global " + name + "=" + strconv.Quote(m.GetGlobalLabels()[name]) + "\n")
+		}
+
+		metrics := make([]*matrix.Metric, 0, len(m.GetMetrics()))
+		for _, metric := range m.GetMetrics() {
+			if metric.IsExportable() {
+				metrics = append(metrics, metric)
+			}
+		}
+		slices.SortFunc(metrics, func(a, b *matrix.Metric) int {
+			return strings.Compare(a.GetName(), b.GetName())
+		})
+		instances := m.GetInstances()
+		for _, key := range sortedKeys(instances) {
+			instance := instances[key]
+			if !instance.IsExportable() {
+				continue
+			}
+			b.WriteString("  instance " + strconv.Quote(key) + "\n")
+			labels := instance.GetLabels()
+			for _, name := range sortedKeys(labels) {
+				b.WriteString("    label " + name + "=" + strconv.Quote(labels[name]) + "\n")
+			}
+			for _, metric := range metrics {
+				if value, ok := metric.GetValueFloat64(instance); ok {
+					b.WriteString("    metric " + metric.GetName() + "=" + strconv.FormatFloat(value, 'f', -1, 64) + "\n")
+				}
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// Diff returns the lines of want that are not in got, prefixed with -, and the lines of got that are not in want,
+// prefixed with +, or an empty string when they are equal
+func Diff(want []byte, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+
+	count := make(map[string]int)
+	for _, l := range gotLines {
+		count[l]++
+	}
+	var b strings.Builder
+	for _, l := range wantLines {
+		if count[l] > 0 {
+			count[l]--
+			continue
+		}
+		b.WriteString("-" + l + "\n")
+	}
+	for _, l := range gotLines {
+		if count[l] > 0 {
+			count[l]--
+			b.WriteString("+" + l + "\n")
+		}
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
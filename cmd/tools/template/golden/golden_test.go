@@ -0,0 +1,67 @@
+package golden
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "replace the golden files with the snapshots of the cases")
+
+func TestCases(t *testing.T) {
+	dirs, err := Find([]string{"testdata"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no cases in testdata")
+	}
+	for _, dir := range dirs {
+		t.Run(dir, func(t *testing.T) {
+			c, err := Load(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Check(*update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{name: "equal", want: "a\nb\n", got: "a\nb\n", diff: ""},
+		{name: "changed", want: "a\nb=1\n", got: "a\nb=2\n", diff: "-b=1\n+b=2\n"},
+		{name: "added", want: "a\n", got: "a\nc\n", diff: "+c\n"},
+		{name: "duplicate removed", want: "a\na\n", got: "a\n", diff: "-a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff([]byte(tt.want), []byte(tt.got)); got != tt.diff {
+				t.Errorf("Diff() got=%q, want=%q", got, tt.diff)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Load(dir)
+	if err == nil {
+		t.Fatal("Load() expected an error for a directory without a case")
+	}
+
+	c, err := Load("testdata/rest/lun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Collector != "Rest" || c.Object != "Lun" || !strings.HasSuffix(c.Template, "lun.yaml") {
+		t.Errorf("Load() got=%+v", c)
+	}
+}
@@ -345,11 +345,14 @@ func findCustomPlugins(path string, template *node.Node, model *Model) error {
 		return nil
 	}
 	builtIn := map[string]bool{
-		"LabelAgent":  true,
-		"MetricAgent": true,
-		"Aggregator":  true,
-		"Max":         true,
-		"Tenant":      true,
+		"LabelAgent":      true,
+		"MetricAgent":     true,
+		"Aggregator":      true,
+		"CloneDependency": true,
+		"Efficiency":      true,
+		"Max":             true,
+		"QosGovernor":     true,
+		"Tenant":          true,
 	}
 	for _, child := range plug[0].Children {
 		name := child.GetNameS()
@@ -1,8 +1,8 @@
 package harvest
 
-Exporters: [Name=_]: #Prom | #Influx
+Exporters: [Name=_]: #Prom | #Influx | #File | #API
 
-#ExporterDefs: string | #Prom | #Influx
+#ExporterDefs: string | #Prom | #Influx | #File | #API
 
 label: [string]: string
 
@@ -19,9 +19,21 @@ label: [string]: string
 	expire_after?: string
 }
 
+#TLSVersion: "tls10" | "tls11" | "tls12" | "tls13" | "TLS10" | "TLS11" | "TLS12" | "TLS13"
+
 #TLS: {
-	cert_file: string
-	key_file:  string
+	cert_file:      string
+	key_file:       string
+	ca_file?:       string
+	min_version?:   #TLSVersion
+	cipher_suites?: [...string]
+}
+
+#ClientTLS: {
+	ca_file?:              string
+	insecure_skip_verify?: bool
+	min_version?:          #TLSVersion
+	cipher_suites?:        [...string]
 }
 
 #Admin: {
@@ -29,14 +41,26 @@ label: [string]: string
 	httpsd?: #HTTPSD
 }
 
+#NamingLint: {
+	allow?: [...string]
+	mode?: "report" | "rewrite"
+}
+
 #Prom: {
 	add_meta_tags?: bool
 	addr?:          string // deprecated
 	allow_addrs_regex?: [...string]
+	convert_units?:   bool
+	exemplar_window?: string
+	exemplars?:       bool
+	export_timeout?:  string
 	exporter:         "Prometheus"
 	local_http_addr?: "0.0.0.0" | "localhost" | "127.0.0.1"
+	naming_lint?:     #NamingLint
+	native_histograms?: bool
 	port?:            int
 	port_range?:      string
+	provenance?:      "labels" | "info"
 	sort_labels?:     bool
 	tls?:             #TLS
 }
@@ -44,11 +68,42 @@ label: [string]: string
 #Influx: {
 	addr?: string // one of addr|url
 	allow_addrs_regex: [...string]
-	bucket?:  string
-	exporter: "InfluxDB"
-	org?:     string
-	token?:   string
-	url?:     string
+	bucket?:         string
+	convert_units?:  bool
+	dedup_window?:   string
+	export_timeout?: string
+	exporter:        "InfluxDB"
+	naming_lint?:    #NamingLint
+	org?:            string
+	provenance?:     "labels" | "info"
+	tls?:            #ClientTLS
+	token?:          string
+	url?:            string
+}
+
+#File: {
+	compress?:       bool
+	convert_units?:  bool
+	export_timeout?: string
+	exporter:        "File"
+	max_file_bytes?: int
+	max_files?:      int
+	naming_lint?:    #NamingLint
+	path:            string
+	provenance?:     "labels" | "info"
+	retention?:      string
+	rotate_every?:   string
+}
+
+#API: {
+	convert_units?:   bool
+	export_timeout?:  string
+	exporter:         "API"
+	local_http_addr?: "0.0.0.0" | "localhost" | "127.0.0.1"
+	naming_lint?:     #NamingLint
+	port:             int
+	provenance?:      "labels" | "info"
+	tls?:             #TLS
 }
 
 #CertificateScript: {
@@ -62,6 +117,45 @@ label: [string]: string
 	timeout?:  string
 }
 
+#MaintenanceWindow: {
+	schedule: string
+	duration: string
+	action?:  "suppress" | "tag"
+	objects?: [...string]
+}
+
+#Hook: {
+	pre_poll?:   string
+	post_poll?:  string
+	on_failure?: string
+	timeout?:    string
+	on_error?:   "continue" | "skip"
+	objects?: [...string]
+}
+
+#Lease: {
+	lock:       "file" | "consul" | "kubernetes"
+	name?:      string
+	duration?:  string
+	holder?:    string
+	path?:      string
+	address?:   string
+	namespace?: string
+}
+
+#CounterCache: {
+	path:     string
+	max_age?: string
+}
+
+#ResourceGuard: {
+	soft_memory_mb?: int
+	hard_memory_mb?: int
+	soft_series?:    int
+	hard_series?:    int
+	shed?: [...string]
+}
+
 #CollectorDef: {
 	[Name=_]: [...string]
 }
@@ -74,22 +168,36 @@ Pollers: [Name=_]: #Poller
 	ca_cert?:            string
 	certificate_script?: #CertificateScript
 	client_timeout?:     string
+	coalesce_requests?:  bool
 	collectors?:         [...#CollectorDef] | [...string]
 	conf_path?:          string
+	counter_cache?:      #CounterCache
 	credentials_file?:   string
 	credentials_script?: #CredentialsScript
 	datacenter?:         string
 	exporters:           [...#ExporterDefs]
+	hooks?: [...#Hook]
 	is_kfs?:             bool
 	labels?:             [...label]
+	lease?:              #Lease
 	log:                 [...string]
+	log_levels?: [string]: "trace" | "debug" | "info" | "warn" | "warning" | "error"
+	log_max_age?:        int
 	log_max_bytes?:      int
 	log_max_files?:      int
+	log_rotate_every?:   string
+	log_to_event_log?:   bool
+	maintenance?: [...#MaintenanceWindow]
 	password?:           string
 	prefer_zapi?:        bool
+	resource_guard?:     #ResourceGuard
 	ssl_cert?:           string
 	ssl_key?:            string
-	tls_min_version?:    string
+	template_overlays?:  [...string]
+	tls_cipher_suites?:  [...string]
+	tls_min_version?:    #TLSVersion
+	tls_renegotiation?:  "never" | "once" | "freely"
 	use_insecure_tls?:   bool
 	username?:           string
+	warm_up?:            string
 }
@@ -6,7 +6,6 @@ package zapi
 
 import (
 	"bytes"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/netapp/harvest/v2/pkg/auth"
@@ -101,13 +100,6 @@ func New(poller *conf.Poller, c *auth.Credentials) (*Client, error) {
 		return nil, err
 	}
 
-	if poller.TLSMinVersion != "" {
-		tlsVersion := client.tlsVersion(poller.TLSMinVersion)
-		if tlsVersion != 0 {
-			client.Logger.Info().Uint16("tlsVersion", tlsVersion).Msg("Using TLS version")
-			transport.TLSClientConfig.MinVersion = tlsVersion
-		}
-	}
 	client.request = request
 
 	// initialize http client
@@ -574,23 +566,6 @@ func (c *Client) SetTimeout(timeout string) {
 	c.client.Timeout = newTimeout
 }
 
-func (c *Client) tlsVersion(version string) uint16 {
-	lower := strings.ToLower(version)
-	switch lower {
-	case "tls10":
-		return tls.VersionTLS10
-	case "tls11":
-		return tls.VersionTLS11
-	case "tls12":
-		return tls.VersionTLS12
-	case "tls13":
-		return tls.VersionTLS13
-	default:
-		c.Logger.Warn().Str("version", version).Msg("Unknown TLS version, using default")
-	}
-	return 0
-}
-
 // NewTestClient It's used for unit test only
 func NewTestClient() *Client {
 	return &Client{
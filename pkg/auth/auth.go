@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -15,7 +14,6 @@ import (
 	"github.com/netapp/harvest/v2/third_party/mergo"
 	"gopkg.in/yaml.v3"
 	"net/http"
-	"os"
 	"os/exec"
 	"path"
 	"strings"
@@ -256,29 +254,6 @@ func (a PollerAuth) Certificate() (tls.Certificate, error) {
 	return tls.LoadX509KeyPair(a.CertPath, a.KeyPath)
 }
 
-// If the CA certificate path is specified, create a CA certificate pool and add the certificate to it.
-// Otherwise, return nil so the host's root CA set is used.
-func (a PollerAuth) loadCertPool(logger *logging.Logger) *x509.CertPool {
-	if a.CaCertPath == "" {
-		return nil
-	}
-
-	caCert, err := os.ReadFile(a.CaCertPath)
-	if err != nil {
-		logger.Error().Err(err).Str("caCertPath", a.CaCertPath).Msg("Failed to read CA certificate. Use host's root CA set.")
-		return nil
-	}
-
-	caCertPool := x509.NewCertPool()
-	ok := caCertPool.AppendCertsFromPEM(caCert)
-	if !ok {
-		logger.Warn().Str("caCertPath", a.CaCertPath).Msg("Failed to append CA certificate to pool. Use host's root CA set.")
-		return nil
-	}
-	logger.Debug().Str("caCertPath", a.CaCertPath).Msg("CA certificate loaded")
-	return caCertPool
-}
-
 func (c *Credentials) GetPollerAuth() (PollerAuth, error) {
 	auth, err := getPollerAuth(c, c.poller)
 	if err != nil {
@@ -426,31 +401,12 @@ func extractCertAndKey(blob string) ([]byte, []byte, error) {
 }
 
 func (c *Credentials) Transport(request *http.Request) (*http.Transport, error) {
-	var (
-		cert      tls.Certificate
-		transport *http.Transport
-	)
-
 	pollerAuth, err := c.GetPollerAuth()
 	if err != nil {
 		return nil, err
 	}
 
-	if pollerAuth.IsCert {
-		cert, err = pollerAuth.Certificate()
-		if err != nil {
-			return nil, err
-		}
-
-		transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			TLSClientConfig: &tls.Config{
-				RootCAs:            pollerAuth.loadCertPool(c.logger),
-				Certificates:       []tls.Certificate{cert},
-				InsecureSkipVerify: pollerAuth.insecureTLS, //nolint:gosec
-			},
-		}
-	} else {
+	if !pollerAuth.IsCert {
 		if !pollerAuth.HasCredentialScript {
 			if pollerAuth.Username == "" {
 				return nil, errs.New(errs.ErrMissingParam, "username")
@@ -462,13 +418,14 @@ func (c *Credentials) Transport(request *http.Request) (*http.Transport, error)
 		if request != nil {
 			request.SetBasicAuth(pollerAuth.Username, pollerAuth.Password)
 		}
-		transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			TLSClientConfig: &tls.Config{
-				RootCAs:            pollerAuth.loadCertPool(c.logger),
-				InsecureSkipVerify: pollerAuth.insecureTLS, //nolint:gosec
-			},
-		}
 	}
-	return transport, err
+
+	tlsConfig, err := c.tlsConfig(pollerAuth)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}, nil
 }
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/tls"
+)
+
+// tlsConfig returns the TLS config shared by the ZAPI and REST clients. Invalid TLS options are logged
+// and left at their defaults
+func (c *Credentials) tlsConfig(pollerAuth PollerAuth) (*tls.Config, error) {
+	options := c.poller.TLSOptions()
+	options.CAFile = pollerAuth.CaCertPath
+	options.InsecureSkipVerify = pollerAuth.insecureTLS
+
+	config, err := options.Client()
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("Invalid TLS options, using defaults")
+	}
+
+	if pollerAuth.IsCert {
+		cert, err := pollerAuth.Certificate()
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
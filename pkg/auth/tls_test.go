@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/tls"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"testing"
+)
+
+func TestCredentials_Transport_TLS(t *testing.T) {
+	tests := []struct {
+		name              string
+		poller            conf.Poller
+		wantMinVersion    uint16
+		wantRenegotiation tls.RenegotiationSupport
+		wantErr           bool
+	}{
+		{name: "defaults", poller: conf.Poller{Username: "u", Password: "p"}},
+		{name: "min version", poller: conf.Poller{Username: "u", Password: "p", TLSMinVersion: "TLS10"}, wantMinVersion: tls.VersionTLS10},
+		{name: "unknown min version", poller: conf.Poller{Username: "u", Password: "p", TLSMinVersion: "tls1"}},
+		{name: "renegotiate once", poller: conf.Poller{Username: "u", Password: "p", TLSRenegotiation: "once"}, wantRenegotiation: tls.RenegotiateOnceAsClient},
+		{name: "renegotiate freely", poller: conf.Poller{Username: "u", Password: "p", TLSRenegotiation: "freely"}, wantRenegotiation: tls.RenegotiateFreelyAsClient},
+		{name: "unknown renegotiation", poller: conf.Poller{Username: "u", Password: "p", TLSRenegotiation: "always"}},
+		{
+			name:    "missing certificate",
+			poller:  conf.Poller{AuthStyle: conf.CertificateAuth, SslCert: "testdata/missing.pem", SslKey: "testdata/missing.key"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poller := tt.poller
+			transport, err := NewCredentials(&poller, logging.Get()).Transport(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transport() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := transport.TLSClientConfig.MinVersion; got != tt.wantMinVersion {
+				t.Errorf("MinVersion got=%d, want=%d", got, tt.wantMinVersion)
+			}
+			if got := transport.TLSClientConfig.Renegotiation; got != tt.wantRenegotiation {
+				t.Errorf("Renegotiation got=%d, want=%d", got, tt.wantRenegotiation)
+			}
+		})
+	}
+}
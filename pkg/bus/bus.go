@@ -0,0 +1,176 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package bus shares the data collected by one collector with the other collectors of the same poller,
+so a collector can use a counter that another collector already polled instead of polling it again.
+
+Data is published per cluster, object, and metric as the raw, not cooked, values of each instance.
+Consumers only use data that is newer than the data they used last time and not older than a maximum age,
+and poll the counter themselves otherwise.
+
+The labels of the instances of an object are published per cluster and object, but only for objects
+that a consumer wants, e.g. to join them with the instances of another object.
+*/
+package bus
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Key identifies the data of one metric
+type Key struct {
+	Cluster string
+	Object  string
+	Metric  string
+}
+
+// Values are the values of one metric by instance, and when they were collected
+type Values struct {
+	Values map[string]float64
+	Time   time.Time
+}
+
+// Labels are the labels of the instances of one object, and when they were collected
+type Labels struct {
+	Instances []map[string]string
+	Time      time.Time
+}
+
+// Bus holds the latest values of each key. A nil Bus shares nothing.
+// It is safe for concurrent use
+type Bus struct {
+	mu     sync.RWMutex
+	values map[Key]Values
+	labels map[Key]Labels
+	wanted map[string]bool
+}
+
+func New() *Bus {
+	return &Bus{values: make(map[Key]Values), labels: make(map[Key]Labels), wanted: make(map[string]bool)}
+}
+
+// Publish replaces the values of key
+func (b *Bus) Publish(key Key, values Values) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = values
+}
+
+// Fresh returns the values of key when they were collected after since, and are not older than maxAge at now
+func (b *Bus) Fresh(key Key, since time.Time, maxAge time.Duration, now time.Time) (Values, bool) {
+	if b == nil {
+		return Values{}, false
+	}
+	b.mu.RLock()
+	values, ok := b.values[key]
+	b.mu.RUnlock()
+	if !ok || !values.Time.After(since) || now.Sub(values.Time) > maxAge {
+		return Values{}, false
+	}
+	return values, true
+}
+
+// Want asks the collectors to publish the labels of object
+func (b *Bus) Want(object string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.wanted[object] = true
+}
+
+// Wanted returns true when a consumer wants the labels of object
+func (b *Bus) Wanted(object string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.wanted[object]
+}
+
+// PublishLabels replaces the labels of key. Labels are keyed by cluster and object, Metric is ignored
+func (b *Bus) PublishLabels(key Key, labels Labels) {
+	if b == nil {
+		return
+	}
+	key.Metric = ""
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.labels[key] = labels
+}
+
+// Labels returns the latest labels of key
+func (b *Bus) Labels(key Key) (Labels, bool) {
+	if b == nil {
+		return Labels{}, false
+	}
+	key.Metric = ""
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	labels, ok := b.labels[key]
+	return labels, ok
+}
+
+// SnapshotLabels copies the labels of the exportable instances of data
+func SnapshotLabels(data *matrix.Matrix, t time.Time) Labels {
+	labels := Labels{Instances: make([]map[string]string, 0, len(data.GetInstances())), Time: t}
+	for _, instance := range data.GetInstances() {
+		if instance.IsExportable() {
+			labels.Instances = append(labels.Instances, maps.Clone(instance.GetLabels()))
+		}
+	}
+	return labels
+}
+
+// Snapshot copies the values of metric from data, keyed by the label of each exportable instance.
+// Instances without the label or without a value are skipped
+func Snapshot(data *matrix.Matrix, metric string, label string, t time.Time) Values {
+	values := Values{Values: make(map[string]float64), Time: t}
+	m := data.GetMetric(metric)
+	if m == nil {
+		return values
+	}
+	for _, instance := range data.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		key := instance.GetLabel(label)
+		if key == "" {
+			continue
+		}
+		if v, ok := m.GetValueFloat64(instance); ok {
+			values.Values[key] = v
+		}
+	}
+	return values
+}
+
+// Apply sets metric of each exportable instance of data to its value in v, by instance key.
+// It returns false, and changes nothing, when v does not have a value for every exportable instance
+func (v Values) Apply(data *matrix.Matrix, metric string) bool {
+	m := data.GetMetric(metric)
+	if m == nil {
+		return false
+	}
+	for key, instance := range data.GetInstances() {
+		if _, ok := v.Values[key]; !ok && instance.IsExportable() {
+			return false
+		}
+	}
+	for key, instance := range data.GetInstances() {
+		if value, ok := v.Values[key]; ok && instance.IsExportable() {
+			_ = m.SetValueFloat64(instance, value)
+		}
+	}
+	return true
+}
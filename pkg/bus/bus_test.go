@@ -0,0 +1,117 @@
+package bus
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+	"time"
+)
+
+func newMatrix(t *testing.T, ops map[string]float64) *matrix.Matrix {
+	t.Helper()
+	m := matrix.New("Workload", "qos", "qos")
+	metric, err := m.NewMetricFloat64("ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range ops {
+		instance, err := m.NewInstance(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.SetLabel("workload", name)
+		if err := metric.SetValueFloat64(instance, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestBus_Fresh(t *testing.T) {
+	now := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+	key := Key{Cluster: "cluster-01", Object: "qos", Metric: "ops"}
+	b := New()
+	b.Publish(key, Values{Values: map[string]float64{"w1": 1}, Time: now.Add(-time.Minute)})
+
+	tests := []struct {
+		name   string
+		key    Key
+		since  time.Time
+		maxAge time.Duration
+		want   bool
+	}{
+		{name: "fresh", key: key, maxAge: 3 * time.Minute, want: true},
+		{name: "already used", key: key, since: now.Add(-time.Minute), maxAge: 3 * time.Minute},
+		{name: "too old", key: key, maxAge: 30 * time.Second},
+		{name: "other cluster", key: Key{Cluster: "cluster-02", Object: "qos", Metric: "ops"}, maxAge: 3 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := b.Fresh(tt.key, tt.since, tt.maxAge, now); got != tt.want {
+				t.Errorf("Fresh() got=%t, want=%t", got, tt.want)
+			}
+		})
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(key, Values{})
+	if _, ok := nilBus.Fresh(key, time.Time{}, time.Hour, now); ok {
+		t.Errorf("nil bus should not have values")
+	}
+}
+
+func TestValues_Apply(t *testing.T) {
+	shared := Snapshot(newMatrix(t, map[string]float64{"w1": 10, "w2": 20}), "ops", "workload", time.Now())
+
+	detail := newMatrix(t, map[string]float64{"w1": 0, "w2": 0})
+	if !shared.Apply(detail, "ops") {
+		t.Fatalf("Apply() expected values for every instance")
+	}
+	if v, _ := detail.GetMetric("ops").GetValueFloat64(detail.GetInstance("w2")); v != 20 {
+		t.Errorf("Apply() got=%f, want=20", v)
+	}
+
+	missing := newMatrix(t, map[string]float64{"w1": 0, "w3": 0})
+	if shared.Apply(missing, "ops") {
+		t.Errorf("Apply() expected false when an instance has no value")
+	}
+	if v, _ := missing.GetMetric("ops").GetValueFloat64(missing.GetInstance("w1")); v != 0 {
+		t.Errorf("Apply() changed the matrix got=%f, want=0", v)
+	}
+}
+
+func TestBus_Labels(t *testing.T) {
+	b := New()
+	if b.Wanted("qos") {
+		t.Fatal("Wanted() got=true before Want")
+	}
+	b.Want("qos")
+	if !b.Wanted("qos") {
+		t.Fatal("Wanted() got=false after Want")
+	}
+
+	data := newMatrix(t, map[string]float64{"w1": 1, "w2": 2})
+	data.GetInstance("w2").SetExportable(false)
+	now := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+	b.PublishLabels(Key{Cluster: "cluster-01", Object: "qos", Metric: "ops"}, SnapshotLabels(data, now))
+
+	labels, ok := b.Labels(Key{Cluster: "cluster-01", Object: "qos"})
+	if !ok || !labels.Time.Equal(now) {
+		t.Fatalf("Labels() got=%v %v, want labels at %v", labels, ok, now)
+	}
+	if len(labels.Instances) != 1 || labels.Instances[0]["workload"] != "w1" {
+		t.Errorf("Labels() got=%v, want the labels of w1", labels.Instances)
+	}
+	data.GetInstance("w1").SetLabel("workload", "changed")
+	if labels.Instances[0]["workload"] != "w1" {
+		t.Errorf("published labels should not change with the matrix")
+	}
+	if _, ok := b.Labels(Key{Cluster: "cluster-02", Object: "qos"}); ok {
+		t.Errorf("Labels() of another cluster got=true")
+	}
+
+	var nilBus *Bus
+	nilBus.Want("qos")
+	if _, ok := nilBus.Labels(Key{Object: "qos"}); ok || nilBus.Wanted("qos") {
+		t.Errorf("nil bus should share nothing")
+	}
+}
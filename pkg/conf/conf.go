@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tlsconf"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"github.com/netapp/harvest/v2/pkg/util"
 	"github.com/netapp/harvest/v2/third_party/mergo"
@@ -281,6 +282,51 @@ func PollerNamed(name string) (*Poller, error) {
 	return poller, nil
 }
 
+// ReadPoller reads the poller named name from the config file at configPath and its poller_files, without
+// changing Config. It is used to re-read the settings of a running poller
+func ReadPoller(configPath string, name string) (*Poller, error) {
+	contents, err := os.ReadFile(ConfigPath(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s err=%w", configPath, err)
+	}
+	cfg, err := unmarshalConfig(contents)
+	if err != nil {
+		return nil, err
+	}
+	poller, ok := cfg.Pollers[name]
+	for _, pat := range cfg.PollerFiles {
+		if ok {
+			break
+		}
+		fs, _ := filepath.Glob(pat)
+		sort.Strings(fs)
+		for _, filename := range fs {
+			fsContents, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("error reading poller_file=%s err=%w", filename, err)
+			}
+			pollerCfg, err := unmarshalConfig(fsContents)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling poller_file=%s err=%w", filename, err)
+			}
+			if poller, ok = pollerCfg.Pollers[name]; ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, errs.New(errs.ErrConfig, "poller ["+name+"] not found")
+	}
+	if poller == nil {
+		poller = &Poller{}
+	}
+	if cfg.Defaults != nil {
+		poller.Union(cfg.Defaults)
+	}
+	poller.Name = name
+	return poller, nil
+}
+
 // Path returns a path based on aPath and the HARVEST_CONF environment variable.
 // If aPath is absolute, it is returned unchanged.
 // When the HARVEST_CONF environment variable is set, a new path is returned relative to HARVEST_CONF.
@@ -435,9 +481,24 @@ func GetUniqueExporters(exporterNames []string) []string {
 	return resultExporters
 }
 
+// TLS are the TLS options of the exporters and the admin node. Servers use the certificate and key, clients,
+// e.g. the InfluxDB exporter, use the CA file and verification options
 type TLS struct {
-	CertFile string `yaml:"cert_file,omitempty"`
-	KeyFile  string `yaml:"key_file,omitempty"`
+	CertFile           string   `yaml:"cert_file,omitempty"`
+	KeyFile            string   `yaml:"key_file,omitempty"`
+	CAFile             string   `yaml:"ca_file,omitempty"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty"`
+	MinVersion         string   `yaml:"min_version,omitempty"`
+	CipherSuites       []string `yaml:"cipher_suites,omitempty"`
+}
+
+func (t TLS) Options() tlsconf.Options {
+	return tlsconf.Options{
+		CAFile:             t.CAFile,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         t.MinVersion,
+		CipherSuites:       t.CipherSuites,
+	}
 }
 
 type Httpsd struct {
@@ -451,8 +512,14 @@ type Httpsd struct {
 	ExpireAfter string `yaml:"expire_after,omitempty"`
 }
 
+// FileSd is the Prometheus file service discovery file that bin/harvest writes with the exporters of the running pollers
+type FileSd struct {
+	Path string `yaml:"path,omitempty"`
+}
+
 type Admin struct {
 	Httpsd Httpsd `yaml:"httpsd,omitempty"`
+	FileSd FileSd `yaml:"file_sd,omitempty"`
 }
 
 type Tools struct {
@@ -502,27 +569,41 @@ type Poller struct {
 	AuthStyle         string               `yaml:"auth_style,omitempty"`
 	CaCertPath        string               `yaml:"ca_cert,omitempty"`
 	ClientTimeout     string               `yaml:"client_timeout,omitempty"`
+	CoalesceRequests  bool                 `yaml:"coalesce_requests,omitempty"`
+	CounterCache      *CounterCache        `yaml:"counter_cache,omitempty"`
 	Collectors        []Collector          `yaml:"collectors,omitempty"`
 	CredentialsFile   string               `yaml:"credentials_file,omitempty"`
 	CredentialsScript CredentialsScript    `yaml:"credentials_script,omitempty"`
 	CertificateScript CertificateScript    `yaml:"certificate_script,omitempty"`
 	Datacenter        string               `yaml:"datacenter,omitempty"`
 	ExporterDefs      []ExportDef          `yaml:"exporters,omitempty"`
+	Hooks             []Hook               `yaml:"hooks,omitempty"`
 	IsKfs             bool                 `yaml:"is_kfs,omitempty"`
 	Labels            *[]map[string]string `yaml:"labels,omitempty"`
+	Lease             *Lease               `yaml:"lease,omitempty"`
+	LogMaxAge         int                  `yaml:"log_max_age,omitempty"`
 	LogMaxBytes       int64                `yaml:"log_max_bytes,omitempty"`
 	LogMaxFiles       int                  `yaml:"log_max_files,omitempty"`
+	LogRotateEvery    string               `yaml:"log_rotate_every,omitempty"`
 	LogSet            *[]string            `yaml:"log,omitempty"`
+	LogToEventLog     bool                 `yaml:"log_to_event_log,omitempty"`
+	LogLevels         map[string]string    `yaml:"log_levels,omitempty"`
+	Maintenance       []MaintenanceWindow  `yaml:"maintenance,omitempty"`
 	Password          string               `yaml:"password,omitempty"`
 	PollerSchedule    string               `yaml:"poller_schedule,omitempty"`
 	PollerLogSchedule string               `yaml:"poller_log_schedule,omitempty"`
 	SslCert           string               `yaml:"ssl_cert,omitempty"`
 	SslKey            string               `yaml:"ssl_key,omitempty"`
+	TLSCipherSuites   []string             `yaml:"tls_cipher_suites,omitempty"`
 	TLSMinVersion     string               `yaml:"tls_min_version,omitempty"`
+	TLSRenegotiation  string               `yaml:"tls_renegotiation,omitempty"`
 	UseInsecureTLS    *bool                `yaml:"use_insecure_tls,omitempty"`
 	Username          string               `yaml:"username,omitempty"`
 	PreferZAPI        bool                 `yaml:"prefer_zapi,omitempty"`
+	ResourceGuard     *ResourceGuard       `yaml:"resource_guard,omitempty"`
 	ConfPath          string               `yaml:"conf_path,omitempty"`
+	TemplateOverlays  []string             `yaml:"template_overlays,omitempty"`
+	WarmUp            string               `yaml:"warm_up,omitempty"`
 	Exporters         []string             `yaml:"-"`
 	promIndex         int
 	Name              string
@@ -556,6 +637,17 @@ func (p *Poller) Union(defaults *Poller) {
 	p.CredentialsScript.Path = pCredentialsScript
 }
 
+// TLSOptions returns the TLS options of the poller's connections to its cluster
+func (p *Poller) TLSOptions() tlsconf.Options {
+	return tlsconf.Options{
+		CAFile:             p.CaCertPath,
+		InsecureSkipVerify: p.UseInsecureTLS != nil && *p.UseInsecureTLS,
+		MinVersion:         p.TLSMinVersion,
+		CipherSuites:       p.TLSCipherSuites,
+		Renegotiation:      p.TLSRenegotiation,
+	}
+}
+
 // ZapiPoller creates a poller out of a node, this is a bridge between the node and struct-based code
 // Used by ZAPI based code
 func ZapiPoller(n *node.Node) *Poller {
@@ -625,6 +717,15 @@ func ZapiPoller(n *node.Node) *Poller {
 	if tlsMinVersion := n.GetChildContentS("tls_min_version"); tlsMinVersion != "" {
 		p.TLSMinVersion = tlsMinVersion
 	}
+	if tlsRenegotiation := n.GetChildContentS("tls_renegotiation"); tlsRenegotiation != "" {
+		p.TLSRenegotiation = tlsRenegotiation
+	}
+	if cipherSuites := n.GetChildS("tls_cipher_suites"); cipherSuites != nil {
+		p.TLSCipherSuites = cipherSuites.GetAllChildContentS()
+	}
+	if coalesce := n.GetChildContentS("coalesce_requests"); coalesce != "" {
+		p.CoalesceRequests = coalesce == "true"
+	}
 	if logSet := n.GetChildS("log"); logSet != nil {
 		names := logSet.GetAllChildNamesS()
 		p.LogSet = &names
@@ -636,22 +737,32 @@ func ZapiPoller(n *node.Node) *Poller {
 }
 
 type Exporter struct {
-	Port              *int      `yaml:"port,omitempty"`
-	PortRange         *IntRange `yaml:"port_range,omitempty"`
-	Type              string    `yaml:"exporter,omitempty"`
-	Addr              *string   `yaml:"addr,omitempty"`
-	URL               *string   `yaml:"url,omitempty"`
-	LocalHTTPAddr     string    `yaml:"local_http_addr,omitempty"`
-	GlobalPrefix      *string   `yaml:"global_prefix,omitempty"`
-	AllowedAddrs      *[]string `yaml:"allow_addrs,omitempty"`
-	AllowedAddrsRegex *[]string `yaml:"allow_addrs_regex,omitempty"`
-	CacheMaxKeep      *string   `yaml:"cache_max_keep,omitempty"`
-	ShouldAddMetaTags *bool     `yaml:"add_meta_tags,omitempty"`
+	Port              *int        `yaml:"port,omitempty"`
+	PortRange         *IntRange   `yaml:"port_range,omitempty"`
+	Type              string      `yaml:"exporter,omitempty"`
+	Addr              *string     `yaml:"addr,omitempty"`
+	URL               *string     `yaml:"url,omitempty"`
+	LocalHTTPAddr     string      `yaml:"local_http_addr,omitempty"`
+	GlobalPrefix      *string     `yaml:"global_prefix,omitempty"`
+	AllowedAddrs      *[]string   `yaml:"allow_addrs,omitempty"`
+	AllowedAddrsRegex *[]string   `yaml:"allow_addrs_regex,omitempty"`
+	CacheMaxKeep      *string     `yaml:"cache_max_keep,omitempty"`
+	ShouldAddMetaTags *bool       `yaml:"add_meta_tags,omitempty"`
+	Routes            *Routes     `yaml:"routes,omitempty"`
+	Normalize         []Normalize `yaml:"normalize,omitempty"`
+	ConvertUnits      bool        `yaml:"convert_units,omitempty"`
+	Provenance        string      `yaml:"provenance,omitempty"`
+	NamingLint        *NamingLint `yaml:"naming_lint,omitempty"`
+	ExportTimeout     string      `yaml:"export_timeout,omitempty"`
+	TLS               TLS         `yaml:"tls,omitempty"`
 
 	// Prometheus specific
-	HeartBeatURL string `yaml:"heart_beat_url,omitempty"`
-	SortLabels   bool   `yaml:"sort_labels,omitempty"`
-	TLS          TLS    `yaml:"tls,omitempty"`
+	HeartBeatURL     string        `yaml:"heart_beat_url,omitempty"`
+	SortLabels       bool          `yaml:"sort_labels,omitempty"`
+	Exemplars        bool          `yaml:"exemplars,omitempty"`         // link perf metrics to recent EMS events of their node
+	ExemplarWindow   string        `yaml:"exemplar_window,omitempty"`   // how long an EMS event is linked, default 5m
+	Auth             *ExporterAuth `yaml:"auth,omitempty"`              // authentication required of scrapes
+	NativeHistograms bool          `yaml:"native_histograms,omitempty"` // serve histograms as native histograms to protobuf scrapes
 
 	// InfluxDB specific
 	Bucket        *string `yaml:"bucket,omitempty"`
@@ -660,10 +771,110 @@ type Exporter struct {
 	Precision     *string `yaml:"precision,omitempty"`
 	ClientTimeout *string `yaml:"client_timeout,omitempty"`
 	Version       *string `yaml:"version,omitempty"`
+	DedupWindow   string  `yaml:"dedup_window,omitempty"` // align timestamps to a window, so redundant pollers write the same points
+
+	// File specific
+	Path         string `yaml:"path,omitempty"`
+	MaxFileBytes int64  `yaml:"max_file_bytes,omitempty"`
+	RotateEvery  string `yaml:"rotate_every,omitempty"`
+	Retention    string `yaml:"retention,omitempty"`
+	MaxFiles     int    `yaml:"max_files,omitempty"`
+	Compress     bool   `yaml:"compress,omitempty"`
 
 	IsTest bool // true when run from unit tests
 }
 
+// ExporterAuth is the authentication the Prometheus exporter requires of scrapes. A scrape is allowed when it passes
+// any of the configured methods
+type ExporterAuth struct {
+	BearerToken  string `yaml:"bearer_token,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	ClientCAFile string `yaml:"client_ca_file,omitempty"` // requires tls, client certificates are verified with this CA
+}
+
+// NamingLint checks the names of exported metrics against the Prometheus naming conventions
+type NamingLint struct {
+	Mode  string   `yaml:"mode,omitempty"`  // report, the default, or rewrite
+	Allow []string `yaml:"allow,omitempty"` // shell patterns of names that are exported as is, e.g. legacy names
+}
+
+// Normalize is a rule that normalizes the values of Labels before they are exported.
+// When Labels is empty, the rule applies to all labels. The steps are applied in the order of the fields
+type Normalize struct {
+	Labels    []string `yaml:"labels,omitempty"`
+	NFC       bool     `yaml:"nfc,omitempty"`       // unicode normalization form C
+	Trim      bool     `yaml:"trim,omitempty"`      // remove leading and trailing whitespace, collapse inner whitespace to one space
+	Lowercase bool     `yaml:"lowercase,omitempty"` // convert to lowercase
+	Replace   string   `yaml:"replace,omitempty"`   // regex of disallowed characters
+	With      string   `yaml:"with,omitempty"`      // replacement of disallowed characters
+	Hash      bool     `yaml:"hash,omitempty"`      // replace the value with its keyed hash, HMAC-SHA256 with HashKey
+	HashKey   string   `yaml:"hash_key,omitempty"`  // secret key of the hash, values hashed with the same key can be joined
+}
+
+// Routes decide which objects and instances are sent to an exporter.
+// When Include is empty, everything is included. Exclude is applied after Include
+type Routes struct {
+	Include []Route `yaml:"include,omitempty"`
+	Exclude []Route `yaml:"exclude,omitempty"`
+}
+
+// Route matches a matrix when its object matches Object and its instance, or global, labels match all Labels.
+// Object and label values are shell patterns, e.g. qos*
+type Route struct {
+	Object string            `yaml:"object,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// MaintenanceWindow is a recurring window during which collection continues, but export is suppressed or tagged.
+// The window starts at the times matching Schedule, a cron expression, and lasts for Duration
+type MaintenanceWindow struct {
+	Schedule string   `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+	Duration string   `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Action   string   `yaml:"action,omitempty" json:"action,omitempty"`
+	Objects  []string `yaml:"objects,omitempty" json:"objects,omitempty"`
+}
+
+// Hook runs scripts before the polls of the matching objects, after them, and when they fail.
+// The scripts receive the context of the poll as JSON on stdin. OnError is continue or skip, the policy when PrePoll fails
+type Hook struct {
+	Objects   []string `yaml:"objects,omitempty" json:"objects,omitempty"`
+	PrePoll   string   `yaml:"pre_poll,omitempty" json:"pre_poll,omitempty"`
+	PostPoll  string   `yaml:"post_poll,omitempty" json:"post_poll,omitempty"`
+	OnFailure string   `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+	Timeout   string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	OnError   string   `yaml:"on_error,omitempty" json:"on_error,omitempty"`
+}
+
+// Lease coordinates a pair of pollers that monitor the same cluster. Both pollers collect, but only the poller that
+// holds the lease exports data. Lock is file, consul, or kubernetes
+type Lease struct {
+	Lock      string `yaml:"lock,omitempty" json:"lock,omitempty"`
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Duration  string `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Holder    string `yaml:"holder,omitempty" json:"holder,omitempty"`
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`
+	Address   string `yaml:"address,omitempty" json:"address,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+}
+
+// CounterCache is the directory where RestPerf caches the counter schemas of clusters, shared by the pollers of
+// a host that monitor the same cluster
+type CounterCache struct {
+	Path   string `yaml:"path,omitempty" json:"path,omitempty"`
+	MaxAge string `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+}
+
+// ResourceGuard has the soft and hard limits of a poller's memory and exported series. A limit of 0 means no limit.
+// Objects in Shed stop polling while the poller is above a hard limit
+type ResourceGuard struct {
+	SoftMemoryMB int      `yaml:"soft_memory_mb,omitempty" json:"soft_memory_mb,omitempty"`
+	HardMemoryMB int      `yaml:"hard_memory_mb,omitempty" json:"hard_memory_mb,omitempty"`
+	SoftSeries   int      `yaml:"soft_series,omitempty" json:"soft_series,omitempty"`
+	HardSeries   int      `yaml:"hard_series,omitempty" json:"hard_series,omitempty"`
+	Shed         []string `yaml:"shed,omitempty" json:"shed,omitempty"`
+}
+
 type Pollers struct {
 	namesInOrder []string
 }
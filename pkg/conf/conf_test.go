@@ -3,7 +3,9 @@ package conf
 import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"maps"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strconv"
@@ -508,3 +510,31 @@ func TestEmbeddedExporter(t *testing.T) {
 		t.Errorf("got port=%d, want port=32990", port)
 	}
 }
+
+func TestReadPoller(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "harvest.yml")
+	contents := `
+Defaults:
+  log_levels:
+    Zapi: debug
+Pollers:
+  cluster-01:
+    addr: 10.0.1.1
+    log_levels:
+      ZapiPerf:Volume: trace
+`
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	poller, err := ReadPoller(path, "cluster-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Zapi": "debug", "ZapiPerf:Volume": "trace"}
+	if !maps.Equal(poller.LogLevels, want) {
+		t.Errorf("LogLevels got=%v want=%v", poller.LogLevels, want)
+	}
+	if _, err := ReadPoller(path, "missing"); err == nil {
+		t.Errorf("expected an error for a missing poller")
+	}
+}
@@ -0,0 +1,313 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a problem of a Harvest config file, at a line of the file
+type ValidationError struct {
+	File string
+	Line int // 0 when the problem is not at a line, e.g. the file can not be read
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	if e.Line == 0 {
+		return e.File + ": " + e.Msg
+	}
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Msg
+}
+
+var (
+	yamlLineRe     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownFieldRe = regexp.MustCompile(`^field (\S+) not found in type (\S+)$`)
+)
+
+// Validate strictly validates the Harvest config file at configPath and its poller files.
+// Loading the config ignores unknown keys, so a typo like exporteres fails silently.
+// Validate reports unknown keys, values of the wrong type, and poller names that are not unique,
+// with the file and line of each problem
+func Validate(configPath string) []ValidationError {
+	contents, err := os.ReadFile(configPath)
+	if err != nil {
+		return []ValidationError{{File: configPath, Msg: err.Error()}}
+	}
+	problems, root := ValidateContents(configPath, contents)
+	if root == nil {
+		return problems
+	}
+
+	pollers := make(map[string]ValidationError)
+	problems = append(problems, uniquePollers(configPath, root, pollers)...)
+	for _, pat := range sequenceValues(mappingValue(root, "Poller_files")) {
+		files, err := filepath.Glob(pat)
+		if err != nil {
+			problems = append(problems, ValidationError{File: configPath, Msg: fmt.Sprintf("invalid poller_files pattern %s: %v", pat, err)})
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			fileContents, err := os.ReadFile(file)
+			if err != nil {
+				problems = append(problems, ValidationError{File: file, Msg: err.Error()})
+				continue
+			}
+			fileProblems, fileRoot := ValidateContents(file, fileContents)
+			problems = append(problems, fileProblems...)
+			if fileRoot != nil {
+				problems = append(problems, uniquePollers(file, fileRoot, pollers)...)
+			}
+		}
+	}
+	return problems
+}
+
+// ValidateContents strictly validates the contents of the Harvest config file named file,
+// and returns its problems and its root mapping, nil when the file is not valid YAML
+func ValidateContents(file string, contents []byte) ([]ValidationError, *yaml.Node) {
+	contents, err := ExpandVars(contents)
+	if err != nil {
+		return []ValidationError{{File: file, Msg: err.Error()}}, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return yamlProblems(file, err), nil
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return []ValidationError{{File: file, Line: doc.Line, Msg: "the config must be a mapping of sections, e.g. Pollers"}}, nil
+	}
+	root := doc.Content[0]
+
+	var problems []ValidationError
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	var cfg HarvestConfig
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		problems = yamlProblems(file, err)
+	}
+
+	// embedded exporters are decoded by ExportDef, which does not know the fields of the decoder
+	var pollers []*yaml.Node
+	if section := mappingValue(root, "Pollers"); section != nil && section.Kind == yaml.MappingNode {
+		for i := 1; i < len(section.Content); i += 2 {
+			pollers = append(pollers, section.Content[i])
+		}
+	}
+	if defaults := mappingValue(root, "Defaults"); defaults != nil {
+		pollers = append(pollers, defaults)
+	}
+	exporterKeys := yamlKeys(reflect.TypeOf(Exporter{}))
+	for _, poller := range pollers {
+		exporters := mappingValue(poller, "exporters")
+		if exporters == nil || exporters.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, e := range exporters.Content {
+			problems = append(problems, unknownKeys(file, e, "exporter", exporterKeys)...)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems, root
+}
+
+// uniquePollers returns a problem for each poller of root that is already defined in seen, and adds the others to seen
+func uniquePollers(file string, root *yaml.Node, seen map[string]ValidationError) []ValidationError {
+	var problems []ValidationError
+	pollers := mappingValue(root, "Pollers")
+	if pollers == nil || pollers.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(pollers.Content); i += 2 {
+		key := pollers.Content[i]
+		if first, ok := seen[key.Value]; ok {
+			problems = append(problems, ValidationError{
+				File: file,
+				Line: key.Line,
+				Msg:  fmt.Sprintf("poller %s is not unique, it is already defined at %s:%d", key.Value, first.File, first.Line),
+			})
+			continue
+		}
+		seen[key.Value] = ValidationError{File: file, Line: key.Line}
+	}
+	return problems
+}
+
+// unknownKeys returns a problem for each key of the mapping n that is not in keys
+func unknownKeys(file string, n *yaml.Node, section string, keys []string) []ValidationError {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var problems []ValidationError
+	for i := 0; i < len(n.Content); i += 2 {
+		key := n.Content[i]
+		if !slices.Contains(keys, key.Value) {
+			problems = append(problems, ValidationError{File: file, Line: key.Line, Msg: unknownKeyMsg(key.Value, section, keys)})
+		}
+	}
+	return problems
+}
+
+// yamlProblems splits the errors of the YAML decoder into problems at lines
+func yamlProblems(file string, err error) []ValidationError {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = strings.Split(err.Error(), "\n")
+	}
+	var problems []ValidationError
+	for _, m := range messages {
+		m = strings.TrimSpace(m)
+		if m == "" || strings.HasSuffix(m, "unmarshal errors:") {
+			continue
+		}
+		problem := ValidationError{File: file, Msg: m}
+		if match := yamlLineRe.FindStringSubmatch(m); match != nil {
+			problem.Line, _ = strconv.Atoi(match[1])
+			problem.Msg = match[2]
+		}
+		if match := unknownFieldRe.FindStringSubmatch(problem.Msg); match != nil {
+			section, keys := sectionOf(match[2])
+			problem.Msg = unknownKeyMsg(match[1], section, keys)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+func unknownKeyMsg(key string, section string, keys []string) string {
+	msg := fmt.Sprintf("unknown key %s in %s", key, section)
+	if suggestion := closestKey(key, keys); suggestion != "" {
+		msg += ", did you mean " + suggestion + "?"
+	}
+	return msg
+}
+
+// sectionOf returns a readable name and the keys of the config type named typeName, e.g. conf.Poller
+func sectionOf(typeName string) (string, []string) {
+	t := configTypes()[typeName]
+	if t == nil {
+		return typeName, nil
+	}
+	name := strings.ToLower(strings.TrimPrefix(typeName, "conf."))
+	if t == reflect.TypeOf(HarvestConfig{}) {
+		name = "the top level"
+	}
+	return name, yamlKeys(t)
+}
+
+// configTypes returns the struct types of the Harvest config by name, e.g. conf.Poller
+func configTypes() map[string]reflect.Type {
+	types := make(map[string]reflect.Type)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || types[t.String()] != nil {
+			return
+		}
+		types[t.String()] = t
+		for i := range t.NumField() {
+			walk(t.Field(i).Type)
+		}
+	}
+	walk(reflect.TypeOf(HarvestConfig{}))
+	return types
+}
+
+// yamlKeys returns the keys of the struct type t in YAML
+func yamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-":
+			continue
+		case strings.Contains(opts, "inline"):
+			keys = append(keys, yamlKeys(f.Type)...)
+			continue
+		case name == "":
+			name = strings.ToLower(f.Name)
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// closestKey returns the key of keys that is closest to key, when it is close enough to be a typo
+func closestKey(key string, keys []string) string {
+	best, bestDistance := "", 3
+	for _, k := range keys {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(k)); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// mappingValue returns the value of key in the mapping n, or nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceValues returns the scalar values of the sequence n
+func sequenceValues(n *yaml.Node) []string {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var values []string
+	for _, c := range n.Content {
+		if c.Kind == yaml.ScalarNode {
+			values = append(values, c.Value)
+		}
+	}
+	return values
+}
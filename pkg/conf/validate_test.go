@@ -0,0 +1,118 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateContents(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []string
+	}{
+		{
+			name: "valid",
+			contents: `
+Exporters:
+  prom:
+    exporter: Prometheus
+    port: 12990
+Pollers:
+  a:
+    addr: 10.0.0.1
+    exporters:
+      - prom
+`,
+		},
+		{
+			name: "unknown key",
+			contents: `
+Pollers:
+  a:
+    addr: 10.0.0.1
+    exporteres:
+      - prom
+`,
+			want: []string{"f.yml:5: unknown key exporteres in poller, did you mean exporters?"},
+		},
+		{
+			name: "unknown section",
+			contents: `
+Poller:
+  a:
+    addr: 10.0.0.1
+`,
+			want: []string{"f.yml:2: unknown key Poller in the top level, did you mean Pollers?"},
+		},
+		{
+			name: "wrong type",
+			contents: `
+Exporters:
+  prom:
+    exporter: Prometheus
+    port: twelve
+`,
+			want: []string{"f.yml:5: cannot unmarshal !!str `twelve` into int"},
+		},
+		{
+			name: "embedded exporter",
+			contents: `
+Pollers:
+  a:
+    addr: 10.0.0.1
+    exporters:
+      - exporter: Prometheus
+        prot: 12990
+`,
+			want: []string{"f.yml:7: unknown key prot in exporter, did you mean port?"},
+		},
+		{
+			name:     "syntax",
+			contents: "Pollers:\n  a:\n\taddr: 10.0.0.1\n",
+			want:     []string{"f.yml:3: found character that cannot start any token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, _ := ValidateContents("f.yml", []byte(tt.contents))
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateContents() got=%q, want=%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_PollerFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	config := write("harvest.yml", "Poller_files:\n  - "+filepath.Join(dir, "pollers", "*.yml")+"\nPollers:\n  a:\n    addr: 10.0.0.1\n")
+	if err := os.Mkdir(filepath.Join(dir, "pollers"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	more := write(filepath.Join("pollers", "more.yml"), "Pollers:\n  b:\n    addr: 10.0.0.2\n  a:\n    adr: 10.0.0.3\n")
+
+	var got []string
+	for _, p := range Validate(config) {
+		got = append(got, p.Error())
+	}
+	want := []string{
+		more + ":5: unknown key adr in poller, did you mean addr?",
+		more + ":4: poller a is not unique, it is already defined at " + config + ":4",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() got=%q, want=%q", got, want)
+	}
+}
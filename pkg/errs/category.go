@@ -0,0 +1,61 @@
+package errs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net"
+	"net/url"
+)
+
+// Categories of errors, the machine-readable names that are exported with the failures of collectors
+const (
+	CategoryAuth        = "auth"         // the credentials were refused, or the user lacks a permission
+	CategoryNetwork     = "network"      // the target could not be reached, or did not answer in time
+	CategoryAPIRejected = "api_rejected" // the target answered the request with an error
+	CategoryParse       = "parse"        // the response of the target could not be read
+	CategorySchema      = "schema"       // the template does not match the target, e.g. an API or counter is missing
+	CategoryOther       = "other"
+)
+
+// Categories are the error categories, in the order they are exported
+var Categories = []string{CategoryAuth, CategoryNetwork, CategoryAPIRejected, CategoryParse, CategorySchema, CategoryOther}
+
+// Category returns the category of err, CategoryOther when err does not match one
+func Category(err error) string {
+	var (
+		urlErr    *url.Error
+		netErr    net.Error
+		restErr   *RestError
+		harvest   HarvestError
+		jsonErr   *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		xmlErr    *xml.SyntaxError
+		gridErr   StorageGridError
+		hasStatus bool
+	)
+	if errors.As(err, &harvest) {
+		hasStatus = harvest.StatusCode != 0
+	}
+	switch {
+	case errors.Is(err, ErrAuthFailed), errors.Is(err, ErrPermissionDenied):
+		return CategoryAuth
+	case errors.As(err, &gridErr) && gridErr.IsAuthErr():
+		return CategoryAuth
+	case errors.Is(err, ErrDeadline), errors.Is(err, ErrConnection), errors.As(err, &urlErr), errors.As(err, &netErr):
+		return CategoryNetwork
+	case errors.Is(err, ErrNoMetric), errors.Is(err, ErrAttributeNotFound), errors.Is(err, ErrWrongTemplate),
+		IsRestErr(err, APINotFound), IsRestErr(err, TableNotFound):
+		return CategorySchema
+	case errors.As(err, &jsonErr), errors.As(err, &typeErr), errors.As(err, &xmlErr):
+		return CategoryParse
+	// ZAPI responses with an HTTP error have a status, responses that lack a part do not
+	case errors.Is(err, ErrAPIResponse) && !hasStatus:
+		return CategoryParse
+	case errors.Is(err, ErrAPIRequestRejected), errors.Is(err, ErrAPIResponse), errors.As(err, &gridErr):
+		return CategoryAPIRejected
+	case errors.As(err, &restErr) && restErr.StatusCode >= 400:
+		return CategoryAPIRejected
+	}
+	return CategoryOther
+}
@@ -0,0 +1,40 @@
+package errs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestCategory(t *testing.T) {
+	syntaxErr := json.Unmarshal([]byte("{"), &struct{}{})
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "rest auth", err: NewRest().StatusCode(401).Error(ErrAuthFailed).Build(), want: CategoryAuth},
+		{name: "rest permission", err: NewRest().StatusCode(403).Error(ErrPermissionDenied).Build(), want: CategoryAuth},
+		{name: "zapi auth", err: New(ErrAuthFailed, "401 Unauthorized", WithStatus(401)), want: CategoryAuth},
+		{name: "storagegrid auth", err: NewStorageGridErr(401, []byte(`{"code":401}`)), want: CategoryAuth},
+		{name: "connection", err: fmt.Errorf("connection error %w", &url.Error{Op: "Get", URL: "https://a", Err: fmt.Errorf("refused")}), want: CategoryNetwork},
+		{name: "zapi connection", err: New(ErrConnection, "refused"), want: CategoryNetwork},
+		{name: "deadline", err: New(ErrDeadline, "task data exceeded its slot"), want: CategoryNetwork},
+		{name: "api not found", err: NewRest().StatusCode(404).Code(APINotFound.Code).Build(), want: CategorySchema},
+		{name: "no metrics", err: New(ErrNoMetric, "volume"), want: CategorySchema},
+		{name: "json", err: fmt.Errorf("parse: %w", syntaxErr), want: CategoryParse},
+		{name: "zapi missing results", err: New(ErrAPIResponse, `missing "results"`), want: CategoryParse},
+		{name: "zapi http error", err: New(ErrAPIResponse, "500 Internal Server Error", WithStatus(500)), want: CategoryAPIRejected},
+		{name: "zapi rejected", err: New(ErrAPIRequestRejected, "invalid query"), want: CategoryAPIRejected},
+		{name: "rest rejected", err: NewRest().StatusCode(400).Code(262179).Message("Unexpected argument").Build(), want: CategoryAPIRejected},
+		{name: "no instances", err: New(ErrNoInstance, "no volumes"), want: CategoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Category(tt.err); got != tt.want {
+				t.Errorf("Category() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ const (
 	ErrAuthFailed                = harvestError("auth failed")
 	ErrConfig                    = harvestError("configuration error")
 	ErrConnection                = harvestError("connection error")
+	ErrDeadline                  = harvestError("deadline exceeded")
 	ErrImplement                 = harvestError("implementation error")
 	ErrInvalidItem               = harvestError("invalid item")
 	ErrInvalidParam              = harvestError("invalid parameter")
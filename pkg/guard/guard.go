@@ -0,0 +1,158 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package guard keeps a poller within its memory and cardinality limits.
+
+A poller has soft and hard limits on its resident memory (RSS) and on the number of series its collectors export.
+Above a soft limit, the poller logs a warning and reports the level in its metadata.
+Above a hard limit, the collectors of the configured low-priority objects stop polling, which sheds their memory and series,
+until the poller is back below its soft limits.
+*/
+package guard
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"path"
+	"strings"
+	"sync"
+)
+
+type Level uint8
+
+const (
+	Normal Level = iota // below the soft limits
+	Soft                // above a soft limit
+	Hard                // above a hard limit, low-priority objects are shed
+)
+
+func (l Level) String() string {
+	switch l {
+	case Soft:
+		return "soft"
+	case Hard:
+		return "hard"
+	}
+	return "normal"
+}
+
+// Guard holds the limits of a poller and the series its collectors exported. A nil Guard has no limits.
+// It is safe for concurrent use
+type Guard struct {
+	limits conf.ResourceGuard
+	mu     sync.RWMutex
+	series map[string]uint64
+	level  Level
+}
+
+// New validates limits and returns a Guard, or nil when limits is nil
+func New(limits *conf.ResourceGuard) (*Guard, error) {
+	if limits == nil {
+		return nil, nil
+	}
+	if limits.SoftMemoryMB < 0 || limits.HardMemoryMB < 0 || limits.SoftSeries < 0 || limits.HardSeries < 0 {
+		return nil, fmt.Errorf("resource_guard limits must not be negative")
+	}
+	if limits.SoftMemoryMB > 0 && limits.HardMemoryMB > 0 && limits.SoftMemoryMB > limits.HardMemoryMB {
+		return nil, fmt.Errorf("resource_guard soft_memory_mb %d must not be above hard_memory_mb %d", limits.SoftMemoryMB, limits.HardMemoryMB)
+	}
+	if limits.SoftSeries > 0 && limits.HardSeries > 0 && limits.SoftSeries > limits.HardSeries {
+		return nil, fmt.Errorf("resource_guard soft_series %d must not be above hard_series %d", limits.SoftSeries, limits.HardSeries)
+	}
+	for _, o := range limits.Shed {
+		if _, err := path.Match(o, ""); err != nil {
+			return nil, fmt.Errorf("resource_guard invalid shed object %q: %w", o, err)
+		}
+	}
+	return &Guard{limits: *limits, series: make(map[string]uint64)}, nil
+}
+
+// Record sets the number of series that collector exported in its last poll
+func (g *Guard) Record(collector string, series uint64) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series[collector] = series
+}
+
+// Series returns the number of series the collectors exported in their last poll
+func (g *Guard) Series() uint64 {
+	if g == nil {
+		return 0
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var total uint64
+	for _, s := range g.series {
+		total += s
+	}
+	return total
+}
+
+// Check updates the level of the poller from its RSS in bytes and the series its collectors exported.
+// Once above a hard limit, the level stays Hard until the poller is below its soft limits.
+// It returns the level, and the level before the check
+func (g *Guard) Check(rssBytes uint64) (Level, Level) {
+	if g == nil {
+		return Normal, Normal
+	}
+	series := g.Series()
+	rssMB := rssBytes / (1024 * 1024)
+
+	above := func(value uint64, limit int) bool {
+		return limit > 0 && value > uint64(limit)
+	}
+	level := Normal
+	switch {
+	case above(rssMB, g.limits.HardMemoryMB) || above(series, g.limits.HardSeries):
+		level = Hard
+	case above(rssMB, g.limits.SoftMemoryMB) || above(series, g.limits.SoftSeries):
+		level = Soft
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	previous := g.level
+	if previous == Hard && level == Soft {
+		level = Hard
+	}
+	g.level = level
+	return level, previous
+}
+
+// Level returns the level of the last check
+func (g *Guard) Level() Level {
+	if g == nil {
+		return Normal
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.level
+}
+
+// Sheds returns true when object is a low-priority object and the poller is above a hard limit.
+// Matching is case-insensitive
+func (g *Guard) Sheds(object string) bool {
+	if g == nil || g.Level() != Hard {
+		return false
+	}
+	object = strings.ToLower(object)
+	for _, o := range g.limits.Shed {
+		if ok, _ := path.Match(strings.ToLower(o), object); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HardMemoryBytes returns the hard memory limit in bytes, or 0 when there is none
+func (g *Guard) HardMemoryBytes() int64 {
+	if g == nil {
+		return 0
+	}
+	return int64(g.limits.HardMemoryMB) * 1024 * 1024
+}
@@ -0,0 +1,80 @@
+package guard
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"testing"
+)
+
+const mb = 1024 * 1024
+
+func TestGuard_Check(t *testing.T) {
+	g, err := New(&conf.ResourceGuard{
+		SoftMemoryMB: 500,
+		HardMemoryMB: 800,
+		SoftSeries:   1000,
+		HardSeries:   2000,
+		Shed:         []string{"Workload*", "nfs_clients"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// steps run in order, since the level depends on the previous one
+	steps := []struct {
+		name      string
+		rssMB     uint64
+		series    uint64
+		want      Level
+		wantSheds bool
+	}{
+		{name: "below soft", rssMB: 100, series: 10, want: Normal},
+		{name: "soft memory", rssMB: 600, series: 10, want: Soft},
+		{name: "soft series", rssMB: 100, series: 1500, want: Soft},
+		{name: "hard series", rssMB: 100, series: 2500, want: Hard, wantSheds: true},
+		{name: "stays hard until below soft", rssMB: 600, series: 10, want: Hard, wantSheds: true},
+		{name: "recovered", rssMB: 100, series: 10, want: Normal},
+		{name: "hard memory", rssMB: 900, series: 10, want: Hard, wantSheds: true},
+	}
+	for _, step := range steps {
+		g.Record("ZapiPerf:Volume", step.series)
+		if got, _ := g.Check(step.rssMB * mb); got != step.want {
+			t.Errorf("%s: Check() got=%s, want=%s", step.name, got, step.want)
+		}
+		if got := g.Sheds("WorkloadDetail"); got != step.wantSheds {
+			t.Errorf("%s: Sheds(WorkloadDetail) got=%t, want=%t", step.name, got, step.wantSheds)
+		}
+		if g.Sheds("Volume") {
+			t.Errorf("%s: Sheds(Volume) should be false, Volume is not a low-priority object", step.name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  *conf.ResourceGuard
+		wantErr bool
+	}{
+		{name: "no limits", limits: nil},
+		{name: "valid", limits: &conf.ResourceGuard{SoftMemoryMB: 1, HardMemoryMB: 2, Shed: []string{"Workload"}}},
+		{name: "soft above hard", limits: &conf.ResourceGuard{SoftSeries: 2, HardSeries: 1}, wantErr: true},
+		{name: "negative", limits: &conf.ResourceGuard{HardMemoryMB: -1}, wantErr: true},
+		{name: "invalid pattern", limits: &conf.ResourceGuard{Shed: []string{"["}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.limits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if tt.limits == nil && g != nil {
+				t.Errorf("New() expected a nil guard without limits")
+			}
+		})
+	}
+
+	var g *Guard
+	if level, _ := g.Check(1 << 40); level != Normal || g.Sheds("Workload") {
+		t.Errorf("a nil guard should have no limits")
+	}
+}
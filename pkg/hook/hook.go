@@ -0,0 +1,205 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package hook runs the scripts of a poller around the polls of its collectors, e.g. to open a firewall rule or refresh
+a Kerberos ticket before a cluster is scraped.
+
+A hook has a script for each event: before a poll (pre_poll), after a poll and its export (post_poll), and when a task
+of the poll fails (on_failure). Each script receives the Context of the poll as JSON on stdin, and is killed when it
+runs longer than the timeout of its hook. When a pre_poll script fails, the poll continues, or is skipped,
+depending on the policy of its hook.
+*/
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+type Event string
+
+const (
+	PrePoll   Event = "pre_poll"
+	PostPoll  Event = "post_poll"
+	OnFailure Event = "on_failure"
+)
+
+type Policy string
+
+const (
+	Continue Policy = "continue" // the poll continues when the pre_poll script fails
+	Skip     Policy = "skip"     // the poll is skipped when the pre_poll script fails
+)
+
+// DefaultTimeout is how long a script can run
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput is the size of the output of a failed script included in its error
+const maxOutput = 512
+
+// Context is the context of a poll, written to the stdin of the scripts
+type Context struct {
+	Event      Event     `json:"event"`
+	Poller     string    `json:"poller"`
+	Datacenter string    `json:"datacenter,omitempty"`
+	Addr       string    `json:"addr,omitempty"`
+	Collector  string    `json:"collector"`
+	Object     string    `json:"object"`
+	Task       string    `json:"task,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+type hook struct {
+	objects []string
+	scripts map[Event]string
+	timeout time.Duration
+	policy  Policy
+}
+
+// Hooks are the hooks of a poller. Nil Hooks have no hooks.
+// They are safe for concurrent use
+type Hooks struct {
+	hooks []hook
+}
+
+// New validates the hooks of a poller and returns Hooks, or nil when there are none
+func New(hooks []conf.Hook) (*Hooks, error) {
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	h := &Hooks{}
+	for i, c := range hooks {
+		if c.PrePoll == "" && c.PostPoll == "" && c.OnFailure == "" {
+			return nil, fmt.Errorf("hooks[%d]: needs a %s, %s, or %s script", i, PrePoll, PostPoll, OnFailure)
+		}
+		timeout := DefaultTimeout
+		if c.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("hooks[%d]: timeout %q must be a positive duration", i, c.Timeout)
+			}
+		}
+		policy := Continue
+		switch Policy(c.OnError) {
+		case "", Continue:
+		case Skip:
+			policy = Skip
+		default:
+			return nil, fmt.Errorf("hooks[%d]: on_error %q must be %s or %s", i, c.OnError, Continue, Skip)
+		}
+		for _, o := range c.Objects {
+			if _, err := path.Match(o, ""); err != nil {
+				return nil, fmt.Errorf("hooks[%d]: invalid object %q: %w", i, o, err)
+			}
+		}
+		h.hooks = append(h.hooks, hook{
+			objects: c.Objects,
+			scripts: map[Event]string{PrePoll: c.PrePoll, PostPoll: c.PostPoll, OnFailure: c.OnFailure},
+			timeout: timeout,
+			policy:  policy,
+		})
+	}
+	return h, nil
+}
+
+// Has returns true when a hook of object has a script for event
+func (h *Hooks) Has(event Event, object string) bool {
+	if h == nil {
+		return false
+	}
+	for _, k := range h.hooks {
+		if k.scripts[event] != "" && matchesObject(k.objects, object) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the scripts for c.Event of the hooks of c.Object, in order, and returns their errors.
+// skip is true when a pre_poll script of a hook with the skip policy failed
+func (h *Hooks) Run(c Context) (skip bool, err error) {
+	if h == nil {
+		return false, nil
+	}
+	var errs []error
+	for _, k := range h.hooks {
+		script := k.scripts[c.Event]
+		if script == "" || !matchesObject(k.objects, c.Object) {
+			continue
+		}
+		if runErr := run(script, c, k.timeout); runErr != nil {
+			errs = append(errs, runErr)
+			if c.Event == PrePoll && k.policy == Skip {
+				skip = true
+			}
+		}
+	}
+	return skip, errors.Join(errs...)
+}
+
+// run runs script with c on its stdin, and kills it, and the processes it forked, after timeout
+func run(script string, c Context, timeout time.Duration) error {
+	lookPath, err := exec.LookPath(script)
+	if err != nil {
+		return fmt.Errorf("%s script lookup failed: %w", c.Event, err)
+	}
+	stdin, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, lookPath) // #nosec
+	setProcessGroup(cmd)
+	// on timeout, kill the processes the script forked too, they would hold its output open until WaitDelay
+	cmd.Cancel = func() error {
+		killProcessGroup(cmd)
+		return nil
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = timeout
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s script %s failed to start: %w", c.Event, lookPath, err)
+	}
+	defer killProcessGroup(cmd)
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		out := strings.TrimSpace(output.String())
+		if len(out) > maxOutput {
+			out = out[:maxOutput] + "..."
+		}
+		return fmt.Errorf("%s script %s failed: %w output=%q", c.Event, lookPath, err, out)
+	}
+	return nil
+}
+
+func matchesObject(objects []string, object string) bool {
+	if len(objects) == 0 {
+		return true
+	}
+	object = strings.ToLower(object)
+	for _, o := range objects {
+		if ok, _ := path.Match(strings.ToLower(o), object); ok {
+			return true
+		}
+	}
+	return false
+}
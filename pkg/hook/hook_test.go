@@ -0,0 +1,123 @@
+package hook
+
+import (
+	"encoding/json"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func script(t *testing.T, dir string, name string, body string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   []conf.Hook
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "valid", hooks: []conf.Hook{{PrePoll: "a.sh", Timeout: "5s", OnError: "skip", Objects: []string{"Volume*"}}}},
+		{name: "no script", hooks: []conf.Hook{{Timeout: "5s"}}, wantErr: true},
+		{name: "invalid timeout", hooks: []conf.Hook{{PrePoll: "a.sh", Timeout: "soon"}}, wantErr: true},
+		{name: "invalid policy", hooks: []conf.Hook{{PrePoll: "a.sh", OnError: "retry"}}, wantErr: true},
+		{name: "invalid object", hooks: []conf.Hook{{PrePoll: "a.sh", Objects: []string{"[Volume"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.hooks)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "context.json")
+	record := script(t, dir, "record.sh", "cat > "+out)
+	fail := script(t, dir, "fail.sh", "echo firewall unavailable; exit 1")
+	slow := script(t, dir, "slow.sh", "sleep 5")
+
+	hooks, err := New([]conf.Hook{
+		{Objects: []string{"Volume"}, PrePoll: record, PostPoll: fail},
+		{Objects: []string{"Qos*"}, PrePoll: fail, OnError: "skip"},
+		{Objects: []string{"Lun"}, PrePoll: slow, Timeout: "100ms", OnError: "skip"},
+		{Objects: []string{"Aggr"}, PrePoll: fail},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		event    Event
+		object   string
+		wantSkip bool
+		wantErr  string
+	}{
+		{name: "recorded", event: PrePoll, object: "volume"},
+		{name: "failed post poll", event: PostPoll, object: "Volume", wantErr: "firewall unavailable"},
+		{name: "skipped", event: PrePoll, object: "QosWorkload", wantSkip: true, wantErr: "exit status 1"},
+		{name: "timed out", event: PrePoll, object: "Lun", wantSkip: true, wantErr: "timed out"},
+		{name: "continued", event: PrePoll, object: "Aggr", wantErr: "exit status 1"},
+		{name: "no hook", event: PrePoll, object: "Disk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skip, err := hooks.Run(Context{Event: tt.event, Poller: "cluster-01", Object: tt.object})
+			if skip != tt.wantSkip {
+				t.Errorf("Run() skip=%v, want=%v", skip, tt.wantSkip)
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Run() err=%v, want=%q", err, tt.wantErr)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Context
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != PrePoll || got.Poller != "cluster-01" || got.Object != "volume" {
+		t.Errorf("context got=%+v", got)
+	}
+
+	var none *Hooks
+	if skip, err := none.Run(Context{Event: PrePoll}); skip || err != nil {
+		t.Errorf("nil Hooks Run() got skip=%v err=%v", skip, err)
+	}
+}
+
+func TestRunTimeoutForked(t *testing.T) {
+	dir := t.TempDir()
+	// the forked sleep holds the output of the script open after the script is killed
+	forked := script(t, dir, "forked.sh", "sleep 5 & wait")
+
+	hooks, err := New([]conf.Hook{{PrePoll: forked, Timeout: "500ms"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = hooks.Run(Context{Event: PrePoll, Poller: "cluster-01", Object: "Volume"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Run() err=%v, want timed out", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Run() took %s, want the forked processes killed at the timeout", elapsed)
+	}
+}
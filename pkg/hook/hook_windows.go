@@ -0,0 +1,15 @@
+//go:build windows
+
+package hook
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, which has no process groups that can be killed together
+func setProcessGroup(*exec.Cmd) {}
+
+// killProcessGroup kills the started cmd. The processes it started are not killed
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,122 @@
+package lease
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ConsulLock stores the lease in the Consul key-value store, with a session that expires when it is not renewed.
+// The address defaults to CONSUL_HTTP_ADDR, and the ACL token is read from CONSUL_HTTP_TOKEN
+type ConsulLock struct {
+	address string
+	key     string
+	token   string
+	session string
+	client  *http.Client
+}
+
+func NewConsulLock(address string, name string) *ConsulLock {
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &ConsulLock{
+		address: strings.TrimSuffix(address, "/"),
+		key:     "harvest/lease/" + name,
+		token:   os.Getenv("CONSUL_HTTP_TOKEN"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *ConsulLock) Acquire(holder string, duration time.Duration) (bool, error) {
+	if err := c.renewSession(holder, duration); err != nil {
+		return false, err
+	}
+	var acquired bool
+	err := c.put("/v1/kv/"+c.key+"?acquire="+url.QueryEscape(c.session), []byte(holder), &acquired)
+	return acquired, err
+}
+
+func (c *ConsulLock) Release(string) error {
+	if c.session == "" {
+		return nil
+	}
+	var released bool
+	err := c.put("/v1/kv/"+c.key+"?release="+url.QueryEscape(c.session), nil, &released)
+	// destroying the session releases the lease too
+	if destroyErr := c.put("/v1/session/destroy/"+c.session, nil, nil); err == nil {
+		err = destroyErr
+	}
+	c.session = ""
+	return err
+}
+
+// renewSession renews the session of the lease, or creates one when it does not exist or expired
+func (c *ConsulLock) renewSession(holder string, duration time.Duration) error {
+	if c.session != "" {
+		err := c.put("/v1/session/renew/"+c.session, nil, nil)
+		if err == nil {
+			return nil
+		}
+		if !isNotFound(err) {
+			return err
+		}
+		c.session = ""
+	}
+	body, err := json.Marshal(map[string]string{
+		"Name": holder,
+		"TTL":  duration.String(),
+		// the key is deleted when the session expires, and the standby can acquire it immediately
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	})
+	if err != nil {
+		return err
+	}
+	var session struct {
+		ID string `json:"ID"`
+	}
+	if err := c.put("/v1/session/create", body, &session); err != nil {
+		return err
+	}
+	c.session = session.ID
+	return nil
+}
+
+func (c *ConsulLock) put(path string, body []byte, result any) error {
+	req, err := http.NewRequest(http.MethodPut, c.address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return statusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
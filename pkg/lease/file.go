@@ -0,0 +1,95 @@
+package lease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// record is the lease stored by FileLock
+type record struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileLock stores the lease in a file on a file system shared by the pollers, e.g. NFS.
+// The clocks of the pollers must be in sync
+type FileLock struct {
+	path string
+}
+
+func NewFileLock(dir string, name string) *FileLock {
+	return &FileLock{path: filepath.Join(dir, name+".lease")}
+}
+
+func (f *FileLock) Acquire(holder string, duration time.Duration) (bool, error) {
+	return f.update(func(r *record, now time.Time) bool {
+		if r.Holder != "" && r.Holder != holder && now.Before(r.Expires) {
+			return false
+		}
+		r.Holder = holder
+		r.Expires = now.Add(duration)
+		return true
+	})
+}
+
+func (f *FileLock) Release(holder string) error {
+	_, err := f.update(func(r *record, _ time.Time) bool {
+		if r.Holder != holder {
+			return false
+		}
+		*r = record{}
+		return true
+	})
+	return err
+}
+
+// update changes the lease with change, and writes it when change returns true.
+// Pollers change the lease one at a time, with a mutex file created exclusively
+func (f *FileLock) update(change func(r *record, now time.Time) bool) (bool, error) {
+	mutex := f.path + ".lock"
+	m, err := os.OpenFile(mutex, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if errors.Is(err, os.ErrExist) {
+		// the other poller is changing the lease, or died while changing it
+		if info, statErr := os.Stat(mutex); statErr == nil && time.Since(info.ModTime()) > time.Minute {
+			_ = os.Remove(mutex)
+		}
+		return false, ErrBusy
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock lease: %w", err)
+	}
+	_ = m.Close()
+	//goland:noinspection GoUnhandledErrorResult
+	defer os.Remove(mutex)
+
+	var r record
+	data, err := os.ReadFile(f.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, fmt.Errorf("failed to read lease: %w", err)
+	default:
+		// a corrupt lease is replaced
+		_ = json.Unmarshal(data, &r)
+	}
+
+	if !change(&r, time.Now()) {
+		return false, nil
+	}
+	if data, err = json.Marshal(r); err != nil {
+		return false, err
+	}
+	// write and rename, so the lease is never partially written
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return false, fmt.Errorf("failed to write lease: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return false, fmt.Errorf("failed to write lease: %w", err)
+	}
+	return true, nil
+}
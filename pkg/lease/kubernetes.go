@@ -0,0 +1,201 @@
+package lease
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	// microTime is the format of the times of a Kubernetes Lease
+	microTime = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// KubernetesLock stores the lease in a Kubernetes Lease, with the service account of the poller's pod.
+// The service account needs get, create, and update permissions on leases in the namespace
+type KubernetesLock struct {
+	leases    string // URL of the leases of the namespace
+	name      string
+	tokenFile string
+	client    *http.Client
+}
+
+type k8sLease struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Metadata   k8sMetadata `json:"metadata"`
+	Spec       k8sSpec     `json:"spec"`
+}
+
+type k8sMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type k8sSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// NewKubernetesLock returns a lock of the Lease name in namespace, which defaults to the namespace of the pod
+func NewKubernetesLock(namespace string, name string) (*KubernetesLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("the kubernetes lock needs to run in a pod")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccount + "namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read namespace of pod: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	ca, err := os.ReadFile(serviceAccount + "ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate of cluster: %w", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+	}
+	return newKubernetesLock("https://"+net.JoinHostPort(host, port), serviceAccount+"token", namespace, name, client), nil
+}
+
+func newKubernetesLock(server string, tokenFile string, namespace string, name string, client *http.Client) *KubernetesLock {
+	return &KubernetesLock{
+		leases:    server + "/apis/coordination.k8s.io/v1/namespaces/" + namespace + "/leases",
+		name:      name,
+		tokenFile: tokenFile,
+		client:    client,
+	}
+}
+
+func (k *KubernetesLock) Acquire(holder string, duration time.Duration) (bool, error) {
+	now := time.Now()
+	var lease k8sLease
+	err := k.do(http.MethodGet, k.href(), nil, &lease)
+	if isNotFound(err) {
+		lease = k8sLease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   k8sMetadata{Name: k.name},
+			Spec: k8sSpec{
+				HolderIdentity:       holder,
+				LeaseDurationSeconds: seconds(duration),
+				AcquireTime:          now.UTC().Format(microTime),
+				RenewTime:            now.UTC().Format(microTime),
+			},
+		}
+		err = k.do(http.MethodPost, k.leases, lease, nil)
+		return conflict(err)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	spec := &lease.Spec
+	if spec.HolderIdentity != "" && spec.HolderIdentity != holder && !expired(spec, now) {
+		return false, nil
+	}
+	if spec.HolderIdentity != holder {
+		spec.HolderIdentity = holder
+		spec.AcquireTime = now.UTC().Format(microTime)
+		spec.LeaseTransitions++
+	}
+	spec.LeaseDurationSeconds = seconds(duration)
+	spec.RenewTime = now.UTC().Format(microTime)
+	// the update fails with a conflict when the other poller changed the lease since it was read
+	return conflict(k.do(http.MethodPut, k.href(), lease, nil))
+}
+
+func (k *KubernetesLock) Release(holder string) error {
+	var lease k8sLease
+	if err := k.do(http.MethodGet, k.href(), nil, &lease); err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity != holder {
+		return nil
+	}
+	lease.Spec.HolderIdentity = ""
+	_, err := conflict(k.do(http.MethodPut, k.href(), lease, nil))
+	return err
+}
+
+// expired returns true when the lease was not renewed within its duration
+func expired(spec *k8sSpec, now time.Time) bool {
+	renewed, err := time.Parse(microTime, spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second))
+}
+
+// conflict returns false, without an error, when the lease was created or changed by the other poller
+func conflict(err error) (bool, error) {
+	var s statusError
+	if errors.As(err, &s) && s.status == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func seconds(d time.Duration) int {
+	return max(int(d.Round(time.Second)/time.Second), 1)
+}
+
+func (k *KubernetesLock) href() string {
+	return k.leases + "/" + k.name
+}
+
+func (k *KubernetesLock) do(method string, href string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, href, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the token of the service account is rotated, and read for each request
+	if token, err := os.ReadFile(k.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
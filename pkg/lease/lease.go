@@ -0,0 +1,220 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package lease coordinates a pair of pollers that monitor the same cluster, an active poller and a warm standby.
+
+Both pollers collect, but only the poller that holds the lease exports data, so redundant pollers do not export
+duplicate series. The holder renews its lease periodically. When it stops renewing, e.g. because it crashed or lost
+its network, the lease expires and the standby acquires it, and starts exporting.
+
+The lease is stored in a file on a shared file system, in Consul, or in a Kubernetes Lease.
+*/
+package lease
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	File       = "file"
+	Consul     = "consul"
+	Kubernetes = "kubernetes"
+)
+
+// ErrBusy is returned when the lock is being changed by the other poller, and should be tried again
+var ErrBusy = errors.New("lease is busy")
+
+// DefaultDuration is how long a lease lasts without renewal
+const DefaultDuration = 30 * time.Second
+
+// Lock stores a lease
+type Lock interface {
+	// Acquire acquires the lease for holder, or renews it when holder already has it.
+	// It returns false when another holder has a lease that has not expired
+	Acquire(holder string, duration time.Duration) (bool, error)
+	// Release releases the lease when holder has it
+	Release(holder string) error
+}
+
+// Elector acquires and renews the lease of a poller. A nil Elector always holds the lease.
+// It is safe for concurrent use
+type Elector struct {
+	lock     Lock
+	holder   string
+	duration time.Duration
+	logger   *logging.Logger
+	holding  atomic.Bool
+	renewed  time.Time // last time the lease was acquired or renewed
+	stop     chan struct{}
+	stopped  bool
+	mu       sync.Mutex // serializes renewals and Stop
+}
+
+// New validates the lease of a poller and returns an Elector, or nil when the poller has no lease.
+// The name of the lease and the holder default to the poller's name, and the hostname and poller's name
+func New(l *conf.Lease, poller string, logger *logging.Logger) (*Elector, error) {
+	if l == nil {
+		return nil, nil
+	}
+	duration := DefaultDuration
+	if l.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(l.Duration); err != nil || duration <= 0 {
+			return nil, fmt.Errorf("lease: duration %q must be a positive duration", l.Duration)
+		}
+	}
+	name := l.Name
+	if name == "" {
+		name = poller
+	}
+	holder := l.Holder
+	if holder == "" {
+		hostname, _ := os.Hostname()
+		holder = hostname + ":" + poller
+	}
+
+	var lock Lock
+	switch l.Lock {
+	case File:
+		if l.Path == "" {
+			return nil, fmt.Errorf("lease: the %s lock needs a path", File)
+		}
+		lock = NewFileLock(l.Path, name)
+	case Consul:
+		// Consul sessions last at least 10s
+		if duration < 10*time.Second {
+			return nil, fmt.Errorf("lease: the %s lock needs a duration of at least 10s, got %s", Consul, duration)
+		}
+		lock = NewConsulLock(l.Address, name)
+	case Kubernetes:
+		var err error
+		if lock, err = NewKubernetesLock(l.Namespace, name); err != nil {
+			return nil, fmt.Errorf("lease: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("lease: lock %q must be one of %s, %s, or %s", l.Lock, File, Consul, Kubernetes)
+	}
+
+	return NewElector(lock, holder, duration, logger), nil
+}
+
+// NewElector returns an Elector of lock for holder
+func NewElector(lock Lock, holder string, duration time.Duration, logger *logging.Logger) *Elector {
+	return &Elector{
+		lock:     lock,
+		holder:   holder,
+		duration: duration,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+// IsHolder returns true when the poller holds the lease, and should export
+func (e *Elector) IsHolder() bool {
+	return e == nil || e.holding.Load()
+}
+
+// Holder returns the identity of the poller in the lease
+func (e *Elector) Holder() string {
+	if e == nil {
+		return ""
+	}
+	return e.holder
+}
+
+// Start tries to acquire the lease, and keeps renewing or acquiring it in the background until Stop
+func (e *Elector) Start() {
+	if e == nil {
+		return
+	}
+	e.Renew(time.Now())
+	go func() {
+		// renew often enough that a slow or failed renewal does not lose the lease
+		ticker := time.NewTicker(e.duration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				e.Renew(now)
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Renew acquires or renews the lease. When the lock can not be reached, the holder keeps the lease until it expires
+func (e *Elector) Renew(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	acquired, err := e.lock.Acquire(e.holder, e.duration)
+	wasHolding := e.holding.Load()
+	switch {
+	case err != nil:
+		if !errors.Is(err, ErrBusy) {
+			e.logger.Warn().Err(err).Str("holder", e.holder).Msg("Unable to renew lease")
+		}
+		if wasHolding && now.Sub(e.renewed) >= e.duration {
+			e.holding.Store(false)
+			e.logger.Warn().Str("holder", e.holder).Msg("Lease expired, standing by")
+		}
+	case acquired:
+		e.renewed = now
+		e.holding.Store(true)
+		if !wasHolding {
+			e.logger.Info().Str("holder", e.holder).Msg("Acquired lease, exporting")
+		}
+	default:
+		e.holding.Store(false)
+		if wasHolding {
+			e.logger.Warn().Str("holder", e.holder).Msg("Lost lease, standing by")
+		}
+	}
+}
+
+// Stop stops renewing the lease and releases it, so the standby does not wait for it to expire
+func (e *Elector) Stop() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	close(e.stop)
+	if e.holding.Swap(false) {
+		if err := e.lock.Release(e.holder); err != nil {
+			e.logger.Warn().Err(err).Str("holder", e.holder).Msg("Unable to release lease")
+		}
+	}
+}
+
+// statusError is the unexpected status of a response
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, e.body)
+}
+
+func isNotFound(err error) bool {
+	var s statusError
+	return errors.As(err, &s) && s.status == http.StatusNotFound
+}
@@ -0,0 +1,206 @@
+package lease
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLock(t *testing.T) {
+	dir := t.TempDir()
+	a := NewFileLock(dir, "cluster-01")
+	b := NewFileLock(dir, "cluster-01")
+
+	steps := []struct {
+		name     string
+		lock     *FileLock
+		holder   string
+		duration time.Duration
+		want     bool
+	}{
+		{name: "a acquires", lock: a, holder: "a", duration: time.Minute, want: true},
+		{name: "b stands by", lock: b, holder: "b", duration: time.Minute, want: false},
+		{name: "a renews", lock: a, holder: "a", duration: -time.Second, want: true},
+		{name: "b acquires expired", lock: b, holder: "b", duration: time.Minute, want: true},
+		{name: "a stands by", lock: a, holder: "a", duration: time.Minute, want: false},
+	}
+	for _, s := range steps {
+		got, err := s.lock.Acquire(s.holder, s.duration)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got != s.want {
+			t.Errorf("%s: Acquire() got=%v, want=%v", s.name, got, s.want)
+		}
+	}
+
+	if err := b.Release("b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := a.Acquire("a", time.Minute); !got {
+		t.Errorf("Acquire() after Release got=false, want=true")
+	}
+}
+
+type fakeLock struct {
+	acquired bool
+	err      error
+	released bool
+}
+
+func (f *fakeLock) Acquire(string, time.Duration) (bool, error) {
+	return f.acquired, f.err
+}
+
+func (f *fakeLock) Release(string) error {
+	f.released = true
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	lock := &fakeLock{acquired: true}
+	e := NewElector(lock, "a", 30*time.Second, logging.Get())
+	start := time.Now()
+
+	steps := []struct {
+		name     string
+		acquired bool
+		err      error
+		at       time.Duration
+		want     bool
+	}{
+		{name: "acquired", acquired: true, want: true},
+		{name: "unreachable", err: errors.New("timeout"), at: 10 * time.Second, want: true},
+		{name: "unreachable and expired", err: errors.New("timeout"), at: 30 * time.Second, want: false},
+		{name: "reacquired", acquired: true, at: 40 * time.Second, want: true},
+		{name: "lost", at: 50 * time.Second, want: false},
+	}
+	for _, s := range steps {
+		lock.acquired, lock.err = s.acquired, s.err
+		e.Renew(start.Add(s.at))
+		if got := e.IsHolder(); got != s.want {
+			t.Errorf("%s: IsHolder() got=%v, want=%v", s.name, got, s.want)
+		}
+	}
+
+	lock.acquired, lock.err = true, nil
+	e.Renew(start)
+	e.Stop()
+	if !lock.released || e.IsHolder() {
+		t.Errorf("Stop() released=%v holder=%v, want released and not holder", lock.released, e.IsHolder())
+	}
+
+	var none *Elector
+	if !none.IsHolder() {
+		t.Errorf("nil Elector IsHolder() got=false, want=true")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		lease   *conf.Lease
+		wantErr bool
+	}{
+		{name: "none", lease: nil},
+		{name: "file", lease: &conf.Lease{Lock: File, Path: t.TempDir(), Duration: "1m"}},
+		{name: "file without path", lease: &conf.Lease{Lock: File}, wantErr: true},
+		{name: "consul", lease: &conf.Lease{Lock: Consul}},
+		{name: "consul too short", lease: &conf.Lease{Lock: Consul, Duration: "5s"}, wantErr: true},
+		{name: "invalid duration", lease: &conf.Lease{Lock: File, Path: "/tmp", Duration: "soon"}, wantErr: true},
+		{name: "unknown lock", lease: &conf.Lease{Lock: "etcd"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.lease, "cluster-01", logging.Get())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeLeases is a Kubernetes API server with the leases of a namespace
+type fakeLeases struct {
+	mu      sync.Mutex
+	leases  map[string]k8sLease
+	version int
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const prefix = "/apis/coordination.k8s.io/v1/namespaces/harvest/leases"
+	name := r.URL.Path[len(prefix):]
+	var lease k8sLease
+	if r.Method != http.MethodGet {
+		_ = json.NewDecoder(r.Body).Decode(&lease)
+	}
+	current, ok := f.leases[name]
+	switch r.Method {
+	case http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(current)
+		return
+	case http.MethodPost:
+		name = "/" + lease.Metadata.Name
+		if _, ok := f.leases[name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	case http.MethodPut:
+		if lease.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+	f.version++
+	lease.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.leases[name] = lease
+}
+
+func TestKubernetesLock(t *testing.T) {
+	fake := &fakeLeases{leases: make(map[string]k8sLease)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	a := newKubernetesLock(server.URL, "", "harvest", "cluster-01", server.Client())
+	b := newKubernetesLock(server.URL, "", "harvest", "cluster-01", server.Client())
+
+	if got, err := a.Acquire("a", time.Minute); err != nil || !got {
+		t.Fatalf("create Acquire() got=%v err=%v, want=true", got, err)
+	}
+	if got, err := b.Acquire("b", time.Minute); err != nil || got {
+		t.Errorf("held Acquire() got=%v err=%v, want=false", got, err)
+	}
+	if got, err := a.Acquire("a", time.Minute); err != nil || !got {
+		t.Errorf("renew Acquire() got=%v err=%v, want=true", got, err)
+	}
+
+	// the lease of a expires
+	lease := fake.leases["/cluster-01"]
+	lease.Spec.RenewTime = time.Now().Add(-2 * time.Minute).UTC().Format(microTime)
+	fake.leases["/cluster-01"] = lease
+	if got, err := b.Acquire("b", time.Minute); err != nil || !got {
+		t.Errorf("expired Acquire() got=%v err=%v, want=true", got, err)
+	}
+	if got := fake.leases["/cluster-01"].Spec; got.HolderIdentity != "b" || got.LeaseTransitions != 1 {
+		t.Errorf("lease got=%+v, want holder b after 1 transition", got)
+	}
+
+	if err := b.Release("b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.Acquire("a", time.Minute); err != nil || !got {
+		t.Errorf("released Acquire() got=%v err=%v, want=true", got, err)
+	}
+}
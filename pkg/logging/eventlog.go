@@ -0,0 +1,33 @@
+package logging
+
+import "github.com/rs/zerolog"
+
+// DefaultEventLogSource is the source of the events Harvest writes to the Windows Event Log
+const DefaultEventLogSource = "Harvest"
+
+// eventID of the events. Sources registered with EventCreate support IDs 1 to 1000
+const eventID = 1
+
+type severity int
+
+const (
+	severityNone severity = iota // not written to the event log
+	severityInfo
+	severityWarning
+	severityError
+)
+
+// eventSeverity returns the severity of the events of level.
+// Debug and trace events are too verbose for the event log
+func eventSeverity(level zerolog.Level) severity {
+	switch level {
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return severityInfo
+	case zerolog.WarnLevel:
+		return severityWarning
+	case zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel:
+		return severityError
+	default:
+		return severityNone
+	}
+}
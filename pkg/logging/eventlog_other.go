@@ -0,0 +1,17 @@
+//go:build !windows
+
+package logging
+
+import (
+	"errors"
+	"github.com/rs/zerolog"
+)
+
+func newEventLogWriter(string) (zerolog.LevelWriter, error) {
+	return nil, errors.New("the event log is only available on Windows")
+}
+
+// IsService returns true when the process runs as a Windows service
+func IsService() bool {
+	return false
+}
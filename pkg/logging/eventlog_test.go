@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+func TestEventSeverity(t *testing.T) {
+	tests := []struct {
+		level zerolog.Level
+		want  severity
+	}{
+		{level: zerolog.TraceLevel, want: severityNone},
+		{level: zerolog.DebugLevel, want: severityNone},
+		{level: zerolog.InfoLevel, want: severityInfo},
+		{level: zerolog.NoLevel, want: severityInfo},
+		{level: zerolog.WarnLevel, want: severityWarning},
+		{level: zerolog.ErrorLevel, want: severityError},
+		{level: zerolog.FatalLevel, want: severityError},
+		{level: zerolog.PanicLevel, want: severityError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			if got := eventSeverity(tt.level); got != tt.want {
+				t.Errorf("eventSeverity() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package logging
+
+import (
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"strings"
+)
+
+// eventLogWriter writes events to the Windows Event Log, with the severity of their level
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+func newEventLogWriter(source string) (zerolog.LevelWriter, error) {
+	// Registering the source requires administrator rights, which services usually have.
+	// The error is ignored, since it also fails when the source is already registered
+	_ = eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogWriter{log: l}, nil
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	switch eventSeverity(level) {
+	case severityInfo:
+		err = w.log.Info(eventID, msg)
+	case severityWarning:
+		err = w.log.Warning(eventID, msg)
+	case severityError:
+		err = w.log.Error(eventID, msg)
+	case severityNone:
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// IsService returns true when the process runs as a Windows service
+func IsService() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// levels are the log levels of collectors that were changed at runtime. They are keyed by collector, e.g. Zapi,
+// or by collector and object, e.g. Zapi:Volume. The level of an object wins over the level of its collector,
+// and objects without a level use the level of the poller
+var levels = struct {
+	sync.RWMutex
+	base      zerolog.Level
+	overrides map[string]zerolog.Level
+}{base: defaultLogLevel}
+
+// LevelOverride is the log level of a collector, or of an object of a collector, of a poller set with the admin API.
+// The level is reverted when it expires
+type LevelOverride struct {
+	Poller    string    `json:"poller"`
+	Collector string    `json:"collector"`
+	Level     string    `json:"level"`
+	Until     time.Time `json:"until"`
+}
+
+// unhooked is the configured logger without the level hook of the poller, loggers of collectors add their own
+var unhooked zerolog.Logger
+
+// levelHook discards the events below the level of its key. The global level of zerolog is the lowest level
+// of all keys, so events of collectors that are debugged are not dropped before the hook sees them
+type levelHook struct {
+	key string
+}
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level < LevelOf(h.key) {
+		e.Discard()
+	}
+}
+
+// ForObject returns a logger that logs at the level of the object of collector, see SetLevels
+func ForObject(collector string, object string) *Logger {
+	Get()
+	l := unhooked.Hook(levelHook{key: collector + ":" + object})
+	return &Logger{Logger: &l}
+}
+
+// LevelOf returns the log level of key, a collector and object separated by a colon, or the level
+// of the poller when key is empty
+func LevelOf(key string) zerolog.Level {
+	levels.RLock()
+	defer levels.RUnlock()
+	if level, ok := levels.overrides[key]; ok {
+		return level
+	}
+	if collector, _, ok := strings.Cut(key, ":"); ok {
+		if level, ok := levels.overrides[collector]; ok {
+			return level
+		}
+	}
+	return levels.base
+}
+
+// SetLevels replaces the log levels of collectors and objects. Collectors and objects that are not
+// in overrides log at the level of the poller
+func SetLevels(overrides map[string]zerolog.Level) {
+	levels.Lock()
+	defer levels.Unlock()
+	levels.overrides = maps.Clone(overrides)
+	setGlobalLevel()
+}
+
+// Levels returns the log levels of collectors and objects set with SetLevels
+func Levels() map[string]zerolog.Level {
+	levels.RLock()
+	defer levels.RUnlock()
+	return maps.Clone(levels.overrides)
+}
+
+func setBaseLevel(level zerolog.Level) {
+	levels.Lock()
+	defer levels.Unlock()
+	levels.base = level
+	setGlobalLevel()
+}
+
+// setGlobalLevel sets the global level of zerolog to the lowest level. The caller must hold the lock
+func setGlobalLevel() {
+	lowest := levels.base
+	for _, level := range levels.overrides {
+		lowest = min(lowest, level)
+	}
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// ParseLevel returns the level named name, one of trace, debug, info, warn, or error
+func ParseLevel(name string) (zerolog.Level, error) {
+	switch strings.ToLower(name) {
+	case "trace":
+		return zerolog.TraceLevel, nil
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "warn", "warning":
+		return zerolog.WarnLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	}
+	return zerolog.NoLevel, fmt.Errorf("invalid log level %q, use one of trace, debug, info, warn, or error", name)
+}
+
+// ParseLevels parses the log levels of collectors and objects, see ParseLevel
+func ParseLevels(names map[string]string) (map[string]zerolog.Level, error) {
+	parsed := make(map[string]zerolog.Level, len(names))
+	var errList []error
+	for key, name := range names {
+		level, err := ParseLevel(name)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		parsed[key] = level
+	}
+	return parsed, errors.Join(errList...)
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+func TestLevelHook(t *testing.T) {
+	defer SetLevels(nil)
+	setBaseLevel(zerolog.InfoLevel)
+	SetLevels(map[string]zerolog.Level{
+		"ZapiPerf":        zerolog.DebugLevel,
+		"ZapiPerf:Volume": zerolog.TraceLevel,
+		"Rest:Volume":     zerolog.ErrorLevel,
+	})
+
+	tests := []struct {
+		key   string
+		level zerolog.Level
+		want  bool
+	}{
+		{key: "", level: zerolog.DebugLevel, want: false},
+		{key: "", level: zerolog.InfoLevel, want: true},
+		{key: "ZapiPerf:Volume", level: zerolog.TraceLevel, want: true},
+		{key: "ZapiPerf:Lun", level: zerolog.TraceLevel, want: false},
+		{key: "ZapiPerf:Lun", level: zerolog.DebugLevel, want: true},
+		{key: "Rest:Volume", level: zerolog.WarnLevel, want: false},
+		{key: "Rest:Lun", level: zerolog.InfoLevel, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"/"+tt.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			l := zerolog.New(&buf).Hook(levelHook{key: tt.key})
+			l.WithLevel(tt.level).Msg("hello")
+			if got := buf.Len() > 0; got != tt.want {
+				t.Errorf("logged got=%t want=%t", got, tt.want)
+			}
+		})
+	}
+
+	if zerolog.GlobalLevel() != zerolog.TraceLevel {
+		t.Errorf("global level got=%s want=trace", zerolog.GlobalLevel())
+	}
+	SetLevels(nil)
+	if zerolog.GlobalLevel() != zerolog.InfoLevel {
+		t.Errorf("global level got=%s want=info", zerolog.GlobalLevel())
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	got, err := ParseLevels(map[string]string{"Zapi": "DEBUG", "Rest:Volume": "warning", "Ems": "2"})
+	if err == nil {
+		t.Errorf("expected an error for a numeric level")
+	}
+	want := map[string]zerolog.Level{"Zapi": zerolog.DebugLevel, "Rest:Volume": zerolog.WarnLevel}
+	if len(got) != len(want) || got["Zapi"] != want["Zapi"] || got["Rest:Volume"] != want["Rest:Volume"] {
+		t.Errorf("ParseLevels() got=%v want=%v", got, want)
+	}
+}
@@ -45,12 +45,21 @@ type LogConfig struct {
 	MaxBackups int
 	// MaxAge the max age in days to keep a logfile
 	MaxAge int
+	// RotateEvery rotates the logfile at this interval, in addition to when it reaches MaxSize. Zero disables it
+	RotateEvery time.Duration
+	// EventLogEnabled makes the framework log to the Windows Event Log
+	EventLogEnabled bool
+	// EventLogSource is the source of the events, DefaultEventLogSource when empty
+	EventLogSource string
 }
 
 var logger *Logger
 
 var once sync.Once
 
+// stopRotation stops the rotation of the logfile of the previous configuration
+var stopRotation chan struct{}
+
 type Logger struct {
 	*zerolog.Logger
 }
@@ -104,9 +113,20 @@ func Configure(config LogConfig) *Logger {
 	if config.FileLoggingEnabled {
 		writers = append(writers, newRollingFile(config))
 	}
+	var eventLogErr error
+	if config.EventLogEnabled {
+		source := config.EventLogSource
+		if source == "" {
+			source = DefaultEventLogSource
+		}
+		var w zerolog.LevelWriter
+		if w, eventLogErr = newEventLogWriter(source); eventLogErr == nil {
+			writers = append(writers, w)
+		}
+	}
 	multiWriters := zerolog.MultiLevelWriter(writers...)
 
-	zerolog.SetGlobalLevel(config.LogLevel)
+	setBaseLevel(config.LogLevel)
 	zerolog.ErrorStackMarshaler = MarshalStack //nolint:reassign
 	zerolog.CallerMarshalFunc = ShortFile
 	zeroLogger := zerolog.New(multiWriters).With().Caller().Str(config.PrefixKey, config.PrefixValue).Timestamp().Logger()
@@ -122,10 +142,18 @@ func Configure(config LogConfig) *Logger {
 		Int("maxSizeMB", config.MaxSize).
 		Int("maxBackups", config.MaxBackups).
 		Int("maxAgeInDays", config.MaxAge).
+		Str("rotateEvery", config.RotateEvery.String()).
+		Bool("eventLog", config.EventLogEnabled).
 		Msg("logging configured")
 
+	if eventLogErr != nil {
+		zeroLogger.Warn().Err(eventLogErr).Msg("Unable to log to the event log")
+	}
+
+	unhooked = zeroLogger
+	hooked := zeroLogger.Hook(levelHook{})
 	logger = &Logger{
-		Logger: &zeroLogger,
+		Logger: &hooked,
 	}
 	return logger
 }
@@ -164,15 +192,39 @@ func MarshalStack(err error) interface{} {
 	return string(trace)
 }
 
-// returns lumberjack writer
+// returns lumberjack writer, rotated every config.RotateEvery when set
 func newRollingFile(config LogConfig) io.Writer {
-	return &lumberjack.Logger{
+	l := &lumberjack.Logger{
 		Filename:   path.Join(config.Directory, config.Filename),
 		MaxBackups: config.MaxBackups, // files
 		MaxSize:    config.MaxSize,    // megabytes
 		MaxAge:     config.MaxAge,     // days
 		Compress:   true,
 	}
+	if stopRotation != nil {
+		close(stopRotation)
+		stopRotation = nil
+	}
+	if config.RotateEvery > 0 {
+		stopRotation = make(chan struct{})
+		go rotate(l, config.RotateEvery, stopRotation)
+	}
+	return l
+}
+
+// rotate rotates l every interval until stop is closed.
+// The file is rotated by the process that writes it, which also works on Windows, where open files can not be moved
+func rotate(l *lumberjack.Logger, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = l.Rotate()
+		case <-stop:
+			return
+		}
+	}
 }
 
 // GetZerologLevel returns log level mapping
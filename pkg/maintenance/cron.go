@@ -0,0 +1,133 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression with five fields: minute, hour, day of month, month, and day of week.
+// Fields support *, lists (1,15), ranges (1-5), and steps (*/15, 0-30/10). Day of week 0 and 7 are Sunday.
+// As in cron, when both day of month and day of week are restricted, a time matches when either matches
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // bit i is set when value i matches
+	domAny, dowAny                bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a cron expression, e.g. "0 2 * * 6" is every Saturday at 02:00
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields, got %d", expr, len(cronFields), len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q %s: %w", expr, cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+	c := &Cron{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepS, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepS); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepS)
+			}
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches returns true when the minute of t matches the expression
+func (c *Cron) Matches(t time.Time) bool {
+	if !has(c.minute, t.Minute()) || !has(c.hour, t.Hour()) || !has(c.month, int(t.Month())) {
+		return false
+	}
+	domMatch := has(c.dom, t.Day())
+	dowMatch := has(c.dow, int(t.Weekday()))
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// LastStart returns the latest time in (t-within, t] that matches the expression, truncated to the minute
+func (c *Cron) LastStart(t time.Time, within time.Duration) (time.Time, bool) {
+	earliest := t.Add(-within)
+	for m := t.Truncate(time.Minute); m.After(earliest); m = m.Add(-time.Minute) {
+		if c.Matches(m) {
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package maintenance decides when a poller, or some of its objects, are in a maintenance window.
+During a window, collectors continue to collect, but their data is either not exported (suppress)
+or exported with a maintenance="true" label (tag).
+
+Windows are configured per poller with cron expressions, or set at runtime through the admin node.
+*/
+package maintenance
+
+import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Action string
+
+const (
+	Suppress Action = "suppress" // data is not exported
+	Tag      Action = "tag"      // data is exported with the maintenance label
+)
+
+// Label is the global label added to exported data when the action is Tag
+const Label = "maintenance"
+
+// Window is a window set at runtime through the admin node
+type Window struct {
+	Poller  string    `json:"poller"`
+	Objects []string  `json:"objects,omitempty"`
+	Action  Action    `json:"action,omitempty"`
+	Until   time.Time `json:"until"`
+}
+
+type scheduled struct {
+	cron     *Cron
+	duration time.Duration
+	action   Action
+	objects  []string
+}
+
+// Calendar holds the maintenance windows of a poller. A nil Calendar has no windows.
+// It is safe for concurrent use
+type Calendar struct {
+	mu        sync.RWMutex
+	scheduled []scheduled
+	runtime   []Window
+}
+
+// New validates the maintenance windows of a poller and returns a Calendar
+func New(windows []conf.MaintenanceWindow) (*Calendar, error) {
+	c := &Calendar{}
+	for i, w := range windows {
+		cron, err := ParseCron(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance[%d]: %w", i, err)
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("maintenance[%d]: duration %q must be a positive duration", i, w.Duration)
+		}
+		action, err := ParseAction(w.Action)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance[%d]: %w", i, err)
+		}
+		if err := validateObjects(w.Objects); err != nil {
+			return nil, fmt.Errorf("maintenance[%d]: %w", i, err)
+		}
+		c.scheduled = append(c.scheduled, scheduled{cron: cron, duration: duration, action: action, objects: w.Objects})
+	}
+	return c, nil
+}
+
+// ParseAction parses the action of a window. Empty means Suppress
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case "", Suppress:
+		return Suppress, nil
+	case Tag:
+		return Tag, nil
+	}
+	return "", fmt.Errorf("action %q must be one of %s, %s", s, Suppress, Tag)
+}
+
+func validateObjects(objects []string) error {
+	for _, o := range objects {
+		if _, err := path.Match(o, ""); err != nil {
+			return fmt.Errorf("invalid object %q: %w", o, err)
+		}
+	}
+	return nil
+}
+
+// SetRuntime replaces the windows set at runtime
+func (c *Calendar) SetRuntime(windows []Window) error {
+	for _, w := range windows {
+		if err := validateObjects(w.Objects); err != nil {
+			return err
+		}
+		if _, err := ParseAction(string(w.Action)); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runtime = windows
+	return nil
+}
+
+// Active returns the action of the windows that object is in at now.
+// When object is in several windows, Suppress wins over Tag
+func (c *Calendar) Active(object string, now time.Time) (Action, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var active Action
+	update := func(a Action) {
+		if a == "" {
+			a = Suppress
+		}
+		if active != Suppress {
+			active = a
+		}
+	}
+	for _, w := range c.runtime {
+		if now.Before(w.Until) && matchesObject(w.Objects, object) {
+			update(w.Action)
+		}
+	}
+	for _, s := range c.scheduled {
+		if !matchesObject(s.objects, object) {
+			continue
+		}
+		if _, ok := s.cron.LastStart(now, s.duration); ok {
+			update(s.action)
+		}
+	}
+	return active, active != ""
+}
+
+// matchesObject returns true when objects is empty, which means all objects, or one of its patterns matches object.
+// Matching is case-insensitive
+func matchesObject(objects []string, object string) bool {
+	if len(objects) == 0 {
+		return true
+	}
+	object = strings.ToLower(object)
+	for _, o := range objects {
+		if ok, _ := path.Match(strings.ToLower(o), object); ok {
+			return true
+		}
+	}
+	return false
+}
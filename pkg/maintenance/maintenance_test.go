@@ -0,0 +1,120 @@
+package maintenance
+
+import (
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	// Saturday
+	sat := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr    string
+		t       time.Time
+		want    bool
+		wantErr bool
+	}{
+		{expr: "0 2 * * 6", t: sat, want: true},
+		{expr: "0 2 * * 6", t: sat.Add(time.Minute), want: false},
+		{expr: "0 2 * * 0", t: sat, want: false},
+		{expr: "0 2 * * 7", t: sat.AddDate(0, 0, 1), want: true},
+		{expr: "*/15 * * * *", t: sat.Add(45 * time.Minute), want: true},
+		{expr: "*/15 * * * *", t: sat.Add(50 * time.Minute), want: false},
+		{expr: "0 1-3 15 6 *", t: sat, want: true},
+		{expr: "0 2 1,15 * *", t: sat, want: true},
+		{expr: "0 2 1 * 6", t: sat, want: true}, // day of month or day of week
+		{expr: "0 2 1 * 1", t: sat, want: false},
+		{expr: "0 10/4 * * *", t: sat.Add(12 * time.Hour), want: true},
+		{expr: "0 2 * *", wantErr: true},
+		{expr: "60 2 * * *", wantErr: true},
+		{expr: "0 2 * * mon", wantErr: true},
+		{expr: "*/0 2 * * *", wantErr: true},
+		{expr: "0 5-3 * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCron() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := c.Matches(tt.t); got != tt.want {
+				t.Errorf("Matches(%s) got=%t, want=%t", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	cal, err := New([]conf.MaintenanceWindow{
+		{Schedule: "0 2 * * 6", Duration: "2h", Action: "tag"},
+		{Schedule: "0 2 * * 6", Duration: "1h", Objects: []string{"volume*"}},
+	})
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	sat := time.Date(2024, 6, 15, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		object     string
+		now        time.Time
+		wantAction Action
+		wantActive bool
+	}{
+		{name: "before", object: "Aggregate", now: sat.Add(-time.Minute)},
+		{name: "start", object: "Aggregate", now: sat, wantAction: Tag, wantActive: true},
+		{name: "suppress wins", object: "Volume", now: sat.Add(30 * time.Minute), wantAction: Suppress, wantActive: true},
+		{name: "shorter window ended", object: "VolumeAnalytics", now: sat.Add(90 * time.Minute), wantAction: Tag, wantActive: true},
+		{name: "after", object: "Volume", now: sat.Add(2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, active := cal.Active(tt.object, tt.now)
+			if action != tt.wantAction || active != tt.wantActive {
+				t.Errorf("Active() got=%s,%t, want=%s,%t", action, active, tt.wantAction, tt.wantActive)
+			}
+		})
+	}
+
+	// runtime windows
+	err = cal.SetRuntime([]Window{{Poller: "u2", Objects: []string{"Aggregate"}, Until: sat.Add(-time.Hour)}})
+	if err != nil {
+		t.Fatalf("SetRuntime() err=%v", err)
+	}
+	if _, active := cal.Active("Aggregate", sat.Add(-2*time.Hour)); !active {
+		t.Errorf("runtime window should be active")
+	}
+	if _, active := cal.Active("Aggregate", sat.Add(-time.Hour)); active {
+		t.Errorf("runtime window should have ended")
+	}
+
+	var nilCal *Calendar
+	if _, active := nilCal.Active("Volume", sat); active {
+		t.Errorf("nil calendar should not be active")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		window conf.MaintenanceWindow
+	}{
+		{name: "schedule", window: conf.MaintenanceWindow{Schedule: "0 2 * *", Duration: "1h"}},
+		{name: "duration", window: conf.MaintenanceWindow{Schedule: "0 2 * * *", Duration: "soon"}},
+		{name: "no duration", window: conf.MaintenanceWindow{Schedule: "0 2 * * *"}},
+		{name: "action", window: conf.MaintenanceWindow{Schedule: "0 2 * * *", Duration: "1h", Action: "drop"}},
+		{name: "objects", window: conf.MaintenanceWindow{Schedule: "0 2 * * *", Duration: "1h", Objects: []string{"[vol"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New([]conf.MaintenanceWindow{tt.window}); err == nil {
+				t.Errorf("New() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+package matrix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// A minimal CBOR (RFC 8949) encoder and decoder for the serialization format of a Matrix.
+// The encoder writes definite lengths and sorted map keys. The decoder reads the values of any
+// encoder, except indefinite lengths, and returns maps as map[string]any
+
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+
+	cborFalse   = 20
+	cborTrue    = 21
+	cborNull    = 22
+	cborFloat16 = 25
+	cborFloat32 = 26
+	cborFloat64 = 27
+)
+
+// maxDepth limits the nesting of decoded values
+const maxDepth = 64
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+type cborEncoder struct {
+	buf []byte
+}
+
+func (e *cborEncoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major<<5|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major<<5|27), n)
+	}
+}
+
+func (e *cborEncoder) text(s string) {
+	e.head(cborText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *cborEncoder) uint(n uint64) {
+	e.head(cborUint, n)
+}
+
+func (e *cborEncoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, cborSimple<<5|cborTrue)
+	} else {
+		e.buf = append(e.buf, cborSimple<<5|cborFalse)
+	}
+}
+
+func (e *cborEncoder) null() {
+	e.buf = append(e.buf, cborSimple<<5|cborNull)
+}
+
+func (e *cborEncoder) float(f float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, cborSimple<<5|cborFloat64), math.Float64bits(f))
+}
+
+func (e *cborEncoder) array(n int) {
+	e.head(cborArray, uint64(n))
+}
+
+func (e *cborEncoder) mapHead(n int) {
+	e.head(cborMap, uint64(n))
+}
+
+// labels writes a map of strings with sorted keys
+func (e *cborEncoder) labels(labels map[string]string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	e.mapHead(len(keys))
+	for _, k := range keys {
+		e.text(k)
+		e.text(labels[k])
+	}
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decode returns the next value: uint64, int64, float64, string, []byte, bool, nil, []any, or map[string]any
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	if d.pos >= len(d.data) {
+		return nil, errCBORTruncated
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	if major == cborSimple {
+		switch info {
+		case cborFalse:
+			return false, nil
+		case cborTrue:
+			return true, nil
+		case cborNull, 23: // null and undefined
+			return nil, nil
+		case cborFloat16:
+			b, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return float16(binary.BigEndian.Uint16(b)), nil
+		case cborFloat32:
+			b, err := d.read(4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case cborFloat64:
+			b, err := d.read(8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	n, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return n, nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return slices.Clone(b), nil
+	case cborArray:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		values := make([]any, 0, n)
+		for range n {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case cborMap:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		values := make(map[string]any, n)
+		for range n {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %v is not a string", k)
+			}
+			if values[key], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default: // tags are ignored
+		return d.decode(depth + 1)
+	}
+}
+
+// argument returns the argument of a head with additional info
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		b, err := d.read(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(b[0]), nil
+	case info == 25:
+		b, err := d.read(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err := d.read(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err := d.read(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, errors.New("cbor: indefinite lengths are not supported")
+	}
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// float16 converts an IEEE 754 half-precision float
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+)
+
+// FormatVersion is the version of the serialization format of a Matrix.
+// It changes when a change of the format breaks decoders of the previous version.
+// Fields may be added without changing the version, decoders ignore the fields they do not know
+const FormatVersion = 1
+
+// MarshalBinary encodes the matrix as a CBOR (RFC 8949) map, so programs in any language can decode it.
+// The map has the text keys:
+//
+//	version        uint, FormatVersion
+//	uuid           text
+//	object         text
+//	identifier     text
+//	exportable     bool
+//	global_labels  map of text to text
+//	export_options node, or null, where a node is a map of name (text), content (text), and children (array of nodes)
+//	instances      array of maps of key (text), labels (map of text to text), exportable (bool), and partial (bool)
+//	metrics        array of maps of key, name, type, property, comment, unit (text), exportable, array, histogram (bool),
+//	               labels (map of text to text), buckets (array of text, or null), and values (array of float64, or
+//	               null when the instance has no value, in the order of instances). Values of uint64 metrics are
+//	               encoded as uint when they are a uint64, so counters larger than 2^53 keep their precision
+//
+// Instances and metrics are sorted by key
+func (m *Matrix) MarshalBinary() ([]byte, error) {
+	e := &cborEncoder{}
+	e.mapHead(9)
+	e.text("version")
+	e.uint(FormatVersion)
+	e.text("uuid")
+	e.text(m.UUID)
+	e.text("object")
+	e.text(m.Object)
+	e.text("identifier")
+	e.text(m.Identifier)
+	e.text("exportable")
+	e.bool(m.exportable)
+	e.text("global_labels")
+	e.labels(m.globalLabels)
+	e.text("export_options")
+	encodeNode(e, m.exportOptions)
+
+	instanceKeys := m.GetInstanceKeys()
+	slices.Sort(instanceKeys)
+	e.text("instances")
+	e.array(len(instanceKeys))
+	for _, key := range instanceKeys {
+		instance := m.instances[key]
+		e.mapHead(4)
+		e.text("key")
+		e.text(key)
+		e.text("labels")
+		e.labels(instance.labels)
+		e.text("exportable")
+		e.bool(instance.exportable)
+		e.text("partial")
+		e.bool(instance.partial)
+	}
+
+	metricKeys := make([]string, 0, len(m.metrics))
+	for key := range m.metrics {
+		metricKeys = append(metricKeys, key)
+	}
+	slices.Sort(metricKeys)
+	e.text("metrics")
+	e.array(len(metricKeys))
+	for _, key := range metricKeys {
+		metric := m.metrics[key]
+		e.mapHead(12)
+		for _, field := range [][2]string{
+			{"key", key}, {"name", metric.name}, {"type", metric.dataType},
+			{"property", metric.property}, {"comment", metric.comment}, {"unit", metric.unit},
+		} {
+			e.text(field[0])
+			e.text(field[1])
+		}
+		e.text("exportable")
+		e.bool(metric.exportable)
+		e.text("array")
+		e.bool(metric.array)
+		e.text("histogram")
+		e.bool(metric.histogram)
+		e.text("labels")
+		e.labels(metric.labels)
+		e.text("buckets")
+		if metric.buckets == nil {
+			e.null()
+		} else {
+			e.array(len(*metric.buckets))
+			for _, b := range *metric.buckets {
+				e.text(b)
+			}
+		}
+		e.text("values")
+		e.array(len(instanceKeys))
+		for _, instanceKey := range instanceKeys {
+			instance := m.instances[instanceKey]
+			v, ok := metric.GetValueFloat64(instance)
+			switch {
+			case !ok:
+				e.null()
+			case metric.exact != nil:
+				if u, exact := metric.exactValue(instance.index); exact {
+					e.uint(u)
+				} else {
+					e.float(v)
+				}
+			default:
+				e.float(v)
+			}
+		}
+	}
+	return e.buf, nil
+}
+
+func encodeNode(e *cborEncoder, n *node.Node) {
+	if n == nil {
+		e.null()
+		return
+	}
+	e.mapHead(3)
+	e.text("name")
+	e.text(n.GetNameS())
+	e.text("content")
+	e.text(n.GetContentS())
+	e.text("children")
+	e.array(len(n.GetChildren()))
+	for _, child := range n.GetChildren() {
+		encodeNode(e, child)
+	}
+}
+
+// UnmarshalBinary replaces the matrix with the matrix encoded by MarshalBinary
+func (m *Matrix) UnmarshalBinary(data []byte) error {
+	d := &cborDecoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return errors.New("cbor: unexpected data after matrix")
+	}
+	root, ok := v.(map[string]any)
+	if !ok {
+		return errors.New("matrix: encoded value is not a map")
+	}
+	if version, _ := root["version"].(uint64); version != FormatVersion {
+		return fmt.Errorf("matrix: unsupported format version %v, want %d", root["version"], FormatVersion)
+	}
+
+	decoded := New(textField(root, "uuid"), textField(root, "object"), textField(root, "identifier"))
+	decoded.exportable = boolField(root, "exportable", true)
+	decoded.globalLabels = labelsField(root, "global_labels")
+	if options, ok := root["export_options"].(map[string]any); ok {
+		decoded.exportOptions = decodeNode(options, 0)
+	}
+
+	instances, _ := root["instances"].([]any)
+	ordered := make([]*Instance, 0, len(instances))
+	for i, raw := range instances {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("matrix: instances[%d] is not a map", i)
+		}
+		instance, err := decoded.NewInstance(textField(fields, "key"))
+		if err != nil {
+			return err
+		}
+		instance.labels = labelsField(fields, "labels")
+		instance.exportable = boolField(fields, "exportable", true)
+		instance.partial = boolField(fields, "partial", false)
+		ordered = append(ordered, instance)
+	}
+
+	metrics, _ := root["metrics"].([]any)
+	for i, raw := range metrics {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("matrix: metrics[%d] is not a map", i)
+		}
+		metric, err := decoded.NewMetricType(textField(fields, "key"), textField(fields, "type"), textField(fields, "name"))
+		if err != nil {
+			return fmt.Errorf("matrix: metrics[%d]: %w", i, err)
+		}
+		metric.property = textField(fields, "property")
+		metric.comment = textField(fields, "comment")
+		metric.unit = textField(fields, "unit")
+		metric.exportable = boolField(fields, "exportable", true)
+		metric.array = boolField(fields, "array", false)
+		metric.histogram = boolField(fields, "histogram", false)
+		if l := labelsField(fields, "labels"); len(l) > 0 {
+			metric.labels = l
+		}
+		if raw, ok := fields["buckets"].([]any); ok {
+			buckets := make([]string, 0, len(raw))
+			for _, b := range raw {
+				s, _ := b.(string)
+				buckets = append(buckets, s)
+			}
+			metric.buckets = &buckets
+		}
+		values, _ := fields["values"].([]any)
+		if len(values) > len(ordered) {
+			return fmt.Errorf("matrix: metrics[%d] has %d values for %d instances", i, len(values), len(ordered))
+		}
+		for j, value := range values {
+			if u, ok := value.(uint64); ok && metric.exact != nil {
+				_ = metric.SetValueUint64(ordered[j], u)
+				continue
+			}
+			if f, ok := numberValue(value); ok {
+				_ = metric.SetValueFloat64(ordered[j], f)
+			}
+		}
+	}
+
+	*m = *decoded
+	return nil
+}
+
+func decodeNode(fields map[string]any, depth int) *node.Node {
+	n := node.NewS(textField(fields, "name"))
+	n.SetContentS(textField(fields, "content"))
+	if depth >= maxDepth {
+		return n
+	}
+	children, _ := fields["children"].([]any)
+	for _, raw := range children {
+		if child, ok := raw.(map[string]any); ok {
+			n.AddChild(decodeNode(child, depth+1))
+		}
+	}
+	return n
+}
+
+func textField(fields map[string]any, key string) string {
+	s, _ := fields[key].(string)
+	return s
+}
+
+func boolField(fields map[string]any, key string, missing bool) bool {
+	b, ok := fields[key].(bool)
+	if !ok {
+		return missing
+	}
+	return b
+}
+
+func labelsField(fields map[string]any, key string) map[string]string {
+	raw, _ := fields[key].(map[string]any)
+	l := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, _ := v.(string)
+		l[k] = s
+	}
+	return l
+}
+
+// numberValue returns a decoded number as float64, and false when value is not a number
+func numberValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
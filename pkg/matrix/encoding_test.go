@@ -0,0 +1,133 @@
+package matrix
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	m := New("RestPerf", "volume", "Volume")
+	m.SetGlobalLabel("cluster", "cluster-01")
+	m.SetExportable(true)
+	options := node.NewS("export_options")
+	options.NewChildS("instance_keys", "").NewChildS("", "volume")
+	m.SetExportOptions(options)
+
+	ops, _ := m.NewMetricFloat64("total_ops", "ops")
+	ops.SetUnit("per_sec")
+	hist, _ := m.NewMetricUint64("read_latency_hist")
+	hist.SetHistogram(true)
+	hist.SetBuckets(&[]string{"<2us", "<6us"})
+	hist.SetLabels(map[string]string{"bucket": "<2us"})
+	hidden, _ := m.NewMetricInt64("sequence")
+	hidden.SetExportable(false)
+
+	vol1, _ := m.NewInstance("vol1")
+	vol1.SetLabel("volume", "vol1")
+	vol2, _ := m.NewInstance("vol2")
+	vol2.SetLabel("volume", "vol2")
+	vol2.SetExportable(false)
+	vol2.SetPartial(true)
+	_ = ops.SetValueFloat64(vol1, 12.5)
+	_ = hist.SetValueUint64(vol2, 7)
+	_ = hidden.SetValueInt64(vol1, -3)
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Matrix
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.UUID != m.UUID || got.Object != m.Object || got.Identifier != m.Identifier {
+		t.Errorf("UnmarshalBinary() got=%s/%s/%s", got.UUID, got.Object, got.Identifier)
+	}
+	if diff := cmp.Diff(m.GetGlobalLabels(), got.GetGlobalLabels()); diff != "" {
+		t.Errorf("global labels mismatch (-want +got):\n%s", diff)
+	}
+	if keys := got.GetExportOptions().GetChildS("instance_keys").GetAllChildContentS(); len(keys) != 1 || keys[0] != "volume" {
+		t.Errorf("export options got=%v", keys)
+	}
+	if got.DisplayMetric("ops") == nil || got.GetMetric("total_ops").GetUnit() != "per_sec" {
+		t.Errorf("metric total_ops not decoded")
+	}
+	if h := got.GetMetric("read_latency_hist"); !h.IsHistogram() || len(*h.Buckets()) != 2 || h.GetLabel("bucket") != "<2us" {
+		t.Errorf("histogram not decoded")
+	}
+	if got.GetMetric("sequence").IsExportable() {
+		t.Errorf("sequence got exportable, want not exportable")
+	}
+	if i := got.GetInstance("vol2"); i.IsExportable() || !i.IsPartial() || i.GetLabel("volume") != "vol2" {
+		t.Errorf("instance vol2 not decoded")
+	}
+
+	values := []struct {
+		metric   string
+		instance string
+		want     float64
+		wantOk   bool
+	}{
+		{metric: "total_ops", instance: "vol1", want: 12.5, wantOk: true},
+		{metric: "total_ops", instance: "vol2"},
+		{metric: "read_latency_hist", instance: "vol2", want: 7, wantOk: true},
+		{metric: "sequence", instance: "vol1", want: -3, wantOk: true},
+	}
+	for _, v := range values {
+		value, ok := got.GetMetric(v.metric).GetValueFloat64(got.GetInstance(v.instance))
+		if ok != v.wantOk || value != v.want {
+			t.Errorf("%s/%s got=%v,%v want=%v,%v", v.metric, v.instance, value, ok, v.want, v.wantOk)
+		}
+	}
+
+	// the encoding is deterministic
+	again, _ := got.MarshalBinary()
+	if string(again) != string(data) {
+		t.Errorf("MarshalBinary() of decoded matrix differs")
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	m := New("Rest", "volume", "volume")
+	valid, _ := m.MarshalBinary()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "truncated", data: valid[:len(valid)-1]},
+		{name: "trailing data", data: append(valid, 0)},
+		{name: "not a map", data: []byte{0x80}},
+		{name: "unsupported version", data: []byte{0xa1, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x02}},
+		{name: "indefinite length", data: []byte{0xbf, 0xff}},
+		{name: "huge length", data: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Matrix
+			if err := got.UnmarshalBinary(tt.data); err == nil {
+				t.Errorf("UnmarshalBinary() got nil error")
+			}
+		})
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	tests := []struct {
+		bits uint16
+		want float64
+	}{
+		{bits: 0x3c00, want: 1},
+		{bits: 0xc000, want: -2},
+		{bits: 0x3555, want: 0.333251953125},
+		{bits: 0x0001, want: 5.960464477539063e-08},
+	}
+	for _, tt := range tests {
+		if got := float16(tt.bits); got != tt.want {
+			t.Errorf("float16(%#x) got=%v, want=%v", tt.bits, got, tt.want)
+		}
+	}
+}
@@ -15,4 +15,5 @@ const (
 	ErrDuplicateMetricKey   = matrixError("duplicate metric key")
 	ErrDuplicateInstanceKey = matrixError("duplicate instance key")
 	ErrUnequalVectors       = matrixError("unequal vectors")
+	ErrInvalidPolicy        = matrixError("invalid policy, must be one of drop, zero, keep, flag")
 )
@@ -11,10 +11,11 @@ import (
 // Instance struct and related methods
 
 type Instance struct {
-	index      int
-	labels     map[string]string
-	exportable bool
-	partial    bool
+	index        int
+	labels       map[string]string
+	exportable   bool
+	partial      bool
+	sharedLabels bool // labels were set with SetLabels and may be shared with another instance
 }
 
 func NewInstance(index int) *Instance {
@@ -46,6 +47,7 @@ func (i *Instance) SetLabel(key, value string) {
 
 func (i *Instance) SetLabels(labels map[string]string) {
 	i.labels = labels
+	i.sharedLabels = true
 }
 
 func (i *Instance) IsExportable() bool {
@@ -65,8 +67,14 @@ func (i *Instance) SetPartial(b bool) {
 }
 
 func (i *Instance) Clone(isExportable bool, labels ...string) *Instance {
-	clone := NewInstance(i.index)
-	clone.labels = i.Copy(labels...)
+	clone := getInstance(i.index)
+	if len(labels) == 0 {
+		maps.Copy(clone.labels, i.labels)
+	} else {
+		for _, k := range labels {
+			clone.labels[k] = i.labels[k]
+		}
+	}
 	clone.exportable = isExportable
 	return clone
 }
@@ -0,0 +1,85 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+package matrix
+
+import (
+	"math"
+	"strings"
+)
+
+// InvalidPolicy describes how cooked values that are negative, NaN, or Inf are handled
+// by the calculation methods (Delta, Divide, DivideWithThreshold, MultiplyByScalar)
+type InvalidPolicy string
+
+const (
+	// InvalidDrop does not export the value. This is the default
+	InvalidDrop InvalidPolicy = "drop"
+	// InvalidZero exports zero instead of the value
+	InvalidZero InvalidPolicy = "zero"
+	// InvalidKeep exports the value as-is
+	InvalidKeep InvalidPolicy = "keep"
+	// InvalidFlag exports the value as-is and sets the InvalidLabel on the instance
+	InvalidFlag InvalidPolicy = "flag"
+)
+
+// InvalidLabel is the instance label set by the InvalidFlag policy
+const InvalidLabel = "invalid_value"
+
+// ParseInvalidPolicy converts s into an InvalidPolicy. An empty string is parsed as InvalidDrop
+func ParseInvalidPolicy(s string) (InvalidPolicy, error) {
+	switch p := InvalidPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return InvalidDrop, nil
+	case InvalidDrop, InvalidZero, InvalidKeep, InvalidFlag:
+		return p, nil
+	}
+	return InvalidDrop, ErrInvalidPolicy
+}
+
+func (m *Matrix) SetInvalidPolicy(p InvalidPolicy) {
+	m.invalidPolicy = p
+}
+
+func (m *Matrix) GetInvalidPolicy() InvalidPolicy {
+	if m.invalidPolicy == "" {
+		return InvalidDrop
+	}
+	return m.invalidPolicy
+}
+
+// InvalidCount returns the number of negative or non-finite values handled by the matrix's InvalidPolicy
+// since the matrix was created
+func (m *Matrix) InvalidCount() int {
+	return m.invalidCount
+}
+
+// ResetInvalid removes the InvalidLabel from the instances, so that the InvalidFlag policy
+// only flags the values of the current poll. Collectors call it before cooking
+func (m *Matrix) ResetInvalid() {
+	for _, instance := range m.GetInstances() {
+		delete(instance.labels, InvalidLabel)
+	}
+}
+
+func isInvalid(v float64) bool {
+	return v < 0 || math.IsNaN(v) || math.IsInf(v, 0)
+}
+
+// handleInvalid applies the matrix's InvalidPolicy to the value of metric for instance.
+// Returns true when the value was dropped
+func (m *Matrix) handleInvalid(metric *Metric, instance *Instance) bool {
+	m.invalidCount++
+	switch m.GetInvalidPolicy() {
+	case InvalidZero:
+		metric.values[instance.index] = 0
+	case InvalidKeep:
+	case InvalidFlag:
+		instance.SetLabel(InvalidLabel, "true")
+	default:
+		metric.record[instance.index] = false
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package matrix
+
+import (
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"sync"
+)
+
+// Locked is a view of a Matrix that is safe for concurrent use.
+//
+// A Matrix is not safe for concurrent use: adding an instance or a metric grows the maps and the
+// value slices that every other read goes through. Collectors and plugins run one at a time, so
+// a plugin that does not start goroutines needs no locking. A plugin that parallelizes its work,
+// e.g. one request per SVM, either gives each goroutine its own Matrix and merges the results
+// after the goroutines finish, or shares a Locked view of the Matrix between them.
+//
+// Every access to the Matrix, for as long as goroutines use the view, must go through the view.
+// Instances and metrics are referenced by their keys, since an *Instance or *Metric returned
+// by the view may be changed by another goroutine once the lock is released.
+type Locked struct {
+	mu sync.RWMutex
+	m  *Matrix
+}
+
+// Locked returns a view of the matrix that is safe for concurrent use. See Locked for the contract
+func (m *Matrix) Locked() *Locked {
+	return &Locked{m: m}
+}
+
+// Read calls fn with the matrix while holding the read lock. fn must not change the matrix,
+// and must not keep references to its instances or metrics after it returns
+func (l *Locked) Read(fn func(*Matrix)) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	fn(l.m)
+}
+
+// Write calls fn with the matrix while holding the write lock, for changes that the methods
+// of the view do not cover, e.g. setting several labels and values of an instance at once
+func (l *Locked) Write(fn func(*Matrix) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fn(l.m)
+}
+
+// NewInstance adds an instance with the labels, or sets the labels of the instance when it exists
+func (l *Locked) NewInstance(key string, labels map[string]string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	instance := l.m.GetInstance(key)
+	if instance == nil {
+		var err error
+		if instance, err = l.m.NewInstance(key); err != nil {
+			return err
+		}
+	}
+	for k, v := range labels {
+		instance.SetLabel(k, v)
+	}
+	return nil
+}
+
+// NewMetricFloat64 adds a float64 metric, unless the metric exists
+func (l *Locked) NewMetricFloat64(key string, display ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.m.GetMetric(key) != nil {
+		return nil
+	}
+	_, err := l.m.NewMetricFloat64(key, display...)
+	return err
+}
+
+func (l *Locked) GetLabel(ikey, label string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if instance := l.m.GetInstance(ikey); instance != nil {
+		return instance.GetLabel(label)
+	}
+	return ""
+}
+
+func (l *Locked) SetLabel(ikey, label, value string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	instance := l.m.GetInstance(ikey)
+	if instance == nil {
+		return errs.New(ErrInvalidInstanceKey, ikey)
+	}
+	instance.SetLabel(label, value)
+	return nil
+}
+
+func (l *Locked) GetValueFloat64(mkey, ikey string) (float64, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.m.LazyGetValueFloat64(mkey, ikey)
+}
+
+func (l *Locked) SetValueFloat64(mkey, ikey string, v float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.m.LazySetValueFloat64(mkey, ikey, v)
+}
+
+// AddValueFloat64 adds v to the value of the metric, which is read and written under the same lock
+func (l *Locked) AddValueFloat64(mkey, ikey string, v float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	metric := l.m.GetMetric(mkey)
+	if metric == nil {
+		return errs.New(ErrInvalidMetricKey, mkey)
+	}
+	instance := l.m.GetInstance(ikey)
+	if instance == nil {
+		return errs.New(ErrInvalidInstanceKey, ikey)
+	}
+	return metric.AddValueFloat64(instance, v)
+}
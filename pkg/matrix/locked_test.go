@@ -0,0 +1,63 @@
+package matrix
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLocked(t *testing.T) {
+	m := New("TestLocked", "test", "test")
+	locked := m.Locked()
+	if err := locked.NewMetricFloat64("ops"); err != nil {
+		t.Fatal(err)
+	}
+	if err := locked.NewInstance("total", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// each goroutine adds its own instance, growing the value slices, while all of them add to the same total
+	var wg sync.WaitGroup
+	workers := 20
+	for i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "w" + strconv.Itoa(i)
+			if err := locked.NewInstance(key, map[string]string{"worker": key}); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := locked.SetValueFloat64("ops", key, float64(i)); err != nil {
+				t.Error(err)
+			}
+			for range 100 {
+				if err := locked.AddValueFloat64("ops", "total", 1); err != nil {
+					t.Error(err)
+				}
+			}
+			_, _ = locked.GetValueFloat64("ops", "total")
+			_ = locked.GetLabel(key, "worker")
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(m.GetInstances()); got != workers+1 {
+		t.Errorf("instances got=%d, want=%d", got, workers+1)
+	}
+	if got, _ := locked.GetValueFloat64("ops", "total"); got != float64(workers*100) {
+		t.Errorf("total got=%f, want=%d", got, workers*100)
+	}
+	if got, _ := locked.GetValueFloat64("ops", "w7"); got != 7 {
+		t.Errorf("w7 got=%f, want=7", got)
+	}
+	if got := locked.GetLabel("w7", "worker"); got != "w7" {
+		t.Errorf("w7 worker got=%s, want=w7", got)
+	}
+	if err := locked.SetLabel("missing", "worker", "x"); err == nil {
+		t.Errorf("SetLabel on a missing instance got=nil, want error")
+	}
+	if err := locked.AddValueFloat64("missing", "total", 1); err == nil {
+		t.Errorf("AddValueFloat64 on a missing metric got=nil, want error")
+	}
+}
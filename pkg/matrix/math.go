@@ -4,6 +4,8 @@
 
 package matrix
 
+import "math"
+
 func (m *Matrix) InstanceWiseAdditionUint64(toInstance, fromInstance *Instance, fromData *Matrix) {
 	for key, fromMetric := range fromData.GetMetrics() {
 		if toMetric := m.GetMetric(key); toMetric != nil {
@@ -13,3 +15,113 @@ func (m *Matrix) InstanceWiseAdditionUint64(toInstance, fromInstance *Instance,
 		}
 	}
 }
+
+// Average accumulates the weighted average of values. The zero value is an empty average.
+// NaN and infinite values and weights, and negative weights, are skipped, so one bad instance does not
+// poison the average. A value with a weight of 0 is counted, but does not move the average
+type Average struct {
+	sum    float64
+	weight float64
+	count  int
+}
+
+// Add adds value with weight to the average
+func (a *Average) Add(value float64, weight float64) {
+	if !isFinite(value) || !isFinite(weight) || weight < 0 {
+		return
+	}
+	a.sum += value * weight
+	a.weight += weight
+	a.count++
+}
+
+// Value returns the weighted average. The average is 0 when all weights are 0, e.g. the average latency of
+// instances without ops, and false when no value was added
+func (a *Average) Value() (float64, bool) {
+	if a.count == 0 {
+		return 0, false
+	}
+	if a.weight == 0 {
+		return 0, true
+	}
+	return a.sum / a.weight, true
+}
+
+// WeightedAverage returns the average of metricKey over instances weighted by weightKey, e.g. the latency
+// weighted by ops, or the percent used weighted by capacity. When weightKey is empty, each instance has a
+// weight of 1. When instances are empty, all instances of the matrix are averaged.
+// Instances without a value or weight are skipped, see Average
+func (m *Matrix) WeightedAverage(metricKey string, weightKey string, instances ...*Instance) (float64, bool) {
+	if len(instances) == 0 {
+		instances = make([]*Instance, 0, len(m.GetInstances()))
+		for _, instance := range m.GetInstances() {
+			instances = append(instances, instance)
+		}
+	}
+	var avg Average
+	m.addAverage(metricKey, weightKey, instances, func(*Instance) []*Average { return []*Average{&avg} })
+	return avg.Value()
+}
+
+// WeightedAverageBy is WeightedAverage for each group of instances. groupsOf returns the groups of an instance,
+// an instance may belong to several groups, or to none. Groups without values are not returned
+func (m *Matrix) WeightedAverageBy(metricKey string, weightKey string, groupsOf func(*Instance) []string) map[string]float64 {
+	averages := make(map[string]*Average)
+	instances := make([]*Instance, 0, len(m.GetInstances()))
+	for _, instance := range m.GetInstances() {
+		instances = append(instances, instance)
+	}
+	m.addAverage(metricKey, weightKey, instances, func(instance *Instance) []*Average {
+		groups := groupsOf(instance)
+		avgs := make([]*Average, 0, len(groups))
+		for _, group := range groups {
+			avg, ok := averages[group]
+			if !ok {
+				avg = &Average{}
+				averages[group] = avg
+			}
+			avgs = append(avgs, avg)
+		}
+		return avgs
+	})
+
+	result := make(map[string]float64, len(averages))
+	for group, avg := range averages {
+		if value, ok := avg.Value(); ok {
+			result[group] = value
+		}
+	}
+	return result
+}
+
+func (m *Matrix) addAverage(metricKey string, weightKey string, instances []*Instance, averagesOf func(*Instance) []*Average) {
+	metric := m.GetMetric(metricKey)
+	if metric == nil {
+		return
+	}
+	var weightMetric *Metric
+	if weightKey != "" {
+		if weightMetric = m.GetMetric(weightKey); weightMetric == nil {
+			return
+		}
+	}
+	for _, instance := range instances {
+		value, ok := metric.GetValueFloat64(instance)
+		if !ok {
+			continue
+		}
+		weight := 1.0
+		if weightMetric != nil {
+			if weight, ok = weightMetric.GetValueFloat64(instance); !ok {
+				continue
+			}
+		}
+		for _, avg := range averagesOf(instance) {
+			avg.Add(value, weight)
+		}
+	}
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
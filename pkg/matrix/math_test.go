@@ -0,0 +1,113 @@
+package matrix
+
+import (
+	"maps"
+	"math"
+	"testing"
+)
+
+func TestAverage(t *testing.T) {
+	type sample struct {
+		value  float64
+		weight float64
+	}
+	tests := []struct {
+		name    string
+		samples []sample
+		want    float64
+		wantOk  bool
+	}{
+		{name: "empty", want: 0, wantOk: false},
+		{name: "weighted", samples: []sample{{10, 1}, {20, 3}}, want: 17.5, wantOk: true},
+		{name: "zero weights", samples: []sample{{10, 0}, {20, 0}}, want: 0, wantOk: true},
+		{name: "NaN value", samples: []sample{{math.NaN(), 1}, {20, 1}}, want: 20, wantOk: true},
+		{name: "NaN weight", samples: []sample{{10, math.NaN()}, {20, 1}}, want: 20, wantOk: true},
+		{name: "infinite value", samples: []sample{{math.Inf(1), 1}, {20, 1}}, want: 20, wantOk: true},
+		{name: "negative weight", samples: []sample{{10, -5}, {20, 1}}, want: 20, wantOk: true},
+		{name: "only skipped", samples: []sample{{math.NaN(), 1}}, want: 0, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var avg Average
+			for _, s := range tt.samples {
+				avg.Add(s.value, s.weight)
+			}
+			got, ok := avg.Value()
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Value() got=%v %v, want=%v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func setUpAverageMatrix() *Matrix {
+	m := New("TestWeightedAverage", "volume", "volume")
+	latency, _ := m.NewMetricFloat64("read_latency")
+	ops, _ := m.NewMetricFloat64("read_ops")
+	rows := []struct {
+		name    string
+		svm     string
+		latency float64
+		ops     float64
+	}{
+		{"A", "svm1", 100, 10},
+		{"B", "svm1", 200, 30},
+		{"C", "svm2", 300, 0},
+		{"D", "svm2", math.NaN(), 10},
+	}
+	for _, r := range rows {
+		instance, _ := m.NewInstance(r.name)
+		instance.SetLabel("svm", r.svm)
+		_ = latency.SetValueFloat64(instance, r.latency)
+		_ = ops.SetValueFloat64(instance, r.ops)
+	}
+	// E has no latency
+	e, _ := m.NewInstance("E")
+	e.SetLabel("svm", "svm3")
+	_ = ops.SetValueFloat64(e, 10)
+	return m
+}
+
+func TestMatrix_WeightedAverage(t *testing.T) {
+	m := setUpAverageMatrix()
+	tests := []struct {
+		name      string
+		metric    string
+		weight    string
+		instances []string
+		want      float64
+		wantOk    bool
+	}{
+		{name: "all weighted", metric: "read_latency", weight: "read_ops", want: 175, wantOk: true},
+		{name: "all unweighted", metric: "read_latency", want: 200, wantOk: true},
+		{name: "instances", metric: "read_latency", weight: "read_ops", instances: []string{"A", "B"}, want: 175, wantOk: true},
+		{name: "no ops", metric: "read_latency", weight: "read_ops", instances: []string{"C"}, want: 0, wantOk: true},
+		{name: "no values", metric: "read_latency", weight: "read_ops", instances: []string{"E"}, wantOk: false},
+		{name: "missing metric", metric: "write_latency", weight: "read_ops", wantOk: false},
+		{name: "missing weight", metric: "read_latency", weight: "write_ops", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances := make([]*Instance, 0, len(tt.instances))
+			for _, name := range tt.instances {
+				instances = append(instances, m.GetInstance(name))
+			}
+			got, ok := m.WeightedAverage(tt.metric, tt.weight, instances...)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("WeightedAverage() got=%v %v, want=%v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestMatrix_WeightedAverageBy(t *testing.T) {
+	m := setUpAverageMatrix()
+	bySvm := func(instance *Instance) []string {
+		return []string{instance.GetLabel("svm"), "cluster"}
+	}
+	got := m.WeightedAverageBy("read_latency", "read_ops", bySvm)
+	want := map[string]float64{"svm1": 175, "svm2": 0, "cluster": 175}
+	if !maps.Equal(got, want) {
+		t.Errorf("WeightedAverageBy() got=%v, want=%v", got, want)
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/netapp/harvest/v2/pkg/logging"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"golang.org/x/exp/maps"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -29,6 +31,9 @@ type Matrix struct {
 	displayMetrics map[string]string  // display name of metric to => metric name (in templates, this is right side)
 	exportOptions  *node.Node
 	exportable     bool
+	invalidPolicy  InvalidPolicy // how negative, NaN, and Inf cooked values are handled
+	invalidCount   int           // number of cooked values handled by invalidPolicy
+	releasable     bool          // released by the collector after export, see Release
 }
 
 type With struct {
@@ -81,6 +86,7 @@ func (m *Matrix) Clone(with With) *Matrix {
 	clone.globalLabels = m.globalLabels
 	clone.exportOptions = m.exportOptions
 	clone.exportable = m.exportable
+	clone.invalidPolicy = m.invalidPolicy
 	clone.displayMetrics = make(map[string]string)
 
 	if with.Instances {
@@ -324,6 +330,11 @@ func (m *Matrix) GetGlobalLabels() map[string]string {
 	return m.globalLabels
 }
 
+// UnshareGlobalLabels gives the matrix its own copy of its global labels. Clone shares them with the original
+func (m *Matrix) UnshareGlobalLabels() {
+	m.globalLabels = maps.Clone(m.globalLabels)
+}
+
 func (m *Matrix) GetExportOptions() *node.Node {
 	if m.exportOptions != nil {
 		return m.exportOptions
@@ -341,6 +352,65 @@ func DefaultExportOptions() *node.Node {
 	return n
 }
 
+// MetricKeys returns the metric_keys of export options by metric name.
+// Metric keys are labels that are exported with that metric only, in addition to the instance_keys
+func MetricKeys(options *node.Node) map[string][]string {
+	keys := make(map[string][]string)
+	if x := options.GetChildS("metric_keys"); x != nil {
+		for _, metric := range x.GetChildren() {
+			keys[metric.GetNameS()] = metric.GetAllChildContentS()
+		}
+	}
+	return keys
+}
+
+// RetentionLabel is the label of the retention class of exported metrics
+const RetentionLabel = "retention"
+
+// RetentionClasses are the retention classes of export options
+var RetentionClasses = []string{"hot", "warm", "archive"}
+
+// Retention returns the retention class of the metrics of export options, and the retention class of the metrics
+// of metric_retention by metric name, which overrides it. The class is empty when export options have no retention
+func Retention(options *node.Node) (string, map[string]string, error) {
+	class := options.GetChildContentS("retention")
+	if class != "" && !slices.Contains(RetentionClasses, class) {
+		return "", nil, fmt.Errorf("invalid retention class %s, expected one of %s", class, strings.Join(RetentionClasses, ", "))
+	}
+	metrics := make(map[string]string)
+	if x := options.GetChildS("metric_retention"); x != nil {
+		for _, c := range x.GetChildren() {
+			if !slices.Contains(RetentionClasses, c.GetNameS()) {
+				return "", nil, fmt.Errorf("invalid retention class %s, expected one of %s", c.GetNameS(), strings.Join(RetentionClasses, ", "))
+			}
+			for _, metric := range c.GetAllChildContentS() {
+				metrics[metric] = c.GetNameS()
+			}
+		}
+	}
+	return class, metrics, nil
+}
+
+// exportPrefixRegex matches the prefixes that are valid at the start of a metric name
+var exportPrefixRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ExportPrefix returns the prefix of export options, ending with an underscore. Exporters prepend it to the object
+// of the exported metrics, after the global prefix of the exporter, so the metrics of custom templates can be told
+// apart from the shipped ones. The prefix is empty when export options have no prefix
+func ExportPrefix(options *node.Node) (string, error) {
+	prefix := options.GetChildContentS("prefix")
+	if prefix == "" {
+		return "", nil
+	}
+	if !exportPrefixRegex.MatchString(prefix) {
+		return "", fmt.Errorf("invalid prefix %s, expected letters, digits, and underscores", prefix)
+	}
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix, nil
+}
+
 func CreateMetric(key string, data *Matrix) error {
 	var err error
 	at := data.GetMetric(key)
@@ -367,22 +437,31 @@ func (m *Matrix) Delta(metricKey string, prevMat *Matrix, logger *logging.Logger
 		if prevInstance != nil {
 			prevIndex := prevInstance.index
 			if curMetric.record[currIndex] && prevRecord[prevIndex] {
+				// uint64 counters larger than 2^53 are subtracted exactly
+				curExact, curOk := curMetric.exactValue(currIndex)
+				prevExact, prevOk := prevMetric.exactValue(prevIndex)
 				curMetric.values[currIndex] -= prevRaw[prevIndex]
+				if curOk && prevOk && curExact >= prevExact {
+					_ = curMetric.SetValueUint64(currInstance, curExact-prevExact)
+				} else {
+					curMetric.setExact(currIndex, curMetric.values[currIndex])
+				}
 				curCooked := curMetric.values[currIndex]
 				// Sometimes ONTAP sends spurious zeroes or values less than the previous poll.
 				// Detect these cases and don't publish them, otherwise the subsequent poll will have large spikes.
 				// Ensure that the current cooked metric (curCooked) is not zero when either the current raw metric (curRaw) or the previous raw metric (prevRaw[prevIndex]) is zero.
 				// A non-zero curCooked under these conditions indicates an issue with the current or previous poll.
 				isInvalidZero := (curRaw == 0 || prevRaw[prevIndex] == 0) && curCooked != 0
-				isNegative := curCooked < 0
 
 				// Check for partial Aggregation
 				ppaOk := prevInstance.IsPartial()
 				cpaOk := currInstance.IsPartial()
 
-				if isInvalidZero || isNegative || ppaOk || cpaOk {
+				if isInvalidZero || ppaOk || cpaOk {
 					curMetric.record[currIndex] = false
 					skips++
+				} else if isInvalid(curCooked) && m.handleInvalid(curMetric, currInstance) {
+					skips++
 				}
 
 				if ppaOk || cpaOk {
@@ -422,13 +501,13 @@ func (m *Matrix) Divide(metricKey string, baseKey string) (int, error) {
 		i := instance.index
 		if metric.record[i] && sRecord[i] {
 			if sValues[i] != 0 {
-				// Don't pass along the value if the numerator or denominator is < 0
+				// The numerator or denominator being < 0 is handled by the matrix's InvalidPolicy
 				// A denominator of zero is fine
-				if metric.values[i] < 0 || sValues[i] < 0 {
-					metric.record[i] = false
+				isNegative := metric.values[i] < 0 || sValues[i] < 0
+				metric.values[i] /= sValues[i]
+				if (isNegative || isInvalid(metric.values[i])) && m.handleInvalid(metric, instance) {
 					skips++
 				}
-				metric.values[i] /= sValues[i]
 			} else {
 				metric.values[i] = 0
 			}
@@ -463,12 +542,17 @@ func (m *Matrix) DivideWithThreshold(metricKey string, baseKey string, threshold
 	for key, instance := range m.GetInstances() {
 		i := instance.index
 		v := metric.values[i]
-		// Don't pass along the value if the numerator or denominator is < 0
+		// The numerator or denominator being < 0 is handled by the matrix's InvalidPolicy
 		// It is important to check sValues[i] < 0 and allow a zero so pass=true and m.values[i] remains unchanged
 		switch {
 		case metric.values[i] < 0 || sValues[i] < 0:
-			metric.record[i] = false
-			skips++
+			// Policies that export the value apply to the cooked value, not the raw numerator
+			if m.GetInvalidPolicy() != InvalidDrop && sValues[i] != 0 {
+				metric.values[i] /= sValues[i]
+			}
+			if m.handleInvalid(metric, instance) {
+				skips++
+			}
 		case metric.record[i] && sRecord[i]:
 			minimumBase := tValues[i] * x
 			if metric.GetName() == "optimal_point_latency" {
@@ -477,6 +561,10 @@ func (m *Matrix) DivideWithThreshold(metricKey string, baseKey string, threshold
 			}
 			if sValues[i] > minimumBase {
 				metric.values[i] /= sValues[i]
+				if isInvalid(metric.values[i]) && m.handleInvalid(metric, instance) {
+					skips++
+					continue
+				}
 				// if cooked latency is greater than 5 secs log delta values
 				if metric.values[i] > 5_000_000 {
 					if len(metric.values) == len(curRawMetric.values) && len(curRawMetric.values) == len(prevRawMetric.values) &&
@@ -510,14 +598,13 @@ func (m *Matrix) MultiplyByScalar(metricKey string, s uint) (int, error) {
 	var skips int
 	x := float64(s)
 	metric := m.GetMetric(metricKey)
-	for i := range len(metric.values) {
+	for _, instance := range m.GetInstances() {
+		i := instance.index
 		if metric.record[i] {
-			// if current is <= 0
-			if metric.values[i] < 0 {
-				metric.record[i] = false
+			metric.values[i] *= x
+			if isInvalid(metric.values[i]) && m.handleInvalid(metric, instance) {
 				skips++
 			}
-			metric.values[i] *= x
 		} else {
 			metric.record[i] = false
 			skips++
@@ -1,6 +1,8 @@
 package matrix
 
 import (
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"maps"
 	"testing"
 )
 
@@ -47,3 +49,69 @@ func TestMatrix_RemoveInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(n *node.Node)
+		class   string
+		metrics map[string]string
+		wantErr bool
+	}{
+		{name: "none", options: func(*node.Node) {}, metrics: map[string]string{}},
+		{name: "class", options: func(n *node.Node) { n.NewChildS("retention", "hot") }, class: "hot", metrics: map[string]string{}},
+		{name: "metrics", options: func(n *node.Node) {
+			n.NewChildS("metric_retention", "").NewChildS("archive", "").NewChildS("", "size")
+		}, metrics: map[string]string{"size": "archive"}},
+		{name: "invalid class", options: func(n *node.Node) { n.NewChildS("retention", "cold") }, wantErr: true},
+		{name: "invalid metric class", options: func(n *node.Node) {
+			n.NewChildS("metric_retention", "").NewChildS("cold", "").NewChildS("", "size")
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := node.NewS("export_options")
+			tt.options(options)
+			class, metrics, err := Retention(options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Retention() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if class != tt.class || !maps.Equal(metrics, tt.metrics) {
+				t.Errorf("Retention() got=%s,%v want=%s,%v", class, metrics, tt.class, tt.metrics)
+			}
+		})
+	}
+}
+
+func TestExportPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		want    string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "prefix", prefix: "custom", want: "custom_"},
+		{name: "underscore", prefix: "custom_", want: "custom_"},
+		{name: "invalid", prefix: "my-team", wantErr: true},
+		{name: "digit", prefix: "1team", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := node.NewS("export_options")
+			if tt.prefix != "" {
+				options.NewChildS("prefix", tt.prefix)
+			}
+			got, err := ExportPrefix(options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportPrefix() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExportPrefix() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,8 @@ package matrix
 import (
 	"fmt"
 	"maps"
+	"math"
+	"slices"
 	"strconv"
 )
 
@@ -22,6 +24,7 @@ type Metric struct {
 	dataType   string
 	property   string
 	comment    string
+	unit       string
 	array      bool
 	histogram  bool
 	exportable bool
@@ -29,6 +32,7 @@ type Metric struct {
 	buckets    *[]string
 	record     []bool
 	values     []float64
+	exact      []uint64 // exact values of uint64 metrics, valid while they round to values
 }
 
 func (m *Metric) Clone(deep bool) *Metric {
@@ -37,6 +41,7 @@ func (m *Metric) Clone(deep bool) *Metric {
 		dataType:   m.dataType,
 		property:   m.property,
 		comment:    m.comment,
+		unit:       m.unit,
 		exportable: m.exportable,
 		array:      m.array,
 		histogram:  m.histogram,
@@ -45,13 +50,16 @@ func (m *Metric) Clone(deep bool) *Metric {
 	clone.labels = maps.Clone(m.labels)
 	if deep {
 		if len(m.record) != 0 {
-			clone.record = make([]bool, len(m.record))
+			clone.record = getBools(len(m.record))
 			copy(clone.record, m.record)
 		}
 		if len(m.values) != 0 {
-			clone.values = make([]float64, len(m.values))
+			clone.values = getFloats(len(m.values))
 			copy(clone.values, m.values)
 		}
+		if m.exact != nil {
+			clone.exact = slices.Clone(m.exact)
+		}
 	}
 	return &clone
 }
@@ -60,6 +68,10 @@ func (m *Metric) GetName() string {
 	return m.name
 }
 
+func (m *Metric) SetName(name string) {
+	m.name = name
+}
+
 func (m *Metric) IsExportable() bool {
 	return m.exportable
 }
@@ -88,6 +100,15 @@ func (m *Metric) SetComment(c string) {
 	m.comment = c
 }
 
+// GetUnit returns the unit of the metric as reported by ONTAP, e.g. microsec, or overridden by the template
+func (m *Metric) GetUnit() string {
+	return m.unit
+}
+
+func (m *Metric) SetUnit(u string) {
+	m.unit = u
+}
+
 func (m *Metric) IsArray() bool {
 	return m.array
 }
@@ -153,13 +174,39 @@ func (m *Metric) GetValues() []float64 {
 // Storage resizing methods
 
 func (m *Metric) Reset(size int) {
-	m.record = make([]bool, size)
-	m.values = make([]float64, size)
+	m.resetExact(size)
+	if cap(m.record) >= size && cap(m.values) >= size {
+		m.record = m.record[:size]
+		m.values = m.values[:size]
+		clear(m.record)
+		clear(m.values)
+		return
+	}
+	putBools(m.record)
+	putFloats(m.values)
+	m.record = getBools(size)
+	m.values = getFloats(size)
+}
+
+// resetExact sizes the exact values of uint64 metrics, other metrics only have float64 values
+func (m *Metric) resetExact(size int) {
+	if m.dataType != "uint64" {
+		return
+	}
+	if m.exact != nil && cap(m.exact) >= size {
+		m.exact = m.exact[:size]
+		clear(m.exact)
+		return
+	}
+	m.exact = make([]uint64, size)
 }
 
 func (m *Metric) Append() {
 	m.record = append(m.record, false)
 	m.values = append(m.values, 0)
+	if m.exact != nil {
+		m.exact = append(m.exact, 0)
+	}
 }
 
 // Remove element at index, shift everything to the left
@@ -170,6 +217,31 @@ func (m *Metric) Remove(index int) {
 	}
 	m.record = m.record[:len(m.record)-1]
 	m.values = m.values[:len(m.values)-1]
+	if m.exact != nil {
+		m.exact = slices.Delete(m.exact, index, index+1)
+	}
+}
+
+// exactValue returns the exact value of a uint64 metric at index. Arithmetic on the float64 values, e.g. Divide,
+// does not update the exact value, so it is only valid while it rounds to the float64 value
+func (m *Metric) exactValue(index int) (uint64, bool) {
+	if m.exact == nil || float64(m.exact[index]) != m.values[index] {
+		return 0, false
+	}
+	return m.exact[index], true
+}
+
+// setExact sets the exact value of a uint64 metric at index from a float64. Values that are not
+// a uint64, e.g. negative or fractional values, do not round to the exact value, see exactValue
+func (m *Metric) setExact(index int, v float64) {
+	if m.exact == nil {
+		return
+	}
+	if v >= 0 && v < math.MaxUint64 {
+		m.exact[index] = uint64(v)
+	} else {
+		m.exact[index] = 0
+	}
 }
 
 // Write methods
@@ -177,33 +249,47 @@ func (m *Metric) Remove(index int) {
 func (m *Metric) SetValueInt64(i *Instance, v int64) error {
 	m.record[i.index] = true
 	m.values[i.index] = float64(v)
+	m.setExact(i.index, float64(v))
 	return nil
 }
 
 func (m *Metric) SetValueUint8(i *Instance, v uint8) error {
 	m.record[i.index] = true
 	m.values[i.index] = float64(v)
+	m.setExact(i.index, float64(v))
 	return nil
 }
 
 func (m *Metric) SetValueUint64(i *Instance, v uint64) error {
 	m.record[i.index] = true
 	m.values[i.index] = float64(v)
+	if m.exact != nil {
+		m.exact[i.index] = v
+	}
 	return nil
 }
 
 func (m *Metric) SetValueFloat64(i *Instance, v float64) error {
 	m.record[i.index] = true
 	m.values[i.index] = v
+	m.setExact(i.index, v)
 	return nil
 }
 
+// SetValueString parses v as a float64. The values of uint64 metrics are parsed as a uint64 first,
+// so counters larger than 2^53 keep their precision
 func (m *Metric) SetValueString(i *Instance, v string) error {
 	var x float64
 	var err error
+	if m.exact != nil {
+		if u, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return m.SetValueUint64(i, u)
+		}
+	}
 	if x, err = strconv.ParseFloat(v, 64); err == nil {
 		m.record[i.index] = true
 		m.values[i.index] = x
+		m.setExact(i.index, x)
 		return nil
 	}
 	return err
@@ -239,6 +325,14 @@ func (m *Metric) AddValueString(i *Instance, v string) error {
 		err  error
 		has  bool
 	)
+	if m.exact != nil {
+		if u, err := strconv.ParseUint(v, 10, 64); err == nil {
+			if prev, ok := m.GetValueUint64(i); ok {
+				return m.SetValueUint64(i, prev+u)
+			}
+			return m.SetValueUint64(i, u)
+		}
+	}
 	if x, err = strconv.ParseFloat(v, 64); err != nil {
 		return err
 	}
@@ -268,6 +362,9 @@ func (m *Metric) GetValueUint8(i *Instance) (uint8, bool) {
 }
 
 func (m *Metric) GetValueUint64(i *Instance) (uint64, bool) {
+	if exact, ok := m.exactValue(i.index); ok {
+		return exact, m.record[i.index]
+	}
 	v := m.values[i.index]
 	val := uint64(v)
 	return val, m.record[i.index]
@@ -278,7 +375,12 @@ func (m *Metric) GetValueFloat64(i *Instance) (float64, bool) {
 	return v, m.record[i.index]
 }
 
+// GetValueString returns the value formatted for exporters. The values of uint64 metrics are formatted
+// without loss of precision
 func (m *Metric) GetValueString(i *Instance) (string, bool) {
+	if exact, ok := m.exactValue(i.index); ok {
+		return strconv.FormatUint(exact, 10), m.record[i.index]
+	}
 	v := m.values[i.index]
 	return strconv.FormatFloat(v, 'f', -1, 64), m.record[i.index]
 }
@@ -363,3 +363,192 @@ func TestMetricReset(t *testing.T) {
 		t.Errorf("expected metric to be skipped but passed")
 	}
 }
+
+func TestMetricFloat64_Delta_InvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  InvalidPolicy
+		cooked  float64
+		record  bool
+		skips   int
+		flagged bool
+	}{
+		{name: "drop", policy: InvalidDrop, cooked: -10, record: false, skips: 1},
+		{name: "zero", policy: InvalidZero, cooked: 0, record: true, skips: 0},
+		{name: "keep", policy: InvalidKeep, cooked: -10, record: true, skips: 0},
+		{name: "flag", policy: InvalidFlag, cooked: -10, record: true, skips: 0, flagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, current := setupMatrix(20, 10, oneInstance)
+			current.SetInvalidPolicy(tt.policy)
+			skips, err := current.Delta("speed", previous, logging.Get())
+			if err != nil {
+				t.Fatalf("Delta method returned an error: %v", err)
+			}
+			if skips != tt.skips {
+				t.Errorf("skips expected=%d, got=%d", tt.skips, skips)
+			}
+			if current.InvalidCount() != 1 {
+				t.Errorf("invalidCount expected=1, got=%d", current.InvalidCount())
+			}
+			instance := current.GetInstance("A")
+			v, ok := current.GetMetric("speed").GetValueFloat64(instance)
+			if v != tt.cooked || ok != tt.record {
+				t.Errorf("expected=%v/%t, got=%v/%t", tt.cooked, tt.record, v, ok)
+			}
+			if flagged := instance.GetLabel(InvalidLabel) == "true"; flagged != tt.flagged {
+				t.Errorf("flagged expected=%t, got=%t", tt.flagged, flagged)
+			}
+		})
+	}
+}
+
+func TestMetricFloat64_DivideWithThreshold_InvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  InvalidPolicy
+		cooked  float64
+		record  bool
+		skips   int
+		flagged bool
+	}{
+		{name: "drop", policy: InvalidDrop, cooked: 1000, record: false, skips: 1},
+		{name: "zero", policy: InvalidZero, cooked: 0, record: true, skips: 0},
+		{name: "keep", policy: InvalidKeep, cooked: -5, record: true, skips: 0},
+		{name: "flag", policy: InvalidFlag, cooked: -5, record: true, skips: 0, flagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the latency delta is 1000 and the ops delta is -200
+			prevMat, curMat := setupMatrixAdv("average_latency", []rawData{{1000, 500, 60}}, []rawData{{2000, 300, 120}}, oneInstance)
+			curMat.SetInvalidPolicy(tt.policy)
+			cachedData := curMat.Clone(With{Data: true, Metrics: true, Instances: true, ExportInstances: true})
+			for k := range curMat.GetMetrics() {
+				if _, err := curMat.Delta(k, prevMat, logging.Get()); err != nil {
+					t.Fatalf("Delta method returned an error: %v", err)
+				}
+			}
+
+			skips, err := curMat.DivideWithThreshold("average_latency", "total_ops", 10, cachedData, prevMat, "timestamp", logging.Get())
+			if err != nil {
+				t.Fatalf("DivideWithThreshold method returned an error: %v", err)
+			}
+			if skips != tt.skips {
+				t.Errorf("skips expected=%d, got=%d", tt.skips, skips)
+			}
+			instance := curMat.GetInstance("A")
+			v, ok := curMat.GetMetric("average_latency").GetValueFloat64(instance)
+			if v != tt.cooked || ok != tt.record {
+				t.Errorf("expected=%v/%t, got=%v/%t", tt.cooked, tt.record, v, ok)
+			}
+			if flagged := instance.GetLabel(InvalidLabel) == "true"; flagged != tt.flagged {
+				t.Errorf("flagged expected=%t, got=%t", tt.flagged, flagged)
+			}
+
+			// the flag does not outlive the poll
+			curMat.ResetInvalid()
+			if _, has := instance.GetLabels()[InvalidLabel]; has {
+				t.Errorf("expected %s to be removed", InvalidLabel)
+			}
+		})
+	}
+}
+
+func TestParseInvalidPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    InvalidPolicy
+		wantErr bool
+	}{
+		{in: "", want: InvalidDrop},
+		{in: "Zero", want: InvalidZero},
+		{in: "flag", want: InvalidFlag},
+		{in: "clamp", want: InvalidDrop, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseInvalidPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseInvalidPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseInvalidPolicy() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricUint64_Precision(t *testing.T) {
+	m := New("Test", "test", "test")
+	a, _ := m.NewInstance("A")
+	metric, _ := m.NewMetricUint64("bytes")
+
+	tests := []struct {
+		name     string
+		set      string
+		want     string
+		exact    uint64
+		hasExact bool
+	}{
+		{name: "above 2^53", set: "9007199254740993", want: "9007199254740993", exact: 9007199254740993, hasExact: true},
+		{name: "max", set: "18446744073709551615", want: "18446744073709551615", exact: 18446744073709551615, hasExact: true},
+		{name: "fraction", set: "12.5", want: "12.5"},
+		{name: "negative", set: "-3", want: "-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := metric.SetValueString(a, tt.set); err != nil {
+				t.Fatalf("SetValueString() error = %v", err)
+			}
+			if got, _ := metric.GetValueString(a); got != tt.want {
+				t.Errorf("GetValueString() got = %s, want %s", got, tt.want)
+			}
+			if tt.hasExact {
+				if got, _ := metric.GetValueUint64(a); got != tt.exact {
+					t.Errorf("GetValueUint64() got = %d, want %d", got, tt.exact)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricUint64_Delta(t *testing.T) {
+	setup := func(v string) *Matrix {
+		m := New("Test", "test", "test")
+		a, _ := m.NewInstance("A")
+		metric, _ := m.NewMetricUint64("bytes")
+		_ = metric.SetValueString(a, v)
+		return m
+	}
+	previous := setup("9007199254740993")
+	current := setup("9007199254741000")
+	if _, err := current.Delta("bytes", previous, logging.Get()); err != nil {
+		t.Fatalf("Delta() error = %v", err)
+	}
+	got, _ := current.GetMetric("bytes").GetValueString(current.GetInstance("A"))
+	if got != "7" {
+		t.Errorf("Delta() got = %s, want 7", got)
+	}
+}
+
+func TestMetricUint64_Remove(t *testing.T) {
+	m := New("Test", "test", "test")
+	metric, _ := m.NewMetricUint64("bytes")
+	for _, key := range []string{"A", "B", "C"} {
+		_, _ = m.NewInstance(key)
+	}
+	_ = metric.SetValueString(m.GetInstance("C"), "9007199254740993")
+	m.RemoveInstance("A")
+	_, _ = m.NewInstance("D")
+
+	got, _ := metric.GetValueString(m.GetInstance("C"))
+	if got != "9007199254740993" {
+		t.Errorf("GetValueString() got = %s, want 9007199254740993", got)
+	}
+	if _, ok := metric.GetValueString(m.GetInstance("D")); ok {
+		t.Errorf("expected new instance to have no value")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+package matrix
+
+import (
+	"sync"
+)
+
+// Pools of matrix storage. Perf collectors clone and reset their matrices every poll.
+// Recycling the storage of released matrices keeps steady-state polling from allocating
+// new instances, label maps, and metric slices every poll
+
+var (
+	instancePool sync.Pool // *Instance
+	floatPool    sync.Pool // *[]float64
+	boolPool     sync.Pool // *[]bool
+)
+
+func getInstance(index int) *Instance {
+	if i, ok := instancePool.Get().(*Instance); ok {
+		i.index = index
+		i.exportable = true
+		return i
+	}
+	return NewInstance(index)
+}
+
+func putInstance(i *Instance) {
+	if i.sharedLabels {
+		// the labels belong to another instance
+		i.labels = make(map[string]string)
+		i.sharedLabels = false
+	} else {
+		clear(i.labels)
+	}
+	i.partial = false
+	instancePool.Put(i)
+}
+
+// getFloats returns a zeroed slice of length size
+func getFloats(size int) []float64 {
+	if p, ok := floatPool.Get().(*[]float64); ok && cap(*p) >= size {
+		s := (*p)[:size]
+		clear(s)
+		return s
+	}
+	return make([]float64, size)
+}
+
+func putFloats(s []float64) {
+	if cap(s) > 0 {
+		floatPool.Put(&s)
+	}
+}
+
+// getBools returns a zeroed slice of length size
+func getBools(size int) []bool {
+	if p, ok := boolPool.Get().(*[]bool); ok && cap(*p) >= size {
+		s := (*p)[:size]
+		clear(s)
+		return s
+	}
+	return make([]bool, size)
+}
+
+func putBools(s []bool) {
+	if cap(s) > 0 {
+		boolPool.Put(&s)
+	}
+}
+
+// Release returns the instances and metric storage of the matrix to a pool, so they can be reused by the next
+// Clone or Reset of any matrix. The matrix, its instances, and its metric values must not be used after it is released.
+// Only release a matrix that is not referenced anymore, e.g. the previous poll's cache of a perf collector
+func (m *Matrix) Release() {
+	for _, metric := range m.metrics {
+		putFloats(metric.values)
+		putBools(metric.record)
+		metric.values = nil
+		metric.record = nil
+	}
+	for _, instance := range m.instances {
+		putInstance(instance)
+	}
+	m.instances = nil
+	m.metrics = nil
+}
+
+// SetReleasable marks the matrix to be released by the collector after it is exported.
+// The flag is not copied by Clone
+func (m *Matrix) SetReleasable(b bool) {
+	m.releasable = b
+}
+
+func (m *Matrix) IsReleasable() bool {
+	return m.releasable
+}
@@ -0,0 +1,110 @@
+package matrix
+
+import (
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestMatrix_Release(t *testing.T) {
+	m := setUpMatrix()
+	m.GetInstance("A").SetLabel("svm", "svm1")
+	m.GetInstance("B").SetPartial(true)
+	m.Release()
+
+	if len(m.GetInstances()) != 0 || len(m.GetMetrics()) != 0 {
+		t.Fatalf("expected released matrix to be empty")
+	}
+
+	// clones and resets after a release must not see the released labels or values
+	next := setUpMatrix()
+	clone := next.Clone(With{Data: false, Metrics: true, Instances: true, ExportInstances: true})
+	clone.Reset()
+	speed := clone.GetMetric("max_speed")
+	for key, instance := range clone.GetInstances() {
+		if len(instance.GetLabels()) != 0 {
+			t.Errorf("instance %s got labels=%v, want none", key, instance.GetLabels())
+		}
+		if instance.IsPartial() {
+			t.Errorf("instance %s is partial", key)
+		}
+		if _, ok := speed.GetValueFloat64(instance); ok {
+			t.Errorf("instance %s has a value after reset", key)
+		}
+	}
+}
+
+func TestMatrix_ReleaseSharedLabels(t *testing.T) {
+	labels := map[string]string{"svm": "svm1"}
+	m := New("uuid", "test", "test")
+	instance, _ := m.NewInstance("A")
+	instance.SetLabels(labels)
+	m.Release()
+
+	if labels["svm"] != "svm1" {
+		t.Errorf("Release() cleared labels shared with another instance")
+	}
+}
+
+// newPollMatrix returns a matrix shaped like a perf collector's cache
+func newPollMatrix(numInstances int, numMetrics int) *Matrix {
+	m := New("uuid", "volume", "volume")
+	for i := range numMetrics {
+		_, _ = m.NewMetricFloat64("metric" + strconv.Itoa(i))
+	}
+	for i := range numInstances {
+		instance, _ := m.NewInstance("instance" + strconv.Itoa(i))
+		instance.SetLabel("volume", "vol"+strconv.Itoa(i))
+		instance.SetLabel("svm", "svm1")
+		instance.SetLabel("node", "node1")
+	}
+	return m
+}
+
+// poll simulates a poll of a perf collector: clone the cache, set values, cache the new values, and cook them
+func poll(prevMat *Matrix, release bool) *Matrix {
+	curMat := prevMat.Clone(With{Data: false, Metrics: true, Instances: true, ExportInstances: true})
+	curMat.Reset()
+	for _, metric := range curMat.GetMetrics() {
+		for _, instance := range curMat.GetInstances() {
+			_ = metric.SetValueFloat64(instance, float64(instance.index))
+		}
+	}
+	cachedData := curMat.Clone(With{Data: true, Metrics: true, Instances: true, ExportInstances: true, PartialInstances: true})
+	for key := range curMat.GetMetrics() {
+		_, _ = curMat.Delta(key, prevMat, logging.Get())
+	}
+	if release {
+		prevMat.Release()
+		curMat.Release()
+	}
+	return cachedData
+}
+
+func benchmarkPoll(b *testing.B, release bool) {
+	prevMat := newPollMatrix(50_000, 10)
+	// warm up the pool
+	prevMat = poll(prevMat, release)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		prevMat = poll(prevMat, release)
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+}
+
+// BenchmarkPoll_50k compares the steady-state allocations of a perf collector poll with and without Release
+func BenchmarkPoll_50k(b *testing.B) {
+	b.Run("release", func(b *testing.B) {
+		benchmarkPoll(b, true)
+	})
+	b.Run("no-release", func(b *testing.B) {
+		benchmarkPoll(b, false)
+	})
+}
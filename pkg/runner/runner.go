@@ -0,0 +1,331 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+/*
+Package runner embeds Harvest collectors in other Go programs.
+
+A Runner loads the collectors of a poller from a Harvest config file, the same way the poller does, and polls them
+when Poll is called. Collectors that the poller does not list can be loaded too, with their default templates, instead of on their schedule. The collected matrices are returned to the caller instead of
+being exported. Use Matrix.MarshalBinary to serialize a matrix, e.g. to send it to a program in another language.
+
+	r, err := runner.New(runner.Options{Config: "harvest.yml", Poller: "cluster-01", Collectors: []string{"Rest"}})
+	if err != nil {
+		return err
+	}
+	matrices, err := r.Poll()
+
+The Options, Runner, and the serialization format of pkg/matrix are a stable API: they only change in a backward
+compatible way within a major version of Harvest. Other packages of Harvest may change in any release.
+
+Unlike a poller, a Runner does not negotiate between ZAPI and REST collectors, does not export metadata, and does not
+run the maintenance windows, hooks, or lease of the poller.
+*/
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/bus"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/logging"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"slices"
+	"strings"
+	"time"
+
+	// the collectors a Runner can load
+	_ "github.com/netapp/harvest/v2/cmd/collectors/configbackup"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/ems"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/health"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/keyperf"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/rest"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/restperf"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/simple"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/statperf"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/storagegrid"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/unix"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/zapi/collector"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/zapiperf"
+)
+
+// Options of a Runner
+type Options struct {
+	Config     string   // path of the Harvest config file, defaults to harvest.yml
+	Poller     string   // name of the poller in the config file, required
+	Collectors []string // collectors to load, e.g. Rest, defaults to the collectors of the poller
+	// Collectors that the poller does not list are loaded with their default templates
+	Objects  []string // objects to load, e.g. Volume, defaults to the objects of the templates of the collectors
+	ConfPath string   // colon-separated paths of the templates, defaults to the conf_path of the poller, or conf
+	Sources  bool     // record the sources of the parameters of the collectors, see Runner.Params
+}
+
+// Runner polls the collectors of a poller on demand.
+// It is not safe for concurrent use
+type Runner struct {
+	collectors []collector.Collector
+	delegates  []*collector.AbstractCollector // the abstract collector of each collector
+}
+
+// Params are the resolved parameters of a collector
+type Params struct {
+	Collector string            // collector:object
+	Params    *node.Node        // parameters after the templates, harvest.yml, and defaults are merged
+	Sources   map[string]string // source of each parameter by path, nil unless Options.Sources is true
+}
+
+// Cost is the estimated cost of a task of a collector
+type Cost struct {
+	Collector string // collector:object
+	collector.Estimate
+	Interval     time.Duration // interval of the task in the schedule of the collector
+	CallsPerHour float64
+	BytesPerHour float64
+}
+
+// New loads and initializes the collectors of a poller. Initializing a collector connects to its cluster.
+// When some collectors fail to initialize, New returns a Runner of the others, and the errors of the failed ones
+func New(o Options) (*Runner, error) {
+	if o.Poller == "" {
+		return nil, errs.New(errs.ErrMissingParam, "poller")
+	}
+	if _, err := conf.LoadHarvestConfig(o.Config); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", o.Config, err)
+	}
+	poller, err := conf.PollerNamed(o.Poller)
+	if err != nil {
+		return nil, err
+	}
+
+	confPath := o.ConfPath
+	if confPath == "" {
+		confPath = conf.DefaultConfPath
+		if poller.ConfPath != "" {
+			confPath = poller.ConfPath
+		}
+	}
+	opts := options.New(options.WithConfPath(confPath), options.WithConfigPath(o.Config))
+	opts.Poller = o.Poller
+	opts.Objects = o.Objects
+
+	logger := logging.Get().SubLogger("Runner", o.Poller)
+	credentials := auth.NewCredentials(poller, logger)
+	shared := bus.New()
+
+	r := &Runner{}
+	var errList []error
+	for _, c := range collectorsOf(poller, o.Collectors) {
+		template, sources, err := importTemplates(opts.ConfPaths, c)
+		if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		collector.Union2(template, poller)
+		template.NewChildS("poller_name", poller.Name)
+		if o.Sources {
+			// the poller's parameters only fill the parameters that the templates do not set
+			pollerParams := node.NewS("poller")
+			collector.Union2(pollerParams, poller)
+			sources = append([]collector.Source{{Name: opts.Config + " poller " + poller.Name, Params: pollerParams}}, sources...)
+		} else {
+			sources = nil
+		}
+
+		for _, object := range objects(template, o.Objects) {
+			col, delegate, err := newCollector(c.Name, object, opts, template, credentials, shared, sources)
+			if err != nil {
+				errList = append(errList, fmt.Errorf("failed to initialize %s:%s: %w", c.Name, object, err))
+				continue
+			}
+			r.collectors = append(r.collectors, col)
+			r.delegates = append(r.delegates, delegate)
+		}
+	}
+	if len(r.collectors) == 0 {
+		errList = append(errList, errs.New(errs.ErrNoCollector, "no collectors of poller "+o.Poller+" match"))
+		return nil, errors.Join(errList...)
+	}
+	return r, errors.Join(errList...)
+}
+
+// Collectors returns the names of the collectors of the Runner, as collector:object
+func (r *Runner) Collectors() []string {
+	names := make([]string, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		names = append(names, c.GetName()+":"+c.GetObject())
+	}
+	return names
+}
+
+// Result is the poll of a collector
+type Result struct {
+	Collector string // collector:object
+	Matrices  []*matrix.Matrix
+	Err       error
+	Duration  time.Duration // time the poll took
+}
+
+// Poll polls each collector once, and returns the matrices they collected, and the errors of the collectors that
+// failed. The matrices are valid until the next poll
+func (r *Runner) Poll() ([]*matrix.Matrix, error) {
+	var results []*matrix.Matrix
+	var errList []error
+	for _, result := range r.PollEach() {
+		results = append(results, result.Matrices...)
+		if result.Err != nil {
+			errList = append(errList, result.Err)
+		}
+	}
+	return results, errors.Join(errList...)
+}
+
+// PollEach polls each collector once, and returns the result of each collector, in the order of Collectors.
+// The matrices are valid until the next poll
+func (r *Runner) PollEach() []Result {
+	results := make([]Result, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		start := time.Now()
+		data, err := c.Poll()
+		results = append(results, Result{
+			Collector: c.GetName() + ":" + c.GetObject(),
+			Matrices:  data,
+			Err:       err,
+			Duration:  time.Since(start),
+		})
+	}
+	return results
+}
+
+// Params returns the resolved parameters of each collector of the Runner.
+// The sources of the parameters are only recorded when the Runner is created with Options.Sources
+func (r *Runner) Params() []Params {
+	result := make([]Params, 0, len(r.delegates))
+	for _, d := range r.delegates {
+		p := Params{Collector: d.Name + ":" + d.Object, Params: d.Params}
+		if d.Sources != nil {
+			p.Sources = collector.ParamSources(d.Params, d.Sources)
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// Estimate estimates the cost of each task of each collector of the Runner without polling data, from the instances of
+// its object, see collector.Estimator. It returns the errors of the collectors that can not estimate, e.g. Zapi
+func (r *Runner) Estimate() ([]Cost, error) {
+	var result []Cost
+	var errList []error
+	for _, c := range r.collectors {
+		name := c.GetName() + ":" + c.GetObject()
+		estimator, ok := c.(collector.Estimator)
+		if !ok {
+			errList = append(errList, errs.New(errs.ErrImplement, name+" can not estimate without polling"))
+			continue
+		}
+		estimates, err := estimator.Estimate()
+		if err != nil {
+			errList = append(errList, fmt.Errorf("failed to estimate %s: %w", name, err))
+			continue
+		}
+		for _, e := range estimates {
+			cost := Cost{Collector: name, Estimate: e}
+			if task := c.GetSchedule().GetTask(e.Task); task != nil {
+				cost.Interval = task.GetInterval()
+			}
+			cost.CallsPerHour, cost.BytesPerHour = e.PerHour(cost.Interval)
+			result = append(result, cost)
+		}
+	}
+	return result, errors.Join(errList...)
+}
+
+// collectorsOf returns the wanted collectors of a poller, or all its collectors when none are wanted.
+// Wanted collectors that the poller does not list use their default templates
+func collectorsOf(poller *conf.Poller, wanted []string) []conf.Collector {
+	if len(wanted) == 0 {
+		return poller.Collectors
+	}
+	result := make([]conf.Collector, 0, len(wanted))
+	for _, name := range wanted {
+		i := slices.IndexFunc(poller.Collectors, func(c conf.Collector) bool { return c.Name == name })
+		if i < 0 {
+			result = append(result, conf.NewCollector(name))
+			continue
+		}
+		result = append(result, poller.Collectors[i])
+	}
+	return result
+}
+
+// importTemplates merges the templates of a collector, like the poller does, and returns the merged template and
+// the templates it merged
+func importTemplates(confPaths []string, c conf.Collector) (*node.Node, []collector.Source, error) {
+	var template *node.Node
+	var sources []collector.Source
+	if c.Templates != nil {
+		for _, t := range *c.Templates {
+			path, err := collector.FindTemplate(confPaths, t, c.Name)
+			if err != nil {
+				continue
+			}
+			subTemplate, err := collector.ImportTemplate(confPaths, t, c.Name)
+			if err != nil {
+				continue
+			}
+			sources = append(sources, collector.Source{Name: path, Params: subTemplate.Copy()})
+			switch {
+			case template == nil:
+				template = subTemplate
+			case c.Name == "Zapi" || c.Name == "ZapiPerf":
+				// Do not overwrite child of objects. They will be concatenated
+				template.Merge(subTemplate, []string{"objects"})
+			default:
+				template.Merge(subTemplate, []string{""})
+			}
+		}
+	}
+	if template == nil {
+		return nil, nil, fmt.Errorf("no templates loaded for %s", c.Name)
+	}
+	return template, sources, nil
+}
+
+// objects returns the wanted objects, or the objects of template
+func objects(template *node.Node, wanted []string) []string {
+	if len(wanted) > 0 {
+		return wanted
+	}
+	if object := template.GetChildContentS("object"); object != "" {
+		return []string{object}
+	}
+	var all []string
+	if templateObjects := template.GetChildS("objects"); templateObjects != nil {
+		for _, object := range templateObjects.GetChildren() {
+			all = append(all, object.GetNameS())
+		}
+	}
+	return all
+}
+
+func newCollector(class string, object string, opts *options.Options, template *node.Node, credentials *auth.Credentials, shared *bus.Bus, sources []collector.Source) (collector.Collector, *collector.AbstractCollector, error) {
+	name := "harvest.collector." + strings.ToLower(class)
+	mod, err := plugin.GetModule(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting module %s err: %w", name, err)
+	}
+	col, ok := mod.New().(collector.Collector)
+	if !ok {
+		return nil, nil, errs.New(errs.ErrNoCollector, class)
+	}
+	delegate := collector.New(class, object, opts, template.Copy(), credentials)
+	delegate.Bus = shared
+	// each collector records the object templates it merges after the shared layers
+	delegate.Sources = slices.Clip(sources)
+	return col, delegate, col.Init(delegate)
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"testing"
+)
+
+func TestRunner(t *testing.T) {
+	r, err := New(Options{Config: "testdata/harvest.yml", Poller: "local", ConfPath: "../../conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Collectors(); len(got) != 1 || got[0] != "Simple:nodemon" {
+		t.Errorf("Collectors() got=%v, want=[Simple:nodemon]", got)
+	}
+
+	data, err := r.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Fatal("Poll() got no matrices")
+	}
+
+	// the matrices of a poll survive a round trip through the serialization format
+	for _, m := range data {
+		encoded, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded matrix.Matrix
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Object != m.Object || len(decoded.GetInstances()) != len(m.GetInstances()) {
+			t.Errorf("decoded %s got %d instances, want %d", decoded.Object, len(decoded.GetInstances()), len(m.GetInstances()))
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{name: "no poller", options: Options{Config: "testdata/harvest.yml"}},
+		{name: "unknown poller", options: Options{Config: "testdata/harvest.yml", Poller: "nope"}},
+		{name: "unknown collector", options: Options{Config: "testdata/harvest.yml", Poller: "local", Collectors: []string{"Nope"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.options); err == nil {
+				t.Errorf("New() got nil error")
+			}
+		})
+	}
+}
+
+func TestNewUnlistedCollectors(t *testing.T) {
+	// Simple is not listed by the poller, and Nope does not exist
+	r, err := New(Options{Config: "testdata/harvest.yml", Poller: "unlisted", Collectors: []string{"Simple", "Nope"}, ConfPath: "../../conf"})
+	if r == nil {
+		t.Fatalf("New() got no runner, err=%v", err)
+	}
+	if err == nil {
+		t.Errorf("New() got nil error for the unknown collector")
+	}
+	if got := r.Collectors(); len(got) != 1 || got[0] != "Simple:nodemon" {
+		t.Errorf("Collectors() got=%v, want=[Simple:nodemon]", got)
+	}
+}
+
+func TestRunnerParams(t *testing.T) {
+	r, err := New(Options{Config: "testdata/harvest.yml", Poller: "local", ConfPath: "../../conf", Sources: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := r.Params()
+	if len(params) != 1 || params[0].Collector != "Simple:nodemon" {
+		t.Fatalf("Params() got=%v, want Simple:nodemon", params)
+	}
+	tests := []struct {
+		path   string
+		source string
+	}{
+		{path: "datacenter", source: "testdata/harvest.yml poller local"},
+		{path: "schedule.data", source: "../../conf/simple/default.yaml"},
+		{path: "poller_name", source: "harvest"},
+	}
+	for _, tt := range tests {
+		if got := params[0].Sources[tt.path]; got != tt.source {
+			t.Errorf("source of %s got=%q, want=%q", tt.path, got, tt.source)
+		}
+	}
+}
+
+func TestRunnerEstimate(t *testing.T) {
+	r, err := New(Options{Config: "testdata/harvest.yml", Poller: "local", ConfPath: "../../conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simple monitors the local host, it can not estimate without polling
+	costs, err := r.Estimate()
+	if err == nil {
+		t.Errorf("Estimate() got nil error")
+	}
+	if len(costs) != 0 {
+		t.Errorf("Estimate() got=%v, want no costs", costs)
+	}
+}
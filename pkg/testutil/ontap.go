@@ -0,0 +1,268 @@
+// Package testutil has test seams shared by the tests of collectors and tools, e.g. a fake ONTAP REST server
+package testutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+const (
+	// FakeUsername and FakePassword are the credentials of the pollers returned by StartONTAP
+	FakeUsername = "admin"
+	FakePassword = "password"
+
+	// startParam is the query parameter of the next page of a collection
+	startParam = "start.index"
+)
+
+// ONTAP is a fake ONTAP REST server that serves the responses recorded in a directory, e.g. counter schemas
+// and rows, so collectors and retry logic can be tested without a cluster.
+//
+// The response of a request is read from the file named after its path, without the query, with slashes
+// replaced by dashes, as .json or .json.gz, e.g. api/storage/volumes?fields=name is served from
+// api-storage-volumes.json. The nth request of a path is served from api-storage-volumes.n.json when it exists,
+// so perf collectors can see counters change between polls. The last numbered file is repeated after that.
+// Collections are paged by the max_records parameter of the request.
+//
+// api/cluster is served from a generated cluster when it is not recorded. Requests without a recording are
+// answered with the 404 of ONTAP
+type ONTAP struct {
+	Username string // the basic auth of requests is not checked when Username is empty
+	Password string
+	Cluster  string // name of the generated cluster
+	Version  [3]int // version of the generated cluster
+
+	dir    string
+	mu     sync.Mutex
+	calls  map[string]int
+	faults []*Fault
+}
+
+// Fault is an injected failure or delay of the requests whose path starts with Path
+type Fault struct {
+	Path    string        // prefix of the path, e.g. api/storage/volumes, empty for every request
+	Status  int           // status of the response, e.g. 503, 0 to only delay the response
+	Latency time.Duration // delay before the response
+	Times   int           // number of requests the fault applies to, 0 for every request
+}
+
+// NewONTAP returns a fake ONTAP that serves the recordings of dir. It is an http.Handler, so demos can serve it
+// with http.ListenAndServeTLS. Harvest's REST client only speaks https
+func NewONTAP(dir string) *ONTAP {
+	return &ONTAP{
+		Cluster: "fake",
+		Version: [3]int{9, 14, 1},
+		dir:     dir,
+		calls:   make(map[string]int),
+	}
+}
+
+// StartONTAP starts a TLS server with a fake ONTAP that serves the recordings of dir, and returns the fake and
+// a poller that connects to it. The server is closed when the test ends
+func StartONTAP(t testing.TB, dir string) (*ONTAP, *conf.Poller) {
+	t.Helper()
+	o := NewONTAP(dir)
+	o.Username = FakeUsername
+	o.Password = FakePassword
+	server := httptest.NewTLSServer(o)
+	t.Cleanup(server.Close)
+
+	insecure := true
+	poller := &conf.Poller{
+		Name:           "fake",
+		Addr:           strings.TrimPrefix(server.URL, "https://"),
+		Username:       FakeUsername,
+		Password:       FakePassword,
+		UseInsecureTLS: &insecure,
+		ClientTimeout:  conf.DefaultTimeout,
+	}
+	return o, poller
+}
+
+// AddFault injects f into the responses of the fake. Faults are applied in the order they were added,
+// the first fault that matches a request wins
+func (o *ONTAP) AddFault(f Fault) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.faults = append(o.faults, &f)
+}
+
+// Calls returns the number of requests of path, e.g. api/storage/volumes, including failed requests
+func (o *ONTAP) Calls(path string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls[strings.Trim(path, "/")]
+}
+
+func (o *ONTAP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if o.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != o.Username || pass != o.Password {
+			writeError(w, http.StatusUnauthorized, "6691623", "User is not authorized")
+			return
+		}
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	o.mu.Lock()
+	o.calls[path]++
+	n := o.calls[path]
+	fault := o.fault(path)
+	o.mu.Unlock()
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault.Status != 0 {
+		writeError(w, fault.Status, strconv.Itoa(fault.Status), "injected fault")
+		return
+	}
+
+	body, err := o.recording(path, n)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "3", "API not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "1", err.Error())
+		return
+	}
+	body, err = page(r, body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "1", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/hal+json")
+	_, _ = w.Write(body)
+}
+
+// fault returns the first matching fault of path, and counts it. The caller must hold the lock
+func (o *ONTAP) fault(path string) Fault {
+	for i, f := range o.faults {
+		if !strings.HasPrefix(path, strings.Trim(f.Path, "/")) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				o.faults = append(o.faults[:i:i], o.faults[i+1:]...)
+			}
+		}
+		return *f
+	}
+	return Fault{}
+}
+
+// recording returns the recorded response of the nth request of path
+func (o *ONTAP) recording(path string, n int) ([]byte, error) {
+	name := filepath.Join(o.dir, strings.ReplaceAll(path, "/", "-"))
+	for i := n; i > 0; i-- {
+		body, err := readRecording(name + "." + strconv.Itoa(i))
+		if !errors.Is(err, os.ErrNotExist) {
+			return body, err
+		}
+	}
+	body, err := readRecording(name)
+	if errors.Is(err, os.ErrNotExist) && path == "api/cluster" {
+		return o.cluster()
+	}
+	return body, err
+}
+
+func (o *ONTAP) cluster() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"name": o.Cluster,
+		"uuid": "00000000-0000-0000-0000-000000000000",
+		"version": map[string]any{
+			"full":       fmt.Sprintf("NetApp Release %d.%d.%d: fake", o.Version[0], o.Version[1], o.Version[2]),
+			"generation": o.Version[0],
+			"major":      o.Version[1],
+			"minor":      o.Version[2],
+		},
+	})
+}
+
+// readRecording reads name.json, or name.json.gz
+func readRecording(name string) ([]byte, error) {
+	body, err := os.ReadFile(name + ".json")
+	if !errors.Is(err, os.ErrNotExist) {
+		return body, err
+	}
+	f, err := os.Open(name + ".json.gz")
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, gz); err != nil { //nolint:gosec
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// page returns the page of the records of body asked for by the max_records and start.index parameters
+// of r, with a link to the next page. Responses without records, e.g. api/cluster, are returned as is
+func page(r *http.Request, body []byte) ([]byte, error) {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", r.URL.Path, err)
+	}
+	raw, ok := response["records"]
+	if !ok {
+		return body, nil
+	}
+	var records []json.RawMessage
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, fmt.Errorf("invalid records %s: %w", r.URL.Path, err)
+	}
+
+	query := r.URL.Query()
+	start, _ := strconv.Atoi(query.Get(startParam))
+	start = min(max(start, 0), len(records))
+	end := len(records)
+	if maxRecords, err := strconv.Atoi(query.Get("max_records")); err == nil && maxRecords > 0 {
+		end = min(start+maxRecords, len(records))
+	}
+
+	links := map[string]any{"self": map[string]string{"href": r.URL.RequestURI()}}
+	if end < len(records) {
+		query.Set(startParam, strconv.Itoa(end))
+		links["next"] = map[string]string{"href": r.URL.Path + "?" + query.Encode()}
+	}
+	response["records"], _ = json.Marshal(records[start:end])
+	response["num_records"], _ = json.Marshal(end - start)
+	response["_links"], _ = json.Marshal(links)
+	return json.Marshal(response)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/hal+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"message": message, "code": code},
+	})
+}
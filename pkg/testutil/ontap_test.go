@@ -0,0 +1,148 @@
+package testutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRecordings(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		if filepath.Ext(name) == ".gz" {
+			var b bytes.Buffer
+			gz := gzip.NewWriter(&b)
+			_, _ = gz.Write([]byte(body))
+			_ = gz.Close()
+			body = b.String()
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatalf("failed to write %s err=%v", name, err)
+		}
+	}
+	return dir
+}
+
+func get(t *testing.T, server *httptest.Server, path string) (int, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.SetBasicAuth(FakeUsername, FakePassword)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET %s err=%v", path, err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var response map[string]any
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("GET %s invalid json=%s err=%v", path, body, err)
+	}
+	return resp.StatusCode, response
+}
+
+func TestONTAP_Recordings(t *testing.T) {
+	dir := writeRecordings(t, map[string]string{
+		"api-storage-volumes.json":                      `{"records":[{"name":"vol1"},{"name":"vol2"},{"name":"vol3"}],"num_records":3}`,
+		"api-cluster-counter-tables-volume.json.gz":     `{"name":"volume","counter_schemas":[{"name":"read_ops"}]}`,
+		"api-cluster-counter-tables-volume-rows.json":   `{"records":[{"id":"vol1","counters":[{"name":"read_ops","value":1}]}]}`,
+		"api-cluster-counter-tables-volume-rows.2.json": `{"records":[{"id":"vol1","counters":[{"name":"read_ops","value":2}]}]}`,
+	})
+	o := NewONTAP(dir)
+	o.Username = FakeUsername
+	o.Password = FakePassword
+	server := httptest.NewTLSServer(o)
+	defer server.Close()
+
+	status, response := get(t, server, "/api/cluster?fields=*")
+	if status != http.StatusOK || response["name"] != "fake" {
+		t.Errorf("generated cluster status=%d response=%v", status, response)
+	}
+
+	status, response = get(t, server, "/api/cluster/counter/tables/volume?return_records=true")
+	if status != http.StatusOK || response["name"] != "volume" {
+		t.Errorf("gzipped recording status=%d response=%v", status, response)
+	}
+
+	// the second poll is served from the .2 recording, and the last recording is repeated after that
+	wants := []float64{1, 2, 2}
+	for i, want := range wants {
+		_, response = get(t, server, "/api/cluster/counter/tables/volume/rows?fields=*")
+		records := response["records"].([]any)
+		counter := records[0].(map[string]any)["counters"].([]any)[0].(map[string]any)
+		if counter["value"] != want {
+			t.Errorf("poll %d value got=%v, want=%v", i+1, counter["value"], want)
+		}
+	}
+	if got := o.Calls("api/cluster/counter/tables/volume/rows"); got != len(wants) {
+		t.Errorf("calls got=%d, want=%d", got, len(wants))
+	}
+
+	status, _ = get(t, server, "/api/storage/luns")
+	if status != http.StatusNotFound {
+		t.Errorf("missing recording status got=%d, want=%d", status, http.StatusNotFound)
+	}
+}
+
+func TestONTAP_Paging(t *testing.T) {
+	dir := writeRecordings(t, map[string]string{
+		"api-storage-volumes.json": `{"records":[{"name":"vol1"},{"name":"vol2"},{"name":"vol3"}],"num_records":3}`,
+	})
+	server := httptest.NewTLSServer(NewONTAP(dir))
+	defer server.Close()
+
+	var names []any
+	path := "/api/storage/volumes?fields=name&max_records=2"
+	for path != "" {
+		_, response := get(t, server, path)
+		for _, r := range response["records"].([]any) {
+			names = append(names, r.(map[string]any)["name"])
+		}
+		path = ""
+		if next, ok := response["_links"].(map[string]any)["next"]; ok {
+			path = next.(map[string]any)["href"].(string)
+		}
+	}
+	if len(names) != 3 || names[0] != "vol1" || names[2] != "vol3" {
+		t.Errorf("paged records got=%v", names)
+	}
+}
+
+func TestONTAP_Faults(t *testing.T) {
+	o := NewONTAP(t.TempDir())
+	o.Username = FakeUsername
+	o.Password = FakePassword
+	server := httptest.NewTLSServer(o)
+	defer server.Close()
+
+	o.AddFault(Fault{Path: "api/cluster", Status: http.StatusServiceUnavailable, Times: 2})
+	o.AddFault(Fault{Path: "api/cluster", Latency: 50 * time.Millisecond})
+	wants := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}
+	for i, want := range wants {
+		start := time.Now()
+		status, response := get(t, server, "/api/cluster")
+		if status != want {
+			t.Errorf("request %d status got=%d, want=%d response=%v", i+1, status, want, response)
+		}
+		if want == http.StatusOK && time.Since(start) < 50*time.Millisecond {
+			t.Errorf("request %d was not delayed", i+1)
+		}
+	}
+
+	resp, err := server.Client().Get(server.URL + "/api/cluster")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("request without auth status got=%d, want=%d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,221 @@
+// Package tlsconf builds the TLS configs of the clients and servers of Harvest, so the pollers, exporters, and the
+// admin node read the same TLS options from harvest.yml and share their defaults
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultServerMinVersion is the minimum TLS version of the servers of Harvest, e.g. the Prometheus exporter
+// and the admin node, when Options.MinVersion is empty
+const DefaultServerMinVersion = tls.VersionTLS13
+
+// Options are the TLS options of a client or server
+type Options struct {
+	CAFile             string   // PEM certificates that replace the host's root CAs, clients only
+	InsecureSkipVerify bool     // do not verify the certificate of the server, clients only
+	MinVersion         string   // one of tls10, tls11, tls12, or tls13
+	CipherSuites       []string // names of the TLS 1.0 - 1.2 cipher suites, the TLS 1.3 cipher suites are not configurable
+	Renegotiation      string   // one of never, once, or freely, clients only
+}
+
+// Client returns the TLS config of a client. The minimum version of clients is decided by the platform when
+// MinVersion is empty.
+// The config is usable even when err is not nil: invalid options are reported in err and left at their defaults,
+// so callers can decide whether to warn or to fail
+func (o Options) Client() (*tls.Config, error) {
+	var errList []error
+	config := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if o.CAFile != "" {
+		pool, err := CertPool(o.CAFile)
+		if err != nil {
+			errList = append(errList, err)
+		}
+		config.RootCAs = pool
+	}
+	if o.Renegotiation != "" {
+		renegotiation, ok := Renegotiation(o.Renegotiation)
+		if !ok {
+			errList = append(errList, fmt.Errorf("invalid renegotiation %q, use one of never, once, or freely", o.Renegotiation))
+		}
+		config.Renegotiation = renegotiation
+	}
+	errList = append(errList, o.setVersionAndCiphers(config, 0))
+
+	return config, errors.Join(errList...)
+}
+
+// Server returns the TLS config of a server, its minimum version is DefaultServerMinVersion when MinVersion
+// is empty. See Client for how invalid options are reported
+func (o Options) Server() (*tls.Config, error) {
+	config := &tls.Config{}
+	err := o.setVersionAndCiphers(config, DefaultServerMinVersion)
+	return config, err
+}
+
+// Validate returns the invalid options, it does not check CAFile since it is read when the config is built
+func (o Options) Validate() error {
+	var errList []error
+	if o.MinVersion != "" {
+		if _, ok := Version(o.MinVersion); !ok {
+			errList = append(errList, invalidVersion(o.MinVersion))
+		}
+	}
+	if _, err := CipherSuites(o.CipherSuites); err != nil {
+		errList = append(errList, err)
+	}
+	if o.Renegotiation != "" {
+		if _, ok := Renegotiation(o.Renegotiation); !ok {
+			errList = append(errList, fmt.Errorf("invalid renegotiation %q, use one of never, once, or freely", o.Renegotiation))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+func (o Options) setVersionAndCiphers(config *tls.Config, defaultVersion uint16) error {
+	var errList []error
+	config.MinVersion = defaultVersion
+	if o.MinVersion != "" {
+		if version, ok := Version(o.MinVersion); ok {
+			config.MinVersion = version
+		} else {
+			errList = append(errList, invalidVersion(o.MinVersion))
+		}
+	}
+	suites, err := CipherSuites(o.CipherSuites)
+	if err != nil {
+		errList = append(errList, err)
+	}
+	config.CipherSuites = suites
+	return errors.Join(errList...)
+}
+
+// Version converts one of tls10, tls11, tls12, or tls13 to its TLS version
+func Version(version string) (uint16, bool) {
+	switch strings.ToLower(version) {
+	case "tls10":
+		return tls.VersionTLS10, true
+	case "tls11":
+		return tls.VersionTLS11, true
+	case "tls12":
+		return tls.VersionTLS12, true
+	case "tls13":
+		return tls.VersionTLS13, true
+	}
+	return 0, false
+}
+
+func invalidVersion(version string) error {
+	return fmt.Errorf("invalid TLS version %q, use one of tls10, tls11, tls12, or tls13", version)
+}
+
+// Renegotiation converts one of never, once, or freely to its renegotiation support.
+// Older clusters, e.g. 7-mode, may ask the client to renegotiate after the handshake
+func Renegotiation(renegotiation string) (tls.RenegotiationSupport, bool) {
+	switch strings.ToLower(renegotiation) {
+	case "never":
+		return tls.RenegotiateNever, true
+	case "once":
+		return tls.RenegotiateOnceAsClient, true
+	case "freely":
+		return tls.RenegotiateFreelyAsClient, true
+	}
+	return tls.RenegotiateNever, false
+}
+
+// CipherSuites converts the names of cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, to their IDs.
+// Insecure cipher suites are allowed, since older clusters may only support them. Unknown names are skipped
+// and reported in err. It returns nil when names is empty, so Go picks the cipher suites
+func CipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	var errList []error
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := ids[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			errList = append(errList, fmt.Errorf("unknown cipher suite %q", name))
+			continue
+		}
+		suites = append(suites, id)
+	}
+	if len(suites) == 0 {
+		suites = nil
+	}
+	return suites, errors.Join(errList...)
+}
+
+// CertPool returns a pool with the PEM certificates of caFile. It returns nil, so the host's root CAs are used,
+// when caFile can not be read or has no certificates
+func CertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to parse CA certificate %s", caFile)
+	}
+	return pool, nil
+}
+
+// Expiry returns when the first of the PEM certificates of file expires, e.g. a certificate or one of its chain,
+// or one of the CAs of a bundle
+func Expiry(file string) (time.Time, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate %s: %w", file, err)
+	}
+	var ders [][]byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+	if len(ders) == 0 {
+		return time.Time{}, fmt.Errorf("failed to parse certificate %s: no PEM certificate", file)
+	}
+	expiry, err := ChainExpiry(ders)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse certificate %s: %w", file, err)
+	}
+	return expiry, nil
+}
+
+// ChainExpiry returns when the first of the DER certificates of a chain expires, e.g. the Certificate of a
+// tls.Certificate
+func ChainExpiry(chain [][]byte) (time.Time, error) {
+	var expiry time.Time
+	for _, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	if expiry.IsZero() {
+		return time.Time{}, errors.New("no certificate")
+	}
+	return expiry, nil
+}
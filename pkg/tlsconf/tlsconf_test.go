@@ -0,0 +1,174 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOptions_Client(t *testing.T) {
+	caFile := writeCA(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		options           Options
+		wantMinVersion    uint16
+		wantCiphers       []uint16
+		wantRenegotiation tls.RenegotiationSupport
+		wantRootCAs       bool
+		wantErr           bool
+	}{
+		{name: "defaults"},
+		{name: "min version", options: Options{MinVersion: "TLS12"}, wantMinVersion: tls.VersionTLS12},
+		{name: "unknown min version", options: Options{MinVersion: "tls1"}, wantErr: true},
+		{
+			name:        "cipher suites",
+			options:     Options{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " tls_rsa_with_aes_128_cbc_sha "}},
+			wantCiphers: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		},
+		{
+			name:        "unknown cipher suite",
+			options:     Options{CipherSuites: []string{"TLS_NOPE", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			wantCiphers: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			wantErr:     true,
+		},
+		{name: "renegotiate once", options: Options{Renegotiation: "once"}, wantRenegotiation: tls.RenegotiateOnceAsClient},
+		{name: "unknown renegotiation", options: Options{Renegotiation: "always"}, wantErr: true},
+		{name: "ca file", options: Options{CAFile: caFile}, wantRootCAs: true},
+		{name: "missing ca file", options: Options{CAFile: "testdata/missing.pem"}, wantErr: true},
+		{name: "ca file without certificates", options: Options{CAFile: notPEM}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.options.Client()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if config == nil {
+				t.Fatal("Client() config is nil")
+			}
+			if config.MinVersion != tt.wantMinVersion {
+				t.Errorf("MinVersion got=%d, want=%d", config.MinVersion, tt.wantMinVersion)
+			}
+			if !slices.Equal(config.CipherSuites, tt.wantCiphers) {
+				t.Errorf("CipherSuites got=%v, want=%v", config.CipherSuites, tt.wantCiphers)
+			}
+			if config.Renegotiation != tt.wantRenegotiation {
+				t.Errorf("Renegotiation got=%d, want=%d", config.Renegotiation, tt.wantRenegotiation)
+			}
+			if (config.RootCAs != nil) != tt.wantRootCAs {
+				t.Errorf("RootCAs got=%v, want=%t", config.RootCAs != nil, tt.wantRootCAs)
+			}
+			if (tt.options.Validate() != nil) != (tt.wantErr && tt.options.CAFile == "") {
+				t.Errorf("Validate() err=%v", tt.options.Validate())
+			}
+		})
+	}
+}
+
+func TestOptions_Server(t *testing.T) {
+	tests := []struct {
+		name           string
+		options        Options
+		wantMinVersion uint16
+		wantErr        bool
+	}{
+		{name: "default", wantMinVersion: DefaultServerMinVersion},
+		{name: "min version", options: Options{MinVersion: "tls12"}, wantMinVersion: tls.VersionTLS12},
+		{name: "unknown min version", options: Options{MinVersion: "ssl3"}, wantMinVersion: DefaultServerMinVersion, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.options.Server()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Server() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if config.MinVersion != tt.wantMinVersion {
+				t.Errorf("MinVersion got=%d, want=%d", config.MinVersion, tt.wantMinVersion)
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	later := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")})
+
+	tests := []struct {
+		name    string
+		file    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "certificate", file: write("cert.pem", certificatePEM(t, soon)), want: soon},
+		{name: "bundle", file: write("bundle.pem", slices.Concat(certificatePEM(t, later), key, certificatePEM(t, soon))), want: soon},
+		{name: "no certificate", file: write("key.pem", key), wantErr: true},
+		{name: "missing", file: filepath.Join(dir, "missing.pem"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expiry(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expiry() err=%v, wantErr=%t", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Expiry() got=%s, want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeCA writes a self-signed certificate to a temp file and returns its path
+func writeCA(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, certificatePEM(t, time.Now().Add(time.Hour)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// certificatePEM returns a self-signed PEM certificate that expires at notAfter
+func certificatePEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "harvest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
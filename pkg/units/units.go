@@ -0,0 +1,68 @@
+/*
+ * Copyright NetApp Inc, 2024 All rights reserved
+ */
+
+// Package units converts the units of ONTAP counters, e.g. microsec or kb_per_sec,
+// to the canonical units of exported metrics, seconds and bytes
+package units
+
+import (
+	"strings"
+)
+
+// Canonical units, named like ONTAP names units
+const (
+	Seconds        = "sec"
+	Bytes          = "b"
+	BytesPerSecond = "b_per_sec"
+)
+
+// blockSize is the size of a WAFL block
+const blockSize = 4096
+
+// Conversion converts values of one unit to a canonical unit
+type Conversion struct {
+	Factor float64 // values are multiplied by Factor
+	Unit   string  // canonical unit
+}
+
+var conversions = map[string]Conversion{
+	"microsec":     {Factor: 1e-6, Unit: Seconds},
+	"microseconds": {Factor: 1e-6, Unit: Seconds},
+	"millisec":     {Factor: 1e-3, Unit: Seconds},
+	"sec":          {Factor: 1, Unit: Seconds},
+	"seconds":      {Factor: 1, Unit: Seconds},
+	"b":            {Factor: 1, Unit: Bytes},
+	"bytes":        {Factor: 1, Unit: Bytes},
+	"kb":           {Factor: 1 << 10, Unit: Bytes},
+	"mb":           {Factor: 1 << 20, Unit: Bytes},
+	"gb":           {Factor: 1 << 30, Unit: Bytes},
+	"blocks":       {Factor: blockSize, Unit: Bytes},
+	"4kb_blocks":   {Factor: blockSize, Unit: Bytes},
+	"b_per_sec":    {Factor: 1, Unit: BytesPerSecond},
+	"kb_per_sec":   {Factor: 1 << 10, Unit: BytesPerSecond},
+	"mb_per_sec":   {Factor: 1 << 20, Unit: BytesPerSecond},
+}
+
+// suffixes are the suffixes of metric names in canonical units, following the Prometheus naming conventions
+var suffixes = map[string]string{
+	Seconds:        "_seconds",
+	Bytes:          "_bytes",
+	BytesPerSecond: "_bytes_per_second",
+}
+
+// Canonical returns the conversion of unit, case-insensitive, and false when unit has no canonical unit,
+// e.g. per_sec, percent, or none
+func Canonical(unit string) (Conversion, bool) {
+	c, ok := conversions[strings.ToLower(unit)]
+	return c, ok
+}
+
+// Name returns name with the suffix of the canonical unit, unless name already ends with it
+func Name(name string, canonical string) string {
+	suffix := suffixes[canonical]
+	if strings.HasSuffix(name, suffix) {
+		return name
+	}
+	return name + suffix
+}
@@ -0,0 +1,36 @@
+package units
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		unit     string
+		want     Conversion
+		wantName string
+		ok       bool
+	}{
+		{unit: "microsec", want: Conversion{Factor: 1e-6, Unit: Seconds}, wantName: "latency_seconds", ok: true},
+		{unit: "MilliSec", want: Conversion{Factor: 1e-3, Unit: Seconds}, wantName: "latency_seconds", ok: true},
+		{unit: "kb_per_sec", want: Conversion{Factor: 1024, Unit: BytesPerSecond}, wantName: "latency_bytes_per_second", ok: true},
+		{unit: "blocks", want: Conversion{Factor: 4096, Unit: Bytes}, wantName: "latency_bytes", ok: true},
+		{unit: "per_sec"},
+		{unit: "percent"},
+		{unit: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			got, ok := Canonical(tt.unit)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Canonical() got=%v %t, want=%v %t", got, ok, tt.want, tt.ok)
+			}
+			if ok {
+				if name := Name("latency", got.Unit); name != tt.wantName {
+					t.Errorf("Name() got=%s, want=%s", name, tt.wantName)
+				}
+			}
+		})
+	}
+	if name := Name("size_bytes", Bytes); name != "size_bytes" {
+		t.Errorf("Name() should not repeat the suffix got=%s", name)
+	}
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// BudgetWindow is the rolling window of a Budget
+	BudgetWindow = 15 * time.Minute
+	// budgetMaxSamples bounds the memory of a Budget, the oldest samples are dropped first
+	budgetMaxSamples = 20_000
+)
+
+// Error classes of API calls
+const (
+	ClassOK               = "ok"
+	ClassAuthFailed       = "auth_failed"
+	ClassPermissionDenied = "permission_denied"
+	ClassClientError      = "client_error"
+	ClassServerError      = "server_error"
+	ClassTimeout          = "timeout"
+	ClassConnectionError  = "connection_error"
+)
+
+// ErrorClasses are the error classes of failed API calls
+var ErrorClasses = []string{ClassAuthFailed, ClassPermissionDenied, ClassClientError, ClassServerError, ClassTimeout,
+	ClassConnectionError}
+
+// Budget tracks the outcome and latency of the API calls to a cluster over a rolling window, to report the error
+// budget of the management plane of the cluster. A Budget is safe for concurrent use
+type Budget struct {
+	mu      sync.Mutex
+	window  time.Duration
+	samples []budgetSample
+	now     func() time.Time
+}
+
+type budgetSample struct {
+	at      time.Time
+	latency time.Duration
+	class   string
+}
+
+// BudgetStats are the stats of the calls of a Budget in its window
+type BudgetStats struct {
+	Calls        uint64
+	SuccessRatio float64 // 1 when there were no calls
+	P95Latency   time.Duration
+	Errors       map[string]uint64 // calls by error class, without ClassOK
+}
+
+func NewBudget(window time.Duration) *Budget {
+	return &Budget{window: window, now: time.Now}
+}
+
+// Record adds a call that took latency and ended with the error class
+func (b *Budget) Record(latency time.Duration, class string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.prune(now)
+	if len(b.samples) >= budgetMaxSamples {
+		b.samples = slices.Delete(b.samples, 0, len(b.samples)-budgetMaxSamples+1)
+	}
+	b.samples = append(b.samples, budgetSample{at: now, latency: latency, class: class})
+}
+
+// Stats returns the stats of the calls in the window. Client errors, e.g. a field the cluster does not support,
+// are counted in Errors, but do not lower the success ratio, since they do not mean that the cluster is degraded
+func (b *Budget) Stats() BudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prune(b.now())
+
+	stats := BudgetStats{
+		Calls:        uint64(len(b.samples)),
+		SuccessRatio: 1,
+		Errors:       make(map[string]uint64),
+	}
+	if len(b.samples) == 0 {
+		return stats
+	}
+
+	latencies := make([]time.Duration, 0, len(b.samples))
+	failed := 0
+	for _, s := range b.samples {
+		latencies = append(latencies, s.latency)
+		if s.class == ClassOK {
+			continue
+		}
+		stats.Errors[s.class]++
+		if s.class != ClassClientError {
+			failed++
+		}
+	}
+	stats.SuccessRatio = 1 - float64(failed)/float64(len(b.samples))
+	stats.P95Latency = percentile(latencies, 95)
+	return stats
+}
+
+// prune removes the samples older than the window. Samples are ordered by time
+func (b *Budget) prune(now time.Time) {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.samples) && b.samples[i].at.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		b.samples = slices.Delete(b.samples, 0, i)
+	}
+}
+
+// percentile returns the nearest-rank percentile p of durations. durations is sorted in place
+func percentile(durations []time.Duration, p int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	slices.Sort(durations)
+	rank := (p*len(durations) + 99) / 100
+	return durations[max(rank, 1)-1]
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestBudget_Stats(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBudget(time.Minute)
+	b.now = func() time.Time { return now }
+
+	// outside the window when Stats is called
+	b.Record(10*time.Second, ClassServerError)
+	now = now.Add(2 * time.Minute)
+
+	for i := 1; i <= 16; i++ {
+		b.Record(time.Duration(i)*time.Millisecond, ClassOK)
+	}
+	b.Record(100*time.Millisecond, ClassTimeout)
+	b.Record(200*time.Millisecond, ClassServerError)
+	b.Record(300*time.Millisecond, ClassClientError)
+	b.Record(400*time.Millisecond, ClassClientError)
+
+	got := b.Stats()
+	if got.Calls != 20 {
+		t.Errorf("Calls got=%d, want=%d", got.Calls, 20)
+	}
+	// client errors do not count against the budget
+	if got.SuccessRatio != 0.9 {
+		t.Errorf("SuccessRatio got=%v, want=%v", got.SuccessRatio, 0.9)
+	}
+	if got.P95Latency != 300*time.Millisecond {
+		t.Errorf("P95Latency got=%v, want=%v", got.P95Latency, 300*time.Millisecond)
+	}
+	wantErrors := map[string]uint64{ClassTimeout: 1, ClassServerError: 1, ClassClientError: 2}
+	if !maps.Equal(got.Errors, wantErrors) {
+		t.Errorf("Errors got=%v, want=%v", got.Errors, wantErrors)
+	}
+
+	now = now.Add(2 * time.Minute)
+	got = b.Stats()
+	if got.Calls != 0 || got.SuccessRatio != 1 || got.P95Latency != 0 {
+		t.Errorf("empty window got=%+v", got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []time.Duration
+		p    int
+		want time.Duration
+	}{
+		{name: "empty", in: nil, p: 95, want: 0},
+		{name: "one", in: []time.Duration{5}, p: 95, want: 5},
+		{name: "unsorted", in: []time.Duration{3, 1, 2, 4}, p: 50, want: 2},
+		{name: "p95 of 10", in: []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, p: 95, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.in, tt.p); got != tt.want {
+				t.Errorf("percentile() got=%v, want=%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,15 @@ package util
 
 type Metadata struct {
 	BytesRx         uint64
+	BytesRxWire     uint64 // bytes received before decompression
 	NumCalls        uint64
 	PluginInstances uint64
+	Budget          *Budget // error budget of the cluster, shared by the clients of the cluster and not reset
 }
 
 func (m *Metadata) Reset() {
 	m.BytesRx = 0
+	m.BytesRxWire = 0
 	m.NumCalls = 0
 	m.PluginInstances = 0
 }
@@ -27,15 +27,19 @@ import (
 var arrayRegex = regexp.MustCompile(`^([a-zA-Z][\w.]*)(\.[0-9#])`)
 
 var IsCollector = map[string]struct{}{
-	"ZapiPerf":    {},
-	"Zapi":        {},
-	"Rest":        {},
-	"RestPerf":    {},
-	"KeyPerf":     {},
-	"Ems":         {},
-	"StorageGrid": {},
-	"Unix":        {},
-	"Simple":      {},
+	"Aiqum":        {},
+	"ZapiPerf":     {},
+	"Zapi":         {},
+	"Rest":         {},
+	"RestPerf":     {},
+	"KeyPerf":      {},
+	"Ems":          {},
+	"StorageGrid":  {},
+	"Unix":         {},
+	"Simple":       {},
+	"Health":       {},
+	"ConfigBackup": {},
+	"StatPerf":     {},
 }
 
 func GetCollectorSlice() []string {